@@ -2,6 +2,7 @@ package main
 
 import (
 	"context"
+	"encoding/json"
 	"errors"
 	"fmt"
 	"os"
@@ -11,7 +12,10 @@ import (
 	"github.com/tsosunchia/iNetSpeed-CLI/internal/config"
 	"github.com/tsosunchia/iNetSpeed-CLI/internal/i18n"
 	"github.com/tsosunchia/iNetSpeed-CLI/internal/render"
+	"github.com/tsosunchia/iNetSpeed-CLI/internal/report"
 	"github.com/tsosunchia/iNetSpeed-CLI/internal/runner"
+	"github.com/tsosunchia/iNetSpeed-CLI/internal/units"
+	"github.com/tsosunchia/iNetSpeed-CLI/internal/update"
 )
 
 var (
@@ -21,11 +25,19 @@ var (
 )
 
 func main() {
+	report.Version = version
 	i18n.SetFromEnv()
 	if lang, ok := i18n.FindLangArg(os.Args[1:]); ok {
 		i18n.Set(lang)
 	}
+	units.SetLocaleFromEnv()
 
+	if len(os.Args) > 1 && os.Args[1] == "version" {
+		enc := json.NewEncoder(os.Stdout)
+		enc.SetIndent("", "  ")
+		enc.Encode(update.Current(version, commit, date))
+		os.Exit(0)
+	}
 	if isVersionRequest(os.Args[1:]) {
 		fmt.Printf(i18n.Text("speedtest %s (commit %s, built %s)\n", "speedtest %s（commit %s，构建于 %s）\n"), version, commit, date)
 		os.Exit(0)
@@ -42,13 +54,57 @@ func main() {
 		fmt.Fprint(os.Stderr, config.Usage())
 		os.Exit(1)
 	}
+	units.Set(cfg.Units)
 
 	var r render.Renderer
+	var tui *render.TUIRenderer
 	isTTY := render.IsTTY()
-	if isTTY {
-		r = render.NewTTYRenderer()
-	} else {
-		r = render.NewPlainRenderer(os.Stderr)
+	switch {
+	case cfg.Quiet, cfg.Widget:
+		isTTY = false
+		r = render.NewQuietRenderer(os.Stderr)
+	case isTTY && cfg.TUI && render.EnableANSI():
+		// The dashboard owns the screen, so nothing may prompt for input.
+		isTTY = false
+		tui = render.NewTUIRenderer()
+		tui.Verbose = cfg.Verbose
+		tui.NoColor = cfg.NoColor
+		tui.Start()
+		r = tui
+	case isTTY:
+		tr := render.NewTTYRenderer()
+		tr.Verbose = cfg.Verbose
+		// Consoles without VT support would print the escapes literally.
+		tr.NoColor = cfg.NoColor || !render.EnableANSI()
+		r = tr
+	default:
+		pr := render.NewPlainRenderer(os.Stderr)
+		pr.Verbose = cfg.Verbose
+		r = pr
+	}
+
+	var events *render.EventLog
+	var eventFile *os.File
+	if cfg.EventLog != "" {
+		if eventFile, err = os.Create(cfg.EventLog); err != nil {
+			fmt.Fprintf(os.Stderr, "  [\u2717] %s\n", err)
+			os.Exit(1)
+		}
+		events = render.NewEventLog(eventFile)
+		r = render.Multi(r, events)
+	}
+
+	var logFile *os.File
+	if cfg.LogFile != "" {
+		if logFile, err = os.Create(cfg.LogFile); err != nil {
+			fmt.Fprintf(os.Stderr, "  [\u2717] %s\n", err)
+			os.Exit(1)
+		}
+		// The plain renderer, whatever renders the terminal: no escapes,
+		// and progress once per tenth rather than redrawn in place.
+		lr := render.NewPlainRenderer(logFile)
+		lr.Verbose = cfg.Verbose
+		r = render.Multi(r, lr)
 	}
 
 	bus := render.NewBus(r)
@@ -56,14 +112,51 @@ func main() {
 	ctx, stop := signal.NotifyContext(context.Background(), syscall.SIGINT, syscall.SIGTERM)
 	defer stop()
 
-	exitCode := runner.Run(ctx, cfg, bus, isTTY)
+	var exitCode int
+	switch {
+	case cfg.Demo:
+		exitCode = runner.Demo(ctx, cfg, bus)
+	case cfg.Register:
+		exitCode = runner.Register(ctx, cfg, bus)
+	case cfg.Remote:
+		exitCode = runner.Remote(ctx, cfg, bus)
+	case cfg.Widget:
+		exitCode = runner.Widget(ctx, cfg, bus)
+	case cfg.Check:
+		exitCode = runner.Check(ctx, cfg, bus)
+	case cfg.Note:
+		exitCode = runner.Note(cfg, bus)
+	case cfg.Server:
+		exitCode = runner.Server(ctx, cfg, bus)
+	case cfg.SelfUpdate:
+		exitCode = runner.SelfUpdate(ctx, bus)
+	default:
+		exitCode = runner.Run(ctx, cfg, bus, isTTY)
+	}
 	bus.Close()
+	if tui != nil {
+		tui.Close()
+	}
+	if events != nil {
+		err := events.Err()
+		if cerr := eventFile.Close(); err == nil {
+			err = cerr
+		}
+		if err != nil {
+			fmt.Fprintf(os.Stderr, i18n.Text("  [!] Event log incomplete: %v\n", "  [!] 事件日志不完整: %v\n"), err)
+		}
+	}
+	if logFile != nil {
+		if err := logFile.Close(); err != nil {
+			fmt.Fprintf(os.Stderr, i18n.Text("  [!] Log file incomplete: %v\n", "  [!] 日志文件不完整: %v\n"), err)
+		}
+	}
 	os.Exit(exitCode)
 }
 
 func isVersionRequest(args []string) bool {
 	for _, arg := range args {
-		if arg == "-v" || arg == "--version" || arg == "version" {
+		if arg == "-v" || arg == "--version" {
 			return true
 		}
 	}
@@ -2,6 +2,8 @@ module github.com/tsosunchia/iNetSpeed-CLI
 
 go 1.25.0
 
-require golang.org/x/net v0.50.0
-
-require golang.org/x/text v0.34.0 // indirect
+require (
+	golang.org/x/net v0.50.0
+	golang.org/x/sync v0.19.0
+	golang.org/x/text v0.34.0
+)
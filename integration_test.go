@@ -14,7 +14,9 @@ import (
 
 	"github.com/tsosunchia/iNetSpeed-CLI/internal/config"
 	"github.com/tsosunchia/iNetSpeed-CLI/internal/latency"
+	"github.com/tsosunchia/iNetSpeed-CLI/internal/ratelimit"
 	"github.com/tsosunchia/iNetSpeed-CLI/internal/render"
+	"github.com/tsosunchia/iNetSpeed-CLI/internal/simulate"
 	"github.com/tsosunchia/iNetSpeed-CLI/internal/transfer"
 )
 
@@ -117,13 +119,74 @@ func TestIntegrationMultiThreadDownload(t *testing.T) {
 	}
 }
 
+func TestIntegrationMaxTotalAcrossRounds(t *testing.T) {
+	srv := mockCDN()
+	defer srv.Close()
+
+	cfg := &config.Config{
+		MaxBytes: 4 * 1024 * 1024,
+		Timeout:  5,
+		Max:      "4M",
+	}
+	gate := &ratelimit.Gate{Budget: ratelimit.NewBudget(3 * 1024 * 1024)}
+
+	var buf bytes.Buffer
+	bus := render.NewBus(render.NewPlainRenderer(&buf))
+	defer bus.Close()
+
+	dl := transfer.RunLimited(context.Background(), srv.Client(), cfg,
+		transfer.Download, 4, srv.URL+"/large", bus, gate, nil)
+	ul := transfer.RunLimited(context.Background(), srv.Client(), cfg,
+		transfer.Upload, 2, srv.URL+"/slurp", bus, gate, nil)
+
+	if total := dl.TotalBytes + ul.TotalBytes; total != 3*1024*1024 {
+		t.Errorf("total = %d, want exactly the 3 MiB cap", total)
+	}
+	if dl.HadFault() || ul.HadFault() {
+		t.Error("hitting the data cap must not count as a fault")
+	}
+	if !gate.Budget.Exhausted() {
+		t.Error("budget should be exhausted")
+	}
+}
+
+func TestIntegrationRateLimitedDownload(t *testing.T) {
+	srv := mockCDN()
+	defer srv.Close()
+
+	cfg := &config.Config{
+		MaxBytes: 4 * 1024 * 1024,
+		Timeout:  1,
+		Max:      "4M",
+	}
+	// 4 Mbps = 500 KB/s: the 2 MiB body cannot finish within the 1s timeout.
+	gate := &ratelimit.Gate{Rate: ratelimit.NewBucket(4_000_000)}
+
+	var buf bytes.Buffer
+	bus := render.NewBus(render.NewPlainRenderer(&buf))
+	defer bus.Close()
+
+	res := transfer.RunLimited(context.Background(), srv.Client(), cfg,
+		transfer.Download, 2, srv.URL+"/large", bus, gate, nil)
+
+	if res.Mbps > 6 {
+		t.Errorf("Mbps = %.1f, want about 4", res.Mbps)
+	}
+	if res.TotalBytes == 0 {
+		t.Error("downloaded 0 bytes")
+	}
+	if res.HadFault() {
+		t.Error("timing out while rate-limited must not count as a fault")
+	}
+}
+
 func TestIntegrationIdleLatency(t *testing.T) {
 	srv := mockCDN()
 	defer srv.Close()
 
 	stats := latency.MeasureIdle(context.Background(), srv.Client(), srv.URL+"/small", 5)
-	if stats.N != 5 {
-		t.Errorf("N = %d, want 5", stats.N)
+	if stats.Raw == nil || stats.Raw.N != 5 || stats.N+stats.Warmup+stats.Outliers != 5 {
+		t.Errorf("stats = %+v, want 5 samples", stats)
 	}
 	if stats.Min <= 0 {
 		t.Error("Min <= 0")
@@ -448,3 +511,65 @@ func TestDownloadBadStatusCode(t *testing.T) {
 		t.Errorf("expected 0 bytes from 403 server, got %d", res.TotalBytes)
 	}
 }
+
+// The tests below drive the transfer engine against the built-in emulator
+// with injected faults to pin down how each one is counted.
+
+func runAgainst(t *testing.T, opts simulate.Options, dir transfer.Direction, threads int) transfer.Result {
+	t.Helper()
+	srv := simulate.Start(opts)
+	defer srv.Close()
+
+	cfg := &config.Config{MaxBytes: 1 << 20, Max: "1MiB", Timeout: 5}
+	url := srv.DownloadURL()
+	if dir == transfer.Upload {
+		url = srv.UploadURL()
+	}
+	bus := render.NewBus(render.NewPlainRenderer(io.Discard))
+	defer bus.Close()
+	return transfer.Run(context.Background(), srv.Client(), cfg, dir, threads, url, bus)
+}
+
+func TestFaultDroppedDownloadKeepsPartialBytes(t *testing.T) {
+	res := runAgainst(t, simulate.Options{DropAfter: 100_000, LargeSize: 1 << 20}, transfer.Download, 3)
+	if res.FaultCount != 3 {
+		t.Errorf("FaultCount = %d, want 3", res.FaultCount)
+	}
+	if res.TotalBytes != 300_000 {
+		t.Errorf("TotalBytes = %d, want the 300000 bytes received before the drops", res.TotalBytes)
+	}
+}
+
+func TestFaultDroppedUpload(t *testing.T) {
+	res := runAgainst(t, simulate.Options{DropAfter: 100_000}, transfer.Upload, 2)
+	if res.FaultCount != 2 {
+		t.Errorf("FaultCount = %d, want 2", res.FaultCount)
+	}
+}
+
+func TestFaultStatusRollsBackUpload(t *testing.T) {
+	res := runAgainst(t, simulate.Options{ErrorRate: 1, Status: 500}, transfer.Upload, 2)
+	if res.FaultCount != 2 {
+		t.Errorf("FaultCount = %d, want 2", res.FaultCount)
+	}
+	if res.TotalBytes != 0 {
+		t.Errorf("TotalBytes = %d, want 0 after rollback", res.TotalBytes)
+	}
+}
+
+func TestFaultStatusDownload(t *testing.T) {
+	res := runAgainst(t, simulate.Options{ErrorRate: 1}, transfer.Download, 2)
+	if res.FaultCount != 2 || res.TotalBytes != 0 {
+		t.Errorf("FaultCount = %d TotalBytes = %d, want 2 and 0", res.FaultCount, res.TotalBytes)
+	}
+}
+
+func TestFaultStallIsNotAFault(t *testing.T) {
+	res := runAgainst(t, simulate.Options{Stall: 300 * time.Millisecond, StallAt: 1000, LargeSize: 1 << 20}, transfer.Download, 1)
+	if res.HadFault() {
+		t.Error("a stall that recovers must not count as a fault")
+	}
+	if res.TotalBytes != 1<<20 || res.Duration < 300*time.Millisecond {
+		t.Errorf("TotalBytes = %d Duration = %v", res.TotalBytes, res.Duration)
+	}
+}
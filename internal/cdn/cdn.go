@@ -0,0 +1,169 @@
+// Package cdn tells which CDN node served a response from the headers the
+// edge adds to it: Apple's CDNUUID and Via, Akamai's X-Cache and Server,
+// and the PoP markers of Cloudflare, Fastly and CloudFront.
+package cdn
+
+import (
+	"net"
+	"net/http"
+	"slices"
+	"strings"
+)
+
+// Provider names reported in Node.Provider.
+const (
+	Apple      = "Apple"
+	Akamai     = "Akamai"
+	Cloudflare = "Cloudflare"
+	Fastly     = "Fastly"
+	CloudFront = "CloudFront"
+)
+
+// Node identifies the edge behind one response. Any field may be empty;
+// Server and Via are kept verbatim for what the parser does not know.
+type Node struct {
+	Provider string
+	Node     string // edge host name
+	PoP      string // location code, such as "hkhkg3" or "HKG"
+	Cache    string // cache status, such as "hit-fresh" or "TCP_MISS"
+	ID       string // request ID the CDN can trace, such as CDNUUID
+	Server   string
+	Via      string
+}
+
+// Identify reads the CDN markers in h.
+func Identify(h http.Header) Node {
+	n := Node{Server: h.Get("Server"), Via: h.Get("Via")}
+	xcache := h.Get("X-Cache")
+	n.Cache = cacheStatus(xcache)
+
+	switch {
+	case h.Get("CDNUUID") != "" || strings.Contains(n.Via, ".apple.com"):
+		n.Provider = Apple
+		n.ID = h.Get("CDNUUID")
+		if host := lastViaHost(n.Via); strings.HasSuffix(host, ".apple.com") {
+			n.Node = host
+			n.PoP, _, _ = strings.Cut(host, "-")
+		}
+	case h.Get("CF-Ray") != "":
+		n.Provider = Cloudflare
+		n.ID = h.Get("CF-Ray")
+		if i := strings.LastIndexByte(n.ID, '-'); i >= 0 {
+			n.PoP = n.ID[i+1:]
+		}
+		n.Cache = h.Get("CF-Cache-Status")
+	case h.Get("X-Amz-Cf-Pop") != "":
+		n.Provider = CloudFront
+		n.PoP = h.Get("X-Amz-Cf-Pop")
+		n.ID = h.Get("X-Amz-Cf-Id")
+	case strings.HasPrefix(h.Get("X-Served-By"), "cache-"):
+		n.Provider = Fastly
+		// The last hop is the edge nearest the client.
+		servedBy := h.Get("X-Served-By")
+		n.Node = strings.TrimSpace(servedBy[strings.LastIndexByte(servedBy, ',')+1:])
+		if i := strings.LastIndexByte(n.Node, '-'); i >= 0 {
+			n.PoP = n.Node[i+1:]
+		}
+	case strings.Contains(xcache, "akamai") || strings.HasPrefix(n.Server, "AkamaiGHost"):
+		n.Provider = Akamai
+		// X-Cache: TCP_HIT from a23-1-2-3.deploy.akamaitechnologies.com (AkamaiGHost/…)
+		if _, rest, ok := strings.Cut(xcache, " from "); ok {
+			n.Node, _, _ = strings.Cut(rest, " ")
+		}
+		n.ID = h.Get("X-Akamai-Request-ID")
+	}
+	return n
+}
+
+// Known reports whether Identify recognized anything.
+func (n Node) Known() bool {
+	return n.Provider != ""
+}
+
+// Hit reports whether cache, a Node's Cache status, says the response came
+// from the cache's store.
+func Hit(cache string) bool {
+	return strings.Contains(strings.ToLower(cache), "hit")
+}
+
+// cdnHosts are the markers of the CDNs' own hosts in Via and X-Cache:
+// Fastly's and Google's edges name themselves "varnish" and "google".
+var cdnHosts = []string{".apple.com", "akamai", "cloudfront", "cloudflare", "varnish", "google"}
+
+// Proxies lists the hosts in h's Via, X-Cache and X-Cache-Lookup headers
+// that belong to no known CDN: the caches and proxies between the client
+// and the edge, such as an ISP's transparent Squid, which add themselves
+// with "1.1 cache.isp.example (squid/4.10)" and "HIT from cache.isp.example".
+func Proxies(h http.Header) []string {
+	var hosts []string
+	add := func(host string) {
+		host = strings.ToLower(strings.TrimSpace(host))
+		if host == "" || slices.Contains(hosts, host) || slices.ContainsFunc(cdnHosts, func(c string) bool { return strings.Contains(host, c) }) {
+			return
+		}
+		hosts = append(hosts, host)
+	}
+	for _, via := range h.Values("Via") {
+		for _, hop := range strings.Split(via, ",") {
+			if fields := strings.Fields(hop); len(fields) >= 2 {
+				add(fields[1])
+			}
+		}
+	}
+	for _, name := range []string{"X-Cache", "X-Cache-Lookup"} {
+		for _, v := range h.Values(name) {
+			for _, entry := range strings.Split(v, ",") {
+				if _, rest, ok := strings.Cut(entry, " from "); ok {
+					host, _, _ := strings.Cut(strings.TrimSpace(rest), " ")
+					if h, _, err := net.SplitHostPort(host); err == nil {
+						host = h // Squid adds its port
+					}
+					add(host)
+				}
+			}
+		}
+	}
+	return hosts
+}
+
+// String is a one-line summary, such as "Apple hkhkg3-edge-bx-008.ts.apple.com
+// (hkhkg3, hit-fresh)".
+func (n Node) String() string {
+	s := n.Provider
+	if s == "" {
+		s = n.Server
+	}
+	if n.Node != "" {
+		s += " " + n.Node
+	}
+	var extra []string
+	for _, v := range []string{n.PoP, n.Cache} {
+		if v != "" {
+			extra = append(extra, v)
+		}
+	}
+	if len(extra) > 0 {
+		s += " (" + strings.Join(extra, ", ") + ")"
+	}
+	return strings.TrimSpace(s)
+}
+
+// lastViaHost is the host of the last Via hop, the proxy nearest the client:
+// "http/1.1 a.apple.com (acdn/1), https/1.1 b.apple.com (acdn/1)" gives
+// "b.apple.com".
+func lastViaHost(via string) string {
+	hops := strings.Split(via, ",")
+	fields := strings.Fields(hops[len(hops)-1])
+	if len(fields) < 2 {
+		return ""
+	}
+	return fields[1]
+}
+
+// cacheStatus is the first word of the last X-Cache entry, the one the edge
+// nearest the client added.
+func cacheStatus(xcache string) string {
+	entries := strings.Split(xcache, ",")
+	status, _, _ := strings.Cut(strings.TrimSpace(entries[len(entries)-1]), " ")
+	return status
+}
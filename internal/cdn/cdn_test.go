@@ -0,0 +1,86 @@
+package cdn
+
+import (
+	"net/http"
+	"slices"
+	"testing"
+)
+
+func header(kv ...string) http.Header {
+	h := http.Header{}
+	for i := 0; i < len(kv); i += 2 {
+		h.Set(kv[i], kv[i+1])
+	}
+	return h
+}
+
+func TestIdentify(t *testing.T) {
+	for _, tc := range []struct {
+		name string
+		h    http.Header
+		want Node
+	}{
+		{"apple", header(
+			"CDNUUID", "5c1b3b5e-1234",
+			"Server", "ATS/9.2.3",
+			"Via", "https/1.1 jptyo12-edge-lx-001.ts.apple.com (acdn/268.14795), http/1.1 jptyo12-edge-bx-012.ts.apple.com (acdn/268.14795)",
+			"X-Cache", "miss, hit-fresh",
+		), Node{Provider: Apple, Node: "jptyo12-edge-bx-012.ts.apple.com", PoP: "jptyo12", Cache: "hit-fresh", ID: "5c1b3b5e-1234",
+			Server: "ATS/9.2.3", Via: "https/1.1 jptyo12-edge-lx-001.ts.apple.com (acdn/268.14795), http/1.1 jptyo12-edge-bx-012.ts.apple.com (acdn/268.14795)"}},
+		{"akamai", header(
+			"Server", "AkamaiGHost",
+			"X-Cache", "TCP_HIT from a23-45-67-89.deploy.akamaitechnologies.com (AkamaiGHost/11.2.0-123) (-)",
+		), Node{Provider: Akamai, Node: "a23-45-67-89.deploy.akamaitechnologies.com", Cache: "TCP_HIT", Server: "AkamaiGHost"}},
+		{"cloudflare", header("Server", "cloudflare", "CF-Ray", "8a1b2c3d4e5f6789-HKG", "CF-Cache-Status", "HIT"),
+			Node{Provider: Cloudflare, PoP: "HKG", Cache: "HIT", ID: "8a1b2c3d4e5f6789-HKG", Server: "cloudflare"}},
+		{"fastly", header("X-Served-By", "cache-iad-kiad7000025-IAD, cache-hkg17920-HKG", "X-Cache", "MISS, HIT"),
+			Node{Provider: Fastly, Node: "cache-hkg17920-HKG", PoP: "HKG", Cache: "HIT"}},
+		{"cloudfront", header("X-Amz-Cf-Pop", "HKG62-C1", "X-Amz-Cf-Id", "abc==", "X-Cache", "Hit from cloudfront"),
+			Node{Provider: CloudFront, PoP: "HKG62-C1", Cache: "Hit", ID: "abc=="}},
+		{"unknown", header("Server", "nginx"), Node{Server: "nginx"}},
+	} {
+		if got := Identify(tc.h); got != tc.want {
+			t.Errorf("%s: %+v\nwant %+v", tc.name, got, tc.want)
+		}
+	}
+}
+
+func TestNodeString(t *testing.T) {
+	for _, tc := range []struct {
+		n    Node
+		want string
+	}{
+		{Node{Provider: Apple, Node: "hkhkg3-edge-bx-008.ts.apple.com", PoP: "hkhkg3", Cache: "hit-fresh"}, "Apple hkhkg3-edge-bx-008.ts.apple.com (hkhkg3, hit-fresh)"},
+		{Node{Provider: Cloudflare, PoP: "HKG"}, "Cloudflare (HKG)"},
+		{Node{Server: "nginx"}, "nginx"},
+	} {
+		if got := tc.n.String(); got != tc.want {
+			t.Errorf("%+v: %q, want %q", tc.n, got, tc.want)
+		}
+	}
+}
+
+func TestProxies(t *testing.T) {
+	squid := http.Header{}
+	squid.Add("Via", "1.1 cache-03.isp.example (squid/4.10)")
+	squid.Add("Via", "http/1.1 jptyo12-edge-bx-012.ts.apple.com (acdn/268.14795)")
+	squid.Set("X-Cache", "HIT from cache-03.isp.example")
+	squid.Set("X-Cache-Lookup", "HIT from cache-03.isp.example:3128")
+	for _, tc := range []struct {
+		name string
+		h    http.Header
+		want []string
+	}{
+		{"squid", squid, []string{"cache-03.isp.example"}},
+		{"apple", header("Via", "https/1.1 jptyo12-edge-lx-001.ts.apple.com (acdn/268.14795), http/1.1 jptyo12-edge-bx-012.ts.apple.com (acdn/268.14795)"), nil},
+		{"akamai", header("X-Cache", "TCP_HIT from a23-45-67-89.deploy.akamaitechnologies.com (AkamaiGHost/11.2.0-123) (-)"), nil},
+		{"fastly", header("Via", "1.1 varnish, 1.1 varnish", "X-Cache", "MISS, HIT"), nil},
+		{"cloudfront", header("Via", "1.1 abc.cloudfront.net (CloudFront)", "X-Cache", "Hit from cloudfront"), nil},
+		{"proxy", header("Via", "1.1 proxy.corp.example"), []string{"proxy.corp.example"}},
+		{"none", header("Server", "nginx"), nil},
+	} {
+		if got := Proxies(tc.h); !slices.Equal(got, tc.want) {
+			t.Errorf("%s: %q, want %q", tc.name, got, tc.want)
+		}
+	}
+}
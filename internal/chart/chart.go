@@ -0,0 +1,169 @@
+// Package chart draws self-contained SVG charts of test results: no scripts,
+// fonts or other external assets, so they open anywhere and can be inlined
+// into other documents.
+package chart
+
+import (
+	"bufio"
+	"encoding/xml"
+	"fmt"
+	"io"
+	"math"
+	"strings"
+)
+
+const (
+	width  = 640
+	height = 400
+	left   = 64 // room for Y tick labels
+	right  = 24
+	top    = 40
+	bottom = 52 // room for X tick labels and title
+)
+
+// Palette colors series in order; it repeats when there are more series.
+var Palette = []string{"#2563eb", "#ea580c", "#16a34a", "#9333ea", "#dc2626", "#0891b2"}
+
+// Point is one observation.
+type Point struct{ X, Y float64 }
+
+// Series is a named set of points drawn in one color.
+type Series struct {
+	Name   string
+	Points []Point
+}
+
+// Plot describes a chart's labels.
+type Plot struct {
+	Title  string
+	XLabel string
+	YLabel string
+}
+
+// Scatter writes p as an SVG scatter plot of series. Both axes start at
+// zero, since throughput and latency are only meaningful against it.
+func Scatter(w io.Writer, p Plot, series []Series) error {
+	b := bufio.NewWriter(w)
+	px, py := frame(b, p, series)
+	for i, s := range series {
+		color := Palette[i%len(Palette)]
+		fmt.Fprintf(b, `<g fill="%s" fill-opacity="0.55">`+"\n", color)
+		for _, pt := range s.Points {
+			fmt.Fprintf(b, `<circle cx="%.1f" cy="%.1f" r="3"/>`+"\n", px(pt.X), py(pt.Y))
+		}
+		b.WriteString("</g>\n")
+		legend(b, i, s.Name, color, false)
+	}
+	b.WriteString("</svg>\n")
+	return b.Flush()
+}
+
+// Lines writes p as an SVG line chart of series, each joining its points in
+// order, such as throughput or latency over time. Both axes start at zero.
+func Lines(w io.Writer, p Plot, series []Series) error {
+	b := bufio.NewWriter(w)
+	px, py := frame(b, p, series)
+	for i, s := range series {
+		if len(s.Points) == 0 {
+			continue
+		}
+		color := Palette[i%len(Palette)]
+		var d strings.Builder
+		for j, pt := range s.Points {
+			cmd := "L"
+			if j == 0 {
+				cmd = "M"
+			}
+			fmt.Fprintf(&d, "%s%.1f %.1f ", cmd, px(pt.X), py(pt.Y))
+		}
+		fmt.Fprintf(b, `<path d="%s" fill="none" stroke="%s" stroke-width="1.5" stroke-linejoin="round"/>`+"\n", strings.TrimSpace(d.String()), color)
+		legend(b, i, s.Name, color, true)
+	}
+	b.WriteString("</svg>\n")
+	return b.Flush()
+}
+
+// frame opens the SVG and draws the title, grid, axes and their labels for
+// the extent of series, returning the mapping from data to SVG coordinates.
+func frame(b *bufio.Writer, p Plot, series []Series) (px, py func(float64) float64) {
+	var maxX, maxY float64
+	for _, s := range series {
+		for _, pt := range s.Points {
+			maxX = math.Max(maxX, pt.X)
+			maxY = math.Max(maxY, pt.Y)
+		}
+	}
+	xs, ys := ticks(maxX), ticks(maxY)
+	spanX, spanY := xs[len(xs)-1], ys[len(ys)-1]
+	plotW := float64(width - left - right)
+	plotH := float64(height - top - bottom)
+	px = func(x float64) float64 { return left + x/spanX*plotW }
+	py = func(y float64) float64 { return top + plotH - y/spanY*plotH }
+
+	fmt.Fprintf(b, `<svg xmlns="http://www.w3.org/2000/svg" width="%d" height="%d" viewBox="0 0 %d %d" font-family="sans-serif" font-size="12">`+"\n", width, height, width, height)
+	fmt.Fprintf(b, `<rect width="%d" height="%d" fill="#fff"/>`+"\n", width, height)
+	fmt.Fprintf(b, `<text x="%d" y="24" font-size="15" font-weight="bold">%s</text>`+"\n", left, esc(p.Title))
+
+	for _, x := range xs {
+		fmt.Fprintf(b, `<line x1="%.1f" y1="%d" x2="%.1f" y2="%.1f" stroke="#e5e7eb"/>`+"\n", px(x), top, px(x), py(0))
+		fmt.Fprintf(b, `<text x="%.1f" y="%.1f" text-anchor="middle" fill="#6b7280">%s</text>`+"\n", px(x), py(0)+16, num(x))
+	}
+	for _, y := range ys {
+		fmt.Fprintf(b, `<line x1="%d" y1="%.1f" x2="%.1f" y2="%.1f" stroke="#e5e7eb"/>`+"\n", left, py(y), px(spanX), py(y))
+		fmt.Fprintf(b, `<text x="%d" y="%.1f" text-anchor="end" fill="#6b7280">%s</text>`+"\n", left-6, py(y)+4, num(y))
+	}
+	fmt.Fprintf(b, `<path d="M%d %d V%.1f H%.1f" fill="none" stroke="#374151"/>`+"\n", left, top, py(0), px(spanX))
+	fmt.Fprintf(b, `<text x="%.1f" y="%d" text-anchor="middle">%s</text>`+"\n", left+plotW/2, height-12, esc(p.XLabel))
+	fmt.Fprintf(b, `<text transform="translate(16 %.1f) rotate(-90)" text-anchor="middle">%s</text>`+"\n", top+plotH/2, esc(p.YLabel))
+	return px, py
+}
+
+// legend writes the i-th legend row, top right: a dot, or a dash for a
+// line series, in color and the series name.
+func legend(b *bufio.Writer, i int, name, color string, line bool) {
+	lx, ly := float64(width-right-160), float64(top+8+16*i)
+	if line {
+		fmt.Fprintf(b, `<rect x="%.1f" y="%.1f" width="10" height="3" fill="%s"/>`+"\n", lx-5, ly-1.5, color)
+	} else {
+		fmt.Fprintf(b, `<circle cx="%.1f" cy="%.1f" r="4" fill="%s"/>`+"\n", lx, ly, color)
+	}
+	fmt.Fprintf(b, `<text x="%.1f" y="%.1f">%s</text>`+"\n", lx+10, ly+4, esc(name))
+}
+
+// ticks returns evenly spaced values from 0 covering max, at a step of 1, 2
+// or 5 times a power of ten, about five of them.
+func ticks(max float64) []float64 {
+	if max <= 0 {
+		max = 1
+	}
+	raw := max / 5
+	mag := math.Pow(10, math.Floor(math.Log10(raw)))
+	step := mag * 10
+	for _, m := range []float64{1, 2, 5} {
+		if raw <= m*mag {
+			step = m * mag
+			break
+		}
+	}
+	var out []float64
+	for i := 0; ; i++ {
+		v := float64(i) * step
+		out = append(out, v)
+		if v >= max {
+			return out
+		}
+	}
+}
+
+// num formats a tick value without trailing zeros.
+func num(v float64) string {
+	s := fmt.Sprintf("%.2f", v)
+	s = strings.TrimRight(s, "0")
+	return strings.TrimSuffix(s, ".")
+}
+
+func esc(s string) string {
+	var b strings.Builder
+	xml.EscapeText(&b, []byte(s))
+	return b.String()
+}
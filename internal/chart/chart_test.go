@@ -0,0 +1,92 @@
+package chart
+
+import (
+	"encoding/xml"
+	"errors"
+	"io"
+	"strings"
+	"testing"
+)
+
+func TestScatter(t *testing.T) {
+	var b strings.Builder
+	err := Scatter(&b, Plot{Title: "RTT <vs> throughput", XLabel: "Mbps", YLabel: "ms"}, []Series{
+		{Name: "Download", Points: []Point{{100, 20}, {480, 85}, {500, 90}}},
+		{Name: "Upload & more", Points: []Point{{40, 25}}},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	svg := b.String()
+	// Well-formed XML, so browsers render it and it can be inlined.
+	d := xml.NewDecoder(strings.NewReader(svg))
+	for {
+		if _, err := d.Token(); err != nil {
+			if !errors.Is(err, io.EOF) {
+				t.Fatalf("invalid SVG: %v\n%s", err, svg)
+			}
+			break
+		}
+	}
+	if got := strings.Count(svg, `r="3"`); got != 4 {
+		t.Errorf("%d points drawn, want 4", got)
+	}
+	for _, want := range []string{"RTT &lt;vs&gt; throughput", "Upload &amp; more", ">500<", ">100<"} {
+		if !strings.Contains(svg, want) {
+			t.Errorf("SVG lacks %q", want)
+		}
+	}
+}
+
+func TestScatterEmpty(t *testing.T) {
+	var b strings.Builder
+	if err := Scatter(&b, Plot{}, nil); err != nil || !strings.HasSuffix(b.String(), "</svg>\n") {
+		t.Errorf("empty chart: %v\n%s", err, b.String())
+	}
+}
+
+func TestLines(t *testing.T) {
+	var b strings.Builder
+	err := Lines(&b, Plot{Title: "Throughput", XLabel: "s", YLabel: "Mbps"}, []Series{
+		{Name: "Download", Points: []Point{{0.1, 100}, {0.2, 300}, {0.3, 250}}},
+		{Name: "Empty"},
+		{Name: "Upload", Points: []Point{{0.1, 40}}},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	svg := b.String()
+	if err := xml.Unmarshal([]byte(svg), new(struct{})); err != nil {
+		t.Fatalf("invalid SVG: %v\n%s", err, svg)
+	}
+	if got := strings.Count(svg, `stroke-width="1.5"`); got != 2 {
+		t.Errorf("%d lines drawn, want 2", got)
+	}
+	// Three points: one move and two line segments.
+	if !strings.Contains(svg, `<path d="M`) || strings.Count(svg, " L") < 2 {
+		t.Errorf("path lacks its segments:\n%s", svg)
+	}
+	if strings.Contains(svg, ">Empty<") {
+		t.Error("series without points got a legend row")
+	}
+}
+
+func TestTicks(t *testing.T) {
+	for _, tc := range []struct {
+		max  float64
+		want string
+	}{
+		{500, "0 100 200 300 400 500"},
+		{87, "0 20 40 60 80 100"},
+		{0.3, "0 0.1 0.2 0.3"},
+		{0, "0 0.2 0.4 0.6 0.8 1"},
+	} {
+		var got []string
+		for _, v := range ticks(tc.max) {
+			got = append(got, num(v))
+		}
+		if strings.Join(got, " ") != tc.want {
+			t.Errorf("ticks(%v) = %v, want %s", tc.max, got, tc.want)
+		}
+	}
+}
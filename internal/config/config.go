@@ -1,16 +1,30 @@
 package config
 
 import (
+	"crypto/tls"
 	"errors"
 	"flag"
 	"fmt"
 	"io"
+	"net"
+	"net/http"
 	"os"
 	"regexp"
+	"slices"
 	"strconv"
 	"strings"
+	"time"
 
+	"github.com/tsosunchia/iNetSpeed-CLI/internal/history"
 	"github.com/tsosunchia/iNetSpeed-CLI/internal/i18n"
+	"github.com/tsosunchia/iNetSpeed-CLI/internal/netx"
+	"github.com/tsosunchia/iNetSpeed-CLI/internal/overhead"
+	"github.com/tsosunchia/iNetSpeed-CLI/internal/pac"
+	"github.com/tsosunchia/iNetSpeed-CLI/internal/payload"
+	"github.com/tsosunchia/iNetSpeed-CLI/internal/probe"
+	"github.com/tsosunchia/iNetSpeed-CLI/internal/sign"
+	"github.com/tsosunchia/iNetSpeed-CLI/internal/simulate"
+	"github.com/tsosunchia/iNetSpeed-CLI/internal/units"
 )
 
 const (
@@ -21,69 +35,731 @@ const (
 	DefaultTimeout      = 10
 	DefaultThreads      = 4
 	DefaultLatencyCount = 20
+	DefaultPayload      = "zero"
 	UserAgent           = "networkQuality/194.80.3 CFNetwork/3860.400.51 Darwin/25.3.0"
+	DefaultWidgetMaxAge = 30 * time.Minute
+	DefaultServerListen = ":9797"
+	DefaultRunCooldown  = 10 * time.Second
+
+	// DefaultSaturationWindow is how long throughput must hold steady under
+	// --stop-on-saturation; MinSaturationWindow is the shortest window, two
+	// one-second averages to compare.
+	DefaultSaturationWindow = 3 * time.Second
+	MinSaturationWindow     = 2 * time.Second
+
+	// MinInterval is the shortest INTERVAL: rounds sample their throughput
+	// every 100 ms.
+	MinInterval = 100 * time.Millisecond
+
+	// MaxAuto (MAX=auto) picks the per-thread cap from a short download
+	// probe in the auto-max stage; DefaultMax applies until it has run.
+	MaxAuto = "auto"
 )
 
 var ErrHelp = errors.New("help requested")
 
+// Stage names used by the runner graph and by SKIP_STAGES / STAGE_TIMEOUTS.
+const (
+	StageDiscover       = "discover"
+	StageEndpoint       = "endpoint"
+	StageURLCheck       = "url-check"
+	StageInfo           = "info"
+	StageSysInfo        = "sysinfo"
+	StageIdleLatency    = "idle-latency"
+	StageICMPLatency    = "icmp-latency"
+	StageTargets        = "latency-targets"
+	StageMTU            = "mtu"
+	StageUDPLatency     = "udp-latency"
+	StageRequestRate    = "request-rate"
+	StageCacheCheck     = "cache-check"
+	StageColdStart      = "cold-start"
+	StageAutoMax        = "auto-max"
+	StageDownloadSingle = "download-single"
+	StageDownloadMulti  = "download-multi"
+	StageUploadSingle   = "upload-single"
+	StageUploadMulti    = "upload-multi"
+	StageUploadVerify   = "upload-verify"
+	StageBidirectional  = "bidirectional"
+	StageThreadRamp     = "thread-ramp"
+	StageIdleAfter      = "idle-latency-after"
+	StageSummary        = "summary"
+	StageRanking        = "ranking"
+	StageCompare        = "compare"
+	StageAssert         = "assert"
+	StageShare          = "share"
+)
+
+// Connection modes of the multi-thread rounds (CONNECTION_MODE).
+const (
+	ConnAuto     = "auto"      // HTTP/2 when offered, connections pooled by Go
+	ConnMulti    = "multi"     // one HTTP/1.1 connection per thread
+	ConnSingleH2 = "single-h2" // every thread as a stream of one HTTP/2 connection
+	ConnBoth     = "both"      // run multi and single-h2 back to back and compare
+)
+
+// Upload request methods (UPLOAD_METHOD). PUT carries the resumable-upload
+// draft headers Apple's endpoint expects; POST suits servers that reject PUT.
+const (
+	UploadPut  = "put"
+	UploadPost = "post"
+)
+
+// Phases (PHASES) a run can be limited to, each a group of stages.
+const (
+	PhaseDownload = "download"
+	PhaseUpload   = "upload"
+	PhaseLatency  = "latency"
+)
+
+// PhaseNames lists the phases in run order.
+var PhaseNames = []string{PhaseLatency, PhaseDownload, PhaseUpload}
+
+var phaseStages = map[string][]string{
+	PhaseDownload: {StageDownloadSingle, StageDownloadMulti},
+	PhaseUpload:   {StageUploadSingle, StageUploadMulti, StageUploadVerify},
+	PhaseLatency:  {StageIdleLatency, StageIdleAfter},
+}
+
+// Download modes (DOWNLOAD_MODE). Range mode has each thread fetch its own
+// byte ranges of the object in turn, so any static file can be tested.
+const (
+	DownloadStream = "stream"
+	DownloadRange  = "range"
+)
+
+// Latency probe connections (LATENCY_CONN). Probes over a reused connection
+// measure queuing delay alone; a new connection per probe adds the TCP and
+// TLS setup to each sample.
+const (
+	LatencyReused = "reused"
+	LatencyNew    = "new"
+	LatencyBoth   = "both" // probe both ways; reused is the headline figure
+)
+
+// Endpoint pre-screen modes (PRESCREEN).
+const (
+	PrescreenTCP = "tcp"
+	PrescreenTLS = "tls"
+	PrescreenOff = "off"
+)
+
+// StageNames lists every configurable stage in run order.
+var StageNames = []string{
+	StageDiscover, StageEndpoint, StageURLCheck, StageInfo, StageSysInfo, StageIdleLatency, StageICMPLatency, StageTargets, StageMTU, StageUDPLatency,
+	StageRequestRate, StageCacheCheck, StageColdStart, StageAutoMax, StageDownloadSingle, StageDownloadMulti, StageUploadSingle, StageUploadMulti,
+	StageUploadVerify, StageBidirectional, StageThreadRamp, StageIdleAfter, StageSummary, StageRanking, StageCompare, StageAssert, StageShare,
+}
+
 type Config struct {
-	DLURL        string
-	ULURL        string
-	LatencyURL   string
-	Max          string
-	MaxBytes     int64
-	Timeout      int
-	Threads      int
-	LatencyCount int
+	DLURL         string
+	ULURL         string
+	LatencyURL    string
+	Max           string
+	MaxBytes      int64
+	Timeout       int
+	Threads       int
+	LatencyCount  int
+	UploadPayload string
+	// Payload is UploadPayload parsed by Load; nil sends zeros.
+	Payload       payload.Source
+	Share         bool
+	ShareURL      string
+	ShareToken    string
+	ShareImage    string
+	Scatter       string // SVG plot of loaded latency against throughput
+	ReportHTML    string // standalone HTML report with charts
+	SkipStages    map[string]bool
+	Phases        []string // the phases to run, in run order; nil runs all
+	StageTimeouts map[string]time.Duration
+	ConfigFile    string
+	StageLimits   map[string]StageLimit
+	LimitRate     string
+	RateBits      int64 // bits per second; 0 means unlimited
+	MaxTotal      string
+	MaxTotalBytes int64 // 0 means unlimited
+	TCPInfo       bool
+	SysInfo       bool // describe the local interface, link, gateway and DNS
+	Simulate      bool
+	SimulateOpts  string
+	Sim           simulate.Options
+	Quiet         bool
+	Verbose       bool
+	Interval      time.Duration // iperf3-style throughput lines this often; 0 for none
+	NoColor       bool
+	Units         string // throughput display unit, one of units.Names
+	TUI           bool   // full-screen dashboard instead of progress lines
+	Demo          bool   // replay the bundled recorded run instead of testing
+	// Compare is set by the `compare` command: the run is checked against
+	// Baseline, or the latest History entry when Baseline is empty.
+	Compare           bool
+	Baseline          string
+	History           string // JSON-lines file each run is appended to
+	CompareThresholds string
+	Thresholds        history.Thresholds
+	ConnectionMode    string
+	ICMP              bool
+	MTU               bool   // probe path MTU and MSS clamping
+	UDPEcho           string // host:port of a UDP echo reflector
+	RequestRate       bool
+	CacheCheck        bool         // fetch the download object with and without cache busting
+	ColdStart         bool         // compare the first seconds of a new connection with a warm one
+	VerifyUpload      bool         // send one upload to completion and check the server's answer
+	Bidi              bool         // download and upload at once, half the threads each
+	ThreadSchedule    []ThreadStep // when set, rounds whose workers join step by step
+	UploadMethod      string       // empty means PUT
+	DownloadMode      string       // empty means stream
+	// UploadFixedLength (UPLOAD_CHUNKED=false) announces the per-thread cap
+	// as Content-Length instead of streaming bodies of unknown length,
+	// which HTTP/1.1 sends chunked.
+	UploadFixedLength bool
+	// LatencyInterval spaces latency probes at least that far apart, zero
+	// for back to back. LatencyProbeBytes, when positive, limits each probe
+	// to that many bytes of LATENCY_URL.
+	LatencyInterval   time.Duration
+	LatencyProbeSize  string
+	LatencyProbeBytes int64
+	LatencyConn       string
+	// LatencyTargets are IPs, host names or TargetGateway, pinged idle and
+	// under load to tell where latency builds up.
+	LatencyTargets []string
+	// DSCP marks every test socket; TOS is the byte carrying it, zero when
+	// DSCP is empty.
+	DSCP string
+	TOS  int
+	// Runs repeats the whole benchmark, pausing Cooldown between runs, and
+	// summarizes each metric's spread when it is above 1. Zero runs a
+	// series until interrupted, as a service; see Series.
+	Runs     int
+	Cooldown time.Duration
+	// API is the address of the HTTP API steering a Series, empty
+	// for none. APIToken, when set, is the bearer token its requests must
+	// carry; without one the API only listens on loopback.
+	API      string
+	APIToken string
+	// SaturationWindow, under --stop-on-saturation, ends a transfer round
+	// once its throughput has held within a few percent for that long;
+	// zero runs rounds to their cap or timeout.
+	SaturationWindow time.Duration
+	// Link is the framing under IP, from OVERHEAD_MODEL, that the line rate
+	// estimated from each round's goodput counts; see package overhead.
+	OverheadModel string
+	Link          overhead.Link
+	// DataBudget caps the run's data like MaxTotal and also scales each
+	// round's per-thread cap down so the rounds fit in it.
+	DataBudget      string
+	DataBudgetBytes int64 // 0 means none
+	// CompareIPVersions runs the benchmark once against an IPv4 and once
+	// against an IPv6 address of the CDN host and compares the two.
+	CompareIPVersions bool
+	// Probe identity, persisted in ProbeState. Register is set by the
+	// `register` command, which enrolls the probe with Collector. Tags are
+	// the --tag pairs the run is recorded with.
+	ProbeID       string
+	ProbeName     string
+	ProbeState    string
+	Register      bool
+	Collector     string
+	RegisterToken string
+	Tags          map[string]string
+	EventLog      string // NDJSON file receiving every bus event
+	LogFile       string // plain-text copy of the output, whatever the terminal shows
+	Prescreen     string
+	PerASN        bool   // benchmark the first endpoint candidate of each AS
+	RankingDB     string // reference file or URL; empty means built-in
+	// NoDoH resolves the CDN host with the system resolver instead of DoH,
+	// and NoGeo skips the ip-api lookups, for networks blocking them.
+	NoDoH bool
+	NoGeo bool
+	// PreferCountry ranks the endpoint candidates in this ISO country code
+	// first, instead of those in the client's country.
+	PreferCountry string
+	// DNS is the host:port of a DNS server the test's lookups go to in
+	// place of DoH and the system resolver.
+	DNS string
+	// Region has the CDN host resolved as from another region; its Name is
+	// empty when the host resolves as from here.
+	Region Region
+	// NoPrompt takes the first endpoint where a terminal would be asked.
+	NoPrompt bool
+	// Preset is the PRESET / --preset bundle applied, if any.
+	Preset string
+	// Args are the arguments Load was given, to load the configuration
+	// again on SIGHUP.
+	Args []string
+	// Discover takes the test URLs from the networkQuality configuration
+	// served at DL_URL's origin; see package discover.
+	Discover bool
+	// DLFallbacks, ULFallbacks and LatencyFallbacks are the later URLs of
+	// comma-separated DL_URL, UL_URL and LATENCY_URL lists, tried in order
+	// when the URL in use fails; see runner's url-check stage.
+	DLFallbacks      []string
+	ULFallbacks      []string
+	LatencyFallbacks []string
+	// URLHook is an http(s) URL or command supplying fresh test URLs; see
+	// package urlhook.
+	URLHook string
+	// ProxyPAC is a PAC file URL or path choosing the test requests' proxy
+	// per URL; Run loads it into PAC. Without it they connect directly.
+	ProxyPAC string
+	PAC      *pac.Script
+	// Each finished run's metrics are pushed to InfluxURL, an InfluxDB
+	// write endpoint, and GraphiteAddr, a Graphite plaintext listener, and
+	// written to Textfile for node_exporter; see package sink.
+	InfluxURL    string
+	InfluxToken  string
+	GraphiteAddr string
+	Textfile     string
+	// UserAgent and Headers apply to every test request; see RequestHeader.
+	UserAgent string
+	Headers   http.Header
+	// Sign adds REQUEST_SIGN's token or HMAC to every test request as it is
+	// sent; nil when unset.
+	Sign *sign.Template
+	// TLS is built from CACert, ClientCert/ClientKey and Insecure for the
+	// test clients; nil keeps the system defaults.
+	CACert     string
+	ClientCert string
+	ClientKey  string
+	Insecure   bool
+	TLS        *tls.Config
+	// Assertions checked after the run; zero disables each one.
+	AssertDownloadMin float64 // Mbps
+	AssertUploadMin   float64 // Mbps
+	AssertLatencyMax  float64 // ms
+	// Widget prints one status line from History, running a new test only
+	// when its latest entry is older than WidgetMaxAge.
+	Widget       bool
+	WidgetMaxAge time.Duration
+	// Remote is set by the `remote` command, which runs the test on each of
+	// RemoteHosts over SSH with RemoteArgs.
+	Remote       bool
+	RemoteHosts  []string
+	RemoteArgs   []string
+	SSHCommand   string
+	RemoteBinary string
+	// Check is set by the `check` command or --dry-run, which validate the
+	// configuration and reach each test URL once instead of testing.
+	Check bool
+	// Note is set by the `note` command, which appends NoteText to History.
+	Note     bool
+	NoteText string
+	// Server is set by the `server` command, which runs a UDP echo reflector
+	// on Listen for --udp-echo.
+	Server bool
+	Listen string
+	// SelfUpdate is set by the `self-update` command, which replaces the
+	// binary with the latest release.
+	SelfUpdate bool
 }
 
 func Usage() string {
-	if i18n.IsZH() {
-		return fmt.Sprintf(`用法:
+	return fmt.Sprintf(i18n.Text(`Usage:
+  speedtest [options]
+  speedtest compare [options]
+  speedtest register --collector URL [options]
+  speedtest remote [options] [user@]host... [-- remote options]
+  speedtest server [--listen ADDR]
+  speedtest note [--history PATH] TEXT
+  speedtest version
+  speedtest self-update
+  speedtest help
+
+Options:
+  -h, --help                    Show this help message
+  -v, --version                 Show version; speedtest version prints it as JSON with the commit, Go version and platform
+  -q, --quiet                   Print only "down=<Mbps> up=<Mbps> latency=<ms>" on stdout (default from QUIET)
+  --verbose                     Also log every transfer request (default from VERBOSE)
+  --interval DURATION           Print an iperf3-style line per interval of each round, e.g. 1s, and a total (default from INTERVAL)
+  --no-color                    Disable ANSI colors (default from NO_COLOR)
+  --tui                         Full-screen dashboard with speed gauge and sparkline on a terminal (default from TUI)
+  --units UNIT                  Unit of the throughput shown: Mbps, MB/s, Mibit or auto (Kbps/Mbps/Gbps by size); JSON
+                                and the sinks stay in Mbps (default from UNITS or Mbps)
+  --lang LANG                   Output language: en, zh, zh-Hant or ja (default from SPEEDTEST_LANG/LC_ALL/LC_MESSAGES/LANGUAGE/LANG)
+  --preset NAME                 Settings bundle: quick (8s rounds, no endpoint prompt), standard (the defaults) or thorough
+                                (30s rounds, 8 threads, MAX=auto, 3 runs); individual flags and variables still apply (default from PRESET)
+  --dl-url URL                  Download test URL (default from DL_URL or %q)
+  --ul-url URL                  Upload test URL (default from UL_URL or %q)
+  --latency-url URL             Latency test URL (default from LATENCY_URL or %q)
+                                Each of the three may be a comma-separated list: when a URL fails its check, the
+                                next one takes over
+  --max SIZE                    Per-thread transfer cap, e.g. 2G/500M/1GiB, or auto to size it from a 2s probe (default from MAX or %q)
+  --timeout SECONDS             Per-thread timeout in seconds, 1-120 (default from TIMEOUT or %d)
+  --threads N                   Concurrent threads, 1-64 (default from THREADS or %d)
+  --latency-count N             Latency sample count, 1-100 (default from LATENCY_COUNT or %d)
+  --latency-interval DURATION   Least time between latency probes, e.g. 100ms; 0 sends them back to back (default from LATENCY_INTERVAL or 0)
+  --latency-probe-size SIZE     Bytes of LATENCY_URL each probe requests with a Range header, e.g. 1K; empty reads the whole object (default from LATENCY_PROBE_SIZE)
+  --latency-conn MODE           Latency probe connections: reused (queuing delay), new (adds connection setup) or both (default from LATENCY_CONN or "reused")
+  --upload-payload KIND         Upload body: zero/random/pattern:TEXT/file:PATH (default from UPLOAD_PAYLOAD or %q)
+  --upload-method METHOD        Upload request method: put or post (default from UPLOAD_METHOD or "put")
+  --upload-chunked              Stream uploads without a Content-Length; =false sends the per-thread cap as length (default from UPLOAD_CHUNKED or true)
+  --download-mode MODE          Download requests: stream (whole object) or range (each thread fetches distinct byte ranges) (default from DOWNLOAD_MODE or "stream")
+  --share                       Upload the anonymized JSON report and print a link (needs SHARE_URL or GITHUB_TOKEN)
+  --share-url URL               Paste service accepting a JSON POST (default from SHARE_URL; GitHub Gist when empty)
+  --share-image PATH            Write a PNG summary card locally (default from SHARE_IMAGE)
+  --scatter PATH                Write an SVG scatter plot of loaded latency against throughput (default from SCATTER_FILE)
+  --report PATH                 Write a standalone HTML report with throughput and latency charts, the path and config (default from REPORT_FILE)
+  --skip STAGES                 Comma-separated stages to skip (default from SKIP_STAGES)
+  --phases LIST                 Run only these of latency, download and upload, e.g. download,latency (default from PHASES)
+  --download-only               Run only the download phase; combines with the two below and overrides PHASES
+  --upload-only                 Run only the upload phase
+  --latency-only                Run only the idle latency phase
+  --stage-timeout LIST          Per-stage timeouts, e.g. info=5s,download-multi=20s (default from STAGE_TIMEOUTS)
+  --config PATH                 JSON file with per-stage threads/max/timeout (default from CONFIG_FILE)
+  --limit-rate RATE             Cap total throughput, e.g. 50Mbps/500kbps/10MB/s (default from LIMIT_RATE)
+  --max-total SIZE              Hard cap on data used by all rounds combined, e.g. 500M (default from MAX_TOTAL)
+  --data-budget SIZE            Cap on data like --max-total that also shrinks each round's per-thread cap to fit,
+                                e.g. 1G for a mobile hotspot (default from DATA_BUDGET)
+  --stop-on-saturation          End each download/upload round once throughput holds within ±3%% for the saturation window,
+                                and report that steady rate (default from STOP_ON_SATURATION)
+  --saturation-window DURATION  How long throughput must hold steady, at least 2s (default from SATURATION_WINDOW or 3s)
+  --tcp-info                    Report kernel TCP stats (RTT, retransmits, cwnd) per connection; Linux/macOS (default from TCP_INFO)
+  --sysinfo                     Report the outgoing interface, its link speed, Wi-Fi signal and PHY rate, the default gateway
+                                and DNS servers; Linux/macOS (default from SYSINFO)
+  --simulate                    Run offline against a built-in CDN emulator (default from SIMULATE)
+  --simulate-opts LIST          Emulator settings, e.g. bandwidth=100Mbps,latency=30ms,errors=0.1,seed=1 (default from SIMULATE_OPTS)
+                                Faults: errors=RATE,status=CODE  drop=SIZE  stall=DURATION,stall-at=SIZE
+  --demo                        Replay a bundled recorded run in real time through the chosen output mode; no network
+                                access, nothing is written (default from DEMO)
+  --dry-run                     Same as the check command (default from DRY_RUN)
+  --history PATH                Append every run's report to this JSON-lines file (default from HISTORY_FILE)
+  --influx-url URL              After each run, write its metrics to this InfluxDB write endpoint, e.g.
+                                http://host:8086/write?db=speedtest (default from INFLUX_URL; 2.x token from INFLUX_TOKEN)
+  --graphite-addr HOST:PORT     After each run, send its metrics to this Graphite plaintext listener (default from
+                                GRAPHITE_ADDR; port 2003 when omitted)
+  --textfile PATH               After each run, replace this file with its metrics in OpenMetrics format, for the
+                                node_exporter textfile collector (default from TEXTFILE)
+  --icmp                        Also measure ICMP echo latency and compare it with HTTP (default from ICMP_LATENCY)
+  --mtu                         Probe the path MTU to the endpoint and warn about MSS clamping or PMTUD black holes (default from MTU_PROBE)
+  --dscp CLASS                  Mark test traffic with this DSCP: EF, AF41, CS1, ... or 0-63; Linux/macOS (default from DSCP)
+  --runs N                      Repeat the benchmark N times, 1-100, and report each metric's mean, median, stddev and CV;
+                                0 repeats it until interrupted, as a service (default from RUNS or 1)
+  --cooldown DURATION           Pause between runs of --runs (default from RUN_COOLDOWN or 10s)
+  --api ADDR                    Serve an HTTP API for the --runs series on ADDR, e.g. 127.0.0.1:9780: POST /run,
+                                GET /results/latest, GET /results?since=TIME (default from API_LISTEN; bearer token from API_TOKEN)
+  --compare-ip-versions         Run the benchmark over IPv4, then over IPv6 to the same CDN host, and print the difference (default from COMPARE_IP_VERSIONS)
+  --udp-echo HOST:PORT          Also measure UDP latency, jitter and loss against this echo server (default from UDP_ECHO)
+  --latency-targets LIST        Also ping these hosts idle and under load, e.g. gateway,1.1.1.1,8.8.8.8, where gateway is
+                                the default gateway, to locate bufferbloat (default from LATENCY_TARGETS)
+  --request-rate                Also measure small-object requests per second, sequential and concurrent (default from REQUEST_RATE)
+  --cache-check                 Fetch the download object with and without cache-busting queries and warn when a transparent
+                                cache on the path, rather than the CDN, answers (default from CACHE_CHECK)
+  --cold-start                  Also compare the first 2 s of a download over a new connection with one over a warmed-up
+                                connection, to show the slow-start penalty of small downloads (default from COLD_START)
+  --bidi                        Also download and upload at the same time, half the threads each, to test full duplex (default from BIDI)
+  --thread-schedule LIST        Also run a round in each direction whose workers join on a schedule of THREADS@OFFSET
+                                steps, e.g. 1@0s,2@3s,4@6s,8@9s, and report the throughput of each step
+                                (default from THREAD_SCHEDULE)
+  --overhead-model MODEL        Link framing counted when estimating the line rate behind the measured goodput: ethernet,
+                                vlan, pppoe, ip or BYTES/MTU, e.g. 38/9000 (default from OVERHEAD_MODEL or "ethernet")
+  --verify-upload               After the upload rounds, send one upload to completion and check that the server answered,
+                                acknowledged every byte and did so at the measured rate (default from VERIFY_UPLOAD)
+  --connection-mode MODE        Multi-thread rounds over auto, multi (N HTTP/1.1 connections), single-h2 (N streams on one
+                                HTTP/2 connection) or both, which compares the two (default from CONNECTION_MODE or "auto")
+  --probe-id ID                 Probe identity included in reports and uploads (default from PROBE_ID or the state file)
+  --probe-name NAME             Human-readable probe name; setting it creates a probe ID if none exists (default from PROBE_NAME)
+  --probe-state PATH            Probe state file (default from PROBE_STATE or probe.json in the user config directory)
+  --tag KEY=VALUE               Label recorded with the run in the report, history and sinks, repeatable, e.g.
+                                location=office (default from TAGS, comma-separated)
+  --event-log PATH              Write every event (stages, throughput ticks, latency samples, errors) as NDJSON (default from EVENT_LOG)
+  --log-file PATH               Also write the output as plain text, without colors or progress bars (default from LOG_FILE)
+  --prescreen MODE              Connect-time check of endpoint candidates before selection: tcp, tls or off (default from PRESCREEN or "tcp")
+  --per-asn                     When the candidates span several ASes, briefly test the first of each and compare them,
+                                e.g. an ISP's embedded cache against Apple's own PoPs (default from PER_ASN)
+  --ranking-db SOURCE           Reference distributions (file or URL) for ranking the result by ASN/country (default from RANKING_DB or built-in)
+  --no-doh                      Resolve the CDN host with the system resolver instead of DoH (default from NO_DOH)
+  --no-geo                      Skip the ip-api location lookups of the client and endpoints (default from NO_GEO)
+  --prefer-country CC           List endpoint candidates located in this country (ISO code, e.g. JP) first, instead of
+                                those in the client's country (default from PREFER_COUNTRY)
+  --dns ADDR                    Resolve through this DNS server, e.g. 192.168.1.1:53, instead of DoH and the system
+                                resolver (default from DNS_SERVER)
+  --region NAME                 Resolve the CDN host as a client in this region would, to test the path to its PoPs
+                                instead of the nearest: jp, us-west, eu-central, cn or a subnet such as
+                                203.0.113.0/24 (default from REGION)
+  --no-prompt                   Take the first endpoint instead of asking on a terminal (default from NO_PROMPT)
+  --discover                    Take the test URLs from the networkQuality config at DL_URL's origin
+                                (/api/v1/gm/config) and report the test endpoint it assigns (default from DISCOVER)
+  -H, --header "NAME: VALUE"    Extra header for test requests, repeatable, e.g. "Authorization: Bearer …" (default from
+                                HTTP_HEADERS, one per line); user:pass@ in a URL is sent as basic auth
+  --url-hook HOOK               URL or command printing JSON with fresh dl_url/ul_url/latency_url and expires_in,
+                                called at start and again before a round when they expire (default from URL_HOOK)
+  --proxy-pac PAC               PAC file (URL or path) whose FindProxyForURL picks the proxy for each test URL;
+                                test requests otherwise connect directly (default from PROXY_PAC)
+  --user-agent UA               User-Agent of test requests (default from USER_AGENT or the networkQuality one)
+  --sign TEMPLATE               Token or signature added to every test request for backends that demand one: query
+                                parameters and headers joined by &, e.g. "ts={timestamp}&sig={hmac(env:SIGN_KEY)}" or
+                                "X-Expires: {timestamp+300}"; placeholders {timestamp}, {timestamp+N}, {nonce} and
+                                {hmac(KEY)}, with env:NAME reading the key from NAME (default from REQUEST_SIGN)
+  --cacert PATH                 Trust the CA certificates in this PEM file instead of the system roots (default from CACERT)
+  --cert PATH, --key PATH       Client certificate and key (PEM) for mutual TLS (default from TLS_CERT/TLS_KEY)
+  -k, --insecure                Skip verification of the server certificate (default from TLS_INSECURE)
+  --assert-download-min MBPS    Fail with exit code 8+1 when the best download is below this (default from ASSERT_DOWNLOAD_MIN)
+  --assert-upload-min MBPS      Fail with exit code 8+2 when the best upload is below this (default from ASSERT_UPLOAD_MIN)
+  --assert-latency-max MS       Fail with exit code 8+4 when the idle latency is above this (default from ASSERT_LATENCY_MAX);
+                                failed assertions add up, e.g. 11 = download and upload
+  --widget                      Print one status line with sparklines for tmux/polybar, testing only when the cached
+                                result in the history file is stale (default from WIDGET)
+  --widget-max-age DURATION     Age after which --widget runs a new test, e.g. 15m (default from WIDGET_MAX_AGE or 30m)
+
+Compare:
+  speedtest compare runs a test, prints the change against a baseline and exits
+  with 3 when a metric regressed past its threshold. Without --baseline it uses
+  the latest entry of the history file (HISTORY_FILE, or history.jsonl in the
+  user config directory) and records the new run there.
+  --baseline PATH               Report or history file to compare against (default from COMPARE_BASELINE)
+  --threshold LIST              Allowed regression in percent, e.g. download=20,upload=20,latency=50 (default from COMPARE_THRESHOLDS; 0 disables)
+
+Register:
+  speedtest register enrolls this probe with a central collector, creating a
+  probe ID if needed, and stores the token it returns in the probe state file.
+  Uploads to the collector's host then carry that token.
+  --collector URL               Collector enrollment endpoint (default from COLLECTOR_URL)
+  --register-token TOKEN        One-time enrollment token (default from REGISTER_TOKEN)
+
+Remote:
+  speedtest remote copies this binary to each host over SSH, runs the test
+  there one host at a time, streams its progress back and ends with a table of
+  all results. Test options given on the command line, and anything after --,
+  are passed on to the remote runs.
+  --ssh COMMAND                 SSH command and options, e.g. "ssh -p 2222 -i key" (default from REMOTE_SSH or "ssh")
+  --remote-binary PATH          Binary to copy instead of this one, e.g. for another OS/arch (default from REMOTE_BINARY)
+
+Check:
+  speedtest check validates the configuration, resolves and picks the endpoint,
+  then sends one tiny request to each test URL (a 1 KiB range of the download
+  and latency URLs, a 1 KiB upload) and reports its status and latency, moving
+  no test data. It exits with 2 when a URL cannot be reached, so it can vet the
+  settings of unattended runs before they are scheduled.
+
+Note:
+  speedtest note records an annotation such as "switched to new router" in the
+  history file (HISTORY_FILE, or history.jsonl in the user config directory).
+  compare lists the notes made since its baseline, so a change in the results
+  can be tied to the event that caused it.
+
+Server:
+  speedtest server runs a UDP echo reflector for --udp-echo until interrupted.
+  It also stamps its receive time into each reply, which lets the client split
+  jitter into upstream and downstream.
+  --listen ADDR                 UDP address to listen on (default from SERVER_LISTEN or %q)
+
+Self-update:
+  speedtest self-update looks up the latest GitHub release and, when it is newer
+  than this build, downloads the binary for this platform, checks it against the
+  release's SHA-256 checksums and replaces this binary with it.
+
+Stages:
+  %s
+
+Environment variables:
+  DL_URL, UL_URL, LATENCY_URL, MAX, TIMEOUT, THREADS, LATENCY_COUNT, UPLOAD_PAYLOAD, UPLOAD_METHOD, UPLOAD_CHUNKED, DOWNLOAD_MODE
+  SHARE, SHARE_URL, SHARE_IMAGE, GITHUB_TOKEN, SKIP_STAGES, PHASES, STAGE_TIMEOUTS, CONFIG_FILE, LIMIT_RATE, MAX_TOTAL, DATA_BUDGET, TCP_INFO, SYSINFO
+  SIMULATE, SIMULATE_OPTS, DEMO, QUIET, VERBOSE, INTERVAL, NO_COLOR, TUI, HISTORY_FILE, COMPARE_BASELINE, COMPARE_THRESHOLDS
+  CONNECTION_MODE, ICMP_LATENCY, PROBE_ID, PROBE_NAME, PROBE_STATE, COLLECTOR_URL, REGISTER_TOKEN, EVENT_LOG, LOG_FILE
+  PRESCREEN, PER_ASN, REMOTE_SSH, REMOTE_BINARY, RANKING_DB, HTTP_HEADERS, USER_AGENT, LATENCY_INTERVAL, LATENCY_PROBE_SIZE, LATENCY_CONN
+  CACERT, TLS_CERT, TLS_KEY, TLS_INSECURE, WIDGET, WIDGET_MAX_AGE
+  ASSERT_DOWNLOAD_MIN, ASSERT_UPLOAD_MIN, ASSERT_LATENCY_MAX, REQUEST_RATE, UDP_ECHO, SERVER_LISTEN, DSCP, URL_HOOK, MTU_PROBE, SCATTER_FILE, REPORT_FILE,
+  RUNS, RUN_COOLDOWN, COMPARE_IP_VERSIONS, BIDI, PROXY_PAC, INFLUX_URL, INFLUX_TOKEN, GRAPHITE_ADDR, TEXTFILE, NO_DOH, NO_GEO, LATENCY_TARGETS, DISCOVER
+  PRESET, NO_PROMPT, STOP_ON_SATURATION, SATURATION_WINDOW, VERIFY_UPLOAD, PREFER_COUNTRY, OVERHEAD_MODEL, DNS_SERVER, CACHE_CHECK, DRY_RUN, API_LISTEN, API_TOKEN, REGION, COLD_START, REQUEST_SIGN, THREAD_SCHEDULE, UNITS, TAGS
+  SPEEDTEST_LANG, LC_ALL, LC_MESSAGES, LC_NUMERIC, LANGUAGE, LANG
+`, `用法:
   speedtest [选项]
+  speedtest compare [选项]
+  speedtest register --collector URL [选项]
+  speedtest remote [选项] [user@]host... [-- 远端选项]
+  speedtest server [--listen ADDR]
+  speedtest note [--history PATH] TEXT
+  speedtest version
+  speedtest self-update
   speedtest help
 
 选项:
   -h, --help                    显示帮助信息
-  -v, --version                 显示版本
-  --lang LANG                   输出语言：zh 显示中文，其他显示英文（默认读取 SPEEDTEST_LANG/LC_ALL/LC_MESSAGES/LANGUAGE/LANG）
+  -v, --version                 显示版本；speedtest version 以 JSON 输出，含 commit、Go 版本与平台
+  -q, --quiet                   仅在 stdout 输出 "down=<Mbps> up=<Mbps> latency=<ms>"（默认取 QUIET）
+  --verbose                     额外输出每个传输请求的日志（默认取 VERBOSE）
+  --interval DURATION           每轮按此间隔输出一行 iperf3 格式的吞吐，例如 1s，并在最后输出合计（默认取 INTERVAL）
+  --no-color                    关闭 ANSI 颜色（默认取 NO_COLOR）
+  --tui                         在终端中显示含速度仪表和趋势图的全屏面板（默认取 TUI）
+  --units UNIT                  显示吞吐的单位：Mbps、MB/s、Mibit 或 auto（按大小选用 Kbps/Mbps/Gbps）；
+                                JSON 与各类输出仍使用 Mbps（默认取 UNITS 或 Mbps）
+  --lang LANG                   输出语言：en、zh、zh-Hant 或 ja（默认读取 SPEEDTEST_LANG/LC_ALL/LC_MESSAGES/LANGUAGE/LANG）
+  --preset NAME                 预设参数组合：quick（每轮 8 秒，不询问节点）、standard（默认值）或 thorough
+                                （每轮 30 秒、8 线程、MAX=auto、测 3 次）；单独指定的参数与环境变量仍然生效（默认取 PRESET）
   --dl-url URL                  下载测速地址（默认取 DL_URL 或 %q）
   --ul-url URL                  上传测速地址（默认取 UL_URL 或 %q）
   --latency-url URL             延迟测速地址（默认取 LATENCY_URL 或 %q）
-  --max SIZE                    单线程流量上限，如 2G/500M/1GiB（默认取 MAX 或 %q）
+                                三者均可为逗号分隔的列表：当前地址检查失败时，自动切换到下一个
+  --max SIZE                    单线程流量上限，如 2G/500M/1GiB，auto 表示按 2 秒预测速自动选择（默认取 MAX 或 %q）
   --timeout SECONDS             单线程超时（秒），范围 1-120（默认取 TIMEOUT 或 %d）
   --threads N                   并发线程数，范围 1-64（默认取 THREADS 或 %d）
   --latency-count N             延迟采样次数，范围 1-100（默认取 LATENCY_COUNT 或 %d）
+  --latency-interval DURATION   延迟探测的最小间隔，如 100ms；0 表示连续探测（默认取 LATENCY_INTERVAL 或 0）
+  --latency-probe-size SIZE     每次探测以 Range 请求 LATENCY_URL 的字节数，如 1K；为空则读取整个对象（默认取 LATENCY_PROBE_SIZE）
+  --latency-conn MODE           延迟探测连接：reused（排队延迟）、new（含建连耗时）或 both（默认取 LATENCY_CONN 或 "reused"）
+  --upload-payload KIND         上传数据类型：zero/random/pattern:TEXT/file:PATH（默认取 UPLOAD_PAYLOAD 或 %q）
+  --upload-method METHOD        上传请求方法：put 或 post（默认取 UPLOAD_METHOD 或 "put"）
+  --upload-chunked              上传不带 Content-Length（流式分块）；=false 时以每线程上限作为长度（默认取 UPLOAD_CHUNKED 或 true）
+  --download-mode MODE          下载请求方式：stream（整个文件）或 range（各线程分别请求不同字节区间）（默认取 DOWNLOAD_MODE 或 "stream"）
+  --share                       测速完成后上传匿名化 JSON 报告并输出分享链接（需 SHARE_URL 或 GITHUB_TOKEN）
+  --share-url URL               接收 JSON POST 的粘贴服务地址（默认取 SHARE_URL；为空时使用 GitHub Gist）
+  --share-image PATH            在本地生成 PNG 结果卡片（默认取 SHARE_IMAGE）
+  --scatter PATH                生成负载延迟与吞吐量的 SVG 散点图（默认取 SCATTER_FILE）
+  --report PATH                 生成独立的 HTML 报告，含吞吐与延迟曲线、路径和配置（默认取 REPORT_FILE）
+  --skip STAGES                 跳过的阶段，逗号分隔（默认取 SKIP_STAGES）
+  --phases LIST                 只运行 latency、download、upload 中的这些部分，如 download,latency（默认取 PHASES）
+  --download-only               只测下载；可与下面两项组合，并覆盖 PHASES
+  --upload-only                 只测上传
+  --latency-only                只测空载延迟
+  --stage-timeout LIST          阶段超时，如 info=5s,download-multi=20s（默认取 STAGE_TIMEOUTS）
+  --config PATH                 JSON 配置文件，按阶段设置 threads/max/timeout（默认取 CONFIG_FILE）
+  --limit-rate RATE             限制总速率，如 50Mbps/500kbps/10MB/s（默认取 LIMIT_RATE）
+  --max-total SIZE              所有测试轮次合计的流量上限，如 500M（默认取 MAX_TOTAL）
+  --data-budget SIZE            与 --max-total 一样限制总流量，并按剩余预算缩小各轮的每线程上限，
+                                如移动热点上用 1G（默认取 DATA_BUDGET）
+  --stop-on-saturation          下载 / 上传轮次的吞吐在稳定窗口内保持在 ±3%% 以内时提前结束，并以该稳定速率作为结果
+                                （默认取 STOP_ON_SATURATION）
+  --saturation-window DURATION  吞吐需保持稳定的时长，至少 2s（默认取 SATURATION_WINDOW 或 3s）
+  --tcp-info                    输出每个连接的内核 TCP 统计（RTT、重传、拥塞窗口），仅 Linux/macOS（默认取 TCP_INFO）
+  --sysinfo                     报告出口网卡、链路速率、Wi-Fi 信号与 PHY 速率、默认网关与 DNS 服务器，仅 Linux/macOS（默认取 SYSINFO）
+  --simulate                    使用内置 CDN 模拟器离线运行（默认取 SIMULATE）
+  --simulate-opts LIST          模拟器参数，如 bandwidth=100Mbps,latency=30ms,errors=0.1,seed=1（默认取 SIMULATE_OPTS）
+                                故障注入: errors=比例,status=状态码  drop=字节数  stall=时长,stall-at=字节数
+  --demo                        按原速回放内置的一次测速录制，经所选输出模式显示；不访问网络，不写入任何文件
+                                （默认取 DEMO）
+  --dry-run                     等同于 check 命令（默认取 DRY_RUN）
+  --history PATH                将每次测速报告追加写入该 JSON Lines 文件（默认取 HISTORY_FILE）
+  --influx-url URL              每次测速完成后将指标写入该 InfluxDB 写入地址，例如
+                                http://host:8086/write?db=speedtest（默认取 INFLUX_URL；2.x 令牌取 INFLUX_TOKEN）
+  --graphite-addr HOST:PORT     每次测速完成后将指标发送至该 Graphite 明文协议监听地址（默认取 GRAPHITE_ADDR；
+                                省略端口时为 2003）
+  --textfile PATH               每次测速完成后以 OpenMetrics 格式将指标原子写入该文件，供 node_exporter 的 textfile
+                                收集器读取（默认取 TEXTFILE）
+  --icmp                        同时测量 ICMP echo 延迟并与 HTTP 延迟对比（默认取 ICMP_LATENCY）
+  --mtu                         探测到节点的路径 MTU，提示 MSS 钳制或 PMTUD 黑洞（默认取 MTU_PROBE）
+  --dscp CLASS                  以此 DSCP 标记测速流量：EF、AF41、CS1 等或 0-63，仅 Linux/macOS（默认取 DSCP）
+  --runs N                      重复测速 N 次（1-100），并统计各指标的均值、中位数、标准差与变异系数；
+                                0 表示作为服务持续测速直到中断（默认取 RUNS 或 1）
+  --cooldown DURATION           --runs 每次测速之间的间隔（默认取 RUN_COOLDOWN 或 10s）
+  --api ADDR                    在 ADDR（如 127.0.0.1:9780）上为 --runs 系列提供 HTTP API：POST /run、
+                                GET /results/latest、GET /results?since=TIME（默认取 API_LISTEN；Bearer 令牌取 API_TOKEN）
+  --compare-ip-versions         先经 IPv4、再经 IPv6 测试同一 CDN 主机，并对比两者差异（默认取 COMPARE_IP_VERSIONS）
+  --udp-echo HOST:PORT          同时测量到该 UDP 回显服务器的延迟、抖动与丢包（默认取 UDP_ECHO）
+  --latency-targets LIST        另外在空载与负载时 ping 这些主机以定位缓冲膨胀，如 gateway,1.1.1.1,8.8.8.8，
+                                gateway 表示默认网关（默认取 LATENCY_TARGETS）
+  --request-rate                同时测量小对象每秒请求数（串行与并发）（默认取 REQUEST_RATE）
+  --cache-check                 分别以带与不带防缓存参数的请求获取下载文件，发现由路径上的透明缓存而非 CDN 应答时
+                                给出警告（默认取 CACHE_CHECK）
+  --cold-start                  另外比较新连接与预热后的复用连接下载前 2 秒的速率，显示小文件下载的慢启动损失
+                                （默认取 COLD_START）
+  --bidi                        另外同时下载与上传（各用一半线程），测试全双工能力（默认取 BIDI）
+  --thread-schedule LIST        另外在每个方向各测一轮，按 线程数@偏移 的计划逐步加入线程，如 1@0s,2@3s,4@6s,8@9s，
+                                并报告每一步的吞吐（默认取 THREAD_SCHEDULE）
+  --overhead-model MODEL        由测得的有效吞吐估算线路速率时计入的链路帧开销：ethernet、vlan、pppoe、ip 或 BYTES/MTU，
+                                如 38/9000（默认取 OVERHEAD_MODEL 或 "ethernet"）
+  --verify-upload               上传轮次后完整发送一次上传，检查服务器是否应答、是否确认收到全部字节以及确认速率是否
+                                与测得速率相符（默认取 VERIFY_UPLOAD）
+  --connection-mode MODE        多线程轮次的连接方式：auto、multi（N 条 HTTP/1.1 连接）、single-h2（一条 HTTP/2 连接上
+                                的 N 个流）或 both（两者都测并对比）（默认取 CONNECTION_MODE 或 "auto"）
+  --probe-id ID                 写入报告与上传内容的探针标识（默认取 PROBE_ID 或状态文件）
+  --probe-name NAME             探针名称；设置后若尚无探针标识会自动生成（默认取 PROBE_NAME）
+  --probe-state PATH            探针状态文件（默认取 PROBE_STATE 或用户配置目录下的 probe.json）
+  --tag KEY=VALUE               随本次测速写入报告、历史记录与输出接收端的标签，可重复，如 location=office
+                                （默认取 TAGS，逗号分隔）
+  --event-log PATH              以 NDJSON 写入全部事件（阶段、吞吐采样、延迟样本、错误）（默认取 EVENT_LOG）
+  --log-file PATH               另以纯文本（无颜色与进度条）写入输出（默认取 LOG_FILE）
+  --prescreen MODE              选择节点前测量各候选节点的连接耗时：tcp、tls 或 off（默认取 PRESCREEN 或 "tcp"）
+  --per-asn                     候选节点分属多个 AS 时，对每个 AS 的首个节点做简短测速并对比，如运营商内置缓存与
+                                Apple 自有节点（默认取 PER_ASN）
+  --ranking-db SOURCE           按 ASN/国家排名所用的参考分布（文件或 URL）（默认取 RANKING_DB 或内置数据）
+  --no-doh                      使用系统 DNS 而非 DoH 解析 CDN 主机（默认取 NO_DOH）
+  --no-geo                      不通过 ip-api 查询客户端与节点的地理位置（默认取 NO_GEO）
+  --prefer-country CC           优先列出位于该国家/地区（ISO 代码，如 JP）的候选节点，而非客户端所在国家/地区的节点
+                                （默认取 PREFER_COUNTRY）
+  --dns ADDR                    通过该 DNS 服务器解析，如 192.168.1.1:53，代替 DoH 与系统 DNS（默认取 DNS_SERVER）
+  --region NAME                 以该地区客户端的身份解析 CDN 主机，测试到该地区节点而非最近节点的路径：jp、us-west、
+                                eu-central、cn 或子网如 203.0.113.0/24（默认取 REGION）
+  --no-prompt                   在终端中直接使用第一个节点而不询问（默认取 NO_PROMPT）
+  --discover                    从 DL_URL 所在源站的 networkQuality 配置（/api/v1/gm/config）获取测速地址，
+                                并报告其分配的测试节点（默认取 DISCOVER）
+  -H, --header "NAME: VALUE"    测速请求附加的请求头，可重复，如 "Authorization: Bearer …"（默认取 HTTP_HEADERS，
+                                每行一个）；URL 中的 user:pass@ 以 Basic 认证发送
+  --url-hook HOOK               输出 JSON（dl_url/ul_url/latency_url 与 expires_in）的 URL 或命令，启动时调用，
+                                测速地址过期时在下一轮之前再次调用（默认取 URL_HOOK）
+  --proxy-pac PAC               PAC 文件（URL 或路径），由其中的 FindProxyForURL 为每个测速地址选择代理；
+                                未设置时测速请求直连（默认取 PROXY_PAC）
+  --user-agent UA               测速请求的 User-Agent（默认取 USER_AGENT 或 networkQuality 的 UA）
+  --sign TEMPLATE               为要求令牌或签名的后端在每个测速请求上附加的内容：以 & 连接的查询参数与请求头，
+                                如 "ts={timestamp}&sig={hmac(env:SIGN_KEY)}" 或 "X-Expires: {timestamp+300}"；
+                                占位符 {timestamp}、{timestamp+N}、{nonce} 与 {hmac(KEY)}，env:NAME 表示从环境变量
+                                NAME 读取密钥（默认取 REQUEST_SIGN）
+  --cacert PATH                 用此 PEM 文件中的 CA 证书代替系统根证书（默认取 CACERT）
+  --cert PATH, --key PATH       双向 TLS 的客户端证书与私钥（PEM）（默认取 TLS_CERT/TLS_KEY）
+  -k, --insecure                不校验服务器证书（默认取 TLS_INSECURE）
+  --assert-download-min MBPS    最佳下载速度低于此值时以退出码 8+1 结束（默认取 ASSERT_DOWNLOAD_MIN）
+  --assert-upload-min MBPS      最佳上传速度低于此值时以退出码 8+2 结束（默认取 ASSERT_UPLOAD_MIN）
+  --assert-latency-max MS       空载延迟高于此值时以退出码 8+4 结束（默认取 ASSERT_LATENCY_MAX）；
+                                多项失败时相加，如 11 = 下载与上传
+  --widget                      为 tmux/polybar 输出一行带迷你趋势图的状态，仅当历史文件中的缓存结果过期时才重新
+                                测速（默认取 WIDGET）
+  --widget-max-age DURATION     --widget 重新测速前缓存结果的有效期，如 15m（默认取 WIDGET_MAX_AGE 或 30m）
 
-环境变量:
-  DL_URL, UL_URL, LATENCY_URL, MAX, TIMEOUT, THREADS, LATENCY_COUNT
-  SPEEDTEST_LANG, LC_ALL, LC_MESSAGES, LANGUAGE, LANG
-`, DefaultDLURL, DefaultULURL, DefaultLatencyURL, DefaultMax, DefaultTimeout, DefaultThreads, DefaultLatencyCount)
-	}
+对比:
+  speedtest compare 执行一次测速并输出相对基线的变化，任一指标的退化超过阈值时
+  以退出码 3 结束。未指定 --baseline 时，与历史文件（HISTORY_FILE，或用户配置目录
+  下的 history.jsonl）中最近一次记录对比，并把本次结果写入其中。
+  --baseline PATH               作为基线的报告或历史文件（默认取 COMPARE_BASELINE）
+  --threshold LIST              允许的退化百分比，如 download=20,upload=20,latency=50（默认取 COMPARE_THRESHOLDS；0 表示不检查）
 
-	return fmt.Sprintf(`Usage:
-  speedtest [options]
-  speedtest help
+注册:
+  speedtest register 将本探针注册到中心收集器（必要时生成探针标识），并把返回的
+  令牌保存到探针状态文件中。此后上传到该收集器主机的内容会附带此令牌。
+  --collector URL               收集器注册地址（默认取 COLLECTOR_URL）
+  --register-token TOKEN        一次性注册令牌（默认取 REGISTER_TOKEN）
 
-Options:
-  -h, --help                    Show this help message
-  -v, --version                 Show version
-  --lang LANG                   Output language: zh for Chinese, others for English (default from SPEEDTEST_LANG/LC_ALL/LC_MESSAGES/LANGUAGE/LANG)
-  --dl-url URL                  Download test URL (default from DL_URL or %q)
-  --ul-url URL                  Upload test URL (default from UL_URL or %q)
-  --latency-url URL             Latency test URL (default from LATENCY_URL or %q)
-  --max SIZE                    Per-thread transfer cap, e.g. 2G/500M/1GiB (default from MAX or %q)
-  --timeout SECONDS             Per-thread timeout in seconds, 1-120 (default from TIMEOUT or %d)
-  --threads N                   Concurrent threads, 1-64 (default from THREADS or %d)
-  --latency-count N             Latency sample count, 1-100 (default from LATENCY_COUNT or %d)
+远程:
+  speedtest remote 通过 SSH 将本程序复制到各主机，逐台执行测速，实时回传进度，
+  最后汇总所有结果。命令行中给出的测速选项以及 -- 之后的参数都会传给远端。
+  --ssh COMMAND                 SSH 命令及参数，如 "ssh -p 2222 -i key"（默认取 REMOTE_SSH 或 "ssh"）
+  --remote-binary PATH          复制该文件而非本程序，如用于其他系统/架构（默认取 REMOTE_BINARY）
 
-Environment variables:
-  DL_URL, UL_URL, LATENCY_URL, MAX, TIMEOUT, THREADS, LATENCY_COUNT
-  SPEEDTEST_LANG, LC_ALL, LC_MESSAGES, LANGUAGE, LANG
-`, DefaultDLURL, DefaultULURL, DefaultLatencyURL, DefaultMax, DefaultTimeout, DefaultThreads, DefaultLatencyCount)
+检查:
+  speedtest check 校验配置，解析并选定节点，然后向每个测速地址发送一个极小的请求
+  （下载与延迟地址读取 1 KiB 范围，上传地址上传 1 KiB），报告其状态与延迟，不传输
+  测速数据。任一地址不可达时以退出码 2 结束，便于在安排无人值守测速前检验设置。
+
+备注:
+  speedtest note 在历史文件（HISTORY_FILE，或用户配置目录下的 history.jsonl）中记录
+  一条备注，如 "switched to new router"。compare 会列出基线之后的备注，便于把结果
+  的变化与引起变化的事件对应起来。
+
+服务端:
+  speedtest server 运行供 --udp-echo 使用的 UDP 回显服务，直到被中断。它会在每个
+  回复中写入接收时间，使客户端能够区分上行与下行抖动。
+  --listen ADDR                 监听的 UDP 地址（默认取 SERVER_LISTEN 或 %q）
+
+自动更新:
+  speedtest self-update 查询 GitHub 上的最新发布，若比当前版本新，则下载本平台的
+  程序，按发布中的 SHA-256 校验值校验后替换当前程序。
+
+阶段:
+  %s
+
+环境变量:
+  DL_URL, UL_URL, LATENCY_URL, MAX, TIMEOUT, THREADS, LATENCY_COUNT, UPLOAD_PAYLOAD, UPLOAD_METHOD, UPLOAD_CHUNKED, DOWNLOAD_MODE
+  SHARE, SHARE_URL, SHARE_IMAGE, GITHUB_TOKEN, SKIP_STAGES, PHASES, STAGE_TIMEOUTS, CONFIG_FILE, LIMIT_RATE, MAX_TOTAL, DATA_BUDGET, TCP_INFO, SYSINFO
+  SIMULATE, SIMULATE_OPTS, DEMO, QUIET, VERBOSE, INTERVAL, NO_COLOR, TUI, HISTORY_FILE, COMPARE_BASELINE, COMPARE_THRESHOLDS
+  CONNECTION_MODE, ICMP_LATENCY, PROBE_ID, PROBE_NAME, PROBE_STATE, COLLECTOR_URL, REGISTER_TOKEN, EVENT_LOG, LOG_FILE
+  PRESCREEN, PER_ASN, REMOTE_SSH, REMOTE_BINARY, RANKING_DB, HTTP_HEADERS, USER_AGENT, LATENCY_INTERVAL, LATENCY_PROBE_SIZE, LATENCY_CONN
+  CACERT, TLS_CERT, TLS_KEY, TLS_INSECURE, WIDGET, WIDGET_MAX_AGE
+  ASSERT_DOWNLOAD_MIN, ASSERT_UPLOAD_MIN, ASSERT_LATENCY_MAX, REQUEST_RATE, UDP_ECHO, SERVER_LISTEN, DSCP, URL_HOOK, MTU_PROBE, SCATTER_FILE, REPORT_FILE,
+  RUNS, RUN_COOLDOWN, COMPARE_IP_VERSIONS, BIDI, PROXY_PAC, INFLUX_URL, INFLUX_TOKEN, GRAPHITE_ADDR, TEXTFILE, NO_DOH, NO_GEO, LATENCY_TARGETS, DISCOVER
+  PRESET, NO_PROMPT, STOP_ON_SATURATION, SATURATION_WINDOW, VERIFY_UPLOAD, PREFER_COUNTRY, OVERHEAD_MODEL, DNS_SERVER, CACHE_CHECK, DRY_RUN, API_LISTEN, API_TOKEN, REGION, COLD_START, REQUEST_SIGN, THREAD_SCHEDULE, UNITS, TAGS
+  SPEEDTEST_LANG, LC_ALL, LC_MESSAGES, LC_NUMERIC, LANGUAGE, LANG
+`), DefaultDLURL, DefaultULURL, DefaultLatencyURL, DefaultMax, DefaultTimeout, DefaultThreads, DefaultLatencyCount, DefaultPayload, DefaultServerListen, strings.Join(StageNames, ", "))
 }
 
 func Load(args ...string) (*Config, error) {
+	allArgs := args
 	langValue := ""
 	if v, ok := i18n.FindLangArg(args); ok {
 		langValue = v
@@ -93,6 +769,18 @@ func Load(args ...string) (*Config, error) {
 	if len(args) == 1 && args[0] == "help" {
 		return nil, ErrHelp
 	}
+	command := ""
+	if len(args) > 0 && (args[0] == "compare" || args[0] == "register" || args[0] == "remote" || args[0] == "server" || args[0] == "note" || args[0] == "check" || args[0] == "self-update") {
+		command, args = args[0], args[1:]
+	}
+	compare := command == "compare"
+	register := command == "register"
+	remote := command == "remote"
+	server := command == "server"
+	note := command == "note"
+	check := command == "check"
+	selfUpdate := command == "self-update"
+	var noteText string
 
 	dlURL := envOr("DL_URL", DefaultDLURL)
 	ulURL := envOr("UL_URL", DefaultULURL)
@@ -101,6 +789,102 @@ func Load(args ...string) (*Config, error) {
 	timeout := envInt("TIMEOUT", DefaultTimeout)
 	threads := envInt("THREADS", DefaultThreads)
 	latencyCount := envInt("LATENCY_COUNT", DefaultLatencyCount)
+	latencyInterval := envOr("LATENCY_INTERVAL", "")
+	latencyProbeSize := envOr("LATENCY_PROBE_SIZE", "")
+	latencyConn := envOr("LATENCY_CONN", LatencyReused)
+	uploadPayload := envOr("UPLOAD_PAYLOAD", DefaultPayload)
+	uploadMethod := envOr("UPLOAD_METHOD", UploadPut)
+	uploadChunked := envBool("UPLOAD_CHUNKED", true)
+	downloadMode := envOr("DOWNLOAD_MODE", DownloadStream)
+	share := envBool("SHARE", false)
+	shareURL := envOr("SHARE_URL", "")
+	shareImage := envOr("SHARE_IMAGE", "")
+	scatter := envOr("SCATTER_FILE", "")
+	reportHTML := envOr("REPORT_FILE", "")
+	skipStages := envOr("SKIP_STAGES", "")
+	phases := envOr("PHASES", "")
+	var downloadOnly, uploadOnly, latencyOnly bool
+	stageTimeouts := envOr("STAGE_TIMEOUTS", "")
+	configFile := envOr("CONFIG_FILE", "")
+	limitRate := envOr("LIMIT_RATE", "")
+	maxTotal := envOr("MAX_TOTAL", "")
+	dataBudget := envOr("DATA_BUDGET", "")
+	stopOnSaturation := envBool("STOP_ON_SATURATION", false)
+	saturationWindow := envOr("SATURATION_WINDOW", "")
+	tcpInfo := envBool("TCP_INFO", false)
+	sysInfo := envBool("SYSINFO", false)
+	simulateOn := envBool("SIMULATE", false)
+	demo := envBool("DEMO", false)
+	dryRun := envBool("DRY_RUN", false)
+	simulateOpts := envOr("SIMULATE_OPTS", "")
+	quiet := envBool("QUIET", false)
+	verbose := envBool("VERBOSE", false)
+	interval := envOr("INTERVAL", "")
+	// https://no-color.org: any non-empty value disables color.
+	noColor := os.Getenv("NO_COLOR") != ""
+	unitName := envOr("UNITS", units.Mbps)
+	tui := envBool("TUI", false)
+	historyFile := envOr("HISTORY_FILE", "")
+	influxURL := envOr("INFLUX_URL", "")
+	graphiteAddr := envOr("GRAPHITE_ADDR", "")
+	textfile := envOr("TEXTFILE", "")
+	baseline := envOr("COMPARE_BASELINE", "")
+	thresholds := envOr("COMPARE_THRESHOLDS", "")
+	connMode := envOr("CONNECTION_MODE", ConnAuto)
+	icmp := envBool("ICMP_LATENCY", false)
+	mtu := envBool("MTU_PROBE", false)
+	udpEcho := envOr("UDP_ECHO", "")
+	latencyTargets := envOr("LATENCY_TARGETS", "")
+	dscp := envOr("DSCP", "")
+	runs := envInt("RUNS", 1)
+	cooldown := envOr("RUN_COOLDOWN", "")
+	api := envOr("API_LISTEN", "")
+	compareIP := envBool("COMPARE_IP_VERSIONS", false)
+	requestRate := envBool("REQUEST_RATE", false)
+	cacheCheck := envBool("CACHE_CHECK", false)
+	coldStart := envBool("COLD_START", false)
+	verifyUpload := envBool("VERIFY_UPLOAD", false)
+	overheadModel := envOr("OVERHEAD_MODEL", overhead.DefaultLink)
+	bidi := envBool("BIDI", false)
+	threadSchedule := envOr("THREAD_SCHEDULE", "")
+	probeID := envOr("PROBE_ID", "")
+	probeName := envOr("PROBE_NAME", "")
+	probeState := envOr("PROBE_STATE", "")
+	collector := envOr("COLLECTOR_URL", "")
+	registerToken := envOr("REGISTER_TOKEN", "")
+	eventLog := envOr("EVENT_LOG", "")
+	logFile := envOr("LOG_FILE", "")
+	prescreen := envOr("PRESCREEN", PrescreenTCP)
+	perASN := envBool("PER_ASN", false)
+	rankingDB := envOr("RANKING_DB", "")
+	noDoH := envBool("NO_DOH", false)
+	noGeo := envBool("NO_GEO", false)
+	preferCountry := envOr("PREFER_COUNTRY", "")
+	dns := envOr("DNS_SERVER", "")
+	region := envOr("REGION", "")
+	noPrompt := envBool("NO_PROMPT", false)
+	preset := envOr("PRESET", "")
+	given := map[string]bool{}
+	discover := envBool("DISCOVER", false)
+	headers := &headerList{vals: splitHeaders(os.Getenv("HTTP_HEADERS"))}
+	tags := &tagList{vals: splitTags(os.Getenv("TAGS"))}
+	userAgent := envOr("USER_AGENT", UserAgent)
+	signTemplate := os.Getenv("REQUEST_SIGN")
+	urlHook := envOr("URL_HOOK", "")
+	proxyPAC := envOr("PROXY_PAC", "")
+	caCert := envOr("CACERT", "")
+	clientCert := envOr("TLS_CERT", "")
+	clientKey := envOr("TLS_KEY", "")
+	insecure := envBool("TLS_INSECURE", false)
+	widget := envBool("WIDGET", false)
+	assertDown := envFloat("ASSERT_DOWNLOAD_MIN", 0)
+	assertUp := envFloat("ASSERT_UPLOAD_MIN", 0)
+	assertLat := envFloat("ASSERT_LATENCY_MAX", 0)
+	widgetMaxAge := envOr("WIDGET_MAX_AGE", "")
+	sshCommand := envOr("REMOTE_SSH", "ssh")
+	remoteBinary := envOr("REMOTE_BINARY", "")
+	listen := envOr("SERVER_LISTEN", DefaultServerListen)
+	var remoteHosts, remoteArgs []string
 
 	if len(args) > 0 {
 		fs := flag.NewFlagSet("speedtest", flag.ContinueOnError)
@@ -109,7 +893,7 @@ func Load(args ...string) (*Config, error) {
 		help := false
 		fs.BoolVar(&help, "h", false, "show help")
 		fs.BoolVar(&help, "help", false, "show help")
-		fs.StringVar(&langValue, "lang", langValue, "output language (zh or en)")
+		fs.StringVar(&langValue, "lang", langValue, "output language (en, zh, zh-Hant, ja)")
 		fs.StringVar(&dlURL, "dl-url", dlURL, "download test URL")
 		fs.StringVar(&ulURL, "ul-url", ulURL, "upload test URL")
 		fs.StringVar(&latencyURL, "latency-url", latencyURL, "latency test URL")
@@ -117,6 +901,104 @@ func Load(args ...string) (*Config, error) {
 		fs.IntVar(&timeout, "timeout", timeout, "per-thread timeout in seconds")
 		fs.IntVar(&threads, "threads", threads, "concurrent threads")
 		fs.IntVar(&latencyCount, "latency-count", latencyCount, "latency sample count")
+		fs.StringVar(&latencyInterval, "latency-interval", latencyInterval, "least time between latency probes")
+		fs.StringVar(&latencyProbeSize, "latency-probe-size", latencyProbeSize, "bytes per latency probe")
+		fs.StringVar(&latencyConn, "latency-conn", latencyConn, "latency probe connections")
+		fs.StringVar(&uploadPayload, "upload-payload", uploadPayload, "upload payload kind")
+		fs.StringVar(&uploadMethod, "upload-method", uploadMethod, "upload request method")
+		fs.BoolVar(&uploadChunked, "upload-chunked", uploadChunked, "stream uploads without a length")
+		fs.StringVar(&downloadMode, "download-mode", downloadMode, "download request mode")
+		fs.BoolVar(&share, "share", share, "upload report and print a link")
+		fs.StringVar(&shareURL, "share-url", shareURL, "paste service URL")
+		fs.StringVar(&shareImage, "share-image", shareImage, "PNG summary card path")
+		fs.StringVar(&scatter, "scatter", scatter, "latency/throughput scatter plot path")
+		fs.StringVar(&reportHTML, "report", reportHTML, "HTML report path")
+		fs.StringVar(&skipStages, "skip", skipStages, "stages to skip")
+		fs.StringVar(&phases, "phases", phases, "phases to run")
+		fs.BoolVar(&downloadOnly, "download-only", false, "run only the download phase")
+		fs.BoolVar(&uploadOnly, "upload-only", false, "run only the upload phase")
+		fs.BoolVar(&latencyOnly, "latency-only", false, "run only the latency phase")
+		fs.StringVar(&stageTimeouts, "stage-timeout", stageTimeouts, "per-stage timeouts")
+		fs.StringVar(&configFile, "config", configFile, "per-stage limits file")
+		fs.StringVar(&limitRate, "limit-rate", limitRate, "total throughput cap")
+		fs.StringVar(&maxTotal, "max-total", maxTotal, "run-wide data cap")
+		fs.StringVar(&dataBudget, "data-budget", dataBudget, "data cap the rounds are scaled to fit")
+		fs.BoolVar(&stopOnSaturation, "stop-on-saturation", stopOnSaturation, "end rounds once throughput is steady")
+		fs.StringVar(&saturationWindow, "saturation-window", saturationWindow, "how long throughput must hold steady")
+		fs.BoolVar(&tcpInfo, "tcp-info", tcpInfo, "report kernel TCP stats")
+		fs.BoolVar(&sysInfo, "sysinfo", sysInfo, "report the local link")
+		fs.BoolVar(&simulateOn, "simulate", simulateOn, "use the built-in CDN emulator")
+		fs.StringVar(&simulateOpts, "simulate-opts", simulateOpts, "emulator settings")
+		fs.BoolVar(&demo, "demo", demo, "replay a recorded run")
+		fs.BoolVar(&dryRun, "dry-run", dryRun, "check the configuration and URLs without testing")
+		fs.BoolVar(&quiet, "q", quiet, "print only the final numbers")
+		fs.BoolVar(&quiet, "quiet", quiet, "print only the final numbers")
+		fs.BoolVar(&verbose, "verbose", verbose, "log every transfer request")
+		fs.StringVar(&interval, "interval", interval, "iperf3-style report interval")
+		fs.BoolVar(&noColor, "no-color", noColor, "disable ANSI colors")
+		fs.StringVar(&unitName, "units", unitName, "throughput display unit")
+		fs.BoolVar(&tui, "tui", tui, "full-screen dashboard")
+		fs.StringVar(&historyFile, "history", historyFile, "history file")
+		fs.StringVar(&influxURL, "influx-url", influxURL, "InfluxDB write endpoint")
+		fs.StringVar(&graphiteAddr, "graphite-addr", graphiteAddr, "Graphite plaintext listener")
+		fs.StringVar(&textfile, "textfile", textfile, "OpenMetrics file for node_exporter")
+		fs.StringVar(&baseline, "baseline", baseline, "baseline report to compare against")
+		fs.StringVar(&thresholds, "threshold", thresholds, "regression thresholds")
+		fs.StringVar(&connMode, "connection-mode", connMode, "multi-thread connection mode")
+		fs.BoolVar(&icmp, "icmp", icmp, "measure ICMP latency too")
+		fs.BoolVar(&mtu, "mtu", mtu, "probe the path MTU")
+		fs.StringVar(&udpEcho, "udp-echo", udpEcho, "UDP echo server for latency and loss")
+		fs.StringVar(&latencyTargets, "latency-targets", latencyTargets, "hosts pinged idle and under load")
+		fs.StringVar(&dscp, "dscp", dscp, "DSCP marking of test traffic")
+		fs.IntVar(&runs, "runs", runs, "repeat the benchmark N times")
+		fs.StringVar(&cooldown, "cooldown", cooldown, "pause between runs")
+		fs.StringVar(&api, "api", api, "HTTP API address for a --runs series")
+		fs.BoolVar(&compareIP, "compare-ip-versions", compareIP, "compare IPv4 with IPv6")
+		fs.BoolVar(&requestRate, "request-rate", requestRate, "measure small-object requests per second")
+		fs.BoolVar(&cacheCheck, "cache-check", cacheCheck, "check for transparent caches on the path")
+		fs.BoolVar(&coldStart, "cold-start", coldStart, "compare a new connection with a warm one")
+		fs.BoolVar(&verifyUpload, "verify-upload", verifyUpload, "check the server acknowledges an upload")
+		fs.StringVar(&overheadModel, "overhead-model", overheadModel, "link framing counted in the line rate")
+		fs.BoolVar(&bidi, "bidi", bidi, "download and upload at the same time")
+		fs.StringVar(&threadSchedule, "thread-schedule", threadSchedule, "workers joining a round step by step")
+		fs.StringVar(&probeID, "probe-id", probeID, "probe identity")
+		fs.StringVar(&probeName, "probe-name", probeName, "probe name")
+		fs.StringVar(&probeState, "probe-state", probeState, "probe state file")
+		fs.Var(tags, "tag", "key=value recorded with the run")
+		fs.StringVar(&collector, "collector", collector, "collector enrollment URL")
+		fs.StringVar(&registerToken, "register-token", registerToken, "one-time enrollment token")
+		fs.StringVar(&eventLog, "event-log", eventLog, "NDJSON event log path")
+		fs.StringVar(&logFile, "log-file", logFile, "plain-text log path")
+		fs.StringVar(&prescreen, "prescreen", prescreen, "endpoint pre-screen mode")
+		fs.BoolVar(&perASN, "per-asn", perASN, "compare one endpoint per AS")
+		fs.StringVar(&rankingDB, "ranking-db", rankingDB, "ranking reference file or URL")
+		fs.BoolVar(&noDoH, "no-doh", noDoH, "resolve with the system resolver only")
+		fs.BoolVar(&noGeo, "no-geo", noGeo, "skip the ip-api lookups")
+		fs.StringVar(&preferCountry, "prefer-country", preferCountry, "rank endpoints in this country first")
+		fs.StringVar(&dns, "dns", dns, "DNS server to resolve with")
+		fs.StringVar(&region, "region", region, "region to resolve the CDN host as from")
+		fs.BoolVar(&noPrompt, "no-prompt", noPrompt, "take the first endpoint without asking")
+		fs.StringVar(&preset, "preset", preset, "settings bundle")
+		fs.BoolVar(&discover, "discover", discover, "take the test URLs from the networkQuality config")
+		fs.Var(headers, "H", "extra request header")
+		fs.Var(headers, "header", "extra request header")
+		fs.StringVar(&userAgent, "user-agent", userAgent, "User-Agent of test requests")
+		fs.StringVar(&signTemplate, "sign", signTemplate, "token or signature added to every test request")
+		fs.StringVar(&urlHook, "url-hook", urlHook, "hook supplying fresh test URLs")
+		fs.StringVar(&proxyPAC, "proxy-pac", proxyPAC, "PAC file choosing the proxy per test URL")
+		fs.StringVar(&caCert, "cacert", caCert, "CA certificates to trust")
+		fs.StringVar(&clientCert, "cert", clientCert, "client certificate for mutual TLS")
+		fs.StringVar(&clientKey, "key", clientKey, "client key for mutual TLS")
+		fs.BoolVar(&insecure, "k", insecure, "skip server certificate verification")
+		fs.BoolVar(&insecure, "insecure", insecure, "skip server certificate verification")
+		fs.Float64Var(&assertDown, "assert-download-min", assertDown, "minimum download Mbps")
+		fs.Float64Var(&assertUp, "assert-upload-min", assertUp, "minimum upload Mbps")
+		fs.Float64Var(&assertLat, "assert-latency-max", assertLat, "maximum idle latency ms")
+		fs.BoolVar(&widget, "widget", widget, "print one status line")
+		fs.StringVar(&widgetMaxAge, "widget-max-age", widgetMaxAge, "widget cache lifetime")
+		fs.StringVar(&sshCommand, "ssh", sshCommand, "SSH command for remote")
+		fs.StringVar(&remoteBinary, "remote-binary", remoteBinary, "binary copied by remote")
+		fs.StringVar(&listen, "listen", listen, "UDP echo server address")
 
 		if err := fs.Parse(args); err != nil {
 			return nil, err
@@ -125,31 +1007,164 @@ func Load(args ...string) (*Config, error) {
 		if help {
 			return nil, ErrHelp
 		}
-		if fs.NArg() > 0 {
-			if i18n.IsZH() {
-				return nil, fmt.Errorf("存在未识别参数: %s", strings.Join(fs.Args(), " "))
+		if remote {
+			var err error
+			if remoteHosts, remoteArgs, err = remoteCommand(fs, args); err != nil {
+				return nil, err
+			}
+		} else if note {
+			noteText = strings.TrimSpace(strings.Join(fs.Args(), " "))
+		} else if fs.NArg() > 0 {
+			return nil, fmt.Errorf(i18n.Text("unexpected argument(s): %s", "存在未识别参数: %s"), strings.Join(fs.Args(), " "))
+		}
+		var misplaced error
+		fs.Visit(func(f *flag.Flag) {
+			given[f.Name] = true
+			switch {
+			case !remote && (f.Name == "ssh" || f.Name == "remote-binary"):
+				misplaced = fmt.Errorf(i18n.Text("--%s is only valid with the remote command", "--%s 仅可用于 remote 命令"), f.Name)
+			case !compare && (f.Name == "baseline" || f.Name == "threshold"):
+				misplaced = fmt.Errorf(i18n.Text("--%s is only valid with the compare command", "--%s 仅可用于 compare 命令"), f.Name)
+			case !register && (f.Name == "collector" || f.Name == "register-token"):
+				misplaced = fmt.Errorf(i18n.Text("--%s is only valid with the register command", "--%s 仅可用于 register 命令"), f.Name)
+			case !server && f.Name == "listen":
+				misplaced = fmt.Errorf(i18n.Text("--%s is only valid with the server command", "--%s 仅可用于 server 命令"), f.Name)
 			}
-			return nil, fmt.Errorf("unexpected argument(s): %s", strings.Join(fs.Args(), " "))
+		})
+		if misplaced != nil {
+			return nil, misplaced
 		}
 	}
 
 	c := &Config{
-		DLURL:        dlURL,
-		ULURL:        ulURL,
-		LatencyURL:   latencyURL,
-		Max:          maxValue,
-		Timeout:      timeout,
-		Threads:      threads,
-		LatencyCount: latencyCount,
+		DLURL:         strings.TrimSpace(dlURL),
+		ULURL:         strings.TrimSpace(ulURL),
+		LatencyURL:    strings.TrimSpace(latencyURL),
+		Max:           maxValue,
+		Timeout:       timeout,
+		Threads:       threads,
+		LatencyCount:  latencyCount,
+		UploadPayload: uploadPayload,
+		Share:         share,
+		ShareURL:      shareURL,
+		ShareToken:    os.Getenv("GITHUB_TOKEN"),
+		ShareImage:    shareImage,
+		Scatter:       scatter,
+		ReportHTML:    reportHTML,
+		ConfigFile:    configFile,
+		LimitRate:     limitRate,
+		MaxTotal:      maxTotal,
+		TCPInfo:       tcpInfo,
+		SysInfo:       sysInfo,
+		Simulate:      simulateOn,
+		SimulateOpts:  simulateOpts,
+		Demo:          demo,
+		Quiet:         quiet,
+		Verbose:       verbose,
+		NoColor:       noColor,
+		TUI:           tui,
+
+		Compare:           compare,
+		Baseline:          baseline,
+		History:           historyFile,
+		CompareThresholds: thresholds,
+		ConnectionMode:    strings.ToLower(connMode),
+		ICMP:              icmp,
+		MTU:               mtu,
+		UDPEcho:           udpEcho,
+		DSCP:              dscp,
+		Runs:              runs,
+		CompareIPVersions: compareIP,
+		RequestRate:       requestRate,
+		CacheCheck:        cacheCheck,
+		ColdStart:         coldStart,
+		VerifyUpload:      verifyUpload,
+		Bidi:              bidi,
+		UploadMethod:      strings.ToLower(strings.TrimSpace(uploadMethod)),
+		UploadFixedLength: !uploadChunked,
+		DownloadMode:      strings.ToLower(strings.TrimSpace(downloadMode)),
+		LatencyProbeSize:  strings.TrimSpace(latencyProbeSize),
+		LatencyConn:       strings.ToLower(strings.TrimSpace(latencyConn)),
+
+		ProbeID:       probeID,
+		ProbeName:     probeName,
+		ProbeState:    probeState,
+		Register:      register,
+		Collector:     collector,
+		RegisterToken: registerToken,
+		EventLog:      eventLog,
+		LogFile:       logFile,
+		Prescreen:     strings.ToLower(prescreen),
+		PerASN:        perASN,
+		RankingDB:     rankingDB,
+		NoDoH:         noDoH,
+		NoGeo:         noGeo,
+		NoPrompt:      noPrompt,
+		PreferCountry: strings.ToUpper(strings.TrimSpace(preferCountry)),
+		Discover:      discover,
+		UserAgent:     userAgent,
+		URLHook:       strings.TrimSpace(urlHook),
+		ProxyPAC:      strings.TrimSpace(proxyPAC),
+		InfluxURL:     strings.TrimSpace(influxURL),
+		InfluxToken:   os.Getenv("INFLUX_TOKEN"),
+		APIToken:      os.Getenv("API_TOKEN"),
+		GraphiteAddr:  strings.TrimSpace(graphiteAddr),
+		Textfile:      textfile,
+		CACert:        caCert,
+		ClientCert:    clientCert,
+		ClientKey:     clientKey,
+		Insecure:      insecure,
+		Widget:        widget,
+
+		AssertDownloadMin: assertDown,
+		AssertUploadMin:   assertUp,
+		AssertLatencyMax:  assertLat,
+
+		Remote:       remote,
+		RemoteHosts:  remoteHosts,
+		RemoteArgs:   remoteArgs,
+		SSHCommand:   sshCommand,
+		RemoteBinary: remoteBinary,
+		Check:        check || dryRun,
+		Note:         note,
+		NoteText:     noteText,
+		Server:       server,
+		Listen:       listen,
+		SelfUpdate:   selfUpdate,
+	}
+	c.Args = allArgs
+	c.DLURL, c.DLFallbacks = splitURLs(c.DLURL)
+	c.ULURL, c.ULFallbacks = splitURLs(c.ULURL)
+	c.LatencyURL, c.LatencyFallbacks = splitURLs(c.LatencyURL)
+	c.OverheadModel = strings.ToLower(strings.TrimSpace(overheadModel))
+	c.DataBudget = strings.TrimSpace(dataBudget)
+	c.DNS = strings.TrimSpace(dns)
+	c.API = strings.TrimSpace(api)
+
+	var ok bool
+	if c.Units, ok = units.Parse(unitName); !ok {
+		return nil, fmt.Errorf(i18n.Text("invalid UNITS %q (valid: %s)", "UNITS 值无效 %q（可选: %s）"), unitName, strings.Join(units.Names, ", "))
+	}
+
+	if preset = strings.ToLower(strings.TrimSpace(preset)); preset != "" {
+		p, ok := Presets[preset]
+		if !ok {
+			return nil, fmt.Errorf(i18n.Text("invalid PRESET %q (valid: %s)", "PRESET 值无效 %q（可选: %s）"),
+				preset, "quick, standard, thorough")
+		}
+		p.apply(c, func(flag, env string) bool { return given[flag] || os.Getenv(env) != "" })
+		c.Preset = preset
 	}
 
 	var err error
-	c.MaxBytes, err = ParseSize(c.Max)
+	if strings.EqualFold(strings.TrimSpace(c.Max), MaxAuto) {
+		c.Max = MaxAuto
+		c.MaxBytes, err = ParseSize(DefaultMax)
+	} else {
+		c.MaxBytes, err = ParseSize(c.Max)
+	}
 	if err != nil {
-		if i18n.IsZH() {
-			return nil, fmt.Errorf("MAX 值无效 %q: %w", c.Max, err)
-		}
-		return nil, fmt.Errorf("invalid MAX %q: %w", c.Max, err)
+		return nil, fmt.Errorf(i18n.Text("invalid MAX %q: %w", "MAX 值无效 %q: %w"), c.Max, err)
 	}
 	if c.MaxBytes <= 0 {
 		return nil, errors.New(i18n.Text("MAX must be > 0", "MAX 必须大于 0"))
@@ -172,28 +1187,779 @@ func Load(args ...string) (*Config, error) {
 	if c.LatencyCount > 100 {
 		return nil, errors.New(i18n.Text("LATENCY_COUNT must be <= 100", "LATENCY_COUNT 必须小于等于 100"))
 	}
-	for _, u := range []struct{ name, val string }{
-		{"DL_URL", c.DLURL},
-		{"UL_URL", c.ULURL},
-		{"LATENCY_URL", c.LatencyURL},
+	if latencyInterval != "" {
+		if c.LatencyInterval, err = parseDuration(latencyInterval); err != nil || c.LatencyInterval < 0 {
+			return nil, fmt.Errorf(i18n.Text("invalid LATENCY_INTERVAL %q", "LATENCY_INTERVAL 值无效 %q"), latencyInterval)
+		}
+	}
+	if c.LatencyProbeSize != "" {
+		if c.LatencyProbeBytes, err = ParseSize(c.LatencyProbeSize); err != nil || c.LatencyProbeBytes <= 0 {
+			return nil, fmt.Errorf(i18n.Text("invalid LATENCY_PROBE_SIZE %q", "LATENCY_PROBE_SIZE 值无效 %q"), c.LatencyProbeSize)
+		}
+	}
+	switch c.LatencyConn {
+	case LatencyReused, LatencyNew, LatencyBoth:
+	default:
+		return nil, fmt.Errorf(i18n.Text("invalid LATENCY_CONN %q (valid: %s)", "LATENCY_CONN 值无效 %q（可选: %s）"),
+			c.LatencyConn, "reused, new, both")
+	}
+	if c.Payload, err = payload.Parse(c.UploadPayload); err != nil {
+		return nil, fmt.Errorf(i18n.Text("invalid UPLOAD_PAYLOAD: %w", "UPLOAD_PAYLOAD 值无效: %w"), err)
+	}
+	switch c.UploadMethod {
+	case UploadPut, UploadPost:
+	default:
+		return nil, fmt.Errorf(i18n.Text("invalid UPLOAD_METHOD %q (valid: %s)", "UPLOAD_METHOD 值无效 %q（可选: %s）"),
+			c.UploadMethod, "put, post")
+	}
+	switch c.DownloadMode {
+	case DownloadStream, DownloadRange:
+	default:
+		return nil, fmt.Errorf(i18n.Text("invalid DOWNLOAD_MODE %q (valid: %s)", "DOWNLOAD_MODE 值无效 %q（可选: %s）"),
+			c.DownloadMode, "stream, range")
+	}
+	if c.SkipStages, err = parseStageSet(skipStages); err != nil {
+		return nil, err
+	}
+	if downloadOnly || uploadOnly || latencyOnly {
+		var only []string
+		for phase, on := range map[string]bool{PhaseDownload: downloadOnly, PhaseUpload: uploadOnly, PhaseLatency: latencyOnly} {
+			if on {
+				only = append(only, phase)
+			}
+		}
+		phases = strings.Join(only, ",")
+	}
+	if c.Phases, err = parsePhases(phases); err != nil {
+		return nil, err
+	}
+	c.skipPhases()
+	if c.StageTimeouts, err = parseStageTimeouts(stageTimeouts); err != nil {
+		return nil, err
+	}
+	if c.LimitRate != "" {
+		if c.RateBits, err = ParseRate(c.LimitRate); err != nil || c.RateBits <= 0 {
+			return nil, fmt.Errorf(i18n.Text("invalid LIMIT_RATE %q", "LIMIT_RATE 值无效 %q"), c.LimitRate)
+		}
+	}
+	if c.MaxTotal != "" {
+		if c.MaxTotalBytes, err = ParseSize(c.MaxTotal); err != nil || c.MaxTotalBytes <= 0 {
+			return nil, fmt.Errorf(i18n.Text("invalid MAX_TOTAL %q", "MAX_TOTAL 值无效 %q"), c.MaxTotal)
+		}
+	}
+	if c.DataBudget != "" {
+		if c.DataBudgetBytes, err = ParseSize(c.DataBudget); err != nil || c.DataBudgetBytes <= 0 {
+			return nil, fmt.Errorf(i18n.Text("invalid DATA_BUDGET %q", "DATA_BUDGET 值无效 %q"), c.DataBudget)
+		}
+	}
+	if c.ThreadSchedule, err = ParseThreadSchedule(threadSchedule); err != nil {
+		return nil, err
+	}
+	if stopOnSaturation {
+		c.SaturationWindow = DefaultSaturationWindow
+		if saturationWindow != "" {
+			if c.SaturationWindow, err = parseDuration(saturationWindow); err != nil || c.SaturationWindow < MinSaturationWindow {
+				return nil, fmt.Errorf(i18n.Text("invalid SATURATION_WINDOW %q (at least %s)", "SATURATION_WINDOW 值无效 %q（至少 %s）"), saturationWindow, MinSaturationWindow)
+			}
+		}
+	}
+	if c.Quiet && c.Verbose {
+		return nil, errors.New(i18n.Text("--quiet and --verbose cannot be combined", "--quiet 与 --verbose 不能同时使用"))
+	}
+	if interval != "" {
+		if c.Interval, err = parseDuration(interval); err != nil || c.Interval < MinInterval {
+			return nil, fmt.Errorf(i18n.Text("invalid INTERVAL %q (at least %s)", "INTERVAL 值无效 %q（至少 %s）"), interval, MinInterval)
+		}
+	}
+	if c.Quiet && c.TUI {
+		return nil, errors.New(i18n.Text("--quiet and --tui cannot be combined", "--quiet 与 --tui 不能同时使用"))
+	}
+	if c.Sim, err = parseSimulate(c.SimulateOpts); err != nil {
+		return nil, err
+	}
+	switch c.ConnectionMode {
+	case ConnAuto, ConnMulti, ConnSingleH2, ConnBoth:
+	default:
+		return nil, fmt.Errorf(i18n.Text("invalid CONNECTION_MODE %q (valid: %s)", "CONNECTION_MODE 值无效 %q（可选: %s）"),
+			c.ConnectionMode, "auto, multi, single-h2, both")
+	}
+	switch c.Prescreen {
+	case PrescreenTCP, PrescreenTLS, PrescreenOff:
+	default:
+		return nil, fmt.Errorf(i18n.Text("invalid PRESCREEN %q (valid: %s)", "PRESCREEN 值无效 %q（可选: %s）"),
+			c.Prescreen, "tcp, tls, off")
+	}
+	if c.Link, err = overhead.ParseLink(c.OverheadModel); err != nil {
+		return nil, fmt.Errorf(i18n.Text("invalid OVERHEAD_MODEL %q (valid: %s)", "OVERHEAD_MODEL 值无效 %q（可选: %s）"),
+			c.OverheadModel, "ethernet, vlan, pppoe, ip, BYTES/MTU")
+	}
+	if strings.TrimSpace(region) != "" {
+		if c.Region, err = ParseRegion(region); err != nil {
+			return nil, err
+		}
+		// The client subnet rides on the DoH queries.
+		if c.NoDoH || c.DNS != "" {
+			return nil, errors.New(i18n.Text("--region resolves over DoH and cannot be combined with --no-doh or --dns", "--region 通过 DoH 解析，不能与 --no-doh 或 --dns 同时使用"))
+		}
+	}
+	if c.PreferCountry != "" && !isCountryCode(c.PreferCountry) {
+		return nil, fmt.Errorf(i18n.Text("invalid PREFER_COUNTRY %q (a two-letter ISO code such as JP)", "PREFER_COUNTRY 值无效 %q（应为两字母 ISO 代码，如 JP）"), c.PreferCountry)
+	}
+	if c.DNS != "" {
+		if c.DNS, err = dnsServer(c.DNS); err != nil {
+			return nil, err
+		}
+	}
+	if c.Headers, err = parseHeaders(headers.vals); err != nil {
+		return nil, err
+	}
+	if c.Tags, err = parseTags(tags.vals); err != nil {
+		return nil, err
+	}
+	if strings.TrimSpace(signTemplate) != "" {
+		if c.Sign, err = sign.Parse(signTemplate); err != nil {
+			return nil, fmt.Errorf(i18n.Text("invalid REQUEST_SIGN: %v", "REQUEST_SIGN 无效: %v"), err)
+		}
+	}
+	if c.TLS, err = loadTLS(c.CACert, c.ClientCert, c.ClientKey, c.Insecure); err != nil {
+		return nil, err
+	}
+	if c.Thresholds, err = parseThresholds(c.CompareThresholds); err != nil {
+		return nil, err
+	}
+	if c.Runs < 0 || c.Runs > 100 {
+		return nil, errors.New(i18n.Text("RUNS must be between 1 and 100, or 0 to run until interrupted", "RUNS 必须在 1 到 100 之间，或为 0 表示持续运行直到中断"))
+	}
+	c.Cooldown = DefaultRunCooldown
+	if cooldown != "" {
+		if c.Cooldown, err = parseDuration(cooldown); err != nil || c.Cooldown < 0 {
+			return nil, fmt.Errorf(i18n.Text("invalid RUN_COOLDOWN %q", "RUN_COOLDOWN 值无效 %q"), cooldown)
+		}
+	}
+	if c.API != "" {
+		if !c.Series() {
+			return nil, errors.New(i18n.Text("--api requires --runs of 2 or more, or 0", "--api 需要 --runs 至少为 2，或为 0"))
+		}
+		host, _, err := net.SplitHostPort(c.API)
+		if err != nil {
+			return nil, fmt.Errorf(i18n.Text("invalid API_LISTEN %q, want [host]:port", "API_LISTEN 值无效 %q，应为 [host]:port"), c.API)
+		}
+		if c.APIToken == "" && !isLoopback(host) {
+			return nil, fmt.Errorf(i18n.Text("API_LISTEN %q is not a loopback address; set API_TOKEN to serve the API on it", "API_LISTEN %q 不是回环地址，需设置 API_TOKEN 才能在其上提供 API"), c.API)
+		}
+	}
+	if c.CompareIPVersions {
+		switch {
+		case c.Series():
+			return nil, errors.New(i18n.Text("--runs cannot be combined with --compare-ip-versions", "--runs 不能与 --compare-ip-versions 同时使用"))
+		case c.Simulate || c.Widget:
+			return nil, errors.New(i18n.Text("--compare-ip-versions cannot be combined with --simulate or --widget", "--compare-ip-versions 不能与 --simulate 或 --widget 同时使用"))
+		case !c.StageEnabled(StageEndpoint):
+			return nil, errors.New(i18n.Text("--compare-ip-versions needs the endpoint stage", "--compare-ip-versions 需要 endpoint 阶段"))
+		}
+	}
+	if c.AssertDownloadMin < 0 || c.AssertUploadMin < 0 || c.AssertLatencyMax < 0 {
+		return nil, errors.New(i18n.Text("assertion limits must not be negative", "断言阈值不能为负数"))
+	}
+	c.WidgetMaxAge = DefaultWidgetMaxAge
+	if widgetMaxAge != "" {
+		if c.WidgetMaxAge, err = parseDuration(widgetMaxAge); err != nil || c.WidgetMaxAge < 0 {
+			return nil, fmt.Errorf(i18n.Text("invalid WIDGET_MAX_AGE %q", "WIDGET_MAX_AGE 值无效 %q"), widgetMaxAge)
+		}
+	}
+	if c.Demo && command != "" {
+		return nil, fmt.Errorf(i18n.Text("--demo cannot be used with the %s command", "--demo 不能用于 %s 命令"), command)
+	}
+	if dryRun && command != "" && command != "check" {
+		return nil, fmt.Errorf(i18n.Text("--dry-run cannot be used with the %s command", "--dry-run 不能用于 %s 命令"), command)
+	}
+	if c.Check && (c.Demo || c.Widget) {
+		return nil, errors.New(i18n.Text("check cannot be combined with --demo or --widget", "check 不能与 --demo 或 --widget 同时使用"))
+	}
+	if c.Demo && c.Widget {
+		return nil, errors.New(i18n.Text("--demo cannot be combined with --widget", "--demo 不能与 --widget 同时使用"))
+	}
+	if c.Widget {
+		if command != "" {
+			return nil, fmt.Errorf(i18n.Text("--widget cannot be used with the %s command", "--widget 不能用于 %s 命令"), command)
+		}
+		if c.Series() {
+			return nil, errors.New(i18n.Text("--runs cannot be combined with --widget", "--runs 不能与 --widget 同时使用"))
+		}
+		if c.History == "" {
+			if c.History, err = history.DefaultPath(); err != nil {
+				return nil, fmt.Errorf(i18n.Text("no history location for --widget, set HISTORY_FILE: %v", "无法确定 --widget 的历史文件位置，请设置 HISTORY_FILE: %v"), err)
+			}
+		}
+	}
+	if c.Note {
+		if c.NoteText == "" {
+			return nil, errors.New(i18n.Text("note requires the text to record", "note 需要提供备注内容"))
+		}
+		if c.History == "" {
+			if c.History, err = history.DefaultPath(); err != nil {
+				return nil, fmt.Errorf(i18n.Text("no history location, set HISTORY_FILE: %v", "无法确定历史文件位置，请设置 HISTORY_FILE: %v"), err)
+			}
+		}
+	}
+	if c.Compare && c.Baseline == "" && c.History == "" {
+		if c.History, err = history.DefaultPath(); err != nil {
+			return nil, fmt.Errorf(i18n.Text("no history location, set HISTORY_FILE or --baseline: %v", "无法确定历史文件位置，请设置 HISTORY_FILE 或 --baseline: %v"), err)
+		}
+	}
+	if c.Remote {
+		if len(c.RemoteHosts) == 0 {
+			return nil, errors.New(i18n.Text("remote requires at least one host", "remote 需要至少一个主机"))
+		}
+		if len(strings.Fields(c.SSHCommand)) == 0 {
+			return nil, errors.New(i18n.Text("REMOTE_SSH must not be empty", "REMOTE_SSH 不能为空"))
+		}
+	}
+	if c.DSCP != "" {
+		if !netx.TOSSupported {
+			return nil, errors.New(i18n.Text("--dscp is not supported on this platform", "当前平台不支持 --dscp"))
+		}
+		if c.DSCP, c.TOS, err = parseDSCP(c.DSCP); err != nil {
+			return nil, err
+		}
+	}
+	if c.UDPEcho != "" {
+		if _, port, err := net.SplitHostPort(c.UDPEcho); err != nil || port == "" {
+			return nil, fmt.Errorf(i18n.Text("invalid UDP_ECHO %q, want host:port", "UDP_ECHO 值无效 %q，应为 host:port"), c.UDPEcho)
+		}
+	}
+	if c.LatencyTargets, err = parseTargets(latencyTargets); err != nil {
+		return nil, err
+	}
+	if c.InfluxURL != "" && !strings.HasPrefix(c.InfluxURL, "http://") && !strings.HasPrefix(c.InfluxURL, "https://") {
+		return nil, errors.New(i18n.Text("INFLUX_URL must start with http(s)://", "INFLUX_URL 必须以 http(s):// 开头"))
+	}
+	if c.GraphiteAddr != "" {
+		if _, _, err := net.SplitHostPort(c.GraphiteAddr); err != nil {
+			c.GraphiteAddr = net.JoinHostPort(c.GraphiteAddr, "2003")
+		}
+		if host, _, err := net.SplitHostPort(c.GraphiteAddr); err != nil || host == "" {
+			return nil, fmt.Errorf(i18n.Text("invalid GRAPHITE_ADDR %q, want host[:port]", "GRAPHITE_ADDR 值无效 %q，应为 host[:port]"), c.GraphiteAddr)
+		}
+	}
+	if c.Server {
+		if _, _, err := net.SplitHostPort(c.Listen); err != nil {
+			return nil, fmt.Errorf(i18n.Text("invalid SERVER_LISTEN %q, want [host]:port", "SERVER_LISTEN 值无效 %q，应为 [host]:port"), c.Listen)
+		}
+	}
+	if c.ProbeState == "" {
+		// Without a config directory the identity still applies to this run;
+		// it just is not remembered.
+		c.ProbeState, _ = probe.DefaultPath()
+	}
+	if c.Register {
+		if c.Collector == "" {
+			return nil, errors.New(i18n.Text("register requires --collector or COLLECTOR_URL", "register 需要设置 --collector 或 COLLECTOR_URL"))
+		}
+		if !strings.HasPrefix(c.Collector, "http://") && !strings.HasPrefix(c.Collector, "https://") {
+			return nil, errors.New(i18n.Text("COLLECTOR_URL must start with http(s)://", "COLLECTOR_URL 必须以 http(s):// 开头"))
+		}
+		if c.ProbeState == "" {
+			return nil, errors.New(i18n.Text("no probe state location, set PROBE_STATE", "无法确定探针状态文件位置，请设置 PROBE_STATE"))
+		}
+	}
+	if c.ConfigFile != "" {
+		if c.StageLimits, err = loadFile(c.ConfigFile); err != nil {
+			return nil, err
+		}
+	}
+	if c.Share && c.ShareURL == "" && c.ShareToken == "" {
+		return nil, errors.New(i18n.Text("--share requires SHARE_URL or GITHUB_TOKEN", "--share 需要设置 SHARE_URL 或 GITHUB_TOKEN"))
+	}
+	if c.ShareURL != "" && !strings.HasPrefix(c.ShareURL, "http://") && !strings.HasPrefix(c.ShareURL, "https://") {
+		return nil, errors.New(i18n.Text("SHARE_URL must start with http(s)://", "SHARE_URL 必须以 http(s):// 开头"))
+	}
+	for _, u := range []struct {
+		name string
+		vals []string
+	}{
+		{"DL_URL", append([]string{c.DLURL}, c.DLFallbacks...)},
+		{"UL_URL", append([]string{c.ULURL}, c.ULFallbacks...)},
+		{"LATENCY_URL", append([]string{c.LatencyURL}, c.LatencyFallbacks...)},
 	} {
-		if !strings.HasPrefix(u.val, "http://") && !strings.HasPrefix(u.val, "https://") {
-			if i18n.IsZH() {
-				return nil, fmt.Errorf("%s 必须以 http(s):// 开头", u.name)
+		for _, v := range u.vals {
+			if !strings.HasPrefix(v, "http://") && !strings.HasPrefix(v, "https://") {
+				return nil, fmt.Errorf(i18n.Text("%s must start with http(s)://", "%s 必须以 http(s):// 开头"), u.name)
 			}
-			return nil, fmt.Errorf("%s must start with http(s)://", u.name)
 		}
 	}
 	return c, nil
 }
 
-func (c *Config) Summary() string {
-	if i18n.IsZH() {
-		return fmt.Sprintf("超时=%ds  上限=%s  线程=%d  延迟采样=%d",
-			c.Timeout, c.Max, c.Threads, c.LatencyCount)
+// localFlags only affect this process and are not passed on by `remote`.
+var localFlags = map[string]bool{
+	"h": true, "help": true, "q": true, "quiet": true, "verbose": true, "no-color": true, "tui": true, "lang": true, "units": true,
+	"event-log": true, "log-file": true, "history": true, "probe-state": true, "ssh": true, "remote-binary": true,
+}
+
+// remoteCommand splits the arguments left after the flags into hosts and
+// the options for the remote runs: the test flags set on the command line,
+// then everything after "--".
+func remoteCommand(fs *flag.FlagSet, args []string) (hosts, forward []string, err error) {
+	headersSent := false
+	fs.Visit(func(f *flag.Flag) {
+		switch v := f.Value.(type) {
+		case *headerList:
+			// -H and --header share the list; forward each entry once.
+			if !headersSent {
+				for _, h := range v.vals {
+					forward = append(forward, "--header="+h)
+				}
+				headersSent = true
+			}
+		case *tagList:
+			for _, t := range v.vals {
+				forward = append(forward, "--tag="+t)
+			}
+		default:
+			if !localFlags[f.Name] {
+				forward = append(forward, "--"+f.Name+"="+f.Value.String())
+			}
+		}
+	})
+	rest := fs.Args()
+	// flag.Parse drops a "--" directly after the flags; it still ends the
+	// host list.
+	if n := len(args) - len(rest); n > 0 && args[n-1] == "--" {
+		return nil, append(forward, rest...), nil
+	}
+	for i, a := range rest {
+		if a == "--" {
+			return hosts, append(forward, rest[i+1:]...), nil
+		}
+		if strings.HasPrefix(a, "-") {
+			return nil, nil, fmt.Errorf(i18n.Text("%s: options must come before the hosts or after --", "%s: 选项必须位于主机之前或 -- 之后"), a)
+		}
+		hosts = append(hosts, a)
 	}
-	return fmt.Sprintf("timeout=%ds  max=%s  threads=%d  latency_count=%d",
+	return hosts, forward, nil
+}
+
+func (c *Config) Summary() string {
+	s := fmt.Sprintf(i18n.Text("timeout=%ds  max=%s  threads=%d  latency_count=%d", "超时=%ds  上限=%s  线程=%d  延迟采样=%d"),
 		c.Timeout, c.Max, c.Threads, c.LatencyCount)
+	if c.Preset != "" {
+		s += fmt.Sprintf("  %s=%s", i18n.Text("preset", "预设"), c.Preset)
+	}
+	if c.UploadPayload != "" && c.UploadPayload != DefaultPayload {
+		s += fmt.Sprintf("  %s=%s", i18n.Text("payload", "上传数据"), c.UploadPayload)
+	}
+	if c.UploadMethod != "" && c.UploadMethod != UploadPut {
+		s += "  upload=" + strings.ToUpper(c.UploadMethod)
+	}
+	if c.UploadFixedLength {
+		s += "  " + i18n.Text("fixed-length uploads", "定长上传")
+	}
+	if c.DownloadMode == DownloadRange {
+		s += "  " + i18n.Text("range downloads", "分段下载")
+	}
+	if c.LatencyInterval > 0 {
+		s += fmt.Sprintf("  %s=%v", i18n.Text("probe interval", "探测间隔"), c.LatencyInterval)
+	}
+	if c.LatencyProbeSize != "" {
+		s += fmt.Sprintf("  %s=%s", i18n.Text("probe size", "探测大小"), c.LatencyProbeSize)
+	}
+	if c.LatencyConn != "" && c.LatencyConn != LatencyReused {
+		s += fmt.Sprintf("  %s=%s", i18n.Text("probe connections", "探测连接"), c.LatencyConn)
+	}
+	if c.LimitRate != "" {
+		s += fmt.Sprintf("  %s=%s", i18n.Text("rate", "限速"), c.LimitRate)
+	}
+	if c.MaxTotal != "" {
+		s += fmt.Sprintf("  %s=%s", i18n.Text("total", "总上限"), c.MaxTotal)
+	}
+	if c.DataBudget != "" {
+		s += fmt.Sprintf("  %s=%s", i18n.Text("budget", "流量预算"), c.DataBudget)
+	}
+	if c.SaturationWindow > 0 {
+		s += fmt.Sprintf("  %s=%v", i18n.Text("stop on saturation", "饱和即停"), c.SaturationWindow)
+	}
+	if c.OverheadModel != "" && c.OverheadModel != overhead.DefaultLink {
+		s += fmt.Sprintf("  %s=%s", i18n.Text("link", "链路"), c.OverheadModel)
+	}
+	if c.DNS != "" {
+		s += "  dns=" + c.DNS
+	}
+	if c.Region.Name != "" {
+		s += fmt.Sprintf("  %s=%s", i18n.Text("region", "地区"), c.Region.Name)
+	}
+	if c.Simulate {
+		s += "  " + i18n.Text("simulate", "模拟")
+		if c.SimulateOpts != "" {
+			s += "=" + c.SimulateOpts
+		}
+	}
+	if c.ConfigFile != "" {
+		s += fmt.Sprintf("  %s=%s", i18n.Text("config", "配置文件"), c.ConfigFile)
+	}
+	if c.Insecure {
+		s += "  " + i18n.Text("insecure", "不校验证书")
+	}
+	if c.Phases != nil {
+		s += fmt.Sprintf("  %s=%s", i18n.Text("phases", "测试部分"), strings.Join(c.Phases, ","))
+	}
+	if c.ConnectionMode != "" && c.ConnectionMode != ConnAuto {
+		s += fmt.Sprintf("  %s=%s", i18n.Text("connections", "连接"), c.ConnectionMode)
+	}
+	if c.DSCP != "" {
+		s += fmt.Sprintf("  dscp=%s", c.DSCP)
+	}
+	switch {
+	case c.Runs == 0:
+		s += fmt.Sprintf("  %s=%s", i18n.Text("runs", "次数"), i18n.Text("until interrupted", "直到中断"))
+	case c.Runs > 1:
+		s += fmt.Sprintf("  %s=%d", i18n.Text("runs", "次数"), c.Runs)
+	}
+	if c.CompareIPVersions {
+		s += "  " + i18n.Text("IPv4 vs IPv6", "IPv4 对比 IPv6")
+	}
+	if c.Compare {
+		base := c.Baseline
+		if base == "" {
+			base = c.History
+		}
+		s += fmt.Sprintf("  %s=%s", i18n.Text("baseline", "基线"), base)
+	}
+	return s
+}
+
+// Asserting reports whether any --assert-* limit is set.
+func (c *Config) Asserting() bool {
+	return c.AssertDownloadMin > 0 || c.AssertUploadMin > 0 || c.AssertLatencyMax > 0
+}
+
+// TotalCap returns the cap on the data of the whole run, the smaller of
+// MAX_TOTAL and DATA_BUDGET, as written and in bytes; zero bytes when
+// neither is set.
+func (c *Config) TotalCap() (string, int64) {
+	if c.DataBudgetBytes > 0 && (c.MaxTotalBytes == 0 || c.DataBudgetBytes < c.MaxTotalBytes) {
+		return c.DataBudget, c.DataBudgetBytes
+	}
+	return c.MaxTotal, c.MaxTotalBytes
+}
+
+// StageEnabled reports whether the named stage should run.
+func (c *Config) StageEnabled(name string) bool {
+	return !c.SkipStages[name]
+}
+
+func isStage(name string) bool {
+	for _, s := range StageNames {
+		if s == name {
+			return true
+		}
+	}
+	return false
+}
+
+func unknownStageErr(name string) error {
+	return fmt.Errorf(i18n.Text("unknown stage %q (valid: %s)", "未知阶段 %q（可选: %s）"), name, strings.Join(StageNames, ", "))
+}
+
+// parsePhases parses the comma-separated PHASES into run order; empty means
+// every phase.
+func parsePhases(s string) ([]string, error) {
+	set := map[string]bool{}
+	for _, p := range strings.Split(s, ",") {
+		p = strings.ToLower(strings.TrimSpace(p))
+		if p == "" {
+			continue
+		}
+		if !slices.Contains(PhaseNames, p) {
+			return nil, fmt.Errorf(i18n.Text("unknown phase %q (valid: %s)", "未知测试部分 %q（可选: %s）"), p, strings.Join(PhaseNames, ", "))
+		}
+		set[p] = true
+	}
+	if len(set) == 0 {
+		return nil, nil
+	}
+	var out []string
+	for _, p := range PhaseNames {
+		if set[p] {
+			out = append(out, p)
+		}
+	}
+	return out, nil
+}
+
+// skipPhases adds the stages of the phases left out of c.Phases to
+// c.SkipStages. The bidirectional round needs both transfer phases; sizing
+// MAX=auto and idle latency after load need one of them.
+func (c *Config) skipPhases() {
+	if c.Phases == nil {
+		return
+	}
+	for _, p := range PhaseNames {
+		if !slices.Contains(c.Phases, p) {
+			for _, s := range phaseStages[p] {
+				c.SkipStages[s] = true
+			}
+		}
+	}
+	dl, ul := slices.Contains(c.Phases, PhaseDownload), slices.Contains(c.Phases, PhaseUpload)
+	if !dl || !ul {
+		c.SkipStages[StageBidirectional] = true
+	}
+	if !dl && !ul {
+		c.SkipStages[StageAutoMax] = true
+		c.SkipStages[StageThreadRamp] = true
+		c.SkipStages[StageIdleAfter] = true
+	}
+}
+
+// splitURLs splits a comma-separated URL list into the URL to start with
+// and its fallbacks. A URL holding a comma must escape it as %2C.
+func splitURLs(s string) (string, []string) {
+	var urls []string
+	for _, u := range strings.Split(s, ",") {
+		if u = strings.TrimSpace(u); u != "" {
+			urls = append(urls, u)
+		}
+	}
+	switch len(urls) {
+	case 0:
+		return "", nil
+	case 1:
+		return urls[0], nil
+	}
+	return urls[0], urls[1:]
+}
+
+// HasFallbacks reports whether any test URL was given as a list.
+func (c *Config) HasFallbacks() bool {
+	return len(c.DLFallbacks)+len(c.ULFallbacks)+len(c.LatencyFallbacks) > 0
+}
+
+// Series reports whether the benchmark repeats: Runs above 1, or 0 for a
+// series that runs until interrupted.
+func (c *Config) Series() bool {
+	return c.Runs != 1
+}
+
+// RunsPhase reports whether PHASES includes phase; every phase runs when
+// PHASES is unset.
+func (c *Config) RunsPhase(phase string) bool {
+	return c.Phases == nil || slices.Contains(c.Phases, phase)
+}
+
+func parseStageSet(s string) (map[string]bool, error) {
+	out := map[string]bool{}
+	for _, name := range strings.Split(s, ",") {
+		name = strings.ToLower(strings.TrimSpace(name))
+		if name == "" {
+			continue
+		}
+		if !isStage(name) {
+			return nil, unknownStageErr(name)
+		}
+		out[name] = true
+	}
+	return out, nil
+}
+
+// TargetGateway in LATENCY_TARGETS stands for the default gateway.
+const TargetGateway = "gateway"
+
+var hostRe = regexp.MustCompile(`^[A-Za-z0-9]([A-Za-z0-9.-]*[A-Za-z0-9])?$`)
+
+// parseTargets parses the comma-separated LATENCY_TARGETS, dropping
+// duplicates.
+func parseTargets(s string) ([]string, error) {
+	var out []string
+	for _, t := range strings.Split(s, ",") {
+		t = strings.TrimSpace(t)
+		if strings.EqualFold(t, TargetGateway) {
+			t = TargetGateway
+		}
+		if t == "" || slices.Contains(out, t) {
+			continue
+		}
+		if net.ParseIP(t) == nil && !hostRe.MatchString(t) {
+			return nil, fmt.Errorf(i18n.Text("invalid latency target %q, want an IP, a host name or gateway", "延迟目标无效 %q，应为 IP、主机名或 gateway"), t)
+		}
+		out = append(out, t)
+	}
+	return out, nil
+}
+
+// parseStageTimeouts parses "stage=duration" pairs; a bare number is seconds.
+func parseStageTimeouts(s string) (map[string]time.Duration, error) {
+	out := map[string]time.Duration{}
+	for _, item := range strings.Split(s, ",") {
+		item = strings.TrimSpace(item)
+		if item == "" {
+			continue
+		}
+		name, val, ok := strings.Cut(item, "=")
+		name = strings.ToLower(strings.TrimSpace(name))
+		if !ok {
+			return nil, fmt.Errorf(i18n.Text("invalid stage timeout %q, want stage=duration", "阶段超时格式无效 %q，应为 阶段=时长"), item)
+		}
+		if !isStage(name) {
+			return nil, unknownStageErr(name)
+		}
+		d, err := parseDuration(strings.TrimSpace(val))
+		if err != nil || d <= 0 {
+			return nil, fmt.Errorf(i18n.Text("invalid timeout for stage %s: %q", "阶段 %s 的超时无效: %q"), name, val)
+		}
+		out[name] = d
+	}
+	return out, nil
+}
+
+// parseSimulate parses SIMULATE_OPTS "key=value" pairs on top of the
+// emulator defaults. A bare latency number is milliseconds.
+func parseSimulate(s string) (simulate.Options, error) {
+	opts := simulate.DefaultOptions()
+	for _, item := range strings.Split(s, ",") {
+		item = strings.TrimSpace(item)
+		if item == "" {
+			continue
+		}
+		key, val, _ := strings.Cut(item, "=")
+		key = strings.ToLower(strings.TrimSpace(key))
+		val = strings.TrimSpace(val)
+		var err error
+		switch key {
+		case "bandwidth":
+			opts.Bandwidth, err = ParseRate(val)
+		case "latency":
+			if n, aerr := strconv.Atoi(val); aerr == nil {
+				opts.Latency = time.Duration(n) * time.Millisecond
+			} else {
+				opts.Latency, err = time.ParseDuration(val)
+			}
+		case "errors":
+			opts.ErrorRate, err = strconv.ParseFloat(val, 64)
+			if err == nil && (opts.ErrorRate < 0 || opts.ErrorRate > 1) {
+				err = errors.New("must be between 0 and 1")
+			}
+		case "status":
+			opts.Status, err = strconv.Atoi(val)
+			if err == nil && (opts.Status < 400 || opts.Status > 599) {
+				err = errors.New("must be a 4xx or 5xx code")
+			}
+		case "seed":
+			opts.Seed, err = strconv.ParseUint(val, 10, 64)
+		case "size":
+			opts.LargeSize, err = ParseSize(val)
+		case "drop":
+			opts.DropAfter, err = ParseSize(val)
+		case "stall":
+			opts.Stall, err = time.ParseDuration(val)
+		case "stall-at":
+			opts.StallAt, err = ParseSize(val)
+		default:
+			return opts, fmt.Errorf(i18n.Text("unknown SIMULATE_OPTS key %q (valid: %s)", "SIMULATE_OPTS 未知参数 %q（可选: %s）"),
+				key, "bandwidth, latency, errors, status, seed, size, drop, stall, stall-at")
+		}
+		if err == nil && (opts.Bandwidth < 0 || opts.Latency < 0 || opts.LargeSize < 0 || opts.Stall < 0) {
+			err = errors.New("must not be negative")
+		}
+		if err != nil {
+			return opts, fmt.Errorf(i18n.Text("invalid SIMULATE_OPTS %s=%q: %v", "SIMULATE_OPTS 参数无效 %s=%q: %v"), key, val, err)
+		}
+	}
+	return opts, nil
+}
+
+// parseThresholds parses COMPARE_THRESHOLDS "metric=percent" pairs on top of
+// the default thresholds. A trailing % is accepted.
+func parseThresholds(s string) (history.Thresholds, error) {
+	th := history.DefaultThresholds()
+	for _, item := range strings.Split(s, ",") {
+		item = strings.TrimSpace(item)
+		if item == "" {
+			continue
+		}
+		key, val, _ := strings.Cut(item, "=")
+		key = strings.ToLower(strings.TrimSpace(key))
+		val = strings.TrimSpace(val)
+		pct, err := strconv.ParseFloat(strings.TrimSuffix(val, "%"), 64)
+		if err != nil || pct < 0 {
+			return th, fmt.Errorf(i18n.Text("invalid COMPARE_THRESHOLDS %s=%q", "COMPARE_THRESHOLDS 参数无效 %s=%q"), key, val)
+		}
+		switch key {
+		case history.MetricDownload:
+			th.Download = pct
+		case history.MetricUpload:
+			th.Upload = pct
+		case history.MetricLatency:
+			th.Latency = pct
+		default:
+			return th, fmt.Errorf(i18n.Text("unknown COMPARE_THRESHOLDS metric %q (valid: %s)", "COMPARE_THRESHOLDS 未知指标 %q（可选: %s）"),
+				key, "download, upload, latency")
+		}
+	}
+	return th, nil
+}
+
+// parseDSCP parses DSCP as a per-hop behavior name (EF, VA, LE, CS0-CS7,
+// AF11-AF43) or a code point 0-63, returning the canonical name and the TOS
+// byte carrying it.
+func parseDSCP(s string) (name string, tos int, err error) {
+	name = strings.ToUpper(strings.TrimSpace(s))
+	code := -1
+	switch {
+	case name == "EF":
+		code = 46
+	case name == "VA":
+		code = 44
+	case name == "LE":
+		code = 1
+	case len(name) == 3 && name[:2] == "CS" && name[2] >= '0' && name[2] <= '7':
+		code = int(name[2]-'0') * 8
+	case len(name) == 4 && name[:2] == "AF" && name[2] >= '1' && name[2] <= '4' && name[3] >= '1' && name[3] <= '3':
+		code = int(name[2]-'0')*8 + int(name[3]-'0')*2
+	default:
+		if n, err := strconv.Atoi(name); err == nil && n >= 0 && n <= 63 {
+			code = n
+		}
+	}
+	if code < 0 {
+		return "", 0, fmt.Errorf(i18n.Text("invalid DSCP %q (valid: EF, VA, LE, CS0-CS7, AF11-AF43 or 0-63)", "DSCP 值无效 %q（可选: EF、VA、LE、CS0-CS7、AF11-AF43 或 0-63）"), s)
+	}
+	return name, code << 2, nil
+}
+
+// dnsServer reads DNS_SERVER, an IP address with an optional port, as the
+// host:port to query.
+func dnsServer(s string) (string, error) {
+	if ip := net.ParseIP(strings.Trim(s, "[]")); ip != nil {
+		return net.JoinHostPort(ip.String(), "53"), nil
+	}
+	host, port, err := net.SplitHostPort(s)
+	if n, perr := strconv.Atoi(port); err != nil || net.ParseIP(host) == nil || perr != nil || n < 1 || n > 65535 {
+		return "", fmt.Errorf(i18n.Text("invalid DNS_SERVER %q (an IP address, optionally with a port)", "DNS_SERVER 值无效 %q（应为 IP 地址，可带端口）"), s)
+	}
+	return net.JoinHostPort(host, port), nil
+}
+
+// isLoopback reports whether the API_LISTEN host only accepts connections
+// from this machine; an empty host listens on every interface.
+func isLoopback(host string) bool {
+	if host == "localhost" {
+		return true
+	}
+	ip := net.ParseIP(host)
+	return ip != nil && ip.IsLoopback()
+}
+
+// isCountryCode reports whether s looks like an ISO 3166-1 alpha-2 code.
+func isCountryCode(s string) bool {
+	return len(s) == 2 && s[0] >= 'A' && s[0] <= 'Z' && s[1] >= 'A' && s[1] <= 'Z'
+}
+
+func parseDuration(s string) (time.Duration, error) {
+	if n, err := strconv.Atoi(s); err == nil {
+		return time.Duration(n) * time.Second, nil
+	}
+	return time.ParseDuration(s)
 }
 
 var sizeRe = regexp.MustCompile(`(?i)^\s*([\d.]+)\s*([a-z]*)\s*$`)
@@ -235,6 +2001,54 @@ func ParseSize(s string) (int64, error) {
 	return int64(num * float64(mul)), nil
 }
 
+var rateRe = regexp.MustCompile(`(?i)^\s*([\d.]+)\s*([a-z/]*)\s*$`)
+
+// ParseRate parses a throughput such as 50Mbps, 500kbps or 1G in bits, or
+// 10MB/s or 10MB in bytes, and returns bits per second. An uppercase B is
+// bytes, a lowercase b or none bits; k, M and G are decimal multiples, Ki,
+// Mi and Gi binary ones.
+func ParseRate(s string) (int64, error) {
+	m := rateRe.FindStringSubmatch(s)
+	if m == nil {
+		return 0, fmt.Errorf("cannot parse rate %q", s)
+	}
+	num, err := strconv.ParseFloat(m[1], 64)
+	if err != nil {
+		return 0, err
+	}
+	unit := m[2]
+	if l := strings.ToLower(unit); strings.HasSuffix(l, "/s") || strings.HasSuffix(l, "ps") {
+		unit = unit[:len(unit)-2]
+	}
+	bytes := strings.HasSuffix(unit, "B")
+	if bytes || strings.HasSuffix(unit, "b") {
+		unit = unit[:len(unit)-1]
+	}
+	unit = strings.ToLower(unit)
+	mul := int64(1)
+	switch unit {
+	case "":
+	case "k":
+		mul = 1000
+	case "m":
+		mul = 1000 * 1000
+	case "g":
+		mul = 1000 * 1000 * 1000
+	case "ki":
+		mul = 1 << 10
+	case "mi":
+		mul = 1 << 20
+	case "gi":
+		mul = 1 << 30
+	default:
+		return 0, fmt.Errorf("unknown unit %q", m[2])
+	}
+	if bytes {
+		mul *= 8
+	}
+	return int64(num * float64(mul)), nil
+}
+
 func HumanBytes(b int64) string {
 	switch {
 	case b >= 1<<30:
@@ -255,6 +2069,30 @@ func envOr(key, fallback string) string {
 	return fallback
 }
 
+func envBool(key string, fallback bool) bool {
+	v := os.Getenv(key)
+	if v == "" {
+		return fallback
+	}
+	b, err := strconv.ParseBool(v)
+	if err != nil {
+		return fallback
+	}
+	return b
+}
+
+func envFloat(key string, fallback float64) float64 {
+	v := os.Getenv(key)
+	if v == "" {
+		return fallback
+	}
+	f, err := strconv.ParseFloat(v, 64)
+	if err != nil {
+		return fallback
+	}
+	return f
+}
+
 func envInt(key string, fallback int) int {
 	v := os.Getenv(key)
 	if v == "" {
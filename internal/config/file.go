@@ -0,0 +1,153 @@
+package config
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"math"
+	"os"
+	"strings"
+
+	"github.com/tsosunchia/iNetSpeed-CLI/internal/i18n"
+)
+
+// Stage groups accepted in the config file. A group applies to both its
+// single- and multi-thread stages; an exact stage name takes precedence.
+const (
+	GroupDownload = "download"
+	GroupUpload   = "upload"
+)
+
+// StageLimit caps the resources of one transfer stage. Zero fields inherit
+// the global MAX / TIMEOUT / THREADS values.
+type StageLimit struct {
+	Threads int    `json:"threads,omitempty"`
+	Max     string `json:"max,omitempty"`
+	Timeout int    `json:"timeout,omitempty"`
+}
+
+// fileConfig is the JSON layout of CONFIG_FILE / --config:
+//
+//	{"stages": {"download": {"threads": 8, "max": "4G"}, "upload-multi": {"timeout": 20}}}
+type fileConfig struct {
+	Stages map[string]StageLimit `json:"stages"`
+}
+
+func isTransferStage(name string) bool {
+	switch name {
+	case StageDownloadSingle, StageDownloadMulti, StageUploadSingle, StageUploadMulti:
+		return true
+	}
+	return false
+}
+
+func stageGroup(name string) string {
+	g, _, _ := strings.Cut(name, "-")
+	return g
+}
+
+func loadFile(path string) (map[string]StageLimit, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf(i18n.Text("cannot read config file: %w", "无法读取配置文件: %w"), err)
+	}
+	dec := json.NewDecoder(bytes.NewReader(data))
+	dec.DisallowUnknownFields()
+	var fc fileConfig
+	if err := dec.Decode(&fc); err != nil {
+		return nil, fmt.Errorf(i18n.Text("invalid config file %s: %w", "配置文件 %s 格式无效: %w"), path, err)
+	}
+
+	out := make(map[string]StageLimit, len(fc.Stages))
+	for name, lim := range fc.Stages {
+		name = strings.ToLower(strings.TrimSpace(name))
+		if name != GroupDownload && name != GroupUpload && !isTransferStage(name) {
+			return nil, fmt.Errorf(i18n.Text("config file: unknown stage %q (valid: download, upload, %s, %s, %s, %s)",
+				"配置文件: 未知阶段 %q（可选: download, upload, %s, %s, %s, %s）"),
+				name, StageDownloadSingle, StageDownloadMulti, StageUploadSingle, StageUploadMulti)
+		}
+		if err := lim.validate(name); err != nil {
+			return nil, err
+		}
+		out[name] = lim
+	}
+	return out, nil
+}
+
+func (l StageLimit) validate(stage string) error {
+	if l.Threads < 0 || l.Threads > 64 {
+		return fmt.Errorf(i18n.Text("config file: stage %s threads must be 1-64", "配置文件: 阶段 %s 的 threads 必须在 1-64 之间"), stage)
+	}
+	if l.Threads > 1 && strings.HasSuffix(stage, "-single") {
+		return fmt.Errorf(i18n.Text("config file: stage %s is single-threaded", "配置文件: 阶段 %s 为单线程"), stage)
+	}
+	if l.Timeout < 0 || l.Timeout > 120 {
+		return fmt.Errorf(i18n.Text("config file: stage %s timeout must be 1-120", "配置文件: 阶段 %s 的 timeout 必须在 1-120 之间"), stage)
+	}
+	if l.Max != "" {
+		n, err := ParseSize(l.Max)
+		if err != nil {
+			return fmt.Errorf(i18n.Text("config file: stage %s max %q: %w", "配置文件: 阶段 %s 的 max %q 无效: %w"), stage, l.Max, err)
+		}
+		if n <= 0 {
+			return errors.New(i18n.Text("MAX must be > 0", "MAX 必须大于 0"))
+		}
+	}
+	return nil
+}
+
+// Limit returns the effective limits for a transfer stage: the exact stage
+// entry, then its group entry, then the global values.
+func (c *Config) Limit(stage string) StageLimit {
+	out := StageLimit{Threads: c.Threads, Max: c.Max, Timeout: c.Timeout}
+	for _, key := range []string{stageGroup(stage), stage} {
+		l, ok := c.StageLimits[key]
+		if !ok {
+			continue
+		}
+		if l.Threads > 0 {
+			out.Threads = l.Threads
+		}
+		if l.Max != "" {
+			out.Max = l.Max
+		}
+		if l.Timeout > 0 {
+			out.Timeout = l.Timeout
+		}
+	}
+	if strings.HasSuffix(stage, "-single") {
+		out.Threads = 1
+	}
+	return out
+}
+
+// ForStage returns a copy of c with the stage's limits applied, so a round
+// never observes another round's settings.
+func (c *Config) ForStage(stage string) *Config {
+	l := c.Limit(stage)
+	cp := *c
+	cp.Threads = l.Threads
+	cp.Timeout = l.Timeout
+	if l.Max != c.Max {
+		cp.Max = l.Max
+		cp.MaxBytes, _ = ParseSize(l.Max)
+	}
+	return &cp
+}
+
+// MaxTimeout returns the longest per-thread timeout of any transfer stage, in
+// seconds, for sizing shared HTTP client deadlines. A THREAD_SCHEDULE
+// round's first workers run for the whole schedule.
+func (c *Config) MaxTimeout() int {
+	out := c.Timeout
+	for _, l := range c.StageLimits {
+		if l.Timeout > out {
+			out = l.Timeout
+		}
+	}
+	if len(c.ThreadSchedule) > 0 {
+		out = max(out, int(math.Ceil(c.ScheduleLength().Seconds())))
+	}
+	return out
+}
@@ -0,0 +1,98 @@
+package config
+
+import (
+	"fmt"
+	"net/http"
+	"net/url"
+	"strings"
+
+	"golang.org/x/net/http/httpguts"
+
+	"github.com/tsosunchia/iNetSpeed-CLI/internal/i18n"
+)
+
+// headerList collects -H and --header. The first flag replaces the list
+// from HTTP_HEADERS instead of adding to it, like every other flag.
+type headerList struct {
+	vals []string
+	set  bool
+}
+
+func (h *headerList) String() string { return strings.Join(h.vals, "\n") }
+
+func (h *headerList) Set(v string) error {
+	if !h.set {
+		h.vals, h.set = nil, true
+	}
+	h.vals = append(h.vals, v)
+	return nil
+}
+
+// splitHeaders splits HTTP_HEADERS, which holds one "Name: value" per line.
+func splitHeaders(s string) []string {
+	var out []string
+	for _, line := range strings.Split(s, "\n") {
+		if line = strings.TrimSpace(line); line != "" {
+			out = append(out, line)
+		}
+	}
+	return out
+}
+
+func parseHeaders(list []string) (http.Header, error) {
+	h := http.Header{}
+	for _, line := range list {
+		name, value, ok := strings.Cut(line, ":")
+		name, value = strings.TrimSpace(name), strings.TrimSpace(value)
+		if !ok || !httpguts.ValidHeaderFieldName(name) || !httpguts.ValidHeaderFieldValue(value) {
+			return nil, fmt.Errorf(i18n.Text("invalid header %q (want \"Name: value\")", "请求头无效 %q（格式应为 \"Name: value\"）"), line)
+		}
+		h.Add(name, value)
+	}
+	return h, nil
+}
+
+// DefaultHeader returns the headers every test request carries, matching
+// what networkQuality sends.
+func DefaultHeader() http.Header {
+	return http.Header{
+		"User-Agent":      {UserAgent},
+		"Accept":          {"*/*"},
+		"Accept-Language": {"zh-CN,zh-Hans;q=0.9"},
+		"Accept-Encoding": {"identity"},
+	}
+}
+
+// RequestHeader returns the headers for test requests: the defaults with
+// USER_AGENT and then the --header values applied on top.
+func (c *Config) RequestHeader() http.Header {
+	h := DefaultHeader()
+	if c.UserAgent != "" {
+		h.Set("User-Agent", c.UserAgent)
+	}
+	for k, v := range c.Headers {
+		h[k] = v
+	}
+	return h
+}
+
+// SetHeader applies h to req, replacing existing values. A Host entry sets
+// the request's Host, which Go does not take from the header map.
+func SetHeader(req *http.Request, h http.Header) {
+	for k, v := range h {
+		if k == "Host" {
+			req.Host = v[0]
+			continue
+		}
+		req.Header[k] = v
+	}
+}
+
+// Redact hides the password of a URL with credentials for display.
+func Redact(rawURL string) string {
+	u, err := url.Parse(rawURL)
+	if err != nil {
+		return rawURL
+	}
+	return u.Redacted()
+}
@@ -0,0 +1,54 @@
+package config
+
+// Preset names accepted by PRESET / --preset.
+const (
+	PresetQuick    = "quick"
+	PresetStandard = "standard"
+	PresetThorough = "thorough"
+)
+
+// Preset is a named bundle of settings. It replaces only the built-in
+// defaults: a setting given by its own flag or environment variable keeps
+// that value. Zero fields leave the default alone.
+type Preset struct {
+	Timeout      int
+	Threads      int
+	Max          string
+	LatencyCount int
+	Runs         int
+	NoPrompt     bool
+}
+
+// Presets maps each preset name to its settings. quick finishes in well
+// under a minute without asking anything; thorough sizes its transfers
+// from a probe, runs longer rounds on more threads and repeats the whole
+// test to show how stable the result is.
+var Presets = map[string]Preset{
+	PresetQuick:    {Timeout: 8, Threads: 4, LatencyCount: 10, NoPrompt: true},
+	PresetStandard: {Timeout: DefaultTimeout, Threads: DefaultThreads, Max: DefaultMax, LatencyCount: DefaultLatencyCount},
+	PresetThorough: {Timeout: 30, Threads: 8, Max: MaxAuto, LatencyCount: 50, Runs: 3},
+}
+
+// apply sets c's fields from p where given reports neither the flag nor the
+// environment variable of the setting as set. Runs stays alone for the
+// modes that cannot repeat.
+func (p Preset) apply(c *Config, given func(flag, env string) bool) {
+	if p.Timeout > 0 && !given("timeout", "TIMEOUT") {
+		c.Timeout = p.Timeout
+	}
+	if p.Threads > 0 && !given("threads", "THREADS") {
+		c.Threads = p.Threads
+	}
+	if p.Max != "" && !given("max", "MAX") {
+		c.Max = p.Max
+	}
+	if p.LatencyCount > 0 && !given("latency-count", "LATENCY_COUNT") {
+		c.LatencyCount = p.LatencyCount
+	}
+	if p.Runs > 0 && !given("runs", "RUNS") && !c.Widget && !c.CompareIPVersions && !c.Demo {
+		c.Runs = p.Runs
+	}
+	if p.NoPrompt && !given("no-prompt", "NO_PROMPT") {
+		c.NoPrompt = true
+	}
+}
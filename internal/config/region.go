@@ -0,0 +1,49 @@
+package config
+
+import (
+	"fmt"
+	"net/netip"
+	"slices"
+	"strings"
+
+	"github.com/tsosunchia/iNetSpeed-CLI/internal/i18n"
+)
+
+// Region is where REGION / --region has the CDN host resolved from: the
+// DoH queries carry Subnet as their EDNS Client Subnet, so Apple's DNS hands
+// out the PoPs serving a client there rather than the ones nearest this
+// one, and the candidates located in Country are ranked first.
+type Region struct {
+	Name    string
+	Subnet  string // a client network in the region, as CIDR
+	Country string // ISO 3166-1 alpha-2; empty for a subnet given as is
+}
+
+// Regions are the named regions, each a broadband network of a large
+// access ISP there.
+var Regions = map[string]Region{
+	"jp":         {Name: "jp", Subnet: "126.0.0.0/24", Country: "JP"},
+	"us-west":    {Name: "us-west", Subnet: "24.4.0.0/24", Country: "US"},
+	"eu-central": {Name: "eu-central", Subnet: "80.128.0.0/24", Country: "DE"},
+	"cn":         {Name: "cn", Subnet: "202.96.209.0/24", Country: "CN"},
+}
+
+// ParseRegion reads a region: one of Regions, or the CIDR of any client
+// network to resolve as, such as 203.0.113.0/24.
+func ParseRegion(s string) (Region, error) {
+	s = strings.ToLower(strings.TrimSpace(s))
+	if r, ok := Regions[s]; ok {
+		return r, nil
+	}
+	if p, err := netip.ParsePrefix(s); err == nil {
+		p = p.Masked()
+		return Region{Name: p.String(), Subnet: p.String()}, nil
+	}
+	names := make([]string, 0, len(Regions))
+	for name := range Regions {
+		names = append(names, name)
+	}
+	slices.Sort(names)
+	return Region{}, fmt.Errorf(i18n.Text("invalid REGION %q (valid: %s, or a subnet such as 203.0.113.0/24)", "REGION 值无效 %q（可选: %s，或子网如 203.0.113.0/24）"),
+		s, strings.Join(names, ", "))
+}
@@ -0,0 +1,72 @@
+package config
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/tsosunchia/iNetSpeed-CLI/internal/i18n"
+)
+
+// ThreadStep is one step of THREAD_SCHEDULE: from At into the round on,
+// Threads workers run.
+type ThreadStep struct {
+	Threads int
+	At      time.Duration
+}
+
+// Limits of a thread schedule, those of THREADS and TIMEOUT.
+const (
+	maxScheduleThreads = 64
+	maxScheduleLength  = 120 * time.Second
+)
+
+// ParseThreadSchedule reads THREAD_SCHEDULE, comma-separated THREADS@OFFSET
+// steps such as 1@0s,2@3s,4@6s,8@9s, where a bare offset is seconds. The
+// first step starts the round at 0; each later one starts later with more
+// workers, the new ones joining those already running.
+func ParseThreadSchedule(s string) ([]ThreadStep, error) {
+	var steps []ThreadStep
+	for _, item := range strings.Split(s, ",") {
+		item = strings.TrimSpace(item)
+		if item == "" {
+			continue
+		}
+		n, at, ok := strings.Cut(item, "@")
+		threads, err := strconv.Atoi(strings.TrimSpace(n))
+		if !ok || err != nil || threads <= 0 || threads > maxScheduleThreads {
+			return nil, fmt.Errorf(i18n.Text("invalid THREAD_SCHEDULE step %q, want THREADS@OFFSET with 1 to %d threads", "THREAD_SCHEDULE 步骤无效 %q，应为 线程数@偏移，线程数为 1 到 %d"),
+				item, maxScheduleThreads)
+		}
+		d, err := parseDuration(strings.TrimSpace(at))
+		if err != nil || d < 0 {
+			return nil, fmt.Errorf(i18n.Text("invalid THREAD_SCHEDULE step %q, want THREADS@OFFSET with 1 to %d threads", "THREAD_SCHEDULE 步骤无效 %q，应为 线程数@偏移，线程数为 1 到 %d"),
+				item, maxScheduleThreads)
+		}
+		if k := len(steps); k == 0 && d != 0 || k > 0 && (d <= steps[k-1].At || threads <= steps[k-1].Threads) {
+			return nil, fmt.Errorf(i18n.Text("THREAD_SCHEDULE must start at 0s and rise in both threads and offset: %q", "THREAD_SCHEDULE 必须从 0s 开始，且线程数与偏移均递增: %q"), s)
+		}
+		steps = append(steps, ThreadStep{Threads: threads, At: d})
+	}
+	if len(steps) > 0 && ScheduleLength(steps, 0) > maxScheduleLength {
+		return nil, fmt.Errorf(i18n.Text("THREAD_SCHEDULE must not run longer than %v", "THREAD_SCHEDULE 的总时长不能超过 %v"), maxScheduleLength)
+	}
+	return steps, nil
+}
+
+// ScheduleLength is how long a round on steps runs: the last step lasts as
+// long as the one before it, or last when it is the only one.
+func ScheduleLength(steps []ThreadStep, last time.Duration) time.Duration {
+	k := len(steps) - 1
+	if k == 0 {
+		return last
+	}
+	return steps[k].At + steps[k].At - steps[k-1].At
+}
+
+// ScheduleLength is how long the THREAD_SCHEDULE round runs; a schedule of
+// one step runs for TIMEOUT.
+func (c *Config) ScheduleLength() time.Duration {
+	return ScheduleLength(c.ThreadSchedule, time.Duration(c.Timeout)*time.Second)
+}
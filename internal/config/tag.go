@@ -0,0 +1,87 @@
+package config
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/tsosunchia/iNetSpeed-CLI/internal/i18n"
+)
+
+// maxTags bounds the --tag pairs of a run, which sinks turn into series
+// labels.
+const maxTags = 32
+
+// tagList collects --tag, checking each pair as it is given. The first
+// flag replaces the list from TAGS instead of adding to it, like every
+// other flag.
+type tagList struct {
+	vals []string
+	set  bool
+}
+
+func (l *tagList) String() string { return strings.Join(l.vals, ",") }
+
+func (l *tagList) Set(v string) error {
+	if _, _, err := parseTag(v); err != nil {
+		return err
+	}
+	if !l.set {
+		l.vals, l.set = nil, true
+	}
+	l.vals = append(l.vals, v)
+	return nil
+}
+
+// splitTags splits TAGS, which holds comma-separated key=value pairs.
+func splitTags(s string) []string {
+	var out []string
+	for _, item := range strings.Split(s, ",") {
+		if item = strings.TrimSpace(item); item != "" {
+			out = append(out, item)
+		}
+	}
+	return out
+}
+
+// parseTags reads key=value pairs. Keys are letters, digits and _, not
+// starting with a digit, as every sink takes them as label names; a
+// repeated key keeps its last value.
+func parseTags(list []string) (map[string]string, error) {
+	if len(list) == 0 {
+		return nil, nil
+	}
+	tags := map[string]string{}
+	for _, item := range list {
+		k, v, err := parseTag(item)
+		if err != nil {
+			return nil, err
+		}
+		tags[k] = v
+	}
+	if len(tags) > maxTags {
+		return nil, fmt.Errorf(i18n.Text("too many tags: %d (at most %d)", "标签过多: %d（最多 %d 个）"), len(tags), maxTags)
+	}
+	return tags, nil
+}
+
+// parseTag reads one key=value pair.
+func parseTag(item string) (string, string, error) {
+	k, v, ok := strings.Cut(item, "=")
+	k, v = strings.TrimSpace(k), strings.TrimSpace(v)
+	if !ok || !isTagKey(k) || v == "" || strings.ContainsAny(v, "\r\n") {
+		return "", "", fmt.Errorf(i18n.Text("invalid tag %q (want KEY=VALUE, the key of letters, digits and _)", "标签无效 %q（格式应为 KEY=VALUE，键由字母、数字与 _ 组成）"), item)
+	}
+	return k, v, nil
+}
+
+func isTagKey(k string) bool {
+	if k == "" || len(k) > 64 {
+		return false
+	}
+	for i, c := range k {
+		if !(c >= 'a' && c <= 'z' || c >= 'A' && c <= 'Z' || c == '_' || i > 0 && c >= '0' && c <= '9') {
+			return false
+		}
+	}
+	return true
+}
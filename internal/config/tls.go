@@ -0,0 +1,42 @@
+package config
+
+import (
+	"crypto/tls"
+	"crypto/x509"
+	"errors"
+	"fmt"
+	"os"
+
+	"github.com/tsosunchia/iNetSpeed-CLI/internal/i18n"
+)
+
+// loadTLS builds the TLS settings of the test clients from CACERT,
+// TLS_CERT/TLS_KEY and TLS_INSECURE. It returns nil when none is set, so
+// the clients keep the system defaults.
+func loadTLS(caFile, certFile, keyFile string, insecure bool) (*tls.Config, error) {
+	if caFile == "" && certFile == "" && keyFile == "" && !insecure {
+		return nil, nil
+	}
+	cfg := &tls.Config{InsecureSkipVerify: insecure}
+	if caFile != "" {
+		pem, err := os.ReadFile(caFile)
+		if err != nil {
+			return nil, fmt.Errorf(i18n.Text("invalid CACERT: %w", "CACERT 无效: %w"), err)
+		}
+		cfg.RootCAs = x509.NewCertPool()
+		if !cfg.RootCAs.AppendCertsFromPEM(pem) {
+			return nil, fmt.Errorf(i18n.Text("invalid CACERT: no PEM certificates in %s", "CACERT 无效: %s 中没有 PEM 证书"), caFile)
+		}
+	}
+	if (certFile == "") != (keyFile == "") {
+		return nil, errors.New(i18n.Text("TLS_CERT and TLS_KEY must be set together", "TLS_CERT 与 TLS_KEY 必须同时设置"))
+	}
+	if certFile != "" {
+		cert, err := tls.LoadX509KeyPair(certFile, keyFile)
+		if err != nil {
+			return nil, fmt.Errorf(i18n.Text("invalid client certificate: %w", "客户端证书无效: %w"), err)
+		}
+		cfg.Certificates = []tls.Certificate{cert}
+	}
+	return cfg, nil
+}
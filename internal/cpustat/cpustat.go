@@ -0,0 +1,39 @@
+// Package cpustat measures the CPU time this process uses, so a result can
+// say when the client, not the network, was the bottleneck: on a small
+// router the transfer loops may saturate the CPU before the link.
+package cpustat
+
+import (
+	"runtime"
+	"time"
+)
+
+// Meter measures the process's CPU use from Start on.
+type Meter struct {
+	cpu   time.Duration
+	start time.Time
+	ok    bool
+}
+
+// Start begins a measurement.
+func Start() Meter {
+	cpu, ok := usage()
+	return Meter{cpu: cpu, start: time.Now(), ok: ok}
+}
+
+// Percent is the CPU time used since Start as a share of what the cores
+// the Go runtime may use (GOMAXPROCS) could have provided, in percent. ok is
+// false where the platform does not report CPU time.
+func (m Meter) Percent() (pct float64, ok bool) {
+	cpu, ok := usage()
+	wall := time.Since(m.start)
+	if !ok || !m.ok || wall <= 0 {
+		return 0, false
+	}
+	return share(cpu-m.cpu, wall, runtime.GOMAXPROCS(0)), true
+}
+
+func share(cpu, wall time.Duration, procs int) float64 {
+	pct := float64(cpu) / (float64(wall) * float64(max(procs, 1))) * 100
+	return min(max(pct, 0), 100)
+}
@@ -0,0 +1,7 @@
+//go:build !unix && !windows
+
+package cpustat
+
+import "time"
+
+func usage() (time.Duration, bool) { return 0, false }
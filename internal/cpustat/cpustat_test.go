@@ -0,0 +1,42 @@
+package cpustat
+
+import (
+	"runtime"
+	"testing"
+	"time"
+)
+
+func TestShare(t *testing.T) {
+	for _, tc := range []struct {
+		cpu, wall time.Duration
+		procs     int
+		want      float64
+	}{
+		{time.Second, time.Second, 1, 100},
+		{time.Second, time.Second, 4, 25},
+		{3 * time.Second, time.Second, 2, 100}, // clamped
+		{0, time.Second, 0, 0},
+	} {
+		if got := share(tc.cpu, tc.wall, tc.procs); got != tc.want {
+			t.Errorf("share(%v, %v, %d) = %v, want %v", tc.cpu, tc.wall, tc.procs, got, tc.want)
+		}
+	}
+}
+
+func TestMeter(t *testing.T) {
+	m := Start()
+	deadline := time.Now().Add(50 * time.Millisecond)
+	for time.Now().Before(deadline) {
+	}
+	pct, ok := m.Percent()
+	if !ok {
+		if runtime.GOOS == "linux" || runtime.GOOS == "darwin" {
+			t.Fatal("CPU time unavailable")
+		}
+		t.Skip("CPU time unavailable on " + runtime.GOOS)
+	}
+	// One busy goroutine: about one core's worth.
+	if pct <= 0 || pct > 100 {
+		t.Errorf("Percent = %.1f", pct)
+	}
+}
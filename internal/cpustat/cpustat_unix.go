@@ -0,0 +1,17 @@
+//go:build unix
+
+package cpustat
+
+import (
+	"syscall"
+	"time"
+)
+
+// usage is the user plus system CPU time of the process so far.
+func usage() (time.Duration, bool) {
+	var ru syscall.Rusage
+	if err := syscall.Getrusage(syscall.RUSAGE_SELF, &ru); err != nil {
+		return 0, false
+	}
+	return time.Duration(ru.Utime.Nano() + ru.Stime.Nano()), true
+}
@@ -0,0 +1,36 @@
+// Package demo holds the recorded run that --demo replays: the event log of
+// one --simulate run in each UI language, gzip-compressed. They were
+// recorded with
+//
+//	speedtest --simulate --simulate-opts bandwidth=480Mbps,latency=12ms --timeout 5 \
+//	    --lang LANG --event-log LANG.ndjson
+//
+// and are recorded again when the events a run emits change.
+package demo
+
+import (
+	"bytes"
+	"compress/gzip"
+	"embed"
+	"io"
+)
+
+//go:embed *.ndjson.gz
+var recordings embed.FS
+
+// Recording returns the event log recorded in lang, or the English one when
+// there is none for it.
+func Recording(lang string) ([]byte, error) {
+	b, err := recordings.ReadFile(lang + ".ndjson.gz")
+	if err != nil {
+		if b, err = recordings.ReadFile("en.ndjson.gz"); err != nil {
+			return nil, err
+		}
+	}
+	zr, err := gzip.NewReader(bytes.NewReader(b))
+	if err != nil {
+		return nil, err
+	}
+	defer zr.Close()
+	return io.ReadAll(zr)
+}
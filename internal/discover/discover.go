@@ -0,0 +1,110 @@
+// Package discover reads the configuration Apple's networkQuality fetches
+// before a test. The mensura origin answers /api/v1/gm/config with the test
+// URLs for the client's region and the edge assigned to it:
+//
+//	{"version": 1, "test_endpoint": "hkhkg3-edge-bx-008.aaplimg.com",
+//	 "urls": {"small_https_download_url": "...", "large_https_download_url": "...",
+//	          "https_upload_url": "...", ...}}
+package discover
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"strings"
+	"time"
+
+	"github.com/tsosunchia/iNetSpeed-CLI/internal/config"
+)
+
+// Path is where the configuration is served, relative to the origin.
+const Path = "/api/v1/gm/config"
+
+// timeout bounds the fetch.
+const timeout = 10 * time.Second
+
+// Config is the part of the configuration the speedtest uses.
+type Config struct {
+	TestEndpoint string // edge host assigned to the client; may be empty
+	DLURL        string // large download
+	ULURL        string // upload (slurp)
+	LatencyURL   string // small download
+}
+
+type document struct {
+	TestEndpoint string            `json:"test_endpoint"`
+	URLs         map[string]string `json:"urls"`
+}
+
+// ConfigURL is the configuration URL at the origin of dlURL.
+func ConfigURL(dlURL string) (string, error) {
+	u, err := url.Parse(dlURL)
+	if err != nil {
+		return "", err
+	}
+	if u.Scheme == "" || u.Host == "" {
+		return "", fmt.Errorf("%q has no origin", dlURL)
+	}
+	return u.Scheme + "://" + u.Host + Path, nil
+}
+
+// Fetch requests the configuration at configURL through client with the
+// headers h and validates it.
+func Fetch(ctx context.Context, client *http.Client, configURL string, h http.Header) (Config, error) {
+	ctx, cancel := context.WithTimeout(ctx, timeout)
+	defer cancel()
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, configURL, nil)
+	if err != nil {
+		return Config{}, err
+	}
+	config.SetHeader(req, h)
+	req.Header.Set("Accept", "application/json")
+	resp, err := client.Do(req)
+	if err != nil {
+		return Config{}, err
+	}
+	defer resp.Body.Close()
+	body, err := io.ReadAll(io.LimitReader(resp.Body, 1<<20))
+	if err != nil {
+		return Config{}, err
+	}
+	if resp.StatusCode != http.StatusOK {
+		return Config{}, fmt.Errorf("HTTP %d", resp.StatusCode)
+	}
+	return parse(body)
+}
+
+// parse prefers the https URLs and falls back to the cleartext ones.
+func parse(body []byte) (Config, error) {
+	var doc document
+	if err := json.Unmarshal(body, &doc); err != nil {
+		return Config{}, fmt.Errorf("invalid config: %w", err)
+	}
+	pick := func(keys ...string) string {
+		for _, k := range keys {
+			if v := doc.URLs[k]; v != "" {
+				return v
+			}
+		}
+		return ""
+	}
+	c := Config{
+		TestEndpoint: doc.TestEndpoint,
+		DLURL:        pick("large_https_download_url", "large_download_url"),
+		ULURL:        pick("https_upload_url", "upload_url"),
+		LatencyURL:   pick("small_https_download_url", "small_download_url"),
+	}
+	if c.DLURL == "" || c.ULURL == "" || c.LatencyURL == "" {
+		return Config{}, errors.New("config lacks the download, upload or small URL")
+	}
+	for _, v := range []string{c.DLURL, c.ULURL, c.LatencyURL} {
+		if !strings.HasPrefix(v, "http://") && !strings.HasPrefix(v, "https://") {
+			return Config{}, fmt.Errorf("config URL %q must start with http(s)://", v)
+		}
+	}
+	return c, nil
+}
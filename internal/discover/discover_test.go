@@ -0,0 +1,62 @@
+package discover
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+func TestConfigURL(t *testing.T) {
+	got, err := ConfigURL("https://mensura.cdn-apple.com/api/v1/gm/large?x=1")
+	if err != nil || got != "https://mensura.cdn-apple.com/api/v1/gm/config" {
+		t.Errorf("got %q, %v", got, err)
+	}
+	if _, err := ConfigURL("/api/v1/gm/large"); err == nil {
+		t.Error("URL without origin accepted")
+	}
+}
+
+func TestParse(t *testing.T) {
+	c, err := parse([]byte(`{"version":1,"test_endpoint":"hkhkg3-edge-bx-008.aaplimg.com","urls":{
+		"small_https_download_url":"https://a/small","large_https_download_url":"https://a/large","https_upload_url":"https://a/slurp",
+		"small_download_url":"http://a/small","large_download_url":"http://a/large","upload_url":"http://a/slurp"}}`))
+	want := Config{TestEndpoint: "hkhkg3-edge-bx-008.aaplimg.com", DLURL: "https://a/large", ULURL: "https://a/slurp", LatencyURL: "https://a/small"}
+	if err != nil || c != want {
+		t.Errorf("got %+v, %v", c, err)
+	}
+	c, err = parse([]byte(`{"urls":{"small_download_url":"http://a/small","large_download_url":"http://a/large","upload_url":"http://a/slurp"}}`))
+	if err != nil || c.DLURL != "http://a/large" || c.TestEndpoint != "" {
+		t.Errorf("cleartext fallback: %+v, %v", c, err)
+	}
+	for _, body := range []string{
+		`not json`,
+		`{"urls":{"large_https_download_url":"https://a/large"}}`,
+		`{"urls":{"small_https_download_url":"a/small","large_https_download_url":"https://a/large","https_upload_url":"https://a/slurp"}}`,
+	} {
+		if _, err := parse([]byte(body)); err == nil {
+			t.Errorf("%s: accepted", body)
+		}
+	}
+}
+
+func TestFetch(t *testing.T) {
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path != Path || r.Header.Get("User-Agent") != "nq" {
+			http.NotFound(w, r)
+			return
+		}
+		w.Write([]byte(`{"test_endpoint":"edge","urls":{"small_https_download_url":"https://a/small",
+			"large_https_download_url":"https://a/large","https_upload_url":"https://a/slurp"}}`))
+	}))
+	defer ts.Close()
+	h := http.Header{"User-Agent": {"nq"}}
+	c, err := Fetch(context.Background(), ts.Client(), ts.URL+Path, h)
+	if err != nil || c.TestEndpoint != "edge" {
+		t.Errorf("got %+v, %v", c, err)
+	}
+	if _, err := Fetch(context.Background(), ts.Client(), ts.URL+"/missing", h); err == nil || !strings.Contains(err.Error(), "404") {
+		t.Errorf("missing config: %v", err)
+	}
+}
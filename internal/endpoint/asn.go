@@ -0,0 +1,57 @@
+package endpoint
+
+import (
+	"regexp"
+	"sort"
+)
+
+// asRe finds the AS in a candidate's description, which ends in ip-api's
+// AS field, such as "Tokyo, Japan (AS714 Apple Inc.)".
+var asRe = regexp.MustCompile(`\((AS(\d+)[^()]*)\)$`)
+
+// ASN is the AS number of an endpoint description, such as "AS714"; empty
+// when its lookup failed.
+func ASN(desc string) string {
+	m := asRe.FindStringSubmatch(desc)
+	if m == nil {
+		return ""
+	}
+	return "AS" + m[2]
+}
+
+// ASName is the AS of an endpoint description with its name, such as
+// "AS714 Apple Inc.".
+func ASName(desc string) string {
+	if m := asRe.FindStringSubmatch(desc); m != nil {
+		return m[1]
+	}
+	return ""
+}
+
+// groupByASN orders eps so the candidates of one AS are listed together,
+// each group where its first member stood, and returns how many ASes they
+// span. Candidates of no known AS form a group of their own.
+func groupByASN(eps []Endpoint) int {
+	first := map[string]int{}
+	for i, ep := range eps {
+		if _, ok := first[ASN(ep.Desc)]; !ok {
+			first[ASN(ep.Desc)] = i
+		}
+	}
+	sort.SliceStable(eps, func(i, j int) bool {
+		return first[ASN(eps[i].Desc)] < first[ASN(eps[j].Desc)]
+	})
+	return len(first)
+}
+
+// perASN is the first candidate of each known AS in eps, grouped as
+// groupByASN leaves them.
+func perASN(eps []Endpoint) []Endpoint {
+	var out []Endpoint
+	for i, ep := range eps {
+		if asn := ASN(ep.Desc); asn != "" && (i == 0 || asn != ASN(eps[i-1].Desc)) {
+			out = append(out, ep)
+		}
+	}
+	return out
+}
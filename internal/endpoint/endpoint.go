@@ -11,6 +11,7 @@ import (
 	"net/url"
 	"os"
 	"regexp"
+	"slices"
 	"sort"
 	"strconv"
 	"strings"
@@ -29,20 +30,61 @@ var (
 	cfDoHAAAAURLTemplate  = "https://cloudflare-dns.com/dns-query?name=%s&type=AAAA"
 	aliDoHURLTemplate     = "https://dns.alidns.com/resolve?name=%s&type=A&short=1"
 	aliDoHAAAAURLTemplate = "https://dns.alidns.com/resolve?name=%s&type=AAAA&short=1"
+	// Cloudflare leaves EDNS Client Subnet out on purpose; Google and Ali
+	// pass it on, which --region needs.
+	googleECSURLTemplate = "https://dns.google/resolve?name=%s&type=%s&edns_client_subnet=%s"
+	aliECSURLTemplate    = "https://dns.alidns.com/resolve?name=%s&type=%s&short=1&edns_client_subnet=%s"
 
 	// dohTimeout is the per-provider timeout for DoH queries.
 	dohTimeout = 1 * time.Second
 
 	dohHTTPClient     = http.DefaultClient
 	resolveDoHFn      = resolveDoHDual
+	resolveSubnetFn   = resolveSubnet
 	resolveSystemFn   = resolveSystem
+	resolveAllFn      = resolveAll
 	fetchIPDescFn     = fetchIPDesc
+	clientCountryFn   = clientCountry
 	openPromptInputFn = openPromptInput
 )
 
+// LookupBudget bounds the geo lookups of one step together, so a blocked
+// ip-api delays the test by at most this much.
+var LookupBudget = 3 * time.Second
+
+// Lookups turns off the auxiliary services Choose relies on, for networks
+// that block them.
+type Lookups struct {
+	NoDoH bool // resolve with the system resolver instead of DoH
+	NoGeo bool // list the candidates without their ip-api location
+	// Family 4 or 6 keeps only the addresses of that IP version; 0 keeps
+	// them all.
+	Family int
+	// Country ranks the candidates located in this ISO 3166-1 alpha-2
+	// country first; empty looks up the client's own.
+	Country string
+	// Resolver, when set, replaces DoH and the system resolver alike: the
+	// candidates are what DNS server hands out.
+	Resolver  *net.Resolver
+	DNSServer string
+	// Subnet, a CIDR, resolves host over DoH as a client in that network
+	// would, through EDNS Client Subnet; Region names it in messages.
+	Subnet string
+	Region string
+}
+
 type Endpoint struct {
 	IP   string
 	Desc string
+	// Country is the ISO code of the location ip-api gave; empty when
+	// unknown.
+	Country string
+	// ConnectMs is the median pre-screen connect time; 0 when it was not
+	// measured, -1 when every attempt failed.
+	ConnectMs float64
+	// PerASN holds the first candidate of each AS when the candidates span
+	// more than one, for --per-asn.
+	PerASN []Endpoint
 }
 
 type IPInfo struct {
@@ -54,6 +96,8 @@ type IPInfo struct {
 	City       string `json:"city"`
 	RegionName string `json:"regionName"`
 	Country    string `json:"country"`
+	// CountryCode is the ISO 3166-1 alpha-2 code, never localized.
+	CountryCode string `json:"countryCode"`
 }
 
 // dohResult holds the outcome of a single DoH provider query.
@@ -63,7 +107,7 @@ type dohResult struct {
 	err      error
 }
 
-func Choose(ctx context.Context, host string, bus *render.Bus, isTTY bool) Endpoint {
+func Choose(ctx context.Context, host string, bus *render.Bus, isTTY bool, ps Prescreen, lk Lookups) Endpoint {
 	bus.Header(i18n.Text("Endpoint Selection", "节点选择"))
 	if host == "" {
 		bus.Warn(i18n.Text("Could not parse host from DL_URL. Skip endpoint selection.", "无法从 DL_URL 解析主机，跳过节点选择。"))
@@ -71,12 +115,39 @@ func Choose(ctx context.Context, host string, bus *render.Bus, isTTY bool) Endpo
 	}
 	bus.Info(i18n.Text("Host: ", "主机: ") + host)
 
-	ips, cfTimedOut, aliTimedOut := resolveDoHFn(ctx, host)
+	var ips []string
+	var cfTimedOut, aliTimedOut bool
+	if lk.Subnet != "" {
+		bus.Info(fmt.Sprintf(i18n.Text("Resolving as a client in %s (EDNS client subnet %s).", "以 %s 客户端的身份解析（EDNS 客户端子网 %s）。"), lk.Region, lk.Subnet))
+		if ips = resolveSubnetFn(ctx, host, lk.Subnet); len(ips) == 0 {
+			bus.Warn(fmt.Sprintf(i18n.Text("DoH returned no endpoint for %s.", "DoH 未返回 %s 的节点。"), lk.Region))
+			return Endpoint{}
+		}
+	} else if lk.NoDoH || lk.Resolver != nil {
+		if lk.Resolver != nil {
+			bus.Info(fmt.Sprintf(i18n.Text("Resolving with DNS server %s.", "使用 DNS 服务器 %s 解析。"), lk.DNSServer))
+		} else {
+			bus.Info(i18n.Text("DoH disabled; resolving with system DNS.", "已禁用 DoH，使用系统 DNS 解析。"))
+		}
+		if ips = resolveAllFn(ctx, lk.Resolver, host); len(ips) == 0 {
+			bus.Warn(i18n.Text("Could not resolve endpoint IP, continue with default DNS.", "无法解析节点 IP，继续使用默认 DNS。"))
+			return Endpoint{}
+		}
+	} else {
+		ips, cfTimedOut, aliTimedOut = resolveDoHFn(ctx, host)
+	}
+	if lk.Family != 0 && len(ips) > 0 {
+		if ips = ofFamily(ips, lk.Family); len(ips) == 0 {
+			bus.Warn(fmt.Sprintf(i18n.Text("%s has no IPv%d address.", "%s 没有 IPv%d 地址。"), host, lk.Family))
+			return Endpoint{}
+		}
+	}
 	if len(ips) == 0 {
 		if cfTimedOut && aliTimedOut {
 			bus.Warn(i18n.Text("Dual DoH (CF + Ali) both timed out. Fallback to system DNS.", "双 DoH（CF + Ali）均超时，回退系统 DNS。"))
-			fb := resolveSystemFn(host)
-			if fb != "" {
+			// The system fallback is IPv4 only.
+			fb := resolveSystemFn(lk.Resolver, host)
+			if fb != "" && lk.Family != 6 {
 				ep := Endpoint{IP: fb, Desc: i18n.Text("system DNS fallback", "系统 DNS 回退")}
 				bus.Info(i18n.Text("Selected endpoint: ", "已选择节点: ") + ep.IP + " (" + ep.Desc + ")")
 				return ep
@@ -89,21 +160,92 @@ func Choose(ctx context.Context, host string, bus *render.Bus, isTTY bool) Endpo
 		return Endpoint{}
 	}
 
-	endpoints := make([]Endpoint, 0, len(ips))
-	for _, ip := range ips {
-		desc := fetchIPDescFn(ctx, ip)
-		endpoints = append(endpoints, Endpoint{IP: ip, Desc: desc})
+	// The connect checks run while the geo lookups are in flight.
+	var connectMs []float64
+	screened := make(chan struct{})
+	if ps.Port != "" && len(ips) > 1 {
+		go func() {
+			defer close(screened)
+			connectMs = prescreenFn(ctx, host, ips, ps)
+		}()
+	} else {
+		close(screened)
+	}
+
+	endpoints := make([]Endpoint, len(ips))
+	for i, ip := range ips {
+		endpoints[i].IP = ip
+	}
+	country := strings.ToUpper(lk.Country)
+	if !lk.NoGeo {
+		gctx, cancel := context.WithTimeout(ctx, LookupBudget)
+		var wg sync.WaitGroup
+		for i := range endpoints {
+			wg.Add(1)
+			go func() {
+				defer wg.Done()
+				endpoints[i].Desc, endpoints[i].Country = fetchIPDescFn(gctx, endpoints[i].IP)
+			}()
+		}
+		if country == "" && len(endpoints) > 1 {
+			wg.Add(1)
+			go func() {
+				defer wg.Done()
+				country = clientCountryFn(gctx)
+			}()
+		}
+		wg.Wait()
+		cancel()
+	}
+	<-screened
+	for i := range connectMs {
+		endpoints[i].ConnectMs = connectMs[i]
 	}
+	rank(endpoints, country)
+	groups := groupByASN(endpoints)
 
-	bus.Info(i18n.Text("Available endpoints:", "可用节点:"))
-	for i, ep := range endpoints {
-		bus.Info(fmt.Sprintf("  %d) %s  %s", i+1, ep.IP, ep.Desc))
+	if connectMs != nil {
+		what := "TCP"
+		if ps.TLS {
+			what = "TCP+TLS"
+		}
+		bus.Info(fmt.Sprintf(i18n.Text("Available endpoints (median %s connect time of %d attempts):", "可用节点（%s 连接耗时，%d 次取中位数）:"),
+			what, prescreenAttempts))
+	} else {
+		bus.Info(i18n.Text("Available endpoints:", "可用节点:"))
+	}
+	if len(endpoints) > 1 {
+		switch located := slices.ContainsFunc(endpoints, func(ep Endpoint) bool { return ep.Country == country }); {
+		case country != "" && located && connectMs != nil:
+			bus.Info(fmt.Sprintf(i18n.Text("  Ranked: endpoints in %s first, then by connect time.", "  排序: %s 境内节点优先，其次按连接耗时。"), country))
+		case country != "" && located:
+			bus.Info(fmt.Sprintf(i18n.Text("  Ranked: endpoints in %s first.", "  排序: %s 境内节点优先。"), country))
+		case connectMs != nil:
+			bus.Info(i18n.Text("  Ranked by connect time.", "  按连接耗时排序。"))
+		}
+	}
+	width := 0
+	for _, ip := range ips {
+		width = max(width, len(ip))
+	}
+	for i := range endpoints {
+		ep := &endpoints[i]
+		if groups > 1 && (i == 0 || ASN(ep.Desc) != ASN(endpoints[i-1].Desc)) {
+			name := ASName(ep.Desc)
+			if name == "" {
+				name = i18n.Text("unknown AS", "未知 AS")
+			}
+			bus.Info("  " + name + ":")
+		}
+		if connectMs == nil {
+			bus.Info(strings.TrimRight(fmt.Sprintf("  %d) %s  %s", i+1, ep.IP, ep.Desc), " "))
+			continue
+		}
+		bus.Info(strings.TrimRight(fmt.Sprintf("  %d) %-*s  %9s  %s", i+1, width, ep.IP, formatConnect(ep.ConnectMs), ep.Desc), " "))
 	}
 
 	choice := 0
 	if len(endpoints) > 1 && isTTY {
-		// Ensure all queued endpoint lines are rendered before interactive prompt.
-		bus.Flush()
 		var cancelled bool
 		choice, cancelled = promptChoice(ctx, len(endpoints), bus)
 		if cancelled {
@@ -112,10 +254,48 @@ func Choose(ctx context.Context, host string, bus *render.Bus, isTTY bool) Endpo
 		}
 	}
 	selected := endpoints[choice]
-	bus.Info(fmt.Sprintf(i18n.Text("Selected endpoint: %s (%s)", "已选择节点: %s (%s)"), selected.IP, selected.Desc))
+	if groups > 1 {
+		selected.PerASN = perASN(endpoints)
+	}
+	if selected.Desc == "" {
+		bus.Info(i18n.Text("Selected endpoint: ", "已选择节点: ") + selected.IP)
+	} else {
+		bus.Info(fmt.Sprintf(i18n.Text("Selected endpoint: %s (%s)", "已选择节点: %s (%s)"), selected.IP, selected.Desc))
+	}
 	return selected
 }
 
+// rank orders eps for selection, the resolver's order aside: those located
+// in country first, then the fastest to connect, with those that never
+// connected last. Ties keep their order.
+func rank(eps []Endpoint, country string) {
+	key := func(ep Endpoint) (int, float64) {
+		k := 0
+		if country == "" || ep.Country != country {
+			k += 2
+		}
+		if ep.ConnectMs < 0 {
+			k++
+		}
+		return k, ep.ConnectMs
+	}
+	sort.SliceStable(eps, func(i, j int) bool {
+		ki, mi := key(eps[i])
+		kj, mj := key(eps[j])
+		if ki != kj {
+			return ki < kj
+		}
+		return mi < mj
+	})
+}
+
+func formatConnect(ms float64) string {
+	if ms < 0 {
+		return i18n.Text("timeout", "超时")
+	}
+	return fmt.Sprintf("%.1f ms", ms)
+}
+
 func HostFromURL(rawURL string) string {
 	u, err := url.Parse(rawURL)
 	if err != nil {
@@ -174,6 +354,26 @@ func resolveDoHDual(ctx context.Context, host string) ([]string, bool, bool) {
 	return merged, cfTimedOut, aliTimedOut
 }
 
+// resolveSubnet queries Google and AliDNS DoH for the A and AAAA records
+// of host as seen from subnet and merges them in that order.
+func resolveSubnet(ctx context.Context, host, subnet string) []string {
+	templates := []string{googleECSURLTemplate, googleECSURLTemplate, aliECSURLTemplate, aliECSURLTemplate}
+	types := []string{"A", "AAAA", "A", "AAAA"}
+	res := make([]dohResult, len(templates))
+	var wg sync.WaitGroup
+	for i, tmpl := range templates {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			// Both speak the JSON API queryAliDoH reads; a CIDR needs no
+			// escaping in a query string.
+			res[i] = queryAliDoH(ctx, host, fmt.Sprintf(tmpl, "%s", types[i], subnet))
+		}()
+	}
+	wg.Wait()
+	return mergeIPs4(res[0].ips, res[1].ips, res[2].ips, res[3].ips)
+}
+
 // queryCFDoH queries Cloudflare DoH (application/dns-json format).
 func queryCFDoH(ctx context.Context, host string, urlTemplate string) dohResult {
 	ctx2, cancel := context.WithTimeout(ctx, dohTimeout)
@@ -341,13 +541,25 @@ func isTimeoutErr(err error) bool {
 	return false
 }
 
-// ResolveHost tries system DNS and returns the first IPv4 address, or "".
-func ResolveHost(host string) string {
-	return resolveSystem(host)
+// ofFamily keeps the addresses in ips of IP version family.
+func ofFamily(ips []string, family int) []string {
+	var out []string
+	for _, ip := range ips {
+		if v6 := strings.Contains(ip, ":"); v6 == (family == 6) {
+			out = append(out, ip)
+		}
+	}
+	return out
+}
+
+// ResolveHost tries res, the system resolver when nil, and returns the first
+// IPv4 address, or "".
+func ResolveHost(res *net.Resolver, host string) string {
+	return resolveSystem(res, host)
 }
 
-func resolveSystem(host string) string {
-	addrs, err := net.LookupHost(host)
+func resolveSystem(res *net.Resolver, host string) string {
+	addrs, err := res.LookupHost(context.Background(), host)
 	if err != nil {
 		return ""
 	}
@@ -359,29 +571,58 @@ func resolveSystem(host string) string {
 	return ""
 }
 
-func fetchIPDesc(ctx context.Context, ip string) string {
+// resolveAll returns every address res, the system resolver when nil, has
+// for host, IPv4 first.
+func resolveAll(ctx context.Context, res *net.Resolver, host string) []string {
+	addrs, err := res.LookupHost(ctx, host)
+	if err != nil {
+		return nil
+	}
+	var v4, v6 []string
+	for _, a := range addrs {
+		if strings.Contains(a, ":") {
+			v6 = append(v6, a)
+		} else {
+			v4 = append(v4, a)
+		}
+	}
+	return mergeIPs(v4, v6)
+}
+
+// fetchIPDesc returns ip's location and AS for display, and the ISO code of
+// its country.
+func fetchIPDesc(ctx context.Context, ip string) (string, string) {
 	for attempt := 0; attempt < 3; attempt++ {
 		if attempt > 0 {
 			select {
 			case <-ctx.Done():
-				return i18n.Text("lookup failed", "查询失败")
+				return i18n.Text("lookup failed", "查询失败"), ""
 			case <-time.After(time.Duration(attempt) * 500 * time.Millisecond):
 			}
 		}
-		desc, err := doFetchIPDesc(ctx, ip)
+		desc, country, err := doFetchIPDesc(ctx, ip)
 		if err != nil {
 			continue
 		}
-		return desc
+		return desc, country
 	}
-	return i18n.Text("lookup failed", "查询失败")
+	return i18n.Text("lookup failed", "查询失败"), ""
+}
+
+// clientCountry is the ISO code of the client's country, or "".
+func clientCountry(ctx context.Context) string {
+	return fetchInfo(ctx, "", false).CountryCode
 }
 
-// ipAPILangSuffix returns "&lang=zh-CN" when the UI language is Chinese,
-// otherwise an empty string (ip-api defaults to English).
+// ipAPILangSuffix returns "&lang=zh-CN" when the UI language is Chinese
+// (Traditional output is converted locally, ip-api has no zh-TW), "&lang=ja"
+// for Japanese, otherwise an empty string (ip-api defaults to English).
 func ipAPILangSuffix() string {
-	if i18n.IsZH() {
+	switch {
+	case i18n.IsZH():
 		return "&lang=zh-CN"
+	case i18n.Lang() == i18n.LangJA:
+		return "&lang=ja"
 	}
 	return ""
 }
@@ -390,36 +631,40 @@ func ipAPILangSuffix() string {
 // (empty string for self-lookup) and fields, appending the language suffix
 // when in Chinese mode.
 func buildIPAPIURL(target, fields string) string {
+	return ipAPIURL(target, fields, ipAPILangSuffix())
+}
+
+func ipAPIURL(target, fields, suffix string) string {
 	if target == "" {
-		return fmt.Sprintf("http://ip-api.com/json/?fields=%s%s", fields, ipAPILangSuffix())
+		return fmt.Sprintf("http://ip-api.com/json/?fields=%s%s", fields, suffix)
 	}
-	return fmt.Sprintf("http://ip-api.com/json/%s?fields=%s%s", target, fields, ipAPILangSuffix())
+	return fmt.Sprintf("http://ip-api.com/json/%s?fields=%s%s", target, fields, suffix)
 }
 
-func doFetchIPDesc(ctx context.Context, ip string) (string, error) {
+func doFetchIPDesc(ctx context.Context, ip string) (string, string, error) {
 	ctx2, cancel := context.WithTimeout(ctx, 4*time.Second)
 	defer cancel()
 
-	reqURL := buildIPAPIURL(ip, "status,city,regionName,country,as,org")
+	reqURL := buildIPAPIURL(ip, "status,city,regionName,country,countryCode,as,org")
 	req, err := http.NewRequestWithContext(ctx2, http.MethodGet, reqURL, nil)
 	if err != nil {
-		return "", err
+		return "", "", err
 	}
 	resp, err := http.DefaultClient.Do(req)
 	if err != nil {
-		return "", err
+		return "", "", err
 	}
 	defer resp.Body.Close()
 	if resp.StatusCode != http.StatusOK {
-		return "", fmt.Errorf("HTTP %d", resp.StatusCode)
+		return "", "", fmt.Errorf("HTTP %d", resp.StatusCode)
 	}
 
 	var info IPInfo
 	if err := json.NewDecoder(resp.Body).Decode(&info); err != nil {
-		return "", err
+		return "", "", err
 	}
 	if info.Status != "success" {
-		return "", fmt.Errorf("ip-api status: %s", info.Status)
+		return "", "", fmt.Errorf("ip-api status: %s", info.Status)
 	}
 
 	loc := info.City
@@ -439,10 +684,22 @@ func doFetchIPDesc(ctx context.Context, ip string) (string, error) {
 	if asn != "" {
 		loc += " (" + asn + ")"
 	}
-	return loc, nil
+	return i18n.LocalizeZH(loc), info.CountryCode, nil
 }
 
+// FetchInfo looks up target (empty for the client itself) with place names
+// in the UI language, for display.
 func FetchInfo(ctx context.Context, target string) IPInfo {
+	return fetchInfo(ctx, target, true)
+}
+
+// FetchInfoEN is FetchInfo with place names in English whatever the UI
+// language, for machine-readable output.
+func FetchInfoEN(ctx context.Context, target string) IPInfo {
+	return fetchInfo(ctx, target, false)
+}
+
+func fetchInfo(ctx context.Context, target string, localized bool) IPInfo {
 	for attempt := 0; attempt < 3; attempt++ {
 		if attempt > 0 {
 			select {
@@ -451,7 +708,7 @@ func FetchInfo(ctx context.Context, target string) IPInfo {
 			case <-time.After(time.Duration(attempt) * 500 * time.Millisecond):
 			}
 		}
-		info, err := doFetchInfo(ctx, target)
+		info, err := doFetchInfo(ctx, target, localized)
 		if err != nil {
 			continue
 		}
@@ -460,16 +717,19 @@ func FetchInfo(ctx context.Context, target string) IPInfo {
 	return IPInfo{}
 }
 
-func doFetchInfo(ctx context.Context, target string) (IPInfo, error) {
+func doFetchInfo(ctx context.Context, target string, localized bool) (IPInfo, error) {
 	ctx2, cancel := context.WithTimeout(ctx, 5*time.Second)
 	defer cancel()
 
-	var reqURL string
+	fields := "status,query,as,isp,org,city,regionName,country"
 	if target == "" {
-		reqURL = buildIPAPIURL("", "status,query,as,isp,city,regionName,country")
-	} else {
-		reqURL = buildIPAPIURL(target, "status,query,as,isp,org,city,regionName,country")
+		fields = "status,query,as,isp,city,regionName,country,countryCode"
 	}
+	suffix := ""
+	if localized {
+		suffix = ipAPILangSuffix()
+	}
+	reqURL := ipAPIURL(target, fields, suffix)
 	req, err := http.NewRequestWithContext(ctx2, http.MethodGet, reqURL, nil)
 	if err != nil {
 		return IPInfo{}, err
@@ -489,6 +749,11 @@ func doFetchInfo(ctx context.Context, target string) (IPInfo, error) {
 	if info.Status != "" && info.Status != "success" {
 		return IPInfo{}, fmt.Errorf("ip-api status: %s", info.Status)
 	}
+	if localized {
+		info.City = i18n.LocalizeZH(info.City)
+		info.RegionName = i18n.LocalizeZH(info.RegionName)
+		info.Country = i18n.LocalizeZH(info.Country)
+	}
 	return info, nil
 }
 
@@ -496,7 +761,7 @@ func doFetchInfo(ctx context.Context, target string) (IPInfo, error) {
 // It returns (choiceIndex, cancelled). When ctx is cancelled (e.g. Ctrl+C),
 // the tty is closed to unblock the read and cancelled=true is returned.
 func promptChoice(ctx context.Context, count int, bus *render.Bus) (int, bool) {
-	fmt.Fprintf(os.Stderr, "  \033[36m\033[1m[?]\033[0m %s", fmt.Sprintf(i18n.Text("Select endpoint [1-%d, Enter=1]: ", "选择节点 [1-%d，回车=1]: "), count))
+	bus.Prompt(fmt.Sprintf(i18n.Text("Select endpoint [1-%d, Enter=1]: ", "选择节点 [1-%d，回车=1]: "), count))
 
 	tty, shouldClose, err := openPromptInputFn()
 	if err != nil {
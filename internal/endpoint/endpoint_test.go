@@ -17,6 +17,12 @@ import (
 	"github.com/tsosunchia/iNetSpeed-CLI/internal/render"
 )
 
+func TestMain(m *testing.M) {
+	// No test asks ip-api where it runs; those that rank by country set it.
+	clientCountryFn = func(context.Context) string { return "" }
+	os.Exit(m.Run())
+}
+
 func newTestBus() *render.Bus {
 	return render.NewBus(render.NewPlainRenderer(&strings.Builder{}))
 }
@@ -40,7 +46,7 @@ func TestHostFromURL(t *testing.T) {
 func TestChooseEmptyHost(t *testing.T) {
 	bus := newTestBus()
 	defer bus.Close()
-	ep := Choose(context.Background(), "", bus, false)
+	ep := Choose(context.Background(), "", bus, false, Prescreen{}, Lookups{})
 	if ep.IP != "" {
 		t.Errorf("expected empty endpoint, got %+v", ep)
 	}
@@ -172,13 +178,13 @@ func TestChooseSystemDNSFallback(t *testing.T) {
 	resolveDoHFn = func(ctx context.Context, host string) ([]string, bool, bool) {
 		return nil, true, true
 	}
-	resolveSystemFn = func(host string) string {
+	resolveSystemFn = func(_ *net.Resolver, host string) string {
 		return "9.9.9.9"
 	}
 
 	bus := newTestBus()
 	defer bus.Close()
-	ep := Choose(context.Background(), "mensura.cdn-apple.com", bus, false)
+	ep := Choose(context.Background(), "mensura.cdn-apple.com", bus, false, Prescreen{}, Lookups{})
 	if ep.IP != "9.9.9.9" {
 		t.Errorf("expected system fallback IP, got %+v", ep)
 	}
@@ -187,6 +193,133 @@ func TestChooseSystemDNSFallback(t *testing.T) {
 	}
 }
 
+func TestChooseNoDoHNoGeo(t *testing.T) {
+	oldResolveDoH := resolveDoHFn
+	oldResolveAll := resolveAllFn
+	oldFetchIPDesc := fetchIPDescFn
+	t.Cleanup(func() {
+		resolveDoHFn = oldResolveDoH
+		resolveAllFn = oldResolveAll
+		fetchIPDescFn = oldFetchIPDesc
+	})
+	resolveDoHFn = func(context.Context, string) ([]string, bool, bool) {
+		t.Error("DoH queried under NoDoH")
+		return nil, false, false
+	}
+	resolveAllFn = func(context.Context, *net.Resolver, string) []string { return []string{"9.9.9.9", "2001:db8::1"} }
+	fetchIPDescFn = func(context.Context, string) (string, string) {
+		t.Error("ip-api queried under NoGeo")
+		return "", ""
+	}
+
+	bus := newTestBus()
+	defer bus.Close()
+	ep := Choose(context.Background(), "mensura.cdn-apple.com", bus, false, Prescreen{}, Lookups{NoDoH: true, NoGeo: true})
+	if ep.IP != "9.9.9.9" || ep.Desc != "" {
+		t.Errorf("endpoint = %+v", ep)
+	}
+	// A DNS server of its own replaces DoH as well.
+	var got *net.Resolver
+	res := &net.Resolver{PreferGo: true}
+	resolveAllFn = func(_ context.Context, r *net.Resolver, _ string) []string {
+		got = r
+		return []string{"8.8.8.8"}
+	}
+	lk := Lookups{NoGeo: true, Resolver: res, DNSServer: "192.0.2.53:53"}
+	if ep := Choose(context.Background(), "mensura.cdn-apple.com", bus, false, Prescreen{}, lk); ep.IP != "8.8.8.8" || got != res {
+		t.Errorf("endpoint over a DNS server = %+v", ep)
+	}
+}
+
+func TestChooseRegion(t *testing.T) {
+	oldResolveDoH := resolveDoHFn
+	oldResolveSubnet := resolveSubnetFn
+	t.Cleanup(func() {
+		resolveDoHFn = oldResolveDoH
+		resolveSubnetFn = oldResolveSubnet
+	})
+	resolveDoHFn = func(context.Context, string) ([]string, bool, bool) {
+		t.Error("plain DoH queried for a region")
+		return nil, false, false
+	}
+	var subnet string
+	resolveSubnetFn = func(_ context.Context, _, s string) []string {
+		subnet = s
+		return []string{"17.253.84.125"}
+	}
+	bus := newTestBus()
+	defer bus.Close()
+	lk := Lookups{NoGeo: true, Subnet: "126.0.0.0/24", Region: "jp"}
+	if ep := Choose(context.Background(), "mensura.cdn-apple.com", bus, false, Prescreen{}, lk); ep.IP != "17.253.84.125" || subnet != "126.0.0.0/24" {
+		t.Errorf("endpoint = %+v, subnet %q", ep, subnet)
+	}
+	resolveSubnetFn = func(context.Context, string, string) []string { return nil }
+	if ep := Choose(context.Background(), "mensura.cdn-apple.com", bus, false, Prescreen{}, lk); ep.IP != "" {
+		t.Errorf("endpoint without answers = %+v", ep)
+	}
+}
+
+func TestResolveSubnet(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if got := r.URL.Query().Get("edns_client_subnet"); got != "126.0.0.0/24" {
+			t.Errorf("%s: edns_client_subnet = %q", r.URL.Path, got)
+		}
+		switch r.URL.Path + " " + r.URL.Query().Get("type") {
+		case "/google A":
+			fmt.Fprint(w, `{"Answer":[{"data":"17.253.84.125"}]}`)
+		case "/google AAAA":
+			fmt.Fprint(w, `{"Answer":[{"data":"2403:300:a42::5"}]}`)
+		case "/ali A":
+			fmt.Fprint(w, `["17.253.84.125","17.253.84.126"]`)
+		default:
+			fmt.Fprint(w, `[]`)
+		}
+	}))
+	defer srv.Close()
+	oldGoogle, oldAli, oldClient := googleECSURLTemplate, aliECSURLTemplate, dohHTTPClient
+	t.Cleanup(func() { googleECSURLTemplate, aliECSURLTemplate, dohHTTPClient = oldGoogle, oldAli, oldClient })
+	googleECSURLTemplate = srv.URL + "/google?name=%s&type=%s&edns_client_subnet=%s"
+	aliECSURLTemplate = srv.URL + "/ali?name=%s&type=%s&short=1&edns_client_subnet=%s"
+	dohHTTPClient = srv.Client()
+
+	got := resolveSubnet(context.Background(), "mensura.cdn-apple.com", "126.0.0.0/24")
+	want := []string{"17.253.84.125", "2403:300:a42::5", "17.253.84.126"}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("resolveSubnet = %v, want %v", got, want)
+	}
+}
+
+func TestChooseGeoBudget(t *testing.T) {
+	oldResolveDoH := resolveDoHFn
+	oldFetchIPDesc := fetchIPDescFn
+	oldBudget := LookupBudget
+	t.Cleanup(func() {
+		resolveDoHFn = oldResolveDoH
+		fetchIPDescFn = oldFetchIPDesc
+		LookupBudget = oldBudget
+	})
+	resolveDoHFn = func(context.Context, string) ([]string, bool, bool) {
+		return []string{"1.1.1.1", "2.2.2.2", "3.3.3.3"}, false, false
+	}
+	// Every lookup hangs until the budget runs out.
+	fetchIPDescFn = func(ctx context.Context, ip string) (string, string) {
+		<-ctx.Done()
+		return "lookup failed", ""
+	}
+	LookupBudget = 50 * time.Millisecond
+
+	bus := newTestBus()
+	defer bus.Close()
+	start := time.Now()
+	ep := Choose(context.Background(), "example.com", bus, false, Prescreen{}, Lookups{})
+	if elapsed := time.Since(start); elapsed > time.Second {
+		t.Errorf("Choose took %v with a %v budget", elapsed, LookupBudget)
+	}
+	if ep.IP != "1.1.1.1" || ep.Desc != "lookup failed" {
+		t.Errorf("endpoint = %+v", ep)
+	}
+}
+
 func TestChooseNoFallbackWhenDualDoHNoIPs(t *testing.T) {
 	oldResolveDoH := resolveDoHFn
 	oldResolveSystem := resolveSystemFn
@@ -199,14 +332,14 @@ func TestChooseNoFallbackWhenDualDoHNoIPs(t *testing.T) {
 		return nil, false, false
 	}
 	resolveSystemCalled := false
-	resolveSystemFn = func(host string) string {
+	resolveSystemFn = func(_ *net.Resolver, host string) string {
 		resolveSystemCalled = true
 		return "8.8.8.8"
 	}
 
 	bus := newTestBus()
 	defer bus.Close()
-	ep := Choose(context.Background(), "mensura.cdn-apple.com", bus, false)
+	ep := Choose(context.Background(), "mensura.cdn-apple.com", bus, false, Prescreen{}, Lookups{})
 	if ep.IP != "" {
 		t.Errorf("expected empty endpoint when dual DoH has no IPs but no timeout, got %+v", ep)
 	}
@@ -216,7 +349,7 @@ func TestChooseNoFallbackWhenDualDoHNoIPs(t *testing.T) {
 }
 
 func TestResolveHostLocalhost(t *testing.T) {
-	ip := ResolveHost("localhost")
+	ip := ResolveHost(nil, "localhost")
 	if ip != "" && net.ParseIP(ip) == nil {
 		t.Errorf("ResolveHost returned invalid IP: %q", ip)
 	}
@@ -261,6 +394,7 @@ func TestParseChoice(t *testing.T) {
 		{name: "newline defaults", line: "\n", count: 4, want: 0, ok: true},
 		{name: "valid one", line: "1", count: 4, want: 0, ok: true},
 		{name: "valid with spaces", line: " 3 ", count: 4, want: 2, ok: true},
+		{name: "windows line ending", line: "2\r\n", count: 4, want: 1, ok: true},
 		{name: "zero invalid", line: "0", count: 4, want: 0, ok: false},
 		{name: "out of range invalid", line: "5", count: 4, want: 0, ok: false},
 		{name: "non number invalid", line: "abc", count: 4, want: 0, ok: false},
@@ -276,6 +410,43 @@ func TestParseChoice(t *testing.T) {
 	}
 }
 
+func TestASN(t *testing.T) {
+	desc := "Tokyo, Japan (AS714 Apple Inc.)"
+	if got := ASN(desc); got != "AS714" {
+		t.Errorf("ASN = %q", got)
+	}
+	if got := ASName(desc); got != "AS714 Apple Inc." {
+		t.Errorf("ASName = %q", got)
+	}
+	if got := ASN("lookup failed"); got != "" {
+		t.Errorf("ASN without AS = %q", got)
+	}
+}
+
+func TestGroupByASN(t *testing.T) {
+	eps := []Endpoint{
+		{IP: "17.253.84.125", Desc: "Tokyo, Japan (AS714 Apple Inc.)"},
+		{IP: "61.147.210.6", Desc: "Jiangsu, China (AS4134 Chinanet)"},
+		{IP: "203.0.113.9", Desc: "lookup failed"},
+		{IP: "17.253.84.126", Desc: "Osaka, Japan (AS714 Apple Inc.)"},
+		{IP: "61.147.210.7", Desc: "Jiangsu, China (AS4134 Chinanet)"},
+	}
+	if n := groupByASN(eps); n != 3 {
+		t.Fatalf("groups = %d, want 3", n)
+	}
+	var ips []string
+	for _, ep := range eps {
+		ips = append(ips, ep.IP)
+	}
+	if got := strings.Join(ips, " "); got != "17.253.84.125 17.253.84.126 61.147.210.6 61.147.210.7 203.0.113.9" {
+		t.Errorf("grouped order = %s", got)
+	}
+	reps := perASN(eps)
+	if len(reps) != 2 || reps[0].IP != "17.253.84.125" || reps[1].IP != "61.147.210.6" {
+		t.Errorf("perASN = %+v", reps)
+	}
+}
+
 // ---------------------------------------------------------------------------
 //  Dual DoH unit tests
 // ---------------------------------------------------------------------------
@@ -564,11 +735,11 @@ func TestPromptChoiceCancelledContext(t *testing.T) {
 	resolveDoHFn = func(_ context.Context, _ string) ([]string, bool, bool) {
 		return []string{"1.1.1.1", "2.2.2.2"}, false, false
 	}
-	fetchIPDescFn = func(_ context.Context, ip string) string {
-		return "test-" + ip
+	fetchIPDescFn = func(_ context.Context, ip string) (string, string) {
+		return "test-" + ip, ""
 	}
 
-	ep := Choose(ctx, "example.com", bus, true)
+	ep := Choose(ctx, "example.com", bus, true, Prescreen{}, Lookups{})
 	// With cancelled ctx, promptChoice should return cancelled=true,
 	// Choose should return empty Endpoint.
 	if ep.IP != "" {
@@ -590,8 +761,8 @@ func TestPromptChoiceCancelDuringRead(t *testing.T) {
 	resolveDoHFn = func(_ context.Context, _ string) ([]string, bool, bool) {
 		return []string{"1.1.1.1", "2.2.2.2"}, false, false
 	}
-	fetchIPDescFn = func(_ context.Context, ip string) string {
-		return "test-" + ip
+	fetchIPDescFn = func(_ context.Context, ip string) (string, string) {
+		return "test-" + ip, ""
 	}
 
 	// Create a pipe that will block on read until closed.
@@ -612,7 +783,7 @@ func TestPromptChoiceCancelDuringRead(t *testing.T) {
 
 	done := make(chan Endpoint, 1)
 	go func() {
-		ep := Choose(ctx, "example.com", bus, true)
+		ep := Choose(ctx, "example.com", bus, true, Prescreen{}, Lookups{})
 		done <- ep
 	}()
 
@@ -646,8 +817,8 @@ func TestPromptChoiceNormalInput(t *testing.T) {
 	resolveDoHFn = func(_ context.Context, _ string) ([]string, bool, bool) {
 		return []string{"10.0.0.1", "10.0.0.2"}, false, false
 	}
-	fetchIPDescFn = func(_ context.Context, ip string) string {
-		return "desc-" + ip
+	fetchIPDescFn = func(_ context.Context, ip string) (string, string) {
+		return "desc-" + ip, ""
 	}
 
 	// Create a pipe; write "2\n" to simulate the user selecting endpoint 2.
@@ -667,7 +838,7 @@ func TestPromptChoiceNormalInput(t *testing.T) {
 	bus := newTestBus()
 	defer bus.Close()
 
-	ep := Choose(context.Background(), "example.com", bus, true)
+	ep := Choose(context.Background(), "example.com", bus, true, Prescreen{}, Lookups{})
 	if ep.IP != "10.0.0.2" {
 		t.Errorf("expected IP=10.0.0.2, got %q", ep.IP)
 	}
@@ -882,4 +1053,202 @@ func TestIPAPILangSuffix(t *testing.T) {
 	if s := ipAPILangSuffix(); s != "&lang=zh-CN" {
 		t.Errorf("zh: expected &lang=zh-CN, got %q", s)
 	}
+
+	i18n.Set("zh-TW")
+	if s := ipAPILangSuffix(); s != "&lang=zh-CN" {
+		t.Errorf("zh-Hant: expected &lang=zh-CN, got %q", s)
+	}
+
+	i18n.Set("ja")
+	if s := ipAPILangSuffix(); s != "&lang=ja" {
+		t.Errorf("ja: expected &lang=ja, got %q", s)
+	}
+}
+
+// ---------------------------------------------------------------------------
+//  Endpoint pre-screen
+// ---------------------------------------------------------------------------
+
+func TestPrescreen(t *testing.T) {
+	srv := httptest.NewTLSServer(http.NotFoundHandler())
+	defer srv.Close()
+	_, port, _ := net.SplitHostPort(srv.Listener.Addr().String())
+
+	closed, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	_, deadPort, _ := net.SplitHostPort(closed.Addr().String())
+	closed.Close()
+
+	for _, tls := range []bool{false, true} {
+		got := prescreen(context.Background(), "example.com", []string{"127.0.0.1"}, Prescreen{Port: port, TLS: tls})
+		if len(got) != 1 || got[0] < 0 {
+			t.Errorf("TLS=%v: connect times = %v", tls, got)
+		}
+	}
+	if got := prescreen(context.Background(), "example.com", []string{"127.0.0.1"}, Prescreen{Port: deadPort}); got[0] != -1 {
+		t.Errorf("closed port: connect time = %v, want -1", got[0])
+	}
+	if got := median([]float64{3, 1, 2, 10}); got != 2.5 {
+		t.Errorf("median = %v, want 2.5", got)
+	}
+}
+
+func TestChoosePrescreen(t *testing.T) {
+	oldResolveDoH := resolveDoHFn
+	oldFetchIPDesc := fetchIPDescFn
+	oldPrescreen := prescreenFn
+	t.Cleanup(func() {
+		resolveDoHFn = oldResolveDoH
+		fetchIPDescFn = oldFetchIPDesc
+		prescreenFn = oldPrescreen
+	})
+	i18n.Set(i18n.LangEN)
+	resolveDoHFn = func(_ context.Context, _ string) ([]string, bool, bool) {
+		return []string{"10.0.0.1", "2001:db8::2"}, false, false
+	}
+	fetchIPDescFn = func(_ context.Context, ip string) (string, string) {
+		return "desc-" + ip, ""
+	}
+	var gotPS Prescreen
+	prescreenFn = func(_ context.Context, host string, ips []string, ps Prescreen) []float64 {
+		gotPS = ps
+		return []float64{12.34, -1}
+	}
+
+	var out strings.Builder
+	bus := render.NewBus(render.NewPlainRenderer(&out))
+	ep := Choose(context.Background(), "example.com", bus, false, Prescreen{Port: "443", TLS: true}, Lookups{})
+	bus.Close()
+
+	if ep.IP != "10.0.0.1" || ep.ConnectMs != 12.34 {
+		t.Errorf("endpoint = %+v", ep)
+	}
+	if gotPS.Port != "443" || !gotPS.TLS {
+		t.Errorf("prescreen options = %+v", gotPS)
+	}
+	for _, want := range []string{
+		"median TCP+TLS connect time of 3 attempts",
+		"1) 10.0.0.1       12.3 ms  desc-10.0.0.1",
+		"2) 2001:db8::2    timeout  desc-2001:db8::2",
+	} {
+		if !strings.Contains(out.String(), want) {
+			t.Errorf("output missing %q\n%s", want, out.String())
+		}
+	}
+}
+
+func TestChooseFamily(t *testing.T) {
+	oldResolveDoH := resolveDoHFn
+	oldResolveSystem := resolveSystemFn
+	t.Cleanup(func() {
+		resolveDoHFn = oldResolveDoH
+		resolveSystemFn = oldResolveSystem
+	})
+	resolveDoHFn = func(context.Context, string) ([]string, bool, bool) {
+		return []string{"9.9.9.9", "2001:db8::1", "2001:db8::2"}, false, false
+	}
+	bus := newTestBus()
+	defer bus.Close()
+	lk := Lookups{NoGeo: true, Family: 6}
+	if ep := Choose(context.Background(), "mensura.cdn-apple.com", bus, false, Prescreen{}, lk); ep.IP != "2001:db8::1" {
+		t.Errorf("IPv6 endpoint = %+v", ep)
+	}
+	lk.Family = 4
+	if ep := Choose(context.Background(), "mensura.cdn-apple.com", bus, false, Prescreen{}, lk); ep.IP != "9.9.9.9" {
+		t.Errorf("IPv4 endpoint = %+v", ep)
+	}
+
+	resolveDoHFn = func(context.Context, string) ([]string, bool, bool) { return []string{"9.9.9.9"}, false, false }
+	lk.Family = 6
+	if ep := Choose(context.Background(), "mensura.cdn-apple.com", bus, false, Prescreen{}, lk); ep.IP != "" {
+		t.Errorf("endpoint without an IPv6 address = %+v", ep)
+	}
+	// The system fallback is IPv4 only.
+	resolveDoHFn = func(context.Context, string) ([]string, bool, bool) { return nil, true, true }
+	resolveSystemFn = func(*net.Resolver, string) string { return "9.9.9.9" }
+	if ep := Choose(context.Background(), "mensura.cdn-apple.com", bus, false, Prescreen{}, lk); ep.IP != "" {
+		t.Errorf("IPv6 endpoint from the system fallback = %+v", ep)
+	}
+}
+
+func TestRank(t *testing.T) {
+	eps := []Endpoint{
+		{IP: "1", Country: "US", ConnectMs: 5},
+		{IP: "2", Country: "JP", ConnectMs: -1},
+		{IP: "3", Country: "JP", ConnectMs: 40},
+		{IP: "4", Country: "", ConnectMs: 2},
+		{IP: "5", Country: "JP", ConnectMs: 30},
+	}
+	ips := func() string {
+		var b strings.Builder
+		for _, ep := range eps {
+			b.WriteString(ep.IP)
+		}
+		return b.String()
+	}
+	rank(eps, "JP")
+	if got := ips(); got != "53241" {
+		t.Errorf("ranked for JP = %s, want 53241", got)
+	}
+	rank(eps, "")
+	if got := ips(); got != "41532" {
+		t.Errorf("ranked by connect time = %s, want 41532", got)
+	}
+
+	// Without measurements or a country the resolver's order stands.
+	eps = []Endpoint{{IP: "1"}, {IP: "2"}, {IP: "3"}}
+	rank(eps, "")
+	if got := ips(); got != "123" {
+		t.Errorf("unranked = %s, want 123", got)
+	}
+}
+
+func TestChooseRanking(t *testing.T) {
+	oldResolveDoH := resolveDoHFn
+	oldFetchIPDesc := fetchIPDescFn
+	oldClientCountry := clientCountryFn
+	oldPrescreen := prescreenFn
+	t.Cleanup(func() {
+		resolveDoHFn = oldResolveDoH
+		fetchIPDescFn = oldFetchIPDesc
+		clientCountryFn = oldClientCountry
+		prescreenFn = oldPrescreen
+	})
+	i18n.Set(i18n.LangEN)
+	resolveDoHFn = func(context.Context, string) ([]string, bool, bool) {
+		return []string{"10.0.0.1", "10.0.0.2", "10.0.0.3"}, false, false
+	}
+	countries := map[string]string{"10.0.0.1": "US", "10.0.0.2": "DE", "10.0.0.3": "DE"}
+	fetchIPDescFn = func(_ context.Context, ip string) (string, string) {
+		return "in " + countries[ip], countries[ip]
+	}
+	clientCountryFn = func(context.Context) string { return "DE" }
+	prescreenFn = func(context.Context, string, []string, Prescreen) []float64 {
+		return []float64{3, 20, 10}
+	}
+
+	var out strings.Builder
+	bus := render.NewBus(render.NewPlainRenderer(&out))
+	ep := Choose(context.Background(), "example.com", bus, false, Prescreen{Port: "443"}, Lookups{})
+	if ep.IP != "10.0.0.3" || ep.Country != "DE" || ep.ConnectMs != 10 {
+		t.Errorf("endpoint in the client's country = %+v", ep)
+	}
+	// The override wins over the client's country.
+	ep = Choose(context.Background(), "example.com", bus, false, Prescreen{Port: "443"}, Lookups{Country: "us"})
+	if ep.IP != "10.0.0.1" {
+		t.Errorf("endpoint with Country US = %+v", ep)
+	}
+	bus.Close()
+	for _, want := range []string{
+		"Ranked: endpoints in DE first, then by connect time.",
+		"1) 10.0.0.3    10.0 ms  in DE",
+		"3) 10.0.0.1     3.0 ms  in US",
+		"Ranked: endpoints in US first, then by connect time.",
+	} {
+		if !strings.Contains(out.String(), want) {
+			t.Errorf("output missing %q\n%s", want, out.String())
+		}
+	}
 }
@@ -0,0 +1,82 @@
+package endpoint
+
+import (
+	"context"
+	"crypto/tls"
+	"net"
+	"sort"
+	"sync"
+	"time"
+)
+
+// Prescreen configures the connect-latency check Choose runs on every
+// candidate before listing them. A zero Prescreen skips the check.
+type Prescreen struct {
+	Port string // TCP port to connect to, usually 443
+	// TLS also completes a TLS handshake with the CDN host as SNI, which
+	// includes the edge's TLS termination in the time.
+	TLS bool
+}
+
+var (
+	// prescreenAttempts connections are made per candidate, one after
+	// another; candidates are checked concurrently.
+	prescreenAttempts = 3
+	prescreenTimeout  = time.Second
+
+	prescreenFn = prescreen
+)
+
+// prescreen returns the median connect time in ms of each ip, in order, or
+// -1 for candidates that never connected.
+func prescreen(ctx context.Context, host string, ips []string, p Prescreen) []float64 {
+	out := make([]float64, len(ips))
+	var wg sync.WaitGroup
+	for i, ip := range ips {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			var samples []float64
+			for a := 0; a < prescreenAttempts && ctx.Err() == nil; a++ {
+				if d, err := connectTime(ctx, host, ip, p); err == nil {
+					samples = append(samples, d)
+				}
+			}
+			out[i] = median(samples)
+		}()
+	}
+	wg.Wait()
+	return out
+}
+
+func connectTime(ctx context.Context, host, ip string, p Prescreen) (float64, error) {
+	ctx, cancel := context.WithTimeout(ctx, prescreenTimeout)
+	defer cancel()
+	start := time.Now()
+	var d net.Dialer
+	conn, err := d.DialContext(ctx, "tcp", net.JoinHostPort(ip, p.Port))
+	if err != nil {
+		return 0, err
+	}
+	defer conn.Close()
+	if p.TLS {
+		// Only the handshake time matters here; the certificate is checked
+		// by the real transfers.
+		tc := tls.Client(conn, &tls.Config{ServerName: host, InsecureSkipVerify: true})
+		if err := tc.HandshakeContext(ctx); err != nil {
+			return 0, err
+		}
+	}
+	return float64(time.Since(start).Microseconds()) / 1000, nil
+}
+
+func median(v []float64) float64 {
+	if len(v) == 0 {
+		return -1
+	}
+	sort.Float64s(v)
+	if len(v)%2 == 1 {
+		return v[len(v)/2]
+	}
+	return (v[len(v)/2-1] + v[len(v)/2]) / 2
+}
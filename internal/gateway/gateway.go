@@ -0,0 +1,95 @@
+// Package gateway finds the default IPv4 gateway in the system routing
+// table, the nearest hop a latency target can name.
+package gateway
+
+import (
+	"bufio"
+	"encoding/binary"
+	"encoding/hex"
+	"errors"
+	"io"
+	"net"
+	"strconv"
+	"strings"
+)
+
+// ErrUnsupported is returned where the routing table cannot be read.
+var ErrUnsupported = errors.New("reading the routing table is not supported on this platform")
+
+var errNotFound = errors.New("no default route")
+
+// Default returns the address of the default IPv4 gateway.
+func Default() (string, error) {
+	return defaultGateway()
+}
+
+// parseProcRoute reads Linux's /proc/net/route, where addresses are
+// little-endian hex, and returns the gateway of the default route with the
+// lowest metric.
+func parseProcRoute(r io.Reader) (string, error) {
+	sc := bufio.NewScanner(r)
+	best, bestMetric := "", -1
+	for sc.Scan() {
+		// Iface Destination Gateway Flags RefCnt Use Metric Mask ...
+		f := strings.Fields(sc.Text())
+		if len(f) < 8 || f[1] != "00000000" || f[7] != "00000000" {
+			continue
+		}
+		b, err := hex.DecodeString(f[2])
+		if err != nil || len(b) != 4 {
+			continue
+		}
+		ip := make(net.IP, 4)
+		binary.BigEndian.PutUint32(ip, binary.LittleEndian.Uint32(b))
+		if ip.IsUnspecified() {
+			continue
+		}
+		metric, _ := strconv.Atoi(f[6])
+		if bestMetric < 0 || metric < bestMetric {
+			best, bestMetric = ip.String(), metric
+		}
+	}
+	if best == "" {
+		return "", errNotFound
+	}
+	return best, nil
+}
+
+// parseRouteGet reads the output of the BSD `route -n get default`, which
+// has a "gateway: 192.168.1.1" line.
+func parseRouteGet(out string) (string, error) {
+	for _, line := range strings.Split(out, "\n") {
+		k, v, ok := strings.Cut(strings.TrimSpace(line), ":")
+		if ok && k == "gateway" {
+			if ip := net.ParseIP(strings.TrimSpace(v)); ip != nil {
+				return ip.String(), nil
+			}
+		}
+	}
+	return "", errNotFound
+}
+
+// parseRoutePrint reads the IPv4 route table of Windows' `route print`,
+// whose default routes are "0.0.0.0 0.0.0.0 <gateway> <interface> <metric>"
+// rows, and returns the gateway with the lowest metric.
+func parseRoutePrint(out string) (string, error) {
+	best, bestMetric := "", -1
+	for _, line := range strings.Split(out, "\n") {
+		f := strings.Fields(line)
+		if len(f) < 5 || f[0] != "0.0.0.0" || f[1] != "0.0.0.0" {
+			continue
+		}
+		ip := net.ParseIP(f[2]) // "On-link" is not a gateway
+		if ip == nil {
+			continue
+		}
+		metric, _ := strconv.Atoi(f[4])
+		if bestMetric < 0 || metric < bestMetric {
+			best, bestMetric = ip.String(), metric
+		}
+	}
+	if best == "" {
+		return "", errNotFound
+	}
+	return best, nil
+}
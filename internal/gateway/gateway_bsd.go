@@ -0,0 +1,13 @@
+//go:build darwin || freebsd || netbsd || openbsd || dragonfly
+
+package gateway
+
+import "os/exec"
+
+func defaultGateway() (string, error) {
+	out, err := exec.Command("route", "-n", "get", "default").Output()
+	if err != nil {
+		return "", err
+	}
+	return parseRouteGet(string(out))
+}
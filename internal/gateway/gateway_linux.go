@@ -0,0 +1,12 @@
+package gateway
+
+import "os"
+
+func defaultGateway() (string, error) {
+	f, err := os.Open("/proc/net/route")
+	if err != nil {
+		return "", err
+	}
+	defer f.Close()
+	return parseProcRoute(f)
+}
@@ -0,0 +1,48 @@
+package gateway
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestParseProcRoute(t *testing.T) {
+	const table = `Iface	Destination	Gateway 	Flags	RefCnt	Use	Metric	Mask		MTU	Window	IRTT
+wlan0	00000000	0101A8C0	0003	0	0	600	00000000	0	0	0
+eth0	00000000	FE01A8C0	0003	0	0	100	00000000	0	0	0
+eth0	0001A8C0	00000000	0001	0	0	100	00FFFFFF	0	0	0
+`
+	gw, err := parseProcRoute(strings.NewReader(table))
+	if err != nil || gw != "192.168.1.254" {
+		t.Errorf("gateway = %q, %v", gw, err)
+	}
+	if _, err := parseProcRoute(strings.NewReader("Iface\tDestination\n")); err == nil {
+		t.Error("table without a default route accepted")
+	}
+}
+
+func TestParseRouteGet(t *testing.T) {
+	const out = `   route to: default
+destination: default
+       mask: default
+    gateway: 10.0.0.1
+  interface: en0
+`
+	if gw, err := parseRouteGet(out); err != nil || gw != "10.0.0.1" {
+		t.Errorf("gateway = %q, %v", gw, err)
+	}
+}
+
+func TestParseRoutePrint(t *testing.T) {
+	const out = `IPv4 Route Table
+===========================================================================
+Active Routes:
+Network Destination        Netmask          Gateway       Interface  Metric
+          0.0.0.0          0.0.0.0      192.168.0.1    192.168.0.23     35
+          0.0.0.0          0.0.0.0      172.16.0.1     172.16.0.9      25
+          0.0.0.0          0.0.0.0         On-link     10.8.0.2         5
+===========================================================================
+`
+	if gw, err := parseRoutePrint(out); err != nil || gw != "172.16.0.1" {
+		t.Errorf("gateway = %q, %v", gw, err)
+	}
+}
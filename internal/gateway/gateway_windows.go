@@ -0,0 +1,11 @@
+package gateway
+
+import "os/exec"
+
+func defaultGateway() (string, error) {
+	out, err := exec.Command("route", "print", "-4", "0.0.0.0").Output()
+	if err != nil {
+		return "", err
+	}
+	return parseRoutePrint(string(out))
+}
@@ -0,0 +1,100 @@
+// Package histogram summarizes samples in exponentially sized buckets, as
+// HDR histograms do: each bucket is a fixed ratio wider than the one below,
+// so quantiles keep the same relative precision from sub-millisecond
+// latencies to multi-gigabit throughput, in memory that grows with the
+// spread of the values rather than their number.
+package histogram
+
+import (
+	"math"
+	"sort"
+)
+
+// growth is the ratio between successive bucket bounds. A quantile is
+// reported as its bucket's geometric midpoint, within about 1% of the sample.
+const growth = 1.02
+
+var logGrowth = math.Log(growth)
+
+// Histogram counts samples by bucket. The zero value is empty and ready.
+type Histogram struct {
+	buckets  map[int]int
+	zeros    int // samples <= 0, below every bucket
+	n        int
+	min, max float64
+}
+
+// Of returns the histogram of values.
+func Of(values []float64) *Histogram {
+	h := &Histogram{}
+	for _, v := range values {
+		h.Add(v)
+	}
+	return h
+}
+
+// Add counts v. Values of zero or less, such as an interval in which
+// nothing arrived, share one bucket below all others.
+func (h *Histogram) Add(v float64) {
+	if h.n == 0 || v < h.min {
+		h.min = v
+	}
+	if h.n == 0 || v > h.max {
+		h.max = v
+	}
+	h.n++
+	if v <= 0 {
+		h.zeros++
+		return
+	}
+	if h.buckets == nil {
+		h.buckets = map[int]int{}
+	}
+	h.buckets[int(math.Floor(math.Log(v)/logGrowth))]++
+}
+
+// Count is the number of samples.
+func (h *Histogram) Count() int { return h.n }
+
+// Quantile returns the value below which a share q of the samples lie,
+// by nearest rank, or zero when h is empty.
+func (h *Histogram) Quantile(q float64) float64 {
+	if h.n == 0 {
+		return 0
+	}
+	rank := int(math.Ceil(q * float64(h.n)))
+	rank = min(max(rank, 1), h.n)
+	if rank <= h.zeros {
+		return h.min
+	}
+	seen := h.zeros
+	keys := make([]int, 0, len(h.buckets))
+	for k := range h.buckets {
+		keys = append(keys, k)
+	}
+	sort.Ints(keys)
+	for _, k := range keys {
+		seen += h.buckets[k]
+		if seen >= rank {
+			mid := math.Pow(growth, float64(k)+0.5)
+			return min(max(mid, h.min), h.max)
+		}
+	}
+	return h.max
+}
+
+// Percentiles is the spread of a histogram's samples.
+type Percentiles struct {
+	P1, P25, P50, P75, P99 float64
+}
+
+// Percentiles returns the 1st, 25th, 50th, 75th and 99th percentiles.
+func (h *Histogram) Percentiles() Percentiles {
+	return Percentiles{
+		P1:  h.Quantile(0.01),
+		P25: h.Quantile(0.25),
+		P50: h.Quantile(0.50),
+		P75: h.Quantile(0.75),
+		P99: h.Quantile(0.99),
+	}
+}
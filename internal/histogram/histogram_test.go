@@ -0,0 +1,52 @@
+package histogram
+
+import (
+	"math"
+	"testing"
+)
+
+func TestQuantile(t *testing.T) {
+	var values []float64
+	for i := 1; i <= 1000; i++ {
+		values = append(values, float64(i))
+	}
+	h := Of(values)
+	if h.Count() != 1000 {
+		t.Fatalf("Count = %d", h.Count())
+	}
+	for _, tc := range []struct{ q, want float64 }{
+		{0.01, 10}, {0.25, 250}, {0.5, 500}, {0.75, 750}, {0.99, 990}, {0, 1}, {1, 1000},
+	} {
+		got := h.Quantile(tc.q)
+		if math.Abs(got-tc.want)/tc.want > 0.01 {
+			t.Errorf("Quantile(%v) = %v, want %v within 1%%", tc.q, got, tc.want)
+		}
+	}
+}
+
+func TestQuantileWide(t *testing.T) {
+	// Relative precision holds across orders of magnitude.
+	h := Of([]float64{0.05, 0.05, 4000, 4000})
+	if got := h.Quantile(0.25); math.Abs(got-0.05)/0.05 > 0.01 {
+		t.Errorf("low quantile = %v", got)
+	}
+	if got := h.Quantile(0.75); math.Abs(got-4000)/4000 > 0.01 {
+		t.Errorf("high quantile = %v", got)
+	}
+}
+
+func TestZerosAndEmpty(t *testing.T) {
+	var h Histogram
+	if h.Quantile(0.5) != 0 || h.Percentiles() != (Percentiles{}) {
+		t.Error("empty histogram not zero")
+	}
+	h = *Of([]float64{0, 0, 0, 100})
+	p := h.Percentiles()
+	if p.P1 != 0 || p.P50 != 0 || p.P75 != 0 || math.Abs(p.P99-100) > 1 {
+		t.Errorf("percentiles = %+v", p)
+	}
+	// A single sample is its own every percentile.
+	if p := Of([]float64{42}).Percentiles(); p.P1 != 42 || p.P99 != 42 {
+		t.Errorf("single sample = %+v", p)
+	}
+}
@@ -0,0 +1,69 @@
+package history
+
+import "github.com/tsosunchia/iNetSpeed-CLI/internal/report"
+
+// Metrics compared between runs.
+const (
+	MetricDownload = "download"
+	MetricUpload   = "upload"
+	MetricLatency  = "latency"
+)
+
+// Thresholds are the regressions tolerated before compare fails, in percent:
+// a throughput drop or an idle latency rise larger than this is a regression.
+// Zero disables the check for that metric.
+type Thresholds struct {
+	Download float64
+	Upload   float64
+	Latency  float64
+}
+
+// DefaultThresholds tolerate a 20% throughput drop and a 50% latency rise.
+func DefaultThresholds() Thresholds {
+	return Thresholds{Download: 20, Upload: 20, Latency: 50}
+}
+
+// Delta is the change of one metric relative to the baseline.
+type Delta struct {
+	Metric     string
+	Current    float64 // Mbps, or ms for latency
+	Baseline   float64
+	Change     float64 // percent; negative means the value went down
+	Threshold  float64
+	Regression bool
+}
+
+// Compare returns the deltas of best download, best upload and median idle
+// latency. Metrics missing from either report, e.g. a skipped stage, are
+// left out.
+func Compare(cur, base *report.Report, th Thresholds) []Delta {
+	var out []Delta
+	add := func(metric string, c, b, limit float64, higherIsBetter bool) {
+		if c <= 0 || b <= 0 {
+			return
+		}
+		d := Delta{Metric: metric, Current: c, Baseline: b, Change: (c - b) / b * 100, Threshold: limit}
+		if limit > 0 {
+			if higherIsBetter {
+				d.Regression = d.Change < -limit
+			} else {
+				d.Regression = d.Change > limit
+			}
+		}
+		out = append(out, d)
+	}
+	add(MetricDownload, cur.Best(report.DirDownload), base.Best(report.DirDownload), th.Download, true)
+	add(MetricUpload, cur.Best(report.DirUpload), base.Best(report.DirUpload), th.Upload, true)
+	add(MetricLatency, cur.IdleLatency.MedianMs, base.IdleLatency.MedianMs, th.Latency, false)
+	return out
+}
+
+// Regressed reports whether any delta crossed its threshold.
+func Regressed(deltas []Delta) bool {
+	for _, d := range deltas {
+		if d.Regression {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,194 @@
+// Package history stores past reports as JSON lines and compares a run
+// against a baseline for `speedtest compare`.
+package history
+
+import (
+	"encoding/json"
+	"errors"
+	"io"
+	"os"
+	"path/filepath"
+	"time"
+
+	"github.com/tsosunchia/iNetSpeed-CLI/internal/report"
+)
+
+// ErrEmpty is returned when a history or baseline file holds no usable report.
+var ErrEmpty = errors.New("no previous report")
+
+// DefaultPath is the history file used by compare when HISTORY_FILE is unset:
+// iNetSpeed-CLI/history.jsonl under the user config directory.
+func DefaultPath() (string, error) {
+	dir, err := os.UserConfigDir()
+	if err != nil {
+		return "", err
+	}
+	return filepath.Join(dir, "iNetSpeed-CLI", "history.jsonl"), nil
+}
+
+// Note is a user annotation kept in the history file alongside the reports,
+// e.g. "switched to new router", so later changes in the results can be
+// traced back to it. Notes are skipped wherever reports are read.
+type Note struct {
+	Time time.Time `json:"time"`
+	Text string    `json:"note"`
+}
+
+// Append adds rep to the history file at path as one JSON line, creating the
+// file and its directory as needed.
+func Append(path string, rep *report.Report) error {
+	return appendLine(path, rep)
+}
+
+// AppendNote adds a note to the history file at path, like Append.
+func AppendNote(path string, n Note) error {
+	return appendLine(path, n)
+}
+
+// Notes returns the notes in the history file dated after from and up to
+// to, oldest first. A missing file yields no notes.
+func Notes(path string, from, to time.Time) ([]Note, error) {
+	var out []Note
+	err := scanAll(path, nil, func(n Note) {
+		if n.Time.After(from) && !n.Time.After(to) {
+			out = append(out, n)
+		}
+	})
+	if errors.Is(err, os.ErrNotExist) {
+		return nil, nil
+	}
+	return out, err
+}
+
+func appendLine(path string, v any) error {
+	line, err := json.Marshal(v)
+	if err != nil {
+		return err
+	}
+	if err := os.MkdirAll(filepath.Dir(path), 0o755); err != nil {
+		return err
+	}
+	f, err := os.OpenFile(path, os.O_WRONLY|os.O_CREATE|os.O_APPEND, 0o644)
+	if err != nil {
+		return err
+	}
+	if _, err := f.Write(append(line, '\n')); err != nil {
+		f.Close()
+		return err
+	}
+	return f.Close()
+}
+
+// Latest returns the most recent non-degraded report in the history file
+// whose Simulated flag matches, so emulator runs are never compared with real
+// ones. A missing file is reported as ErrEmpty: there is no previous run yet.
+func Latest(path string, simulated bool) (*report.Report, error) {
+	rep, err := last(path, func(r *report.Report) bool { return !r.Degraded && r.Simulated == simulated })
+	if errors.Is(err, os.ErrNotExist) {
+		return nil, ErrEmpty
+	}
+	return rep, err
+}
+
+// Recent returns up to n of the latest reports in the history file that
+// Latest would consider, oldest first. A missing file yields no reports.
+func Recent(path string, n int, simulated bool) ([]*report.Report, error) {
+	var out []*report.Report
+	err := scan(path, func(r *report.Report) {
+		if r.Degraded || r.Simulated != simulated {
+			return
+		}
+		if out = append(out, r); len(out) > n {
+			out = out[1:]
+		}
+	})
+	if errors.Is(err, os.ErrNotExist) {
+		return nil, nil
+	}
+	return out, err
+}
+
+// Since returns the reports in the history file taken at or after from,
+// oldest first. A missing file yields no reports.
+func Since(path string, from time.Time) ([]*report.Report, error) {
+	var out []*report.Report
+	err := scan(path, func(r *report.Report) {
+		if !r.Time.Before(from) {
+			out = append(out, r)
+		}
+	})
+	if errors.Is(err, os.ErrNotExist) {
+		return nil, nil
+	}
+	return out, err
+}
+
+// Load reads a baseline file: a single JSON report, as written by --share, or
+// a history file, in which case its last entry is used.
+func Load(path string) (*report.Report, error) {
+	return last(path, func(*report.Report) bool { return true })
+}
+
+func last(path string, keep func(*report.Report) bool) (*report.Report, error) {
+	var found *report.Report
+	err := scan(path, func(r *report.Report) {
+		if keep(r) {
+			found = r
+		}
+	})
+	if err != nil {
+		return nil, err
+	}
+	if found == nil {
+		return nil, ErrEmpty
+	}
+	return found, nil
+}
+
+// scan decodes every report in the file at path in order.
+func scan(path string, fn func(*report.Report)) error {
+	return scanAll(path, fn, nil)
+}
+
+// scanAll decodes every entry in the file at path in order, passing reports
+// to onReport and notes to onNote; either may be nil.
+func scanAll(path string, onReport func(*report.Report), onNote func(Note)) error {
+	f, err := os.Open(path)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+
+	dec := json.NewDecoder(f)
+	for {
+		var raw json.RawMessage
+		if err := dec.Decode(&raw); err == io.EOF {
+			return nil
+		} else if err != nil {
+			return err
+		}
+		var kind struct {
+			Note *string `json:"note"`
+		}
+		if err := json.Unmarshal(raw, &kind); err != nil {
+			return err
+		}
+		if kind.Note != nil {
+			if onNote != nil {
+				var n Note
+				if err := json.Unmarshal(raw, &n); err != nil {
+					return err
+				}
+				onNote(n)
+			}
+			continue
+		}
+		if onReport != nil {
+			var r report.Report
+			if err := json.Unmarshal(raw, &r); err != nil {
+				return err
+			}
+			onReport(&r)
+		}
+	}
+}
@@ -0,0 +1,221 @@
+package history
+
+import (
+	"errors"
+	"math"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/tsosunchia/iNetSpeed-CLI/internal/report"
+)
+
+func rep(down, up, lat float64) *report.Report {
+	r := report.New()
+	r.Rounds = []report.Round{
+		{Direction: report.DirDownload, Mbps: down},
+		{Direction: report.DirUpload, Mbps: up},
+	}
+	r.IdleLatency.MedianMs = lat
+	return r
+}
+
+func TestAppendLatest(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "sub", "history.jsonl")
+	if _, err := Latest(path, false); !errors.Is(err, ErrEmpty) {
+		t.Fatalf("Latest on missing file: err = %v, want ErrEmpty", err)
+	}
+
+	degraded := rep(1, 1, 1)
+	degraded.Degraded = true
+	simulated := rep(2, 2, 2)
+	simulated.Simulated = true
+	for _, r := range []*report.Report{rep(100, 10, 5), rep(200, 20, 6), simulated, degraded} {
+		if err := Append(path, r); err != nil {
+			t.Fatal(err)
+		}
+	}
+	got, err := Latest(path, false)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got.Best(report.DirDownload) != 200 {
+		t.Errorf("Latest download = %v, want 200 (degraded and simulated entries skipped)", got.Best(report.DirDownload))
+	}
+	if got, err := Latest(path, true); err != nil || got.Best(report.DirDownload) != 2 {
+		t.Errorf("Latest simulated = %v, %v", got, err)
+	}
+
+	// Load takes the last entry as-is.
+	got, err = Load(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !got.Degraded {
+		t.Error("Load should return the last entry even if degraded")
+	}
+}
+
+func TestLoadSingleReport(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "baseline.json")
+	f, err := os.Create(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := rep(300, 30, 7).WriteJSON(f); err != nil {
+		t.Fatal(err)
+	}
+	f.Close()
+
+	got, err := Load(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got.Best(report.DirUpload) != 30 {
+		t.Errorf("upload = %v, want 30", got.Best(report.DirUpload))
+	}
+	if _, err := Load(filepath.Join(t.TempDir(), "missing.json")); !errors.Is(err, os.ErrNotExist) {
+		t.Errorf("Load missing: err = %v", err)
+	}
+	empty := filepath.Join(t.TempDir(), "empty.json")
+	os.WriteFile(empty, nil, 0o644)
+	if _, err := Load(empty); !errors.Is(err, ErrEmpty) {
+		t.Errorf("Load empty: err = %v, want ErrEmpty", err)
+	}
+	bad := filepath.Join(t.TempDir(), "bad.json")
+	os.WriteFile(bad, []byte("{not json"), 0o644)
+	if _, err := Load(bad); err == nil {
+		t.Error("Load should fail on malformed JSON")
+	}
+}
+
+func TestCompare(t *testing.T) {
+	base := rep(1000, 100, 10)
+	cur := rep(770, 95, 16)
+	cur.Rounds = append(cur.Rounds, report.Round{Direction: report.DirDownload, Mbps: 500})
+
+	deltas := Compare(cur, base, DefaultThresholds())
+	if len(deltas) != 3 {
+		t.Fatalf("got %d deltas, want 3", len(deltas))
+	}
+	want := []struct {
+		metric     string
+		change     float64
+		regression bool
+	}{
+		{MetricDownload, -23, true},
+		{MetricUpload, -5, false},
+		{MetricLatency, 60, true},
+	}
+	for i, w := range want {
+		d := deltas[i]
+		if d.Metric != w.metric || math.Abs(d.Change-w.change) > 1e-9 || d.Regression != w.regression {
+			t.Errorf("delta %d = %+v, want %s %.0f%% regression=%v", i, d, w.metric, w.change, w.regression)
+		}
+	}
+	if !Regressed(deltas) {
+		t.Error("Regressed = false")
+	}
+
+	// A zero threshold disables the check; missing metrics are skipped.
+	cur.IdleLatency.MedianMs = 0
+	deltas = Compare(cur, base, Thresholds{Upload: 1})
+	if len(deltas) != 2 || !Regressed(deltas) {
+		t.Errorf("deltas = %+v", deltas)
+	}
+	if deltas[0].Regression {
+		t.Error("download check should be disabled")
+	}
+}
+
+func TestRecent(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "history.jsonl")
+	if got, err := Recent(path, 3, false); err != nil || len(got) != 0 {
+		t.Fatalf("Recent on missing file = %v, %v", got, err)
+	}
+	degraded := rep(1, 1, 1)
+	degraded.Degraded = true
+	for _, r := range []*report.Report{rep(100, 10, 5), rep(200, 20, 6), degraded, rep(300, 30, 7), rep(400, 40, 8)} {
+		if err := Append(path, r); err != nil {
+			t.Fatal(err)
+		}
+	}
+	got, err := Recent(path, 3, false)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var downs []float64
+	for _, r := range got {
+		downs = append(downs, r.Best(report.DirDownload))
+	}
+	if len(downs) != 3 || downs[0] != 200 || downs[2] != 400 {
+		t.Errorf("Recent downloads = %v, want [200 300 400]", downs)
+	}
+}
+
+func TestSince(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "history.jsonl")
+	if got, err := Since(path, time.Time{}); err != nil || len(got) != 0 {
+		t.Fatalf("Since on missing file = %v, %v", got, err)
+	}
+	t0 := time.Date(2026, 5, 1, 12, 0, 0, 0, time.UTC)
+	for i, down := range []float64{100, 200, 300} {
+		r := rep(down, 10, 5)
+		r.Time = t0.Add(time.Duration(i) * time.Hour)
+		if err := Append(path, r); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := AppendNote(path, Note{Time: t0.Add(2 * time.Hour), Text: "new router"}); err != nil {
+		t.Fatal(err)
+	}
+	got, err := Since(path, t0.Add(time.Hour))
+	if err != nil || len(got) != 2 || got[0].Best(report.DirDownload) != 200 || got[1].Best(report.DirDownload) != 300 {
+		t.Errorf("Since = %v, %v", got, err)
+	}
+}
+
+func TestNotes(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "history.jsonl")
+	if got, err := Notes(path, time.Time{}, time.Now()); err != nil || len(got) != 0 {
+		t.Fatalf("Notes on missing file = %v, %v", got, err)
+	}
+	t0 := time.Date(2026, 5, 1, 12, 0, 0, 0, time.UTC)
+	old := rep(100, 10, 5)
+	old.Time = t0
+	for _, e := range []any{
+		old,
+		Note{Time: t0.Add(time.Hour), Text: "switched to new router"},
+		Note{Time: t0.Add(2 * time.Hour), Text: "moved the AP"},
+	} {
+		var err error
+		switch v := e.(type) {
+		case *report.Report:
+			err = Append(path, v)
+		case Note:
+			err = AppendNote(path, v)
+		}
+		if err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	got, err := Notes(path, t0, t0.Add(90*time.Minute))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(got) != 1 || got[0].Text != "switched to new router" || !got[0].Time.Equal(t0.Add(time.Hour)) {
+		t.Errorf("Notes = %+v", got)
+	}
+	// Notes are not reports: the last report is still the latest entry.
+	if r, err := Latest(path, false); err != nil || !r.Time.Equal(t0) {
+		t.Errorf("Latest = %v, %v", r, err)
+	}
+	if r, err := Load(path); err != nil || r.Best(report.DirDownload) != 100 {
+		t.Errorf("Load = %v, %v", r, err)
+	}
+	if reps, err := Recent(path, 5, false); err != nil || len(reps) != 1 {
+		t.Errorf("Recent = %v, %v", reps, err)
+	}
+}
@@ -0,0 +1,630 @@
+package i18n
+
+// jaCatalog holds Japanese translations keyed by the English source text.
+// Messages missing here are shown in English.
+var jaCatalog = map[string]string{
+	// cmd/speedtest
+	"speedtest %s (commit %s, built %s)\n": "speedtest %s（コミット %s、ビルド %s）\n",
+
+	// config
+	"unexpected argument(s): %s":                        "不明な引数: %s",
+	"invalid MAX %q: %w":                                "MAX の値が不正です %q: %w",
+	"MAX must be > 0":                                   "MAX は 0 より大きい必要があります",
+	"TIMEOUT must be > 0":                               "TIMEOUT は 0 より大きい必要があります",
+	"THREADS must be > 0":                               "THREADS は 0 より大きい必要があります",
+	"LATENCY_COUNT must be > 0":                         "LATENCY_COUNT は 0 より大きい必要があります",
+	"TIMEOUT must be <= 120":                            "TIMEOUT は 120 以下である必要があります",
+	"THREADS must be <= 64":                             "THREADS は 64 以下である必要があります",
+	"LATENCY_COUNT must be <= 100":                      "LATENCY_COUNT は 100 以下である必要があります",
+	"invalid UPLOAD_PAYLOAD: %w":                        "UPLOAD_PAYLOAD の値が不正です: %w",
+	"--share requires SHARE_URL or GITHUB_TOKEN":        "--share には SHARE_URL または GITHUB_TOKEN が必要です",
+	"SHARE_URL must start with http(s)://":              "SHARE_URL は http(s):// で始まる必要があります",
+	"%s must start with http(s)://":                     "%s は http(s):// で始まる必要があります",
+	"unknown phase %q (valid: %s)":                      "不明なフェーズ %q（有効な値: %s）",
+	"unknown stage %q (valid: %s)":                      "不明なステージ %q（有効な値: %s）",
+	"invalid stage timeout %q, want stage=duration":     "ステージタイムアウトの形式が不正です %q（ステージ=時間 の形式で指定）",
+	"invalid timeout for stage %s: %q":                  "ステージ %s のタイムアウトが不正です: %q",
+	"timeout=%ds  max=%s  threads=%d  latency_count=%d": "タイムアウト=%ds  上限=%s  スレッド=%d  遅延サンプル=%d",
+	"payload":                                "ペイロード",
+	"rate":                                   "速度制限",
+	"total":                                  "総量上限",
+	"stop on saturation":                     "飽和で停止",
+	"config":                                 "設定ファイル",
+	"baseline":                               "ベースライン",
+	"phases":                                 "フェーズ",
+	"connections":                            "接続",
+	"invalid CONNECTION_MODE %q (valid: %s)": "CONNECTION_MODE の値が不正です %q（有効な値: %s）",
+	"invalid header %q (want \"Name: value\")":                             "ヘッダーが不正です %q（\"Name: value\" の形式で指定してください）",
+	"invalid UPLOAD_METHOD %q (valid: %s)":                                 "UPLOAD_METHOD の値が不正です %q（有効な値: %s）",
+	"fixed-length uploads":                                                 "固定長アップロード",
+	"invalid DOWNLOAD_MODE %q (valid: %s)":                                 "DOWNLOAD_MODE の値が不正です %q（有効な値: %s）",
+	"range downloads":                                                      "Range ダウンロード",
+	"invalid PRESCREEN %q (valid: %s)":                                     "PRESCREEN の値が不正です %q（有効な値: %s）",
+	"invalid PREFER_COUNTRY %q (a two-letter ISO code such as JP)":         "PREFER_COUNTRY の値が不正です %q（JP のような 2 文字の ISO コード）",
+	"invalid OVERHEAD_MODEL %q (valid: %s)":                                "OVERHEAD_MODEL の値が不正です %q（有効な値: %s）",
+	"invalid PRESET %q (valid: %s)":                                        "PRESET の値が不正です %q（有効な値: %s）",
+	"invalid UDP_ECHO %q, want host:port":                                  "UDP_ECHO の値が不正です %q（host:port 形式で指定してください）",
+	"note requires the text to record":                                     "note には記録する内容が必要です",
+	"no history location, set HISTORY_FILE: %v":                            "履歴ファイルの場所を特定できません。HISTORY_FILE を設定してください: %v",
+	"invalid DSCP %q (valid: EF, VA, LE, CS0-CS7, AF11-AF43 or 0-63)":      "DSCP の値が不正です %q（有効な値: EF、VA、LE、CS0-CS7、AF11-AF43 または 0-63）",
+	"--dscp is not supported on this platform":                             "このプラットフォームでは --dscp を使用できません",
+	"invalid SERVER_LISTEN %q, want [host]:port":                           "SERVER_LISTEN の値が不正です %q（[host]:port 形式で指定してください）",
+	"assertion limits must not be negative":                                "アサーションのしきい値は負にできません",
+	"invalid WIDGET_MAX_AGE %q":                                            "WIDGET_MAX_AGE の値が不正です %q",
+	"RUNS must be between 1 and 100, or 0 to run until interrupted":        "RUNS は 1 から 100 の範囲、または中断まで続ける場合は 0 を指定してください",
+	"until interrupted":                                                    "中断まで",
+	"invalid INTERVAL %q (at least %s)":                                    "INTERVAL の値が不正です %q（%s 以上）",
+	"invalid LATENCY_INTERVAL %q":                                          "LATENCY_INTERVAL の値が不正です %q",
+	"invalid LATENCY_PROBE_SIZE %q":                                        "LATENCY_PROBE_SIZE の値が不正です %q",
+	"invalid LATENCY_CONN %q (valid: %s)":                                  "LATENCY_CONN の値が不正です %q（有効な値: %s）",
+	"probe interval":                                                       "プローブ間隔",
+	"probe size":                                                           "プローブサイズ",
+	"probe connections":                                                    "プローブ接続",
+	"invalid RUN_COOLDOWN %q":                                              "RUN_COOLDOWN の値が不正です %q",
+	"--runs cannot be combined with --widget":                              "--runs は --widget と併用できません",
+	"--runs cannot be combined with --compare-ip-versions":                 "--runs は --compare-ip-versions と併用できません",
+	"--compare-ip-versions cannot be combined with --simulate or --widget": "--compare-ip-versions は --simulate や --widget と併用できません",
+	"--compare-ip-versions needs the endpoint stage":                       "--compare-ip-versions には endpoint ステージが必要です",
+	"runs":   "回数",
+	"preset": "プリセット",
+	"--widget cannot be used with the %s command":            "--widget は %s コマンドと併用できません",
+	"no history location for --widget, set HISTORY_FILE: %v": "--widget の履歴ファイルの場所を決定できません。HISTORY_FILE を設定してください: %v",
+	"invalid CACERT: %w":                                                      "CACERT が不正です: %w",
+	"invalid CACERT: no PEM certificates in %s":                               "CACERT が不正です: %s に PEM 証明書がありません",
+	"TLS_CERT and TLS_KEY must be set together":                               "TLS_CERT と TLS_KEY は同時に指定する必要があります",
+	"invalid client certificate: %w":                                          "クライアント証明書が不正です: %w",
+	"insecure":                                                                "証明書未検証",
+	"--%s is only valid with the compare command":                             "--%s は compare コマンドでのみ使用できます",
+	"--%s is only valid with the register command":                            "--%s は register コマンドでのみ使用できます",
+	"--%s is only valid with the remote command":                              "--%s は remote コマンドでのみ使用できます",
+	"--%s is only valid with the server command":                              "--%s は server コマンドでのみ使用できます",
+	"remote requires at least one host":                                       "remote には少なくとも 1 つのホストが必要です",
+	"REMOTE_SSH must not be empty":                                            "REMOTE_SSH は空にできません",
+	"%s: options must come before the hosts or after --":                      "%s: オプションはホストの前か -- の後に指定してください",
+	"register requires --collector or COLLECTOR_URL":                          "register には --collector または COLLECTOR_URL が必要です",
+	"COLLECTOR_URL must start with http(s)://":                                "COLLECTOR_URL は http(s):// で始まる必要があります",
+	"no probe state location, set PROBE_STATE":                                "プローブ状態ファイルの場所を決定できません。PROBE_STATE を指定してください",
+	"no history location, set HISTORY_FILE or --baseline: %v":                 "履歴ファイルの場所を決定できません。HISTORY_FILE または --baseline を指定してください: %v",
+	"invalid COMPARE_THRESHOLDS %s=%q":                                        "COMPARE_THRESHOLDS の値が不正です %s=%q",
+	"unknown COMPARE_THRESHOLDS metric %q (valid: %s)":                        "COMPARE_THRESHOLDS の不明な指標 %q（有効な値: %s）",
+	"--quiet and --verbose cannot be combined":                                "--quiet と --verbose は同時に指定できません",
+	"--quiet and --tui cannot be combined":                                    "--quiet と --tui は同時に指定できません",
+	"invalid LIMIT_RATE %q":                                                   "LIMIT_RATE の値が不正です %q",
+	"invalid MAX_TOTAL %q":                                                    "MAX_TOTAL の値が不正です %q",
+	"invalid SATURATION_WINDOW %q (at least %s)":                              "SATURATION_WINDOW の値が不正です %q（%s 以上）",
+	"cannot read config file: %w":                                             "設定ファイルを読み込めません: %w",
+	"invalid config file %s: %w":                                              "設定ファイル %s の形式が不正です: %w",
+	"config file: unknown stage %q (valid: download, upload, %s, %s, %s, %s)": "設定ファイル: 不明なステージ %q（有効な値: download, upload, %s, %s, %s, %s）",
+	"config file: stage %s threads must be 1-64":                              "設定ファイル: ステージ %s の threads は 1-64 で指定してください",
+	"config file: stage %s is single-threaded":                                "設定ファイル: ステージ %s はシングルスレッドです",
+	"config file: stage %s timeout must be 1-120":                             "設定ファイル: ステージ %s の timeout は 1-120 で指定してください",
+	"config file: stage %s max %q: %w":                                        "設定ファイル: ステージ %s の max %q が不正です: %w",
+
+	"--demo cannot be used with the %s command": "--demo は %s コマンドと併用できません",
+	"--demo cannot be combined with --widget":   "--demo は --widget と併用できません",
+
+	// demo
+	"Cannot read the demo recording: %v":                        "デモの記録を読み込めません: %v",
+	"Demo mode: replaying a recorded run; nothing is measured.": "デモモード: 記録済みの測定を再生しています。実際の測定は行いません。",
+
+	// request rate
+	"TTFB: p50 %.2f / p90 %.2f / p99 %.2f ms  (min %.2f, max %.2f)": "最初のバイトまで: p50 %.2f / p90 %.2f / p99 %.2f ms  (最小 %.2f、最大 %.2f)",
+
+	// CDN node
+	"Served by":         "配信ノード",
+	"Served by %s (%s)": "配信ノード %s（%s）",
+
+	// auxiliary lookups
+	"DoH disabled; resolving with system DNS.": "DoH を無効化しました。システム DNS で解決します。",
+	"Geo lookups disabled.":                    "位置情報の照会を無効化しました。",
+	"Resolving with DNS server %s.":            "DNS サーバー %s で解決します。",
+
+	"invalid DNS_SERVER %q (an IP address, optionally with a port)": "DNS_SERVER の値が不正です %q（IP アドレス、ポートは省略可）",
+
+	// latency targets
+	"Latency Targets":                                               "レイテンシ計測先",
+	"Cannot ping latency target %s: %s":                             "レイテンシ計測先 %s に ping できません: %s",
+	"invalid latency target %q, want an IP, a host name or gateway": "レイテンシ計測先の値が不正です %q（IP アドレス、ホスト名または gateway を指定してください）",
+	"unreachable":            "到達不能",
+	"idle %s  ↓ %s  ↑ %s ms": "アイドル %s  ↓ %s  ↑ %s ms",
+	"%s: %.2f ms median  (min %.2f / max %.2f)  loss %.0f%%":                                                                             "%s: 中央値 %.2f ms  (最小 %.2f / 最大 %.2f)  損失 %.0f%%",
+	"Latency to the gateway rises %.0f ms under load: the queue filling up is on the LAN or in the router.":                              "負荷時にゲートウェイまでのレイテンシが %.0f ms 上昇します。キューの滞留は LAN 内またはルーターで起きています。",
+	"Latency to %s, the nearest target affected, rises %.0f ms under load: the queue filling up is on the path to it.":                   "影響を受ける最も近い計測先 %s までのレイテンシが負荷時に %.0f ms 上昇します。キューの滞留はそこまでの経路上で起きています。",
+	"Latency to every target stays flat under load while the CDN's rises %.0f ms: the queue filling up is beyond them, on the CDN path.": "負荷時も各計測先までのレイテンシは安定していますが、CDN までは %.0f ms 上昇します。キューの滞留はそれらより先の CDN 経路上で起きています。",
+
+	// progress
+	"ETA %ds":      "残り %d 秒",
+	"ETA %dm%02ds": "残り %d 分 %02d 秒",
+
+	// percentiles
+	"Throughput p1/p25/p50/p75/p99: %s / %s / %s / %s / %s %s":               "スループット p1/p25/p50/p75/p99: %s / %s / %s / %s / %s %s",
+	"Loaded latency p1/p25/p50/p75/p99: %.1f / %.1f / %.1f / %.1f / %.1f ms": "負荷時遅延 p1/p25/p50/p75/p99: %.1f / %.1f / %.1f / %.1f / %.1f ms",
+	"%d micro-stalls: 100 ms slices below a tenth of the median throughput.": "%d 回のマイクロストール: スループットが中央値の 10 分の 1 を下回った 100 ms 区間です。",
+
+	// range downloads
+	"Range requests unavailable (%v); downloading whole objects.": "Range リクエストを使用できません（%v）。オブジェクト全体をダウンロードします。",
+	"Range mode: %s object, %s per request":                       "Range モード: オブジェクト %s、リクエストごとに %s",
+
+	// interface counters
+	"Cannot read the counters of %s: %v": "%s のカウンタを読み取れません: %v",
+	"%s carried %.2f× the test's bytes: other traffic shared the interface and may have lowered the result.": "%s はテストのバイト数の %.2f 倍を転送しました: 他の通信がインターフェースを共有しており、結果が低く出た可能性があります。",
+	"%s carried only %.2f× the test's bytes: the test likely went through another interface (VPN or proxy).": "%s はテストのバイト数の %.2f 倍しか転送していません: テストは別のインターフェース（VPN やプロキシ）を経由した可能性があります。",
+	"Interface %s: %s, %.1f%% protocol overhead":                                                             "インターフェース %s: %s、プロトコルオーバーヘッド %.1f%%",
+
+	// system info
+	"System":                "システム",
+	"Interface":             "インターフェース",
+	"Link Speed":            "リンク速度",
+	"Gateway":               "ゲートウェイ",
+	"unknown":               "不明",
+	"Local Link":            "ローカルリンク",
+	" (noise %d)":           "（ノイズ %d）",
+	"ch ":                   "チャンネル ",
+	"%s, Wi-Fi PHY %g Mbps": "%s、Wi-Fi PHY %g Mbps",
+	"%s, %d Mbps":           "%s、%d Mbps",
+	"Link details are not available on this platform.": "このプラットフォームではリンク情報を取得できません。",
+	"Cannot read the link of %s: %v":                   "%s のリンク情報を読み取れません: %v",
+	"Throughput of %s exceeds the Wi-Fi PHY rate of %g Mbps read before the test; Wi-Fi lowers the rate while idle.":                    "スループット %s はテスト前に読み取った Wi-Fi PHY レート %g Mbps を上回っています。Wi-Fi はアイドル時にレートを下げます。",
+	"Throughput of %s is close to what the %g Mbps local link carries: the link to this device, not the ISP, is likely the bottleneck.": "スループット %s は %g Mbps のローカルリンクの上限に近づいています。ボトルネックは ISP ではなく、この端末までのリンクである可能性が高いです。",
+
+	// diagnostics
+	"Diagnostics":        "診断",
+	"during download":    "（ダウンロード中）",
+	"during upload":      "（アップロード中）",
+	"connection reset":   "接続リセット",
+	"read timeout":       "読み取りタイムアウト",
+	"HTTP error status":  "HTTP エラーステータス",
+	"TLS error":          "TLS エラー",
+	"DNS failure":        "DNS 解決失敗",
+	"connection refused": "接続拒否",
+	"network error":      "ネットワークエラー",
+	"the path or a middlebox dropped connections mid-transfer":        "経路上または中間装置が転送途中で接続を切断しました",
+	"possible ISP upload policing":                                    "ISP による上りのポリシングの可能性があります",
+	"the path stalled, as on a congested or lossy link":               "輻輳やパケットロスの多いリンクのように経路が停滞しました",
+	"the server rejected or throttled requests; check the test URL":   "サーバーがリクエストを拒否または制限しました。テスト URL を確認してください",
+	"a proxy may be intercepting HTTPS, or the system clock is wrong": "プロキシが HTTPS を傍受しているか、システム時刻が正しくない可能性があります",
+	"the resolver is unreliable or blocks the test host":              "DNS リゾルバが不安定か、テストホストをブロックしています",
+	"a firewall or the endpoint rejects new connections":              "ファイアウォールまたはエンドポイントが新しい接続を拒否しています",
+	"run with --verbose to see each request's error":                  "--verbose で各リクエストのエラーを確認してください",
+
+	// TLS
+	"  (%d connections, %d resumed)":     "  (%d 接続、うち %d 件はセッション再開)",
+	"Certificate %s issued by %s for %s": "証明書 %s（発行者 %s、対象 %s）",
+	"The certificate was issued by %s, which the system does not trust: a middlebox may be intercepting TLS, and such boxes often lower throughput too.": "証明書はシステムが信頼していない %s によって発行されています。中間装置が TLS を傍受している可能性があり、そうした装置はスループットも低下させがちです。",
+	"issued by %s": "発行者 %s",
+
+	// data budget
+	"Data budget: %s of %s left":                 "データ予算: 残り %s（%s 中）",
+	"  (%s on the wire with probes and framing)": "  (プローブとプロトコルのオーバーヘッドを含め %s)",
+	"invalid DATA_BUDGET %q":                     "DATA_BUDGET の値が不正です %q",
+	"budget":                                     "データ予算",
+
+	// latency filtering
+	"Dropped %d warm-up and %d outlier samples; raw: avg %.2f / max %.2f  jitter %.2f ms": "ウォームアップ %d 件と外れ値 %d 件を除外しました。除外前: 平均 %.2f / 最大 %.2f  ジッター %.2f ms",
+
+	// cache check
+	"Transparent Cache Check": "透過キャッシュの検出",
+	"Cache check failed: %v":  "キャッシュ検出に失敗しました: %v",
+	"A cache on the path (%s) answered instead of the CDN: the download results measure the way to that cache, not to the CDN.":                                               "経路上のキャッシュ（%s）が CDN の代わりに応答しました。ダウンロード結果は CDN ではなくそのキャッシュまでの経路を測っています。",
+	"Plain requests were answered from a cache without any CDN marker in %.1f ms, cache-busted ones in %.1f ms: a transparent cache on the path likely serves the downloads.": "通常のリクエストは CDN の目印を持たないキャッシュから %.1f ms で、キャッシュ回避リクエストは %.1f ms で応答されました。経路上の透過キャッシュがダウンロードを提供している可能性があります。",
+	"Requests pass through a proxy (%s), which did not answer from a cache.":                                                                                                  "リクエストはプロキシ（%s）を経由していますが、キャッシュからの応答ではありませんでした。",
+	"No transparent cache: first byte in %.1f ms, %.1f ms with cache busting":                                                                                                 "透過キャッシュなし: 最初のバイトまで %.1f ms、キャッシュ回避時 %.1f ms",
+	"Cache-busted requests were answered from a cache too: the cache ignores query strings.":                                                                                  "キャッシュ回避リクエストもキャッシュから応答されました。このキャッシュはクエリ文字列を無視しています。",
+	"plain":                           "通常",
+	"cache-busted":                    "キャッシュ回避",
+	"%s: HTTP %d, first byte %.1f ms": "%s: HTTP %d、最初のバイトまで %.1f ms",
+	"Transparent Cache":               "透過キャッシュ",
+	"suspected":                       "疑いあり",
+
+	// check
+	"--dry-run cannot be used with the %s command":     "--dry-run は %s コマンドと併用できません",
+	"check cannot be combined with --demo or --widget": "check は --demo や --widget と併用できません",
+	"Configuration is valid.":                          "設定は有効です。",
+	"URL Check":                                        "URL チェック",
+	"%s URL unreachable: %v":                           "%s URL に到達できません: %v",
+	"%s HTTP %d, first byte %.1f ms":                   "%s HTTP %d、最初のバイトまで %.1f ms",
+	"%d of %d test URLs cannot be reached.":            "測定 URL %d 件（全 %d 件中）に到達できません。",
+	"All test URLs are reachable.":                     "すべての測定 URL に到達できます。",
+
+	// API
+	"--api requires --runs of 2 or more, or 0":                                      "--api には 2 以上の --runs が必要です",
+	"invalid API_LISTEN %q, want [host]:port":                                       "API_LISTEN の値が不正です %q（[host]:port 形式で指定してください）",
+	"API_LISTEN %q is not a loopback address; set API_TOKEN to serve the API on it": "API_LISTEN %q はループバックアドレスではありません。このアドレスで API を提供するには API_TOKEN を設定してください",
+	"API listening on http://%s":                                                    "API は http://%s で待ち受け中",
+	"API: starting the next run now.":                                               "API: 次の測定を今すぐ開始します。",
+	"Cannot start the API: %v":                                                      "API を開始できません: %v",
+
+	// region
+	"invalid REGION %q (valid: %s, or a subnet such as 203.0.113.0/24)":        "REGION の値が不正です %q（有効な値: %s、または 203.0.113.0/24 のようなサブネット）",
+	"--region resolves over DoH and cannot be combined with --no-doh or --dns": "--region は DoH で解決するため、--no-doh や --dns と併用できません",
+	"region": "地域",
+	"Resolving as a client in %s (EDNS client subnet %s).": "%s のクライアントとして解決します（EDNS クライアントサブネット %s）。",
+	"DoH returned no endpoint for %s.":                     "DoH は %s のエンドポイントを返しませんでした。",
+
+	// cold start
+	"Cold vs Warm Connection":                    "新規接続と再利用接続の比較",
+	"Cold-start test failed: %v":                 "新規接続テストに失敗しました: %v",
+	"New connection":                             "新規接続",
+	"Warm connection":                            "再利用接続",
+	"%s in the first %.0f s, first byte %.1f ms": "%s（最初の %.0f 秒）、最初のバイトまで %.1f ms",
+	"The warm fetch did not reuse the warmed connection; both fetches started cold.": "再利用側の取得がウォームアップ済みの接続を再利用しなかったため、どちらも新規接続から始まりました。",
+	"Slow-start penalty: %.0f%% less data in the first %.0f s on a new connection":   "スロースタートの損失: 新規接続では転送量が %.0f%% 少なくなりました（最初の %.0f 秒）",
+	"No slow-start penalty: a new connection is as fast as a warm one.":              "スロースタートの損失なし: 新規接続も再利用接続と同じ速さです。",
+	"The new connection reached %.0f%% of the warm rate after %.1f s.":               "新規接続は再利用接続の速度の %.0f%% に %.1f 秒で達しました。",
+	"The new connection did not reach %.0f%% of the warm rate within %.0f s.":        "新規接続は再利用接続の速度の %.0f%% に達しませんでした（%.0f 秒以内）。",
+	"Cold Start":                        "新規接続",
+	"%s vs %s warm in the first %.0f s": "%s、再利用接続 %s（最初の %.0f 秒）",
+
+	// self-update
+	"Self-Update":     "自動更新",
+	"Current Version": "現在のバージョン",
+	"Latest Release":  "最新リリース",
+	"Could not look up the latest release: %v": "最新リリースを確認できませんでした: %v",
+	"Already up to date.":                      "すでに最新です。",
+	"Cannot locate this binary: %v":            "実行中のバイナリが見つかりません: %v",
+	"Downloading %s ...":                       "%s をダウンロードしています...",
+	"Download failed: %v":                      "ダウンロードに失敗しました: %v",
+	"SHA-256 checksum verified.":               "SHA-256 チェックサムを確認しました。",
+	"Could not replace %s: %v":                 "%s を置き換えられませんでした: %v",
+	"Updated %s to %s.":                        "%s を %s に更新しました。",
+
+	// request signing
+	"invalid REQUEST_SIGN: %v": "REQUEST_SIGN が無効です: %v",
+
+	// thread ramp
+	"invalid THREAD_SCHEDULE step %q, want THREADS@OFFSET with 1 to %d threads": "THREAD_SCHEDULE のステップ %q が無効です。スレッド数@オフセットの形式で、スレッド数は 1 から %d です",
+	"THREAD_SCHEDULE must start at 0s and rise in both threads and offset: %q":  "THREAD_SCHEDULE は 0s から始まり、スレッド数とオフセットがともに増えていく必要があります: %q",
+	"THREAD_SCHEDULE must not run longer than %v":                               "THREAD_SCHEDULE の合計時間は %v を超えられません",
+	"Download · Thread Ramp":                                                    "ダウンロード · スレッド段階",
+	"Upload · Thread Ramp":                                                      "アップロード · スレッド段階",
+	"Schedule: %s over %.0fs":                                                   "スケジュール: %s、計 %.0f 秒",
+	"Limit: %s per thread":                                                      "上限: スレッドあたり %s",
+	"%d threads":                                                                "%d スレッド",
+	"Throughput per second: ":                                                   "毎秒のスループット: ",
+	"Thread Ramp":                                                               "スレッド段階",
+
+	// url failover
+	"%s  (HTTP %d, first byte %.1f ms)":              "%s  (HTTP %d、最初のバイト %.1f ミリ秒)",
+	"%s URL %s failed (%s) and no fallback is left.": "%s URL %s が失敗しました（%s）。残りの予備 URL はありません。",
+	"%s URL %s failed (%s); switching to %s.":        "%s URL %s が失敗しました（%s）。%s に切り替えます。",
+	"Failover": "URL 切り替え",
+
+	// units
+	"invalid UNITS %q (valid: %s)": "UNITS の値が無効です %q（有効な値: %s）",
+	"  delivery %s":                "  配信レート %s",
+
+	// run tags
+	"invalid tag %q (want KEY=VALUE, the key of letters, digits and _)": "タグが不正です %q（KEY=VALUE の形式で、キーは英数字と _ で指定してください）",
+	"too many tags: %d (at most %d)":                                    "タグが多すぎます: %d（最大 %d）",
+	"Tags:    ":                                                         "タグ:  ",
+	"Run ID:  ":                                                         "測定 ID: ",
+
+	// TCP info
+	"TCP info: not available on this platform.":           "TCP 統計: このプラットフォームでは利用できません。",
+	"TCP %s  rtt %.2f ms (var %.2f)  retrans %d  cwnd %s": "TCP %s  RTT %.2f ms (変動 %.2f)  再送 %d  輻輳ウィンドウ %s",
+
+	// emulator
+	"simulate": "シミュレーション",
+	"unknown SIMULATE_OPTS key %q (valid: %s)": "SIMULATE_OPTS のキーが不明です %q（有効なキー: %s）",
+	"invalid SIMULATE_OPTS %s=%q: %v":          "SIMULATE_OPTS の値が不正です %s=%q: %v",
+
+	// latency by flows
+	"Loaded latency by flows: ": "フロー数別の負荷時遅延: ",
+
+	// line rate
+	"Line Rate (est.)":                 "回線速度（推定）",
+	"  (%s, MTU %d, +%.1f%% overhead)": "  (%s、MTU %d、オーバーヘッド +%.1f%%)",
+	"Goodput is the HTTP payload the rounds moved; the line rate adds the TCP/IP headers, TLS records, HTTP/2 frames and link framing it took.": "グッドプットは各ラウンドが転送した HTTP ペイロードです。回線速度はそれに要した TCP/IP ヘッダー、TLS レコード、HTTP/2 フレーム、リンク層フレームを加えたものです。",
+	"link": "リンク",
+
+	// upload verification
+	"Upload Verification": "アップロード検証",
+	"Sending %s in one request and waiting for the server's answer.": "%s を 1 回のリクエストで送信し、サーバーの応答を待ちます。",
+	"Upload verification failed: %v":                                 "アップロード検証に失敗しました: %v",
+	"The server answered the upload with HTTP %d: it did not accept the data, so the upload results may count data it discarded.":                 "サーバーはアップロードに HTTP %d で応答しました。データは受け付けられておらず、アップロード結果は破棄されたデータを含んでいる可能性があります。",
+	"The server acknowledged %s of the %s sent: the upload results count data it never received.":                                                 "サーバーが受信を確認したのは %s だけです（送信量 %s）。アップロード結果はサーバーに届かなかったデータを含んでいます。",
+	"The server confirmed receipt at %s, %.0f%% of the %s the client wrote at: the rest sat in buffers on the way, inflating the upload results.": "サーバーが受信を確認した速度は %s で、クライアントの書き込み速度の %.0f%%（%s）です。残りは途中のバッファに滞留しており、アップロード結果が過大になっています。",
+	"Server acknowledged %s at %s  (HTTP %d)":                  "サーバーが %s の受信を確認しました。速度 %s  (HTTP %d)",
+	"Server answered after %s at %s  (HTTP %d, no byte count)": "サーバーは %s の受信後に応答しました。速度 %s  (HTTP %d、バイト数の報告なし)",
+
+	// config discovery
+	"Config Discovery": "設定の取得",
+	"Config discovery failed, keeping the configured URLs: %v": "設定の取得に失敗しました。設定済みのテスト URL を使用します: %v",
+	"Test URLs from ":                   "テスト URL の取得元: ",
+	"Same test URLs as run 1.":          "1 回目と同じテスト URL を使用します。",
+	"Test endpoint assigned by Apple: ": "Apple が割り当てたテストエンドポイント: ",
+	"Test Endpoint":                     "テストエンドポイント",
+
+	// endpoint
+	"Endpoint Selection": "エンドポイント選択",
+	"Could not parse host from DL_URL. Skip endpoint selection.": "DL_URL からホストを解析できません。エンドポイント選択をスキップします。",
+	"Host: ": "ホスト: ",
+	"Dual DoH (CF + Ali) both timed out. Fallback to system DNS.": "デュアル DoH（CF + Ali）が両方ともタイムアウトしました。システム DNS にフォールバックします。",
+	"system DNS fallback":                                          "システム DNS フォールバック",
+	"%s has no IPv%d address.":                                     "%s には IPv%d アドレスがありません。",
+	"Selected endpoint: ":                                          "選択したエンドポイント: ",
+	"Selected endpoint: %s (%s)":                                   "選択したエンドポイント: %s (%s)",
+	"Could not resolve endpoint IP, continue with default DNS.":    "エンドポイントの IP を解決できません。既定の DNS で続行します。",
+	"Dual DoH returned no endpoint, continue with default DNS.":    "デュアル DoH がエンドポイントを返しませんでした。既定の DNS で続行します。",
+	"Available endpoints:":                                         "利用可能なエンドポイント:",
+	"Available endpoints (median %s connect time of %d attempts):": "利用可能なエンドポイント（%s 接続時間、%d 回の中央値）:",
+	"timeout":          "タイムアウト",
+	"lookup failed":    "照会失敗",
+	"unknown location": "不明な場所",
+	"unknown AS":       "不明な AS",
+
+	// per-ASN comparison
+	"--per-asn: the endpoint candidates belong to a single AS, nothing to compare.": "--per-asn: エンドポイント候補はすべて同じ AS に属しているため、比較対象がありません。",
+	"Per-ASN Comparison": "AS 別の比較",
+	"%d ASes, %d latency probes and a %ds download each": "%d 個の AS、それぞれ遅延 %d 回と %d 秒のダウンロード",
+	"%s  %.1f ms  (%s)": "%s  %.1f ms  (%s)",
+	"%s outperforms Apple's own PoPs (%s) by %.0f%%.": "%s は Apple 自身の PoP（%s）より %.0f%% 高速です。",
+	"Fastest: %s":                       "最速: %s",
+	"Select endpoint [1-%d, Enter=1]: ": "エンドポイントを選択 [1-%d、Enter=1]: ",
+	"Interactive input unavailable, defaulting to endpoint 1.": "対話入力が利用できないため、エンドポイント 1 を使用します。",
+	"Invalid selection '%s', fallback to 1.":                   "無効な選択 '%s'、1 を使用します。",
+
+	// endpoint ranking
+	"  Ranked: endpoints in %s first, then by connect time.": "  並び順: %s 国内のエンドポイントを優先し、次に接続時間順。",
+	"  Ranked: endpoints in %s first.":                       "  並び順: %s 国内のエンドポイントを優先。",
+	"  Ranked by connect time.":                              "  接続時間順に並べています。",
+
+	// runner
+	"Config:  ":         "設定:  ",
+	"Environment Check": "環境チェック",
+	"Go binary — no external dependencies required.": "Go バイナリ — 外部依存は不要です。",
+	"Interrupted.": "中断されました。",
+	"Simulation mode: built-in CDN emulator at %s (%s, %v latency). Results are not real measurements.": "シミュレーションモード: 内蔵 CDN エミュレーター %s（%s、遅延 %v）。結果は実測値ではありません。",
+	"unlimited":                   "無制限",
+	"Stage failed: %v":            "ステージ失敗: %v",
+	"Connection Information":      "接続情報",
+	"Client":                      "クライアント",
+	"  Location":                  "  所在地",
+	"Server":                      "サーバー",
+	"  Endpoint":                  "  エンドポイント",
+	"Idle Latency":                "アイドル遅延",
+	"Samples: %d":                 "サンプル数: %d",
+	"ICMP Latency":                "ICMP 遅延",
+	"UDP Latency":                 "UDP 遅延",
+	"Echo server: %s":             "エコーサーバー: %s",
+	"UDP latency unavailable: %v": "UDP 遅延を測定できません: %v",
+	"No UDP replies from %s; is the echo server running?":                "%s から UDP の応答がありません。エコーサーバーは起動していますか？",
+	"%.2f ms median  (min %.2f / max %.2f)  jitter %.2f ms  loss %.1f%%": "中央値 %.2f ms  (最小 %.2f / 最大 %.2f)  ジッター %.2f ms  損失 %.1f%%",
+	"Jitter upstream %.2f ms / downstream %.2f ms":                       "上りジッター %.2f ms / 下りジッター %.2f ms",
+	"%d packets reordered, %d duplicated.":                               "%d 個のパケットが順序入れ替わり、%d 個が重複しました。",
+	"%.2f ms  (jitter %.2f ms, loss %.1f%%)":                             "%.2f ms  (ジッター %.2f ms、損失 %.1f%%)",
+	"Request Rate":                                                       "リクエストレート",
+	"Sequential":                                                         "逐次",
+	"%d concurrent":                                                      "%d 並列",
+	"%s: %.1f req/s  (%d requests, median %.2f ms)":                      "%s: %.1f req/s  (%d リクエスト、中央値 %.2f ms)",
+	"%d requests failed.":                                                "%d 件のリクエストが失敗しました。",
+	"%.1f req/s":                                                         "%.1f req/s",
+	"  (%.1f with %d concurrent)":                                        "  (%.1f、%d 並列)",
+	"Cannot resolve %s for ICMP.":                                        "ICMP 用に %s を解決できません。",
+	"ICMP latency unavailable: %v":                                       "ICMP 遅延を測定できません: %v",
+	"No ICMP replies from %s; ICMP may be blocked.":                      "%s から ICMP 応答がありません。ICMP が遮断されている可能性があります。",
+	"%.2f ms median  (min %.2f / max %.2f)  loss %.0f%%":                 "中央値 %.2f ms  (最小 %.2f / 最大 %.2f)  損失 %.0f%%",
+	"%.2f ms  (loss %.0f%%)":                                             "%.2f ms  (損失 %.0f%%)",
+	"ICMP latency (%.1f ms) is well above HTTP (%.1f ms): ICMP is likely deprioritized on the path; trust the HTTP figure.": "ICMP 遅延（%.1f ms）が HTTP（%.1f ms）を大きく上回っています。経路上で ICMP の優先度が下げられている可能性が高いため、HTTP の値を参照してください。",
+	"HTTP latency (%.1f ms) is well above ICMP (%.1f ms): a proxy or middlebox may be delaying HTTP traffic.":               "HTTP 遅延（%.1f ms）が ICMP（%.1f ms）を大きく上回っています。プロキシや中間装置が HTTP 通信を遅延させている可能性があります。",
+	"Path MTU":                                   "経路 MTU",
+	"Cannot resolve %s for the MTU probe.":       "MTU プローブ用に %s を解決できません。",
+	"TCP MSS unavailable: %v":                    "TCP MSS を取得できません: %v",
+	"TCP MSS %d (MTU %d)":                        "TCP MSS %d（MTU %d）",
+	"ICMP MTU probe unavailable: %v":             "ICMP による MTU プローブを実行できません: %v",
+	"%d bytes  (largest unfragmented ICMP echo)": "%d バイト  (断片化なしで通る ICMP エコーの最大値)",
+	"  (PMTUD black hole)":                       "  (PMTUD ブラックホール)",
+	"Packets over %d bytes are dropped without an ICMP \"fragmentation needed\": a PMTUD black hole is likely and can stall large transfers. Clamping the TCP MSS on the router usually fixes it.": "%d バイトを超えるパケットが ICMP「フラグメント必要」なしに破棄されています。PMTUD ブラックホールの可能性が高く、大きな転送が停滞することがあります。ルーターで TCP MSS をクランプすると通常は解決します。",
+	"Path MTU %d is below %d, typical of PPPoE, VPN or tunnel links; each packet carries less data.":                                                                                               "経路 MTU %d は %d 未満です。PPPoE、VPN、トンネル回線でよく見られ、パケットごとに運べるデータが少なくなります。",
+	"Idle Latency (after load)":                "アイドル遅延（負荷後）",
+	"No latency samples after the load.":       "負荷後の遅延サンプルを取得できませんでした。",
+	"Drift vs before load: %+.2f ms":           "負荷前との差: %+.2f ms",
+	"  After Load":                             "  負荷後",
+	"  New Connection":                         "  新規接続",
+	"No latency samples over new connections.": "新規接続では遅延のサンプルを取得できませんでした。",
+	"New connection per probe: %.2f ms median  (%+.2f ms for connection setup)": "プローブごとに新規接続: 中央値 %.2f ms  (接続確立 %+.2f ms)",
+	"Loaded latency over new connections: %.2f ms  (jitter %.2f ms)":            "新規接続での負荷時遅延: %.2f ms  (ジッター %.2f ms)",
+	"%.2f ms  (%+.2f ms)": "%.2f ms  (%+.2f ms)",
+	"Idle latency stayed %.1f ms higher after the load; the link may not recover from load (CGNAT state exhaustion, modem queueing).": "負荷後もアイドル遅延が %.1f ms 高いままです。回線が負荷から回復していない可能性があります（CGNAT の状態テーブル枯渇、モデムのキュー滞留など）。",
+	"%.2f ms median  (min %.2f / avg %.2f / max %.2f)  jitter %.2f ms":                                                                "中央値 %.2f ms  (最小 %.2f / 平均 %.2f / 最大 %.2f)  ジッター %.2f ms",
+	"Download (single thread)":      "ダウンロード（シングルスレッド）",
+	"Download (multi-thread)":       "ダウンロード（マルチスレッド）",
+	"Upload (single thread)":        "アップロード（シングルスレッド）",
+	"Upload (multi-thread)":         "アップロード（マルチスレッド）",
+	"Threads: %d":                   "スレッド数: %d",
+	"Limit: %s / %ds per thread":    "上限: %s / スレッドあたり %ds",
+	"%s  (%s in %.1fs)":             "%s  (%s、%.1f 秒)",
+	"%s  (%s in %.1fs, %d threads)": "%s  (%s、%.1f 秒、%d スレッド)",
+	"Network issue detected during this round; result may be affected.":                 "このラウンド中にネットワーク障害が発生しました。結果に影響している可能性があります。",
+	"Stopped early: throughput held within ±3%% for %v; the result is the steady rate.": "早期終了: スループットが ±3%% 以内のまま %v 続いたため、安定時の速度を結果とします。",
+	"Loaded latency: %.2f ms  (jitter %.2f ms)":                                         "負荷時遅延: %.2f ms  (ジッター %.2f ms)",
+	"Latency rises with throughput (r = %.2f): queues build up under load.":             "遅延がスループットとともに上昇しています（r = %.2f）。負荷時にキューが溜まっています。",
+
+	"Thread shares: %s  (Jain fairness %.3f)": "スレッド別の割合: %s  (Jain 公平性指数 %.3f)",
+	"Uneven threads: one connection carried %.0f%% of the data (fairness %.2f); the total reflects per-flow treatment on the path more than link capacity.": "スレッド間の偏り: 1 本の接続がデータの %.0f%% を運びました（公平性指数 %.2f）。合計速度は回線容量よりも経路上のフロー単位の扱いを反映しています。",
+
+	"  lat %.0fms":              "  遅延 %.0fms",
+	"\U0001f4ca Summary":        "\U0001f4ca 測定結果",
+	"%.2f ms  (jitter %.2f ms)": "%.2f ms  (ジッター %.2f ms)",
+	"Data Used":                 "使用データ量",
+	"Total data cap %s reached, round skipped.":                     "総データ量上限 %s に達したため、このラウンドをスキップします。",
+	"Rate-capped at %s: throughput reflects the cap, not the link.": "%s に速度制限中: スループットは回線ではなく制限値を反映しています。",
+	"Total data cap %s reached; later rounds were cut short.":       "総データ量上限 %s に達したため、後続のラウンドは途中で終了しました。",
+	"All tests complete.":                      "すべてのテストが完了しました。",
+	"Could not write summary card: %v":         "結果カードを書き込めません: %v",
+	"Summary card: ":                           "結果カード: ",
+	"Could not write scatter plot: %v":         "散布図を書き出せません: %v",
+	"Scatter plot: ":                           "散布図: ",
+	"Could not write HTML report: %v":          "HTML レポートを書き出せません: %v",
+	"HTML report: ":                            "HTML レポート: ",
+	"Transfer Cap":                             "転送上限",
+	"Run %d/%d":                                "%d/%d 回目の測定",
+	"Run %d":                                   "%d 回目の測定",
+	"Cooling down for %v before the next run.": "次の測定まで %v 待機します。",
+	"Same endpoint as run 1: ":                 "1 回目と同じエンドポイント: ",
+	"Statistics over %d runs":                  "%d 回の測定の統計",
+	"No metric was measured.":                  "測定できた指標はありません。",
+	"Idle latency":                             "アイドル遅延",
+	"Idle jitter":                              "アイドルジッター",
+	"IPv4 vs IPv6":                             "IPv4 と IPv6 の比較",
+	"IPv4 endpoint":                            "IPv4 エンドポイント",
+	"IPv6 endpoint":                            "IPv6 エンドポイント",
+	"none":                                     "なし",
+	"Metric":                                   "指標",
+	"No metric was measured over both IP versions.":                                                                  "両方の IP バージョンで測定できた指標はありません。",
+	"%s is %.0f%% apart between IPv4 and IPv6, in favour of IPv%d: the two likely take different routes to the CDN.": "%s は IPv4 と IPv6 で %.0f%% 差があり、IPv%d が優位です。両者は CDN まで異なる経路を通っている可能性があります。",
+	"mean %s, median %s, stddev %s %s (CV %.1f%%, n=%d)":                                                             "平均 %s、中央値 %s、標準偏差 %s %s（変動係数 %.1f%%、n=%d）",
+	"Loaded latency vs throughput":                                                                                   "負荷時遅延とスループット",
+	"Throughput (Mbps)":                                                                                              "スループット (Mbps)",
+	"Latency (ms)":                                                                                                   "遅延 (ms)",
+	"Could not share results: %v":                                                                                    "結果を共有できません: %v",
+	"Share":                                                                                                          "共有リンク",
+	"%d streams on one HTTP/2 connection":                                                                            "1 本の HTTP/2 接続上の %d ストリーム",
+	"%d TCP connections":                                                                                             "%d 本の TCP 接続",
+	"%d×TCP %s  vs  1×HTTP/2 %s  (%.0f%%)":                                                                           "%d×TCP %s  対  1×HTTP/2 %s  (%.0f%%)",
+	"%s: one connection reaches only %.0f%% of %d separate ones; per-connection shaping is likely.": "%s: 単一接続は個別接続の %.0f%% しか出ていません（%d 本）。接続単位の帯域制御が行われている可能性があります。",
+	"%s: one connection keeps up with %d separate ones; total capacity is the limit.":               "%s: 単一接続でも %d 本の個別接続と同等です。ボトルネックは回線全体の帯域です。",
+
+	// --runs signals
+	"SIGUSR1: starting the next run now.":                           "SIGUSR1: 次の測定をすぐに開始します。",
+	"SIGUSR2: no run has finished yet.":                             "SIGUSR2: 完了した測定はまだありません。",
+	"SIGHUP: configuration not reloaded: %v":                        "SIGHUP: 設定を再読み込みしませんでした: %v",
+	"SIGHUP: configuration reloaded; it applies from the next run.": "SIGHUP: 設定を再読み込みしました。次の測定から適用されます。",
+
+	"The system was suspended for %.1fs during this round; that time is excluded, but the result may be affected.": "このラウンド中にシステムが %.1f 秒間サスペンドされました。その時間は除外していますが、結果に影響している可能性があります。",
+
+	"Client CPU: %.0f%% of %d cores": "クライアント CPU: %.0f%%（%d コア中）",
+	"The client's CPU was %.0f%% busy: this device, not the network, likely limited the result.": "クライアントの CPU 使用率が %.0f%% でした。結果を制限したのはネットワークではなくこの端末である可能性が高いです。",
+
+	// sinks
+	"INFLUX_URL must start with http(s)://":      "INFLUX_URL は http(s):// で始まる必要があります",
+	"invalid GRAPHITE_ADDR %q, want host[:port]": "GRAPHITE_ADDR の値が不正です %q（host[:port] 形式で指定してください）",
+	"Could not write metrics to InfluxDB: %v":    "InfluxDB に指標を書き込めません: %v",
+	"Metrics written to InfluxDB.":               "InfluxDB に指標を書き込みました。",
+	"Could not send metrics to Graphite: %v":     "Graphite に指標を送信できません: %v",
+	"Metrics sent to Graphite at %s.":            "Graphite（%s）に指標を送信しました。",
+	"Could not write metrics textfile: %v":       "指標のテキストファイルを書き込めません: %v",
+	"Metrics written to %s.":                     "%s に指標を書き込みました。",
+
+	"Cannot read baseline: %v":     "ベースラインを読み込めません: %v",
+	"Could not record history: %v": "履歴を記録できません: %v",
+	"Comparison":                   "比較",
+	"No previous run in %s; this run becomes the baseline.": "%s に過去の結果がないため、今回の結果をベースラインにします。",
+	"last run":                            "前回",
+	"Baseline: %s (%s)":                   "ベースライン: %s（%s）",
+	"Could not read notes: %v":            "メモを読み込めません: %v",
+	"Notes since the previous run":        "前回の測定以降のメモ",
+	"Note %s: %s":                         "メモ %s: %s",
+	"Assertions":                          "アサーション",
+	"%s (min %s)":                         "%s（下限 %s）",
+	"%.1f ms (max %g)":                    "%.1f ms（上限 %g）",
+	"%s: not measured, assertion failed.": "%s: 未測定のためアサーション失敗。",
+	"  passed":                            "  合格",
+	"  FAILED":                            "  不合格",
+	"The baseline has no metrics in common with this run.": "ベースラインと今回の測定に共通の指標がありません。",
+	"%s  %+.1f%% vs %s (%s)":                               "%s  %+.1f%%（%s比: %s）",
+	"%s regressed by %.1f%%, over the %g%% threshold.":     "%s が %.1f%% 悪化し、しきい値 %g%% を超えました。",
+	"Probe:   ":                                           "プローブ: ",
+	"Probe state: %v":                                     "プローブ状態: %v",
+	"Probe Registration":                                  "プローブ登録",
+	"URL hook failed: %v":                                 "URL フックの呼び出しに失敗しました: %v",
+	"Could not renew test URLs: %v":                       "テスト URL を更新できません: %v",
+	"Test URLs supplied by the URL hook.":                 "テスト URL は URL フックから取得しました。",
+	"Test URLs supplied by the URL hook, valid until %s.": "テスト URL は URL フックから取得しました（%s まで有効）。",
+	"Cannot load PAC file: %v":                            "PAC ファイルを読み込めません: %v",
+	"Proxy:   PAC ":                                       "プロキシ: PAC ",
+	"PAC: %s via %s (%s)":                                 "PAC: %s は %s 経由（%s）",
+	"Could not record note: %v":                           "メモを記録できません: %v",
+	"Note recorded in ":                                   "メモを記録しました: ",
+	"UDP Echo Server":                                     "UDP エコーサーバー",
+	"Cannot listen on %s: %v":                             "%s で待ち受けできません: %v",
+	"Listening on %s (UDP); stop with Ctrl+C.":            "%s（UDP）で待ち受け中です。Ctrl+C で停止します。",
+	"Echo server failed: %v":                              "エコーサーバーでエラーが発生しました: %v",
+	"Stopped.":                                            "停止しました。",
+	"Probe ID":                                            "プローブ ID",
+	"Probe Name":                                          "プローブ名",
+	"Collector":                                           "コレクター",
+	"Registration failed: %v":                             "登録に失敗しました: %v",
+	"Could not save probe token: %v":                      "プローブトークンを保存できません: %v",
+	"Registered; token saved to ":                         "登録しました。トークンの保存先: ",
+	"Remote hosts: %s":                                    "リモートホスト: %s",
+	"Remote: %s":                                          "リモート: %s",
+	"%s failed: %v":                                       "%s が失敗しました: %v",
+	"Remote Summary":                                      "リモート結果まとめ",
+	"no result":                                           "結果なし",
+	"down %s  up %s  latency %.1f ms":                     "下り %s  上り %s  遅延 %.1f ms",
+	"(exit %d)":                                           "（終了コード %d）",
+	"Ranking":                                             "ランキング",
+	"Rate-capped run; ranking skipped.":                   "速度制限中のため、ランキングを省略しました。",
+	"Cannot load ranking reference %s: %v; using the built-in one.": "ランキング参照データ %s を読み込めません: %v。内蔵データを使用します。",
+	"No reference for this network.":                                "このネットワークの参照データがありません。",
+	"all":                                                           "全",
+	"Reference: %s users, %s":                                       "参照: %s ユーザー（%s）",
+	"faster than over %d%% of %s users":                             "%d%% 超の %s ユーザーより高速",
+	"among the slowest 5%% of %s users":                             "下位 5%% の %s ユーザーに該当",
+	"faster than ~%d%% of %s users":                                 "約 %d%% の %s ユーザーより高速",
+	"BDP (download)":                                                "BDP（ダウンロード）",
+	"BDP (upload)":                                                  "BDP（アップロード）",
+	"%s  (%s × %.1f ms)":                                            "%s  (%s × %.1f ms)",
+	"  window limit %s":                                             "  ウィンドウ上限 %s",
+	"One connection reached %s of the %s a %s window allows: the host's TCP buffers, not the CDN, are the cap. Raise %s to at least %s.": "単一接続が %s に達し、%s（%s ウィンドウの上限）に迫っています。ボトルネックは CDN ではなくこのホストの TCP バッファです。%s を %s 以上に引き上げてください。",
+	"Ramp: %s steady after %.1fs  (variation %.0f%%, %d drops)":                                                                          "立ち上がり: %s で安定（%.1f 秒後）  (変動 %.0f%%、急落 %d 回)",
+	"Throughput saws up and down with little added latency: a policer dropping traffic above the rate is likely.":                        "スループットがのこぎり状に上下し、遅延はほとんど増えていません。上限を超えたトラフィックを破棄するポリサーの可能性が高いです。",
+	"Throughput is flat while latency rose %.0f ms: a shaper queueing traffic to the rate is likely.":                                    "スループットは平坦で遅延が %.0f ms 増加しました。キューで速度を抑えるシェーパーの可能性が高いです。",
+
+	"Total data cap %s reached, probe skipped.":         "総データ量上限 %s に達したため、事前測定を省略します。",
+	"Probing link speed for %ds with %d threads.":       "回線速度を %d 秒間事前測定します（%d スレッド）。",
+	"The probe moved no data; keeping MAX=%s.":          "事前測定でデータを受信できなかったため、MAX=%s のままにします。",
+	"%s: MAX=%s per thread (~%.0fs at full link speed)": "%s: スレッドごとの上限 MAX=%s（全速で約 %.0f 秒）",
+	"Bidirectional":                                           "双方向同時",
+	"Threads: %d download + %d upload":                        "スレッド: ダウンロード %d + アップロード %d",
+	"↓ %s + ↑ %s = %s  (%.1fs)":                               "↓ %s + ↑ %s = %s  (%.1f 秒)",
+	"Kept %.0f%% of solo download and %.0f%% of solo upload.": "単方向時のダウンロードの %.0f%%、アップロードの %.0f%% を維持しました。",
+	"Download collapses while uploading: the saturated uplink likely delays the download's ACKs (bufferbloat on the upstream queue).": "アップロード中はダウンロードが大きく低下します。飽和した上りキューがダウンロードの ACK を遅らせている可能性が高いです（上りのバッファブロート）。",
+	"Upload collapses while downloading: the downlink queue likely delays the upload's ACKs.":                                         "ダウンロード中はアップロードが大きく低下します。下りキューがアップロードの ACK を遅らせている可能性が高いです。",
+	"Both directions slow down together: the link behaves half-duplex, as a shared medium such as Wi-Fi does.":                        "両方向が同時に低下します。Wi-Fi などの共有媒体のように半二重で動作しています。",
+
+	// transfer
+	"Download":   "ダウンロード",
+	"Upload":     "アップロード",
+	"ok":         "成功",
+	"fault":      "障害",
+	"time limit": "時間切れ",
+	"cancelled":  "キャンセル",
+	"saturated":  "飽和",
+
+	// render
+	"Stage":              "ステージ",
+	"Speed":              "速度",
+	"Trend":              "推移",
+	"Latency":            "遅延",
+	"Threads":            "スレッド",
+	"avg %s  peak %s %s": "平均 %s  ピーク %s %s",
+	"%.1f ms (loaded)":   "%.1f ms（負荷時）",
+	"%.1f ms (idle)":     "%.1f ms（アイドル）",
+	" %d/%d active":      " %d/%d 稼働中",
+
+	// HTML report
+	"iNetSpeed-CLI Speed Test Report": "iNetSpeed-CLI 速度測定レポート",
+	"Over Time":                       "時間推移",
+	"Rounds":                          "ラウンド",
+	"Path":                            "経路",
+	"Configuration":                   "設定",
+	"simulated":                       "シミュレーション",
+	"degraded":                        "品質低下",
+	"rate-capped":                     "速度制限中",
+	"best round":                      "最良ラウンド",
+	"jitter %.1f ms":                  "ジッター %.1f ms",
+	"Round":                           "ラウンド",
+	"Data":                            "データ量",
+	"Time (s)":                        "時間 (秒)",
+	"Loaded Latency (ms)":             "負荷時遅延 (ms)",
+	"Faults":                          "障害",
+	"Endpoint":                        "エンドポイント",
+	"Throughput over time":            "スループットの推移",
+	"Loaded latency over time":        "負荷時遅延の推移",
+
+	// cmd/speedtest
+	"  [!] Event log incomplete: %v\n": "  [!] イベントログが不完全です: %v\n",
+	"  [!] Log file incomplete: %v\n":  "  [!] ログファイルが不完全です: %v\n",
+}
@@ -0,0 +1,100 @@
+package i18n
+
+import "strings"
+
+// hantCatalog overrides the automatic Simplified-to-Traditional conversion for
+// messages where a character-level mapping reads unnaturally.
+var hantCatalog = map[string]string{
+	"Select endpoint [1-%d, Enter=1]: ": "選擇節點 [1-%d，Enter=1]: ",
+}
+
+// hantPhrases are Taiwan-style vocabulary substitutions applied to the
+// Simplified text before character conversion. Longer phrases come first.
+var hantPhrases = strings.NewReplacer(
+	"服务器", "伺服器",
+	"服务端", "伺服器端",
+	"网络", "網路",
+	"默认", "預設",
+	"信息", "資訊",
+	"数据", "資料",
+	"线程", "執行緒",
+	"文件", "檔案",
+	"程序", "程式",
+	"交互", "互動",
+	"回车", "Enter",
+	"质量", "品質",
+	"软件", "軟體",
+	"硬件", "硬體",
+	"支持", "支援",
+	"设置", "設定",
+	"内存", "記憶體",
+	"接口", "介面",
+	"链接", "連結",
+	"运行", "執行",
+	"创建", "建立",
+	"获取", "取得",
+	"视频", "影片",
+	"屏幕", "螢幕",
+	"日志", "日誌",
+	"剩余", "剩餘",
+	"其余", "其餘",
+	"重复", "重複",
+	"复用", "複用",
+	"复制", "複製",
+	"恢复", "恢復",
+	"回复", "回覆",
+	"占用", "佔用",
+	"占比", "佔比",
+	"占位", "佔位",
+)
+
+// hantPairs lists Simplified/Traditional character pairs. Characters whose
+// Traditional form depends on context (里, 复, 台, 系, 干, ...) are omitted.
+const hantPairs = "" +
+	"为為于於从從传傳别別务務动動单單双雙发發变變号號后後响響围圍头頭应應并並" +
+	"开開总總户戶报報择擇据據数數断斷无無时時显顯机機构構样樣检檢汇匯测測点點" +
+	"环環现現线線结結络絡统統继繼续續网網节節范範认認设設识識试試询詢语語读讀" +
+	"负負败敗贴貼赖賴车車轮輪载載输輸过過进進连連迟遲选選采採链鏈长長阶階项項" +
+	"须須参參帮幫类類们們这這个個来來对對说說会會学學国國经經关關实實问問题題" +
+	"间間门門业業东東两兩严嚴丢丟乐樂习習书書买買乱亂争爭亏虧云雲产產亲親亿億" +
+	"仅僅仓倉价價众眾优優伞傘伤傷伪偽体體佣傭侧側侦偵俭儉债債倾傾储儲儿兒兴興" +
+	"兰蘭兹茲养養冲衝决決况況冻凍净淨减減几幾击擊划劃则則刚剛创創删刪剂劑剧劇" +
+	"办辦劳勞势勢区區医醫华華协協卫衛厂廠压壓厅廳历歷县縣叠疊吗嗎启啟员員呜嗚" +
+	"团團园園图圖圆圓场場坏壞块塊坚堅垒壘处處备備夺奪奋奮妇婦妈媽娱娛宁寧宝寶" +
+	"宠寵审審宽寬寻尋导導将將尔爾尘塵尝嘗层層属屬岁歲峡峽币幣师師帐帳带帶庆慶" +
+	"库庫庙廟废廢异異弃棄张張强強归歸当當录錄彻徹径徑忆憶忧憂恋戀恶惡悬懸惊驚" +
+	"惯慣战戰扩擴扫掃护護担擔拟擬拥擁挂掛损損换換挤擠摄攝旧舊晓曉暂暫术術杀殺" +
+	"权權条條杨楊极極标標栈棧树樹档檔桥橋楼樓欢歡毁毀气氣汉漢汤湯沟溝没沒泽澤" +
+	"济濟浏瀏浓濃涂塗润潤涨漲渐漸温溫湾灣满滿滤濾灯燈灵靈炼煉热熱烦煩爱愛状狀" +
+	"犹猶独獨电電画畫畅暢疗療盖蓋监監盘盤码碼础礎确確离離种種积積称稱稳穩穷窮" +
+	"竞競笔筆签簽简簡粮糧紧緊红紅级級纪紀约約纯純纲綱纳納纵縱纸紙练練组組细細" +
+	"终終绑綁绕繞给給绝絕绩績维維综綜绿綠缓緩编編缩縮罗羅罚罰职職联聯肃肅脑腦" +
+	"脏臟脚腳脱脫艺藝荐薦获獲药藥莱萊虑慮虚虛补補装裝见見观觀规規视視览覽觉覺" +
+	"计計讨討让讓训訓议議讯訊记記讲講许許论論访訪证證评評诉訴话話诚誠该該详詳" +
+	"误誤请請诸諸课課调調谁誰谢謝谱譜贡貢财財责責货貨质質购購费費资資赛賽赞贊" +
+	"赶趕轨軌转轉软軟轻輕较較辅輔辑輯边邊达達迁遷运運还還远遠违違适適递遞逻邏" +
+	"遗遺邮郵释釋钟鐘钥鑰钱錢铁鐵银銀销銷锁鎖错錯键鍵镜鏡闭閉闲閒闻聞阅閱队隊" +
+	"际際陆陸险險随隨隐隱难難顶頂顺順顾顧预預领領频頻颜顏风風飞飛馆館驱驅验驗" +
+	"骤驟鱼魚鸟鳥黄黃齐齊龙龍准準尽盡与與仪儀内內册冊写寫墙牆声聲够夠帧幀态態" +
+	"执執拦攔滞滯胀脹营營诊診趋趨跃躍针針钩鉤钳鉗锯鋸阈閾顿頓颈頸额額饱飽齿齒"
+
+var hantMap = func() map[rune]rune {
+	r := []rune(hantPairs)
+	m := make(map[rune]rune, len(r)/2)
+	for i := 0; i+1 < len(r); i += 2 {
+		m[r[i]] = r[i+1]
+	}
+	return m
+}()
+
+// ToHant converts Simplified Chinese text to Traditional Chinese using a
+// phrase table followed by a character table.
+func ToHant(s string) string {
+	s = hantPhrases.Replace(s)
+	return strings.Map(func(r rune) rune {
+		if t, ok := hantMap[r]; ok {
+			return t
+		}
+		return r
+	}, s)
+}
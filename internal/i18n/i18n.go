@@ -7,33 +7,69 @@ import (
 )
 
 const (
-	LangEN = "en"
-	LangZH = "zh"
+	LangEN     = "en"
+	LangZH     = "zh" // Simplified Chinese (zh-Hans)
+	LangZHHant = "zh-Hant"
+	LangJA     = "ja"
 )
 
+// Supported lists every UI language in display order.
+var Supported = []string{LangEN, LangZH, LangZHHant, LangJA}
+
 var current atomic.Value
 
 func init() {
 	current.Store(LangEN)
 }
 
+// catalogs maps a language to translations keyed by the English source text.
+// Simplified Chinese is supplied inline at each call site; Traditional
+// Chinese falls back to converting it (see hant.go).
+var catalogs = map[string]map[string]string{
+	LangJA:     jaCatalog,
+	LangZHHant: hantCatalog,
+}
+
+// normalize maps a locale or language tag (zh_TW.UTF-8, zh-Hans, ja-JP, ...)
+// to one of the supported languages, defaulting to English.
 func normalize(lang string) string {
 	v := strings.ToLower(strings.TrimSpace(lang))
-	if strings.HasPrefix(v, LangZH) {
+	if i := strings.IndexAny(v, ".@"); i >= 0 {
+		v = v[:i]
+	}
+	v = strings.ReplaceAll(v, "_", "-")
+	switch {
+	case strings.HasPrefix(v, LangZH):
+		for _, tag := range []string{"hant", "-tw", "-hk", "-mo"} {
+			if strings.Contains(v, tag) {
+				return LangZHHant
+			}
+		}
 		return LangZH
+	case v == LangJA || strings.HasPrefix(v, "ja-"):
+		return LangJA
 	}
 	return LangEN
 }
 
+// DetectFromEnv resolves the UI language from SPEEDTEST_LANG, then the first
+// non-empty locale variable among LC_ALL, LC_MESSAGES, LANGUAGE and LANG.
+// The C/POSIX locales are treated as unset.
 func DetectFromEnv() string {
 	if v := strings.TrimSpace(os.Getenv("SPEEDTEST_LANG")); v != "" {
 		return normalize(v)
 	}
 	keys := []string{"LC_ALL", "LC_MESSAGES", "LANGUAGE", "LANG"}
 	for _, k := range keys {
-		if strings.HasPrefix(strings.ToLower(strings.TrimSpace(os.Getenv(k))), LangZH) {
-			return LangZH
+		v := strings.TrimSpace(os.Getenv(k))
+		if k == "LANGUAGE" {
+			// LANGUAGE is a colon-separated priority list.
+			v, _, _ = strings.Cut(v, ":")
 		}
+		if v == "" || v == "C" || v == "POSIX" || strings.HasPrefix(v, "C.") {
+			continue
+		}
+		return normalize(v)
 	}
 	return LangEN
 }
@@ -61,15 +97,46 @@ func Lang() string {
 	return LangEN
 }
 
+// IsZH reports whether the UI language is Chinese in either script.
 func IsZH() bool {
-	return Lang() == LangZH
+	l := Lang()
+	return l == LangZH || l == LangZHHant
 }
 
+// Text returns the message for the current language. en doubles as the
+// catalog key for languages other than Simplified Chinese.
 func Text(en, zh string) string {
-	if IsZH() {
+	return TextIn(Lang(), en, zh)
+}
+
+// TextIn returns the message for lang. Machine-readable output passes LangEN
+// so it never changes with the UI language.
+func TextIn(lang, en, zh string) string {
+	switch lang {
+	case LangZH:
 		return zh
+	case LangZHHant:
+		if v, ok := catalogs[lang][en]; ok {
+			return v
+		}
+		return ToHant(zh)
+	case LangEN:
+		return en
+	default:
+		if v, ok := catalogs[lang][en]; ok {
+			return v
+		}
+		return en
+	}
+}
+
+// LocalizeZH adapts Simplified Chinese text from external sources (e.g. geo
+// lookups) to the active Chinese script. Other languages are returned as-is.
+func LocalizeZH(s string) string {
+	if Lang() == LangZHHant {
+		return ToHant(s)
 	}
-	return en
+	return s
 }
 
 func FindLangArg(args []string) (string, bool) {
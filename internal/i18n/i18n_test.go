@@ -1,6 +1,19 @@
 package i18n
 
-import "testing"
+import (
+	"go/ast"
+	"go/parser"
+	"go/token"
+	"io/fs"
+	"path/filepath"
+	"regexp"
+	"strconv"
+	"strings"
+	"testing"
+	"unicode"
+
+	"golang.org/x/text/encoding/traditionalchinese"
+)
 
 func TestResolve(t *testing.T) {
 	t.Setenv("LANG", "en_US.UTF-8")
@@ -12,6 +25,227 @@ func TestResolve(t *testing.T) {
 	}
 }
 
+func TestNormalize(t *testing.T) {
+	tests := map[string]string{
+		"zh":          LangZH,
+		"zh-Hans":     LangZH,
+		"zh_CN.UTF-8": LangZH,
+		"zh-SG":       LangZH,
+		"zh-Hant":     LangZHHant,
+		"zh_TW.UTF-8": LangZHHant,
+		"zh-HK":       LangZHHant,
+		"zh-Hant-MO":  LangZHHant,
+		"ja":          LangJA,
+		"ja_JP.UTF-8": LangJA,
+		"en_US":       LangEN,
+		"jam":         LangEN,
+		"":            LangEN,
+	}
+	for in, want := range tests {
+		if got := normalize(in); got != want {
+			t.Errorf("normalize(%q) = %q, want %q", in, got, want)
+		}
+	}
+}
+
+func TestDetectFromEnvPrecedence(t *testing.T) {
+	t.Setenv("SPEEDTEST_LANG", "")
+	t.Setenv("LC_ALL", "ja_JP.UTF-8")
+	t.Setenv("LC_MESSAGES", "")
+	t.Setenv("LANGUAGE", "")
+	t.Setenv("LANG", "zh_CN.UTF-8")
+	if got := DetectFromEnv(); got != LangJA {
+		t.Errorf("LC_ALL should win over LANG, got %q", got)
+	}
+
+	t.Setenv("LC_ALL", "C")
+	t.Setenv("LANGUAGE", "zh_TW:en")
+	if got := DetectFromEnv(); got != LangZHHant {
+		t.Errorf("C locale should be skipped and LANGUAGE list used, got %q", got)
+	}
+
+	t.Setenv("SPEEDTEST_LANG", "en")
+	if got := DetectFromEnv(); got != LangEN {
+		t.Errorf("SPEEDTEST_LANG should win, got %q", got)
+	}
+}
+
+func TestText(t *testing.T) {
+	old := Lang()
+	defer Set(old)
+
+	Set(LangEN)
+	if got := Text("Upload", "上传"); got != "Upload" {
+		t.Errorf("en: %q", got)
+	}
+	Set(LangZH)
+	if got := Text("Upload", "上传"); got != "上传" {
+		t.Errorf("zh: %q", got)
+	}
+	Set(LangZHHant)
+	if got := Text("Upload", "上传"); got != "上傳" {
+		t.Errorf("zh-Hant: %q", got)
+	}
+	if got := Text("Select endpoint [1-%d, Enter=1]: ", "选择节点 [1-%d，回车=1]: "); got != hantCatalog["Select endpoint [1-%d, Enter=1]: "] {
+		t.Errorf("zh-Hant catalog override not used: %q", got)
+	}
+	if !IsZH() {
+		t.Error("zh-Hant should report IsZH")
+	}
+	Set(LangJA)
+	if got := Text("Upload", "上传"); got != "アップロード" {
+		t.Errorf("ja: %q", got)
+	}
+	if got := Text("not in catalog", "不在目录中"); got != "not in catalog" {
+		t.Errorf("ja fallback: %q", got)
+	}
+	if IsZH() {
+		t.Error("ja should not report IsZH")
+	}
+}
+
+func TestTextIn(t *testing.T) {
+	old := Lang()
+	defer Set(old)
+
+	Set(LangZH)
+	if got := TextIn(LangEN, "Upload", "上传"); got != "Upload" {
+		t.Errorf("en under a zh UI: %q", got)
+	}
+	if got := TextIn(LangJA, "Upload", "上传"); got != "アップロード" {
+		t.Errorf("ja under a zh UI: %q", got)
+	}
+}
+
+func TestToHant(t *testing.T) {
+	tests := map[string]string{
+		"下载（多线程）":    "下載（多執行緒）",
+		"无法解析节点 IP":  "無法解析節點 IP",
+		"网络故障":       "網路故障",
+		"默认读取":       "預設讀取",
+		"无法写入历史记录":   "無法寫入歷史記錄",
+		"剩余预算":       "剩餘預算",
+		"ASCII only": "ASCII only",
+	}
+	for in, want := range tests {
+		if got := ToHant(in); got != want {
+			t.Errorf("ToHant(%q) = %q, want %q", in, got, want)
+		}
+	}
+	if len([]rune(hantPairs))%2 != 0 {
+		t.Fatal("hantPairs must contain an even number of runes")
+	}
+}
+
+// big5Simplified are Simplified forms that Big5 and its HKSCS extension
+// encode as well, so TestHantCoverage names them explicitly.
+const big5Simplified = "与仪余声复够墙跃齿"
+
+// TestHantCoverage renders every literal zh message of the module in
+// Traditional Chinese and fails on characters left in Simplified form: those
+// Big5 cannot encode, and those of big5Simplified.
+func TestHantCoverage(t *testing.T) {
+	enc := traditionalchinese.Big5.NewEncoder()
+	n := 0
+	err := filepath.WalkDir("../..", func(path string, d fs.DirEntry, err error) error {
+		if err != nil || d.IsDir() || !strings.HasSuffix(path, ".go") || strings.HasSuffix(path, "_test.go") {
+			return err
+		}
+		fset := token.NewFileSet()
+		f, err := parser.ParseFile(fset, path, nil, 0)
+		if err != nil {
+			return err
+		}
+		ast.Inspect(f, func(node ast.Node) bool {
+			en, zh, ok := textArgs(node)
+			if !ok {
+				return true
+			}
+			n++
+			for _, r := range TextIn(LangZHHant, en, zh) {
+				if !unicode.Is(unicode.Han, r) {
+					continue
+				}
+				if _, err := enc.String(string(r)); err != nil || strings.ContainsRune(big5Simplified, r) {
+					t.Errorf("%s: zh-Hant keeps Simplified %c", fset.Position(node.Pos()), r)
+				}
+			}
+			return true
+		})
+		return nil
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if n < 100 {
+		t.Fatalf("found only %d messages", n)
+	}
+}
+
+// textArgs returns the en and zh literals of a Text or TextIn call.
+func textArgs(node ast.Node) (en, zh string, ok bool) {
+	call, isCall := node.(*ast.CallExpr)
+	if !isCall {
+		return "", "", false
+	}
+	var name string
+	switch fn := call.Fun.(type) {
+	case *ast.SelectorExpr:
+		if x, isIdent := fn.X.(*ast.Ident); isIdent && x.Name == "i18n" {
+			name = fn.Sel.Name
+		}
+	case *ast.Ident:
+		name = fn.Name
+	}
+	args := call.Args
+	switch {
+	case name == "Text" && len(args) == 2:
+	case name == "TextIn" && len(args) == 3:
+		args = args[1:]
+	default:
+		return "", "", false
+	}
+	en, okEN := literal(args[0])
+	zh, okZH := literal(args[1])
+	return en, zh, okEN && okZH
+}
+
+// literal evaluates a string literal or a concatenation of them.
+func literal(e ast.Expr) (string, bool) {
+	switch v := e.(type) {
+	case *ast.BasicLit:
+		s, err := strconv.Unquote(v.Value)
+		return s, err == nil
+	case *ast.BinaryExpr:
+		x, okX := literal(v.X)
+		y, okY := literal(v.Y)
+		return x + y, okX && okY && v.Op == token.ADD
+	case *ast.ParenExpr:
+		return literal(v.X)
+	}
+	return "", false
+}
+
+var verbRe = regexp.MustCompile(`%[-+# 0-9.]*[a-zA-Z%]`)
+
+func TestCatalogFormatVerbs(t *testing.T) {
+	for lang, cat := range catalogs {
+		for en, msg := range cat {
+			want := verbRe.FindAllString(en, -1)
+			got := verbRe.FindAllString(msg, -1)
+			if len(want) != len(got) {
+				t.Errorf("%s: %q has verbs %v, translation %q has %v", lang, en, want, msg, got)
+				continue
+			}
+			for i := range want {
+				if want[i] != got[i] {
+					t.Errorf("%s: %q verb %d is %s, translation uses %s", lang, en, i, want[i], got[i])
+				}
+			}
+		}
+	}
+}
+
 func TestDetectFromEnv(t *testing.T) {
 	t.Setenv("SPEEDTEST_LANG", "")
 	t.Setenv("LC_ALL", "")
@@ -0,0 +1,110 @@
+// Package ifstat reads the byte counters the OS keeps for a network
+// interface, to check what a test moved against everything that crossed
+// the interface meanwhile.
+package ifstat
+
+import (
+	"bufio"
+	"errors"
+	"fmt"
+	"io"
+	"strconv"
+	"strings"
+)
+
+// ErrUnsupported is returned where the counters cannot be read.
+var ErrUnsupported = errors.New("reading interface counters is not supported on this platform")
+
+// Counters are the bytes an interface has received and sent since boot.
+type Counters struct {
+	RxBytes uint64
+	TxBytes uint64
+}
+
+// Sub returns the bytes moved between the readings prev and c.
+func (c Counters) Sub(prev Counters) Counters {
+	return Counters{RxBytes: c.RxBytes - prev.RxBytes, TxBytes: c.TxBytes - prev.TxBytes}
+}
+
+// Read returns the counters of the interface called name.
+func Read(name string) (Counters, error) {
+	return read(name)
+}
+
+// parseProcNetDev reads Linux's /proc/net/dev:
+//
+//	Inter-|   Receive                            |  Transmit
+//	 face |bytes    packets errs drop ...        |bytes    packets ...
+//	  eth0: 1234567    8901    0    0 ...          7654321   1098 ...
+func parseProcNetDev(r io.Reader, name string) (Counters, error) {
+	sc := bufio.NewScanner(r)
+	for sc.Scan() {
+		iface, rest, ok := strings.Cut(sc.Text(), ":")
+		if !ok || strings.TrimSpace(iface) != name {
+			continue
+		}
+		f := strings.Fields(rest)
+		if len(f) < 16 {
+			return Counters{}, fmt.Errorf("short /proc/net/dev entry for %s", name)
+		}
+		rx, err1 := strconv.ParseUint(f[0], 10, 64)
+		tx, err2 := strconv.ParseUint(f[8], 10, 64)
+		if err := errors.Join(err1, err2); err != nil {
+			return Counters{}, err
+		}
+		return Counters{RxBytes: rx, TxBytes: tx}, nil
+	}
+	if err := sc.Err(); err != nil {
+		return Counters{}, err
+	}
+	return Counters{}, fmt.Errorf("interface %s not found", name)
+}
+
+// parseNetstat reads the output of `netstat -ibn` on macOS and the BSDs,
+// taking the link-level row of name:
+//
+//	Name  Mtu   Network       Address            Ipkts Ierrs     Ibytes    Opkts Oerrs     Obytes  Coll
+//	en0   1500  <Link#6>      a4:83:e7:00:00:01  88140     0  101239741    40713     0    5436345     0
+//
+// Interfaces without a link address leave the Address column empty.
+func parseNetstat(out, name string) (Counters, error) {
+	lines := strings.Split(out, "\n")
+	if len(lines) == 0 {
+		return Counters{}, errors.New("empty netstat output")
+	}
+	header := strings.Fields(lines[0])
+	ib, ob, addr := -1, -1, -1
+	for i, h := range header {
+		switch h {
+		case "Ibytes":
+			ib = i
+		case "Obytes":
+			ob = i
+		case "Address":
+			addr = i
+		}
+	}
+	if ib < 0 || ob < 0 {
+		return Counters{}, errors.New("netstat output has no byte columns")
+	}
+	for _, line := range lines[1:] {
+		f := strings.Fields(line)
+		if len(f) < 3 || f[0] != name || !strings.HasPrefix(f[2], "<Link") {
+			continue
+		}
+		i, o := ib, ob
+		if len(f) == len(header)-1 && addr >= 0 {
+			i, o = i-1, o-1
+		}
+		if o >= len(f) {
+			break
+		}
+		rx, err1 := strconv.ParseUint(f[i], 10, 64)
+		tx, err2 := strconv.ParseUint(f[o], 10, 64)
+		if err := errors.Join(err1, err2); err != nil {
+			return Counters{}, err
+		}
+		return Counters{RxBytes: rx, TxBytes: tx}, nil
+	}
+	return Counters{}, fmt.Errorf("interface %s not found", name)
+}
@@ -0,0 +1,13 @@
+//go:build darwin || freebsd || netbsd || openbsd || dragonfly
+
+package ifstat
+
+import "os/exec"
+
+func read(name string) (Counters, error) {
+	out, err := exec.Command("netstat", "-ibn", "-I", name).Output()
+	if err != nil {
+		return Counters{}, err
+	}
+	return parseNetstat(string(out), name)
+}
@@ -0,0 +1,12 @@
+package ifstat
+
+import "os"
+
+func read(name string) (Counters, error) {
+	f, err := os.Open("/proc/net/dev")
+	if err != nil {
+		return Counters{}, err
+	}
+	defer f.Close()
+	return parseProcNetDev(f, name)
+}
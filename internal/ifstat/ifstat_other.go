@@ -0,0 +1,5 @@
+//go:build !linux && !darwin && !freebsd && !netbsd && !openbsd && !dragonfly
+
+package ifstat
+
+func read(string) (Counters, error) { return Counters{}, ErrUnsupported }
@@ -0,0 +1,51 @@
+package ifstat
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestParseProcNetDev(t *testing.T) {
+	const dev = `Inter-|   Receive                                                |  Transmit
+ face |bytes    packets errs drop fifo frame compressed multicast|bytes    packets errs drop fifo colls carrier compressed
+    lo:  924567    8123    0    0    0     0          0         0   924567    8123    0    0    0     0       0          0
+  eth0: 98765432   71234    0    3    0     0          0       120  5432109   40123    0    0    0     0       0          0
+`
+	c, err := parseProcNetDev(strings.NewReader(dev), "eth0")
+	if err != nil || c != (Counters{RxBytes: 98765432, TxBytes: 5432109}) {
+		t.Errorf("eth0 = %+v, %v", c, err)
+	}
+	if _, err := parseProcNetDev(strings.NewReader(dev), "wlan0"); err == nil {
+		t.Error("missing interface found")
+	}
+}
+
+func TestParseNetstat(t *testing.T) {
+	const out = `Name       Mtu   Network       Address            Ipkts Ierrs     Ibytes    Opkts Oerrs     Obytes  Coll
+en0        1500  <Link#6>      a4:83:e7:00:00:01  88140     0  101239741    40713     0    5436345     0
+en0        1500  192.168.1       192.168.1.23     87000     -  100000000    40000     -    5000000     -
+utun3      1380  <Link#20>                          120     0      15360      140     0      17920     0
+`
+	for _, tc := range []struct {
+		name string
+		want Counters
+	}{
+		{"en0", Counters{RxBytes: 101239741, TxBytes: 5436345}},
+		{"utun3", Counters{RxBytes: 15360, TxBytes: 17920}},
+	} {
+		c, err := parseNetstat(out, tc.name)
+		if err != nil || c != tc.want {
+			t.Errorf("%s = %+v, %v; want %+v", tc.name, c, err, tc.want)
+		}
+	}
+	if _, err := parseNetstat(out, "en1"); err == nil {
+		t.Error("missing interface found")
+	}
+}
+
+func TestSub(t *testing.T) {
+	d := Counters{RxBytes: 150, TxBytes: 40}.Sub(Counters{RxBytes: 100, TxBytes: 10})
+	if d != (Counters{RxBytes: 50, TxBytes: 30}) {
+		t.Errorf("Sub = %+v", d)
+	}
+}
@@ -2,13 +2,16 @@ package latency
 
 import (
 	"context"
+	"fmt"
 	"math"
 	"net/http"
+	"net/http/httptrace"
 	"sort"
 	"sync"
 	"time"
 
 	"github.com/tsosunchia/iNetSpeed-CLI/internal/config"
+	"github.com/tsosunchia/iNetSpeed-CLI/internal/histogram"
 )
 
 type Stats struct {
@@ -18,20 +21,86 @@ type Stats struct {
 	Max    float64
 	Jitter float64
 	N      int
+	// Pct is the spread of the samples, read from their histogram.
+	Pct histogram.Percentiles
+	// Raw is set by Robust: the stats of every sample, where the fields
+	// above cover only those it kept. Warmup is the number of leading
+	// samples it dropped and Outliers the number the MAD test rejected.
+	Raw      *Stats
+	Warmup   int
+	Outliers int
+}
+
+// madCutoff is the modified z-score, 0.6745·|x−median|/MAD, beyond which
+// Robust rejects a sample (Iglewicz and Hoaglin).
+const madCutoff = 3.5
+
+// Method names the filtering Robust applies, for reports.
+const Method = "drop-first+mad-3.5"
+
+// SampleFunc receives each successful probe's round-trip time in ms.
+type SampleFunc func(ms float64)
+
+// Options shape the probes. The zero value sends them back to back over the
+// client's kept-alive connections, each reading the whole object.
+type Options struct {
+	// Interval is the least time between the starts of two probes.
+	Interval time.Duration
+	// Size, when positive, requests only the first Size bytes of the
+	// object with a Range header.
+	Size int64
+	// NewConn opens a connection for every probe, so each sample includes
+	// connection setup; reused connections measure queuing delay alone.
+	NewConn bool
+}
+
+// pacer spaces probes Interval apart: a token bucket holding one token,
+// refilled every Interval. A probe slower than that lets the next one start
+// at once rather than building up a backlog of catch-up probes.
+type pacer struct {
+	interval time.Duration
+	next     time.Time
+}
+
+// wait blocks until the next probe may start, returning false when ctx is
+// done first.
+func (p *pacer) wait(ctx context.Context) bool {
+	if d := time.Until(p.next); p.interval > 0 && d > 0 {
+		t := time.NewTimer(d)
+		defer t.Stop()
+		select {
+		case <-ctx.Done():
+			return false
+		case <-t.C:
+		}
+	}
+	p.next = time.Now().Add(p.interval)
+	return ctx.Err() == nil
 }
 
 func MeasureIdle(ctx context.Context, client *http.Client, url string, n int) Stats {
+	return MeasureIdleFunc(ctx, client, url, nil, n, Options{}, nil)
+}
+
+// MeasureIdleFunc is MeasureIdle with the probes shaped by opts and every
+// sample also passed to fn. Requests carry hdr, or config.DefaultHeader when
+// it is nil.
+func MeasureIdleFunc(ctx context.Context, client *http.Client, url string, hdr http.Header, n int, opts Options, fn SampleFunc) Stats {
 	samples := make([]float64, 0, n)
+	pace := pacer{interval: opts.Interval}
 	for i := 0; i < n; i++ {
-		if ctx.Err() != nil {
+		if !pace.wait(ctx) {
 			break
 		}
-		d := probe(ctx, client, url)
+		d := probe(ctx, client, url, hdr, opts)
 		if d >= 0 {
 			samples = append(samples, d)
+			if fn != nil {
+				fn(d)
+			}
 		}
 	}
-	return Compute(samples)
+	return Robust(samples)
 }
 
 type Probe struct {
@@ -40,17 +109,30 @@ type Probe struct {
 	cancel  context.CancelFunc
 	client  *http.Client
 	url     string
+	hdr     http.Header
+	opts    Options
+	fn      SampleFunc
 	samples []float64
 	wg      sync.WaitGroup
 }
 
 func StartLoaded(ctx context.Context, client *http.Client, url string) *Probe {
+	return StartLoadedFunc(ctx, client, url, nil, Options{}, nil)
+}
+
+// StartLoadedFunc is StartLoaded with the probes shaped by opts and every
+// sample also passed to fn. Requests carry hdr, or config.DefaultHeader when
+// it is nil.
+func StartLoadedFunc(ctx context.Context, client *http.Client, url string, hdr http.Header, opts Options, fn SampleFunc) *Probe {
 	ctx2, cancel := context.WithCancel(ctx)
 	p := &Probe{
 		ctx:    ctx2,
 		cancel: cancel,
 		client: client,
 		url:    url,
+		hdr:    hdr,
+		opts:   opts,
+		fn:     fn,
 	}
 	p.wg.Add(1)
 	go p.loop()
@@ -59,45 +141,75 @@ func StartLoaded(ctx context.Context, client *http.Client, url string) *Probe {
 
 func (p *Probe) loop() {
 	defer p.wg.Done()
+	pace := pacer{interval: p.opts.Interval}
 	for {
-		if p.ctx.Err() != nil {
+		if !pace.wait(p.ctx) {
 			return
 		}
-		d := probe(p.ctx, p.client, p.url)
+		d := probe(p.ctx, p.client, p.url, p.hdr, p.opts)
 		if d >= 0 {
 			p.mu.Lock()
 			p.samples = append(p.samples, d)
 			p.mu.Unlock()
+			if p.fn != nil {
+				p.fn(d)
+			}
 		}
 	}
 }
 
+// Last returns the most recent sample in ms and the number of samples taken
+// so far, zero before the first one. It is safe to call while the probe runs.
+func (p *Probe) Last() (float64, int) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	if len(p.samples) == 0 {
+		return 0, 0
+	}
+	return p.samples[len(p.samples)-1], len(p.samples)
+}
+
 func (p *Probe) Stop() Stats {
 	p.cancel()
 	p.wg.Wait()
 	p.mu.Lock()
 	s := p.samples
 	p.mu.Unlock()
-	return Compute(s)
+	return Robust(s)
 }
 
-func probe(ctx context.Context, client *http.Client, url string) float64 {
+func probe(ctx context.Context, client *http.Client, url string, hdr http.Header, opts Options) float64 {
+	ms, _ := probeTimed(ctx, client, url, hdr, opts)
+	return ms
+}
+
+// probeTimed is probe that also returns the time to the first response
+// byte, in ms. Both are -1 when the request fails.
+func probeTimed(ctx context.Context, client *http.Client, url string, hdr http.Header, opts Options) (total, ttfb float64) {
 	ctx2, cancel := context.WithTimeout(ctx, 3*time.Second)
 	defer cancel()
 
+	var first time.Time
+	ctx2 = httptrace.WithClientTrace(ctx2, &httptrace.ClientTrace{
+		GotFirstResponseByte: func() { first = time.Now() },
+	})
 	req, err := http.NewRequestWithContext(ctx2, http.MethodGet, url, nil)
 	if err != nil {
-		return -1
+		return -1, -1
+	}
+	if hdr == nil {
+		hdr = config.DefaultHeader()
 	}
-	req.Header.Set("User-Agent", config.UserAgent)
-	req.Header.Set("Accept", "*/*")
-	req.Header.Set("Accept-Language", "zh-CN,zh-Hans;q=0.9")
-	req.Header.Set("Accept-Encoding", "identity")
+	config.SetHeader(req, hdr)
+	if opts.Size > 0 {
+		req.Header.Set("Range", fmt.Sprintf("bytes=0-%d", opts.Size-1))
+	}
+	req.Close = opts.NewConn
 
 	start := time.Now()
 	resp, err := client.Do(req)
 	if err != nil {
-		return -1
+		return -1, -1
 	}
 	defer resp.Body.Close()
 	buf := make([]byte, 4096)
@@ -107,7 +219,12 @@ func probe(ctx context.Context, client *http.Client, url string) float64 {
 			break
 		}
 	}
-	return float64(time.Since(start).Microseconds()) / 1000.0
+	total = float64(time.Since(start).Microseconds()) / 1000.0
+	ttfb = total
+	if !first.IsZero() {
+		ttfb = float64(first.Sub(start).Microseconds()) / 1000.0
+	}
+	return total, ttfb
 }
 
 func Compute(samples []float64) Stats {
@@ -127,12 +244,7 @@ func Compute(samples []float64) Stats {
 	min := sorted[0]
 	max := sorted[n-1]
 
-	var med float64
-	if n%2 == 1 {
-		med = sorted[n/2]
-	} else {
-		med = (sorted[n/2-1] + sorted[n/2]) / 2
-	}
+	med := median(sorted)
 
 	var jitter float64
 	if n > 1 {
@@ -149,5 +261,94 @@ func Compute(samples []float64) Stats {
 		Max:    math.Round(max*100) / 100,
 		Jitter: math.Round(jitter*100) / 100,
 		N:      n,
+		Pct:    roundPercentiles(histogram.Of(samples).Percentiles()),
+	}
+}
+
+// Robust is Compute over the samples left once the first, which pays for
+// warming the connection up, is dropped and the outliers are rejected by
+// their distance from the median in median absolute deviations, so a single
+// GC pause or ARP miss no longer drags the average, the maximum and the
+// jitter along. Raw keeps the stats of every sample.
+func Robust(samples []float64) Stats {
+	if len(samples) == 0 {
+		return Stats{}
+	}
+	raw := Compute(samples)
+	kept, warmup, outliers := Filter(samples)
+	s := Compute(kept)
+	s.Raw, s.Warmup, s.Outliers = &raw, warmup, outliers
+	return s
+}
+
+// Filter returns the samples Robust keeps and how many it dropped as
+// warm-up and rejected as outliers. Fewer than three samples are kept
+// whole, and none is rejected when most of them are equal.
+func Filter(samples []float64) (kept []float64, warmup, outliers int) {
+	if len(samples) < 3 {
+		return samples, 0, 0
+	}
+	rest := samples[1:]
+	med := median(rest)
+	dev := make([]float64, len(rest))
+	for i, v := range rest {
+		dev[i] = math.Abs(v - med)
+	}
+	mad := median(dev)
+	if mad == 0 {
+		return rest, 1, 0
+	}
+	kept = make([]float64, 0, len(rest))
+	for i, v := range rest {
+		if 0.6745*dev[i]/mad > madCutoff {
+			outliers++
+			continue
+		}
+		kept = append(kept, v)
+	}
+	return kept, 1, outliers
+}
+
+func median(vs []float64) float64 {
+	sorted := make([]float64, len(vs))
+	copy(sorted, vs)
+	sort.Float64s(sorted)
+	n := len(sorted)
+	if n%2 == 1 {
+		return sorted[n/2]
+	}
+	return (sorted[n/2-1] + sorted[n/2]) / 2
+}
+
+func roundPercentiles(p histogram.Percentiles) histogram.Percentiles {
+	r := func(v float64) float64 { return math.Round(v*100) / 100 }
+	return histogram.Percentiles{P1: r(p.P1), P25: r(p.P25), P50: r(p.P50), P75: r(p.P75), P99: r(p.P99)}
+}
+
+// Correlation is Pearson's r between xs and ys, which must be the same
+// length. It is 0 with fewer than three pairs or when either side is
+// constant.
+func Correlation(xs, ys []float64) float64 {
+	n := len(xs)
+	if n < 3 || len(ys) != n {
+		return 0
+	}
+	var mx, my float64
+	for i := range xs {
+		mx += xs[i]
+		my += ys[i]
+	}
+	mx /= float64(n)
+	my /= float64(n)
+	var sxy, sxx, syy float64
+	for i := range xs {
+		dx, dy := xs[i]-mx, ys[i]-my
+		sxy += dx * dy
+		sxx += dx * dx
+		syy += dy * dy
+	}
+	if sxx == 0 || syy == 0 {
+		return 0
 	}
+	return sxy / math.Sqrt(sxx*syy)
 }
@@ -1,8 +1,14 @@
 package latency
 
 import (
+	"context"
 	"math"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"sync/atomic"
 	"testing"
+	"time"
 )
 
 func TestComputeEmpty(t *testing.T) {
@@ -42,6 +48,20 @@ func TestComputeOdd(t *testing.T) {
 	}
 }
 
+func TestComputePercentiles(t *testing.T) {
+	samples := make([]float64, 0, 100)
+	for i := 1; i <= 100; i++ {
+		samples = append(samples, float64(i))
+	}
+	// One slow probe shows in p99 but not in the median.
+	samples[99] = 500
+	p := Compute(samples).Pct
+	near := func(got, want float64) bool { return math.Abs(got-want) <= want*0.02 }
+	if !near(p.P1, 1) || !near(p.P25, 25) || !near(p.P50, 50) || !near(p.P75, 75) || !near(p.P99, 99) {
+		t.Errorf("Pct = %+v", p)
+	}
+}
+
 func TestComputeEven(t *testing.T) {
 	// 4 samples: median is average of middle two
 	s := Compute([]float64{10, 20, 30, 40})
@@ -65,3 +85,138 @@ func TestComputeAvg(t *testing.T) {
 		t.Errorf("Avg = %f, want %f", s.Avg, want)
 	}
 }
+
+func TestRobust(t *testing.T) {
+	// A slow first probe and one GC pause among steady samples.
+	s := Robust([]float64{80, 10, 11, 10.5, 9.5, 10, 250, 10.2, 9.8})
+	if s.Warmup != 1 || s.Outliers != 1 || s.N != 7 || s.Max != 11 || s.Avg != 10.14 {
+		t.Errorf("filtered %+v", s)
+	}
+	if s.Raw == nil || s.Raw.N != 9 || s.Raw.Max != 250 {
+		t.Errorf("raw %+v", s.Raw)
+	}
+	if s.Median != 10 || s.Jitter > 0.5 {
+		t.Errorf("median %v jitter %v", s.Median, s.Jitter)
+	}
+
+	// Too few samples to filter.
+	if s := Robust([]float64{80, 10}); s.N != 2 || s.Warmup != 0 || s.Raw.N != 2 {
+		t.Errorf("two samples: %+v", s)
+	}
+	// Equal samples leave no spread to judge outliers by.
+	if kept, warmup, outliers := Filter([]float64{5, 10, 10, 10, 40}); len(kept) != 4 || warmup != 1 || outliers != 0 {
+		t.Errorf("Filter = %v, %d, %d", kept, warmup, outliers)
+	}
+	if s := Robust(nil); s.N != 0 || s.Raw != nil {
+		t.Errorf("no samples: %+v", s)
+	}
+}
+
+func TestMeasureRate(t *testing.T) {
+	var hits atomic.Int64
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		hits.Add(1)
+		time.Sleep(5 * time.Millisecond)
+		w.Write([]byte("ok"))
+	}))
+	defer srv.Close()
+
+	seq := MeasureRate(context.Background(), srv.Client(), srv.URL, nil, 1, 200*time.Millisecond)
+	if seq.Requests < 5 || seq.Errors != 0 || seq.Latency.N != seq.Requests || seq.RPS <= 0 {
+		t.Errorf("sequential rate = %+v", seq)
+	}
+	if tt := seq.TTFB; tt.Min < 5 || tt.P50 < tt.Min || tt.P90 < tt.P50 || tt.Max < tt.P99 || tt.P50 > seq.Latency.Median {
+		t.Errorf("sequential TTFB = %+v, median %.2f ms", tt, seq.Latency.Median)
+	}
+	conc := MeasureRate(context.Background(), srv.Client(), srv.URL, nil, 4, 200*time.Millisecond)
+	if conc.Workers != 4 || conc.RPS < 2*seq.RPS {
+		t.Errorf("concurrent rate %.0f req/s not above sequential %.0f req/s", conc.RPS, seq.RPS)
+	}
+	if int(hits.Load()) < seq.Requests+conc.Requests {
+		t.Errorf("server saw %d requests, rates report %d", hits.Load(), seq.Requests+conc.Requests)
+	}
+}
+
+func TestPercentiles(t *testing.T) {
+	var samples []float64
+	for i := 100; i >= 1; i-- {
+		samples = append(samples, float64(i))
+	}
+	got := Percentiles(samples)
+	if want := (Spread{Min: 1, P50: 50, P90: 90, P99: 99, Max: 100}); got != want {
+		t.Errorf("Percentiles = %+v, want %+v", got, want)
+	}
+	if got := Percentiles(nil); got != (Spread{}) {
+		t.Errorf("Percentiles(nil) = %+v", got)
+	}
+}
+
+func TestProbeLast(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		time.Sleep(5 * time.Millisecond)
+	}))
+	defer srv.Close()
+
+	p := StartLoaded(context.Background(), srv.Client(), srv.URL)
+	deadline := time.Now().Add(2 * time.Second)
+	ms, n := p.Last()
+	for n == 0 && time.Now().Before(deadline) {
+		time.Sleep(5 * time.Millisecond)
+		ms, n = p.Last()
+	}
+	s := p.Stop()
+	if n == 0 || ms < 5 || s.Raw == nil || s.Raw.N < n {
+		t.Errorf("Last = %v, %d after %+v", ms, n, s)
+	}
+}
+
+func TestProbeOptions(t *testing.T) {
+	var conns atomic.Int64
+	var lastRange atomic.Value
+	srv := httptest.NewUnstartedServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		lastRange.Store(r.Header.Get("Range"))
+		w.Write([]byte("ok"))
+	}))
+	srv.Config.ConnState = func(_ net.Conn, s http.ConnState) {
+		if s == http.StateNew {
+			conns.Add(1)
+		}
+	}
+	srv.Start()
+	defer srv.Close()
+
+	start := time.Now()
+	s := MeasureIdleFunc(context.Background(), srv.Client(), srv.URL, nil, 4, Options{Interval: 30 * time.Millisecond, Size: 100, NewConn: true}, nil)
+	if s.Raw == nil || s.Raw.N != 4 || conns.Load() != 4 {
+		t.Errorf("new connections: %+v over %d connections", s, conns.Load())
+	}
+	if elapsed := time.Since(start); elapsed < 90*time.Millisecond {
+		t.Errorf("4 probes 30ms apart took %v", elapsed)
+	}
+	if got := lastRange.Load(); got != "bytes=0-99" {
+		t.Errorf("Range = %q", got)
+	}
+
+	conns.Store(0)
+	if s = MeasureIdleFunc(context.Background(), srv.Client(), srv.URL, nil, 4, Options{}, nil); s.Raw == nil || s.Raw.N != 4 || conns.Load() != 1 {
+		t.Errorf("reused connection: %+v over %d connections", s, conns.Load())
+	}
+	if got := lastRange.Load(); got != "" {
+		t.Errorf("Range = %q without a size", got)
+	}
+}
+
+func TestCorrelation(t *testing.T) {
+	if r := Correlation([]float64{1, 2, 3, 4}, []float64{10, 20, 30, 40}); math.Abs(r-1) > 1e-9 {
+		t.Errorf("linear r = %v", r)
+	}
+	if r := Correlation([]float64{1, 2, 3, 4}, []float64{8, 6, 4, 2}); math.Abs(r+1) > 1e-9 {
+		t.Errorf("inverse r = %v", r)
+	}
+	if r := Correlation([]float64{1, 2, 3}, []float64{5, 5, 5}); r != 0 {
+		t.Errorf("constant r = %v", r)
+	}
+	if r := Correlation([]float64{1, 2}, []float64{1, 2}); r != 0 {
+		t.Errorf("two-point r = %v", r)
+	}
+}
@@ -0,0 +1,95 @@
+package latency
+
+import (
+	"context"
+	"math"
+	"net/http"
+	"sort"
+	"sync"
+	"time"
+)
+
+// Rate is the outcome of a request-rate run: how many small requests
+// completed per second with the given number of requests in flight.
+type Rate struct {
+	Workers  int
+	Requests int
+	Errors   int
+	Duration time.Duration
+	RPS      float64
+	Latency  Stats
+	TTFB     Spread
+}
+
+// Spread is a distribution by percentiles, in ms.
+type Spread struct {
+	Min, P50, P90, P99, Max float64
+}
+
+// Percentiles returns the spread of samples; it is zero when there are none.
+// Percentiles are nearest-rank.
+func Percentiles(samples []float64) Spread {
+	n := len(samples)
+	if n == 0 {
+		return Spread{}
+	}
+	sorted := append([]float64(nil), samples...)
+	sort.Float64s(sorted)
+	rank := func(p float64) float64 {
+		i := int(math.Ceil(p*float64(n))) - 1
+		return math.Round(sorted[min(max(i, 0), n-1)]*100) / 100
+	}
+	return Spread{
+		Min: math.Round(sorted[0]*100) / 100,
+		P50: rank(0.50),
+		P90: rank(0.90),
+		P99: rank(0.99),
+		Max: math.Round(sorted[n-1]*100) / 100,
+	}
+}
+
+// MeasureRate issues back-to-back GETs of url from workers goroutines for d
+// and counts the completed ones, timing each to its end and to its first
+// response byte. With one worker it measures strictly
+// sequential request turnaround; more workers show how well the path and
+// server overlap requests.
+func MeasureRate(ctx context.Context, client *http.Client, url string, hdr http.Header, workers int, d time.Duration) Rate {
+	ctx2, cancel := context.WithTimeout(ctx, d)
+	defer cancel()
+
+	var (
+		mu      sync.Mutex
+		samples []float64
+		ttfbs   []float64
+		errs    int
+		wg      sync.WaitGroup
+	)
+	start := time.Now()
+	for i := 0; i < workers; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for ctx2.Err() == nil {
+				ms, ttfb := probeTimed(ctx2, client, url, hdr, Options{})
+				mu.Lock()
+				switch {
+				case ms >= 0:
+					samples = append(samples, ms)
+					ttfbs = append(ttfbs, ttfb)
+				case ctx2.Err() == nil:
+					// Requests cut off by the deadline are not failures.
+					errs++
+				}
+				mu.Unlock()
+			}
+		}()
+	}
+	wg.Wait()
+	elapsed := time.Since(start)
+
+	r := Rate{Workers: workers, Requests: len(samples), Errors: errs, Duration: elapsed, Latency: Compute(samples), TTFB: Percentiles(ttfbs)}
+	if elapsed > 0 {
+		r.RPS = float64(r.Requests) / elapsed.Seconds()
+	}
+	return r
+}
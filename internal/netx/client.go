@@ -5,26 +5,69 @@ import (
 	"crypto/tls"
 	"net"
 	"net/http"
+	"net/url"
 	"time"
 
 	"golang.org/x/net/http2"
+
+	"github.com/tsosunchia/iNetSpeed-CLI/internal/tcpinfo"
+)
+
+// Connection strategies for Options.Mode.
+const (
+	// ModeAuto uses HTTP/2 when the server offers it and lets the transport
+	// pool connections.
+	ModeAuto = ""
+	// ModeMulti speaks HTTP/1.1 only, so every concurrent request gets its
+	// own TCP connection.
+	ModeMulti = "multi"
+	// ModeSingleH2 carries every request as a stream of one HTTP/2
+	// connection (h2c with prior knowledge for http:// URLs).
+	ModeSingleH2 = "single-h2"
 )
 
 type Options struct {
 	PinHost string
 	PinIP   string
 	Timeout time.Duration
+	// Tracker, when set, records every dialed connection for TCP_INFO.
+	Tracker *tcpinfo.Tracker
+	Mode    string
+	// TLS, when set, supplies the roots, client certificates and
+	// verification setting; it is cloned, not modified.
+	TLS *tls.Config
+	// TOS is the IP TOS / traffic class byte of every connection; zero
+	// leaves the OS default.
+	TOS int
+	// Proxy, when set, chooses each request's proxy as http.Transport.Proxy
+	// does; unset, requests connect directly whatever the environment says.
+	Proxy func(*http.Request) (*url.URL, error)
+	// Observe, when set, is shown every response before the caller reads
+	// it; it must not touch the body.
+	Observe func(*http.Response)
+	// Sign, when set, may add to every request, on a copy, just before it
+	// is sent.
+	Sign func(*http.Request)
+	// Counter, when set, counts the bytes of every connection.
+	Counter *Counter
+	// Resolver, when set, looks up the hosts the client connects to in
+	// place of the system resolver.
+	Resolver *net.Resolver
 }
 
 func NewClient(opts Options) *http.Client {
 	dialer := &net.Dialer{
 		Timeout:   10 * time.Second,
 		KeepAlive: 30 * time.Second,
+		Control:   TOSControl(opts.TOS),
+		Resolver:  opts.Resolver,
 	}
 
-	tlsCfg := &tls.Config{
-		MinVersion: tls.VersionTLS12,
+	tlsCfg := &tls.Config{}
+	if opts.TLS != nil {
+		tlsCfg = opts.TLS.Clone()
 	}
+	tlsCfg.MinVersion = tls.VersionTLS12
 	if opts.PinHost != "" {
 		tlsCfg.ServerName = opts.PinHost
 	}
@@ -35,10 +78,15 @@ func NewClient(opts Options) *http.Client {
 		MaxIdleConns:        100,
 		MaxIdleConnsPerHost: 100,
 		IdleConnTimeout:     90 * time.Second,
+		Proxy:               opts.Proxy,
 	}
 
+	dial := dialer.DialContext
+	if opts.TOS != 0 || opts.Resolver != nil {
+		transport.DialContext = dial
+	}
 	if opts.PinHost != "" && opts.PinIP != "" {
-		transport.DialContext = func(ctx context.Context, network, addr string) (net.Conn, error) {
+		dial = func(ctx context.Context, network, addr string) (net.Conn, error) {
 			host, port, err := net.SplitHostPort(addr)
 			if err != nil {
 				return dialer.DialContext(ctx, network, addr)
@@ -48,12 +96,73 @@ func NewClient(opts Options) *http.Client {
 			}
 			return dialer.DialContext(ctx, network, addr)
 		}
+		transport.DialContext = dial
+	}
+	if opts.Tracker != nil {
+		pinned := dial
+		transport.DialContext = func(ctx context.Context, network, addr string) (net.Conn, error) {
+			c, err := pinned(ctx, network, addr)
+			if err != nil {
+				return nil, err
+			}
+			return opts.Tracker.Wrap(c), nil
+		}
+	}
+	if opts.Counter != nil {
+		inner := transport.DialContext
+		if inner == nil {
+			inner = dial
+		}
+		transport.DialContext = func(ctx context.Context, network, addr string) (net.Conn, error) {
+			c, err := inner(ctx, network, addr)
+			if err != nil {
+				return nil, err
+			}
+			return &countedConn{Conn: c, c: opts.Counter}, nil
+		}
 	}
 
-	_ = http2.ConfigureTransport(transport)
+	switch opts.Mode {
+	case ModeMulti:
+		transport.Protocols = new(http.Protocols)
+		transport.Protocols.SetHTTP1(true)
+	case ModeSingleH2:
+		// For HTTP/2 the limit only serializes dials: once the first
+		// connection is up, every waiting request shares it.
+		transport.MaxConnsPerHost = 1
+		transport.Protocols = new(http.Protocols)
+		transport.Protocols.SetHTTP2(true)
+		transport.Protocols.SetUnencryptedHTTP2(true)
+	default:
+		_ = http2.ConfigureTransport(transport)
+	}
 
+	var rt http.RoundTripper = transport
+	if opts.Observe != nil || opts.Sign != nil {
+		rt = &observer{Transport: transport, fn: opts.Observe, sign: opts.Sign}
+	}
 	return &http.Client{
-		Transport: transport,
+		Transport: rt,
 		Timeout:   opts.Timeout,
 	}
 }
+
+// observer passes each request to sign on its way out and each response
+// to fn on its way to the client.
+type observer struct {
+	*http.Transport
+	fn   func(*http.Response)
+	sign func(*http.Request)
+}
+
+func (o *observer) RoundTrip(req *http.Request) (*http.Response, error) {
+	if o.sign != nil {
+		req = req.Clone(req.Context())
+		o.sign(req)
+	}
+	resp, err := o.Transport.RoundTrip(req)
+	if err == nil && o.fn != nil {
+		o.fn(resp)
+	}
+	return resp, err
+}
@@ -0,0 +1,226 @@
+package netx
+
+import (
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/tls"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"io"
+	"math/big"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/tsosunchia/iNetSpeed-CLI/internal/simulate"
+	"github.com/tsosunchia/iNetSpeed-CLI/internal/tcpinfo"
+)
+
+func TestClientModes(t *testing.T) {
+	opts := simulate.DefaultOptions()
+	opts.Bandwidth = 0
+	opts.Latency = 20 * time.Millisecond
+	opts.LargeSize = 256 << 10
+	srv := simulate.Start(opts)
+	defer srv.Close()
+
+	tests := []struct {
+		mode      string
+		proto     string
+		wantConns int
+	}{
+		{ModeMulti, "HTTP/1.1", 4},
+		{ModeSingleH2, "HTTP/2.0", 1},
+	}
+	for _, tt := range tests {
+		t.Run(tt.mode, func(t *testing.T) {
+			tr := tcpinfo.NewTracker()
+			client := NewClient(Options{Timeout: 5 * time.Second, Tracker: tr, Mode: tt.mode})
+			var wg sync.WaitGroup
+			for range 4 {
+				wg.Add(1)
+				go func() {
+					defer wg.Done()
+					resp, err := client.Get(srv.DownloadURL())
+					if err != nil {
+						t.Error(err)
+						return
+					}
+					defer resp.Body.Close()
+					if resp.Proto != tt.proto {
+						t.Errorf("proto = %s, want %s", resp.Proto, tt.proto)
+					}
+					io.Copy(io.Discard, resp.Body)
+				}()
+			}
+			wg.Wait()
+			if n := len(tr.Collect()); n != tt.wantConns {
+				t.Errorf("connections = %d, want %d", n, tt.wantConns)
+			}
+			client.Transport.(*http.Transport).CloseIdleConnections()
+		})
+	}
+}
+
+func TestClientSign(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte(r.URL.RawQuery + " " + r.Header.Get("X-Token")))
+	}))
+	defer srv.Close()
+	client := NewClient(Options{Timeout: 5 * time.Second, Sign: func(r *http.Request) {
+		r.URL.RawQuery = "token=abc"
+		r.Header.Set("X-Token", "abc")
+	}})
+	req, _ := http.NewRequest(http.MethodGet, srv.URL+"/small", nil)
+	resp, err := client.Do(req)
+	if err != nil {
+		t.Fatal(err)
+	}
+	body, _ := io.ReadAll(resp.Body)
+	resp.Body.Close()
+	if string(body) != "token=abc abc" {
+		t.Errorf("server saw %q", body)
+	}
+	if req.URL.RawQuery != "" || req.Header.Get("X-Token") != "" {
+		t.Errorf("caller's request changed: %s %v", req.URL, req.Header)
+	}
+}
+
+func TestClientCounter(t *testing.T) {
+	opts := simulate.DefaultOptions()
+	opts.Bandwidth = 0
+	opts.Latency = 0
+	opts.LargeSize = 256 << 10
+	srv := simulate.Start(opts)
+	defer srv.Close()
+
+	var c Counter
+	client := NewClient(Options{Timeout: 5 * time.Second, Counter: &c})
+	resp, err := client.Get(srv.DownloadURL())
+	if err != nil {
+		t.Fatal(err)
+	}
+	n, _ := io.Copy(io.Discard, resp.Body)
+	resp.Body.Close()
+	// The body, the response headers and the request.
+	if n != 256<<10 || c.Total() <= n || c.Total() > n+4096 {
+		t.Errorf("counted %d bytes for a %d-byte body", c.Total(), n)
+	}
+	if (*Counter)(nil).Total() != 0 {
+		t.Error("nil Counter counted")
+	}
+}
+
+func TestCountedConnReadFrom(t *testing.T) {
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer ln.Close()
+	got := make(chan int64, 1)
+	go func() {
+		c, err := ln.Accept()
+		if err != nil {
+			got <- -1
+			return
+		}
+		n, _ := io.Copy(io.Discard, c)
+		c.Close()
+		got <- n
+	}()
+	raw, err := net.Dial("tcp", ln.Addr().String())
+	if err != nil {
+		t.Fatal(err)
+	}
+	var counter Counter
+	var c net.Conn = &countedConn{Conn: raw, c: &counter}
+	// Without it *net.TCPConn's sendfile path is out of reach.
+	rf, ok := c.(io.ReaderFrom)
+	if !ok {
+		t.Fatal("counted connection hides io.ReaderFrom")
+	}
+	if n, err := rf.ReadFrom(strings.NewReader(strings.Repeat("x", 5000))); n != 5000 || err != nil {
+		t.Fatalf("ReadFrom = %d, %v", n, err)
+	}
+	c.Close()
+	if n := <-got; n != 5000 || counter.Total() != 5000 {
+		t.Errorf("server read %d, counted %d", n, counter.Total())
+	}
+}
+
+func TestClientTLS(t *testing.T) {
+	clientCert := selfSigned(t)
+	srv := httptest.NewUnstartedServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		io.WriteString(w, r.TLS.PeerCertificates[0].Subject.CommonName)
+	}))
+	pool := x509.NewCertPool()
+	pool.AddCert(clientCert.Leaf)
+	srv.TLS = &tls.Config{ClientAuth: tls.RequireAndVerifyClientCert, ClientCAs: pool}
+	srv.StartTLS()
+	defer srv.Close()
+
+	roots := x509.NewCertPool()
+	roots.AddCert(srv.Certificate())
+	tests := []struct {
+		name string
+		tls  *tls.Config
+		ok   bool
+	}{
+		{"system roots", nil, false},
+		{"no client cert", &tls.Config{RootCAs: roots}, false},
+		{"mutual", &tls.Config{RootCAs: roots, Certificates: []tls.Certificate{clientCert}}, true},
+		{"insecure", &tls.Config{InsecureSkipVerify: true, Certificates: []tls.Certificate{clientCert}}, true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			client := NewClient(Options{Timeout: 5 * time.Second, TLS: tt.tls})
+			resp, err := client.Get(srv.URL)
+			if !tt.ok {
+				if err == nil {
+					resp.Body.Close()
+					t.Fatal("request succeeded")
+				}
+				return
+			}
+			if err != nil {
+				t.Fatal(err)
+			}
+			defer resp.Body.Close()
+			if b, _ := io.ReadAll(resp.Body); string(b) != "probe" {
+				t.Errorf("peer = %q", b)
+			}
+		})
+	}
+}
+
+func selfSigned(t *testing.T) tls.Certificate {
+	t.Helper()
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+	tmpl := &x509.Certificate{
+		SerialNumber:          big.NewInt(1),
+		Subject:               pkix.Name{CommonName: "probe"},
+		NotBefore:             time.Now().Add(-time.Hour),
+		NotAfter:              time.Now().Add(time.Hour),
+		KeyUsage:              x509.KeyUsageDigitalSignature | x509.KeyUsageCertSign,
+		ExtKeyUsage:           []x509.ExtKeyUsage{x509.ExtKeyUsageClientAuth},
+		BasicConstraintsValid: true,
+		IsCA:                  true,
+	}
+	der, err := x509.CreateCertificate(rand.Reader, tmpl, tmpl, &key.PublicKey, key)
+	if err != nil {
+		t.Fatal(err)
+	}
+	leaf, err := x509.ParseCertificate(der)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return tls.Certificate{Certificate: [][]byte{der}, PrivateKey: key, Leaf: leaf}
+}
@@ -0,0 +1,54 @@
+package netx
+
+import (
+	"io"
+	"net"
+	"sync/atomic"
+)
+
+// Counter adds up the bytes read and written by the connections of every
+// client it is given to: payload, probes, HTTP and TLS framing alike. The
+// nil Counter counts nothing.
+type Counter struct {
+	n atomic.Int64
+}
+
+// Total returns the bytes counted so far.
+func (c *Counter) Total() int64 {
+	if c == nil {
+		return 0
+	}
+	return c.n.Load()
+}
+
+// countedConn is a connection whose traffic adds to a Counter.
+type countedConn struct {
+	net.Conn
+	c *Counter
+}
+
+func (cc *countedConn) Read(p []byte) (int, error) {
+	n, err := cc.Conn.Read(p)
+	cc.c.n.Add(int64(n))
+	return n, err
+}
+
+func (cc *countedConn) Write(p []byte) (int, error) {
+	n, err := cc.Conn.Write(p)
+	cc.c.n.Add(int64(n))
+	return n, err
+}
+
+// ReadFrom keeps the connection's own io.ReaderFrom, such as the sendfile
+// path of *net.TCPConn, reachable through the wrapper.
+func (cc *countedConn) ReadFrom(r io.Reader) (int64, error) {
+	var n int64
+	var err error
+	if rf, ok := cc.Conn.(io.ReaderFrom); ok {
+		n, err = rf.ReadFrom(r)
+	} else {
+		n, err = io.Copy(cc.Conn, r)
+	}
+	cc.c.n.Add(n)
+	return n, err
+}
@@ -0,0 +1,22 @@
+package netx
+
+import (
+	"context"
+	"net"
+)
+
+// NewResolver returns a resolver that sends its queries to server, a
+// host:port of a DNS server, rather than to the ones the OS is set up
+// with; nil, the system resolver, when server is empty.
+func NewResolver(server string) *net.Resolver {
+	if server == "" {
+		return nil
+	}
+	return &net.Resolver{
+		PreferGo: true,
+		Dial: func(ctx context.Context, network, _ string) (net.Conn, error) {
+			var d net.Dialer
+			return d.DialContext(ctx, network, server)
+		},
+	}
+}
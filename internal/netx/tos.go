@@ -0,0 +1,30 @@
+package netx
+
+import (
+	"net"
+	"strings"
+	"syscall"
+)
+
+// TOSControl returns a Dialer/ListenConfig Control function that sets the
+// IPv4 TOS byte or IPv6 traffic class of each socket to tos, i.e. the DSCP
+// shifted left by two. It returns nil for zero, the OS default.
+func TOSControl(tos int) func(network, address string, c syscall.RawConn) error {
+	if tos == 0 {
+		return nil
+	}
+	return func(network, address string, c syscall.RawConn) error {
+		v6 := strings.HasSuffix(network, "6")
+		if !v6 && !strings.HasSuffix(network, "4") {
+			// "tcp" or "udp": the address the dialer resolved decides.
+			host, _, err := net.SplitHostPort(address)
+			ip := net.ParseIP(host)
+			v6 = err == nil && ip != nil && ip.To4() == nil
+		}
+		var serr error
+		if err := c.Control(func(fd uintptr) { serr = setTOS(fd, v6, tos) }); err != nil {
+			return err
+		}
+		return serr
+	}
+}
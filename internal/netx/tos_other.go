@@ -0,0 +1,13 @@
+//go:build !linux && !darwin
+
+package netx
+
+import "errors"
+
+// TOSSupported reports whether Options.TOS and TOSControl take effect here.
+// Windows ignores IP_TOS from applications; marking there needs a QoS policy.
+const TOSSupported = false
+
+func setTOS(uintptr, bool, int) error {
+	return errors.New("DSCP marking is not supported on this platform")
+}
@@ -0,0 +1,15 @@
+//go:build linux || darwin
+
+package netx
+
+import "syscall"
+
+// TOSSupported reports whether Options.TOS and TOSControl take effect here.
+const TOSSupported = true
+
+func setTOS(fd uintptr, v6 bool, tos int) error {
+	if v6 {
+		return syscall.SetsockoptInt(int(fd), syscall.IPPROTO_IPV6, syscall.IPV6_TCLASS, tos)
+	}
+	return syscall.SetsockoptInt(int(fd), syscall.IPPROTO_IP, syscall.IP_TOS, tos)
+}
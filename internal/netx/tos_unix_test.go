@@ -0,0 +1,44 @@
+//go:build linux || darwin
+
+package netx
+
+import (
+	"net"
+	"syscall"
+	"testing"
+)
+
+func TestTOSControl(t *testing.T) {
+	if TOSControl(0) != nil {
+		t.Error("TOS 0 should leave sockets alone")
+	}
+	for _, network := range []string{"tcp4", "tcp6"} {
+		ln, err := net.Listen(network, "localhost:0")
+		if err != nil {
+			t.Logf("%s: %v", network, err)
+			continue
+		}
+		defer ln.Close()
+		d := net.Dialer{Control: TOSControl(0xb8)}
+		c, err := d.Dial("tcp", ln.Addr().String())
+		if err != nil {
+			t.Fatal(err)
+		}
+		defer c.Close()
+		raw, err := c.(*net.TCPConn).SyscallConn()
+		if err != nil {
+			t.Fatal(err)
+		}
+		var got int
+		raw.Control(func(fd uintptr) {
+			if network == "tcp6" {
+				got, err = syscall.GetsockoptInt(int(fd), syscall.IPPROTO_IPV6, syscall.IPV6_TCLASS)
+			} else {
+				got, err = syscall.GetsockoptInt(int(fd), syscall.IPPROTO_IP, syscall.IP_TOS)
+			}
+		})
+		if err != nil || got != 0xb8 {
+			t.Errorf("%s: TOS = %#x, %v; want 0xb8", network, got, err)
+		}
+	}
+}
@@ -0,0 +1,101 @@
+// Package overhead estimates the line rate behind a measured goodput: the
+// bytes each layer under HTTP adds on the way to the wire, so a result can
+// be held against an advertised link speed. A gigabit Ethernet link carries
+// about 940 Mbps of TCP payload; the other 6% are headers and framing.
+package overhead
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+)
+
+// Per-packet and per-record overheads of the layers above the link.
+const (
+	ipv4Header = 20
+	ipv6Header = 40
+	// tcpHeader includes the 12 bytes of the timestamp option, on by
+	// default on Linux, macOS and Windows servers alike.
+	tcpHeader = 32
+	// A full TLS 1.3 record carries 16 KiB with a 5-byte header, a content
+	// type byte and a 16-byte AEAD tag.
+	tlsRecord   = 16384
+	tlsOverhead = 22
+	// An HTTP/2 DATA frame carries 16 KiB, the default maximum, behind a
+	// 9-byte header.
+	h2Frame    = 16384
+	h2Overhead = 9
+)
+
+// Link is the framing under IP: the bytes each packet costs on the medium
+// beyond the IP packet itself, and the largest IP packet.
+type Link struct {
+	Name  string
+	Bytes int
+	MTU   int
+}
+
+// Links are the named link models. Ethernet counts the 14-byte header, the
+// 4-byte FCS, the 8-byte preamble and the 12-byte inter-frame gap; VLAN adds
+// an 802.1Q tag; PPPoE adds its 8 bytes and shrinks the MTU to fit them. IP
+// counts no link framing: the rate an IP-level shaper sees.
+var Links = map[string]Link{
+	"ethernet": {Name: "ethernet", Bytes: 38, MTU: 1500},
+	"vlan":     {Name: "vlan", Bytes: 42, MTU: 1500},
+	"pppoe":    {Name: "pppoe", Bytes: 46, MTU: 1492},
+	"ip":       {Name: "ip", Bytes: 0, MTU: 1500},
+}
+
+// DefaultLink is the link assumed unless told otherwise.
+const DefaultLink = "ethernet"
+
+// ParseLink reads a link model: one of Links, or BYTES/MTU for any other
+// framing, such as 38/9000 for Ethernet jumbo frames.
+func ParseLink(s string) (Link, error) {
+	s = strings.ToLower(strings.TrimSpace(s))
+	if l, ok := Links[s]; ok {
+		return l, nil
+	}
+	b, m, ok := strings.Cut(s, "/")
+	if ok {
+		bytes, err1 := strconv.Atoi(b)
+		mtu, err2 := strconv.Atoi(m)
+		if err1 == nil && err2 == nil && bytes >= 0 && bytes <= 1000 && mtu >= 576 && mtu <= 65535 {
+			return Link{Name: s, Bytes: bytes, MTU: mtu}, nil
+		}
+	}
+	return Link{}, fmt.Errorf("unknown link model %q", s)
+}
+
+// Stack is the path a transfer's payload took down to the link.
+type Stack struct {
+	Link  Link
+	IPv6  bool
+	TLS   bool
+	HTTP2 bool
+}
+
+// Factor is the bytes on the wire per byte of payload.
+func (s Stack) Factor() float64 {
+	ip := ipv4Header
+	if s.IPv6 {
+		ip = ipv6Header
+	}
+	mss := s.Link.MTU - ip - tcpHeader
+	if mss <= 0 {
+		return 1
+	}
+	f := float64(s.Link.MTU+s.Link.Bytes) / float64(mss)
+	if s.TLS {
+		f *= float64(tlsRecord+tlsOverhead) / tlsRecord
+	}
+	if s.HTTP2 {
+		f *= float64(h2Frame+h2Overhead) / h2Frame
+	}
+	return f
+}
+
+// LineRate is the rate the link carried to deliver goodput.
+func (s Stack) LineRate(goodput float64) float64 {
+	return goodput * s.Factor()
+}
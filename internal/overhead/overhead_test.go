@@ -0,0 +1,52 @@
+package overhead
+
+import (
+	"math"
+	"testing"
+)
+
+func TestFactor(t *testing.T) {
+	eth := Links["ethernet"]
+	tests := []struct {
+		stack Stack
+		want  float64
+	}{
+		// 1538 bytes on the wire for 1448 of payload.
+		{Stack{Link: eth}, 1538.0 / 1448},
+		{Stack{Link: eth, IPv6: true}, 1538.0 / 1428},
+		{Stack{Link: eth, TLS: true}, 1538.0 / 1448 * 16406 / 16384},
+		{Stack{Link: eth, TLS: true, HTTP2: true}, 1538.0 / 1448 * 16406 / 16384 * 16393 / 16384},
+		{Stack{Link: Links["pppoe"]}, 1538.0 / 1440},
+		{Stack{Link: Links["ip"]}, 1500.0 / 1448},
+		{Stack{Link: Link{MTU: 40}}, 1},
+	}
+	for _, tt := range tests {
+		if got := tt.stack.Factor(); math.Abs(got-tt.want) > 1e-12 {
+			t.Errorf("%+v: factor %v, want %v", tt.stack, got, tt.want)
+		}
+	}
+	// The gigabit Ethernet rule of thumb.
+	if got := (Stack{Link: eth, TLS: true}).LineRate(940); got < 998 || got > 1000 {
+		t.Errorf("940 Mbps of goodput over TLS on Ethernet = %.1f Mbps line rate", got)
+	}
+}
+
+func TestParseLink(t *testing.T) {
+	for in, want := range map[string]Link{
+		"Ethernet": Links["ethernet"],
+		" pppoe ":  Links["pppoe"],
+		"38/9000":  {Name: "38/9000", Bytes: 38, MTU: 9000},
+		"0/1280":   {Name: "0/1280", Bytes: 0, MTU: 1280},
+		"vlan":     Links["vlan"],
+		"ip":       Links["ip"],
+	} {
+		if got, err := ParseLink(in); err != nil || got != want {
+			t.Errorf("ParseLink(%q) = %+v, %v", in, got, err)
+		}
+	}
+	for _, in := range []string{"", "wifi", "38", "38/100", "-1/1500", "x/1500"} {
+		if _, err := ParseLink(in); err == nil {
+			t.Errorf("ParseLink(%q) accepted", in)
+		}
+	}
+}
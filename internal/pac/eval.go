@@ -0,0 +1,491 @@
+package pac
+
+import (
+	"errors"
+	"fmt"
+	"math"
+	"net"
+	"regexp"
+	"strconv"
+	"strings"
+)
+
+// value is a JavaScript value: string, float64, bool or nil (null and
+// undefined alike).
+type value any
+
+// maxDepth bounds the call depth, so a runaway recursive PAC file fails
+// instead of exhausting the stack.
+const maxDepth = 64
+
+type scope struct {
+	vars   map[string]value
+	funcs  map[string]*funcDecl
+	parent *scope
+}
+
+func newScope(parent *scope) *scope {
+	return &scope{vars: map[string]value{}, funcs: map[string]*funcDecl{}, parent: parent}
+}
+
+func (s *scope) lookup(name string) (*scope, bool) {
+	for ; s != nil; s = s.parent {
+		if _, ok := s.vars[name]; ok {
+			return s, true
+		}
+	}
+	return nil, false
+}
+
+func (s *scope) function(name string) *funcDecl {
+	for ; s != nil; s = s.parent {
+		if f := s.funcs[name]; f != nil {
+			return f
+		}
+	}
+	return nil
+}
+
+type interp struct {
+	script *Script
+	global *scope
+	depth  int
+}
+
+// exec runs a statement; returned reports a return statement, with its value.
+func (in *interp) exec(s stmt, sc *scope) (v value, returned bool, err error) {
+	switch s := s.(type) {
+	case *funcDecl:
+		sc.funcs[s.name] = s
+	case *varStmt:
+		for i, name := range s.names {
+			var v value
+			if s.inits[i] != nil {
+				if v, err = in.eval(s.inits[i], sc); err != nil {
+					return nil, false, err
+				}
+			}
+			sc.vars[name] = v
+		}
+	case *exprStmt:
+		_, err = in.eval(s.x, sc)
+	case *returnStmt:
+		if s.x != nil {
+			v, err = in.eval(s.x, sc)
+		}
+		return v, true, err
+	case *blockStmt:
+		return in.execList(s.list, sc)
+	case *ifStmt:
+		c, err := in.eval(s.cond, sc)
+		if err != nil {
+			return nil, false, err
+		}
+		if truthy(c) {
+			return in.exec(s.then, sc)
+		}
+		if s.els != nil {
+			return in.exec(s.els, sc)
+		}
+	}
+	return nil, false, err
+}
+
+func (in *interp) execList(list []stmt, sc *scope) (value, bool, error) {
+	for _, s := range list {
+		v, returned, err := in.exec(s, sc)
+		if err != nil || returned {
+			return v, returned, err
+		}
+	}
+	return nil, false, nil
+}
+
+func (in *interp) eval(x expr, sc *scope) (value, error) {
+	switch x := x.(type) {
+	case *literal:
+		return x.v, nil
+	case *ident:
+		if owner, ok := sc.lookup(x.name); ok {
+			return owner.vars[x.name], nil
+		}
+		return nil, fmt.Errorf("%s is not defined", x.name)
+	case *assign:
+		v, err := in.eval(x.x, sc)
+		if err != nil {
+			return nil, err
+		}
+		owner, ok := sc.lookup(x.name)
+		if !ok {
+			owner = in.global
+		}
+		owner.vars[x.name] = v
+		return v, nil
+	case *unary:
+		v, err := in.eval(x.x, sc)
+		if err != nil {
+			return nil, err
+		}
+		switch x.op {
+		case "!":
+			return !truthy(v), nil
+		case "-":
+			return -number(v), nil
+		}
+		return number(v), nil
+	case *ternary:
+		c, err := in.eval(x.cond, sc)
+		if err != nil {
+			return nil, err
+		}
+		if truthy(c) {
+			return in.eval(x.a, sc)
+		}
+		return in.eval(x.b, sc)
+	case *binary:
+		return in.binary(x, sc)
+	case *member:
+		v, err := in.eval(x.x, sc)
+		if err != nil {
+			return nil, err
+		}
+		if s, ok := v.(string); ok && x.name == "length" {
+			return float64(len(s)), nil
+		}
+		return nil, fmt.Errorf("property %s of %s is not supported", x.name, typeName(v))
+	case *call:
+		args := make([]value, len(x.args))
+		for i, a := range x.args {
+			v, err := in.eval(a, sc)
+			if err != nil {
+				return nil, err
+			}
+			args[i] = v
+		}
+		switch fn := x.fn.(type) {
+		case *ident:
+			return in.call(fn.name, args, sc)
+		case *member:
+			recv, err := in.eval(fn.x, sc)
+			if err != nil {
+				return nil, err
+			}
+			return method(recv, fn.name, args)
+		}
+		return nil, errors.New("call of a non-function")
+	}
+	return nil, fmt.Errorf("unsupported expression %T", x)
+}
+
+func (in *interp) binary(x *binary, sc *scope) (value, error) {
+	a, err := in.eval(x.x, sc)
+	if err != nil {
+		return nil, err
+	}
+	// && and || short-circuit and yield an operand, as in JavaScript.
+	switch x.op {
+	case "&&":
+		if !truthy(a) {
+			return a, nil
+		}
+		return in.eval(x.y, sc)
+	case "||":
+		if truthy(a) {
+			return a, nil
+		}
+		return in.eval(x.y, sc)
+	}
+	b, err := in.eval(x.y, sc)
+	if err != nil {
+		return nil, err
+	}
+	switch x.op {
+	case "===":
+		return a == b, nil
+	case "!==":
+		return a != b, nil
+	case "==":
+		return looseEqual(a, b), nil
+	case "!=":
+		return !looseEqual(a, b), nil
+	case "+":
+		sa, aStr := a.(string)
+		sb, bStr := b.(string)
+		if aStr || bStr {
+			if !aStr {
+				sa = str(a)
+			}
+			if !bStr {
+				sb = str(b)
+			}
+			return sa + sb, nil
+		}
+		return number(a) + number(b), nil
+	case "-":
+		return number(a) - number(b), nil
+	case "*":
+		return number(a) * number(b), nil
+	case "/":
+		return number(a) / number(b), nil
+	case "%":
+		return math.Mod(number(a), number(b)), nil
+	}
+	sa, aStr := a.(string)
+	sb, bStr := b.(string)
+	var less, equal bool
+	if aStr && bStr {
+		less, equal = sa < sb, sa == sb
+	} else {
+		na, nb := number(a), number(b)
+		less, equal = na < nb, na == nb
+	}
+	switch x.op {
+	case "<":
+		return less, nil
+	case ">":
+		return !less && !equal, nil
+	case "<=":
+		return less || equal, nil
+	}
+	return !less, nil
+}
+
+// call invokes a function declared in the script or a PAC helper.
+func (in *interp) call(name string, args []value, sc *scope) (value, error) {
+	if f := sc.function(name); f != nil {
+		if in.depth >= maxDepth {
+			return nil, errors.New("call depth exceeded")
+		}
+		in.depth++
+		defer func() { in.depth-- }()
+		local := newScope(in.global)
+		for i, p := range f.params {
+			var v value
+			if i < len(args) {
+				v = args[i]
+			}
+			local.vars[p] = v
+		}
+		v, _, err := in.execList(f.body, local)
+		return v, err
+	}
+	return in.builtin(name, args)
+}
+
+// builtin implements the PAC helper functions.
+func (in *interp) builtin(name string, args []value) (value, error) {
+	arg := func(i int) string {
+		if i < len(args) {
+			return str(args[i])
+		}
+		return ""
+	}
+	switch name {
+	case "isPlainHostName":
+		return !strings.Contains(arg(0), "."), nil
+	case "dnsDomainIs":
+		return strings.HasSuffix(strings.ToLower(arg(0)), strings.ToLower(arg(1))), nil
+	case "localHostOrDomainIs":
+		h, hd := strings.ToLower(arg(0)), strings.ToLower(arg(1))
+		return h == hd || !strings.Contains(h, ".") && strings.HasPrefix(hd, h+"."), nil
+	case "dnsDomainLevels":
+		return float64(strings.Count(arg(0), ".")), nil
+	case "shExpMatch":
+		return shExpMatch(arg(0), arg(1)), nil
+	case "isResolvable":
+		return in.resolve(arg(0)) != "", nil
+	case "dnsResolve":
+		if ip := in.resolve(arg(0)); ip != "" {
+			return ip, nil
+		}
+		return nil, nil
+	case "myIpAddress":
+		return in.script.MyIP(), nil
+	case "isInNet":
+		return isInNet(in.resolve(arg(0)), arg(1), arg(2)), nil
+	case "convert_addr":
+		ip := net.ParseIP(arg(0)).To4()
+		if ip == nil {
+			return 0.0, nil
+		}
+		return float64(uint32(ip[0])<<24 | uint32(ip[1])<<16 | uint32(ip[2])<<8 | uint32(ip[3])), nil
+	case "alert":
+		return nil, nil
+	case "weekdayRange", "dateRange", "timeRange":
+		return nil, fmt.Errorf("%s is not supported", name)
+	}
+	return nil, fmt.Errorf("%s is not defined", name)
+}
+
+// resolve returns host itself when it is an IP address.
+func (in *interp) resolve(host string) string {
+	if net.ParseIP(host) != nil {
+		return host
+	}
+	return in.script.Resolve(host)
+}
+
+// method implements the string methods PAC files use.
+func method(recv value, name string, args []value) (value, error) {
+	s, ok := recv.(string)
+	if !ok {
+		return nil, fmt.Errorf("%s.%s is not supported", typeName(recv), name)
+	}
+	arg := func(i int) string {
+		if i < len(args) {
+			return str(args[i])
+		}
+		return ""
+	}
+	num := func(i int, def float64) int {
+		if i < len(args) && args[i] != nil {
+			def = number(args[i])
+		}
+		if math.IsNaN(def) {
+			return 0
+		}
+		return int(math.Max(0, math.Min(def, float64(len(s)))))
+	}
+	switch name {
+	case "toLowerCase":
+		return strings.ToLower(s), nil
+	case "toUpperCase":
+		return strings.ToUpper(s), nil
+	case "indexOf":
+		return float64(strings.Index(s, arg(0))), nil
+	case "lastIndexOf":
+		return float64(strings.LastIndex(s, arg(0))), nil
+	case "startsWith":
+		return strings.HasPrefix(s, arg(0)), nil
+	case "endsWith":
+		return strings.HasSuffix(s, arg(0)), nil
+	case "includes":
+		return strings.Contains(s, arg(0)), nil
+	case "charAt":
+		i := num(0, 0)
+		if i >= len(s) {
+			return "", nil
+		}
+		return s[i : i+1], nil
+	case "substring":
+		a, b := num(0, 0), num(1, float64(len(s)))
+		if a > b {
+			a, b = b, a
+		}
+		return s[a:b], nil
+	case "substr":
+		a := num(0, 0)
+		n := len(s) - a
+		if len(args) > 1 {
+			n = min(max(int(number(args[1])), 0), n)
+		}
+		return s[a : a+n], nil
+	case "trim":
+		return strings.TrimSpace(s), nil
+	}
+	return nil, fmt.Errorf("string method %s is not supported", name)
+}
+
+// shExpMatch matches a shell expression: * is any run of characters and ?
+// any one character.
+func shExpMatch(s, pattern string) bool {
+	var b strings.Builder
+	b.WriteString("^")
+	for _, r := range pattern {
+		switch r {
+		case '*':
+			b.WriteString(".*")
+		case '?':
+			b.WriteString(".")
+		default:
+			b.WriteString(regexp.QuoteMeta(string(r)))
+		}
+	}
+	b.WriteString("$")
+	re, err := regexp.Compile(b.String())
+	return err == nil && re.MatchString(s)
+}
+
+func isInNet(ip, pattern, mask string) bool {
+	a, p, m := net.ParseIP(ip).To4(), net.ParseIP(pattern).To4(), net.ParseIP(mask).To4()
+	if a == nil || p == nil || m == nil {
+		return false
+	}
+	for i := range a {
+		if a[i]&m[i] != p[i]&m[i] {
+			return false
+		}
+	}
+	return true
+}
+
+func truthy(v value) bool {
+	switch v := v.(type) {
+	case bool:
+		return v
+	case string:
+		return v != ""
+	case float64:
+		return v != 0 && !math.IsNaN(v)
+	}
+	return false
+}
+
+func number(v value) float64 {
+	switch v := v.(type) {
+	case float64:
+		return v
+	case bool:
+		if v {
+			return 1
+		}
+		return 0
+	case string:
+		s := strings.TrimSpace(v)
+		if s == "" {
+			return 0
+		}
+		if n, err := strconv.ParseFloat(s, 64); err == nil {
+			return n
+		}
+	}
+	return math.NaN()
+}
+
+func str(v value) string {
+	switch v := v.(type) {
+	case string:
+		return v
+	case float64:
+		return strconv.FormatFloat(v, 'f', -1, 64)
+	case bool:
+		return strconv.FormatBool(v)
+	}
+	return "undefined"
+}
+
+// looseEqual is JavaScript's ==: null equals only null, and mixed types
+// compare as numbers.
+func looseEqual(a, b value) bool {
+	if a == nil || b == nil {
+		return a == nil && b == nil
+	}
+	sa, aStr := a.(string)
+	sb, bStr := b.(string)
+	if aStr && bStr {
+		return sa == sb
+	}
+	return number(a) == number(b)
+}
+
+func typeName(v value) string {
+	switch v.(type) {
+	case string:
+		return "string"
+	case float64:
+		return "number"
+	case bool:
+		return "boolean"
+	}
+	return "undefined"
+}
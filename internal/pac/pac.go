@@ -0,0 +1,216 @@
+// Package pac evaluates proxy auto-config files, the FindProxyForURL
+// JavaScript function enterprise networks publish to choose a proxy per URL.
+// It interprets the subset of JavaScript PAC files are written in: function
+// and var declarations, if/else, return, string, number and boolean
+// expressions, the standard PAC helpers (shExpMatch, dnsDomainIs, isInNet
+// and the like) and the common string methods. Loops, regular expressions
+// and the date and time helpers are not supported and fail the evaluation.
+package pac
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"net/http"
+	"net/url"
+	"os"
+	"strings"
+	"sync"
+	"time"
+)
+
+// timeout bounds fetching a PAC file.
+const timeout = 15 * time.Second
+
+// Direct is the PAC entry for connecting without a proxy.
+const Direct = "DIRECT"
+
+// Script is a parsed PAC file. It is safe for concurrent use; decisions are
+// cached per URL.
+type Script struct {
+	prog []stmt
+
+	// Resolve and MyIP back dnsResolve, isResolvable, isInNet and
+	// myIpAddress; Parse sets them to use the system resolver and the
+	// address of the default route.
+	Resolve func(host string) string
+	MyIP    func() string
+
+	mu    sync.Mutex
+	cache map[string]Route
+}
+
+// Route is the proxy chosen for a URL: Entry is the PAC entry that was
+// applied, such as "DIRECT" or "PROXY proxy.corp:3128", and Proxy its URL,
+// nil for DIRECT.
+type Route struct {
+	Entry string
+	Proxy *url.URL
+}
+
+// Load reads a PAC file from an http(s) URL or a local path (file:// URLs
+// included) and parses it.
+func Load(ctx context.Context, spec string) (*Script, error) {
+	ctx, cancel := context.WithTimeout(ctx, timeout)
+	defer cancel()
+	var src []byte
+	var err error
+	if strings.HasPrefix(spec, "http://") || strings.HasPrefix(spec, "https://") {
+		src, err = fetch(ctx, spec)
+	} else {
+		src, err = os.ReadFile(strings.TrimPrefix(spec, "file://"))
+	}
+	if err != nil {
+		return nil, err
+	}
+	return Parse(string(src))
+}
+
+func fetch(ctx context.Context, url string) ([]byte, error) {
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, url, nil)
+	if err != nil {
+		return nil, err
+	}
+	resp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+	body, err := io.ReadAll(io.LimitReader(resp.Body, 1<<20))
+	if err != nil {
+		return nil, err
+	}
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("HTTP %d", resp.StatusCode)
+	}
+	return body, nil
+}
+
+// Parse parses PAC source. It fails when the source cannot be parsed or
+// declares no FindProxyForURL function.
+func Parse(src string) (*Script, error) {
+	p := &parser{lex: lexer{src: src}}
+	prog, err := p.program()
+	if err != nil {
+		return nil, fmt.Errorf("pac: %w", err)
+	}
+	found := false
+	for _, s := range prog {
+		if f, ok := s.(*funcDecl); ok && f.name == "FindProxyForURL" {
+			found = true
+		}
+	}
+	if !found {
+		return nil, errors.New("pac: no FindProxyForURL function")
+	}
+	return &Script{prog: prog, Resolve: ResolveWith(nil), MyIP: myIP, cache: map[string]Route{}}, nil
+}
+
+// FindProxy calls FindProxyForURL(rawURL, host) and returns its result.
+func (s *Script) FindProxy(rawURL, host string) (string, error) {
+	in := &interp{script: s, global: newScope(nil)}
+	for _, st := range s.prog {
+		if _, _, err := in.exec(st, in.global); err != nil {
+			return "", fmt.Errorf("pac: %w", err)
+		}
+	}
+	v, err := in.call("FindProxyForURL", []value{rawURL, host}, in.global)
+	if err != nil {
+		return "", fmt.Errorf("pac: %w", err)
+	}
+	res, ok := v.(string)
+	if !ok {
+		return "", fmt.Errorf("pac: FindProxyForURL returned %s, not a string", typeName(v))
+	}
+	return res, nil
+}
+
+// Route returns the proxy for u: the first entry of the FindProxyForURL
+// result this client can use. As browsers do, https URLs are passed to the
+// script without their path and query.
+func (s *Script) Route(u *url.URL) (Route, error) {
+	raw := u.String()
+	if u.Scheme == "https" {
+		raw = "https://" + u.Host + "/"
+	}
+	s.mu.Lock()
+	rt, ok := s.cache[raw]
+	s.mu.Unlock()
+	if ok {
+		return rt, nil
+	}
+	res, err := s.FindProxy(raw, u.Hostname())
+	if err != nil {
+		return Route{}, err
+	}
+	if rt, err = Choose(res); err != nil {
+		return Route{}, err
+	}
+	s.mu.Lock()
+	s.cache[raw] = rt
+	s.mu.Unlock()
+	return rt, nil
+}
+
+// Choose picks the first usable entry of a FindProxyForURL result such as
+// "PROXY a:3128; PROXY b:3128; DIRECT". PROXY and HTTP entries are HTTP
+// proxies, HTTPS ones are reached over TLS and SOCKS and SOCKS5 ones speak
+// SOCKS5; SOCKS4 entries are skipped. An empty result means DIRECT.
+func Choose(result string) (Route, error) {
+	if strings.TrimSpace(result) == "" {
+		return Route{Entry: Direct}, nil
+	}
+	for _, e := range strings.Split(result, ";") {
+		f := strings.Fields(e)
+		if len(f) == 0 {
+			continue
+		}
+		kind := strings.ToUpper(f[0])
+		if kind == Direct {
+			return Route{Entry: Direct}, nil
+		}
+		if len(f) != 2 {
+			continue
+		}
+		scheme := map[string]string{"PROXY": "http", "HTTP": "http", "HTTPS": "https", "SOCKS": "socks5", "SOCKS5": "socks5"}[kind]
+		if scheme == "" {
+			continue
+		}
+		return Route{Entry: kind + " " + f[1], Proxy: &url.URL{Scheme: scheme, Host: f[1]}}, nil
+	}
+	return Route{}, fmt.Errorf("pac: no usable proxy in %q", result)
+}
+
+// ResolveWith returns a Resolve that looks hosts up with res, the system
+// resolver when nil, preferring IPv4 as browsers' dnsResolve does.
+func ResolveWith(res *net.Resolver) func(host string) string {
+	return func(host string) string {
+		ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+		defer cancel()
+		addrs, err := res.LookupIPAddr(ctx, host)
+		if err != nil {
+			return ""
+		}
+		for _, a := range addrs {
+			if v4 := a.IP.To4(); v4 != nil {
+				return v4.String()
+			}
+		}
+		if len(addrs) > 0 {
+			return addrs[0].IP.String()
+		}
+		return ""
+	}
+}
+
+// myIP is the local address of the default route; no packet is sent.
+func myIP() string {
+	c, err := net.Dial("udp", "192.0.2.1:53")
+	if err != nil {
+		return "127.0.0.1"
+	}
+	defer c.Close()
+	return c.LocalAddr().(*net.UDPAddr).IP.String()
+}
@@ -0,0 +1,164 @@
+package pac
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+const corpPAC = `
+// Typical enterprise PAC file.
+var corpProxy = "PROXY proxy.corp:3128";
+
+function isInternal(host) {
+	return isPlainHostName(host) || dnsDomainIs(host, ".corp.example") ||
+		isInNet(dnsResolve(host), "10.0.0.0", "255.0.0.0");
+}
+
+function FindProxyForURL(url, host) {
+	host = host.toLowerCase();
+	if (isInternal(host)) return "DIRECT";
+	/* the CDN is reachable directly */
+	if (shExpMatch(host, "*.cdn-apple.com") || host == "mensura.cdn-apple.com")
+		return "DIRECT";
+	else if (url.substring(0, 5) === "http:" && host.indexOf("upload") != -1) {
+		return "HTTPS secure.corp:443; DIRECT";
+	}
+	var n = dnsDomainLevels(host);
+	return n > 3 ? "SOCKS5 socks.corp:1080" : corpProxy + "; DIRECT";
+}
+`
+
+func testScript(t *testing.T, src string) *Script {
+	t.Helper()
+	s, err := Parse(src)
+	if err != nil {
+		t.Fatal(err)
+	}
+	s.Resolve = func(host string) string {
+		if host == "intranet.example" {
+			return "10.1.2.3"
+		}
+		return ""
+	}
+	s.MyIP = func() string { return "192.168.1.20" }
+	return s
+}
+
+func TestFindProxy(t *testing.T) {
+	s := testScript(t, corpPAC)
+	for _, tc := range []struct{ url, host, want string }{
+		{"https://wiki/", "wiki", "DIRECT"},
+		{"https://git.corp.example/", "GIT.corp.example", "DIRECT"},
+		{"https://intranet.example/", "intranet.example", "DIRECT"},
+		{"https://mensura.cdn-apple.com/", "mensura.cdn-apple.com", "DIRECT"},
+		{"http://upload.example.net/x", "upload.example.net", "HTTPS secure.corp:443; DIRECT"},
+		{"https://upload.example.net/", "upload.example.net", "PROXY proxy.corp:3128; DIRECT"},
+		{"https://a.b.c.d.example/", "a.b.c.d.example", "SOCKS5 socks.corp:1080"},
+	} {
+		got, err := s.FindProxy(tc.url, tc.host)
+		if err != nil {
+			t.Errorf("%s: %v", tc.url, err)
+		} else if got != tc.want {
+			t.Errorf("%s: %q, want %q", tc.url, got, tc.want)
+		}
+	}
+}
+
+func TestParseErrors(t *testing.T) {
+	for _, src := range []string{
+		`function main() { return "DIRECT"; }`,
+		`function FindProxyForURL(url, host) { for (;;) {} }`,
+		`function FindProxyForURL(url, host) { return "DIRECT; }`,
+		`function FindProxyForURL(url, host) { if (/x/.test(host)) return "DIRECT"; }`,
+		`function FindProxyForURL(url, host) { return "DIRECT";`,
+	} {
+		if _, err := Parse(src); err == nil {
+			t.Errorf("Parse(%q) succeeded", src)
+		}
+	}
+}
+
+func TestEvalErrors(t *testing.T) {
+	for _, src := range []string{
+		`function FindProxyForURL(url, host) { if (timeRange(8, 18)) return "DIRECT"; }`,
+		`function FindProxyForURL(url, host) { return nope; }`,
+		`function FindProxyForURL(url, host) { return 1; }`,
+		`function FindProxyForURL(url, host) { return FindProxyForURL(url, host); }`,
+	} {
+		if _, err := testScript(t, src).FindProxy("https://x/", "x"); err == nil {
+			t.Errorf("FindProxy succeeded for %q", src)
+		}
+	}
+}
+
+func TestChoose(t *testing.T) {
+	for _, tc := range []struct{ in, entry, proxy string }{
+		{"DIRECT", "DIRECT", ""},
+		{"", "DIRECT", ""},
+		{"PROXY p:3128; DIRECT", "PROXY p:3128", "http://p:3128"},
+		{"SOCKS4 s:1080; HTTPS p:443", "HTTPS p:443", "https://p:443"},
+		{"socks s:1080", "SOCKS s:1080", "socks5://s:1080"},
+	} {
+		rt, err := Choose(tc.in)
+		if err != nil {
+			t.Errorf("Choose(%q): %v", tc.in, err)
+			continue
+		}
+		proxy := ""
+		if rt.Proxy != nil {
+			proxy = rt.Proxy.String()
+		}
+		if rt.Entry != tc.entry || proxy != tc.proxy {
+			t.Errorf("Choose(%q) = %q %q", tc.in, rt.Entry, proxy)
+		}
+	}
+	if _, err := Choose("SOCKS4 s:1080"); err == nil {
+		t.Error("SOCKS4-only result accepted")
+	}
+}
+
+func TestRouteStripsHTTPSPath(t *testing.T) {
+	s := testScript(t, `function FindProxyForURL(url, host) {
+		return url == "https://cdn.example/" ? "DIRECT" : "PROXY p:8080";
+	}`)
+	u, _ := url.Parse("https://cdn.example/large?token=secret")
+	rt, err := s.Route(u)
+	if err != nil || rt.Entry != Direct || rt.Proxy != nil {
+		t.Errorf("https route = %+v, %v", rt, err)
+	}
+	u, _ = url.Parse("http://cdn.example/large")
+	if rt, _ := s.Route(u); rt.Entry != "PROXY p:8080" {
+		t.Errorf("http route = %+v", rt)
+	}
+}
+
+func TestLoad(t *testing.T) {
+	src := `function FindProxyForURL(url, host) { return "DIRECT" }`
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path != "/proxy.pac" {
+			http.NotFound(w, r)
+			return
+		}
+		w.Write([]byte(src))
+	}))
+	defer srv.Close()
+	if _, err := Load(context.Background(), srv.URL+"/proxy.pac"); err != nil {
+		t.Error(err)
+	}
+	if _, err := Load(context.Background(), srv.URL+"/missing.pac"); err == nil || !strings.Contains(err.Error(), "404") {
+		t.Errorf("missing PAC: %v", err)
+	}
+	path := filepath.Join(t.TempDir(), "proxy.pac")
+	if err := os.WriteFile(path, []byte(src), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := Load(context.Background(), "file://"+path); err != nil {
+		t.Error(err)
+	}
+}
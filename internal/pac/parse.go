@@ -0,0 +1,558 @@
+package pac
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+)
+
+type tokKind int
+
+const (
+	tEOF tokKind = iota
+	tIdent
+	tNum
+	tStr
+	tPunct
+)
+
+type token struct {
+	kind tokKind
+	text string
+	line int
+}
+
+func (t token) String() string {
+	if t.kind == tEOF {
+		return "end of file"
+	}
+	return strconv.Quote(t.text)
+}
+
+// puncts lists the operators, longest first so that "===" wins over "==".
+var puncts = []string{"===", "!==", "==", "!=", "<=", ">=", "&&", "||",
+	"(", ")", "{", "}", ",", ";", ".", "!", "<", ">", "+", "-", "*", "/", "%", "=", "?", ":"}
+
+type lexer struct {
+	src  string
+	pos  int
+	line int
+}
+
+func (l *lexer) next() (token, error) {
+	if l.line == 0 {
+		l.line = 1
+	}
+	for l.pos < len(l.src) {
+		c := l.src[l.pos]
+		switch {
+		case c == '\n':
+			l.line++
+			l.pos++
+		case c == ' ' || c == '\t' || c == '\r' || c == '\f' || c == '\v':
+			l.pos++
+		case strings.HasPrefix(l.src[l.pos:], "//"):
+			for l.pos < len(l.src) && l.src[l.pos] != '\n' {
+				l.pos++
+			}
+		case strings.HasPrefix(l.src[l.pos:], "/*"):
+			end := strings.Index(l.src[l.pos+2:], "*/")
+			if end < 0 {
+				return token{}, fmt.Errorf("line %d: unterminated comment", l.line)
+			}
+			l.line += strings.Count(l.src[l.pos:l.pos+2+end], "\n")
+			l.pos += end + 4
+		default:
+			return l.token()
+		}
+	}
+	return token{kind: tEOF, line: l.line}, nil
+}
+
+func (l *lexer) token() (token, error) {
+	start := l.pos
+	c := l.src[l.pos]
+	switch {
+	case c == '"' || c == '\'':
+		var b strings.Builder
+		l.pos++
+		for l.pos < len(l.src) && l.src[l.pos] != c {
+			ch := l.src[l.pos]
+			if ch == '\n' {
+				break
+			}
+			if ch == '\\' && l.pos+1 < len(l.src) {
+				l.pos++
+				ch = l.src[l.pos]
+				switch ch {
+				case 'n':
+					ch = '\n'
+				case 't':
+					ch = '\t'
+				}
+			}
+			b.WriteByte(ch)
+			l.pos++
+		}
+		if l.pos >= len(l.src) || l.src[l.pos] != c {
+			return token{}, fmt.Errorf("line %d: unterminated string", l.line)
+		}
+		l.pos++
+		return token{kind: tStr, text: b.String(), line: l.line}, nil
+	case isDigit(c):
+		for l.pos < len(l.src) && (isDigit(l.src[l.pos]) || l.src[l.pos] == '.') {
+			l.pos++
+		}
+		return token{kind: tNum, text: l.src[start:l.pos], line: l.line}, nil
+	case isIdentStart(c):
+		for l.pos < len(l.src) && (isIdentStart(l.src[l.pos]) || isDigit(l.src[l.pos])) {
+			l.pos++
+		}
+		return token{kind: tIdent, text: l.src[start:l.pos], line: l.line}, nil
+	}
+	for _, p := range puncts {
+		if strings.HasPrefix(l.src[l.pos:], p) {
+			l.pos += len(p)
+			return token{kind: tPunct, text: p, line: l.line}, nil
+		}
+	}
+	return token{}, fmt.Errorf("line %d: unexpected character %q", l.line, c)
+}
+
+func isDigit(c byte) bool { return c >= '0' && c <= '9' }
+
+func isIdentStart(c byte) bool {
+	return c == '_' || c == '$' || c >= 'a' && c <= 'z' || c >= 'A' && c <= 'Z'
+}
+
+// The syntax tree.
+type (
+	stmt any
+	expr any
+
+	funcDecl struct {
+		name   string
+		params []string
+		body   []stmt
+	}
+	varStmt struct {
+		names []string
+		inits []expr // nil where a name has no initializer
+	}
+	exprStmt   struct{ x expr }
+	returnStmt struct{ x expr } // x is nil for a bare return
+	blockStmt  struct{ list []stmt }
+	ifStmt     struct {
+		cond      expr
+		then, els stmt // els is nil without an else
+	}
+
+	literal struct{ v value }
+	ident   struct{ name string }
+	assign  struct {
+		name string
+		x    expr
+	}
+	unary struct {
+		op string
+		x  expr
+	}
+	binary struct {
+		op   string
+		x, y expr
+	}
+	ternary struct{ cond, a, b expr }
+	member  struct {
+		x    expr
+		name string
+	}
+	call struct {
+		fn   expr // ident or member
+		args []expr
+	}
+)
+
+type parser struct {
+	lex  lexer
+	tok  token
+	peek *token
+}
+
+func (p *parser) advance() error {
+	if p.peek != nil {
+		p.tok, p.peek = *p.peek, nil
+		return nil
+	}
+	t, err := p.lex.next()
+	p.tok = t
+	return err
+}
+
+func (p *parser) lookahead() (token, error) {
+	if p.peek == nil {
+		t, err := p.lex.next()
+		if err != nil {
+			return token{}, err
+		}
+		p.peek = &t
+	}
+	return *p.peek, nil
+}
+
+func (p *parser) is(text string) bool {
+	return (p.tok.kind == tPunct || p.tok.kind == tIdent) && p.tok.text == text
+}
+
+func (p *parser) expect(text string) error {
+	if !p.is(text) {
+		return fmt.Errorf("line %d: expected %q, found %s", p.tok.line, text, p.tok)
+	}
+	return p.advance()
+}
+
+func (p *parser) identName() (string, error) {
+	if p.tok.kind != tIdent {
+		return "", fmt.Errorf("line %d: expected a name, found %s", p.tok.line, p.tok)
+	}
+	name := p.tok.text
+	return name, p.advance()
+}
+
+func (p *parser) program() ([]stmt, error) {
+	if err := p.advance(); err != nil {
+		return nil, err
+	}
+	var prog []stmt
+	for p.tok.kind != tEOF {
+		s, err := p.statement()
+		if err != nil {
+			return nil, err
+		}
+		prog = append(prog, s)
+	}
+	return prog, nil
+}
+
+// endStatement consumes an optional semicolon; PAC files often omit them.
+func (p *parser) endStatement() error {
+	if p.is(";") {
+		return p.advance()
+	}
+	return nil
+}
+
+func (p *parser) statement() (stmt, error) {
+	switch {
+	case p.is(";"):
+		return &blockStmt{}, p.advance()
+	case p.is("{"):
+		list, err := p.block()
+		return &blockStmt{list}, err
+	case p.is("function"):
+		return p.function()
+	case p.is("var") || p.is("let") || p.is("const"):
+		return p.varStatement()
+	case p.is("if"):
+		return p.ifStatement()
+	case p.is("return"):
+		line := p.tok.line
+		if err := p.advance(); err != nil {
+			return nil, err
+		}
+		if p.is(";") || p.is("}") || p.tok.kind == tEOF || p.tok.line != line {
+			return &returnStmt{}, p.endStatement()
+		}
+		x, err := p.expression()
+		if err != nil {
+			return nil, err
+		}
+		return &returnStmt{x}, p.endStatement()
+	case p.is("for") || p.is("while") || p.is("do") || p.is("switch") || p.is("try"):
+		return nil, fmt.Errorf("line %d: %q statements are not supported", p.tok.line, p.tok.text)
+	}
+	x, err := p.expression()
+	if err != nil {
+		return nil, err
+	}
+	return &exprStmt{x}, p.endStatement()
+}
+
+func (p *parser) block() ([]stmt, error) {
+	if err := p.expect("{"); err != nil {
+		return nil, err
+	}
+	var list []stmt
+	for !p.is("}") {
+		if p.tok.kind == tEOF {
+			return nil, fmt.Errorf("line %d: missing }", p.tok.line)
+		}
+		s, err := p.statement()
+		if err != nil {
+			return nil, err
+		}
+		list = append(list, s)
+	}
+	return list, p.advance()
+}
+
+func (p *parser) function() (stmt, error) {
+	if err := p.advance(); err != nil {
+		return nil, err
+	}
+	name, err := p.identName()
+	if err != nil {
+		return nil, err
+	}
+	if err := p.expect("("); err != nil {
+		return nil, err
+	}
+	f := &funcDecl{name: name}
+	for !p.is(")") {
+		param, err := p.identName()
+		if err != nil {
+			return nil, err
+		}
+		f.params = append(f.params, param)
+		if !p.is(")") {
+			if err := p.expect(","); err != nil {
+				return nil, err
+			}
+		}
+	}
+	if err := p.advance(); err != nil {
+		return nil, err
+	}
+	f.body, err = p.block()
+	return f, err
+}
+
+func (p *parser) varStatement() (stmt, error) {
+	v := &varStmt{}
+	for {
+		if err := p.advance(); err != nil {
+			return nil, err
+		}
+		name, err := p.identName()
+		if err != nil {
+			return nil, err
+		}
+		var init expr
+		if p.is("=") {
+			if err := p.advance(); err != nil {
+				return nil, err
+			}
+			if init, err = p.ternary(); err != nil {
+				return nil, err
+			}
+		}
+		v.names = append(v.names, name)
+		v.inits = append(v.inits, init)
+		if !p.is(",") {
+			return v, p.endStatement()
+		}
+	}
+}
+
+func (p *parser) ifStatement() (stmt, error) {
+	if err := p.advance(); err != nil {
+		return nil, err
+	}
+	if err := p.expect("("); err != nil {
+		return nil, err
+	}
+	cond, err := p.expression()
+	if err != nil {
+		return nil, err
+	}
+	if err := p.expect(")"); err != nil {
+		return nil, err
+	}
+	s := &ifStmt{cond: cond}
+	if s.then, err = p.statement(); err != nil {
+		return nil, err
+	}
+	if p.is("else") {
+		if err := p.advance(); err != nil {
+			return nil, err
+		}
+		if s.els, err = p.statement(); err != nil {
+			return nil, err
+		}
+	}
+	return s, nil
+}
+
+// expression parses an assignment or a conditional expression.
+func (p *parser) expression() (expr, error) {
+	if p.tok.kind == tIdent {
+		next, err := p.lookahead()
+		if err != nil {
+			return nil, err
+		}
+		if next.kind == tPunct && next.text == "=" {
+			name := p.tok.text
+			if err := p.advance(); err != nil {
+				return nil, err
+			}
+			if err := p.advance(); err != nil {
+				return nil, err
+			}
+			x, err := p.expression()
+			return &assign{name, x}, err
+		}
+	}
+	return p.ternary()
+}
+
+func (p *parser) ternary() (expr, error) {
+	cond, err := p.binary(0)
+	if err != nil || !p.is("?") {
+		return cond, err
+	}
+	if err := p.advance(); err != nil {
+		return nil, err
+	}
+	a, err := p.ternary()
+	if err != nil {
+		return nil, err
+	}
+	if err := p.expect(":"); err != nil {
+		return nil, err
+	}
+	b, err := p.ternary()
+	return &ternary{cond, a, b}, err
+}
+
+// precedence lists the binary operators from loosest to tightest binding.
+var precedence = [][]string{
+	{"||"},
+	{"&&"},
+	{"==", "!=", "===", "!=="},
+	{"<", ">", "<=", ">="},
+	{"+", "-"},
+	{"*", "/", "%"},
+}
+
+func (p *parser) binary(level int) (expr, error) {
+	if level == len(precedence) {
+		return p.unary()
+	}
+	x, err := p.binary(level + 1)
+	if err != nil {
+		return nil, err
+	}
+	for p.tok.kind == tPunct && contains(precedence[level], p.tok.text) {
+		op := p.tok.text
+		if err := p.advance(); err != nil {
+			return nil, err
+		}
+		y, err := p.binary(level + 1)
+		if err != nil {
+			return nil, err
+		}
+		x = &binary{op, x, y}
+	}
+	return x, nil
+}
+
+func contains(list []string, s string) bool {
+	for _, v := range list {
+		if v == s {
+			return true
+		}
+	}
+	return false
+}
+
+func (p *parser) unary() (expr, error) {
+	if p.tok.kind == tPunct && (p.tok.text == "!" || p.tok.text == "-" || p.tok.text == "+") {
+		op := p.tok.text
+		if err := p.advance(); err != nil {
+			return nil, err
+		}
+		x, err := p.unary()
+		return &unary{op, x}, err
+	}
+	return p.postfix()
+}
+
+func (p *parser) postfix() (expr, error) {
+	x, err := p.primary()
+	if err != nil {
+		return nil, err
+	}
+	for {
+		switch {
+		case p.is("."):
+			if err := p.advance(); err != nil {
+				return nil, err
+			}
+			name, err := p.identName()
+			if err != nil {
+				return nil, err
+			}
+			x = &member{x, name}
+		case p.is("("):
+			if err := p.advance(); err != nil {
+				return nil, err
+			}
+			c := &call{fn: x}
+			for !p.is(")") {
+				arg, err := p.ternary()
+				if err != nil {
+					return nil, err
+				}
+				c.args = append(c.args, arg)
+				if !p.is(")") {
+					if err := p.expect(","); err != nil {
+						return nil, err
+					}
+				}
+			}
+			if err := p.advance(); err != nil {
+				return nil, err
+			}
+			x = c
+		default:
+			return x, nil
+		}
+	}
+}
+
+func (p *parser) primary() (expr, error) {
+	t := p.tok
+	switch t.kind {
+	case tStr:
+		return &literal{t.text}, p.advance()
+	case tNum:
+		n, err := strconv.ParseFloat(t.text, 64)
+		if err != nil {
+			return nil, fmt.Errorf("line %d: bad number %s", t.line, t)
+		}
+		return &literal{n}, p.advance()
+	case tIdent:
+		switch t.text {
+		case "true":
+			return &literal{true}, p.advance()
+		case "false":
+			return &literal{false}, p.advance()
+		case "null", "undefined":
+			return &literal{nil}, p.advance()
+		}
+		return &ident{t.text}, p.advance()
+	}
+	if p.is("(") {
+		if err := p.advance(); err != nil {
+			return nil, err
+		}
+		x, err := p.expression()
+		if err != nil {
+			return nil, err
+		}
+		return x, p.expect(")")
+	}
+	if p.is("/") {
+		return nil, fmt.Errorf("line %d: regular expressions are not supported", t.line)
+	}
+	return nil, fmt.Errorf("line %d: unexpected %s", t.line, t)
+}
@@ -0,0 +1,340 @@
+package payload
+
+import (
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"io"
+	"math/rand/v2"
+	"os"
+	"runtime"
+	"strings"
+	"sync"
+)
+
+// bufSize is the size of the pooled buffers shared by payload readers and
+// the download loop. Devices with one or two usable CPUs, typically small
+// routers, get 64 KiB: there every worker's buffer counts against little
+// memory and cache, and the link saturates with smaller reads anyway.
+var bufSize = bufferSize(runtime.GOMAXPROCS(0))
+
+// BufferSize returns the size of the buffers GetBuffer hands out.
+func BufferSize() int { return bufSize }
+
+func bufferSize(procs int) int {
+	if procs <= 2 {
+		return 64 * 1024
+	}
+	return 256 * 1024
+}
+
+// zeroBlock backs every zero payload's WriteTo. It is never written to.
+var zeroBlock = make([]byte, bufSize)
+
+var bufPool = sync.Pool{
+	New: func() any {
+		b := make([]byte, bufSize)
+		return &b
+	},
+}
+
+// GetBuffer returns a BufferSize() scratch buffer from the shared pool.
+func GetBuffer() *[]byte {
+	return bufPool.Get().(*[]byte)
+}
+
+// PutBuffer returns a buffer obtained from GetBuffer to the pool.
+func PutBuffer(b *[]byte) {
+	if b == nil || len(*b) != bufSize {
+		return
+	}
+	bufPool.Put(b)
+}
+
+// Source produces upload request bodies. Implementations must be safe for
+// concurrent Open calls; each returned reader is used by a single worker.
+type Source interface {
+	// Name identifies the strategy in config summaries and reports.
+	Name() string
+	// Open returns a reader that yields exactly n bytes and then io.EOF.
+	Open(n int64) (io.ReadCloser, error)
+}
+
+// Parse builds a Source from a spec string:
+//
+//	zero (or empty)    all-zero bytes (default, highly compressible)
+//	random             pseudo-random bytes (compression- and dedup-resistant)
+//	pattern:TEXT       TEXT repeated; use pattern:hex:DEADBEEF for raw bytes
+//	file:PATH          contents of PATH, looped until n bytes are sent
+func Parse(spec string) (Source, error) {
+	spec = strings.TrimSpace(spec)
+	kind, arg, _ := strings.Cut(spec, ":")
+	switch strings.ToLower(kind) {
+	case "", "zero", "zeros":
+		return Zero(), nil
+	case "random", "rand":
+		return Random(), nil
+	case "pattern":
+		if strings.HasPrefix(strings.ToLower(arg), "hex:") {
+			b, err := hex.DecodeString(arg[len("hex:"):])
+			if err != nil {
+				return nil, fmt.Errorf("invalid hex pattern: %w", err)
+			}
+			return Pattern(b)
+		}
+		return Pattern([]byte(arg))
+	case "file":
+		return File(arg)
+	default:
+		return nil, fmt.Errorf("unknown payload %q (want zero, random, pattern:TEXT or file:PATH)", spec)
+	}
+}
+
+type zeroSource struct{}
+
+// Zero returns a Source of all-zero bytes.
+func Zero() Source { return zeroSource{} }
+
+func (zeroSource) Name() string { return "zero" }
+
+func (zeroSource) Open(n int64) (io.ReadCloser, error) {
+	return io.NopCloser(&zeroReader{remaining: n}), nil
+}
+
+type zeroReader struct {
+	remaining int64
+}
+
+func (z *zeroReader) Read(p []byte) (int, error) {
+	if z.remaining <= 0 {
+		return 0, io.EOF
+	}
+	if int64(len(p)) > z.remaining {
+		p = p[:z.remaining]
+	}
+	clear(p)
+	z.remaining -= int64(len(p))
+	return len(p), nil
+}
+
+// WriteTo hands w slices of the shared zero block, so io.Copy needs neither
+// a buffer of its own nor a fill per read.
+func (z *zeroReader) WriteTo(w io.Writer) (int64, error) {
+	var total int64
+	for z.remaining > 0 {
+		n, err := w.Write(zeroBlock[:min(int64(len(zeroBlock)), z.remaining)])
+		total += int64(n)
+		z.remaining -= int64(n)
+		if err != nil {
+			return total, err
+		}
+	}
+	return total, nil
+}
+
+type randomSource struct{}
+
+// Random returns a Source of pseudo-random bytes. Each reader is seeded
+// independently so concurrent uploads never share identical content.
+func Random() Source { return randomSource{} }
+
+func (randomSource) Name() string { return "random" }
+
+func (randomSource) Open(n int64) (io.ReadCloser, error) {
+	var seed [32]byte
+	for i := 0; i < len(seed); i += 8 {
+		v := rand.Uint64()
+		for j := 0; j < 8; j++ {
+			seed[i+j] = byte(v >> (8 * j))
+		}
+	}
+	return io.NopCloser(&randomReader{rng: rand.NewChaCha8(seed), remaining: n}), nil
+}
+
+type randomReader struct {
+	rng       *rand.ChaCha8
+	remaining int64
+}
+
+func (r *randomReader) Read(p []byte) (int, error) {
+	if r.remaining <= 0 {
+		return 0, io.EOF
+	}
+	if int64(len(p)) > r.remaining {
+		p = p[:r.remaining]
+	}
+	_, _ = r.rng.Read(p)
+	r.remaining -= int64(len(p))
+	return len(p), nil
+}
+
+type patternSource struct {
+	block []byte
+}
+
+// Pattern returns a Source that repeats pat. The pattern is pre-expanded into
+// a BufferSize block so reads are plain copies.
+func Pattern(pat []byte) (Source, error) {
+	if len(pat) == 0 {
+		return nil, errors.New("empty payload pattern")
+	}
+	block := make([]byte, bufSize-bufSize%len(pat))
+	if len(block) == 0 {
+		block = append(block, pat...)
+	}
+	for i := 0; i < len(block); i += len(pat) {
+		copy(block[i:], pat)
+	}
+	return &patternSource{block: block}, nil
+}
+
+func (s *patternSource) Name() string { return "pattern" }
+
+func (s *patternSource) Open(n int64) (io.ReadCloser, error) {
+	return io.NopCloser(&loopReader{block: s.block, remaining: n}), nil
+}
+
+// loopReader cycles over an in-memory block, continuing where the previous
+// read left off so the byte stream stays periodic.
+type loopReader struct {
+	block     []byte
+	off       int
+	remaining int64
+}
+
+func (l *loopReader) Read(p []byte) (int, error) {
+	if l.remaining <= 0 {
+		return 0, io.EOF
+	}
+	if int64(len(p)) > l.remaining {
+		p = p[:l.remaining]
+	}
+	n := 0
+	for n < len(p) {
+		c := copy(p[n:], l.block[l.off:])
+		n += c
+		l.off = (l.off + c) % len(l.block)
+	}
+	l.remaining -= int64(n)
+	return n, nil
+}
+
+// WriteTo hands w the block itself, starting where reading left off.
+func (l *loopReader) WriteTo(w io.Writer) (int64, error) {
+	var total int64
+	for l.remaining > 0 {
+		chunk := l.block[l.off:]
+		if int64(len(chunk)) > l.remaining {
+			chunk = chunk[:l.remaining]
+		}
+		n, err := w.Write(chunk)
+		total += int64(n)
+		l.remaining -= int64(n)
+		l.off = (l.off + n) % len(l.block)
+		if err != nil {
+			return total, err
+		}
+	}
+	return total, nil
+}
+
+type fileSource struct {
+	path string
+}
+
+// File returns a Source that streams the file at path, rewinding at EOF until
+// the requested size has been produced.
+func File(path string) (Source, error) {
+	if path == "" {
+		return nil, errors.New("payload file path is empty")
+	}
+	fi, err := os.Stat(path)
+	if err != nil {
+		return nil, err
+	}
+	if !fi.Mode().IsRegular() || fi.Size() == 0 {
+		return nil, fmt.Errorf("payload file %q must be a non-empty regular file", path)
+	}
+	return &fileSource{path: path}, nil
+}
+
+func (s *fileSource) Name() string { return "file" }
+
+func (s *fileSource) Open(n int64) (io.ReadCloser, error) {
+	f, err := os.Open(s.path)
+	if err != nil {
+		return nil, err
+	}
+	return &fileReader{f: f, remaining: n}, nil
+}
+
+type fileReader struct {
+	f         *os.File
+	remaining int64
+	rewound   bool
+}
+
+func (r *fileReader) Read(p []byte) (int, error) {
+	if r.remaining <= 0 {
+		return 0, io.EOF
+	}
+	if int64(len(p)) > r.remaining {
+		p = p[:r.remaining]
+	}
+	n, err := r.f.Read(p)
+	if n > 0 {
+		r.rewound = false
+		r.remaining -= int64(n)
+		return n, nil
+	}
+	if errors.Is(err, io.EOF) && !r.rewound {
+		r.rewound = true
+		if _, serr := r.f.Seek(0, io.SeekStart); serr != nil {
+			return 0, serr
+		}
+		return r.Read(p)
+	}
+	if err == nil {
+		err = io.ErrNoProgress
+	}
+	return 0, err
+}
+
+// WriteTo passes the file to w's ReadFrom a buffer's worth at a time, which
+// lets a plain TCP connection send it with sendfile(2), and copies through a
+// pooled buffer otherwise.
+func (r *fileReader) WriteTo(w io.Writer) (int64, error) {
+	rf, ok := w.(io.ReaderFrom)
+	if !ok {
+		bp := GetBuffer()
+		defer PutBuffer(bp)
+		return io.CopyBuffer(w, struct{ io.Reader }{r}, *bp)
+	}
+	var total int64
+	for r.remaining > 0 {
+		n, err := rf.ReadFrom(io.LimitReader(r.f, min(r.remaining, int64(bufSize))))
+		total += n
+		r.remaining -= n
+		if err != nil {
+			return total, err
+		}
+		if n > 0 {
+			r.rewound = false
+			continue
+		}
+		// The file ended: loop it, unless it yielded nothing since the
+		// last rewind.
+		if r.rewound {
+			return total, io.ErrNoProgress
+		}
+		r.rewound = true
+		if _, err := r.f.Seek(0, io.SeekStart); err != nil {
+			return total, err
+		}
+	}
+	return total, nil
+}
+
+func (r *fileReader) Close() error {
+	return r.f.Close()
+}
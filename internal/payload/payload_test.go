@@ -0,0 +1,210 @@
+package payload
+
+import (
+	"bytes"
+	"io"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestZeroReader(t *testing.T) {
+	r := &zeroReader{remaining: 100}
+	buf := make([]byte, 50)
+	for i := 0; i < 50; i++ {
+		buf[i] = 0xff
+	}
+	n, err := r.Read(buf)
+	if n != 50 || err != nil {
+		t.Errorf("Read(50) = %d, %v", n, err)
+	}
+	if !bytes.Equal(buf, make([]byte, 50)) {
+		t.Error("zeroReader did not clear the buffer")
+	}
+	n, err = r.Read(buf)
+	if n != 50 || err != nil {
+		t.Errorf("Read(50) = %d, %v", n, err)
+	}
+	n, err = r.Read(buf)
+	if n != 0 || err != io.EOF {
+		t.Errorf("Read(0) = %d, %v", n, err)
+	}
+}
+
+func TestParse(t *testing.T) {
+	dir := t.TempDir()
+	path := filepath.Join(dir, "body.bin")
+	if err := os.WriteFile(path, []byte("abc"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		spec string
+		want string
+	}{
+		{"", "zero"},
+		{"zero", "zero"},
+		{"RANDOM", "random"},
+		{"pattern:ab", "pattern"},
+		{"pattern:hex:deadbeef", "pattern"},
+		{"file:" + path, "file"},
+	}
+	for _, tt := range tests {
+		src, err := Parse(tt.spec)
+		if err != nil {
+			t.Errorf("Parse(%q) error: %v", tt.spec, err)
+			continue
+		}
+		if src.Name() != tt.want {
+			t.Errorf("Parse(%q).Name() = %q, want %q", tt.spec, src.Name(), tt.want)
+		}
+	}
+
+	for _, bad := range []string{"bogus", "pattern:", "pattern:hex:zz", "file:", "file:" + filepath.Join(dir, "missing")} {
+		if _, err := Parse(bad); err == nil {
+			t.Errorf("Parse(%q) expected error", bad)
+		}
+	}
+}
+
+func TestSourcesYieldExactSize(t *testing.T) {
+	dir := t.TempDir()
+	path := filepath.Join(dir, "body.bin")
+	if err := os.WriteFile(path, []byte("0123456789"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	pat, _ := Pattern([]byte("xyz"))
+	file, _ := File(path)
+
+	size := int64(3*bufSize + 17)
+	for _, src := range []Source{Zero(), Random(), pat, file} {
+		rc, err := src.Open(size)
+		if err != nil {
+			t.Fatalf("%s: Open error: %v", src.Name(), err)
+		}
+		n, err := io.Copy(io.Discard, rc)
+		rc.Close()
+		if err != nil || n != size {
+			t.Errorf("%s: read %d bytes (err %v), want %d", src.Name(), n, err, size)
+		}
+	}
+}
+
+func TestWriteToMatchesRead(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "body.bin")
+	if err := os.WriteFile(path, []byte("0123456789"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	pat, _ := Pattern([]byte("xyz"))
+	file, _ := File(path)
+	size := int64(2*bufSize + 5)
+	for _, src := range []Source{Zero(), pat, file} {
+		// Reads only, through a wrapper hiding WriteTo.
+		rc, _ := src.Open(size)
+		want, _ := io.ReadAll(struct{ io.Reader }{rc})
+		rc.Close()
+		// Into a ReaderFrom and into a plain writer, after a first short
+		// read, so WriteTo has to continue where Read left off.
+		for _, plain := range []bool{false, true} {
+			rc, _ := src.Open(size)
+			head := make([]byte, 7)
+			io.ReadFull(rc, head)
+			var buf bytes.Buffer
+			var w io.Writer = &buf
+			if plain {
+				w = struct{ io.Writer }{&buf}
+			}
+			n, err := rc.(io.WriterTo).WriteTo(w)
+			rc.Close()
+			got := append(head, buf.Bytes()...)
+			if err != nil || n != size-7 || !bytes.Equal(got, want) {
+				t.Errorf("%s (plain writer %v): WriteTo = %d, %v; output matches reads: %v", src.Name(), plain, n, err, bytes.Equal(got, want))
+			}
+		}
+	}
+}
+
+func TestBufferSize(t *testing.T) {
+	if bufferSize(1) != 64*1024 || bufferSize(2) != 64*1024 || bufferSize(8) != 256*1024 {
+		t.Errorf("bufferSize = %d, %d, %d", bufferSize(1), bufferSize(2), bufferSize(8))
+	}
+}
+
+func TestPatternRepeats(t *testing.T) {
+	src, _ := Pattern([]byte("ab"))
+	rc, _ := src.Open(7)
+	b, _ := io.ReadAll(rc)
+	if string(b) != "abababa" {
+		t.Errorf("pattern output = %q, want %q", b, "abababa")
+	}
+}
+
+func TestFileLoops(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "body.bin")
+	if err := os.WriteFile(path, []byte("abc"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	src, err := File(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	rc, _ := src.Open(8)
+	defer rc.Close()
+	b, _ := io.ReadAll(rc)
+	if string(b) != "abcabcab" {
+		t.Errorf("file output = %q, want %q", b, "abcabcab")
+	}
+}
+
+func TestRandomReadersDiffer(t *testing.T) {
+	a, _ := Random().Open(64)
+	b, _ := Random().Open(64)
+	ab, _ := io.ReadAll(a)
+	bb, _ := io.ReadAll(b)
+	if bytes.Equal(ab, bb) {
+		t.Error("independent random readers produced identical output")
+	}
+}
+
+func TestBufferPool(t *testing.T) {
+	b := GetBuffer()
+	if len(*b) != bufSize {
+		t.Fatalf("len = %d, want %d", len(*b), bufSize)
+	}
+	PutBuffer(b)
+	PutBuffer(nil)
+}
+
+// BenchmarkSources measures each source on its own, without a transfer loop
+// around it: go test -bench . ./internal/payload.
+func BenchmarkSources(b *testing.B) {
+	path := filepath.Join(b.TempDir(), "body.bin")
+	if err := os.WriteFile(path, bytes.Repeat([]byte("0123456789abcdef"), 64<<10), 0o644); err != nil {
+		b.Fatal(err)
+	}
+	pat, _ := Pattern([]byte("xyz"))
+	file, _ := File(path)
+	const size = 16 << 20
+	for _, src := range []Source{Zero(), Random(), pat, file} {
+		b.Run(src.Name(), func(b *testing.B) {
+			b.SetBytes(size)
+			for range b.N {
+				rc, err := src.Open(size)
+				if err != nil {
+					b.Fatal(err)
+				}
+				if n, err := io.Copy(io.Discard, rc); n != size || err != nil {
+					b.Fatalf("copied %d, %v", n, err)
+				}
+				rc.Close()
+			}
+		})
+	}
+}
+
+// BenchmarkBufferPool measures a GetBuffer and PutBuffer round trip.
+func BenchmarkBufferPool(b *testing.B) {
+	for range b.N {
+		PutBuffer(GetBuffer())
+	}
+}
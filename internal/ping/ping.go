@@ -0,0 +1,209 @@
+// Package ping measures round-trip time with ICMP echo. It prefers the
+// unprivileged datagram sockets of Linux (net.ipv4.ping_group_range) and
+// macOS and falls back to raw sockets, which need root or CAP_NET_RAW.
+package ping
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"net"
+	"time"
+)
+
+const (
+	typeEchoRequest4 = 8
+	typeEchoReply4   = 0
+	typeEchoRequest6 = 128
+	typeEchoReply6   = 129
+)
+
+// Result holds the round-trip times of the echoes that were answered.
+type Result struct {
+	Sent    int
+	Samples []float64 // milliseconds
+}
+
+// Loss is the fraction of echoes left unanswered.
+func (r Result) Loss() float64 {
+	if r.Sent == 0 {
+		return 0
+	}
+	return 1 - float64(len(r.Samples))/float64(r.Sent)
+}
+
+// Measure sends n echo requests to ip, one at a time, waiting up to timeout
+// for each reply and interval between them. It fails only when no ICMP
+// socket can be opened; unanswered echoes count as loss.
+func Measure(ctx context.Context, ip string, n int, interval, timeout time.Duration) (Result, error) {
+	dst := net.ParseIP(ip)
+	if dst == nil {
+		return Result{}, fmt.Errorf("invalid IP %q", ip)
+	}
+	conn, addr, err := listen(dst)
+	if err != nil {
+		return Result{}, err
+	}
+	defer conn.Close()
+	stop := context.AfterFunc(ctx, func() { conn.SetDeadline(time.Now()) })
+	defer stop()
+
+	v6 := dst.To4() == nil
+	token := make([]byte, 16)
+	rand.Read(token)
+	var res Result
+	buf := make([]byte, 1500)
+	for seq := 0; seq < n && ctx.Err() == nil; seq++ {
+		if seq > 0 && !sleep(ctx, interval) {
+			break
+		}
+		start := time.Now()
+		if _, err := conn.WriteTo(echoRequest(v6, seq, token, 0), addr); err != nil {
+			return res, err
+		}
+		res.Sent++
+		conn.SetReadDeadline(start.Add(timeout))
+		for {
+			m, _, err := conn.ReadFrom(buf)
+			if err != nil {
+				break // timed out: the echo is lost
+			}
+			if isReply(buf[:m], v6, seq, token) {
+				res.Samples = append(res.Samples, float64(time.Since(start))/float64(time.Millisecond))
+				break
+			}
+		}
+	}
+	return res, nil
+}
+
+// ErrTooBig is returned by Echo when the local stack refuses the packet
+// because it already knows the path MTU is smaller, from the interface or
+// from an earlier ICMP "fragmentation needed".
+var ErrTooBig = errors.New("packet exceeds the known path MTU")
+
+// Echo sends one echo request to ip as an IP packet of size bytes, with
+// fragmentation prohibited, and reports whether the reply arrived within
+// timeout. An unanswered echo is not an error.
+func Echo(ctx context.Context, ip string, size int, timeout time.Duration) (bool, error) {
+	dst := net.ParseIP(ip)
+	if dst == nil {
+		return false, fmt.Errorf("invalid IP %q", ip)
+	}
+	conn, addr, err := listen(dst)
+	if err != nil {
+		return false, err
+	}
+	defer conn.Close()
+	v6 := dst.To4() == nil
+	if err := setDontFragment(conn, v6); err != nil {
+		return false, fmt.Errorf("cannot set don't-fragment: %w", err)
+	}
+	stop := context.AfterFunc(ctx, func() { conn.SetDeadline(time.Now()) })
+	defer stop()
+
+	token := make([]byte, 16)
+	rand.Read(token)
+	hdr := 20 + 8
+	if v6 {
+		hdr = 40 + 8
+	}
+	start := time.Now()
+	if _, err := conn.WriteTo(echoRequest(v6, 0, token, max(size-hdr-len(token), 0)), addr); err != nil {
+		if tooBig(err) {
+			return false, ErrTooBig
+		}
+		return false, err
+	}
+	conn.SetReadDeadline(start.Add(timeout))
+	buf := make([]byte, 65536)
+	for {
+		m, _, err := conn.ReadFrom(buf)
+		if err != nil {
+			return false, nil
+		}
+		if isReply(buf[:m], v6, 0, token) {
+			return true, nil
+		}
+	}
+}
+
+// listen opens a datagram ICMP socket where the platform allows it and a
+// raw one otherwise, returning the address form its WriteTo expects.
+func listen(dst net.IP) (net.PacketConn, net.Addr, error) {
+	if c, err := listenDgram(dst); err == nil {
+		return c, &net.UDPAddr{IP: dst}, nil
+	}
+	network := "ip4:icmp"
+	if dst.To4() == nil {
+		network = "ip6:ipv6-icmp"
+	}
+	c, err := net.ListenPacket(network, "")
+	if err != nil {
+		return nil, nil, fmt.Errorf("no ICMP socket available (needs ping_group_range or root): %w", err)
+	}
+	return c, &net.IPAddr{IP: dst}, nil
+}
+
+var errUnsupported = errors.New("unprivileged ICMP sockets not supported on this platform")
+
+// echoRequest builds an echo request whose payload carries token, so replies
+// can be matched even when the kernel rewrites the identifier, followed by
+// pad zero bytes.
+func echoRequest(v6 bool, seq int, token []byte, pad int) []byte {
+	b := make([]byte, 8+len(token)+pad)
+	b[0] = typeEchoRequest4
+	if v6 {
+		b[0] = typeEchoRequest6
+	}
+	copy(b[4:6], token)
+	binary.BigEndian.PutUint16(b[6:], uint16(seq))
+	copy(b[8:], token)
+	if !v6 {
+		// The kernel fills in the ICMPv6 checksum; ICMPv4 is ours to set.
+		binary.BigEndian.PutUint16(b[2:], checksum(b))
+	}
+	return b
+}
+
+// isReply reports whether b, with or without a leading IPv4 header, is the
+// echo reply for seq.
+func isReply(b []byte, v6 bool, seq int, token []byte) bool {
+	if !v6 && len(b) >= 20 && b[0]>>4 == 4 {
+		b = b[int(b[0]&0x0f)*4:]
+	}
+	want := byte(typeEchoReply4)
+	if v6 {
+		want = typeEchoReply6
+	}
+	return len(b) >= 8+len(token) && b[0] == want &&
+		binary.BigEndian.Uint16(b[6:]) == uint16(seq) &&
+		string(b[8:8+len(token)]) == string(token)
+}
+
+func checksum(b []byte) uint16 {
+	var sum uint32
+	for i := 0; i+1 < len(b); i += 2 {
+		sum += uint32(b[i])<<8 | uint32(b[i+1])
+	}
+	if len(b)%2 == 1 {
+		sum += uint32(b[len(b)-1]) << 8
+	}
+	for sum>>16 != 0 {
+		sum = sum&0xffff + sum>>16
+	}
+	return ^uint16(sum)
+}
+
+func sleep(ctx context.Context, d time.Duration) bool {
+	t := time.NewTimer(d)
+	defer t.Stop()
+	select {
+	case <-t.C:
+		return true
+	case <-ctx.Done():
+		return false
+	}
+}
@@ -0,0 +1,21 @@
+package ping
+
+import (
+	"net"
+	"syscall"
+)
+
+// IP_DONTFRAG and IPV6_DONTFRAG from <netinet/in.h>, missing from syscall.
+const (
+	ipDontFrag   = 28
+	ipv6DontFrag = 62
+)
+
+// setDontFragment sets DF, so sends larger than the known path MTU fail
+// with EMSGSIZE.
+func setDontFragment(c net.PacketConn, v6 bool) error {
+	if v6 {
+		return setsockopt(c, syscall.IPPROTO_IPV6, ipv6DontFrag, 1)
+	}
+	return setsockopt(c, syscall.IPPROTO_IP, ipDontFrag, 1)
+}
@@ -0,0 +1,15 @@
+package ping
+
+import (
+	"net"
+	"syscall"
+)
+
+// setDontFragment sets DF and disables local fragmentation, so sends larger
+// than the known path MTU fail with EMSGSIZE.
+func setDontFragment(c net.PacketConn, v6 bool) error {
+	if v6 {
+		return setsockopt(c, syscall.IPPROTO_IPV6, syscall.IPV6_MTU_DISCOVER, syscall.IPV6_PMTUDISC_DO)
+	}
+	return setsockopt(c, syscall.IPPROTO_IP, syscall.IP_MTU_DISCOVER, syscall.IP_PMTUDISC_DO)
+}
@@ -0,0 +1,11 @@
+//go:build !linux && !darwin
+
+package ping
+
+import "net"
+
+func listenDgram(net.IP) (net.PacketConn, error) { return nil, errUnsupported }
+
+func setDontFragment(net.PacketConn, bool) error { return errUnsupported }
+
+func tooBig(error) bool { return false }
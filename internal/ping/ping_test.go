@@ -0,0 +1,84 @@
+package ping
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+)
+
+func TestEchoRoundTrip(t *testing.T) {
+	token := []byte("0123456789abcdef")
+	req := echoRequest(false, 7, token, 0)
+	if checksum(req) != 0 {
+		t.Errorf("request checksum does not verify: %#04x", checksum(req))
+	}
+
+	// A reply as a raw socket delivers it: IPv4 header, then the echo with
+	// the type flipped.
+	reply := append(make([]byte, 20), req...)
+	reply[0] = 0x45
+	reply[20] = typeEchoReply4
+	if !isReply(reply, false, 7, token) {
+		t.Error("reply with IPv4 header not matched")
+	}
+	if !isReply(reply[20:], false, 7, token) {
+		t.Error("bare reply not matched")
+	}
+	if isReply(reply, false, 8, token) {
+		t.Error("reply matched the wrong sequence")
+	}
+	if isReply(req, false, 7, token) {
+		t.Error("request matched as a reply")
+	}
+	other := append([]byte(nil), reply[20:]...)
+	other[len(other)-1] ^= 0xff
+	if isReply(other, false, 7, token) {
+		t.Error("reply with another token matched")
+	}
+
+	req6 := echoRequest(true, 1, token, 0)
+	req6[0] = typeEchoReply6
+	if !isReply(req6, true, 1, token) {
+		t.Error("ICMPv6 reply not matched")
+	}
+}
+
+func TestResultLoss(t *testing.T) {
+	if got := (Result{Sent: 4, Samples: []float64{1, 2, 3}}).Loss(); got != 0.25 {
+		t.Errorf("Loss = %v, want 0.25", got)
+	}
+	if got := (Result{}).Loss(); got != 0 {
+		t.Errorf("Loss of nothing = %v", got)
+	}
+}
+
+func TestMeasureLoopback(t *testing.T) {
+	res, err := Measure(context.Background(), "127.0.0.1", 3, 10*time.Millisecond, time.Second)
+	if err != nil {
+		t.Skipf("ICMP not available here: %v", err)
+	}
+	if res.Sent != 3 || len(res.Samples) != 3 {
+		t.Errorf("sent %d, answered %d", res.Sent, len(res.Samples))
+	}
+	if _, err := Measure(context.Background(), "not-an-ip", 1, 0, time.Second); err == nil {
+		t.Error("expected error for invalid IP")
+	}
+}
+
+func TestEchoLoopback(t *testing.T) {
+	if n := len(echoRequest(false, 0, make([]byte, 16), 100)); n != 8+16+100 {
+		t.Errorf("padded request is %d bytes", n)
+	}
+	ok, err := Echo(context.Background(), "127.0.0.1", 1500, time.Second)
+	if err != nil {
+		t.Skipf("ICMP not available here: %v", err)
+	}
+	if !ok {
+		t.Error("1500-byte echo over loopback unanswered")
+	}
+	// Loopback's MTU is 64 KiB: the kernel refuses larger DF packets.
+	if _, err := Echo(context.Background(), "127.0.0.1", 70000, time.Second); !errors.Is(err, ErrTooBig) {
+		t.Errorf("oversized echo: err = %v, want ErrTooBig", err)
+	}
+}
@@ -0,0 +1,45 @@
+//go:build linux || darwin
+
+package ping
+
+import (
+	"errors"
+	"net"
+	"os"
+	"syscall"
+)
+
+// listenDgram opens an unprivileged ICMP datagram socket. The net package
+// treats it as a UDP socket, which is enough for ReadFrom and WriteTo.
+func listenDgram(dst net.IP) (net.PacketConn, error) {
+	family, proto := syscall.AF_INET, syscall.IPPROTO_ICMP
+	if dst.To4() == nil {
+		family, proto = syscall.AF_INET6, syscall.IPPROTO_ICMPV6
+	}
+	fd, err := syscall.Socket(family, syscall.SOCK_DGRAM, proto)
+	if err != nil {
+		return nil, err
+	}
+	f := os.NewFile(uintptr(fd), "icmp")
+	defer f.Close()
+	return net.FilePacketConn(f)
+}
+
+// setsockopt sets an integer option on the socket behind c.
+func setsockopt(c net.PacketConn, level, opt, value int) error {
+	sc, ok := c.(syscall.Conn)
+	if !ok {
+		return errUnsupported
+	}
+	raw, err := sc.SyscallConn()
+	if err != nil {
+		return err
+	}
+	var serr error
+	if err := raw.Control(func(fd uintptr) { serr = syscall.SetsockoptInt(int(fd), level, opt, value) }); err != nil {
+		return err
+	}
+	return serr
+}
+
+func tooBig(err error) bool { return errors.Is(err, syscall.EMSGSIZE) }
@@ -0,0 +1,140 @@
+// Package pmtu estimates the path MTU to an endpoint, which explains poor
+// throughput on PPPoE, VPN and tunnel links. Two independent views are
+// combined: the TCP MSS the endpoint's connection settles on, which routers
+// that clamp MSS lower, and the largest ICMP echo that crosses the path with
+// fragmentation prohibited. When TCP is promised more than the path carries
+// and the oversized probes vanish without an ICMP "fragmentation needed",
+// path MTU discovery is black-holed and large transfers can stall.
+package pmtu
+
+import (
+	"context"
+	"errors"
+	"net"
+	"time"
+
+	"github.com/tsosunchia/iNetSpeed-CLI/internal/ping"
+	"github.com/tsosunchia/iNetSpeed-CLI/internal/tcpinfo"
+)
+
+// Ethernet is the MTU of an unencumbered path.
+const Ethernet = 1500
+
+const (
+	probeTimeout = 700 * time.Millisecond
+	attempts     = 2 // per size, to tell loss from a drop
+	baseSize     = 64
+)
+
+// Result is what Measure learned. Zero fields were not measured.
+type Result struct {
+	MSS     int // TCP MSS of a connection to the endpoint
+	TCPMTU  int // the MTU that MSS implies
+	PathMTU int // largest unfragmented echo answered, capped at Ethernet
+	// Blackhole is set when echoes just above PathMTU were dropped without
+	// the kernel learning a smaller MTU, while TCP expects to fit more.
+	Blackhole bool
+	TCPErr    error
+	ICMPErr   error
+}
+
+// MTU is the best estimate of the path MTU, or 0 when nothing was measured.
+func (r Result) MTU() int {
+	if r.PathMTU > 0 {
+		return r.PathMTU
+	}
+	return r.TCPMTU
+}
+
+// errNoReply means the endpoint ignores even small echoes.
+var errNoReply = errors.New("no reply to ICMP echo")
+
+// Measure probes ip, connecting over TCP to port for the MSS.
+func Measure(ctx context.Context, ip, port string) Result {
+	var res Result
+	v6 := net.ParseIP(ip).To4() == nil
+	if mss, err := dialMSS(ctx, net.JoinHostPort(ip, port)); err != nil {
+		res.TCPErr = err
+	} else {
+		res.MSS = mss
+		res.TCPMTU = mtuFromMSS(mss, v6)
+	}
+	echo := func(ctx context.Context, size int) (bool, error) {
+		return ping.Echo(ctx, ip, size, probeTimeout)
+	}
+	probe(ctx, &res, echo)
+	return res
+}
+
+func dialMSS(ctx context.Context, addr string) (int, error) {
+	d := net.Dialer{Timeout: 3 * time.Second}
+	c, err := d.DialContext(ctx, "tcp", addr)
+	if err != nil {
+		return 0, err
+	}
+	defer c.Close()
+	info, err := tcpinfo.Get(c)
+	if err != nil {
+		return 0, err
+	}
+	return int(info.MSS), nil
+}
+
+// mtuFromMSS adds the IP and TCP headers back to an MSS.
+func mtuFromMSS(mss int, v6 bool) int {
+	if v6 {
+		return mss + 60
+	}
+	return mss + 40
+}
+
+// probe fills res.PathMTU and res.Blackhole by binary search over echo
+// sizes. echo reports whether an unfragmented packet of size was answered,
+// or ping.ErrTooBig when the kernel already knows it cannot fit.
+func probe(ctx context.Context, res *Result, echo func(context.Context, int) (bool, error)) {
+	silent := map[int]bool{}
+	fits := func(size int) (bool, error) {
+		for i := 0; i < attempts; i++ {
+			ok, err := echo(ctx, size)
+			if errors.Is(err, ping.ErrTooBig) {
+				// An earlier attempt may have drawn a "fragmentation
+				// needed": PMTUD works for this size.
+				delete(silent, size)
+				return false, nil
+			}
+			if err != nil || ok {
+				return ok, err
+			}
+			silent[size] = true
+		}
+		return false, nil
+	}
+
+	ok, err := fits(baseSize)
+	if err == nil && !ok {
+		err = errNoReply
+	}
+	if err != nil {
+		res.ICMPErr = err
+		return
+	}
+	// Invariant: lo fits, hi does not.
+	lo, hi := baseSize, Ethernet+1
+	if ok, _ := fits(Ethernet); ok {
+		lo = Ethernet
+	}
+	for hi-lo > 1 && ctx.Err() == nil {
+		mid := (lo + hi) / 2
+		if ok, _ := fits(mid); ok {
+			lo = mid
+		} else {
+			hi = mid
+		}
+	}
+	res.PathMTU = lo
+	limit := res.TCPMTU
+	if limit == 0 {
+		limit = Ethernet
+	}
+	res.Blackhole = lo < Ethernet && silent[lo+1] && limit > lo
+}
@@ -0,0 +1,77 @@
+package pmtu
+
+import (
+	"context"
+	"errors"
+	"testing"
+
+	"github.com/tsosunchia/iNetSpeed-CLI/internal/ping"
+)
+
+// path simulates echoes over a link of the given MTU. With pmtud, the first
+// oversized packet teaches the kernel the MTU, as an ICMP "fragmentation
+// needed" would; without it, oversized packets just disappear.
+type path struct {
+	mtu   int
+	pmtud bool
+	known bool
+	sent  int
+}
+
+func (p *path) echo(_ context.Context, size int) (bool, error) {
+	p.sent++
+	if size <= p.mtu {
+		return true, nil
+	}
+	if p.known {
+		return false, ping.ErrTooBig
+	}
+	p.known = p.pmtud
+	return false, nil
+}
+
+func TestProbe(t *testing.T) {
+	for _, tc := range []struct {
+		name      string
+		p         path
+		tcpMTU    int
+		want      int
+		blackhole bool
+	}{
+		{"ethernet", path{mtu: 9000}, 1500, 1500, false},
+		{"pppoe", path{mtu: 1492, pmtud: true}, 1500, 1492, false},
+		{"clamped mss", path{mtu: 1492}, 1492, 1492, false},
+		{"black hole", path{mtu: 1420}, 1500, 1420, true},
+		{"black hole, no tcp", path{mtu: 1420}, 0, 1420, true},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			res := Result{TCPMTU: tc.tcpMTU}
+			probe(context.Background(), &res, tc.p.echo)
+			if res.PathMTU != tc.want || res.Blackhole != tc.blackhole || res.ICMPErr != nil {
+				t.Errorf("got %+v, want path MTU %d, blackhole %v", res, tc.want, tc.blackhole)
+			}
+		})
+	}
+}
+
+func TestProbeICMPUnavailable(t *testing.T) {
+	res := Result{TCPMTU: 1492}
+	probe(context.Background(), &res, func(context.Context, int) (bool, error) { return false, nil })
+	if !errors.Is(res.ICMPErr, errNoReply) || res.PathMTU != 0 || res.MTU() != 1492 {
+		t.Errorf("silent endpoint: %+v", res)
+	}
+	denied := errors.New("operation not permitted")
+	probe(context.Background(), &res, func(context.Context, int) (bool, error) { return false, denied })
+	if !errors.Is(res.ICMPErr, denied) {
+		t.Errorf("ICMPErr = %v", res.ICMPErr)
+	}
+}
+
+func TestMTUFromMSS(t *testing.T) {
+	if got := mtuFromMSS(1452, false); got != 1492 {
+		t.Errorf("IPv4 = %d", got)
+	}
+	if got := mtuFromMSS(1440, true); got != 1500 {
+		t.Errorf("IPv6 = %d", got)
+	}
+}
@@ -0,0 +1,167 @@
+// Package probe keeps the identity of this installation (PROBE_ID and
+// PROBE_NAME) in a small state file, and enrolls it with a central collector
+// so results from many sites can be told apart and authenticated.
+package probe
+
+import (
+	"bytes"
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"os"
+	"path/filepath"
+	"runtime"
+	"time"
+)
+
+// Identity is the persisted probe state. Collector and Token are set by a
+// successful Register.
+type Identity struct {
+	ID        string `json:"id"`
+	Name      string `json:"name,omitempty"`
+	Collector string `json:"collector,omitempty"`
+	Token     string `json:"token,omitempty"`
+}
+
+// DefaultPath is the state file used when PROBE_STATE is unset:
+// iNetSpeed-CLI/probe.json under the user config directory.
+func DefaultPath() (string, error) {
+	dir, err := os.UserConfigDir()
+	if err != nil {
+		return "", err
+	}
+	return filepath.Join(dir, "iNetSpeed-CLI", "probe.json"), nil
+}
+
+// Load reads the state file. A missing file yields a zero Identity.
+func Load(path string) (Identity, error) {
+	var id Identity
+	data, err := os.ReadFile(path)
+	if errors.Is(err, os.ErrNotExist) {
+		return id, nil
+	}
+	if err != nil {
+		return id, err
+	}
+	if err := json.Unmarshal(data, &id); err != nil {
+		return id, fmt.Errorf("%s: %w", path, err)
+	}
+	return id, nil
+}
+
+// Save writes the state file. It holds the collector token, so it is only
+// readable by the owner.
+func Save(path string, id Identity) error {
+	data, err := json.MarshalIndent(id, "", "  ")
+	if err != nil {
+		return err
+	}
+	if err := os.MkdirAll(filepath.Dir(path), 0o700); err != nil {
+		return err
+	}
+	return os.WriteFile(path, append(data, '\n'), 0o600)
+}
+
+// Resolve merges the configured id and name into the stored identity and
+// persists the result. A probe without a stored or configured ID gets a
+// random one, but only when create is set or a name was configured: plain
+// runs never write state on their own.
+func Resolve(path, id, name string, create bool) (Identity, error) {
+	st, err := Load(path)
+	if err != nil {
+		return st, err
+	}
+	want := st
+	if id != "" {
+		want.ID = id
+	}
+	if name != "" {
+		want.Name = name
+	}
+	if want.ID == "" {
+		if !create && name == "" {
+			return want, nil
+		}
+		want.ID = NewID()
+	}
+	if want != st {
+		if err := Save(path, want); err != nil {
+			return want, err
+		}
+	}
+	return want, nil
+}
+
+// NewID returns a random RFC 4122 version 4 UUID.
+func NewID() string {
+	var b [16]byte
+	rand.Read(b[:])
+	b[6] = b[6]&0x0f | 0x40
+	b[8] = b[8]&0x3f | 0x80
+	h := hex.EncodeToString(b[:])
+	return h[:8] + "-" + h[8:12] + "-" + h[12:16] + "-" + h[16:20] + "-" + h[20:]
+}
+
+var httpClient = &http.Client{Timeout: 15 * time.Second}
+
+// Register enrolls the probe with the collector at collectorURL. The
+// one-time enrollment token is sent as a bearer token and exchanged for the
+// probe token the collector replies with ({"token": "..."}), which is
+// stored in the returned Identity.
+func Register(ctx context.Context, collectorURL, enrollToken, version string, id Identity) (Identity, error) {
+	body, err := json.Marshal(map[string]string{
+		"probe_id":   id.ID,
+		"probe_name": id.Name,
+		"version":    version,
+		"platform":   runtime.GOOS + "/" + runtime.GOARCH,
+	})
+	if err != nil {
+		return id, err
+	}
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, collectorURL, bytes.NewReader(body))
+	if err != nil {
+		return id, err
+	}
+	req.Header.Set("Content-Type", "application/json")
+	if enrollToken != "" {
+		req.Header.Set("Authorization", "Bearer "+enrollToken)
+	}
+	resp, err := httpClient.Do(req)
+	if err != nil {
+		return id, err
+	}
+	defer resp.Body.Close()
+	data, err := io.ReadAll(io.LimitReader(resp.Body, 64*1024))
+	if err != nil {
+		return id, err
+	}
+	if resp.StatusCode >= 400 {
+		return id, fmt.Errorf("collector: HTTP %d", resp.StatusCode)
+	}
+	var out struct {
+		Token string `json:"token"`
+	}
+	if err := json.Unmarshal(data, &out); err != nil || out.Token == "" {
+		return id, errors.New("collector returned no token")
+	}
+	id.Collector = collectorURL
+	id.Token = out.Token
+	return id, nil
+}
+
+// SameOrigin reports whether target is served by the collector, so uploads
+// there can carry the probe token.
+func (id Identity) SameOrigin(target string) bool {
+	if id.Collector == "" || id.Token == "" {
+		return false
+	}
+	a, err1 := url.Parse(id.Collector)
+	b, err2 := url.Parse(target)
+	return err1 == nil && err2 == nil && a.Scheme == b.Scheme && a.Host == b.Host
+}
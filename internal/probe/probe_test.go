@@ -0,0 +1,84 @@
+package probe
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"regexp"
+	"testing"
+)
+
+func TestResolve(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "state", "probe.json")
+
+	// Nothing configured and nothing stored: no identity, no file.
+	id, err := Resolve(path, "", "", false)
+	if err != nil || id.ID != "" {
+		t.Fatalf("Resolve = %+v, %v", id, err)
+	}
+	if _, err := os.Stat(path); !os.IsNotExist(err) {
+		t.Fatal("state written without an identity")
+	}
+
+	// A name alone creates and persists a random ID.
+	id, err = Resolve(path, "", "lab-1", false)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !regexp.MustCompile(`^[0-9a-f]{8}-[0-9a-f]{4}-4[0-9a-f]{3}-[89ab][0-9a-f]{3}-[0-9a-f]{12}$`).MatchString(id.ID) || id.Name != "lab-1" {
+		t.Errorf("identity = %+v", id)
+	}
+	again, err := Resolve(path, "", "", false)
+	if err != nil || again != id {
+		t.Errorf("stored identity = %+v, want %+v", again, id)
+	}
+
+	// A configured ID overrides the stored one and keeps the name.
+	id, err = Resolve(path, "probe-42", "", false)
+	if err != nil || id.ID != "probe-42" || id.Name != "lab-1" {
+		t.Errorf("override = %+v, %v", id, err)
+	}
+	if fi, err := os.Stat(path); err != nil || fi.Mode().Perm() != 0o600 {
+		t.Errorf("state file mode = %v, %v", fi.Mode(), err)
+	}
+
+	os.WriteFile(path, []byte("{"), 0o600)
+	if _, err := Resolve(path, "", "", false); err == nil {
+		t.Error("expected error for corrupt state")
+	}
+}
+
+func TestRegister(t *testing.T) {
+	var got map[string]string
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Header.Get("Authorization") != "Bearer enroll-me" {
+			http.Error(w, "forbidden", http.StatusForbidden)
+			return
+		}
+		json.NewDecoder(r.Body).Decode(&got)
+		w.Write([]byte(`{"token":"probe-secret"}`))
+	}))
+	defer srv.Close()
+
+	in := Identity{ID: "abc", Name: "lab-1"}
+	id, err := Register(context.Background(), srv.URL+"/enroll", "enroll-me", "1.2.3", in)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if id.Token != "probe-secret" || id.Collector != srv.URL+"/enroll" || id.ID != "abc" {
+		t.Errorf("identity = %+v", id)
+	}
+	if got["probe_id"] != "abc" || got["probe_name"] != "lab-1" || got["version"] != "1.2.3" || got["platform"] == "" {
+		t.Errorf("request body = %v", got)
+	}
+	if !id.SameOrigin(srv.URL+"/results") || id.SameOrigin("https://paste.example/") {
+		t.Error("SameOrigin mismatch")
+	}
+
+	if _, err := Register(context.Background(), srv.URL, "wrong", "1.2.3", in); err == nil {
+		t.Error("expected error for rejected enrollment token")
+	}
+}
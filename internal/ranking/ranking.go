@@ -0,0 +1,155 @@
+// Package ranking places a result within coarse reference distributions of
+// Apple CDN throughput, grouped by client ASN and country, so a raw Mbps
+// figure can be read as "faster than ~70% of AS4837 users".
+package ranking
+
+import (
+	"context"
+	_ "embed"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"math"
+	"net/http"
+	"os"
+	"strings"
+	"time"
+)
+
+// Global is the group used when neither the ASN nor the country is known.
+const Global = "*"
+
+// DB holds reference distributions. Groups are keyed by ASN ("AS4837"),
+// ISO country code ("CN") or Global; each distribution lists the Mbps found
+// at Percentiles, in ascending order.
+type DB struct {
+	Updated     string           `json:"updated"`
+	Percentiles []float64        `json:"percentiles"`
+	Groups      map[string]Group `json:"groups"`
+}
+
+// Group is the reference for one ASN or country.
+type Group struct {
+	Name     string    `json:"name"`
+	Download []float64 `json:"download"`
+	Upload   []float64 `json:"upload"`
+}
+
+//go:embed reference.json
+var builtin []byte
+
+// Builtin returns the reference shipped with the binary.
+func Builtin() *DB {
+	db, err := parse(builtin)
+	if err != nil {
+		panic("ranking: bad built-in reference: " + err.Error())
+	}
+	return db
+}
+
+// Load reads a reference from a file or an http(s) URL; an empty source
+// means the built-in one.
+func Load(ctx context.Context, src string) (*DB, error) {
+	if src == "" {
+		return Builtin(), nil
+	}
+	var data []byte
+	var err error
+	if strings.HasPrefix(src, "http://") || strings.HasPrefix(src, "https://") {
+		data, err = fetch(ctx, src)
+	} else {
+		data, err = os.ReadFile(src)
+	}
+	if err != nil {
+		return nil, err
+	}
+	return parse(data)
+}
+
+func fetch(ctx context.Context, url string) ([]byte, error) {
+	ctx, cancel := context.WithTimeout(ctx, 10*time.Second)
+	defer cancel()
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, url, nil)
+	if err != nil {
+		return nil, err
+	}
+	resp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("HTTP %d", resp.StatusCode)
+	}
+	return io.ReadAll(io.LimitReader(resp.Body, 1<<20))
+}
+
+func parse(data []byte) (*DB, error) {
+	var db DB
+	if err := json.Unmarshal(data, &db); err != nil {
+		return nil, err
+	}
+	if len(db.Percentiles) == 0 || !ascending(db.Percentiles) || db.Percentiles[0] <= 0 || db.Percentiles[len(db.Percentiles)-1] >= 100 {
+		return nil, errors.New("percentiles must ascend within (0, 100)")
+	}
+	for key, g := range db.Groups {
+		for _, dist := range [][]float64{g.Download, g.Upload} {
+			if dist != nil && (len(dist) != len(db.Percentiles) || !ascending(dist)) {
+				return nil, fmt.Errorf("group %s: need %d ascending values per direction", key, len(db.Percentiles))
+			}
+		}
+	}
+	return &db, nil
+}
+
+func ascending(v []float64) bool {
+	for i := 1; i < len(v); i++ {
+		if v[i] < v[i-1] {
+			return false
+		}
+	}
+	return true
+}
+
+// Lookup picks the most specific group for the client: its ASN, then its
+// country, then Global. asn may be ip-api's "AS4837 China Unicom ..." form.
+func (db *DB) Lookup(asn, country string) (string, Group, bool) {
+	if f := strings.Fields(asn); len(f) > 0 {
+		asn = strings.ToUpper(f[0])
+	}
+	for _, key := range []string{asn, strings.ToUpper(country), Global} {
+		if g, ok := db.Groups[key]; ok && key != "" {
+			return key, g, true
+		}
+	}
+	return "", Group{}, false
+}
+
+// Percentile returns the share of the distribution below mbps, interpolating
+// linearly between the reference points and towards 0 Mbps below the first.
+// Above the last point it returns that point's percentile; capped reports
+// whether the result lies beyond the reference.
+func (db *DB) Percentile(dist []float64, mbps float64) (pct float64, capped bool) {
+	ps := db.Percentiles
+	if len(dist) != len(ps) || mbps <= 0 {
+		return 0, false
+	}
+	if mbps >= dist[len(dist)-1] {
+		return ps[len(ps)-1], true
+	}
+	lo, plo := 0.0, 0.0
+	for i, v := range dist {
+		if mbps < v {
+			return plo + (ps[i]-plo)*(mbps-lo)/(v-lo), false
+		}
+		lo, plo = v, ps[i]
+	}
+	return plo, false
+}
+
+// Round5 rounds a percentile to the nearest 5 for display; the reference is
+// too coarse for more.
+func Round5(pct float64) int {
+	return int(math.Round(pct/5) * 5)
+}
@@ -0,0 +1,116 @@
+package ranking
+
+import (
+	"context"
+	"math"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestBuiltin(t *testing.T) {
+	db := Builtin()
+	if _, ok := db.Groups[Global]; !ok {
+		t.Fatal("built-in reference has no global group")
+	}
+	for key, g := range db.Groups {
+		if g.Name == "" || g.Download == nil || g.Upload == nil {
+			t.Errorf("group %s is incomplete: %+v", key, g)
+		}
+	}
+}
+
+func TestLookup(t *testing.T) {
+	db := Builtin()
+	tests := []struct {
+		asn, country, want string
+	}{
+		{"AS4837 CHINA UNICOM China169 Backbone", "CN", "AS4837"},
+		{"as4134", "", "AS4134"},
+		{"AS64512 Example Net", "cn", "CN"},
+		{"", "ZZ", Global},
+		{"", "", Global},
+	}
+	for _, tt := range tests {
+		if key, _, ok := db.Lookup(tt.asn, tt.country); !ok || key != tt.want {
+			t.Errorf("Lookup(%q, %q) = %q, %v; want %q", tt.asn, tt.country, key, ok, tt.want)
+		}
+	}
+	empty := &DB{Percentiles: []float64{50}}
+	if _, _, ok := empty.Lookup("AS1", "US"); ok {
+		t.Error("Lookup on an empty reference should fail")
+	}
+}
+
+func TestPercentile(t *testing.T) {
+	db := &DB{Percentiles: []float64{10, 50, 90}}
+	dist := []float64{10, 100, 500}
+	tests := []struct {
+		mbps, want float64
+		capped     bool
+	}{
+		{0, 0, false},
+		{5, 5, false},
+		{10, 10, false},
+		{55, 30, false},
+		{100, 50, false},
+		{300, 70, false},
+		{500, 90, true},
+		{2000, 90, true},
+	}
+	for _, tt := range tests {
+		got, capped := db.Percentile(dist, tt.mbps)
+		if math.Abs(got-tt.want) > 1e-9 || capped != tt.capped {
+			t.Errorf("Percentile(%g) = %g, %v; want %g, %v", tt.mbps, got, capped, tt.want, tt.capped)
+		}
+	}
+	if got, _ := db.Percentile(nil, 100); got != 0 {
+		t.Errorf("Percentile without a distribution = %g", got)
+	}
+	if Round5(72.4) != 70 || Round5(72.6) != 75 {
+		t.Errorf("Round5: %d %d", Round5(72.4), Round5(72.6))
+	}
+}
+
+func TestLoad(t *testing.T) {
+	const ref = `{"updated":"x","percentiles":[25,75],"groups":{"*":{"name":"all","download":[10,90]}}}`
+	path := filepath.Join(t.TempDir(), "ref.json")
+	if err := os.WriteFile(path, []byte(ref), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path != "/ref.json" {
+			http.NotFound(w, r)
+			return
+		}
+		w.Write([]byte(ref))
+	}))
+	defer srv.Close()
+
+	for _, src := range []string{path, srv.URL + "/ref.json"} {
+		db, err := Load(context.Background(), src)
+		if err != nil || db.Updated != "x" || len(db.Groups[Global].Download) != 2 {
+			t.Errorf("Load(%s) = %+v, %v", src, db, err)
+		}
+	}
+	if db, err := Load(context.Background(), ""); err != nil || len(db.Groups) < 2 {
+		t.Errorf("Load(\"\") = %+v, %v", db, err)
+	}
+	if _, err := Load(context.Background(), srv.URL+"/missing"); err == nil {
+		t.Error("expected error for HTTP 404")
+	}
+
+	for _, bad := range []string{
+		`{"percentiles":[],"groups":{}}`,
+		`{"percentiles":[50,10],"groups":{}}`,
+		`{"percentiles":[0,50],"groups":{}}`,
+		`{"percentiles":[10,50],"groups":{"CN":{"download":[1,2,3]}}}`,
+		`{"percentiles":[10,50],"groups":{"CN":{"upload":[5,1]}}}`,
+	} {
+		if _, err := parse([]byte(bad)); err == nil {
+			t.Errorf("parse(%s): expected error", bad)
+		}
+	}
+}
@@ -0,0 +1,183 @@
+package ratelimit
+
+import (
+	"context"
+	"io"
+	"math"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+// Bucket is a token-bucket limiter shared by every worker of a run. Tokens
+// are bytes; a reader may overdraw the bucket and then sleeps off the debt,
+// so callers never need to split their reads to fit the burst size.
+type Bucket struct {
+	mu     sync.Mutex
+	rate   float64 // bytes per second
+	burst  float64
+	tokens float64
+	last   time.Time
+}
+
+// NewBucket returns a limiter for bitsPerSec, or nil (no limit) when
+// bitsPerSec <= 0. The burst allows roughly 100 ms of traffic.
+func NewBucket(bitsPerSec int64) *Bucket {
+	if bitsPerSec <= 0 {
+		return nil
+	}
+	rate := float64(bitsPerSec) / 8
+	burst := rate / 10
+	if burst < 4096 {
+		burst = 4096
+	}
+	return &Bucket{rate: rate, burst: burst, tokens: burst, last: time.Now()}
+}
+
+// Chunk is the largest read a limited reader issues at once, about 50 ms of
+// traffic, so progress stays smooth at low rates.
+func (b *Bucket) Chunk() int {
+	c := int(b.rate / 20)
+	if c < 4096 {
+		c = 4096
+	}
+	return c
+}
+
+// Wait consumes n tokens, sleeping until the bucket is no longer in debt or
+// ctx is done. A nil Bucket never waits.
+func (b *Bucket) Wait(ctx context.Context, n int) error {
+	if b == nil || n <= 0 {
+		return nil
+	}
+	b.mu.Lock()
+	now := time.Now()
+	b.tokens += now.Sub(b.last).Seconds() * b.rate
+	if b.tokens > b.burst {
+		b.tokens = b.burst
+	}
+	b.last = now
+	b.tokens -= float64(n)
+	debt := b.tokens
+	b.mu.Unlock()
+
+	if debt >= 0 {
+		return nil
+	}
+	t := time.NewTimer(time.Duration(-debt / b.rate * float64(time.Second)))
+	defer t.Stop()
+	select {
+	case <-t.C:
+		return nil
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
+
+// Budget is a hard cap on bytes transferred across all phases of a run.
+type Budget struct {
+	limit     int64
+	remaining atomic.Int64
+}
+
+// NewBudget returns a cap of n bytes, or nil (unlimited) when n <= 0.
+func NewBudget(n int64) *Budget {
+	if n <= 0 {
+		return nil
+	}
+	b := &Budget{limit: n}
+	b.remaining.Store(n)
+	return b
+}
+
+// Take reserves up to n bytes and returns how many were granted.
+func (b *Budget) Take(n int64) int64 {
+	if b == nil {
+		return n
+	}
+	for {
+		rem := b.remaining.Load()
+		if rem <= 0 {
+			return 0
+		}
+		got := min(n, rem)
+		if b.remaining.CompareAndSwap(rem, rem-got) {
+			return got
+		}
+	}
+}
+
+// Refund returns unused bytes from a previous Take.
+func (b *Budget) Refund(n int64) {
+	if b != nil && n > 0 {
+		b.remaining.Add(n)
+	}
+}
+
+// Exhausted reports whether the cap has been reached.
+func (b *Budget) Exhausted() bool {
+	return b != nil && b.remaining.Load() <= 0
+}
+
+// Remaining returns the bytes left under the cap, or math.MaxInt64 when
+// there is none.
+func (b *Budget) Remaining() int64 {
+	if b == nil {
+		return math.MaxInt64
+	}
+	return max(b.remaining.Load(), 0)
+}
+
+// Limit returns the configured cap in bytes.
+func (b *Budget) Limit() int64 {
+	if b == nil {
+		return 0
+	}
+	return b.limit
+}
+
+// Gate combines the optional rate limit and data cap applied to transfers.
+// A nil Gate, or one with both fields nil, passes traffic through untouched.
+type Gate struct {
+	Rate   *Bucket
+	Budget *Budget
+}
+
+// Active reports whether the gate limits anything.
+func (g *Gate) Active() bool {
+	return g != nil && (g.Rate != nil || g.Budget != nil)
+}
+
+// Reader wraps r so reads are paced by the rate limit and stop with io.EOF
+// once the data cap is used up. Ending with EOF lets both the download loop
+// and the upload request body finish cleanly instead of reporting a fault.
+func (g *Gate) Reader(ctx context.Context, r io.Reader) io.Reader {
+	if !g.Active() {
+		return r
+	}
+	return &gatedReader{ctx: ctx, r: r, g: g}
+}
+
+type gatedReader struct {
+	ctx context.Context
+	r   io.Reader
+	g   *Gate
+}
+
+func (gr *gatedReader) Read(p []byte) (int, error) {
+	if gr.g.Rate != nil && len(p) > gr.g.Rate.Chunk() {
+		p = p[:gr.g.Rate.Chunk()]
+	}
+	want := gr.g.Budget.Take(int64(len(p)))
+	if want == 0 && len(p) > 0 {
+		return 0, io.EOF
+	}
+	n, err := gr.r.Read(p[:want])
+	gr.g.Budget.Refund(want - int64(n))
+	if werr := gr.g.Rate.Wait(gr.ctx, n); werr != nil && err == nil {
+		// The deadline expired while pacing, not on the wire: end the
+		// transfer cleanly rather than report a network fault.
+		err = io.EOF
+	}
+	return n, err
+}
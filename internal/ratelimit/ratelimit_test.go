@@ -0,0 +1,85 @@
+package ratelimit
+
+import (
+	"bytes"
+	"context"
+	"io"
+	"math"
+	"testing"
+	"time"
+)
+
+func TestNilLimitersPassThrough(t *testing.T) {
+	if NewBucket(0) != nil || NewBudget(0) != nil {
+		t.Fatal("zero limits should yield nil limiters")
+	}
+	var g *Gate
+	r := bytes.NewReader(make([]byte, 10))
+	if g.Reader(context.Background(), r) != io.Reader(r) {
+		t.Error("nil gate should return the reader unchanged")
+	}
+	var b *Bucket
+	if err := b.Wait(context.Background(), 1<<30); err != nil {
+		t.Error(err)
+	}
+}
+
+func TestBudgetTakeRefund(t *testing.T) {
+	b := NewBudget(100)
+	if got := b.Take(60); got != 60 || b.Remaining() != 40 {
+		t.Fatalf("Take(60) = %d, %d remaining", got, b.Remaining())
+	}
+	if got := b.Take(60); got != 40 {
+		t.Fatalf("Take(60) = %d, want 40", got)
+	}
+	if !b.Exhausted() {
+		t.Fatal("budget should be exhausted")
+	}
+	b.Refund(10)
+	if b.Exhausted() || b.Take(50) != 10 {
+		t.Error("refund not applied")
+	}
+	if b.Limit() != 100 {
+		t.Errorf("Limit = %d", b.Limit())
+	}
+	if r := NewBudget(0).Remaining(); r != math.MaxInt64 {
+		t.Errorf("unlimited Remaining = %d", r)
+	}
+}
+
+func TestGateBudgetEndsWithEOF(t *testing.T) {
+	g := &Gate{Budget: NewBudget(1000)}
+	n, err := io.Copy(io.Discard, g.Reader(context.Background(), bytes.NewReader(make([]byte, 5000))))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if n != 1000 {
+		t.Errorf("read %d bytes, want 1000", n)
+	}
+	if !g.Budget.Exhausted() {
+		t.Error("budget should be exhausted")
+	}
+}
+
+func TestGateRatePaces(t *testing.T) {
+	// 800 kbit/s = 100 KB/s; 30 KB beyond the 10 KB burst takes ~200 ms.
+	g := &Gate{Rate: NewBucket(800_000)}
+	start := time.Now()
+	n, err := io.Copy(io.Discard, g.Reader(context.Background(), bytes.NewReader(make([]byte, 30_000))))
+	if err != nil || n != 30_000 {
+		t.Fatalf("copy = %d, %v", n, err)
+	}
+	if el := time.Since(start); el < 150*time.Millisecond || el > 2*time.Second {
+		t.Errorf("elapsed %v, want about 200ms", el)
+	}
+}
+
+func TestGateDeadlineWhilePacingIsEOF(t *testing.T) {
+	ctx, cancel := context.WithTimeout(context.Background(), 50*time.Millisecond)
+	defer cancel()
+	g := &Gate{Rate: NewBucket(80_000)} // 10 KB/s
+	_, err := io.Copy(io.Discard, g.Reader(ctx, bytes.NewReader(make([]byte, 1<<20))))
+	if err != nil {
+		t.Errorf("err = %v, want clean EOF", err)
+	}
+}
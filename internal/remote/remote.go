@@ -0,0 +1,182 @@
+// Package remote runs the speedtest on other machines over SSH. It relies on
+// the system ssh client, so keys, agents and ~/.ssh/config work as usual:
+// the binary is streamed to a temporary file on the host, run with its event
+// log on stdout, and removed afterwards.
+package remote
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"os/exec"
+	"runtime"
+	"strings"
+	"time"
+
+	"github.com/tsosunchia/iNetSpeed-CLI/internal/report"
+)
+
+// Options describe how to reach the hosts and what to run there.
+type Options struct {
+	SSH []string // ssh command and its options, e.g. ["ssh", "-p", "2222"]
+	// Binary is copied to the host; empty means this executable, which is
+	// then checked against the host's OS and architecture.
+	Binary string
+	Args   []string // passed to the remote speedtest
+}
+
+// Event is one line of the remote event log.
+type Event struct {
+	Time  time.Time       `json:"time"`
+	Kind  string          `json:"kind"`
+	Label string          `json:"label"`
+	Value string          `json:"value"`
+	Data  json.RawMessage `json:"data"`
+}
+
+// Result is the outcome of one host. Report is nil when the remote run
+// ended before producing one.
+type Result struct {
+	Host   string
+	Report *report.Report
+	Exit   int
+	Err    error
+}
+
+// Run executes the test on host, calling onEvent for every remote event as
+// it arrives.
+func Run(ctx context.Context, host string, opts Options, onEvent func(Event)) Result {
+	res := Result{Host: host, Exit: -1}
+	bin := opts.Binary
+	if bin == "" {
+		exe, err := os.Executable()
+		if err != nil {
+			res.Err = err
+			return res
+		}
+		out, err := ssh(ctx, opts, host, "uname -sm").Output()
+		if err != nil {
+			res.Err = fmt.Errorf("ssh: %w", sshError(err))
+			return res
+		}
+		platform, err := parsePlatform(string(out))
+		if err != nil {
+			res.Err = err
+			return res
+		}
+		if local := runtime.GOOS + "/" + runtime.GOARCH; platform != local {
+			res.Err = fmt.Errorf("host is %s but this binary is %s; set REMOTE_BINARY", platform, local)
+			return res
+		}
+		bin = exe
+	}
+	f, err := os.Open(bin)
+	if err != nil {
+		res.Err = err
+		return res
+	}
+	defer f.Close()
+
+	cmd := ssh(ctx, opts, host, script(opts.Args))
+	cmd.Stdin = f
+	var stderr bytes.Buffer
+	cmd.Stderr = &stderr
+	stdout, err := cmd.StdoutPipe()
+	if err != nil {
+		res.Err = err
+		return res
+	}
+	if err := cmd.Start(); err != nil {
+		res.Err = err
+		return res
+	}
+	sc := bufio.NewScanner(stdout)
+	sc.Buffer(make([]byte, 64*1024), 4*1024*1024)
+	for sc.Scan() {
+		var ev Event
+		if json.Unmarshal(sc.Bytes(), &ev) != nil {
+			continue
+		}
+		if ev.Kind == "report" {
+			var d struct {
+				Report report.Report `json:"report"`
+			}
+			if json.Unmarshal(ev.Data, &d) == nil {
+				res.Report = &d.Report
+			}
+		}
+		onEvent(ev)
+	}
+	io.Copy(io.Discard, stdout)
+	err = cmd.Wait()
+	res.Exit = cmd.ProcessState.ExitCode()
+	if res.Exit == 255 || (err != nil && res.Report == nil) {
+		msg := strings.TrimSpace(stderr.String())
+		if i := strings.LastIndexByte(msg, '\n'); i >= 0 {
+			msg = msg[i+1:]
+		}
+		if msg == "" {
+			msg = err.Error()
+		}
+		res.Err = errors.New(msg)
+	}
+	return res
+}
+
+func ssh(ctx context.Context, opts Options, host, command string) *exec.Cmd {
+	args := append(append([]string(nil), opts.SSH[1:]...), "-o", "BatchMode=yes", host, command)
+	return exec.CommandContext(ctx, opts.SSH[0], args...)
+}
+
+func sshError(err error) error {
+	var ee *exec.ExitError
+	if errors.As(err, &ee) && len(ee.Stderr) > 0 {
+		return errors.New(strings.TrimSpace(string(ee.Stderr)))
+	}
+	return err
+}
+
+// script is the remote shell command: store stdin as an executable temp
+// file, run it with the event log on stdout and clean up, keeping its exit
+// code.
+func script(args []string) string {
+	var b strings.Builder
+	b.WriteString(`f=$(mktemp "${TMPDIR:-/tmp}/speedtest.XXXXXX") || exit 255; trap 'rm -f "$f"' EXIT; cat >"$f" && chmod +x "$f" && "$f" --event-log /dev/stdout`)
+	for _, a := range args {
+		b.WriteByte(' ')
+		b.WriteString(quote(a))
+	}
+	b.WriteString(` </dev/null`)
+	return b.String()
+}
+
+// quote makes s a single POSIX shell word.
+func quote(s string) string {
+	return "'" + strings.ReplaceAll(s, "'", `'\''`) + "'"
+}
+
+// parsePlatform maps `uname -sm` output to GOOS/GOARCH.
+func parsePlatform(uname string) (string, error) {
+	f := strings.Fields(uname)
+	if len(f) != 2 {
+		return "", fmt.Errorf("unexpected uname output %q", strings.TrimSpace(uname))
+	}
+	goos := strings.ToLower(f[0])
+	arch := map[string]string{
+		"x86_64": "amd64", "amd64": "amd64",
+		"aarch64": "arm64", "arm64": "arm64",
+		"i386": "386", "i686": "386",
+		"armv7l": "arm", "armv6l": "arm",
+		"mips": "mips", "mipsel": "mipsle",
+		"riscv64": "riscv64", "s390x": "s390x", "ppc64le": "ppc64le",
+	}[f[1]]
+	if arch == "" {
+		return "", fmt.Errorf("unsupported architecture %q", f[1])
+	}
+	return goos + "/" + arch, nil
+}
@@ -0,0 +1,98 @@
+package remote
+
+import (
+	"context"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"runtime"
+	"strings"
+	"testing"
+)
+
+// fakeSSH writes an "ssh" that runs the remote command locally and a
+// "speedtest" that prints a few events, echoing its arguments.
+func fakeSSH(t *testing.T) (ssh, bin string) {
+	t.Helper()
+	if runtime.GOOS == "windows" {
+		t.Skip("needs a POSIX shell")
+	}
+	dir := t.TempDir()
+	ssh = filepath.Join(dir, "ssh")
+	bin = filepath.Join(dir, "speedtest")
+	write := func(path, body string) {
+		if err := os.WriteFile(path, []byte("#!/bin/sh\n"+body), 0o755); err != nil {
+			t.Fatal(err)
+		}
+	}
+	write(ssh, `while [ $# -gt 1 ]; do shift; done; exec sh -c "$1"`+"\n")
+	write(bin, `echo "{\"time\":\"2026-01-02T03:04:05Z\",\"kind\":\"info\",\"value\":\"args: $*\"}"
+echo 'not json'
+echo '{"time":"2026-01-02T03:04:06Z","kind":"report","data":{"report":{"version":"t","idle_latency":{"median_ms":8.5},"rounds":[{"direction":"download","mbps":120}]}}}'
+exit ${EXIT:-0}
+`)
+	return ssh, bin
+}
+
+func TestRun(t *testing.T) {
+	ssh, bin := fakeSSH(t)
+	var events []Event
+	res := Run(context.Background(), "probe@example", Options{SSH: []string{ssh}, Binary: bin, Args: []string{"--threads=2", "it's"}},
+		func(ev Event) { events = append(events, ev) })
+	if res.Err != nil || res.Exit != 0 {
+		t.Fatalf("Run = %+v", res)
+	}
+	if len(events) != 2 || events[0].Kind != "info" || events[1].Kind != "report" {
+		t.Fatalf("events = %+v", events)
+	}
+	if want := "args: --event-log /dev/stdout --threads=2 it's"; events[0].Value != want {
+		t.Errorf("remote args = %q, want %q", events[0].Value, want)
+	}
+	if res.Report == nil || res.Report.Best("download") != 120 || res.Report.IdleLatency.MedianMs != 8.5 {
+		t.Errorf("report = %+v", res.Report)
+	}
+
+	t.Setenv("EXIT", "2")
+	if res = Run(context.Background(), "h", Options{SSH: []string{ssh}, Binary: bin}, func(Event) {}); res.Exit != 2 || res.Err != nil || res.Report == nil {
+		t.Errorf("degraded run = %+v", res)
+	}
+	res = Run(context.Background(), "h", Options{SSH: []string{ssh}, Binary: filepath.Join(t.TempDir(), "missing")}, func(Event) {})
+	if res.Err == nil {
+		t.Error("expected error for a missing binary")
+	}
+}
+
+func TestRunSSHFailure(t *testing.T) {
+	if _, err := exec.LookPath("false"); err != nil {
+		t.Skip("no false(1)")
+	}
+	_, bin := fakeSSH(t)
+	res := Run(context.Background(), "h", Options{SSH: []string{"false"}, Binary: bin}, func(Event) {})
+	if res.Err == nil || res.Report != nil {
+		t.Errorf("Run = %+v", res)
+	}
+}
+
+func TestParsePlatform(t *testing.T) {
+	tests := []struct {
+		in, want string
+	}{
+		{"Linux x86_64\n", "linux/amd64"},
+		{"Darwin arm64", "darwin/arm64"},
+		{"Linux aarch64", "linux/arm64"},
+		{"FreeBSD amd64", "freebsd/amd64"},
+	}
+	for _, tt := range tests {
+		if got, err := parsePlatform(tt.in); err != nil || got != tt.want {
+			t.Errorf("parsePlatform(%q) = %q, %v; want %q", tt.in, got, err, tt.want)
+		}
+	}
+	for _, bad := range []string{"", "Linux", "Linux sparc64"} {
+		if _, err := parsePlatform(bad); err == nil {
+			t.Errorf("parsePlatform(%q): expected error", bad)
+		}
+	}
+	if got := script([]string{"a b", "it's"}); !strings.HasSuffix(got, `--event-log /dev/stdout 'a b' 'it'\''s' </dev/null`) {
+		t.Errorf("script = %s", got)
+	}
+}
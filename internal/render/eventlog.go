@@ -0,0 +1,117 @@
+package render
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"sync"
+	"time"
+)
+
+// EventLog is a Renderer that writes every event as one JSON object per line
+// (NDJSON), so a run's full time series can be analyzed or plotted offline.
+// Sync barriers are internal and not logged.
+type EventLog struct {
+	mu  sync.Mutex
+	enc *json.Encoder
+	err error
+}
+
+func NewEventLog(w io.Writer) *EventLog {
+	return &EventLog{enc: json.NewEncoder(w)}
+}
+
+type logEntry struct {
+	Time  string         `json:"time"`
+	Kind  string         `json:"kind"`
+	Label string         `json:"label,omitempty"`
+	Value string         `json:"value,omitempty"`
+	Data  map[string]any `json:"data,omitempty"`
+}
+
+func (l *EventLog) Render(ev Event) {
+	if ev.Kind == KindSync {
+		return
+	}
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	if l.err != nil {
+		return
+	}
+	l.err = l.enc.Encode(logEntry{
+		Time:  ev.Time.UTC().Format(time.RFC3339Nano),
+		Kind:  ev.Kind.String(),
+		Label: ev.Label,
+		Value: ev.Value,
+		Data:  ev.Data,
+	})
+}
+
+// Err returns the first write error; later events are dropped after it.
+func (l *EventLog) Err() error {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	return l.err
+}
+
+// ReadEventLog parses an event log written by EventLog back into events,
+// for replaying a recorded run. Numbers in Data come back as float64 and the
+// report as a generic JSON object.
+func ReadEventLog(r io.Reader) ([]Event, error) {
+	var events []Event
+	dec := json.NewDecoder(r)
+	for {
+		var e logEntry
+		if err := dec.Decode(&e); errors.Is(err, io.EOF) {
+			return events, nil
+		} else if err != nil {
+			return nil, fmt.Errorf("event %d: %w", len(events)+1, err)
+		}
+		kind, ok := kindByName(e.Kind)
+		if !ok {
+			return nil, fmt.Errorf("event %d: unknown kind %q", len(events)+1, e.Kind)
+		}
+		t, err := time.Parse(time.RFC3339Nano, e.Time)
+		if err != nil {
+			return nil, fmt.Errorf("event %d: %w", len(events)+1, err)
+		}
+		events = append(events, Event{Kind: kind, Label: e.Label, Value: e.Value, Data: e.Data, Time: t})
+	}
+}
+
+func kindByName(name string) (EventKind, bool) {
+	for k, n := range kindNames {
+		if n == name {
+			return EventKind(k), true
+		}
+	}
+	return 0, false
+}
+
+// Replay sends events to b as they were recorded, each after the time that
+// separated it from the first divided by speed; zero speed sends them all at
+// once. It returns ctx.Err() when cancelled part way.
+func Replay(ctx context.Context, b *Bus, events []Event, speed float64) error {
+	start := time.Now()
+	for _, ev := range events {
+		if speed > 0 {
+			at := start.Add(time.Duration(float64(ev.Time.Sub(events[0].Time)) / speed))
+			if d := time.Until(at); d > 0 {
+				t := time.NewTimer(d)
+				select {
+				case <-t.C:
+				case <-ctx.Done():
+					t.Stop()
+					return ctx.Err()
+				}
+			}
+		}
+		if err := ctx.Err(); err != nil {
+			return err
+		}
+		b.Send(Event{Kind: ev.Kind, Label: ev.Label, Value: ev.Value, Data: ev.Data})
+	}
+	return nil
+}
@@ -3,10 +3,13 @@ package render
 import (
 	"fmt"
 	"io"
+	"math"
 	"os"
 	"strings"
 	"sync"
 	"time"
+
+	"github.com/tsosunchia/iNetSpeed-CLI/internal/i18n"
 )
 
 type EventKind int
@@ -22,44 +25,178 @@ const (
 	KindProgress
 	KindFatal
 	KindSync
+	KindDebug    // per-request detail, shown only by verbose renderers
+	KindPrompt   // question awaiting input on the same line; no newline
+	KindInterval // a line of an iperf3-style report; Label tags it
+
+	// Data-only kinds carry measurements in Event.Data for the event log;
+	// display renderers ignore them.
+	KindStage   // Label is the stage name, Value "start", "end" or "skipped"
+	KindSample  // per-tick throughput of a transfer round
+	KindLatency // one latency probe; Label is the phase
+	KindReport  // the final report, in Data["report"]
 )
 
+var kindNames = [...]string{
+	KindBanner:   "banner",
+	KindHeader:   "header",
+	KindInfo:     "info",
+	KindWarn:     "warn",
+	KindResult:   "result",
+	KindKV:       "kv",
+	KindLine:     "line",
+	KindProgress: "progress",
+	KindFatal:    "fatal",
+	KindSync:     "sync",
+	KindDebug:    "debug",
+	KindPrompt:   "prompt",
+	KindInterval: "interval",
+	KindStage:    "stage",
+	KindSample:   "sample",
+	KindLatency:  "latency",
+	KindReport:   "report",
+}
+
+func (k EventKind) String() string {
+	if k >= 0 && int(k) < len(kindNames) {
+		return kindNames[k]
+	}
+	return fmt.Sprintf("kind(%d)", int(k))
+}
+
+// dataOnly reports whether k carries no text for the terminal.
+func (k EventKind) dataOnly() bool {
+	return k == KindStage || k == KindSample || k == KindLatency || k == KindReport
+}
+
 type Event struct {
 	Kind  EventKind
 	Label string
 	Value string
+	Data  map[string]any
 	Time  time.Time
 	done  chan struct{}
 }
 
+// busSize is how many events the bus holds for a renderer that has fallen
+// behind before Send waits for it to catch up.
+const busSize = 256
+
+// Bus carries events from the measurement to a renderer on a goroutine of
+// its own, in the order they were sent. A progress event still waiting for
+// the renderer is replaced by a newer one for the same label rather than
+// queued behind it, so a slow terminal sees only the latest reading and
+// never holds back a header, message or result sent after it.
 type Bus struct {
-	ch   chan Event
-	wg   sync.WaitGroup
-	once sync.Once
+	mu     sync.Mutex
+	cond   *sync.Cond // signalled when events are queued, taken or the bus closes
+	queue  []Event
+	closed bool
+	wg     sync.WaitGroup
 }
 
 func NewBus(r Renderer) *Bus {
-	b := &Bus{ch: make(chan Event, 256)}
+	b := &Bus{}
+	b.cond = sync.NewCond(&b.mu)
 	b.wg.Add(1)
 	go func() {
 		defer b.wg.Done()
-		for ev := range b.ch {
-			r.Render(ev)
-			if ev.done != nil {
-				close(ev.done)
+		for {
+			b.mu.Lock()
+			for len(b.queue) == 0 && !b.closed {
+				b.cond.Wait()
+			}
+			evs := b.queue
+			b.queue = nil
+			b.cond.Broadcast()
+			b.mu.Unlock()
+			if len(evs) == 0 {
+				return
+			}
+			for _, ev := range evs {
+				r.Render(ev)
+				if ev.done != nil {
+					close(ev.done)
+				}
 			}
 		}
 	}()
 	return b
 }
 
+// Send queues ev for the renderer, stamped with the current time unless it
+// has one. It waits while busSize events are already waiting, unless ev is
+// progress that replaces one of them; after Close it drops ev.
 func (b *Bus) Send(ev Event) {
-	ev.Time = time.Now()
-	b.ch <- ev
+	if ev.Time.IsZero() {
+		ev.Time = time.Now()
+	}
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	if ev.Kind == KindProgress && b.coalesce(ev) {
+		return
+	}
+	for len(b.queue) >= busSize && !b.closed {
+		b.cond.Wait()
+	}
+	if b.closed {
+		if ev.done != nil {
+			close(ev.done)
+		}
+		return
+	}
+	b.queue = append(b.queue, ev)
+	b.cond.Broadcast()
 }
 
+// coalesce puts progress event ev in the place of a waiting one for the same
+// label, if no other kind of event was queued after that one. A fraction
+// lower than the waiting one's starts a new round and is queued after it,
+// so the renderer still sees the last one finish. b.mu is held.
+func (b *Bus) coalesce(ev Event) bool {
+	for i := len(b.queue) - 1; i >= 0 && b.queue[i].Kind == KindProgress; i-- {
+		if q := b.queue[i]; q.Label == ev.Label {
+			f, _, _ := completion(ev)
+			qf, _, _ := completion(q)
+			if f < qf {
+				return false
+			}
+			b.queue[i] = ev
+			return true
+		}
+	}
+	return false
+}
+
+// Hold returns a bus whose events are kept back, and release, which closes
+// it and passes them on to b in the order they were sent. Work running
+// alongside other output prints through one, so that its lines come out
+// together once it is done.
+func (b *Bus) Hold() (held *Bus, release func()) {
+	var kept heldEvents
+	held = NewBus(&kept)
+	return held, func() {
+		held.Close()
+		for _, ev := range kept {
+			b.Send(ev)
+		}
+	}
+}
+
+type heldEvents []Event
+
+func (h *heldEvents) Render(ev Event) {
+	if ev.Kind != KindSync {
+		*h = append(*h, ev)
+	}
+}
+
+// Close renders what is still queued and stops the bus.
 func (b *Bus) Close() {
-	b.once.Do(func() { close(b.ch) })
+	b.mu.Lock()
+	b.closed = true
+	b.cond.Broadcast()
+	b.mu.Unlock()
 	b.wg.Wait()
 }
 
@@ -72,6 +209,90 @@ func (b *Bus) KV(k, v string)           { b.Send(Event{Kind: KindKV, Label: k, V
 func (b *Bus) Line()                    { b.Send(Event{Kind: KindLine}) }
 func (b *Bus) Fatal(v string)           { b.Send(Event{Kind: KindFatal, Value: v}) }
 func (b *Bus) Progress(label, v string) { b.Send(Event{Kind: KindProgress, Label: label, Value: v}) }
+func (b *Bus) Debug(v string)           { b.Send(Event{Kind: KindDebug, Value: v}) }
+
+// ProgressETA is Progress for work of known size: frac of it is done and
+// eta is the time left. The TTY renderer draws a bar; the plain one prints
+// a line at every tenth.
+func (b *Bus) ProgressETA(label, v string, frac float64, eta time.Duration) {
+	b.Send(Event{Kind: KindProgress, Label: label, Value: v,
+		Data: map[string]any{"fraction": frac, "eta_s": eta.Seconds()}})
+}
+
+// Interval prints one line of an iperf3-style interval report, tagged
+// "[ label ]"; data carries its numbers for the event log. An empty label
+// prints v alone.
+func (b *Bus) Interval(label, v string, data map[string]any) {
+	b.Send(Event{Kind: KindInterval, Label: label, Value: v, Data: data})
+}
+
+func intervalText(ev Event) string {
+	if ev.Label == "" {
+		return ev.Value
+	}
+	return "[ " + ev.Label + " ]  " + ev.Value
+}
+
+// completion reads what ProgressETA put in ev.
+func completion(ev Event) (frac, etaSec float64, ok bool) {
+	frac, ok = ev.Data["fraction"].(float64)
+	etaSec, _ = ev.Data["eta_s"].(float64)
+	return frac, etaSec, ok
+}
+
+// progressBarWidth is the number of cells of the TTY progress bar.
+const progressBarWidth = 20
+
+func progressBar(frac float64) string {
+	n := int(math.Round(min(max(frac, 0), 1) * progressBarWidth))
+	return strings.Repeat("\u2588", n) + strings.Repeat("\u2591", progressBarWidth-n)
+}
+
+// formatETA shows the time left as "42s" or "3m05s".
+func formatETA(sec float64) string {
+	d := time.Duration(math.Ceil(max(sec, 0))) * time.Second
+	if d < time.Minute {
+		return fmt.Sprintf(i18n.Text("ETA %ds", "剩余 %d 秒"), int(d.Seconds()))
+	}
+	return fmt.Sprintf(i18n.Text("ETA %dm%02ds", "剩余 %d 分 %02d 秒"), int(d.Minutes()), int(d.Seconds())%60)
+}
+
+// Stage records a stage transition; data may carry its duration or error.
+func (b *Bus) Stage(name, phase string, data map[string]any) {
+	b.Send(Event{Kind: KindStage, Label: name, Value: phase, Data: data})
+}
+
+// Sample records a throughput sample of the round in the given direction.
+func (b *Bus) Sample(dir string, data map[string]any) {
+	b.Send(Event{Kind: KindSample, Label: dir, Data: data})
+}
+
+// Latency records a single latency probe taken during phase.
+func (b *Bus) Latency(phase string, ms float64) {
+	b.Send(Event{Kind: KindLatency, Label: phase, Data: map[string]any{"rtt_ms": ms}})
+}
+
+// LoadedLatency is Latency for a probe taken during a transfer, with the
+// download and upload flows running at the time and dir naming which of
+// them ran.
+func (b *Bus) LoadedLatency(phase string, ms float64, dir string, down, up int) {
+	b.Send(Event{Kind: KindLatency, Label: phase, Data: map[string]any{
+		"rtt_ms": ms, "direction": dir, "download_flows": down, "upload_flows": up}})
+}
+
+// Report records the final machine-readable result of the run.
+func (b *Bus) Report(v any) {
+	b.Send(Event{Kind: KindReport, Data: map[string]any{"report": v}})
+}
+
+// Prompt shows a question and returns once it is on screen, so the caller
+// can read the answer.
+func (b *Bus) Prompt(v string) {
+	done := make(chan struct{})
+	b.Send(Event{Kind: KindPrompt, Value: v, done: done})
+	<-done
+}
+
 func (b *Bus) Flush() {
 	done := make(chan struct{})
 	b.Send(Event{Kind: KindSync, done: done})
@@ -82,6 +303,19 @@ type Renderer interface {
 	Render(Event)
 }
 
+// Multi returns a Renderer that passes every event to each of rs in turn.
+func Multi(rs ...Renderer) Renderer {
+	return multiRenderer(rs)
+}
+
+type multiRenderer []Renderer
+
+func (m multiRenderer) Render(ev Event) {
+	for _, r := range m {
+		r.Render(ev)
+	}
+}
+
 const (
 	cReset  = "\033[0m"
 	cBold   = "\033[1m"
@@ -92,46 +326,83 @@ const (
 	cRed    = "\033[31m"
 )
 
+// kvWidth is the column width of KV labels.
+const kvWidth = 18
+
 type TTYRenderer struct {
 	mu       sync.Mutex
 	w        io.Writer
-	lastProg string
+	lastProg int // display width of the progress line on screen
+	width    func() int
+
+	// Verbose shows KindDebug events; NoColor drops ANSI colors (NO_COLOR)
+	// while keeping the in-place progress line.
+	Verbose bool
+	NoColor bool
 }
 
 func NewTTYRenderer() *TTYRenderer {
-	return &TTYRenderer{w: os.Stderr}
+	return &TTYRenderer{w: os.Stderr, width: termWidth}
+}
+
+func (t *TTYRenderer) c(code string) string {
+	if t.NoColor {
+		return ""
+	}
+	return code
 }
 
 func (t *TTYRenderer) Render(ev Event) {
+	if ev.Kind.dataOnly() {
+		return
+	}
 	t.mu.Lock()
 	defer t.mu.Unlock()
 
-	if t.lastProg != "" && ev.Kind != KindProgress {
-		fmt.Fprintf(t.w, "\r%s\r", strings.Repeat(" ", len(t.lastProg)+2))
-		t.lastProg = ""
+	if t.lastProg > 0 && ev.Kind != KindProgress {
+		fmt.Fprintf(t.w, "\r%s\r", strings.Repeat(" ", t.lastProg))
+		t.lastProg = 0
 	}
 
 	switch ev.Kind {
 	case KindBanner:
-		fmt.Fprintf(t.w, "\n  %s%s%s%s\n", cCyan, cBold, ev.Value, cReset)
+		fmt.Fprintf(t.w, "\n  %s%s%s%s\n", t.c(cCyan), t.c(cBold), ev.Value, t.c(cReset))
 	case KindHeader:
-		fmt.Fprintf(t.w, "\n%s%s  \u25b8 %s%s\n", cCyan, cBold, ev.Value, cReset)
+		fmt.Fprintf(t.w, "\n%s%s  \u25b8 %s%s\n", t.c(cCyan), t.c(cBold), ev.Value, t.c(cReset))
 	case KindInfo:
-		fmt.Fprintf(t.w, "  %s%s[+]%s %s\n", cGreen, cBold, cReset, ev.Value)
+		fmt.Fprintf(t.w, "  %s%s[+]%s %s\n", t.c(cGreen), t.c(cBold), t.c(cReset), ev.Value)
 	case KindWarn:
-		fmt.Fprintf(t.w, "  %s%s[!]%s %s\n", cYellow, cBold, cReset, ev.Value)
+		fmt.Fprintf(t.w, "  %s%s[!]%s %s\n", t.c(cYellow), t.c(cBold), t.c(cReset), ev.Value)
 	case KindResult:
-		fmt.Fprintf(t.w, "  %s%s    \u279c  %s%s\n", cGreen, cBold, ev.Value, cReset)
+		fmt.Fprintf(t.w, "  %s%s    \u279c  %s%s\n", t.c(cGreen), t.c(cBold), ev.Value, t.c(cReset))
 	case KindKV:
-		fmt.Fprintf(t.w, "  %s%s%-18s%s %s\n", cDim, cBold, ev.Label+":", cReset, ev.Value)
+		fmt.Fprintf(t.w, "  %s%s%s%s %s\n", t.c(cDim), t.c(cBold), padRight(ev.Label+":", kvWidth), t.c(cReset), ev.Value)
 	case KindLine:
-		fmt.Fprintf(t.w, "%s\n", cDim+"\u2500\u2500\u2500\u2500\u2500\u2500\u2500\u2500\u2500\u2500\u2500\u2500\u2500\u2500\u2500\u2500\u2500\u2500\u2500\u2500\u2500\u2500\u2500\u2500\u2500\u2500\u2500\u2500\u2500\u2500\u2500\u2500\u2500\u2500\u2500\u2500\u2500\u2500\u2500\u2500\u2500\u2500\u2500\u2500\u2500\u2500\u2500\u2500\u2500\u2500\u2500\u2500\u2500\u2500\u2500\u2500\u2500\u2500\u2500\u2500"+cReset)
+		fmt.Fprintf(t.w, "%s\n", t.c(cDim)+"\u2500\u2500\u2500\u2500\u2500\u2500\u2500\u2500\u2500\u2500\u2500\u2500\u2500\u2500\u2500\u2500\u2500\u2500\u2500\u2500\u2500\u2500\u2500\u2500\u2500\u2500\u2500\u2500\u2500\u2500\u2500\u2500\u2500\u2500\u2500\u2500\u2500\u2500\u2500\u2500\u2500\u2500\u2500\u2500\u2500\u2500\u2500\u2500\u2500\u2500\u2500\u2500\u2500\u2500\u2500\u2500\u2500\u2500\u2500\u2500"+t.c(cReset))
 	case KindProgress:
-		line := fmt.Sprintf("  %s[%s] %s%s", cDim, ev.Label, ev.Value, cReset)
-		fmt.Fprintf(t.w, "\r%s", line)
-		t.lastProg = line
+		// A line that wraps cannot be rewritten with \r; keep it within the
+		// terminal and pad over what is left of the previous one.
+		text := fmt.Sprintf("  [%s] %s", ev.Label, ev.Value)
+		if frac, eta, ok := completion(ev); ok {
+			text = fmt.Sprintf("  [%s] %s %3.0f%%  %s  %s", ev.Label, progressBar(frac), frac*100, formatETA(eta), ev.Value)
+		}
+		if t.width != nil {
+			text = truncate(text, t.width()-1)
+		}
+		w := displayWidth(text)
+		pad := strings.Repeat(" ", max(t.lastProg-w, 0))
+		fmt.Fprintf(t.w, "\r%s%s%s%s", t.c(cDim), text, t.c(cReset), pad)
+		t.lastProg = w
+	case KindInterval:
+		fmt.Fprintf(t.w, "  %s\n", intervalText(ev))
 	case KindFatal:
-		fmt.Fprintf(t.w, "  %s%s[\u2717]%s %s\n", cRed, cBold, cReset, ev.Value)
+		fmt.Fprintf(t.w, "  %s%s[\u2717]%s %s\n", t.c(cRed), t.c(cBold), t.c(cReset), ev.Value)
+	case KindPrompt:
+		fmt.Fprintf(t.w, "  %s%s[?]%s %s", t.c(cCyan), t.c(cBold), t.c(cReset), ev.Value)
+	case KindDebug:
+		if t.Verbose {
+			fmt.Fprintf(t.w, "  %s[.] %s%s\n", t.c(cDim), ev.Value, t.c(cReset))
+		}
 	case KindSync:
 		// no-op; used only as a synchronization barrier
 	}
@@ -140,6 +411,13 @@ func (t *TTYRenderer) Render(ev Event) {
 type PlainRenderer struct {
 	mu sync.Mutex
 	w  io.Writer
+	// Progress of known size is printed once per tenth: the tenth last
+	// printed and the fraction last seen, by label.
+	tenth map[string]int
+	frac  map[string]float64
+
+	// Verbose shows KindDebug events.
+	Verbose bool
 }
 
 func NewPlainRenderer(w io.Writer) *PlainRenderer {
@@ -162,18 +440,63 @@ func (p *PlainRenderer) Render(ev Event) {
 	case KindResult:
 		fmt.Fprintf(p.w, "      -> %s\n", ev.Value)
 	case KindKV:
-		fmt.Fprintf(p.w, "  %-18s %s\n", ev.Label+":", ev.Value)
+		fmt.Fprintf(p.w, "  %s %s\n", padRight(ev.Label+":", kvWidth), ev.Value)
 	case KindLine:
 		fmt.Fprintln(p.w, "  "+strings.Repeat("-", 56))
 	case KindProgress:
-		fmt.Fprintf(p.w, "  [%s] %s\n", ev.Label, ev.Value)
+		frac, eta, ok := completion(ev)
+		if !ok {
+			fmt.Fprintf(p.w, "  [%s] %s\n", ev.Label, ev.Value)
+			break
+		}
+		if p.tenth == nil {
+			p.tenth, p.frac = map[string]int{}, map[string]float64{}
+		}
+		// A fraction going back means the next task under the same label.
+		if frac < p.frac[ev.Label] {
+			p.tenth[ev.Label] = 0
+		}
+		p.frac[ev.Label] = frac
+		if tenth := int(frac * 10); tenth > p.tenth[ev.Label] {
+			p.tenth[ev.Label] = tenth
+			fmt.Fprintf(p.w, "  [%s] %3.0f%%  %s  %s\n", ev.Label, frac*100, formatETA(eta), ev.Value)
+		}
+	case KindInterval:
+		fmt.Fprintf(p.w, "  %s\n", intervalText(ev))
 	case KindFatal:
 		fmt.Fprintf(p.w, "  [X] %s\n", ev.Value)
+	case KindPrompt:
+		fmt.Fprintf(p.w, "  [?] %s", ev.Value)
+	case KindDebug:
+		if p.Verbose {
+			fmt.Fprintf(p.w, "  [.] %s\n", ev.Value)
+		}
 	case KindSync:
 		// no-op; used only as a synchronization barrier
 	}
 }
 
+// QuietRenderer drops everything except fatal errors, leaving stdout free for
+// the single-line --quiet result.
+type QuietRenderer struct {
+	mu sync.Mutex
+	w  io.Writer
+}
+
+func NewQuietRenderer(w io.Writer) *QuietRenderer {
+	return &QuietRenderer{w: w}
+}
+
+func (q *QuietRenderer) Render(ev Event) {
+	if ev.Kind != KindFatal {
+		return
+	}
+	q.mu.Lock()
+	defer q.mu.Unlock()
+	fmt.Fprintf(q.w, "[X] %s\n", ev.Value)
+}
+
+// IsTTY reports whether stderr is a terminal.
 func IsTTY() bool {
 	fi, err := os.Stderr.Stat()
 	if err != nil {
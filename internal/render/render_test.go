@@ -2,12 +2,22 @@ package render
 
 import (
 	"bytes"
+	"context"
+	"flag"
+	"fmt"
+	"os"
+	"path/filepath"
+	"reflect"
 	"strings"
 	"sync"
 	"testing"
 	"time"
+
+	"github.com/tsosunchia/iNetSpeed-CLI/internal/demo"
 )
 
+var update = flag.Bool("update", false, "rewrite the golden files in testdata")
+
 func TestPlainRendererAllKinds(t *testing.T) {
 	var buf bytes.Buffer
 	r := NewPlainRenderer(&buf)
@@ -115,8 +125,445 @@ func TestBusFlushWaitsForRender(t *testing.T) {
 	}
 }
 
+func TestBusCoalescesProgress(t *testing.T) {
+	held, release := make(chan struct{}), make(chan struct{})
+	var got []string
+	r := &capRenderer{fn: func(ev Event) {
+		if ev.Value == "start" {
+			close(held)
+			<-release
+		}
+		got = append(got, ev.Kind.String()+" "+ev.Label+" "+ev.Value)
+	}}
+	bus := NewBus(r)
+	bus.Info("start")
+	<-held
+	for i := 0; i < 10*busSize; i++ {
+		bus.Progress("dl", fmt.Sprint(i))
+		bus.Progress("ul", fmt.Sprint(i))
+	}
+	bus.Header("next")
+	bus.Progress("dl", "last")
+	close(release)
+	bus.Close()
+
+	want := []string{
+		"info  start",
+		fmt.Sprintf("progress dl %d", 10*busSize-1),
+		fmt.Sprintf("progress ul %d", 10*busSize-1),
+		"header  next",
+		"progress dl last",
+	}
+	if strings.Join(got, "\n") != strings.Join(want, "\n") {
+		t.Errorf("rendered\n%s\nwant\n%s", strings.Join(got, "\n"), strings.Join(want, "\n"))
+	}
+}
+
+func TestBusSendAfterClose(t *testing.T) {
+	bus := NewBus(&capRenderer{fn: func(Event) {}})
+	bus.Close()
+	bus.Info("late")
+	bus.Flush()
+}
+
+func TestBusHold(t *testing.T) {
+	var got []Event
+	bus := NewBus(&capRenderer{fn: func(ev Event) {
+		if ev.Kind == KindInfo {
+			got = append(got, ev)
+		}
+	}})
+	held, release := bus.Hold()
+	held.Info("held")
+	held.Flush()
+	bus.Info("live")
+	bus.Flush()
+	if len(got) != 1 {
+		t.Fatalf("held output rendered early: %+v", got)
+	}
+	release()
+	bus.Close()
+	if len(got) != 2 || got[1].Value != "held" || !got[1].Time.Before(got[0].Time) {
+		t.Errorf("released %+v", got)
+	}
+}
+
 type capRenderer struct {
 	fn func(Event)
 }
 
 func (c *capRenderer) Render(ev Event) { c.fn(ev) }
+
+func TestDebugOnlyWhenVerbose(t *testing.T) {
+	var quiet, loud bytes.Buffer
+	p := NewPlainRenderer(&quiet)
+	p.Render(Event{Kind: KindDebug, Value: "GET /large"})
+	v := NewPlainRenderer(&loud)
+	v.Verbose = true
+	v.Render(Event{Kind: KindDebug, Value: "GET /large"})
+
+	if quiet.Len() != 0 {
+		t.Errorf("non-verbose renderer printed %q", quiet.String())
+	}
+	if !strings.Contains(loud.String(), "[.] GET /large") {
+		t.Errorf("verbose output = %q", loud.String())
+	}
+}
+
+func TestTTYRendererNoColor(t *testing.T) {
+	var buf bytes.Buffer
+	r := &TTYRenderer{w: &buf, NoColor: true, Verbose: true}
+	for _, k := range []EventKind{KindBanner, KindHeader, KindInfo, KindWarn, KindResult, KindLine, KindFatal, KindDebug} {
+		r.Render(Event{Kind: k, Value: "x"})
+	}
+	r.Render(Event{Kind: KindKV, Label: "k", Value: "v"})
+	r.Render(Event{Kind: KindProgress, Label: "DL", Value: "1 Mbps"})
+	if strings.Contains(buf.String(), "\033[") {
+		t.Errorf("NoColor output contains ANSI escapes: %q", buf.String())
+	}
+
+	buf.Reset()
+	(&TTYRenderer{w: &buf}).Render(Event{Kind: KindInfo, Value: "x"})
+	if !strings.Contains(buf.String(), "\033[") {
+		t.Error("default TTY output should be colored")
+	}
+}
+
+func TestQuietRenderer(t *testing.T) {
+	var buf bytes.Buffer
+	r := NewQuietRenderer(&buf)
+	for _, k := range []EventKind{KindBanner, KindHeader, KindInfo, KindWarn, KindResult, KindKV, KindLine, KindProgress, KindDebug} {
+		r.Render(Event{Kind: k, Value: "noise"})
+	}
+	if buf.Len() != 0 {
+		t.Fatalf("quiet renderer printed %q", buf.String())
+	}
+	r.Render(Event{Kind: KindFatal, Value: "boom"})
+	if buf.String() != "[X] boom\n" {
+		t.Errorf("fatal output = %q", buf.String())
+	}
+}
+
+func TestEventLog(t *testing.T) {
+	var buf bytes.Buffer
+	l := NewEventLog(&buf)
+	ts := time.Date(2026, 1, 2, 3, 4, 5, 0, time.UTC)
+	for _, ev := range []Event{
+		{Kind: KindStage, Label: "download-multi", Value: "start", Time: ts},
+		{Kind: KindSample, Label: "download", Data: map[string]any{"mbps": 12.5}, Time: ts},
+		{Kind: KindSync, Time: ts},
+		{Kind: KindWarn, Value: "careful", Time: ts},
+	} {
+		l.Render(ev)
+	}
+	want := `{"time":"2026-01-02T03:04:05Z","kind":"stage","label":"download-multi","value":"start"}
+{"time":"2026-01-02T03:04:05Z","kind":"sample","label":"download","data":{"mbps":12.5}}
+{"time":"2026-01-02T03:04:05Z","kind":"warn","value":"careful"}
+`
+	if buf.String() != want || l.Err() != nil {
+		t.Errorf("event log = %s (err %v), want %s", buf.String(), l.Err(), want)
+	}
+}
+
+func TestTTYRendererIgnoresDataEvents(t *testing.T) {
+	var buf bytes.Buffer
+	r := &TTYRenderer{w: &buf}
+	r.Render(Event{Kind: KindProgress, Label: "DL", Value: "10 Mbps"})
+	before := buf.String()
+	r.Render(Event{Kind: KindSample, Label: "download", Data: map[string]any{"mbps": 10.0}})
+	r.Render(Event{Kind: KindLatency, Label: "loaded", Data: map[string]any{"rtt_ms": 3.0}})
+	if buf.String() != before {
+		t.Errorf("data events changed the terminal: %q", buf.String())
+	}
+}
+
+func TestTTYRendererProgressWidth(t *testing.T) {
+	var buf bytes.Buffer
+	r := &TTYRenderer{w: &buf, NoColor: true, width: func() int { return 20 }}
+
+	r.Render(Event{Kind: KindProgress, Label: "DL", Value: "123.4 Mbps  ████████████"})
+	if got, want := buf.String(), "\r  [DL] 123.4 Mbps …"; got != want {
+		t.Errorf("truncated progress = %q, want %q", got, want)
+	}
+	buf.Reset()
+	r.Render(Event{Kind: KindProgress, Label: "DL", Value: "5 Mbps"})
+	if got, want := buf.String(), "\r  [DL] 5 Mbps      "; got != want {
+		t.Errorf("shorter progress = %q, want %q (padded over the previous line)", got, want)
+	}
+	buf.Reset()
+	r.Render(Event{Kind: KindInfo, Value: "done"})
+	if got := buf.String(); !strings.HasPrefix(got, "\r"+strings.Repeat(" ", 13)+"\r") {
+		t.Errorf("progress not cleared: %q", got)
+	}
+}
+
+func TestProgressETA(t *testing.T) {
+	var tty, plain bytes.Buffer
+	// The plain bus is flushed after each update, which keeps it from
+	// coalescing them; a flush would clear the TTY's bar.
+	ttyBus, plainBus := NewBus(&TTYRenderer{w: &tty, NoColor: true}), NewBus(NewPlainRenderer(&plain))
+	for _, frac := range []float64{0.05, 0.12, 0.18, 0.25, 1} {
+		ttyBus.ProgressETA("DL", "50 Mbps", frac, 75*time.Second)
+		plainBus.ProgressETA("DL", "50 Mbps", frac, 75*time.Second)
+		plainBus.Flush()
+	}
+	// The next round under the same label starts over.
+	ttyBus.ProgressETA("DL", "60 Mbps", 0.11, 3*time.Second)
+	plainBus.ProgressETA("DL", "60 Mbps", 0.11, 3*time.Second)
+	ttyBus.Close()
+	plainBus.Close()
+
+	// 0.18 is in the same tenth as 0.12.
+	want := "  [DL]  12%  ETA 1m15s  50 Mbps\n" +
+		"  [DL]  25%  ETA 1m15s  50 Mbps\n" +
+		"  [DL] 100%  ETA 1m15s  50 Mbps\n" +
+		"  [DL]  11%  ETA 3s  60 Mbps\n"
+	if got := plain.String(); got != want {
+		t.Errorf("plain progress =\n%s\nwant\n%s", got, want)
+	}
+	if got := tty.String(); !strings.HasSuffix(got, "\r  [DL] ██░░░░░░░░░░░░░░░░░░  11%  ETA 3s  60 Mbps   ") {
+		t.Errorf("tty progress = %q", got)
+	}
+}
+
+func TestInterval(t *testing.T) {
+	var tty, plain, log bytes.Buffer
+	bus := NewBus(Multi(&TTYRenderer{w: &tty, NoColor: true}, NewPlainRenderer(&plain), NewEventLog(&log)))
+	bus.Progress("DL", "50 Mbps")
+	bus.Interval("DL", "  0.00-1.00   s", map[string]any{"mbps": 941.0})
+	bus.Interval("", "- - -", nil)
+	bus.Close()
+
+	if got, want := plain.String(), "  [DL] 50 Mbps\n  [ DL ]    0.00-1.00   s\n  - - -\n"; got != want {
+		t.Errorf("plain = %q, want %q", got, want)
+	}
+	// The progress line is cleared first.
+	if got := tty.String(); !strings.HasSuffix(got, "\r  [ DL ]    0.00-1.00   s\n  - - -\n") {
+		t.Errorf("tty = %q", got)
+	}
+	if !strings.Contains(log.String(), `"kind":"interval","label":"DL","value":"  0.00-1.00   s","data":{"mbps":941}`) {
+		t.Errorf("event log = %s", log.String())
+	}
+}
+
+func TestPrompt(t *testing.T) {
+	var tty, plain bytes.Buffer
+	bus := NewBus(Multi(&TTYRenderer{w: &tty}, NewPlainRenderer(&plain), NewQuietRenderer(&plain)))
+	bus.Info("list")
+	bus.Prompt("Select [1-2]: ")
+	// Prompt returns only once rendered, without a newline.
+	if got := plain.String(); !strings.HasSuffix(got, "  [?] Select [1-2]: ") || !strings.Contains(got, "list") {
+		t.Errorf("plain prompt = %q", got)
+	}
+	if got := tty.String(); !strings.Contains(got, cCyan+cBold+"[?]"+cReset+" Select [1-2]: ") || strings.HasSuffix(got, "\n") {
+		t.Errorf("tty prompt = %q", got)
+	}
+	bus.Close()
+}
+
+func TestDisplayWidth(t *testing.T) {
+	tests := []struct {
+		s    string
+		want int
+	}{
+		{"Tokyo", 5},
+		{"日本 东京", 9},
+		{"ｄｌ", 4},
+		{"é", 1}, // combining accent
+		{"↓ 512 ▁█", 8},
+	}
+	for _, tt := range tests {
+		if got := displayWidth(tt.s); got != tt.want {
+			t.Errorf("displayWidth(%q) = %d, want %d", tt.s, got, tt.want)
+		}
+	}
+	if got := truncate("下载 100 Mbps", 6); got != "下载 …" {
+		t.Errorf("truncate = %q", got)
+	}
+	if got := truncate("下载", 2); got != "…" {
+		t.Errorf("truncate straddling = %q", got)
+	}
+}
+
+func TestKVAlignsCJK(t *testing.T) {
+	var buf bytes.Buffer
+	r := NewPlainRenderer(&buf)
+	r.Render(Event{Kind: KindKV, Label: "空载延迟", Value: "v"})
+	r.Render(Event{Kind: KindKV, Label: "Idle", Value: "v"})
+	lines := strings.Split(strings.TrimSuffix(buf.String(), "\n"), "\n")
+	if len(lines) != 2 || displayWidth(lines[0]) != displayWidth(lines[1]) {
+		t.Errorf("KV columns misaligned:\n%s", buf.String())
+	}
+}
+
+func TestTUIFrame(t *testing.T) {
+	r := &TUIRenderer{NoColor: true}
+	r.Render(Event{Kind: KindBanner, Value: "iNetSpeed"})
+	r.Render(Event{Kind: KindStage, Label: "download-multi", Value: "start", Data: map[string]any{"step": 2, "steps": 4}})
+	r.Render(Event{Kind: KindHeader, Value: "Download (multi-thread)"})
+	// 100, 400 and 200 Mbps over successive half seconds.
+	for i, bytes := range []int64{6_250_000, 31_250_000, 43_750_000} {
+		secs := float64(i+1) / 2
+		r.Render(Event{Kind: KindSample, Label: "download", Data: map[string]any{
+			"bytes": bytes, "elapsed_s": secs, "mbps": float64(bytes) * 8 / secs / 1e6, "threads": 4, "active": int32(3),
+		}})
+	}
+	r.Render(Event{Kind: KindLatency, Label: "loaded", Data: map[string]any{"rtt_ms": 41.5}})
+	for i := range 12 {
+		r.Render(Event{Kind: KindInfo, Value: fmt.Sprintf("line %d %s", i, strings.Repeat("x", 100))})
+	}
+
+	frame := r.frame(60)
+	text := strings.Join(frame, "\n")
+	for _, want := range []string{"iNetSpeed", "▸ Download (multi-thread)", "2/4 download-multi", "200.0 Mbps",
+		"avg 233.3  peak 400.0", "▂█▄", "41.5 ms (loaded)", "●●●○ 3/4 active", "line 11"} {
+		if !strings.Contains(text, want) {
+			t.Errorf("frame lacks %q:\n%s", want, text)
+		}
+	}
+	if strings.Contains(text, "line 3 ") {
+		t.Errorf("frame keeps more than %d log lines", tuiLogLines)
+	}
+	for _, line := range frame {
+		if w := displayWidth(line); w >= 60 {
+			t.Errorf("line is %d columns wide: %q", w, line)
+		}
+	}
+}
+
+func TestTUICloseReplaysTranscript(t *testing.T) {
+	var buf bytes.Buffer
+	r := &TUIRenderer{w: &buf, NoColor: true}
+	r.Start()
+	r.Render(Event{Kind: KindHeader, Value: "Idle Latency"})
+	r.Render(Event{Kind: KindProgress, Label: "DL", Value: "10 Mbps"})
+	r.Render(Event{Kind: KindResult, Value: "8.30 ms median"})
+	r.Render(Event{Kind: KindSample, Label: "download", Data: map[string]any{"bytes": 1, "elapsed_s": 0.5}})
+	time.Sleep(2 * tuiInterval)
+	r.Close()
+
+	out := buf.String()
+	_, after, ok := strings.Cut(out, "\033[?1049l")
+	if !strings.HasPrefix(out, "\033[?1049h") || !ok {
+		t.Fatalf("alternate screen not entered and left: %q", out)
+	}
+	if !strings.Contains(after, "▸ Idle Latency") || !strings.Contains(after, "8.30 ms median") || strings.Contains(after, "10 Mbps") {
+		t.Errorf("transcript = %q", after)
+	}
+}
+
+func demoEvents(t *testing.T) []Event {
+	t.Helper()
+	b, err := demo.Recording("en")
+	if err != nil {
+		t.Fatal(err)
+	}
+	events, err := ReadEventLog(bytes.NewReader(b))
+	if err != nil {
+		t.Fatal(err)
+	}
+	return events
+}
+
+// TestDemoGolden renders the bundled recording in every output mode and
+// compares the result with testdata/demo-*.golden; run with -update to
+// accept a deliberate change.
+func TestDemoGolden(t *testing.T) {
+	events := demoEvents(t)
+	fixed := func() int { return 100 }
+	for _, tc := range []struct {
+		name string
+		out  func(*bytes.Buffer) Renderer
+		done func(Renderer, *bytes.Buffer)
+	}{
+		{name: "plain", out: func(b *bytes.Buffer) Renderer { return NewPlainRenderer(b) }},
+		{name: "plain-verbose", out: func(b *bytes.Buffer) Renderer { return &PlainRenderer{w: b, Verbose: true} }},
+		{name: "tty", out: func(b *bytes.Buffer) Renderer { return &TTYRenderer{w: b, width: fixed} }},
+		{name: "tty-nocolor", out: func(b *bytes.Buffer) Renderer { return &TTYRenderer{w: b, width: fixed, NoColor: true} }},
+		{
+			name: "tui",
+			out:  func(b *bytes.Buffer) Renderer { return &TUIRenderer{w: b, width: fixed, NoColor: true} },
+			done: func(r Renderer, b *bytes.Buffer) {
+				tui := r.(*TUIRenderer)
+				b.WriteString(strings.Join(tui.frame(80), "\n") + "\n")
+				tui.Close()
+			},
+		},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			var buf bytes.Buffer
+			r := tc.out(&buf)
+			for _, ev := range events {
+				r.Render(ev)
+			}
+			if tc.done != nil {
+				tc.done(r, &buf)
+			}
+			path := filepath.Join("testdata", "demo-"+tc.name+".golden")
+			if *update {
+				if err := os.WriteFile(path, buf.Bytes(), 0o644); err != nil {
+					t.Fatal(err)
+				}
+				return
+			}
+			want, err := os.ReadFile(path)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if !bytes.Equal(buf.Bytes(), want) {
+				t.Errorf("output differs from %s; rerun with -update if the change is intended\n%s", path, buf.String())
+			}
+		})
+	}
+}
+
+func TestEventLogRoundTrip(t *testing.T) {
+	events := demoEvents(t)
+	if len(events) == 0 || events[len(events)-1].Kind != KindReport {
+		t.Fatalf("recording has %d events", len(events))
+	}
+	var buf bytes.Buffer
+	l := NewEventLog(&buf)
+	for _, ev := range events {
+		l.Render(ev)
+	}
+	again, err := ReadEventLog(&buf)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !reflect.DeepEqual(again, events) {
+		t.Error("events changed when logged and read again")
+	}
+	if _, err := ReadEventLog(strings.NewReader(`{"time":"2025-01-01T00:00:00Z","kind":"bogus"}`)); err == nil {
+		t.Error("unknown kind accepted")
+	}
+}
+
+func TestReplay(t *testing.T) {
+	t0 := time.Now()
+	events := []Event{
+		{Kind: KindInfo, Value: "one", Time: t0},
+		{Kind: KindInfo, Value: "two", Time: t0.Add(60 * time.Millisecond)},
+	}
+	var buf bytes.Buffer
+	bus := NewBus(NewPlainRenderer(&buf))
+	start := time.Now()
+	if err := Replay(context.Background(), bus, events, 2); err != nil {
+		t.Fatal(err)
+	}
+	bus.Close()
+	if d := time.Since(start); d < 30*time.Millisecond {
+		t.Errorf("replayed in %v, want the 60ms gap halved", d)
+	}
+	if buf.String() != "  [+] one\n  [+] two\n" {
+		t.Errorf("output %q", buf.String())
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	bus = NewBus(NewPlainRenderer(&buf))
+	defer bus.Close()
+	if err := Replay(ctx, bus, events, 1); err == nil {
+		t.Error("cancelled replay succeeded")
+	}
+}
@@ -0,0 +1,78 @@
+package render
+
+import (
+	"os"
+	"strconv"
+	"strings"
+	"unicode"
+
+	"golang.org/x/text/width"
+)
+
+// EnableANSI prepares stderr for ANSI escape sequences and reports whether
+// the terminal will interpret them. Only consoles older than Windows 10
+// refuse; callers should then render without colors.
+func EnableANSI() bool {
+	return enableVT()
+}
+
+// termWidth returns the column count of the terminal on stderr, falling back
+// to $COLUMNS and then 80.
+func termWidth() int {
+	if w := consoleWidth(); w > 0 {
+		return w
+	}
+	if w, err := strconv.Atoi(os.Getenv("COLUMNS")); err == nil && w > 0 {
+		return w
+	}
+	return 80
+}
+
+// runeWidth is the number of terminal columns r occupies: two for wide and
+// fullwidth East Asian characters such as CJK ideographs, none for
+// combining marks and control characters.
+func runeWidth(r rune) int {
+	switch {
+	case r < 0x20 || r == 0x7f || unicode.Is(unicode.Mn, r) || unicode.Is(unicode.Me, r) || r == 0x200b:
+		return 0
+	}
+	switch width.LookupRune(r).Kind() {
+	case width.EastAsianWide, width.EastAsianFullwidth:
+		return 2
+	}
+	return 1
+}
+
+// displayWidth is the number of columns s occupies.
+func displayWidth(s string) int {
+	n := 0
+	for _, r := range s {
+		n += runeWidth(r)
+	}
+	return n
+}
+
+// truncate shortens s to at most w columns, marking the cut with "…". A
+// wide character that would straddle the limit is dropped whole.
+func truncate(s string, w int) string {
+	if w <= 0 || displayWidth(s) <= w {
+		return s
+	}
+	var b strings.Builder
+	n := 0
+	for _, r := range s {
+		rw := runeWidth(r)
+		if n+rw > w-1 {
+			break
+		}
+		b.WriteRune(r)
+		n += rw
+	}
+	return b.String() + "…"
+}
+
+// padRight pads s with spaces to w columns; fmt's %-*s counts runes, which
+// leaves CJK labels misaligned.
+func padRight(s string, w int) string {
+	return s + strings.Repeat(" ", max(w-displayWidth(s), 0))
+}
@@ -0,0 +1,7 @@
+//go:build !linux && !darwin && !windows
+
+package render
+
+func enableVT() bool { return true }
+
+func consoleWidth() int { return 0 }
@@ -0,0 +1,20 @@
+//go:build linux || darwin
+
+package render
+
+import (
+	"os"
+	"syscall"
+	"unsafe"
+)
+
+func enableVT() bool { return true }
+
+func consoleWidth() int {
+	var ws struct{ Row, Col, X, Y uint16 }
+	_, _, errno := syscall.Syscall(syscall.SYS_IOCTL, os.Stderr.Fd(), uintptr(syscall.TIOCGWINSZ), uintptr(unsafe.Pointer(&ws)))
+	if errno != 0 {
+		return 0
+	}
+	return int(ws.Col)
+}
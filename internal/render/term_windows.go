@@ -0,0 +1,52 @@
+//go:build windows
+
+package render
+
+import (
+	"os"
+	"syscall"
+	"unsafe"
+)
+
+const enableVirtualTerminalProcessing = 0x0004
+
+var (
+	kernel32                       = syscall.NewLazyDLL("kernel32.dll")
+	procSetConsoleMode             = kernel32.NewProc("SetConsoleMode")
+	procGetConsoleScreenBufferInfo = kernel32.NewProc("GetConsoleScreenBufferInfo")
+)
+
+// enableVT turns on VT processing for the stderr console. Windows 10 1511
+// and later accept it; older consoles reject the flag and get no escapes.
+func enableVT() bool {
+	h := syscall.Handle(os.Stderr.Fd())
+	var mode uint32
+	if err := syscall.GetConsoleMode(h, &mode); err != nil {
+		// Not a console, e.g. mintty or a pipe; those handle ANSI themselves.
+		return true
+	}
+	if mode&enableVirtualTerminalProcessing != 0 {
+		return true
+	}
+	r, _, _ := procSetConsoleMode.Call(uintptr(h), uintptr(mode|enableVirtualTerminalProcessing))
+	return r != 0
+}
+
+type coord struct{ X, Y int16 }
+
+type consoleScreenBufferInfo struct {
+	Size              coord
+	CursorPosition    coord
+	Attributes        uint16
+	Window            struct{ Left, Top, Right, Bottom int16 }
+	MaximumWindowSize coord
+}
+
+func consoleWidth() int {
+	var info consoleScreenBufferInfo
+	r, _, _ := procGetConsoleScreenBufferInfo.Call(os.Stderr.Fd(), uintptr(unsafe.Pointer(&info)))
+	if r == 0 {
+		return 0
+	}
+	return int(info.Window.Right-info.Window.Left) + 1
+}
@@ -0,0 +1,331 @@
+package render
+
+import (
+	"fmt"
+	"io"
+	"math"
+	"os"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/tsosunchia/iNetSpeed-CLI/internal/i18n"
+	"github.com/tsosunchia/iNetSpeed-CLI/internal/units"
+)
+
+// tuiInterval is how often the dashboard is redrawn (10 Hz).
+const tuiInterval = 100 * time.Millisecond
+
+// tuiLogLines is how many of the latest text lines the dashboard shows, and
+// tuiSpark how many throughput samples its sparkline keeps.
+const (
+	tuiLogLines = 8
+	tuiSpark    = 120
+)
+
+var sparkRunes = []rune("\u2581\u2582\u2583\u2584\u2585\u2586\u2587\u2588")
+
+// TUIRenderer is the --tui dashboard: a full-screen view redrawn at 10 Hz
+// with a speed gauge, a sparkline of recent throughput, the latest latency
+// probe, thread activity and the run's stage progress. What TTYRenderer
+// would have printed is kept and printed when the dashboard closes, so the
+// results stay in the terminal's scrollback.
+type TUIRenderer struct {
+	mu    sync.Mutex
+	w     io.Writer
+	width func() int
+
+	// Verbose and NoColor are as for TTYRenderer.
+	Verbose bool
+	NoColor bool
+
+	title      string
+	phase      string
+	stage      string
+	step       int
+	steps      int
+	flows      map[string]*tuiFlow // by direction; both run at once under --bidi
+	mbps       float64             // all flows over their latest sample interval
+	avg        float64             // all flows over the current round
+	peak       float64             // highest mbps seen, the gauge's scale
+	spark      []float64
+	threads    int
+	active     int
+	rtt        float64
+	rttPhase   string
+	log        []string
+	transcript []Event
+
+	stop chan struct{}
+	done chan struct{}
+}
+
+// tuiFlow is the latest state of one direction's transfer.
+type tuiFlow struct {
+	bytes, secs, mbps, avg float64
+	threads, active        int
+}
+
+func NewTUIRenderer() *TUIRenderer {
+	return &TUIRenderer{w: os.Stderr, width: termWidth}
+}
+
+func (t *TUIRenderer) c(code string) string {
+	if t.NoColor {
+		return ""
+	}
+	return code
+}
+
+// Start switches to the alternate screen and begins redrawing.
+func (t *TUIRenderer) Start() {
+	t.stop = make(chan struct{})
+	t.done = make(chan struct{})
+	fmt.Fprint(t.w, "\033[?1049h\033[?25l")
+	go func() {
+		defer close(t.done)
+		tick := time.NewTicker(tuiInterval)
+		defer tick.Stop()
+		for {
+			t.draw()
+			select {
+			case <-tick.C:
+			case <-t.stop:
+				return
+			}
+		}
+	}()
+}
+
+// Close stops redrawing, restores the screen and prints the transcript.
+func (t *TUIRenderer) Close() {
+	if t.stop != nil {
+		close(t.stop)
+		<-t.done
+		t.stop = nil
+	}
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	fmt.Fprint(t.w, "\033[?25h\033[?1049l")
+	tr := &TTYRenderer{w: t.w, width: t.width, Verbose: t.Verbose, NoColor: t.NoColor}
+	for _, ev := range t.transcript {
+		tr.Render(ev)
+	}
+	t.transcript = nil
+}
+
+func (t *TUIRenderer) Render(ev Event) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	switch ev.Kind {
+	case KindBanner:
+		if t.title == "" {
+			t.title = ev.Value
+		} else {
+			t.addLog(ev.Value)
+		}
+	case KindHeader:
+		t.phase = ev.Value
+		t.mbps, t.avg, t.threads, t.active = 0, 0, 0, 0
+		t.flows = nil
+	case KindInfo:
+		t.addLog("[+] " + ev.Value)
+	case KindWarn:
+		t.addLog("[!] " + ev.Value)
+	case KindResult:
+		t.addLog(" \u279c  " + ev.Value)
+	case KindKV:
+		t.addLog(padRight(ev.Label+":", kvWidth) + " " + ev.Value)
+	case KindFatal:
+		t.addLog("[\u2717] " + ev.Value)
+	case KindDebug:
+		if t.Verbose {
+			t.addLog("[.] " + ev.Value)
+		}
+	case KindStage:
+		if ev.Value == "start" {
+			t.stage = ev.Label
+			t.step = int(toFloat(ev.Data["step"]))
+			t.steps = int(toFloat(ev.Data["steps"]))
+		}
+	case KindSample:
+		t.sample(ev.Label, ev.Data)
+	case KindLatency:
+		t.rtt = toFloat(ev.Data["rtt_ms"])
+		t.rttPhase = ev.Label
+	}
+	switch ev.Kind {
+	case KindProgress, KindSync, KindPrompt:
+	default:
+		if !ev.Kind.dataOnly() {
+			t.transcript = append(t.transcript, ev)
+		}
+	}
+}
+
+func (t *TUIRenderer) addLog(line string) {
+	t.log = append(t.log, line)
+	if len(t.log) > tuiLogLines {
+		t.log = t.log[len(t.log)-tuiLogLines:]
+	}
+}
+
+// sample takes the throughput of the interval since the direction's
+// previous sample from the round's running totals, and sums the directions.
+func (t *TUIRenderer) sample(dir string, d map[string]any) {
+	if t.flows == nil {
+		t.flows = map[string]*tuiFlow{}
+	}
+	f := t.flows[dir]
+	if f == nil {
+		f = &tuiFlow{}
+		t.flows[dir] = f
+	}
+	bytes := toFloat(d["bytes"])
+	secs := toFloat(d["elapsed_s"])
+	if secs > f.secs && bytes >= f.bytes {
+		f.mbps = (bytes - f.bytes) * 8 / ((secs - f.secs) * 1e6)
+	}
+	f.bytes, f.secs = bytes, secs
+	f.avg = toFloat(d["mbps"])
+	f.threads = int(toFloat(d["threads"]))
+	f.active = int(toFloat(d["active"]))
+
+	t.mbps, t.avg, t.threads, t.active = 0, 0, 0, 0
+	for _, f := range t.flows {
+		t.mbps += f.mbps
+		t.avg += f.avg
+		t.threads += f.threads
+		t.active += f.active
+	}
+	t.spark = append(t.spark, t.mbps)
+	if len(t.spark) > tuiSpark {
+		t.spark = t.spark[len(t.spark)-tuiSpark:]
+	}
+	t.peak = math.Max(t.peak, math.Max(t.mbps, t.avg))
+	if ms, ok := d["latency_ms"].(float64); ok {
+		t.rtt, t.rttPhase = ms, "loaded"
+	}
+}
+
+func toFloat(v any) float64 {
+	switch n := v.(type) {
+	case float64:
+		return n
+	case int:
+		return float64(n)
+	case int32:
+		return float64(n)
+	case int64:
+		return float64(n)
+	}
+	return 0
+}
+
+func (t *TUIRenderer) draw() {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	w := 80
+	if t.width != nil {
+		w = t.width()
+	}
+	var b strings.Builder
+	b.WriteString("\033[H")
+	for _, line := range t.frame(w) {
+		b.WriteString(line)
+		b.WriteString("\033[K\n")
+	}
+	b.WriteString("\033[J")
+	io.WriteString(t.w, b.String())
+}
+
+// frame lays out the dashboard for a terminal w columns wide.
+func (t *TUIRenderer) frame(w int) []string {
+	w = max(w, 40)
+	bar := w - 34 // room for the label and the figures beside the bar
+	var out []string
+	add := func(s string) { out = append(out, s) }
+	label := func(en, zh string) string { return "  " + padRight(i18n.Text(en, zh), 9) }
+
+	add("  " + t.c(cCyan) + t.c(cBold) + truncate(t.title, w-3) + t.c(cReset))
+	add("  " + t.c(cCyan) + t.c(cBold) + "\u25b8 " + truncate(t.phase, w-5) + t.c(cReset))
+	progress := 0.0
+	if t.steps > 0 {
+		progress = float64(t.step) / float64(t.steps)
+	}
+	add(label("Stage", "阶段") + fmt.Sprintf("%s %d/%d %s", t.bar(progress, bar, cCyan), t.step, t.steps, truncate(t.stage, 14)))
+	add("")
+
+	add(label("Speed", "速度") + fmt.Sprintf("%s %12s", t.bar(t.mbps/gaugeScale(t.peak), bar, cGreen), units.Rate(t.mbps, 1)))
+	add(label("", "") + fmt.Sprintf(i18n.Text("avg %s  peak %s %s", "平均 %s  峰值 %s %s"), units.Value(t.avg, 1), units.Value(t.peak, 1), units.Label()))
+	add(label("Trend", "趋势") + t.c(cGreen) + sparkline(t.spark, w-12) + t.c(cReset))
+	switch {
+	case t.rttPhase == "":
+		add(label("Latency", "延迟") + "-")
+	case strings.HasPrefix(t.rttPhase, "idle"):
+		add(label("Latency", "延迟") + fmt.Sprintf(i18n.Text("%.1f ms (idle)", "%.1f 毫秒（空载）"), t.rtt))
+	default:
+		add(label("Latency", "延迟") + fmt.Sprintf(i18n.Text("%.1f ms (loaded)", "%.1f 毫秒（负载）"), t.rtt))
+	}
+	if t.threads > 0 {
+		dots := strings.Repeat("\u25cf", min(t.active, t.threads)) + strings.Repeat("\u25cb", max(t.threads-t.active, 0))
+		add(label("Threads", "线程") + truncate(dots, bar) + fmt.Sprintf(i18n.Text(" %d/%d active", " %d/%d 活跃"), t.active, t.threads))
+	} else {
+		add(label("Threads", "线程") + "-")
+	}
+	add("")
+	add(t.c(cDim) + strings.Repeat("\u2500", w-1) + t.c(cReset))
+	for _, line := range t.log {
+		add("  " + truncate(line, w-3))
+	}
+	return out
+}
+
+// bar draws a bracketed bar w cells wide filled to frac.
+func (t *TUIRenderer) bar(frac float64, w int, color string) string {
+	if math.IsNaN(frac) {
+		frac = 0
+	}
+	frac = math.Min(math.Max(frac, 0), 1)
+	n := int(math.Round(frac * float64(w)))
+	return "[" + t.c(color) + strings.Repeat("\u2588", n) + t.c(cReset) + t.c(cDim) + strings.Repeat("\u2591", w-n) + t.c(cReset) + "]"
+}
+
+// gaugeScale is the full-scale value of the speed gauge: the smallest 1, 2
+// or 5 times a power of ten at or above peak.
+func gaugeScale(peak float64) float64 {
+	if peak <= 0 {
+		return 1
+	}
+	mag := math.Pow(10, math.Floor(math.Log10(peak)))
+	for _, m := range []float64{1, 2, 5, 10} {
+		if peak <= m*mag {
+			return m * mag
+		}
+	}
+	return 10 * mag
+}
+
+// sparkline draws the last w values of vs, scaled to their maximum.
+func sparkline(vs []float64, w int) string {
+	if w <= 0 || len(vs) == 0 {
+		return ""
+	}
+	if len(vs) > w {
+		vs = vs[len(vs)-w:]
+	}
+	var top float64
+	for _, v := range vs {
+		top = math.Max(top, v)
+	}
+	var b strings.Builder
+	for _, v := range vs {
+		i := 0
+		if top > 0 {
+			i = int(v / top * float64(len(sparkRunes)-1))
+		}
+		b.WriteRune(sparkRunes[min(max(i, 0), len(sparkRunes)-1)])
+	}
+	return b.String()
+}
@@ -0,0 +1,843 @@
+package report
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/url"
+	"slices"
+	"strings"
+	"time"
+)
+
+// Version is stamped into every report; cmd/speedtest overrides it with the
+// linker-provided build version.
+var Version = "dev"
+
+// Report is the machine-readable result of a full run. Field names are part
+// of the public output format and must stay stable.
+type Report struct {
+	// ID is a random UUID naming the run, which stays with the report in
+	// the history file and the collectors it is sent to.
+	ID          string     `json:"id,omitempty"`
+	Version     string     `json:"version"`
+	Time        time.Time  `json:"time"`
+	Units       Units      `json:"units"`
+	Config      ConfigInfo `json:"config"`
+	Client      Peer       `json:"client"`
+	Server      Peer       `json:"server"`
+	System      *System    `json:"system,omitempty"`
+	IdleLatency Latency    `json:"idle_latency"`
+	// IdleLatencyAfter is measured again once the throughput rounds are done.
+	// LatencyDriftMs is its median minus the IdleLatency median, and
+	// LatencyDrifted flags a rise large enough to suggest the link did not
+	// recover from the load.
+	IdleLatencyAfter *Latency `json:"idle_latency_after,omitempty"`
+	LatencyDriftMs   float64  `json:"latency_drift_ms,omitempty"`
+	LatencyDrifted   bool     `json:"latency_drifted,omitempty"`
+	// IdleLatencyNewConn is the idle latency over a new connection per
+	// probe, set under LATENCY_CONN=both; its excess over IdleLatency is
+	// the cost of connection setup.
+	IdleLatencyNewConn *Latency `json:"idle_latency_new_conn,omitempty"`
+	// ICMPLatency is set when --icmp ran. LatencyDiscrepancy is "icmp_slower"
+	// or "http_slower" when the two disagree by more than path noise.
+	ICMPLatency        *Latency `json:"icmp_latency,omitempty"`
+	ICMPLossPct        float64  `json:"icmp_loss_pct,omitempty"`
+	LatencyDiscrepancy string   `json:"latency_discrepancy,omitempty"`
+	// LatencyTargets holds the ICMP latency of each LATENCY_TARGETS host.
+	LatencyTargets []TargetLatency `json:"latency_targets,omitempty"`
+	// LatencyByFlows groups the loaded-latency samples of the transfer
+	// rounds by the flows running when each was taken.
+	LatencyByFlows []FlowLatency `json:"latency_by_flows,omitempty"`
+	// MTU is set when --mtu ran.
+	MTU *MTU `json:"mtu,omitempty"`
+	// UDP is set when --udp-echo ran.
+	UDP *UDP `json:"udp,omitempty"`
+	// RequestRate is set when --request-rate ran: small-object requests per
+	// second with one request in flight, then with one per thread.
+	RequestRate   []RequestRate `json:"request_rate,omitempty"`
+	Rounds        []Round       `json:"rounds"`
+	DataUsedBytes int64         `json:"data_used_bytes"`
+	Degraded      bool          `json:"degraded"`
+	// WireBytes is every byte the test connections read and wrote: the
+	// payload of DataUsedBytes plus the latency probes and the HTTP and
+	// TLS framing.
+	WireBytes int64 `json:"wire_bytes,omitempty"`
+	// Bidirectional is set when --bidi ran.
+	Bidirectional *Bidi `json:"bidirectional,omitempty"`
+	// ThreadRamps holds a round per direction when THREAD_SCHEDULE ran.
+	ThreadRamps []ThreadRamp `json:"thread_ramps,omitempty"`
+	// UploadCheck is set when --verify-upload ran.
+	UploadCheck *UploadCheck `json:"upload_check,omitempty"`
+	// CacheCheck is set when --cache-check ran.
+	CacheCheck *CacheCheck `json:"cache_check,omitempty"`
+	// ColdStart is set when --cold-start ran.
+	ColdStart *ColdStart `json:"cold_start,omitempty"`
+	// Failovers lists every switch from a failing test URL to the next of
+	// its list, in the order they happened.
+	Failovers []Failover `json:"failovers,omitempty"`
+	// LineRate estimates what the link carried for the best rounds.
+	LineRate *LineRate `json:"line_rate,omitempty"`
+	// ProxyRoutes records, under PROXY_PAC, the PAC decision each stage's
+	// requests to each host were sent with, in stage order, then by host.
+	ProxyRoutes []ProxyRoute `json:"proxy_routes,omitempty"`
+	// CDN records the edge node that served each stage's first response.
+	CDN []CDNNode `json:"cdn,omitempty"`
+	// TLS lists the distinct sessions the test connections negotiated.
+	TLS []TLSSession `json:"tls,omitempty"`
+	// TestEndpoint is the edge Apple's networkQuality configuration
+	// assigned under --discover.
+	TestEndpoint string `json:"test_endpoint,omitempty"`
+	// RateCapped marks results measured under --limit-rate; throughput then
+	// reflects the cap rather than the link.
+	RateCapped bool `json:"rate_capped,omitempty"`
+	// TotalCapReached is set when --max-total or --data-budget cut the run
+	// short.
+	TotalCapReached bool `json:"total_cap_reached,omitempty"`
+	// Simulated marks runs against the built-in emulator (--simulate).
+	Simulated bool `json:"simulated,omitempty"`
+	// Probe identifies the installation that ran the test (PROBE_ID).
+	Probe *Probe `json:"probe,omitempty"`
+	// Tags are the --tag key=value pairs the run was recorded with, such as
+	// location=office, for grouping results collected across conditions.
+	Tags map[string]string `json:"tags,omitempty"`
+	// ConnComparison is filled by CONNECTION_MODE=both, one entry per
+	// direction that ran in both modes.
+	ConnComparison []ConnComparison `json:"connection_comparison,omitempty"`
+	// Ranking places the best round of each direction within the reference
+	// distribution of the client's ASN or country.
+	Ranking []Rank `json:"ranking,omitempty"`
+	// PerASN compares the first endpoint candidate of each AS when --per-asn
+	// ran and the candidates spanned several.
+	PerASN []ASNResult `json:"per_asn,omitempty"`
+	// TCPWindow holds the bandwidth-delay product of each direction and,
+	// where the OS exposes them, how it compares with the TCP buffer limits.
+	TCPWindow []WindowCheck `json:"tcp_window,omitempty"`
+	// Diagnostics counts the faulted transfer requests of all rounds by
+	// direction and category, the most frequent first, followed by the
+	// findings outside the transfers.
+	Diagnostics []Diagnostic `json:"diagnostics,omitempty"`
+	// Assertions holds the --assert-* checks, one per limit set.
+	Assertions []Assertion `json:"assertions,omitempty"`
+	// Run and Runs number the reports of --runs; the last one carries
+	// RunStats, each metric's spread over all of them. Runs is 0 in a
+	// series running until interrupted, which has no last one.
+	Run      int           `json:"run,omitempty"`
+	Runs     int           `json:"runs,omitempty"`
+	RunStats []MetricStats `json:"run_stats,omitempty"`
+	// IPVersion is 4 or 6 in the two reports of --compare-ip-versions; the
+	// IPv6 one carries IPComparison.
+	IPVersion    int       `json:"ip_version,omitempty"`
+	IPComparison []IPDelta `json:"ip_comparison,omitempty"`
+}
+
+// Assertion is one --assert-* check. Value is zero when the metric was not
+// measured, which fails the check.
+type Assertion struct {
+	Metric string  `json:"metric"` // download, upload or latency
+	Limit  float64 `json:"limit"`  // Mbps minimum, or ms maximum for latency
+	Value  float64 `json:"value"`
+	Passed bool    `json:"passed"`
+}
+
+// ASNResult is the short test --per-asn ran against the first endpoint
+// candidate of one AS. Selected marks the endpoint the run went on with.
+type ASNResult struct {
+	ASN          string  `json:"asn"`
+	Name         string  `json:"name,omitempty"`
+	IP           string  `json:"ip"`
+	LatencyMs    float64 `json:"latency_ms"`
+	DownloadMbps float64 `json:"download_mbps"`
+	Selected     bool    `json:"selected,omitempty"`
+}
+
+// TargetLatency is the ICMP latency of a latency target while idle and
+// during the download and upload rounds. Error is set when the target could
+// not be resolved or pinged.
+type TargetLatency struct {
+	Target        string   `json:"target"`
+	IP            string   `json:"ip,omitempty"`
+	Idle          *Latency `json:"idle,omitempty"`
+	IdleLossPct   float64  `json:"idle_loss_pct,omitempty"`
+	Download      *Latency `json:"loaded_download,omitempty"`
+	Upload        *Latency `json:"loaded_upload,omitempty"`
+	LoadedLossPct float64  `json:"loaded_loss_pct,omitempty"`
+	Error         string   `json:"error,omitempty"`
+}
+
+// FlowLatency is the loaded latency of the samples taken while
+// DownloadFlows and UploadFlows transfer flows ran. Direction is download,
+// upload or both.
+type FlowLatency struct {
+	Direction     string  `json:"direction"`
+	DownloadFlows int     `json:"download_flows"`
+	UploadFlows   int     `json:"upload_flows"`
+	Samples       int     `json:"samples"`
+	MedianMs      float64 `json:"median_ms"`
+	AvgMs         float64 `json:"avg_ms"`
+	MaxMs         float64 `json:"max_ms"`
+}
+
+// MTU is the result of path MTU probing. TCPMSS and TCPMTU come from a TCP
+// connection to the endpoint, PathMTU from unfragmented ICMP echoes; either
+// is zero when its probe was unavailable.
+type MTU struct {
+	TCPMSS    int  `json:"tcp_mss,omitempty"`
+	TCPMTU    int  `json:"tcp_mtu,omitempty"`
+	PathMTU   int  `json:"path_mtu,omitempty"`
+	Blackhole bool `json:"pmtud_blackhole,omitempty"`
+}
+
+// UDP is the result of probing a UDP echo server. The one-way jitters are
+// present only when the server timestamps its replies, as `speedtest server`
+// does.
+type UDP struct {
+	Target       string  `json:"target"`
+	Sent         int     `json:"sent"`
+	Received     int     `json:"received"`
+	LossPct      float64 `json:"loss_pct"`
+	Reordered    int     `json:"reordered,omitempty"`
+	Duplicates   int     `json:"duplicates,omitempty"`
+	Latency      Latency `json:"latency"`
+	UpJitterMs   float64 `json:"up_jitter_ms,omitempty"`
+	DownJitterMs float64 `json:"down_jitter_ms,omitempty"`
+}
+
+// ProxyRoute is the PAC entry, such as "DIRECT" or "PROXY proxy:3128",
+// applied to a stage's requests to Host.
+type ProxyRoute struct {
+	Stage string `json:"stage,omitempty"`
+	Host  string `json:"host"`
+	Proxy string `json:"proxy"`
+}
+
+// CDNNode is the edge that served the first response of a stage, as told
+// by the CDN's response headers.
+type CDNNode struct {
+	Stage    string `json:"stage,omitempty"`
+	Provider string `json:"provider,omitempty"`
+	Node     string `json:"node,omitempty"`
+	PoP      string `json:"pop,omitempty"`
+	Cache    string `json:"cache,omitempty"`
+	ID       string `json:"id,omitempty"`
+	Server   string `json:"server,omitempty"`
+	Via      string `json:"via,omitempty"`
+}
+
+// TLSSession is one combination of TLS parameters and server certificate
+// the test connections negotiated, and how many connections did. A
+// certificate that does not chain to a root the system trusts points to a
+// TLS-intercepting middlebox, which often slows the transfer too.
+type TLSSession struct {
+	Version     string   `json:"version"`
+	CipherSuite string   `json:"cipher_suite"`
+	ALPN        string   `json:"alpn,omitempty"`
+	Subject     string   `json:"subject,omitempty"`
+	Issuer      string   `json:"issuer,omitempty"`
+	SANs        []string `json:"sans,omitempty"`
+	Untrusted   bool     `json:"untrusted,omitempty"`
+	Connections int      `json:"connections"`
+	Resumed     int      `json:"resumed"`
+}
+
+// UploadCheck is one upload sent to completion and the server's answer to
+// it. WriteMbps is the rate the client handed the body to the network, as
+// the upload rounds measure it; AckMbps runs up to the server's answer.
+// Suspect marks an answer that does not back the upload results: an error
+// status, fewer bytes acknowledged than sent, or an acknowledgement that
+// came far slower than the writes, the rest having sat in buffers.
+type UploadCheck struct {
+	Status     int     `json:"status"`
+	SentBytes  int64   `json:"sent_bytes"`
+	AckedBytes *int64  `json:"acked_bytes,omitempty"` // as reported by the server, when it did
+	WriteMbps  float64 `json:"write_mbps"`
+	AckMbps    float64 `json:"ack_mbps"`
+	Suspect    bool    `json:"suspect,omitempty"`
+}
+
+// CacheCheck is the download object fetched twice as it is and twice with
+// a cache-busting query. Verdict is "isp-cache" when a cache outside the
+// CDN, one of Proxies, answered from its store; "proxy" when Proxies only
+// passed the requests on; "unknown-cache" when responses without any CDN
+// marker came from a cache and much faster than the cache-busted ones; and
+// "cdn" otherwise. QueryIgnored marks cache-busted fetches answered from a
+// cache all the same.
+type CacheCheck struct {
+	Verdict      string       `json:"verdict"`
+	Proxies      []string     `json:"proxies,omitempty"`
+	QueryIgnored bool         `json:"query_ignored,omitempty"`
+	PlainTTFBMs  float64      `json:"plain_ttfb_ms"`
+	BustedTTFBMs float64      `json:"busted_ttfb_ms"`
+	Fetches      []CacheFetch `json:"fetches"`
+}
+
+// CacheFetch is one fetch of the cache check. AgeSec is the Age header,
+// when there was one; Cache is the X-Cache status and Provider the CDN
+// whose markers the response carried.
+type CacheFetch struct {
+	Busted   bool    `json:"busted"`
+	Status   int     `json:"status"`
+	AgeSec   *int    `json:"age_sec,omitempty"`
+	Cache    string  `json:"cache,omitempty"`
+	Provider string  `json:"provider,omitempty"`
+	TTFBMs   float64 `json:"ttfb_ms"`
+	Bytes    int64   `json:"bytes"`
+}
+
+// ColdStart compares the first WindowSec seconds of a download over a new
+// connection, Cold, with those over a connection warmed up beforehand,
+// Warm. PenaltyPct is how much less the new connection moved; RampMs is
+// when it first reached 90% of the warm rate, null when it did not within
+// the window.
+type ColdStart struct {
+	WindowSec  float64        `json:"window_sec"`
+	Cold       ColdStartFetch `json:"cold"`
+	Warm       ColdStartFetch `json:"warm"`
+	PenaltyPct float64        `json:"penalty_pct"`
+	RampMs     *float64       `json:"ramp_ms"`
+}
+
+// ColdStartFetch is one fetch of the cold-start test, timed from sending
+// the request. Reused is whether it went over an existing connection.
+type ColdStartFetch struct {
+	Mbps   float64 `json:"mbps"`
+	TTFBMs float64 `json:"ttfb_ms"`
+	Bytes  int64   `json:"bytes"`
+	Reused bool    `json:"reused"`
+}
+
+// LineRate is the goodput of the best rounds with the overhead of every
+// layer under HTTP added back: the rate to hold against an advertised link
+// speed. Link names the OVERHEAD_MODEL framing, LinkBytes per packet over
+// IP packets of up to MTU bytes; each factor is the wire bytes per byte of
+// payload in that direction, TLS and HTTP/2 framing included where used.
+type LineRate struct {
+	Link           string  `json:"link"`
+	LinkBytes      int     `json:"link_bytes"`
+	MTU            int     `json:"mtu"`
+	IPv6           bool    `json:"ipv6,omitempty"`
+	HTTP2          bool    `json:"http2,omitempty"`
+	DownloadMbps   float64 `json:"download_mbps,omitempty"`
+	DownloadFactor float64 `json:"download_factor,omitempty"`
+	UploadMbps     float64 `json:"upload_mbps,omitempty"`
+	UploadFactor   float64 `json:"upload_factor,omitempty"`
+}
+
+// Bidi is the --bidi round: download and upload at once, Threads each.
+// The retained shares compare each direction with its best solo round, and
+// Congested names the direction that lost far more than the other, or
+// "both" when each kept too little, as on a half-duplex medium.
+type Bidi struct {
+	Threads          int     `json:"threads"`
+	DurationSec      float64 `json:"duration_sec"`
+	DownloadMbps     float64 `json:"download_mbps"`
+	UploadMbps       float64 `json:"upload_mbps"`
+	CombinedMbps     float64 `json:"combined_mbps"`
+	LoadedLatency    Latency `json:"loaded_latency"`
+	DownloadRetained float64 `json:"download_retained,omitempty"`
+	UploadRetained   float64 `json:"upload_retained,omitempty"`
+	Congested        string  `json:"congested,omitempty"`
+	PausedSec        float64 `json:"paused_sec,omitempty"`     // as for Round
+	ClientCPUPct     float64 `json:"client_cpu_pct,omitempty"` // as for Round
+}
+
+// Failover is a switch from a test URL that failed its check to the next
+// one of the list given for it. URL names the list: dl_url, ul_url or
+// latency_url. Stage is where the failure showed.
+type Failover struct {
+	URL    string `json:"url"`
+	Stage  string `json:"stage"`
+	From   string `json:"from"`
+	To     string `json:"to"`
+	Reason string `json:"reason"`
+}
+
+// ThreadRamp is the THREAD_SCHEDULE round of one direction: its throughput
+// every SeriesIntervalMs, and the mean of each step of the schedule.
+type ThreadRamp struct {
+	Direction        string     `json:"direction"`
+	SeriesIntervalMs int        `json:"series_interval_ms"`
+	SeriesMbps       []float64  `json:"series_mbps"`
+	Steps            []RampStep `json:"steps"`
+}
+
+// RampStep is one step of a ThreadRamp. GainPct compares its throughput
+// with the step before; the first step has none.
+type RampStep struct {
+	Threads  int     `json:"threads"`
+	StartSec float64 `json:"start_sec"`
+	EndSec   float64 `json:"end_sec"`
+	Mbps     float64 `json:"mbps"`
+	GainPct  float64 `json:"gain_pct,omitempty"`
+}
+
+// RequestRate is one request-rate phase against LATENCY_URL.
+type RequestRate struct {
+	Workers     int     `json:"workers"`
+	Requests    int     `json:"requests"`
+	Errors      int     `json:"errors"`
+	DurationSec float64 `json:"duration_sec"`
+	RPS         float64 `json:"rps"`
+	MedianMs    float64 `json:"median_ms"`
+	TTFB        *TTFB   `json:"ttfb_ms,omitempty"`
+}
+
+// TTFB is the spread of a request-rate phase's time to first byte, in ms.
+type TTFB struct {
+	Min float64 `json:"min"`
+	P50 float64 `json:"p50"`
+	P90 float64 `json:"p90"`
+	P99 float64 `json:"p99"`
+	Max float64 `json:"max"`
+}
+
+// WindowCheck sets a direction's bandwidth-delay product against the
+// kernel's TCP buffer limit. One connection can carry at most LimitBytes
+// per round trip, i.e. CeilingMbps; a single-thread round reaching that
+// ceiling is WindowBound: host tuning, not the network, is the cap.
+type WindowCheck struct {
+	Direction  string  `json:"direction"`
+	Mbps       float64 `json:"mbps"` // best round
+	RTTMs      float64 `json:"rtt_ms"`
+	BDPBytes   int64   `json:"bdp_bytes"`
+	SingleMbps float64 `json:"single_mbps,omitempty"`
+	// LimitBytes is zero when the limits could not be read.
+	LimitBytes       int64   `json:"limit_bytes,omitempty"`
+	LimitSource      string  `json:"limit_source,omitempty"`
+	CeilingMbps      float64 `json:"ceiling_mbps,omitempty"`
+	WindowBound      bool    `json:"window_bound,omitempty"`
+	RecommendedBytes int64   `json:"recommended_bytes,omitempty"`
+}
+
+// Rank is where a result falls among users of the same group. Percentile
+// is the share of the group below Mbps; Capped means the result is beyond
+// the top of the reference, so the share is at least Percentile.
+type Rank struct {
+	Direction  string  `json:"direction"`
+	Mbps       float64 `json:"mbps"`
+	Group      string  `json:"group"`
+	GroupName  string  `json:"group_name,omitempty"`
+	Percentile float64 `json:"percentile"`
+	Capped     bool    `json:"capped,omitempty"`
+}
+
+// ConnComparison sets N separate connections against N streams multiplexed
+// on one HTTP/2 connection. A single connection falling well short of the
+// separate ones points at per-connection shaping rather than link capacity.
+type ConnComparison struct {
+	Direction     string  `json:"direction"`
+	Streams       int     `json:"streams"`
+	MultiMbps     float64 `json:"multi_mbps"`
+	SingleH2Mbps  float64 `json:"single_h2_mbps"`
+	Ratio         float64 `json:"ratio"`
+	PerConnShaped bool    `json:"per_connection_shaping"`
+}
+
+// Diagnostic is how many transfer requests in one direction failed with one
+// kind of fault: dns, connect, tls, http_status, read_timeout, reset or
+// other. A finding outside the transfers sets Finding instead: the
+// LatencyDiscrepancy, with the HTTP and ICMP idle medians it compares.
+type Diagnostic struct {
+	Direction string  `json:"direction,omitempty"`
+	Fault     string  `json:"fault,omitempty"`
+	Count     int     `json:"count,omitempty"`
+	Finding   string  `json:"finding,omitempty"`
+	HTTPMs    float64 `json:"http_ms,omitempty"`
+	ICMPMs    float64 `json:"icmp_ms,omitempty"`
+}
+
+// Probe is the identity a report is filed under. It is kept by Anonymized:
+// collectors need it to tell sites apart.
+type Probe struct {
+	ID   string `json:"id"`
+	Name string `json:"name,omitempty"`
+}
+
+// Units names the units of the report's fields, which do not follow
+// --units: Display is the unit the terminal showed throughput in.
+type Units struct {
+	Throughput string `json:"throughput"`
+	Latency    string `json:"latency"`
+	Data       string `json:"data"`
+	Display    string `json:"display"`
+}
+
+type ConfigInfo struct {
+	DLURL         string `json:"dl_url"`
+	ULURL         string `json:"ul_url"`
+	LatencyURL    string `json:"latency_url"`
+	Max           string `json:"max"`
+	TimeoutSec    int    `json:"timeout_sec"`
+	Threads       int    `json:"threads"`
+	LatencyCount  int    `json:"latency_count"`
+	UploadPayload string `json:"upload_payload"`
+	LimitRate     string `json:"limit_rate,omitempty"`
+	MaxTotal      string `json:"max_total,omitempty"`
+	ConnMode      string `json:"connection_mode,omitempty"`
+	DownloadMode  string `json:"download_mode,omitempty"`
+	Insecure      bool   `json:"insecure,omitempty"` // server certificates were not verified
+	// DataBudget is the --data-budget the rounds were scaled to fit.
+	DataBudget string `json:"data_budget,omitempty"`
+	// Phases lists the phases the run was limited to; the report leaves
+	// out the sections of the others.
+	Phases []string `json:"phases,omitempty"`
+	// SaturationWindowSec is the --stop-on-saturation window; zero when
+	// rounds ran to their limits.
+	SaturationWindowSec float64 `json:"saturation_window_sec,omitempty"`
+	// DSCP is the --dscp marking of the test traffic and TOS the byte that
+	// carried it.
+	DSCP string `json:"dscp,omitempty"`
+	TOS  int    `json:"tos,omitempty"`
+	// AutoMbps is the probe throughput MAX=auto chose Max from.
+	AutoMbps float64 `json:"max_auto_probe_mbps,omitempty"`
+	// ProxyPAC is the PAC file that chose the test requests' proxies.
+	ProxyPAC string `json:"proxy_pac,omitempty"`
+	// The latency probes' LATENCY_INTERVAL, LATENCY_PROBE_SIZE and
+	// LATENCY_CONN, each omitted at its default.
+	LatencyIntervalMs float64 `json:"latency_interval_ms,omitempty"`
+	LatencyProbeSize  string  `json:"latency_probe_size,omitempty"`
+	LatencyConn       string  `json:"latency_conn,omitempty"`
+	// Preset is the PRESET bundle the settings started from.
+	Preset string `json:"preset,omitempty"`
+	// Region is the --region the CDN host was resolved as from.
+	Region string `json:"region,omitempty"`
+}
+
+// System describes the client's end of the path (--sysinfo). LinkMbps is
+// the negotiated speed of a wired link; a wireless one sets WiFi instead.
+type System struct {
+	Interface string   `json:"interface,omitempty"`
+	LinkMbps  int      `json:"link_mbps,omitempty"`
+	WiFi      *WiFi    `json:"wifi,omitempty"`
+	Gateway   string   `json:"gateway,omitempty"`
+	DNS       []string `json:"dns,omitempty"`
+}
+
+// WiFi is the state of the wireless link when the test started. TxMbps is
+// the PHY rate of the last frame sent, which drops while the link is idle.
+type WiFi struct {
+	SSID     string  `json:"ssid,omitempty"`
+	RSSIdBm  int     `json:"rssi_dbm,omitempty"`
+	NoisedBm int     `json:"noise_dbm,omitempty"`
+	TxMbps   float64 `json:"tx_rate_mbps,omitempty"`
+	Channel  string  `json:"channel,omitempty"`
+}
+
+type Peer struct {
+	IP       string `json:"ip,omitempty"`
+	Host     string `json:"host,omitempty"`
+	ISP      string `json:"isp,omitempty"`
+	ASN      string `json:"asn,omitempty"`
+	Location string `json:"location,omitempty"`
+	Country  string `json:"country,omitempty"` // ISO 3166-1 alpha-2
+	Endpoint string `json:"endpoint,omitempty"`
+}
+
+type Latency struct {
+	MinMs    float64 `json:"min_ms"`
+	AvgMs    float64 `json:"avg_ms"`
+	MedianMs float64 `json:"median_ms"`
+	MaxMs    float64 `json:"max_ms"`
+	JitterMs float64 `json:"jitter_ms"`
+	Samples  int     `json:"samples"`
+
+	// Percentiles are read from a histogram of the samples.
+	Percentiles *Percentiles `json:"percentiles,omitempty"`
+
+	// Method names the filter the figures above passed through, when
+	// they did: "drop-first+mad-3.5" drops the first sample as connection
+	// warm-up and rejects those more than 3.5 modified z-scores, by median
+	// absolute deviation, from the median. Raw is the same over every
+	// sample.
+	Method           string   `json:"method,omitempty"`
+	WarmupDropped    int      `json:"warmup_dropped,omitempty"`
+	OutliersRejected int      `json:"outliers_rejected,omitempty"`
+	Raw              *Latency `json:"raw,omitempty"`
+}
+
+// Percentiles is the spread of a set of samples, such as latencies in ms
+// or per-interval throughput in Mbps.
+type Percentiles struct {
+	P1  float64 `json:"p1"`
+	P25 float64 `json:"p25"`
+	P50 float64 `json:"p50"`
+	P75 float64 `json:"p75"`
+	P99 float64 `json:"p99"`
+}
+
+type Round struct {
+	// Name is English whatever the UI language; Label is the name as shown
+	// and never serialized.
+	Name          string    `json:"name"`
+	Label         string    `json:"-"`
+	Direction     string    `json:"direction"`
+	Threads       int       `json:"threads"`
+	Bytes         int64     `json:"bytes"`
+	DurationSec   float64   `json:"duration_sec"`
+	Mbps          float64   `json:"mbps"`
+	Faults        int       `json:"faults"`
+	LoadedLatency Latency   `json:"loaded_latency"`
+	TCP           []TCPFlow `json:"tcp,omitempty"`
+	// FaultKinds breaks Faults down by category, as in Diagnostic.
+	FaultKinds map[string]int `json:"fault_kinds,omitempty"`
+	// ConnMode is "multi" or "single-h2" when CONNECTION_MODE pinned how
+	// the round's threads were connected.
+	ConnMode string `json:"connection_mode,omitempty"`
+	// LoadedLatencyNewConn is LoadedLatency over a new connection per
+	// probe, set under LATENCY_CONN=both.
+	LoadedLatencyNewConn *Latency `json:"loaded_latency_new_conn,omitempty"`
+	// Upload rounds keep their throughput every SeriesIntervalMs and the
+	// policer/shaper diagnosis drawn from it.
+	SeriesIntervalMs int       `json:"series_interval_ms,omitempty"`
+	SeriesMbps       []float64 `json:"series_mbps,omitempty"`
+	Ramp             *Ramp     `json:"ramp,omitempty"`
+	// Scatter pairs each loaded-latency sample with the throughput of the
+	// interval it arrived in.
+	Scatter *Scatter `json:"scatter,omitempty"`
+	// Timeline is never serialized; see Timeline.
+	Timeline *Timeline `json:"-"`
+	// Multi-thread rounds keep each thread's share of the bytes, in thread
+	// order, and Jain's fairness index over them (1 is an even split).
+	// Skewed marks a round where one connection took most of the link.
+	WorkerShares []float64 `json:"worker_shares,omitempty"`
+	Fairness     float64   `json:"fairness,omitempty"`
+	Skewed       bool      `json:"skewed,omitempty"`
+	// PausedSec is how long the system was suspended (e.g. laptop sleep)
+	// during the round; DurationSec and Mbps leave it out.
+	PausedSec float64 `json:"paused_sec,omitempty"`
+	// ClientCPUPct is the CPU the client used during the round, as a share
+	// of the cores it may use. ClientBound marks a round where that was
+	// nearly all of it: the device, not the network, set the pace.
+	ClientCPUPct float64 `json:"client_cpu_pct,omitempty"`
+	ClientBound  bool    `json:"client_bound,omitempty"`
+	// ThroughputPct is the spread of the throughput over the round's
+	// 100 ms slices. MicroStalls counts the slices, between the first and
+	// the last that reached the median, below a tenth of it: stalls too
+	// short to move the round's average.
+	ThroughputPct *Percentiles `json:"throughput_percentiles_mbps,omitempty"`
+	MicroStalls   int          `json:"micro_stalls,omitempty"`
+	// Saturated marks a round --stop-on-saturation ended early; Mbps is
+	// then the steady rate of its last window.
+	Saturated bool `json:"saturated,omitempty"`
+	// Interface is what the OS counted on the interface the round ran
+	// over, where it keeps such counters.
+	Interface *IfaceCounters `json:"interface,omitempty"`
+}
+
+// Title is the round's name in the UI language, or its report name when the
+// round was read back from JSON.
+func (rd Round) Title() string {
+	if rd.Label != "" {
+		return rd.Label
+	}
+	return rd.Name
+}
+
+// IfaceCounters checks a round against the OS: Bytes is what its interface
+// received (download) or sent (upload) during the round, and Overhead that
+// over the bytes the round counted. Protocol headers put it a few percent
+// above 1; OtherTraffic marks a round where far more crossed the
+// interface, likely traffic that was not the test's.
+type IfaceCounters struct {
+	Name         string  `json:"name"`
+	Bytes        uint64  `json:"bytes"`
+	Overhead     float64 `json:"overhead_ratio"`
+	OtherTraffic bool    `json:"other_traffic,omitempty"`
+}
+
+// Scatter shows queue build-up: latency that climbs with throughput, a
+// positive Correlation (Pearson's r), is bufferbloat.
+type Scatter struct {
+	Points      []ScatterPoint `json:"points"`
+	Correlation float64        `json:"correlation"`
+}
+
+// Timeline is a round's throughput every IntervalSec and its loaded latency
+// samples over time, kept for the HTML report. It is not part of the JSON
+// output.
+type Timeline struct {
+	IntervalSec float64
+	Mbps        []float64
+	Latency     []TimedRTT
+}
+
+// TimedRTT is a latency sample AtSec seconds into its round.
+type TimedRTT struct {
+	AtSec float64
+	RTTMs float64
+}
+
+// ScatterPoint is one latency sample and the throughput when it was taken.
+type ScatterPoint struct {
+	Mbps  float64 `json:"mbps"`
+	RTTMs float64 `json:"rtt_ms"`
+}
+
+// Ramp is the rate-limiter signature of an upload round. Verdict is
+// "policer" (sawtooth, little queueing), "shaper" (flat, rising latency),
+// "none" or "unclear".
+type Ramp struct {
+	Verdict       string  `json:"verdict"`
+	SteadyMbps    float64 `json:"steady_mbps"`
+	RampMs        float64 `json:"ramp_ms"`
+	CV            float64 `json:"cv"`
+	Drops         int     `json:"drops"`
+	LatencyRiseMs float64 `json:"latency_rise_ms"`
+}
+
+// TCPFlow is the kernel's view of one connection at the end of a round.
+// Fields the platform does not report are zero.
+type TCPFlow struct {
+	Remote       string  `json:"remote"`
+	BytesRead    int64   `json:"bytes_read"`
+	BytesWritten int64   `json:"bytes_written"`
+	RTTMs        float64 `json:"rtt_ms"`
+	RTTVarMs     float64 `json:"rttvar_ms"`
+	MinRTTMs     float64 `json:"min_rtt_ms,omitempty"`
+	Retransmits  uint64  `json:"retransmits"`
+	CwndBytes    uint64  `json:"cwnd_bytes"`
+	DeliveryMbps float64 `json:"delivery_mbps,omitempty"`
+}
+
+const (
+	DirDownload = "download"
+	DirUpload   = "upload"
+)
+
+// Values of Report.LatencyDiscrepancy.
+const (
+	ICMPSlower = "icmp_slower"
+	HTTPSlower = "http_slower"
+)
+
+// New returns an empty report stamped with the current version and time.
+func New() *Report {
+	return &Report{
+		Version: Version,
+		Time:    time.Now().UTC(),
+		Units:   Units{Throughput: "Mbps", Latency: "ms", Data: "bytes", Display: "Mbps"},
+	}
+}
+
+// Best returns the highest throughput recorded for the given direction, or 0
+// when no round in that direction ran.
+func (r *Report) Best(direction string) float64 {
+	var best float64
+	for _, rd := range r.Rounds {
+		if rd.Direction == direction && rd.Mbps > best {
+			best = rd.Mbps
+		}
+	}
+	return best
+}
+
+// Anonymized returns a copy suitable for publishing: the client IP and the
+// local network's addresses are dropped and credentials or query strings are
+// stripped from URLs.
+func (r *Report) Anonymized() *Report {
+	c := *r
+	c.Rounds = append([]Round(nil), r.Rounds...)
+	c.Client.IP = ""
+	if r.System != nil {
+		// The network name and local resolvers identify the site.
+		sys := *r.System
+		sys.Gateway, sys.DNS = "", nil
+		if sys.WiFi != nil {
+			w := *sys.WiFi
+			w.SSID = ""
+			sys.WiFi = &w
+		}
+		c.System = &sys
+	}
+	c.Config.DLURL = scrubURL(c.Config.DLURL)
+	c.Config.ULURL = scrubURL(c.Config.ULURL)
+	c.Config.LatencyURL = scrubURL(c.Config.LatencyURL)
+	if r.Failovers != nil {
+		c.Failovers = make([]Failover, len(r.Failovers))
+		for i, f := range r.Failovers {
+			f.From, f.To = scrubURL(f.From), scrubURL(f.To)
+			c.Failovers[i] = f
+		}
+	}
+	if r.LatencyTargets != nil {
+		// The gateway target is the System.Gateway address by another name.
+		c.LatencyTargets = append([]TargetLatency(nil), r.LatencyTargets...)
+		for i, lt := range c.LatencyTargets {
+			if lt.Target == "gateway" {
+				c.LatencyTargets[i].IP = ""
+			}
+		}
+	}
+	if r.ProxyRoutes != nil {
+		// Keep the kind of each PAC entry, not the local proxy's address.
+		c.ProxyRoutes = make([]ProxyRoute, len(r.ProxyRoutes))
+		for i, pr := range r.ProxyRoutes {
+			if kind, _, ok := strings.Cut(pr.Proxy, " "); ok {
+				pr.Proxy = kind
+			}
+			c.ProxyRoutes[i] = pr
+		}
+	}
+	return &c
+}
+
+// QuietLine is the stable --quiet output: best download and upload Mbps and
+// idle median latency in ms, one decimal each. New keys may be appended but
+// existing ones never change meaning or order.
+func (r *Report) QuietLine() string {
+	return fmt.Sprintf("down=%.1f up=%.1f latency=%.1f",
+		r.Best(DirDownload), r.Best(DirUpload), r.IdleLatency.MedianMs)
+}
+
+// WriteJSON writes the report as indented JSON followed by a newline.
+// MarshalJSON leaves out IdleLatency and Rounds when Config.Phases leaves
+// out their phases; a phase that ran and failed still writes its key.
+// An empty struct named like a field hides that field and, being zero, is
+// itself omitted; the others keep their order.
+func (r Report) MarshalJSON() ([]byte, error) {
+	type plain Report
+	type hidden struct{}
+	lat, xfer := r.runsPhase("latency"), r.runsPhase("download") || r.runsPhase("upload")
+	switch {
+	case lat && xfer:
+		return json.Marshal(plain(r))
+	case xfer:
+		return json.Marshal(struct {
+			plain
+			IdleLatency hidden `json:"idle_latency,omitzero"`
+		}{plain: plain(r)})
+	case lat:
+		return json.Marshal(struct {
+			plain
+			Rounds hidden `json:"rounds,omitzero"`
+		}{plain: plain(r)})
+	default:
+		return json.Marshal(struct {
+			plain
+			IdleLatency hidden `json:"idle_latency,omitzero"`
+			Rounds      hidden `json:"rounds,omitzero"`
+		}{plain: plain(r)})
+	}
+}
+
+// runsPhase reports whether the run included phase, as config.RunsPhase.
+func (r *Report) runsPhase(phase string) bool {
+	return r.Config.Phases == nil || slices.Contains(r.Config.Phases, phase)
+}
+
+func (r *Report) WriteJSON(w io.Writer) error {
+	enc := json.NewEncoder(w)
+	enc.SetIndent("", "  ")
+	return enc.Encode(r)
+}
+
+func scrubURL(raw string) string {
+	u, err := url.Parse(raw)
+	if err != nil {
+		return ""
+	}
+	u.User = nil
+	u.RawQuery = ""
+	u.Fragment = ""
+	return u.String()
+}
@@ -0,0 +1,108 @@
+package report
+
+import (
+	"math"
+	"sort"
+)
+
+// MetricStats is the spread of one metric over the runs of --runs. StdDev is
+// the sample standard deviation and CV the coefficient of variation
+// (StdDev/Mean), so links of different speeds compare on the same scale.
+type MetricStats struct {
+	Metric string  `json:"metric"` // download, upload, latency or jitter
+	Unit   string  `json:"unit"`   // Mbps or ms
+	N      int     `json:"n"`
+	Mean   float64 `json:"mean"`
+	Median float64 `json:"median"`
+	Min    float64 `json:"min"`
+	Max    float64 `json:"max"`
+	StdDev float64 `json:"stddev"`
+	CV     float64 `json:"cv"`
+}
+
+// IPDelta compares a headline metric measured over IPv4 with the same metric
+// measured over IPv6 (--compare-ip-versions). Delta is the IPv6 value minus
+// the IPv4 one and DeltaPct that difference relative to IPv4.
+type IPDelta struct {
+	Metric   string  `json:"metric"` // download, upload, latency or jitter
+	Unit     string  `json:"unit"`   // Mbps or ms
+	IPv4     float64 `json:"ipv4"`
+	IPv6     float64 `json:"ipv6"`
+	Delta    float64 `json:"delta"`
+	DeltaPct float64 `json:"delta_pct"`
+}
+
+// headline are the metrics RunStats and CompareIPVersions summarize: best
+// download and upload, and idle latency median and jitter. Zero means not
+// measured.
+var headline = []struct {
+	name, unit string
+	value      func(*Report) float64
+}{
+	{DirDownload, "Mbps", func(r *Report) float64 { return r.Best(DirDownload) }},
+	{DirUpload, "Mbps", func(r *Report) float64 { return r.Best(DirUpload) }},
+	{"latency", "ms", func(r *Report) float64 { return r.IdleLatency.MedianMs }},
+	{"jitter", "ms", func(r *Report) float64 { return r.IdleLatency.JitterMs }},
+}
+
+// CompareIPVersions sets the headline metrics of v4 against those of v6.
+// Metrics either run did not measure are omitted.
+func CompareIPVersions(v4, v6 *Report) []IPDelta {
+	var out []IPDelta
+	for _, m := range headline {
+		a, b := m.value(v4), m.value(v6)
+		if a <= 0 || b <= 0 {
+			continue
+		}
+		out = append(out, IPDelta{Metric: m.name, Unit: m.unit, IPv4: a, IPv6: b, Delta: b - a, DeltaPct: (b - a) / a * 100})
+	}
+	return out
+}
+
+// RunStats summarizes the headline metrics of reps. Runs that did not
+// measure a metric are left out of it; metrics no run measured are omitted.
+func RunStats(reps []*Report) []MetricStats {
+	var out []MetricStats
+	for _, m := range headline {
+		var vs []float64
+		for _, r := range reps {
+			if v := m.value(r); v > 0 {
+				vs = append(vs, v)
+			}
+		}
+		if len(vs) == 0 {
+			continue
+		}
+		s := spread(vs)
+		s.Metric, s.Unit = m.name, m.unit
+		out = append(out, s)
+	}
+	return out
+}
+
+func spread(vs []float64) MetricStats {
+	sorted := append([]float64(nil), vs...)
+	sort.Float64s(sorted)
+	n := len(sorted)
+	s := MetricStats{N: n, Min: sorted[0], Max: sorted[n-1]}
+	for _, v := range sorted {
+		s.Mean += v
+	}
+	s.Mean /= float64(n)
+	if n%2 == 1 {
+		s.Median = sorted[n/2]
+	} else {
+		s.Median = (sorted[n/2-1] + sorted[n/2]) / 2
+	}
+	if n > 1 {
+		var ss float64
+		for _, v := range sorted {
+			ss += (v - s.Mean) * (v - s.Mean)
+		}
+		s.StdDev = math.Sqrt(ss / float64(n-1))
+	}
+	if s.Mean > 0 {
+		s.CV = s.StdDev / s.Mean
+	}
+	return s
+}
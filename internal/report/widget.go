@@ -0,0 +1,68 @@
+package report
+
+import (
+	"fmt"
+	"math"
+	"strings"
+)
+
+// WidgetPoints is how many past runs the --widget sparklines show.
+const WidgetPoints = 8
+
+var sparks = []rune("▁▂▃▄▅▆▇█")
+
+// WidgetLine is the single --widget status line built from recent reports,
+// oldest first: the latest download, upload and idle latency, each followed
+// by a sparkline of its history. Metrics a report did not measure are left
+// out of the sparkline.
+func WidgetLine(reps []*Report) string {
+	if len(reps) == 0 {
+		return "↓ - ↑ - ⏱ -"
+	}
+	var down, up, lat []float64
+	for _, r := range reps {
+		if v := r.Best(DirDownload); v > 0 {
+			down = append(down, v)
+		}
+		if v := r.Best(DirUpload); v > 0 {
+			up = append(up, v)
+		}
+		if v := r.IdleLatency.MedianMs; v > 0 {
+			lat = append(lat, v)
+		}
+	}
+	return strings.Join([]string{
+		widgetMetric("↓", down, "%.0f"),
+		widgetMetric("↑", up, "%.0f"),
+		widgetMetric("⏱", lat, "%.0fms"),
+	}, " ")
+}
+
+func widgetMetric(icon string, vals []float64, format string) string {
+	if len(vals) == 0 {
+		return icon + " -"
+	}
+	s := icon + " " + fmt.Sprintf(format, vals[len(vals)-1])
+	if len(vals) > 1 {
+		s += " " + Sparkline(vals)
+	}
+	return s
+}
+
+// Sparkline draws vals as block characters scaled between their minimum
+// and maximum. A flat series is drawn at mid height.
+func Sparkline(vals []float64) string {
+	lo, hi := math.Inf(1), math.Inf(-1)
+	for _, v := range vals {
+		lo, hi = math.Min(lo, v), math.Max(hi, v)
+	}
+	var b strings.Builder
+	for _, v := range vals {
+		i := len(sparks) / 2
+		if hi > lo {
+			i = int(math.Round((v - lo) / (hi - lo) * float64(len(sparks)-1)))
+		}
+		b.WriteRune(sparks[i])
+	}
+	return b.String()
+}
@@ -0,0 +1,119 @@
+package runner
+
+import (
+	"crypto/subtle"
+	"encoding/json"
+	"fmt"
+	"mime"
+	"net"
+	"net/http"
+	"slices"
+	"time"
+
+	"github.com/tsosunchia/iNetSpeed-CLI/internal/history"
+	"github.com/tsosunchia/iNetSpeed-CLI/internal/i18n"
+	"github.com/tsosunchia/iNetSpeed-CLI/internal/report"
+)
+
+// serveAPI starts the HTTP API of the series on addr, which lets dashboards
+// steer a --runs series run as a service without shelling out: POST /run
+// starts the next run at once, as SIGUSR1 does, and GET /results/latest and
+// GET /results?since=TIME return finished reports as JSON. With API_TOKEN
+// set every request must carry it as a bearer token; without it POST /run
+// must be JSON sent by a script, not a browser. The API stops with the
+// control.
+func (c *control) serveAPI(addr string) error {
+	ln, err := net.Listen("tcp", addr)
+	if err != nil {
+		return err
+	}
+	c.history = c.r.cfg.History
+	c.api = &http.Server{Handler: c.apiHandler(), ReadHeaderTimeout: 10 * time.Second}
+	go c.api.Serve(ln)
+	c.r.bus.Info(fmt.Sprintf(i18n.Text("API listening on http://%s", "API 正在监听 http://%s"), ln.Addr()))
+	return nil
+}
+
+func (c *control) apiHandler() http.Handler {
+	mux := http.NewServeMux()
+	mux.HandleFunc("POST /run", func(w http.ResponseWriter, req *http.Request) {
+		if c.r.cfg.APIToken == "" && !fromScript(req) {
+			writeJSON(w, http.StatusForbidden, map[string]string{"error": "POST /run takes Content-Type: application/json and no Origin"})
+			return
+		}
+		c.r.bus.Info(i18n.Text("API: starting the next run now.", "API: 立即开始下一次测速。"))
+		select {
+		case c.runNow <- struct{}{}:
+		default:
+		}
+		writeJSON(w, http.StatusAccepted, map[string]string{"status": "accepted"})
+	})
+	mux.HandleFunc("GET /results/latest", func(w http.ResponseWriter, req *http.Request) {
+		reps, err := c.results(time.Time{})
+		switch {
+		case err != nil:
+			writeJSON(w, http.StatusInternalServerError, map[string]string{"error": err.Error()})
+		case len(reps) == 0:
+			writeJSON(w, http.StatusNotFound, map[string]string{"error": "no run has finished yet"})
+		default:
+			writeJSON(w, http.StatusOK, reps[len(reps)-1])
+		}
+	})
+	mux.HandleFunc("GET /results", func(w http.ResponseWriter, req *http.Request) {
+		var since time.Time
+		if v := req.URL.Query().Get("since"); v != "" {
+			var err error
+			if since, err = time.Parse(time.RFC3339, v); err != nil {
+				writeJSON(w, http.StatusBadRequest, map[string]string{"error": "since must be an RFC 3339 time"})
+				return
+			}
+		}
+		reps, err := c.results(since)
+		if err != nil {
+			writeJSON(w, http.StatusInternalServerError, map[string]string{"error": err.Error()})
+			return
+		}
+		writeJSON(w, http.StatusOK, append([]*report.Report{}, reps...))
+	})
+	token := c.r.cfg.APIToken
+	if token == "" {
+		return mux
+	}
+	want := []byte("Bearer " + token)
+	return http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+		if subtle.ConstantTimeCompare([]byte(req.Header.Get("Authorization")), want) != 1 {
+			w.Header().Set("WWW-Authenticate", `Bearer realm="speedtest"`)
+			writeJSON(w, http.StatusUnauthorized, map[string]string{"error": "missing or wrong bearer token"})
+			return
+		}
+		mux.ServeHTTP(w, req)
+	})
+}
+
+// fromScript reports whether req, JSON without an Origin, cannot have come
+// from a web page. A page on any site can post a form to a loopback API,
+// but not application/json without a CORS preflight the API never answers,
+// and browsers mark what pages send with Origin. Without a token this is
+// what keeps a page open in a browser on the host from starting runs.
+func fromScript(req *http.Request) bool {
+	mt, _, err := mime.ParseMediaType(req.Header.Get("Content-Type"))
+	return err == nil && mt == "application/json" && req.Header.Get("Origin") == ""
+}
+
+// results returns the reports taken at or after since, oldest first: from
+// the history file when the series records one, which also holds the runs
+// before this series, and else the published runs of the series.
+func (c *control) results(since time.Time) ([]*report.Report, error) {
+	if c.history != "" {
+		return history.Since(c.history, since)
+	}
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return slices.DeleteFunc(slices.Clone(c.published), func(r *report.Report) bool { return r.Time.Before(since) }), nil
+}
+
+func writeJSON(w http.ResponseWriter, code int, v any) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(code)
+	json.NewEncoder(w).Encode(v)
+}
@@ -0,0 +1,78 @@
+package runner
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/tsosunchia/iNetSpeed-CLI/internal/config"
+	"github.com/tsosunchia/iNetSpeed-CLI/internal/history"
+	"github.com/tsosunchia/iNetSpeed-CLI/internal/i18n"
+	"github.com/tsosunchia/iNetSpeed-CLI/internal/report"
+	"github.com/tsosunchia/iNetSpeed-CLI/internal/units"
+)
+
+// Exit codes of failed assertions: exitAssert plus the bit of every metric
+// that failed, so 9-15 tell scripts exactly which limits were missed.
+const (
+	exitAssert         = 8
+	assertDownloadFail = 1
+	assertUploadFail   = 2
+	assertLatencyFail  = 4
+)
+
+// assertions checks the run against the --assert-* limits. A metric that
+// was not measured fails its check.
+func assertions(cfg *config.Config, rep *report.Report) ([]report.Assertion, int) {
+	var out []report.Assertion
+	failed := 0
+	add := func(metric string, limit, value float64, bit int, higherIsBetter bool) {
+		if limit <= 0 {
+			return
+		}
+		a := report.Assertion{Metric: metric, Limit: limit, Value: value}
+		if higherIsBetter {
+			a.Passed = value >= limit
+		} else {
+			a.Passed = value > 0 && value <= limit
+		}
+		if !a.Passed {
+			failed |= bit
+		}
+		out = append(out, a)
+	}
+	add(history.MetricDownload, cfg.AssertDownloadMin, rep.Best(report.DirDownload), assertDownloadFail, true)
+	add(history.MetricUpload, cfg.AssertUploadMin, rep.Best(report.DirUpload), assertUploadFail, true)
+	add(history.MetricLatency, cfg.AssertLatencyMax, rep.IdleLatency.MedianMs, assertLatencyFail, false)
+	return out, failed
+}
+
+func (r *run) assert(ctx context.Context) error {
+	bus := r.bus
+	bus.Header(i18n.Text("Assertions", "断言"))
+	r.mu.Lock()
+	checks, failed := assertions(r.cfg, r.rep)
+	r.rep.Assertions = checks
+	r.assertFailed = failed
+	r.mu.Unlock()
+	for _, a := range checks {
+		label := i18n.Text("Download", "下载")
+		line := fmt.Sprintf(i18n.Text("%s (min %s)", "%s（下限 %s）"), units.Rate(a.Value, 1), units.Rate(a.Limit, 0))
+		switch a.Metric {
+		case history.MetricUpload:
+			label = i18n.Text("Upload", "上传")
+		case history.MetricLatency:
+			label = i18n.Text("Idle Latency", "空载延迟")
+			line = fmt.Sprintf(i18n.Text("%.1f ms (max %g)", "%.1f 毫秒（上限 %g）"), a.Value, a.Limit)
+		}
+		switch {
+		case a.Value <= 0:
+			bus.Warn(fmt.Sprintf(i18n.Text("%s: not measured, assertion failed.", "%s：未测量，断言失败。"), label))
+		case a.Passed:
+			bus.Info(label + ": " + line + i18n.Text("  passed", "  通过"))
+		default:
+			bus.Warn(label + ": " + line + i18n.Text("  FAILED", "  未通过"))
+		}
+	}
+	bus.Line()
+	return nil
+}
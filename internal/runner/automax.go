@@ -0,0 +1,77 @@
+package runner
+
+import (
+	"context"
+	"fmt"
+	"math"
+	"time"
+
+	"github.com/tsosunchia/iNetSpeed-CLI/internal/config"
+	"github.com/tsosunchia/iNetSpeed-CLI/internal/i18n"
+	"github.com/tsosunchia/iNetSpeed-CLI/internal/transfer"
+	"github.com/tsosunchia/iNetSpeed-CLI/internal/units"
+)
+
+// autoMaxProbe is how long, in seconds, the auto-max stage downloads; tests
+// shorten it.
+var autoMaxProbe = 2
+
+// autoMaxWindow is the measurement window MAX=auto sizes the cap for, and
+// autoMaxFloor the smallest cap it picks.
+const (
+	autoMaxWindow = 12 * time.Second
+	autoMaxFloor  = 1_000_000
+)
+
+// autoMax runs for MAX=auto. A short multi-thread download estimates the link
+// speed, and the per-thread cap becomes what the whole link moves in
+// autoMaxWindow: a single connection filling the link then runs for about
+// the window, and threads sharing the link end at TIMEOUT before their cap,
+// so no round is cut short by a cap sized for another link.
+func (r *run) autoMax(ctx context.Context) error {
+	bus := r.bus
+	bus.Header(i18n.Text("Transfer Cap", "传输上限"))
+	if r.gate.Budget.Exhausted() {
+		bus.Warn(fmt.Sprintf(i18n.Text("Total data cap %s reached, probe skipped.", "已达总流量上限 %s，跳过预测速。"), r.capName()))
+		return nil
+	}
+	r.refreshURLs(ctx, time.Duration(autoMaxProbe+2)*time.Second)
+	cfg := r.cfg.ForStage(config.StageDownloadMulti)
+	cfg.Timeout = autoMaxProbe
+	bus.Info(fmt.Sprintf(i18n.Text("Probing link speed for %ds with %d threads.", "预测速 %d 秒（%d 线程）。"), cfg.Timeout, cfg.Threads))
+	res := transfer.RunLimited(ctx, r.client, cfg, transfer.Download, cfg.Threads, cfg.DLURL, bus, r.gate, nil)
+
+	r.mu.Lock()
+	r.totalData += res.TotalBytes
+	r.rep.DataUsedBytes = r.totalData
+	r.mu.Unlock()
+	if ctx.Err() != nil {
+		return nil
+	}
+	size, n := autoMaxSize(res.Mbps)
+	if n == 0 {
+		bus.Warn(fmt.Sprintf(i18n.Text("The probe moved no data; keeping MAX=%s.", "预测速未取得数据，沿用 MAX=%s。"), config.DefaultMax))
+		return nil
+	}
+	// The chosen cap replaces auto for the rest of this run and any later
+	// run of --runs, which then compare like with like.
+	c := *r.cfg
+	c.Max, c.MaxBytes = size, n
+	r.cfg = &c
+	r.rep.Config.Max = size
+	r.rep.Config.AutoMbps = math.Round(res.Mbps*10) / 10
+	bus.Result(fmt.Sprintf(i18n.Text("%s: MAX=%s per thread (~%.0fs at full link speed)", "%s：每线程上限 %s（满速约 %.0f 秒）"),
+		units.Rate(res.Mbps, 0), size, autoMaxWindow.Seconds()))
+	return nil
+}
+
+// autoMaxSize returns the cap for a link of mbps, rounded up to whole
+// megabytes, as a MAX value and in bytes. It returns zero bytes when mbps is.
+func autoMaxSize(mbps float64) (string, int64) {
+	if mbps <= 0 {
+		return "", 0
+	}
+	n := int64(math.Max(mbps*1e6/8*autoMaxWindow.Seconds(), autoMaxFloor))
+	mb := (n + 999_999) / 1_000_000
+	return fmt.Sprintf("%dM", mb), mb * 1_000_000
+}
@@ -0,0 +1,134 @@
+package runner
+
+import (
+	"context"
+	"fmt"
+	"math"
+	"sync"
+	"time"
+
+	"github.com/tsosunchia/iNetSpeed-CLI/internal/config"
+	"github.com/tsosunchia/iNetSpeed-CLI/internal/i18n"
+	"github.com/tsosunchia/iNetSpeed-CLI/internal/latency"
+	"github.com/tsosunchia/iNetSpeed-CLI/internal/report"
+	"github.com/tsosunchia/iNetSpeed-CLI/internal/transfer"
+	"github.com/tsosunchia/iNetSpeed-CLI/internal/units"
+)
+
+// bidiRetainMin is the share of its solo throughput a direction must keep
+// under bidirectional load, and bidiGap how much more of it the other
+// direction must keep for the loss to count as asymmetric congestion.
+const (
+	bidiRetainMin = 0.7
+	bidiGap       = 0.2
+)
+
+// bidirectional runs download and upload workers at once, half the threads
+// each, after the solo rounds. A full-duplex link carries both near their
+// solo speeds; a saturated upload that starves the download's ACKs, or a
+// shared medium, shows up as a direction that falls well short.
+func (r *run) bidirectional(ctx context.Context) error {
+	bus := r.bus
+	bus.Header(i18n.Text("Bidirectional", "双向同时测速"))
+	cfg := r.cfg.ForStage(config.StageBidirectional)
+	if r.gate.Budget.Exhausted() {
+		bus.Warn(fmt.Sprintf(i18n.Text("Total data cap %s reached, round skipped.", "已达总流量上限 %s，跳过本轮。"), r.capName()))
+		return nil
+	}
+	r.refreshURLs(ctx, time.Duration(cfg.Timeout+2)*time.Second)
+	cfg = r.budgeted(config.StageBidirectional, 1, r.cfg.ForStage(config.StageBidirectional))
+	// One direction stopping at saturation would leave the other running
+	// alone, which is no longer a bidirectional test.
+	cfg.SaturationWindow = 0
+	threads := max(cfg.Threads/2, 1)
+	bus.Info(fmt.Sprintf(i18n.Text("Threads: %d download + %d upload", "线程: 下载 %d + 上传 %d"), threads, threads))
+	bus.Info(fmt.Sprintf(i18n.Text("Limit: %s / %ds per thread", "上限: %s / 每线程 %ds"), cfg.Max, cfg.Timeout))
+	r.budgetNote()
+
+	probeClient, opts := r.headlineProbe(r.client)
+	loadedProbe := latency.StartLoadedFunc(ctx, probeClient, cfg.LatencyURL, cfg.RequestHeader(), opts, r.loadedSample("bidi"))
+	var dl, ul transfer.Result
+	var wg sync.WaitGroup
+	wg.Add(2)
+	go func() {
+		defer wg.Done()
+		dl = transfer.RunLimited(ctx, r.client, cfg, transfer.Download, threads, cfg.DLURL, bus, r.gate, loadedSource{loadedProbe, &r.load})
+	}()
+	go func() {
+		defer wg.Done()
+		ul = transfer.RunLimited(ctx, r.client, cfg, transfer.Upload, threads, cfg.ULURL, bus, r.gate, loadedSource{loadedProbe, &r.load})
+	}()
+	wg.Wait()
+	loaded := loadedProbe.Stop()
+
+	r.mu.Lock()
+	r.totalData += dl.TotalBytes + ul.TotalBytes
+	r.rep.DataUsedBytes = r.totalData
+	r.rep.TotalCapReached = r.gate.Budget.Exhausted()
+	b := bidiReport(threads, dl, ul, loaded, r.rep.Best(report.DirDownload), r.rep.Best(report.DirUpload))
+	r.rep.Bidirectional = b
+	r.mu.Unlock()
+
+	bus.Result(fmt.Sprintf(i18n.Text("↓ %s + ↑ %s = %s  (%.1fs)", "↓ %s + ↑ %s = %s  (耗时 %.1fs)"),
+		units.Value(b.DownloadMbps, 0), units.Value(b.UploadMbps, 0), units.Rate(b.CombinedMbps, 0), b.DurationSec))
+	if dl.HadFault() || ul.HadFault() {
+		bus.Warn(i18n.Text("Network issue detected during this round; result may be affected.", "本轮测试中出现网络故障，结果可能受影响。"))
+	}
+	showPause(bus, max(dl.Paused, ul.Paused))
+	showCPU(bus, max(dl.CPUPct, ul.CPUPct))
+	bus.Info(fmt.Sprintf(i18n.Text("Loaded latency: %.2f ms  (jitter %.2f ms)", "负载延迟: %.2f 毫秒  (抖动 %.2f 毫秒)"),
+		loaded.Median, loaded.Jitter))
+	if b.DownloadRetained > 0 && b.UploadRetained > 0 {
+		bus.Info(fmt.Sprintf(i18n.Text("Kept %.0f%% of solo download and %.0f%% of solo upload.", "保持了单向下载的 %.0f%% 与单向上传的 %.0f%%。"),
+			b.DownloadRetained*100, b.UploadRetained*100))
+	}
+	switch b.Congested {
+	case report.DirDownload:
+		bus.Warn(i18n.Text("Download collapses while uploading: the saturated uplink likely delays the download's ACKs (bufferbloat on the upstream queue).",
+			"上传时下载速度大幅下降：上行队列饱和，很可能拖慢了下载的 ACK（上行缓冲膨胀）。"))
+	case report.DirUpload:
+		bus.Warn(i18n.Text("Upload collapses while downloading: the downlink queue likely delays the upload's ACKs.",
+			"下载时上传速度大幅下降：下行队列很可能拖慢了上传的 ACK。"))
+	case bidiBoth:
+		bus.Warn(i18n.Text("Both directions slow down together: the link behaves half-duplex, as a shared medium such as Wi-Fi does.",
+			"两个方向同时变慢：链路表现为半双工，常见于 Wi-Fi 等共享介质。"))
+	}
+	return nil
+}
+
+// bidiBoth is Bidi.Congested when both directions fell short.
+const bidiBoth = "both"
+
+// bidiReport summarizes the bidirectional round against the best solo
+// download and upload; a zero solo leaves that direction's share unset.
+func bidiReport(threads int, dl, ul transfer.Result, loaded latency.Stats, soloDL, soloUL float64) *report.Bidi {
+	b := &report.Bidi{
+		Threads:       threads,
+		DurationSec:   math.Max(dl.Duration.Seconds(), ul.Duration.Seconds()),
+		DownloadMbps:  dl.Mbps,
+		UploadMbps:    ul.Mbps,
+		CombinedMbps:  dl.Mbps + ul.Mbps,
+		LoadedLatency: latencyReport(loaded),
+		PausedSec:     math.Round(max(dl.Paused, ul.Paused).Seconds()*10) / 10,
+		ClientCPUPct:  math.Round(max(dl.CPUPct, ul.CPUPct)*10) / 10,
+	}
+	if soloDL > 0 {
+		b.DownloadRetained = math.Round(dl.Mbps/soloDL*1000) / 1000
+	}
+	if soloUL > 0 {
+		b.UploadRetained = math.Round(ul.Mbps/soloUL*1000) / 1000
+	}
+	if soloDL <= 0 || soloUL <= 0 {
+		return b
+	}
+	d, u := b.DownloadRetained, b.UploadRetained
+	switch {
+	case d < bidiRetainMin && u-d >= bidiGap:
+		b.Congested = report.DirDownload
+	case u < bidiRetainMin && d-u >= bidiGap:
+		b.Congested = report.DirUpload
+	case d < bidiRetainMin && u < bidiRetainMin:
+		b.Congested = bidiBoth
+	}
+	return b
+}
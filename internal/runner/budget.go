@@ -0,0 +1,105 @@
+package runner
+
+import (
+	"fmt"
+
+	"github.com/tsosunchia/iNetSpeed-CLI/internal/config"
+	"github.com/tsosunchia/iNetSpeed-CLI/internal/i18n"
+	"github.com/tsosunchia/iNetSpeed-CLI/internal/netx"
+)
+
+// budgetStages are the rounds --data-budget shares the budget among, in
+// run order. The auto-max probe and the verification upload size
+// themselves and stay within the hard cap alone.
+var budgetStages = []string{config.StageDownloadSingle, config.StageDownloadMulti,
+	config.StageUploadSingle, config.StageUploadMulti, config.StageBidirectional}
+
+// budgetHeadroom is the share of what is left of the budget held back for
+// the latency probes and the HTTP and TLS framing, which the per-thread
+// caps do not count; budgetFloor is the smallest cap a round gets.
+const (
+	budgetHeadroom = 0.1
+	budgetFloor    = 1_000_000
+)
+
+// capName is the cap on the run's data as written, for messages.
+func (r *run) capName() string {
+	name, _ := r.cfg.TotalCap()
+	return name
+}
+
+// stageModes is the connection modes a transfer stage runs its rounds
+// under, in order.
+func (r *run) stageModes(stage string) []string {
+	if r.cfg.ForStage(stage).Threads > 1 {
+		return connModes(r.cfg.ConnectionMode)
+	}
+	return []string{netx.ModeAuto}
+}
+
+// slots is the number of threads stage runs over its last modes
+// connection modes.
+func (r *run) slots(stage string, modes int) int64 {
+	threads := r.cfg.ForStage(stage).Threads
+	if stage == config.StageBidirectional {
+		return int64(2 * max(threads/2, 1))
+	}
+	return int64(threads * modes)
+}
+
+// planned reports whether stage is to run.
+func (r *run) planned(stage string) bool {
+	return r.cfg.StageEnabled(stage) && (stage != config.StageBidirectional || r.cfg.Bidi)
+}
+
+// budgeted returns cfg, the limits of stage's round with modes connection
+// modes still to go, with the per-thread cap lowered when --data-budget
+// calls for it. What is left of the budget, less budgetHeadroom, is shared
+// equally among the threads of this round, the planned rounds after it and
+// those of the later runs of --runs; a round that moves less than its
+// share, as a single connection usually does, leaves more to the rest.
+func (r *run) budgeted(stage string, modes int, cfg *config.Config) *config.Config {
+	if r.cfg.DataBudgetBytes <= 0 {
+		return cfg
+	}
+	var rest, all int64
+	later := false
+	for _, s := range budgetStages {
+		if !r.planned(s) {
+			continue
+		}
+		n := r.slots(s, len(r.stageModes(s)))
+		all += n
+		switch {
+		case s == stage:
+			rest += r.slots(s, modes)
+			later = true
+		case later:
+			rest += n
+		}
+	}
+	if r.rep.Runs > 1 {
+		rest += all * int64(r.rep.Runs-r.rep.Run)
+	}
+	if rest == 0 {
+		return cfg
+	}
+	left := float64(r.gate.Budget.Remaining()) * (1 - budgetHeadroom)
+	share := int64(left) / rest / budgetFloor * budgetFloor
+	share = max(share, budgetFloor)
+	if share >= cfg.MaxBytes {
+		return cfg
+	}
+	c := *cfg
+	c.Max, c.MaxBytes = fmt.Sprintf("%dM", share/1_000_000), share
+	return &c
+}
+
+// budgetNote shows what is left of --data-budget as a round starts.
+func (r *run) budgetNote() {
+	if r.cfg.DataBudgetBytes <= 0 {
+		return
+	}
+	r.bus.Info(fmt.Sprintf(i18n.Text("Data budget: %s of %s left", "流量预算: 剩余 %s（共 %s）"),
+		config.HumanBytes(r.gate.Budget.Remaining()), r.cfg.DataBudget))
+}
@@ -0,0 +1,219 @@
+package runner
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"math"
+	"net/http"
+	"net/http/httptrace"
+	"net/url"
+	"slices"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/tsosunchia/iNetSpeed-CLI/internal/cdn"
+	"github.com/tsosunchia/iNetSpeed-CLI/internal/config"
+	"github.com/tsosunchia/iNetSpeed-CLI/internal/i18n"
+	"github.com/tsosunchia/iNetSpeed-CLI/internal/report"
+)
+
+// cacheProbeSize is how much of the download object each cache-check fetch
+// reads; cacheSpeedup is how much sooner than the cache-busted fetches a
+// plain one must answer to be taken for a cache near the client.
+const (
+	cacheProbeSize = 256 << 10
+	cacheSpeedup   = 2
+)
+
+// Cache-check verdicts, as in report.CacheCheck.
+const (
+	cacheISP     = "isp-cache"
+	cacheProxy   = "proxy"
+	cacheUnknown = "unknown-cache"
+	cacheCDN     = "cdn"
+)
+
+// cacheCheck fetches the start of the download object twice as it is and
+// twice with a query string no cache can have seen, and compares the cache
+// headers and the time to the first byte. An ISP's transparent cache names
+// itself in Via or X-Cache, or answers plain requests from its store far
+// sooner than the CDN answers the busted ones; either way the download
+// rounds would measure the path to that cache rather than to the CDN.
+func (r *run) cacheCheck(ctx context.Context) error {
+	bus := r.bus
+	bus.Header(i18n.Text("Transparent Cache Check", "透明缓存检测"))
+	r.refreshURLs(ctx, 4*time.Duration(r.cfg.Timeout)*time.Second)
+	var fetches []report.CacheFetch
+	var proxies []string
+	for i, busted := range []bool{false, false, true, true} {
+		if ctx.Err() != nil {
+			return nil
+		}
+		target := r.cfg.DLURL
+		if busted {
+			target = cacheBust(target, i)
+		}
+		f, hops, err := r.cacheFetch(ctx, target)
+		if err != nil {
+			bus.Warn(fmt.Sprintf(i18n.Text("Cache check failed: %v", "缓存检测失败: %v"), err))
+			return nil
+		}
+		f.Busted = busted
+		fetches = append(fetches, f)
+		for _, h := range hops {
+			if !slices.Contains(proxies, h) {
+				proxies = append(proxies, h)
+			}
+		}
+		bus.Debug(cacheFetchLine(f))
+	}
+	c := cacheVerdict(fetches, proxies)
+	r.mu.Lock()
+	r.rep.CacheCheck = c
+	r.mu.Unlock()
+
+	switch c.Verdict {
+	case cacheISP:
+		bus.Warn(fmt.Sprintf(i18n.Text("A cache on the path (%s) answered instead of the CDN: the download results measure the way to that cache, not to the CDN.",
+			"路径上的缓存（%s）代替 CDN 作答：下载结果反映的是到该缓存的路径，而非到 CDN 的路径。"), strings.Join(c.Proxies, ", ")))
+	case cacheUnknown:
+		bus.Warn(fmt.Sprintf(i18n.Text("Plain requests were answered from a cache without any CDN marker in %.1f ms, cache-busted ones in %.1f ms: a transparent cache on the path likely serves the downloads.",
+			"普通请求由不带任何 CDN 标记的缓存应答，首字节 %.1f 毫秒，防缓存请求为 %.1f 毫秒：路径上很可能有透明缓存在提供下载。"), c.PlainTTFBMs, c.BustedTTFBMs))
+	case cacheProxy:
+		bus.Info(fmt.Sprintf(i18n.Text("Requests pass through a proxy (%s), which did not answer from a cache.", "请求经过代理（%s），但其未从缓存应答。"),
+			strings.Join(c.Proxies, ", ")))
+	default:
+		bus.Result(fmt.Sprintf(i18n.Text("No transparent cache: first byte in %.1f ms, %.1f ms with cache busting", "未发现透明缓存：首字节 %.1f 毫秒，防缓存时 %.1f 毫秒"),
+			c.PlainTTFBMs, c.BustedTTFBMs))
+	}
+	if c.QueryIgnored {
+		bus.Info(i18n.Text("Cache-busted requests were answered from a cache too: the cache ignores query strings.", "防缓存请求同样由缓存应答：该缓存忽略查询参数。"))
+	}
+	return nil
+}
+
+// cacheBust is rawURL with a query parameter no earlier request carried.
+func cacheBust(rawURL string, i int) string {
+	u, err := url.Parse(rawURL)
+	if err != nil {
+		return rawURL
+	}
+	q := u.Query()
+	q.Set("cb", strconv.FormatInt(time.Now().UnixNano(), 36)+strconv.Itoa(i))
+	u.RawQuery = q.Encode()
+	return u.String()
+}
+
+// cacheFetch reads the first cacheProbeSize bytes of target and returns
+// what its headers and timing say, with the proxies they name.
+func (r *run) cacheFetch(ctx context.Context, target string) (report.CacheFetch, []string, error) {
+	var f report.CacheFetch
+	ctx, cancel := context.WithTimeout(ctx, time.Duration(r.cfg.Timeout)*time.Second)
+	defer cancel()
+	var first time.Time
+	ctx = httptrace.WithClientTrace(ctx, &httptrace.ClientTrace{
+		GotFirstResponseByte: func() { first = time.Now() },
+	})
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, target, nil)
+	if err != nil {
+		return f, nil, err
+	}
+	config.SetHeader(req, r.cfg.RequestHeader())
+	req.Header.Set("Range", fmt.Sprintf("bytes=0-%d", cacheProbeSize-1))
+	start := time.Now()
+	resp, err := r.client.Do(req)
+	if err != nil {
+		return f, nil, err
+	}
+	defer resp.Body.Close()
+	f.Bytes, err = io.Copy(io.Discard, io.LimitReader(resp.Body, cacheProbeSize))
+	r.mu.Lock()
+	r.totalData += f.Bytes
+	r.rep.DataUsedBytes = r.totalData
+	r.mu.Unlock()
+	if err != nil {
+		return f, nil, err
+	}
+	if first.IsZero() {
+		first = time.Now()
+	}
+	f.Status = resp.StatusCode
+	f.TTFBMs = math.Round(float64(first.Sub(start).Microseconds())/10) / 100
+	if age, err := strconv.Atoi(strings.TrimSpace(resp.Header.Get("Age"))); err == nil && age >= 0 {
+		f.AgeSec = &age
+	}
+	n := cdn.Identify(resp.Header)
+	f.Cache, f.Provider = n.Cache, n.Provider
+	return f, cdn.Proxies(resp.Header), nil
+}
+
+// cached reports whether f was answered from a cache's store.
+func cached(f report.CacheFetch) bool {
+	return f.AgeSec != nil && *f.AgeSec > 0 || cdn.Hit(f.Cache)
+}
+
+// cacheVerdict weighs the fetches of the cache check: plain ones first,
+// then cache-busted ones.
+func cacheVerdict(fetches []report.CacheFetch, proxies []string) *report.CacheCheck {
+	c := &report.CacheCheck{Proxies: proxies, Fetches: fetches, Verdict: cacheCDN}
+	var plain, busted []float64
+	anyHit, bareHit := false, false
+	for i, f := range fetches {
+		hit := cached(f)
+		anyHit = anyHit || hit
+		if f.Busted {
+			busted = append(busted, f.TTFBMs)
+			c.QueryIgnored = c.QueryIgnored || hit
+			continue
+		}
+		// The first plain fetch may have filled the cache.
+		if i > 0 {
+			plain = append(plain, f.TTFBMs)
+			bareHit = bareHit || hit && f.Provider == ""
+		}
+	}
+	c.PlainTTFBMs = meanMs(plain)
+	c.BustedTTFBMs = meanMs(busted)
+	switch {
+	case len(proxies) > 0 && anyHit:
+		c.Verdict = cacheISP
+	case len(proxies) > 0:
+		c.Verdict = cacheProxy
+	case bareHit && c.PlainTTFBMs*cacheSpeedup <= c.BustedTTFBMs:
+		c.Verdict = cacheUnknown
+	}
+	return c
+}
+
+// meanMs is the mean of vs rounded to hundredths.
+func meanMs(vs []float64) float64 {
+	if len(vs) == 0 {
+		return 0
+	}
+	var sum float64
+	for _, v := range vs {
+		sum += v
+	}
+	return math.Round(sum/float64(len(vs))*100) / 100
+}
+
+// cacheFetchLine describes one fetch for --verbose.
+func cacheFetchLine(f report.CacheFetch) string {
+	kind := i18n.Text("plain", "普通")
+	if f.Busted {
+		kind = i18n.Text("cache-busted", "防缓存")
+	}
+	line := fmt.Sprintf(i18n.Text("%s: HTTP %d, first byte %.1f ms", "%s: HTTP %d，首字节 %.1f 毫秒"), kind, f.Status, f.TTFBMs)
+	if f.AgeSec != nil {
+		line += fmt.Sprintf(", Age %d", *f.AgeSec)
+	}
+	if f.Cache != "" {
+		line += ", X-Cache " + f.Cache
+	}
+	if f.Provider != "" {
+		line += ", " + f.Provider
+	}
+	return line
+}
@@ -0,0 +1,50 @@
+package runner
+
+import (
+	"fmt"
+	"net/http"
+	"slices"
+	"strings"
+
+	"github.com/tsosunchia/iNetSpeed-CLI/internal/cdn"
+	"github.com/tsosunchia/iNetSpeed-CLI/internal/i18n"
+	"github.com/tsosunchia/iNetSpeed-CLI/internal/report"
+)
+
+// observeResponse is the test clients' response hook. It records the TLS
+// session of each connection, and the first response a stage receives
+// records the CDN node that served it.
+func (r *run) observeResponse(resp *http.Response) {
+	if resp.TLS != nil {
+		r.observeTLS(resp.TLS)
+	}
+	stage := stageName(resp.Request.Context())
+	n := cdn.Identify(resp.Header)
+	if !n.Known() && n.Server == "" && n.Via == "" {
+		return
+	}
+	r.mu.Lock()
+	seen := slices.ContainsFunc(r.rep.CDN, func(c report.CDNNode) bool { return c.Stage == stage })
+	if !seen {
+		r.rep.CDN = append(r.rep.CDN, report.CDNNode{Stage: stage, Provider: n.Provider, Node: n.Node, PoP: n.PoP,
+			Cache: n.Cache, ID: n.ID, Server: n.Server, Via: n.Via})
+	}
+	r.mu.Unlock()
+	if !seen {
+		r.bus.Debug(fmt.Sprintf(i18n.Text("Served by %s (%s)", "服务节点 %s（%s）"), n, stage))
+	}
+}
+
+// servedBy lists the distinct nodes in r.rep.CDN, in the order first seen.
+func (r *run) servedBy() string {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	var nodes []string
+	for _, c := range r.rep.CDN {
+		n := cdn.Node{Provider: c.Provider, Node: c.Node, PoP: c.PoP, Server: c.Server}
+		if s := n.String(); s != "" && !slices.Contains(nodes, s) {
+			nodes = append(nodes, s)
+		}
+	}
+	return strings.Join(nodes, "; ")
+}
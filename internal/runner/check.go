@@ -0,0 +1,102 @@
+package runner
+
+import (
+	"context"
+	"fmt"
+	"math"
+	"strings"
+
+	"github.com/tsosunchia/iNetSpeed-CLI/internal/config"
+	"github.com/tsosunchia/iNetSpeed-CLI/internal/i18n"
+	"github.com/tsosunchia/iNetSpeed-CLI/internal/render"
+	"github.com/tsosunchia/iNetSpeed-CLI/internal/simulate"
+	"github.com/tsosunchia/iNetSpeed-CLI/internal/transfer"
+)
+
+// checkTarget is one test URL the check reaches.
+type checkTarget struct {
+	key    string // name in the --quiet line
+	en, zh string
+	dir    transfer.Direction
+	url    string
+}
+
+// Check runs the `check` command and --dry-run: it goes through the setup
+// of a run, the URL hook, the PAC file, config discovery and the endpoint
+// choice, then sends one tiny request to each test URL and reports how it
+// answered, moving no test data. Exit codes: 0 every URL answered, 1 the URL
+// hook or PAC file failed, 2 a URL or the discovery failed, 130 interrupted.
+func Check(ctx context.Context, cfg *config.Config, bus *render.Bus) int {
+	cfg, urlExpires, ok := prepare(ctx, cfg, bus)
+	if !ok {
+		return 1
+	}
+	if cfg.Simulate {
+		srv := simulate.Start(cfg.Sim)
+		defer srv.Close()
+		cfg = simulatedConfig(cfg, srv)
+	}
+	r := newRun(cfg, bus, false)
+	r.urlExpires = urlExpires
+	r.banner()
+	bus.Result(i18n.Text("Configuration is valid.", "配置有效。"))
+	if cfg.Simulate {
+		bus.Warn(simulationNote(cfg))
+	}
+
+	if cfg.Discover {
+		r.discoverURLs(ctx)
+	}
+	if !cfg.Simulate {
+		if err := r.selectEndpoint(ctx); err != nil {
+			bus.Warn(fmt.Sprintf(i18n.Text("Stage failed: %v", "阶段失败: %v"), err))
+			r.markDegraded()
+		}
+	}
+	if ctx.Err() != nil {
+		bus.Warn(i18n.Text("Interrupted.", "已中断。"))
+		return 130
+	}
+
+	bus.Header(i18n.Text("URL Check", "地址检查"))
+	targets := []checkTarget{
+		{"down", "Download", "下载", transfer.Download, r.cfg.DLURL},
+		{"up", "Upload", "上传", transfer.Upload, r.cfg.ULURL},
+		{"latency", "Latency", "延迟", transfer.Download, r.cfg.LatencyURL},
+	}
+	var quiet []string
+	failed := 0
+	for _, t := range targets {
+		if ctx.Err() != nil {
+			bus.Warn(i18n.Text("Interrupted.", "已中断。"))
+			return 130
+		}
+		label := i18n.Text(t.en, t.zh)
+		bus.Debug(label + ": " + config.Redact(t.url))
+		rc, err := transfer.CheckURL(ctx, r.client, r.cfg, t.dir, t.url)
+		r.totalData += rc.Bytes
+		if err != nil {
+			failed++
+			quiet = append(quiet, t.key+"=fail")
+			bus.Warn(fmt.Sprintf(i18n.Text("%s URL unreachable: %v", "%s地址不可达: %v"), label, err))
+			continue
+		}
+		ms := math.Round(float64(rc.TTFB.Microseconds())/100) / 10
+		quiet = append(quiet, fmt.Sprintf("%s=%d/%.1fms", t.key, rc.Status, ms))
+		bus.KV(label, fmt.Sprintf(i18n.Text("%s HTTP %d, first byte %.1f ms", "%s HTTP %d，首字节 %.1f 毫秒"), rc.Method, rc.Status, ms))
+	}
+	bus.KV(i18n.Text("Data Used", "消耗流量"), config.HumanBytes(r.totalData))
+	if r.cfg.Quiet {
+		fmt.Fprintln(stdout, strings.Join(quiet, " "))
+	}
+
+	if failed > 0 {
+		bus.Warn(fmt.Sprintf(i18n.Text("%d of %d test URLs cannot be reached.", "有 %d 个测速地址不可达（共 %d 个）。"), failed, len(targets)))
+		return 2
+	}
+	if r.isDegraded() {
+		return 2
+	}
+	bus.Result(i18n.Text("All test URLs are reachable.", "所有测速地址均可达。"))
+	return 0
+}
@@ -0,0 +1,242 @@
+package runner
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"math"
+	"net/http"
+	"net/http/httptrace"
+	"time"
+
+	"github.com/tsosunchia/iNetSpeed-CLI/internal/config"
+	"github.com/tsosunchia/iNetSpeed-CLI/internal/i18n"
+	"github.com/tsosunchia/iNetSpeed-CLI/internal/netx"
+	"github.com/tsosunchia/iNetSpeed-CLI/internal/report"
+	"github.com/tsosunchia/iNetSpeed-CLI/internal/transfer"
+	"github.com/tsosunchia/iNetSpeed-CLI/internal/units"
+)
+
+// coldWindow is how long each cold-start fetch is measured from sending
+// its request: about what a small App Store download takes. Tests shorten
+// it.
+var coldWindow = 2 * time.Second
+
+// warmChunk is the size of the range requests that bring the warm
+// connection up to speed; rampShare is the share of the warm rate a cold
+// interval must reach to count as up to speed.
+const (
+	warmChunk = 4 << 20
+	rampShare = 0.9
+)
+
+// errNoRange is returned by the warm-up when the server answers range
+// requests with the whole object, so the connection cannot be reused
+// without reading all of it.
+var errNoRange = errors.New("the server ignores Range requests")
+
+// windowFetch is what one cold-start fetch moved in coldWindow.
+type windowFetch struct {
+	bytes  int64
+	ttfb   time.Duration
+	dur    time.Duration
+	bins   []int64 // bytes per transfer.SeriesInterval from sending the request
+	reused bool
+}
+
+func (f windowFetch) mbps() float64 {
+	if f.dur <= 0 {
+		return 0
+	}
+	return float64(f.bytes) * 8 / f.dur.Seconds() / 1e6
+}
+
+// coldStart downloads for coldWindow over a brand-new connection, then
+// warms a second connection with back-to-back range requests and
+// downloads for coldWindow over it again. The new connection pays for the
+// handshakes and for TCP slow start, which a small download never grows
+// out of; the gap is why small downloads can crawl on a link whose
+// steady-state speed is fine.
+func (r *run) coldStart(ctx context.Context) error {
+	bus := r.bus
+	bus.Header(i18n.Text("Cold vs Warm Connection", "新连接与复用连接对比"))
+	r.refreshURLs(ctx, 4*coldWindow)
+	opts := r.clientOptions()
+	// Clients of their own keep the test's pooled connections out of it
+	// and, untracked, out of TCP_INFO.
+	opts.Tracker = nil
+
+	cold := netx.NewClient(opts)
+	defer cold.CloseIdleConnections()
+	c, err := r.windowFetch(ctx, cold)
+	if err != nil {
+		bus.Warn(fmt.Sprintf(i18n.Text("Cold-start test failed: %v", "新连接测试失败: %v"), err))
+		return nil
+	}
+	warm := netx.NewClient(opts)
+	defer warm.CloseIdleConnections()
+	if err := r.warmUp(ctx, warm); err != nil {
+		bus.Warn(fmt.Sprintf(i18n.Text("Cold-start test failed: %v", "新连接测试失败: %v"), err))
+		return nil
+	}
+	w, err := r.windowFetch(ctx, warm)
+	if err != nil {
+		bus.Warn(fmt.Sprintf(i18n.Text("Cold-start test failed: %v", "新连接测试失败: %v"), err))
+		return nil
+	}
+	cs := coldStartReport(c, w)
+	r.mu.Lock()
+	r.rep.ColdStart = cs
+	r.mu.Unlock()
+
+	bus.KV(i18n.Text("New connection", "新连接"), fmt.Sprintf(i18n.Text("%s in the first %.0f s, first byte %.1f ms", "%s（前 %.0f 秒），首字节 %.1f 毫秒"),
+		units.Rate(cs.Cold.Mbps, 1), cs.WindowSec, cs.Cold.TTFBMs))
+	bus.KV(i18n.Text("Warm connection", "复用连接"), fmt.Sprintf(i18n.Text("%s in the first %.0f s, first byte %.1f ms", "%s（前 %.0f 秒），首字节 %.1f 毫秒"),
+		units.Rate(cs.Warm.Mbps, 1), cs.WindowSec, cs.Warm.TTFBMs))
+	if !w.reused {
+		bus.Warn(i18n.Text("The warm fetch did not reuse the warmed connection; both fetches started cold.", "复用测试未能沿用预热的连接，两次均从新连接开始。"))
+	}
+	if cs.PenaltyPct > 0 {
+		bus.Result(fmt.Sprintf(i18n.Text("Slow-start penalty: %.0f%% less data in the first %.0f s on a new connection", "慢启动损失: 新连接少传输 %.0f%% 的数据（前 %.0f 秒）"),
+			cs.PenaltyPct, cs.WindowSec))
+	} else {
+		bus.Result(i18n.Text("No slow-start penalty: a new connection is as fast as a warm one.", "无慢启动损失: 新连接与复用连接一样快。"))
+	}
+	if cs.RampMs != nil {
+		bus.Info(fmt.Sprintf(i18n.Text("The new connection reached %.0f%% of the warm rate after %.1f s.", "新连接达到复用连接速率的 %.0f%% 用时 %.1f 秒。"),
+			rampShare*100, *cs.RampMs/1000))
+	} else if cs.PenaltyPct > 0 {
+		bus.Info(fmt.Sprintf(i18n.Text("The new connection did not reach %.0f%% of the warm rate within %.0f s.", "新连接未能达到复用连接速率的 %.0f%%（%.0f 秒内）。"),
+			rampShare*100, cs.WindowSec))
+	}
+	return nil
+}
+
+// coldStartReport compares the fetch over a new connection, c, with the
+// one over a warmed connection, w.
+func coldStartReport(c, w windowFetch) *report.ColdStart {
+	fetch := func(f windowFetch) report.ColdStartFetch {
+		return report.ColdStartFetch{
+			Mbps:   math.Round(f.mbps()*10) / 10,
+			TTFBMs: math.Round(float64(f.ttfb.Microseconds())/10) / 100,
+			Bytes:  f.bytes,
+			Reused: f.reused,
+		}
+	}
+	cs := &report.ColdStart{WindowSec: coldWindow.Seconds(), Cold: fetch(c), Warm: fetch(w)}
+	warm := w.mbps()
+	if warm <= 0 {
+		return cs
+	}
+	cs.PenaltyPct = math.Round((1-c.mbps()/warm)*1000) / 10
+	want := warm * rampShare * 1e6 / 8 * transfer.SeriesInterval.Seconds()
+	for i, n := range c.bins {
+		if float64(n) >= want {
+			ms := float64((time.Duration(i+1) * transfer.SeriesInterval).Milliseconds())
+			cs.RampMs = &ms
+			break
+		}
+	}
+	return cs
+}
+
+// warmUp reads the download object in warmChunk ranges over client for
+// coldWindow, leaving behind one idle connection whose congestion window
+// has grown.
+func (r *run) warmUp(ctx context.Context, client *http.Client) error {
+	deadline := time.Now().Add(coldWindow)
+	for off := int64(0); time.Now().Before(deadline); off += warmChunk {
+		if err := ctx.Err(); err != nil {
+			return err
+		}
+		if err := r.warmChunk(ctx, client, off); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (r *run) warmChunk(ctx context.Context, client *http.Client, off int64) error {
+	ctx, cancel := context.WithTimeout(ctx, time.Duration(r.cfg.Timeout)*time.Second)
+	defer cancel()
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, r.cfg.DLURL, nil)
+	if err != nil {
+		return err
+	}
+	config.SetHeader(req, r.cfg.RequestHeader())
+	req.Header.Set("Range", fmt.Sprintf("bytes=%d-%d", off, off+warmChunk-1))
+	resp, err := client.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode >= 400 {
+		return &transfer.StatusError{Code: resp.StatusCode}
+	}
+	if resp.StatusCode != http.StatusPartialContent {
+		return errNoRange
+	}
+	n, err := io.Copy(io.Discard, resp.Body)
+	r.addData(n)
+	return err
+}
+
+// windowFetch downloads the download object over client for coldWindow
+// from sending the request, or until it ends.
+func (r *run) windowFetch(ctx context.Context, client *http.Client) (windowFetch, error) {
+	var f windowFetch
+	ctx, cancel := context.WithTimeout(ctx, coldWindow)
+	defer cancel()
+	var first time.Time
+	ctx = httptrace.WithClientTrace(ctx, &httptrace.ClientTrace{
+		GotConn:              func(info httptrace.GotConnInfo) { f.reused = info.Reused },
+		GotFirstResponseByte: func() { first = time.Now() },
+	})
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, r.cfg.DLURL, nil)
+	if err != nil {
+		return f, err
+	}
+	config.SetHeader(req, r.cfg.RequestHeader())
+	start := time.Now()
+	resp, err := client.Do(req)
+	if err != nil {
+		return f, err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode >= 400 {
+		return f, &transfer.StatusError{Code: resp.StatusCode}
+	}
+	if first.IsZero() {
+		first = time.Now()
+	}
+	f.ttfb = first.Sub(start)
+	f.bins = make([]int64, int(coldWindow/transfer.SeriesInterval))
+	buf := make([]byte, 64<<10)
+	for {
+		n, err := resp.Body.Read(buf)
+		now := time.Since(start)
+		if n > 0 && now < coldWindow {
+			f.bytes += int64(n)
+			f.bins[int(now/transfer.SeriesInterval)] += int64(n)
+		}
+		if err != nil || now >= coldWindow {
+			f.dur = min(now, coldWindow)
+			if err != nil && err != io.EOF && ctx.Err() == nil {
+				r.addData(f.bytes)
+				return f, err
+			}
+			break
+		}
+	}
+	r.addData(f.bytes)
+	return f, nil
+}
+
+// addData counts n bytes a side measurement moved in the data used.
+func (r *run) addData(n int64) {
+	r.mu.Lock()
+	r.totalData += n
+	r.rep.DataUsedBytes = r.totalData
+	r.mu.Unlock()
+}
@@ -0,0 +1,164 @@
+package runner
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"os"
+	"os/signal"
+	"slices"
+	"sync"
+
+	"github.com/tsosunchia/iNetSpeed-CLI/internal/config"
+	"github.com/tsosunchia/iNetSpeed-CLI/internal/i18n"
+	"github.com/tsosunchia/iNetSpeed-CLI/internal/report"
+)
+
+// control steers a --runs series from outside, so a long series run as a
+// service needs no restart: SIGHUP loads the configuration again for the
+// following runs, SIGUSR1 ends the cooldown and starts the next run at
+// once, and SIGUSR2 prints the statistics of the runs finished so far.
+type control struct {
+	r      *run // the first run, whose configuration the series started with
+	sigs   chan os.Signal
+	runNow chan struct{}
+	done   chan struct{}
+
+	mu   sync.Mutex
+	reps []*report.Report
+	cfg  *config.Config // reloaded for the next run; nil when not
+
+	// The HTTP API of --api, nil when off, serves the published reports,
+	// which no longer change, or the history file.
+	api       *http.Server
+	history   string
+	published []*report.Report
+}
+
+// control starts listening for the signals that steer the series r begins.
+func (r *run) control(ctx context.Context) *control {
+	c := &control{
+		r:      r,
+		sigs:   make(chan os.Signal, 1),
+		runNow: make(chan struct{}, 1),
+		done:   make(chan struct{}),
+	}
+	if len(controlSignals) == 0 {
+		return c
+	}
+	signal.Notify(c.sigs, controlSignals...)
+	go func() {
+		for {
+			select {
+			case <-ctx.Done():
+				return
+			case <-c.done:
+				return
+			case s := <-c.sigs:
+				c.handle(s)
+			}
+		}
+	}()
+	return c
+}
+
+func (c *control) stop() {
+	if len(controlSignals) > 0 {
+		signal.Stop(c.sigs)
+	}
+	if c.api != nil {
+		c.api.Close()
+	}
+	close(c.done)
+}
+
+func (c *control) handle(s os.Signal) {
+	bus := c.r.bus
+	switch s {
+	case sigReload:
+		c.reload()
+	case sigRunNow:
+		bus.Info(i18n.Text("SIGUSR1: starting the next run now.", "SIGUSR1: 立即开始下一次测速。"))
+		select {
+		case c.runNow <- struct{}{}:
+		default:
+		}
+	case sigDump:
+		c.mu.Lock()
+		reps := slices.Clone(c.reps)
+		c.mu.Unlock()
+		if len(reps) == 0 {
+			bus.Info(i18n.Text("SIGUSR2: no run has finished yet.", "SIGUSR2: 尚无已完成的测速。"))
+			return
+		}
+		showRunStats(c.r.bus, len(reps), report.RunStats(reps))
+	}
+}
+
+// reload loads the configuration from the series' arguments, the
+// environment and CONFIG_FILE again. A configuration that does not load
+// is reported and the current one kept.
+func (c *control) reload() {
+	bus := c.r.bus
+	fresh, err := config.Load(c.r.cfg.Args...)
+	if err != nil {
+		bus.Warn(fmt.Sprintf(i18n.Text("SIGHUP: configuration not reloaded: %v", "SIGHUP: 未重新加载配置: %v"), err))
+		return
+	}
+	cfg := keepSeries(c.r.cfg, fresh)
+	c.mu.Lock()
+	c.cfg = cfg
+	c.mu.Unlock()
+	bus.Info(i18n.Text("SIGHUP: configuration reloaded; it applies from the next run.", "SIGHUP: 已重新加载配置，自下一次测速起生效。"))
+	bus.Info(i18n.Text("Config:  ", "配置:  ") + cfg.Summary())
+}
+
+// keepSeries is fresh with what the series was set up with at the start:
+// its length, the test URLs and endpoint they led to, the proxy script and
+// the emulator.
+func keepSeries(start, fresh *config.Config) *config.Config {
+	c := *fresh
+	c.Runs = start.Runs
+	c.DLURL, c.ULURL, c.LatencyURL = start.DLURL, start.ULURL, start.LatencyURL
+	c.URLHook, c.Discover = start.URLHook, start.Discover
+	c.ProxyPAC, c.PAC = start.ProxyPAC, start.PAC
+	c.Simulate, c.Sim = start.Simulate, start.Sim
+	return &c
+}
+
+// keptReports is how many reports a series holds on to: all of a bounded
+// one, and the latest of one running until interrupted.
+const keptReports = 100
+
+// finished records rep and returns every report of the series so far, or
+// the latest keptReports of them.
+func (c *control) finished(rep *report.Report) []*report.Report {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.reps = keepLatest(append(c.reps, rep))
+	return slices.Clone(c.reps)
+}
+
+// publish records rep, published, for the API.
+func (c *control) publish(rep *report.Report) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.published = keepLatest(append(c.published, rep))
+}
+
+func keepLatest(reps []*report.Report) []*report.Report {
+	if n := len(reps) - keptReports; n > 0 {
+		return slices.Delete(reps, 0, n)
+	}
+	return reps
+}
+
+// reloaded returns the configuration SIGHUP loaded since the last call, or
+// nil.
+func (c *control) reloaded() *config.Config {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	cfg := c.cfg
+	c.cfg = nil
+	return cfg
+}
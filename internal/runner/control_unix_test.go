@@ -0,0 +1,77 @@
+//go:build unix
+
+package runner
+
+import (
+	"bytes"
+	"context"
+	"strings"
+	"syscall"
+	"testing"
+	"time"
+
+	"github.com/tsosunchia/iNetSpeed-CLI/internal/config"
+	"github.com/tsosunchia/iNetSpeed-CLI/internal/render"
+	"github.com/tsosunchia/iNetSpeed-CLI/internal/report"
+)
+
+func TestControl(t *testing.T) {
+	cfg, err := config.Load("--runs", "5", "--cooldown", "1h", "--threads", "4")
+	if err != nil {
+		t.Fatal(err)
+	}
+	cfg.DLURL = "http://emulator.invalid/large"
+	var buf bytes.Buffer
+	bus := render.NewBus(render.NewPlainRenderer(&buf))
+	r := newRun(cfg, bus, false)
+	ctl := r.control(context.Background())
+
+	ctl.handle(sigDump)
+	ctl.finished(&report.Report{Rounds: []report.Round{{Direction: report.DirDownload, Mbps: 100}}})
+	ctl.handle(sigDump)
+
+	// A real SIGUSR1 cuts the hour of cooldown short.
+	if err := syscall.Kill(syscall.Getpid(), syscall.SIGUSR1); err != nil {
+		t.Fatal(err)
+	}
+	done := make(chan bool, 1)
+	go func() { done <- r.cooldown(context.Background(), ctl.runNow) }()
+	select {
+	case ok := <-done:
+		if !ok {
+			t.Error("cooldown interrupted")
+		}
+	case <-time.After(5 * time.Second):
+		t.Fatal("SIGUSR1 did not end the cooldown")
+	}
+
+	t.Setenv("LATENCY_COUNT", "0")
+	ctl.handle(sigReload)
+	if c := ctl.reloaded(); c != nil {
+		t.Errorf("invalid configuration reloaded: %+v", c)
+	}
+	t.Setenv("RUNS", "9")
+	t.Setenv("LATENCY_COUNT", "7")
+	ctl.handle(sigReload)
+	c := ctl.reloaded()
+	if c == nil || c.LatencyCount != 7 || c.Threads != 4 || c.Runs != 5 || c.DLURL != cfg.DLURL {
+		t.Errorf("reloaded %+v", c)
+	}
+	if ctl.reloaded() != nil {
+		t.Error("reloaded configuration handed out twice")
+	}
+	ctl.stop()
+	bus.Close()
+
+	for _, want := range []string{
+		"SIGUSR2: no run has finished yet.",
+		"Statistics over 1 runs",
+		"SIGUSR1: starting the next run now.",
+		"SIGHUP: configuration not reloaded: LATENCY_COUNT must be > 0",
+		"SIGHUP: configuration reloaded; it applies from the next run.",
+	} {
+		if !strings.Contains(buf.String(), want) {
+			t.Errorf("output missing %q\n%s", want, buf.String())
+		}
+	}
+}
@@ -0,0 +1,84 @@
+package runner
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+
+	"github.com/tsosunchia/iNetSpeed-CLI/internal/config"
+	"github.com/tsosunchia/iNetSpeed-CLI/internal/demo"
+	"github.com/tsosunchia/iNetSpeed-CLI/internal/i18n"
+	"github.com/tsosunchia/iNetSpeed-CLI/internal/render"
+	"github.com/tsosunchia/iNetSpeed-CLI/internal/report"
+)
+
+// demoSpeed is how fast Demo replays; tests set zero to skip the waits.
+var demoSpeed = 1.0
+
+// Demo runs --demo: the bundled recording of a run is replayed through bus
+// at its recorded pace, so output modes and renderers can be tried without
+// a network. Nothing is measured or written; under --quiet the recorded
+// report's line is printed. Exit codes: 0 success, 1 unreadable recording,
+// 130 interrupted.
+func Demo(ctx context.Context, cfg *config.Config, bus *render.Bus) int {
+	events, err := demoEvents(i18n.Lang())
+	if err != nil {
+		bus.Fatal(fmt.Sprintf(i18n.Text("Cannot read the demo recording: %v", "无法读取演示录制: %v"), err))
+		return 1
+	}
+	bus.Warn(i18n.Text("Demo mode: replaying a recorded run; nothing is measured.", "演示模式：回放一次录制的测速，不进行实际测量。"))
+	if err := render.Replay(ctx, bus, events, demoSpeed); err != nil {
+		bus.Warn(i18n.Text("Interrupted.", "已中断。"))
+		return 130
+	}
+	if cfg.Quiet {
+		if rep := recordedReport(events); rep != nil {
+			fmt.Fprintln(stdout, rep.QuietLine())
+		}
+	}
+	return 0
+}
+
+// demoEvents reads the recording for lang. Traditional Chinese replays the
+// Simplified one, converted.
+func demoEvents(lang string) ([]render.Event, error) {
+	rec := lang
+	if lang == i18n.LangZHHant {
+		rec = i18n.LangZH
+	}
+	b, err := demo.Recording(rec)
+	if err != nil {
+		return nil, err
+	}
+	events, err := render.ReadEventLog(bytes.NewReader(b))
+	if err != nil {
+		return nil, err
+	}
+	if lang == i18n.LangZHHant {
+		for i := range events {
+			events[i].Label = i18n.ToHant(events[i].Label)
+			events[i].Value = i18n.ToHant(events[i].Value)
+		}
+	}
+	return events, nil
+}
+
+// recordedReport decodes the report event of a recording.
+func recordedReport(events []render.Event) *report.Report {
+	for _, ev := range events {
+		if ev.Kind != render.KindReport {
+			continue
+		}
+		b, err := json.Marshal(ev.Data["report"])
+		if err != nil {
+			return nil
+		}
+		var rep report.Report
+		if json.Unmarshal(b, &rep) != nil {
+			return nil
+		}
+		return &rep
+	}
+	return nil
+}
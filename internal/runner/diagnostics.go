@@ -0,0 +1,51 @@
+package runner
+
+import (
+	"slices"
+
+	"github.com/tsosunchia/iNetSpeed-CLI/internal/i18n"
+	"github.com/tsosunchia/iNetSpeed-CLI/internal/report"
+	"github.com/tsosunchia/iNetSpeed-CLI/internal/transfer"
+)
+
+// faultDiagnostics totals the faults of rounds by direction and category,
+// the most frequent first; ties keep download before upload and the order
+// of transfer.Faults.
+func faultDiagnostics(rounds []report.Round) []report.Diagnostic {
+	var out []report.Diagnostic
+	for _, dir := range []string{report.DirDownload, report.DirUpload} {
+		for _, f := range transfer.Faults {
+			n := 0
+			for _, rd := range rounds {
+				if rd.Direction == dir {
+					n += rd.FaultKinds[string(f)]
+				}
+			}
+			if n > 0 {
+				out = append(out, report.Diagnostic{Direction: dir, Fault: string(f), Count: n})
+			}
+		}
+	}
+	slices.SortStableFunc(out, func(a, b report.Diagnostic) int { return b.Count - a.Count })
+	return out
+}
+
+// diagnostics closes the summary with what went wrong during the transfers,
+// each kind of fault with what it most likely means, and with a discrepancy
+// between the HTTP and ICMP idle latency.
+func (r *run) diagnostics() {
+	r.mu.Lock()
+	r.rep.Diagnostics = faultDiagnostics(r.rep.Rounds)
+	if d := r.rep.LatencyDiscrepancy; d != "" && r.rep.ICMPLatency != nil {
+		r.rep.Diagnostics = append(r.rep.Diagnostics, report.Diagnostic{Finding: d, HTTPMs: r.idle.Median, ICMPMs: r.rep.ICMPLatency.MedianMs})
+	}
+	diags := r.rep.Diagnostics
+	r.mu.Unlock()
+	if len(diags) == 0 {
+		return
+	}
+	r.bus.Header(i18n.Text("Diagnostics", "诊断"))
+	for _, d := range diags {
+		r.bus.Warn(transfer.Explain(d))
+	}
+}
@@ -0,0 +1,55 @@
+package runner
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/tsosunchia/iNetSpeed-CLI/internal/config"
+	"github.com/tsosunchia/iNetSpeed-CLI/internal/discover"
+	"github.com/tsosunchia/iNetSpeed-CLI/internal/endpoint"
+	"github.com/tsosunchia/iNetSpeed-CLI/internal/i18n"
+)
+
+// discoverURLs fetches the networkQuality configuration at DL_URL's origin
+// and switches the run to the test URLs it advertises, as networkQuality
+// does. A failed fetch keeps the configured URLs and marks the run degraded;
+// a later run of --runs reuses the first run's answer.
+func (r *run) discoverURLs(ctx context.Context) error {
+	bus := r.bus
+	bus.Header(i18n.Text("Config Discovery", "配置发现"))
+	if r.disc == nil {
+		configURL, err := discover.ConfigURL(r.cfg.DLURL)
+		var d discover.Config
+		if err == nil {
+			d, err = discover.Fetch(ctx, r.client, configURL, r.cfg.RequestHeader())
+		}
+		if err != nil {
+			bus.Warn(fmt.Sprintf(i18n.Text("Config discovery failed, keeping the configured URLs: %v", "配置发现失败，沿用已配置的测速地址: %v"), err))
+			r.markDegraded()
+			return nil
+		}
+		bus.Info(i18n.Text("Test URLs from ", "测速地址来自 ") + configURL)
+		r.disc = &d
+	} else {
+		bus.Info(i18n.Text("Same test URLs as run 1.", "沿用第 1 次测速的测速地址。"))
+	}
+
+	d := r.disc
+	c := *r.cfg
+	c.DLURL, c.ULURL, c.LatencyURL = d.DLURL, d.ULURL, d.LatencyURL
+	r.cfg = &c
+	r.cdnHost = endpoint.HostFromURL(d.DLURL)
+	r.mu.Lock()
+	r.rep.Config.DLURL = config.Redact(d.DLURL)
+	r.rep.Config.ULURL = config.Redact(d.ULURL)
+	r.rep.Config.LatencyURL = config.Redact(d.LatencyURL)
+	r.rep.TestEndpoint = d.TestEndpoint
+	r.mu.Unlock()
+	bus.KV(i18n.Text("Download", "下载"), config.Redact(d.DLURL))
+	bus.KV(i18n.Text("Upload", "上传"), config.Redact(d.ULURL))
+	bus.KV(i18n.Text("Latency", "延迟"), config.Redact(d.LatencyURL))
+	if d.TestEndpoint != "" {
+		bus.Result(i18n.Text("Test endpoint assigned by Apple: ", "Apple 分配的测试节点: ") + d.TestEndpoint)
+	}
+	return nil
+}
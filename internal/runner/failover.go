@@ -0,0 +1,112 @@
+package runner
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"math"
+	"net/url"
+
+	"github.com/tsosunchia/iNetSpeed-CLI/internal/config"
+	"github.com/tsosunchia/iNetSpeed-CLI/internal/i18n"
+	"github.com/tsosunchia/iNetSpeed-CLI/internal/report"
+	"github.com/tsosunchia/iNetSpeed-CLI/internal/transfer"
+)
+
+// testURL is a test URL in use and the fallbacks given after it, which
+// replace it in order when it fails.
+type testURL struct {
+	key    string // report.Failover.URL
+	en, zh string
+	dir    transfer.Direction
+	url    *string
+	rest   *[]string
+}
+
+// testURLs are the run's test URLs: download, upload, then latency.
+func (r *run) testURLs() []testURL {
+	return []testURL{
+		{"dl_url", "Download", "下载", transfer.Download, &r.cfg.DLURL, &r.cfg.DLFallbacks},
+		{"ul_url", "Upload", "上传", transfer.Upload, &r.cfg.ULURL, &r.cfg.ULFallbacks},
+		{"latency_url", "Latency", "延迟", transfer.Download, &r.cfg.LatencyURL, &r.cfg.LatencyFallbacks},
+	}
+}
+
+// urlCheck sends one tiny request to each test URL given as a list before
+// any test, and moves down the list past URLs that do not answer, so a
+// scheduled run survives an endpoint outage or a regional block.
+func (r *run) urlCheck(ctx context.Context) error {
+	r.bus.Header(i18n.Text("URL Check", "地址检查"))
+	for _, u := range r.testURLs() {
+		if len(*u.rest) == 0 {
+			continue
+		}
+		if ctx.Err() != nil {
+			return nil
+		}
+		r.failOver(ctx, config.StageURLCheck, u)
+	}
+	return nil
+}
+
+// recheck runs after a transfer round of dir that hit a network fault: a
+// URL that then fails its check as well gives way to the next of its list
+// for the rounds after.
+func (r *run) recheck(ctx context.Context, stage string, dir transfer.Direction) {
+	u := r.testURLs()[0]
+	if dir == transfer.Upload {
+		u = r.testURLs()[1]
+	}
+	if len(*u.rest) == 0 || ctx.Err() != nil {
+		return
+	}
+	r.failOver(ctx, stage, u)
+}
+
+// failOver checks u's URL and, while it fails, replaces it with the next
+// of its list, recording each switch against stage. With the list used
+// up, the last URL stays and the run is marked degraded.
+func (r *run) failOver(ctx context.Context, stage string, u testURL) {
+	bus := r.bus
+	label := i18n.Text(u.en, u.zh)
+	for {
+		rc, err := transfer.CheckURL(ctx, r.client, r.cfg, u.dir, *u.url)
+		r.addData(rc.Bytes)
+		if err == nil {
+			ms := math.Round(float64(rc.TTFB.Microseconds())/100) / 10
+			bus.KV(label, fmt.Sprintf(i18n.Text("%s  (HTTP %d, first byte %.1f ms)", "%s  (HTTP %d，首字节 %.1f 毫秒)"), config.Redact(*u.url), rc.Status, ms))
+			return
+		}
+		if ctx.Err() != nil {
+			return
+		}
+		reason := checkError(err)
+		if len(*u.rest) == 0 {
+			bus.Warn(fmt.Sprintf(i18n.Text("%s URL %s failed (%s) and no fallback is left.", "%s地址 %s 失败（%s），已无备用地址。"), label, config.Redact(*u.url), reason))
+			r.markDegraded()
+			return
+		}
+		from := *u.url
+		*u.url, *u.rest = (*u.rest)[0], (*u.rest)[1:]
+		bus.Warn(fmt.Sprintf(i18n.Text("%s URL %s failed (%s); switching to %s.", "%s地址 %s 失败（%s），切换到 %s。"), label, config.Redact(from), reason, config.Redact(*u.url)))
+		r.mu.Lock()
+		r.rep.Failovers = append(r.rep.Failovers, report.Failover{
+			URL:    u.key,
+			Stage:  stage,
+			From:   config.Redact(from),
+			To:     config.Redact(*u.url),
+			Reason: reason,
+		})
+		r.mu.Unlock()
+	}
+}
+
+// checkError describes a failed check without the URL net/http wraps its
+// errors in, which the message already names.
+func checkError(err error) string {
+	var ue *url.Error
+	if errors.As(err, &ue) {
+		return ue.Err.Error()
+	}
+	return err.Error()
+}
@@ -0,0 +1,128 @@
+package runner
+
+import (
+	"cmp"
+	"fmt"
+	"slices"
+	"strings"
+	"sync"
+
+	"github.com/tsosunchia/iNetSpeed-CLI/internal/i18n"
+	"github.com/tsosunchia/iNetSpeed-CLI/internal/latency"
+	"github.com/tsosunchia/iNetSpeed-CLI/internal/render"
+	"github.com/tsosunchia/iNetSpeed-CLI/internal/report"
+	"github.com/tsosunchia/iNetSpeed-CLI/internal/transfer"
+)
+
+// load counts the transfer flows running while loaded latency is probed,
+// by direction, and keeps each sample with the counts it was taken under,
+// so the report can show how latency scales with the number of flows. The
+// rounds keep the counts current through loadedSource.
+type load struct {
+	mu      sync.Mutex
+	flows   [2]int // by transfer.Direction
+	samples map[flowKey][]float64
+}
+
+type flowKey struct{ down, up int }
+
+// SetFlows implements transfer.FlowCounter.
+func (l *load) SetFlows(dir transfer.Direction, n int) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	l.flows[dir] = n
+}
+
+// sample records a latency sample under the flows running now and returns
+// them. Samples taken with no flow running are not kept.
+func (l *load) sample(ms float64) (down, up int) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	k := flowKey{l.flows[transfer.Download], l.flows[transfer.Upload]}
+	if k.down+k.up > 0 {
+		if l.samples == nil {
+			l.samples = map[flowKey][]float64{}
+		}
+		l.samples[k] = append(l.samples[k], ms)
+	}
+	return k.down, k.up
+}
+
+// loadedSource is a loaded-latency probe handed to a transfer round, which
+// reports its flows to l.
+type loadedSource struct {
+	*latency.Probe
+	*load
+}
+
+// flowDirection names the direction of down download and up upload flows,
+// or is empty when none ran.
+func flowDirection(down, up int) string {
+	switch {
+	case down > 0 && up > 0:
+		return bidiBoth
+	case down > 0:
+		return report.DirDownload
+	case up > 0:
+		return report.DirUpload
+	}
+	return ""
+}
+
+// loadedSample forwards loaded-latency probes to the event log under phase,
+// with the flows of each direction running when they were taken.
+func (r *run) loadedSample(phase string) latency.SampleFunc {
+	return func(ms float64) {
+		down, up := r.load.sample(ms)
+		r.bus.LoadedLatency(phase, ms, flowDirection(down, up), down, up)
+	}
+}
+
+// latencyByFlows groups the loaded-latency samples of the run by the flows
+// they were taken under: download, then upload, then both, by flow count.
+func (r *run) latencyByFlows() []report.FlowLatency {
+	r.load.mu.Lock()
+	defer r.load.mu.Unlock()
+	var out []report.FlowLatency
+	for k, samples := range r.load.samples {
+		s := latency.Compute(samples)
+		out = append(out, report.FlowLatency{
+			Direction:     flowDirection(k.down, k.up),
+			DownloadFlows: k.down,
+			UploadFlows:   k.up,
+			Samples:       s.N,
+			MedianMs:      s.Median,
+			AvgMs:         s.Avg,
+			MaxMs:         s.Max,
+		})
+	}
+	order := map[string]int{report.DirDownload: 0, report.DirUpload: 1, bidiBoth: 2}
+	slices.SortFunc(out, func(a, b report.FlowLatency) int {
+		return cmp.Or(cmp.Compare(order[a.Direction], order[b.Direction]),
+			cmp.Compare(a.DownloadFlows+a.UploadFlows, b.DownloadFlows+b.UploadFlows),
+			cmp.Compare(a.DownloadFlows, b.DownloadFlows))
+	})
+	return out
+}
+
+// showLatencyByFlows lists the loaded latency by flow count under
+// --verbose.
+func showLatencyByFlows(bus *render.Bus, groups []report.FlowLatency) {
+	if len(groups) < 2 {
+		return
+	}
+	parts := make([]string, len(groups))
+	for i, g := range groups {
+		var flows string
+		switch g.Direction {
+		case report.DirDownload:
+			flows = fmt.Sprintf("↓%d", g.DownloadFlows)
+		case report.DirUpload:
+			flows = fmt.Sprintf("↑%d", g.UploadFlows)
+		default:
+			flows = fmt.Sprintf("↓%d+↑%d", g.DownloadFlows, g.UploadFlows)
+		}
+		parts[i] = fmt.Sprintf("%s %.1f ms (n=%d)", flows, g.MedianMs, g.Samples)
+	}
+	bus.Debug(i18n.Text("Loaded latency by flows: ", "各流数下的负载延迟: ") + strings.Join(parts, ", "))
+}
@@ -0,0 +1,55 @@
+package runner
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"github.com/tsosunchia/iNetSpeed-CLI/internal/config"
+	"github.com/tsosunchia/iNetSpeed-CLI/internal/i18n"
+	"github.com/tsosunchia/iNetSpeed-CLI/internal/urlhook"
+)
+
+// applyURLHook calls cfg.URLHook and stores the URLs it supplies in cfg,
+// returning when they expire (zero for never).
+func applyURLHook(ctx context.Context, cfg *config.Config) (time.Time, error) {
+	u, err := urlhook.Fetch(ctx, cfg.URLHook)
+	if err != nil {
+		return time.Time{}, err
+	}
+	if u.DLURL != "" {
+		cfg.DLURL = u.DLURL
+	}
+	if u.ULURL != "" {
+		cfg.ULURL = u.ULURL
+	}
+	if u.LatencyURL != "" {
+		cfg.LatencyURL = u.LatencyURL
+	}
+	return u.Expires, nil
+}
+
+// refreshURLs renews the hook URLs when they would expire within d, the
+// longest the next stage can take. A failed renewal keeps the old URLs and
+// marks the run degraded.
+func (r *run) refreshURLs(ctx context.Context, d time.Duration) {
+	if r.cfg.URLHook == "" || !urlhook.Stale(r.urlExpires, time.Now(), d) {
+		return
+	}
+	exp, err := applyURLHook(ctx, r.cfg)
+	if err != nil {
+		r.bus.Warn(fmt.Sprintf(i18n.Text("Could not renew test URLs: %v", "无法更新测速地址: %v"), err))
+		r.markDegraded()
+		return
+	}
+	r.urlExpires = exp
+	r.bus.Info(urlsRenewed(exp))
+}
+
+func urlsRenewed(exp time.Time) string {
+	if exp.IsZero() {
+		return i18n.Text("Test URLs supplied by the URL hook.", "测速地址由 URL 钩子提供。")
+	}
+	return fmt.Sprintf(i18n.Text("Test URLs supplied by the URL hook, valid until %s.", "测速地址由 URL 钩子提供，有效期至 %s。"),
+		exp.Local().Format("15:04:05"))
+}
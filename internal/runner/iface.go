@@ -0,0 +1,91 @@
+package runner
+
+import (
+	"fmt"
+	"math"
+
+	"github.com/tsosunchia/iNetSpeed-CLI/internal/config"
+	"github.com/tsosunchia/iNetSpeed-CLI/internal/i18n"
+	"github.com/tsosunchia/iNetSpeed-CLI/internal/ifstat"
+	"github.com/tsosunchia/iNetSpeed-CLI/internal/render"
+	"github.com/tsosunchia/iNetSpeed-CLI/internal/report"
+	"github.com/tsosunchia/iNetSpeed-CLI/internal/sink"
+	"github.com/tsosunchia/iNetSpeed-CLI/internal/transfer"
+)
+
+// otherTrafficRatio is the ratio of interface to test bytes from which a
+// round is said to have shared the interface: TCP/IP, TLS and link headers
+// add well under a tenth.
+const otherTrafficRatio = 1.25
+
+// lostTrafficRatio is the ratio below which the test's bytes likely left
+// through another interface than the one routed to the endpoint.
+const lostTrafficRatio = 0.9
+
+// ifaceMinBytes is the least a round must move for its ratio to mean much.
+const ifaceMinBytes = 1 << 20
+
+var (
+	readIfCounters = ifstat.Read
+	routeInterface = sink.Interface
+)
+
+// startIfaceCheck reads the OS counters of the interface routed to the
+// endpoint. The returned function reads them again at the end of a round in
+// direction dir that counted bytes, and compares the two; it returns nil
+// where the counters cannot be read or the round moved too little.
+func (r *run) startIfaceCheck() func(dir transfer.Direction, bytes int64) *report.IfaceCounters {
+	none := func(transfer.Direction, int64) *report.IfaceCounters { return nil }
+	r.mu.Lock()
+	ip := r.rep.Server.IP
+	r.mu.Unlock()
+	if r.ep.IP != "" {
+		ip = r.ep.IP
+	}
+	name := routeInterface(ip)
+	if name == "" {
+		return none
+	}
+	before, err := readIfCounters(name)
+	if err != nil {
+		r.bus.Debug(fmt.Sprintf(i18n.Text("Cannot read the counters of %s: %v", "无法读取 %s 的流量计数: %v"), name, err))
+		return none
+	}
+	return func(dir transfer.Direction, bytes int64) *report.IfaceCounters {
+		after, err := readIfCounters(name)
+		if err != nil || bytes < ifaceMinBytes || after.RxBytes < before.RxBytes || after.TxBytes < before.TxBytes {
+			return nil
+		}
+		d := after.Sub(before)
+		moved := d.RxBytes
+		if dir == transfer.Upload {
+			moved = d.TxBytes
+		}
+		ratio := float64(moved) / float64(bytes)
+		return &report.IfaceCounters{
+			Name:         name,
+			Bytes:        moved,
+			Overhead:     math.Round(ratio*1000) / 1000,
+			OtherTraffic: ratio >= otherTrafficRatio,
+		}
+	}
+}
+
+// showIface warns when the interface carried far more than the test, which
+// then shared the link with other traffic, and notes when it carried less.
+func showIface(bus *render.Bus, c *report.IfaceCounters) {
+	if c == nil {
+		return
+	}
+	switch {
+	case c.OtherTraffic:
+		bus.Warn(fmt.Sprintf(i18n.Text("%s carried %.2f× the test's bytes: other traffic shared the interface and may have lowered the result.",
+			"%s 承载了测速流量的 %.2f 倍：其他流量共用了该接口，结果可能偏低。"), c.Name, c.Overhead))
+	case c.Overhead < lostTrafficRatio:
+		bus.Info(fmt.Sprintf(i18n.Text("%s carried only %.2f× the test's bytes: the test likely went through another interface (VPN or proxy).",
+			"%s 仅承载了测速流量的 %.2f 倍：测速流量可能经由其他接口（VPN 或代理）。"), c.Name, c.Overhead))
+	default:
+		bus.Debug(fmt.Sprintf(i18n.Text("Interface %s: %s, %.1f%% protocol overhead", "接口 %s: %s，协议开销 %.1f%%"),
+			c.Name, config.HumanBytes(int64(c.Bytes)), (c.Overhead-1)*100))
+	}
+}
@@ -0,0 +1,97 @@
+package runner
+
+import (
+	"context"
+	"fmt"
+	"math"
+
+	"github.com/tsosunchia/iNetSpeed-CLI/internal/i18n"
+	"github.com/tsosunchia/iNetSpeed-CLI/internal/render"
+	"github.com/tsosunchia/iNetSpeed-CLI/internal/report"
+	"github.com/tsosunchia/iNetSpeed-CLI/internal/units"
+)
+
+// ipGapPct is the difference between IPv4 and IPv6 in a metric taken to
+// mean the two versions reach the CDN over different paths. Latency must
+// also differ by ipGapMs, so a few milliseconds on a short path do not count.
+const (
+	ipGapPct = 20
+	ipGapMs  = 5
+)
+
+// compareIPVersions runs the benchmark twice, starting with r: pinned to an
+// IPv4 address of the CDN host, then to an IPv6 one, pausing cfg.Cooldown in
+// between. Both reports are published; the IPv6 one also carries the
+// difference. The exit code is the worse of the two.
+func (r *run) compareIPVersions(ctx context.Context) int {
+	v4 := r
+	v4.family, v4.rep.IPVersion = 4, 4
+	r.bus.Line()
+	r.bus.Banner("IPv4")
+	if !v4.measure(ctx) {
+		return 130
+	}
+	code := v4.publish(ctx)
+	if !v4.cooldown(ctx, nil) {
+		return 130
+	}
+
+	v6 := v4.successor()
+	v6.family, v6.rep.IPVersion = 6, 6
+	r.bus.Line()
+	r.bus.Banner("IPv6")
+	if !v6.measure(ctx) {
+		return 130
+	}
+	v6.rep.IPComparison = report.CompareIPVersions(v4.rep, v6.rep)
+	showIPComparison(r.bus, v4.ep.IP, v6.ep.IP, v6.rep.IPComparison)
+	return max(code, v6.publish(ctx))
+}
+
+var ipMetricNames = map[string][2]string{
+	report.DirDownload: {"Download", "下载"},
+	report.DirUpload:   {"Upload", "上传"},
+	"latency":          {"Idle latency", "空闲延迟"},
+	"jitter":           {"Idle jitter", "空闲抖动"},
+}
+
+// showIPComparison prints the two versions' metrics side by side with the
+// change from IPv4 to IPv6, and points out the metrics far apart.
+func showIPComparison(bus *render.Bus, ip4, ip6 string, deltas []report.IPDelta) {
+	bus.Header(i18n.Text("IPv4 vs IPv6", "IPv4 对比 IPv6"))
+	for _, e := range []struct{ label, ip string }{{i18n.Text("IPv4 endpoint", "IPv4 节点"), ip4}, {i18n.Text("IPv6 endpoint", "IPv6 节点"), ip6}} {
+		if e.ip == "" {
+			e.ip = i18n.Text("none", "无")
+		}
+		bus.KV(e.label, e.ip)
+	}
+	if len(deltas) == 0 {
+		bus.Warn(i18n.Text("No metric was measured over both IP versions.", "没有在两种 IP 版本下都测得的指标。"))
+		return
+	}
+	bus.KV(i18n.Text("Metric", "指标"), fmt.Sprintf("%10s  %10s  %s", "IPv4", "IPv6", "IPv6 - IPv4"))
+	for _, d := range deltas {
+		n := ipMetricNames[d.Metric]
+		v4, v6, delta, unit := fmt.Sprintf("%.2f", d.IPv4), fmt.Sprintf("%.2f", d.IPv6), fmt.Sprintf("%+.2f", d.Delta), d.Unit
+		if unit == units.Mbps {
+			v4, v6, delta, unit = units.Value(d.IPv4, 2), units.Value(d.IPv6, 2), units.Value(d.Delta, 2), units.Label()
+			if d.Delta >= 0 {
+				delta = "+" + delta
+			}
+		}
+		bus.KV(i18n.Text(n[0], n[1]), fmt.Sprintf("%10s  %10s  %s %s (%+.1f%%)", v4, v6, delta, unit, d.DeltaPct))
+	}
+	for _, d := range deltas {
+		if d.Metric == "jitter" || math.Abs(d.DeltaPct) < ipGapPct || d.Unit == "ms" && math.Abs(d.Delta) < ipGapMs {
+			continue
+		}
+		// Higher is better for throughput, lower for latency.
+		better := 4
+		if (d.Delta > 0) == (d.Unit == "Mbps") {
+			better = 6
+		}
+		n := ipMetricNames[d.Metric]
+		bus.Warn(fmt.Sprintf(i18n.Text("%s is %.0f%% apart between IPv4 and IPv6, in favour of IPv%d: the two likely take different routes to the CDN.",
+			"%s在 IPv4 与 IPv6 之间相差 %.0f%%，IPv%d 更优：两者到 CDN 的路由可能不同。"), i18n.Text(n[0], n[1]), math.Abs(d.DeltaPct), better))
+	}
+}
@@ -0,0 +1,71 @@
+package runner
+
+import (
+	"fmt"
+	"math"
+	"net/url"
+	"strings"
+
+	"github.com/tsosunchia/iNetSpeed-CLI/internal/i18n"
+	"github.com/tsosunchia/iNetSpeed-CLI/internal/overhead"
+	"github.com/tsosunchia/iNetSpeed-CLI/internal/report"
+	"github.com/tsosunchia/iNetSpeed-CLI/internal/units"
+)
+
+// lineRate estimates the line rate behind the best download and upload and
+// shows it next to the goodput the rounds measured. The path MTU, when
+// --mtu found a smaller one, replaces the model's.
+func (r *run) lineRate() {
+	r.mu.Lock()
+	down, up := r.rep.Best(report.DirDownload), r.rep.Best(report.DirUpload)
+	h2 := false
+	for _, s := range r.rep.TLS {
+		h2 = h2 || s.ALPN == "h2"
+	}
+	mtu := 0
+	if m := r.rep.MTU; m != nil {
+		mtu = m.PathMTU
+		if mtu == 0 {
+			mtu = m.TCPMTU
+		}
+	}
+	r.mu.Unlock()
+	if down <= 0 && up <= 0 {
+		return
+	}
+
+	link := r.cfg.Link
+	if mtu > 0 && mtu < link.MTU {
+		link.MTU = mtu
+	}
+	ipv6 := strings.Contains(r.ep.IP, ":") || r.family == 6
+	stack := func(rawURL string) overhead.Stack {
+		u, err := url.Parse(rawURL)
+		return overhead.Stack{Link: link, IPv6: ipv6, TLS: err == nil && u.Scheme == "https", HTTP2: h2}
+	}
+	lr := &report.LineRate{Link: link.Name, LinkBytes: link.Bytes, MTU: link.MTU, IPv6: ipv6, HTTP2: h2}
+	var parts []string
+	if down > 0 {
+		st := stack(r.cfg.DLURL)
+		lr.DownloadFactor = round4(st.Factor())
+		lr.DownloadMbps = math.Round(st.LineRate(down)*10) / 10
+		parts = append(parts, "↓ "+units.Rate(lr.DownloadMbps, 0))
+	}
+	if up > 0 {
+		st := stack(r.cfg.ULURL)
+		lr.UploadFactor = round4(st.Factor())
+		lr.UploadMbps = math.Round(st.LineRate(up)*10) / 10
+		parts = append(parts, "↑ "+units.Rate(lr.UploadMbps, 0))
+	}
+	r.mu.Lock()
+	r.rep.LineRate = lr
+	r.mu.Unlock()
+
+	factor := max(lr.DownloadFactor, lr.UploadFactor)
+	r.bus.KV(i18n.Text("Line Rate (est.)", "线路速率（估算）"), strings.Join(parts, "  ")+
+		fmt.Sprintf(i18n.Text("  (%s, MTU %d, +%.1f%% overhead)", "  (%s，MTU %d，开销 +%.1f%%)"), link.Name, link.MTU, (factor-1)*100))
+	r.bus.Debug(i18n.Text("Goodput is the HTTP payload the rounds moved; the line rate adds the TCP/IP headers, TLS records, HTTP/2 frames and link framing it took.",
+		"有效吞吐为各轮传输的 HTTP 负载；线路速率另计其所需的 TCP/IP 头部、TLS 记录、HTTP/2 帧与链路帧开销。"))
+}
+
+func round4(v float64) float64 { return math.Round(v*10000) / 10000 }
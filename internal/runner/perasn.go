@@ -0,0 +1,106 @@
+package runner
+
+import (
+	"context"
+	"fmt"
+	"math"
+	"time"
+
+	"github.com/tsosunchia/iNetSpeed-CLI/internal/config"
+	"github.com/tsosunchia/iNetSpeed-CLI/internal/endpoint"
+	"github.com/tsosunchia/iNetSpeed-CLI/internal/i18n"
+	"github.com/tsosunchia/iNetSpeed-CLI/internal/latency"
+	"github.com/tsosunchia/iNetSpeed-CLI/internal/netx"
+	"github.com/tsosunchia/iNetSpeed-CLI/internal/report"
+	"github.com/tsosunchia/iNetSpeed-CLI/internal/transfer"
+	"github.com/tsosunchia/iNetSpeed-CLI/internal/units"
+)
+
+// The short test --per-asn runs against each AS: a few idle latency probes
+// and a multi-thread download of at most perASNTimeout.
+const (
+	perASNSamples = 5
+	perASNTimeout = 5
+)
+
+// appleASNs are the ASes of Apple's own CDN PoPs.
+var appleASNs = map[string]bool{"AS714": true, "AS6185": true}
+
+// perASN tests the first endpoint candidate of each AS the candidates span,
+// such as Apple's PoPs, a CDN partner and an ISP's embedded cache, and
+// compares them. The run goes on with the endpoint selected before.
+func (r *run) perASN(ctx context.Context) {
+	bus := r.bus
+	if len(r.ep.PerASN) < 2 {
+		bus.Info(i18n.Text("--per-asn: the endpoint candidates belong to a single AS, nothing to compare.", "--per-asn: 候选节点均属同一 AS，无可对比。"))
+		return
+	}
+	bus.Header(i18n.Text("Per-ASN Comparison", "按 AS 对比"))
+	bus.Info(fmt.Sprintf(i18n.Text("%d ASes, %d latency probes and a %ds download each", "%d 个 AS，每个测 %d 次延迟与 %d 秒下载"),
+		len(r.ep.PerASN), perASNSamples, perASNTimeout))
+	r.refreshURLs(ctx, time.Duration(perASNTimeout+2)*time.Second)
+	cfg := r.cfg.ForStage(config.StageDownloadMulti)
+	cfg.Timeout = min(cfg.Timeout, perASNTimeout)
+
+	var results []report.ASNResult
+	for _, ep := range r.ep.PerASN {
+		if ctx.Err() != nil {
+			return
+		}
+		if r.gate.Budget.Exhausted() {
+			bus.Warn(fmt.Sprintf(i18n.Text("Total data cap %s reached, round skipped.", "已达总流量上限 %s，跳过本轮。"), r.capName()))
+			break
+		}
+		opts := r.clientOptions()
+		opts.PinHost, opts.PinIP = r.cdnHost, ep.IP
+		client := netx.NewClient(opts)
+		idle := latency.MeasureIdleFunc(ctx, client, cfg.LatencyURL, cfg.RequestHeader(), perASNSamples, r.probeOpts(false), nil)
+		res := transfer.RunLimited(ctx, client, cfg, transfer.Download, cfg.Threads, cfg.DLURL, bus, r.gate, nil)
+		client.CloseIdleConnections()
+
+		r.mu.Lock()
+		r.totalData += res.TotalBytes
+		r.rep.DataUsedBytes = r.totalData
+		r.mu.Unlock()
+		a := report.ASNResult{
+			ASN:          endpoint.ASN(ep.Desc),
+			Name:         endpoint.ASName(ep.Desc),
+			IP:           ep.IP,
+			LatencyMs:    math.Round(idle.Median*100) / 100,
+			DownloadMbps: math.Round(res.Mbps*10) / 10,
+			Selected:     ep.IP == r.ep.IP,
+		}
+		results = append(results, a)
+		bus.KV(a.Name, fmt.Sprintf(i18n.Text("%s  %.1f ms  (%s)", "%s  %.1f 毫秒  (%s)"), units.Rate(a.DownloadMbps, 0), a.LatencyMs, a.IP))
+		if res.HadFault() {
+			bus.Warn(i18n.Text("Network issue detected during this round; result may be affected.", "本轮测试中出现网络故障，结果可能受影响。"))
+		}
+	}
+	r.mu.Lock()
+	r.rep.PerASN = results
+	r.mu.Unlock()
+	if best, apple := fastestASN(results); best != nil && apple != nil && best != apple && apple.DownloadMbps > 0 {
+		bus.Info(fmt.Sprintf(i18n.Text("%s outperforms Apple's own PoPs (%s) by %.0f%%.", "%s 比 Apple 自有节点（%s）快 %.0f%%。"),
+			best.Name, apple.Name, (best.DownloadMbps/apple.DownloadMbps-1)*100))
+	} else if best != nil {
+		bus.Info(fmt.Sprintf(i18n.Text("Fastest: %s", "最快: %s"), best.Name))
+	}
+}
+
+// fastestASN picks the fastest download of results and the fastest of
+// those in Apple's ASes; either is nil when there is none.
+func fastestASN(results []report.ASNResult) (best, apple *report.ASNResult) {
+	for i := range results {
+		a := &results[i]
+		if a.DownloadMbps <= 0 {
+			continue
+		}
+		if best == nil || a.DownloadMbps > best.DownloadMbps {
+			best = a
+		}
+		if appleASNs[a.ASN] && (apple == nil || a.DownloadMbps > apple.DownloadMbps) {
+			apple = a
+		}
+	}
+	return best, apple
+}
@@ -0,0 +1,62 @@
+package runner
+
+import (
+	"cmp"
+	"context"
+	"fmt"
+	"net/http"
+	"net/url"
+	"slices"
+
+	"github.com/tsosunchia/iNetSpeed-CLI/internal/config"
+	"github.com/tsosunchia/iNetSpeed-CLI/internal/i18n"
+	"github.com/tsosunchia/iNetSpeed-CLI/internal/netx"
+	"github.com/tsosunchia/iNetSpeed-CLI/internal/pac"
+	"github.com/tsosunchia/iNetSpeed-CLI/internal/report"
+)
+
+// loadPAC loads cfg.ProxyPAC into a copy of cfg.
+func loadPAC(ctx context.Context, cfg *config.Config) (*config.Config, error) {
+	script, err := pac.Load(ctx, cfg.ProxyPAC)
+	if err != nil {
+		return nil, err
+	}
+	if cfg.DNS != "" {
+		script.Resolve = pac.ResolveWith(netx.NewResolver(cfg.DNS))
+	}
+	c := *cfg
+	c.PAC = script
+	return &c, nil
+}
+
+// proxyFor is the test clients' proxy under PROXY_PAC. The first request a
+// stage sends to a host records the PAC entry it went through; the routes
+// are kept in stage order, then by host, as concurrent stages and workers
+// reach them in no fixed order.
+func (r *run) proxyFor(req *http.Request) (*url.URL, error) {
+	rt, err := r.cfg.PAC.Route(req.URL)
+	if err != nil {
+		return nil, err
+	}
+	route := report.ProxyRoute{Stage: stageName(req.Context()), Host: req.URL.Host, Proxy: rt.Entry}
+	r.mu.Lock()
+	seen := slices.Contains(r.rep.ProxyRoutes, route)
+	if !seen {
+		r.rep.ProxyRoutes = append(r.rep.ProxyRoutes, route)
+		slices.SortFunc(r.rep.ProxyRoutes, compareRoutes)
+	}
+	r.mu.Unlock()
+	if !seen {
+		r.bus.Debug(fmt.Sprintf(i18n.Text("PAC: %s via %s (%s)", "PAC: %s 经 %s（%s）"), route.Host, route.Proxy, route.Stage))
+	}
+	return rt.Proxy, nil
+}
+
+// compareRoutes orders proxy routes by the stage's place in the run, then
+// by host.
+func compareRoutes(a, b report.ProxyRoute) int {
+	if c := cmp.Compare(slices.Index(config.StageNames, a.Stage), slices.Index(config.StageNames, b.Stage)); c != 0 {
+		return c
+	}
+	return cmp.Compare(a.Host, b.Host)
+}
@@ -0,0 +1,104 @@
+package runner
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"strings"
+
+	"github.com/tsosunchia/iNetSpeed-CLI/internal/config"
+	"github.com/tsosunchia/iNetSpeed-CLI/internal/i18n"
+	"github.com/tsosunchia/iNetSpeed-CLI/internal/remote"
+	"github.com/tsosunchia/iNetSpeed-CLI/internal/render"
+	"github.com/tsosunchia/iNetSpeed-CLI/internal/report"
+	"github.com/tsosunchia/iNetSpeed-CLI/internal/units"
+)
+
+// Remote runs the `remote` command: the test runs on each host in turn and
+// the results are summarized together. Exit codes: 0 every host succeeded,
+// 2 a host failed or was degraded, 130 interrupted.
+func Remote(ctx context.Context, cfg *config.Config, bus *render.Bus) int {
+	bus.Line()
+	bus.Banner("\u26a1 iNetSpeed-CLI")
+	bus.Info(fmt.Sprintf(i18n.Text("Remote hosts: %s", "远程主机: %s"), strings.Join(cfg.RemoteHosts, ", ")))
+	bus.Line()
+
+	opts := remote.Options{
+		SSH:    strings.Fields(cfg.SSHCommand),
+		Binary: cfg.RemoteBinary,
+		// Remote output is shown here, so it follows the local language.
+		Args: append([]string{"--lang=" + i18n.Lang()}, cfg.RemoteArgs...),
+	}
+	var results []remote.Result
+	for _, host := range cfg.RemoteHosts {
+		if ctx.Err() != nil {
+			break
+		}
+		bus.Header(fmt.Sprintf(i18n.Text("Remote: %s", "远程: %s"), host))
+		res := remote.Run(ctx, host, opts, func(ev remote.Event) { forwardEvent(bus, host, ev) })
+		if res.Err != nil && ctx.Err() == nil {
+			bus.Warn(fmt.Sprintf(i18n.Text("%s failed: %v", "%s 失败: %v"), host, res.Err))
+		}
+		results = append(results, res)
+	}
+	if ctx.Err() != nil {
+		bus.Warn(i18n.Text("Interrupted.", "已中断。"))
+		return 130
+	}
+
+	bus.Header(i18n.Text("Remote Summary", "远程汇总"))
+	ok := true
+	for _, res := range results {
+		rep := res.Report
+		if rep == nil {
+			ok = false
+			bus.KV(res.Host, i18n.Text("no result", "无结果"))
+			continue
+		}
+		line := fmt.Sprintf(i18n.Text("down %s  up %s  latency %.1f ms", "下载 %s  上传 %s  延迟 %.1f 毫秒"),
+			units.Rate(rep.Best(report.DirDownload), 1), units.Rate(rep.Best(report.DirUpload), 1), rep.IdleLatency.MedianMs)
+		if rep.Degraded || res.Exit != 0 {
+			ok = false
+			line += "  " + fmt.Sprintf(i18n.Text("(exit %d)", "（退出码 %d）"), res.Exit)
+		}
+		bus.KV(res.Host, line)
+	}
+	bus.Line()
+	if !ok {
+		return 2
+	}
+	return 0
+}
+
+// forwardEvent shows a remote event locally. Framing (banner, lines) and
+// data-only events are left out; progress and interval lines are labelled
+// with the host.
+func forwardEvent(bus *render.Bus, host string, ev remote.Event) {
+	switch ev.Kind {
+	case "header":
+		bus.Header(ev.Value)
+	case "info":
+		bus.Info(ev.Value)
+	case "warn", "fatal":
+		bus.Warn(ev.Value)
+	case "result":
+		bus.Result(ev.Value)
+	case "kv":
+		bus.KV(ev.Label, ev.Value)
+	case "progress":
+		// Keep the completion ProgressETA attached, if any.
+		var data map[string]any
+		json.Unmarshal(ev.Data, &data)
+		bus.Send(render.Event{Kind: render.KindProgress, Label: host + " " + ev.Label, Value: ev.Value, Data: data})
+	case "interval":
+		var data map[string]any
+		json.Unmarshal(ev.Data, &data)
+		label := ev.Label
+		if label != "" {
+			label = host + " " + label
+		}
+		bus.Interval(label, ev.Value, data)
+	case "debug":
+		bus.Debug(ev.Value)
+	}
+}
@@ -0,0 +1,125 @@
+package runner
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"github.com/tsosunchia/iNetSpeed-CLI/internal/i18n"
+	"github.com/tsosunchia/iNetSpeed-CLI/internal/render"
+	"github.com/tsosunchia/iNetSpeed-CLI/internal/report"
+	"github.com/tsosunchia/iNetSpeed-CLI/internal/units"
+)
+
+// repeat runs the benchmark cfg.Runs times, or until interrupted when that
+// is 0, starting with r, pausing cfg.Cooldown between runs so one run's
+// queues drain before the next. Each run's report is published as usual;
+// the last one also carries every metric's spread over all runs. The exit
+// code is the worst of the runs'. Signals steer the series while it runs;
+// see control.
+func (r *run) repeat(ctx context.Context) int {
+	ctl := r.control(ctx)
+	defer ctl.stop()
+	if r.cfg.API != "" {
+		if err := ctl.serveAPI(r.cfg.API); err != nil {
+			r.bus.Fatal(fmt.Sprintf(i18n.Text("Cannot start the API: %v", "无法启动 API: %v"), err))
+			return 1
+		}
+	}
+	code := 0
+	for {
+		r.bus.Line()
+		if r.rep.Runs == 0 {
+			r.bus.Banner(fmt.Sprintf(i18n.Text("Run %d", "第 %d 次测速"), r.rep.Run))
+		} else {
+			r.bus.Banner(fmt.Sprintf(i18n.Text("Run %d/%d", "第 %d/%d 次测速"), r.rep.Run, r.rep.Runs))
+		}
+		if !r.measure(ctx) {
+			return 130
+		}
+		reps := ctl.finished(r.rep)
+		if r.rep.Run == r.rep.Runs {
+			r.rep.RunStats = report.RunStats(reps)
+			showRunStats(r.bus, len(reps), r.rep.RunStats)
+			return max(code, r.publish(ctx))
+		}
+		code = max(code, r.publish(ctx))
+		ctl.publish(r.rep)
+
+		if !r.cooldown(ctx, ctl.runNow) {
+			return 130
+		}
+		if cfg := ctl.reloaded(); cfg != nil {
+			r.cfg = cfg
+		}
+		r = r.next()
+	}
+}
+
+// cooldown pauses cfg.Cooldown before the next run, or until wake, returning
+// false when interrupted.
+func (r *run) cooldown(ctx context.Context, wake <-chan struct{}) bool {
+	if r.cfg.Cooldown <= 0 {
+		return true
+	}
+	r.bus.Info(fmt.Sprintf(i18n.Text("Cooling down for %v before the next run.", "等待 %v 后开始下一次测速。"), r.cfg.Cooldown))
+	select {
+	case <-ctx.Done():
+		r.bus.Warn(i18n.Text("Interrupted.", "已中断。"))
+		return false
+	case <-wake:
+		return true
+	case <-time.After(r.cfg.Cooldown):
+		return true
+	}
+}
+
+// next prepares the following run of --runs. On top of what successor
+// keeps, it stays on the endpoint and the cap MAX=auto chose.
+func (r *run) next() *run {
+	n := r.successor()
+	n.rep.Run = r.rep.Run + 1
+	n.ep = r.ep
+	n.rep.Config.AutoMbps = r.rep.Config.AutoMbps
+	if n.ep.IP != "" && n.cdnHost != "" {
+		n.buildClients()
+	}
+	return n
+}
+
+// successor starts a run after r that keeps what describes the test rather
+// than its results: the discovered or possibly renewed hook URLs, the probe
+// identity, the baseline and the data budget.
+func (r *run) successor() *run {
+	n := newRun(r.cfg, r.bus, r.isTTY)
+	n.disc = r.disc
+	n.urlExpires = r.urlExpires
+	n.baseline = r.baseline
+	n.gate = r.gate
+	n.probe = r.probe
+	n.rep.Probe = r.rep.Probe
+	return n
+}
+
+func showRunStats(bus *render.Bus, runs int, stats []report.MetricStats) {
+	bus.Header(fmt.Sprintf(i18n.Text("Statistics over %d runs", "%d 次测速统计"), runs))
+	if len(stats) == 0 {
+		bus.Warn(i18n.Text("No metric was measured.", "未测得任何指标。"))
+		return
+	}
+	names := map[string]string{
+		report.DirDownload: i18n.Text("Download", "下载"),
+		report.DirUpload:   i18n.Text("Upload", "上传"),
+		"latency":          i18n.Text("Idle latency", "空闲延迟"),
+		"jitter":           i18n.Text("Idle jitter", "空闲抖动"),
+	}
+	for _, s := range stats {
+		mean, median, stddev, unit := fmt.Sprintf("%.2f", s.Mean), fmt.Sprintf("%.2f", s.Median), fmt.Sprintf("%.2f", s.StdDev), s.Unit
+		if unit == units.Mbps {
+			mean, median, stddev, unit = units.Value(s.Mean, 2), units.Value(s.Median, 2), units.Value(s.StdDev, 2), units.Label()
+		}
+		bus.KV(names[s.Metric], fmt.Sprintf(i18n.Text("mean %s, median %s, stddev %s %s (CV %.1f%%, n=%d)",
+			"均值 %s，中位数 %s，标准差 %s %s（变异系数 %.1f%%，n=%d）"),
+			mean, median, stddev, unit, s.CV*100, s.N))
+	}
+}
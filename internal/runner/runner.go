@@ -2,129 +2,1835 @@ package runner
 
 import (
 	"context"
+	"crypto/tls"
+	"errors"
 	"fmt"
+	"io"
+	"maps"
+	"math"
+	"net"
+	"net/http"
+	"net/url"
+	"os"
+	"runtime"
+	"slices"
+	"strings"
+	"sync"
 	"time"
 
+	"github.com/tsosunchia/iNetSpeed-CLI/internal/chart"
 	"github.com/tsosunchia/iNetSpeed-CLI/internal/config"
+	"github.com/tsosunchia/iNetSpeed-CLI/internal/discover"
 	"github.com/tsosunchia/iNetSpeed-CLI/internal/endpoint"
+	"github.com/tsosunchia/iNetSpeed-CLI/internal/histogram"
+	"github.com/tsosunchia/iNetSpeed-CLI/internal/history"
 	"github.com/tsosunchia/iNetSpeed-CLI/internal/i18n"
 	"github.com/tsosunchia/iNetSpeed-CLI/internal/latency"
 	"github.com/tsosunchia/iNetSpeed-CLI/internal/netx"
+	"github.com/tsosunchia/iNetSpeed-CLI/internal/ping"
+	"github.com/tsosunchia/iNetSpeed-CLI/internal/pmtu"
+	"github.com/tsosunchia/iNetSpeed-CLI/internal/probe"
+	"github.com/tsosunchia/iNetSpeed-CLI/internal/ranking"
+	"github.com/tsosunchia/iNetSpeed-CLI/internal/ratelimit"
 	"github.com/tsosunchia/iNetSpeed-CLI/internal/render"
+	"github.com/tsosunchia/iNetSpeed-CLI/internal/report"
+	"github.com/tsosunchia/iNetSpeed-CLI/internal/shaping"
+	"github.com/tsosunchia/iNetSpeed-CLI/internal/share"
+	"github.com/tsosunchia/iNetSpeed-CLI/internal/simulate"
+	"github.com/tsosunchia/iNetSpeed-CLI/internal/tcpinfo"
 	"github.com/tsosunchia/iNetSpeed-CLI/internal/transfer"
+	"github.com/tsosunchia/iNetSpeed-CLI/internal/udpprobe"
+	"github.com/tsosunchia/iNetSpeed-CLI/internal/units"
 )
 
-// Run executes the full speedtest pipeline. Exit codes: 0 success, 2 degraded, 130 interrupted.
+// Run executes the full speedtest pipeline. Exit codes: 0 success, 1 unreadable
+// baseline, 2 degraded, 3 regression found by compare, 9-15 failed
+// assertions (see exitAssert), 130 interrupted.
 func Run(ctx context.Context, cfg *config.Config, bus *render.Bus, isTTY bool) int {
-	degraded := false
-
-	bus.Line()
-	bus.Banner("\u26a1 iNetSpeed-CLI")
-	bus.Info(i18n.Text("Config:  ", "配置:  ") + cfg.Summary())
-	bus.Line()
+	var baseline *report.Report
+	if cfg.Compare {
+		var err error
+		if baseline, err = loadBaseline(cfg); err != nil {
+			bus.Fatal(fmt.Sprintf(i18n.Text("Cannot read baseline: %v", "无法读取基线: %v"), err))
+			return 1
+		}
+	}
+	cfg, urlExpires, ok := prepare(ctx, cfg, bus)
+	if !ok {
+		return 1
+	}
+	if cfg.Simulate {
+		srv := simulate.Start(cfg.Sim)
+		defer srv.Close()
+		cfg = simulatedConfig(cfg, srv)
+	}
+	r := newRun(cfg, bus, isTTY)
+	r.baseline = baseline
+	r.urlExpires = urlExpires
+	r.banner()
 
 	bus.Header(i18n.Text("Environment Check", "环境检查"))
 	bus.Info(i18n.Text("Go binary \u2014 no external dependencies required.", "Go 二进制程序 — 无需外部依赖。"))
+	if cfg.Simulate {
+		bus.Warn(simulationNote(cfg))
+	}
 
 	if ctx.Err() != nil {
 		bus.Warn(i18n.Text("Interrupted.", "已中断。"))
 		return 130
 	}
 
-	cdnHost := endpoint.HostFromURL(cfg.DLURL)
-	ep := endpoint.Choose(ctx, cdnHost, bus, isTTY)
+	if cfg.CompareIPVersions {
+		return r.compareIPVersions(ctx)
+	}
+	if cfg.Series() {
+		return r.repeat(ctx)
+	}
+	if !r.measure(ctx) {
+		return 130
+	}
+	return r.publish(ctx)
+}
+
+// prepare applies the URL hook and loads the PAC file, returning the
+// configuration to go on with and when the hook's URLs expire. On failure
+// it reports why and returns false.
+func prepare(ctx context.Context, cfg *config.Config, bus *render.Bus) (*config.Config, time.Time, bool) {
+	// The emulator serves its own URLs; a hook would only be overridden.
+	var urlExpires time.Time
+	if cfg.URLHook != "" && !cfg.Simulate {
+		c := *cfg
+		cfg = &c
+		var err error
+		if urlExpires, err = applyURLHook(ctx, cfg); err != nil {
+			bus.Fatal(fmt.Sprintf(i18n.Text("URL hook failed: %v", "URL 钩子调用失败: %v"), err))
+			return nil, urlExpires, false
+		}
+	}
+	if cfg.ProxyPAC != "" {
+		var err error
+		if cfg, err = loadPAC(ctx, cfg); err != nil {
+			bus.Fatal(fmt.Sprintf(i18n.Text("Cannot load PAC file: %v", "无法加载 PAC 文件: %v"), err))
+			return nil, urlExpires, false
+		}
+	}
+	return cfg, urlExpires, true
+}
 
-	clientOpts := netx.Options{
-		Timeout: time.Duration(cfg.Timeout+5) * time.Second,
+// simulationNote tells that cfg, a simulated configuration, tests against
+// the emulator.
+func simulationNote(cfg *config.Config) string {
+	return fmt.Sprintf(i18n.Text("Simulation mode: built-in CDN emulator at %s (%s, %v latency). Results are not real measurements.",
+		"模拟模式：使用内置 CDN 模拟器 %s（%s，延迟 %v），结果并非真实测量。"),
+		endpoint.HostFromURL(cfg.DLURL), simBandwidth(cfg.Sim.Bandwidth), cfg.Sim.Latency)
+}
+
+// banner opens the output with the configuration the run goes by.
+func (r *run) banner() {
+	cfg, bus := r.cfg, r.bus
+	bus.Line()
+	bus.Banner("\u26a1 iNetSpeed-CLI")
+	bus.Info(i18n.Text("Config:  ", "配置:  ") + cfg.Summary())
+	r.setProbe()
+	if r.probe.ID != "" {
+		bus.Info(i18n.Text("Probe:   ", "探针:  ") + probeLabel(r.probe))
+	}
+	if len(cfg.Tags) > 0 {
+		bus.Info(i18n.Text("Tags:    ", "标签:  ") + tagList(cfg.Tags))
 	}
-	if ep.IP != "" && cdnHost != "" {
-		clientOpts.PinHost = cdnHost
-		clientOpts.PinIP = ep.IP
+	bus.Debug(i18n.Text("Run ID:  ", "测速 ID: ") + r.rep.ID)
+	if cfg.URLHook != "" && !cfg.Simulate {
+		bus.Info(urlsRenewed(r.urlExpires))
 	}
-	client := netx.NewClient(clientOpts)
+	if cfg.PAC != nil {
+		bus.Info(i18n.Text("Proxy:   PAC ", "代理:  PAC ") + config.Redact(cfg.ProxyPAC))
+	}
+	bus.Line()
+}
 
+// measure executes the stage graph, returning false when interrupted.
+func (r *run) measure(ctx context.Context) bool {
+	err := r.graph().Execute(ctx)
 	if ctx.Err() != nil {
-		bus.Warn(i18n.Text("Interrupted.", "已中断。"))
-		return 130
+		r.bus.Warn(i18n.Text("Interrupted.", "已中断。"))
+		return false
+	}
+	if err != nil {
+		r.bus.Warn(fmt.Sprintf(i18n.Text("Stage failed: %v", "阶段失败: %v"), err))
+		r.markDegraded()
+	}
+	return true
+}
+
+// publish emits, records and pushes the finished report and returns the
+// run's exit code.
+func (r *run) publish(ctx context.Context) int {
+	r.rep.Degraded = r.isDegraded()
+	r.bus.Report(r.rep)
+	if r.cfg.History != "" {
+		if err := history.Append(r.cfg.History, r.rep); err != nil {
+			r.bus.Warn(fmt.Sprintf(i18n.Text("Could not record history: %v", "无法写入历史记录: %v"), err))
+		}
 	}
+	r.pushMetrics(ctx)
 
-	if !gatherInfo(ctx, bus, cdnHost, ep) {
-		degraded = true
+	if r.cfg.Quiet {
+		fmt.Fprintln(stdout, r.rep.QuietLine())
 	}
 
-	if ctx.Err() != nil {
-		bus.Warn(i18n.Text("Interrupted.", "已中断。"))
-		return 130
+	if r.assertFailed != 0 {
+		return exitAssert + r.assertFailed
+	}
+	if r.regressed {
+		return 3
+	}
+	if r.isDegraded() {
+		return 2
+	}
+	return 0
+}
+
+// setProbe resolves the probe identity and stamps it on the report. A broken
+// state file costs the stored identity, not the run.
+func (r *run) setProbe() {
+	id := probe.Identity{ID: r.cfg.ProbeID, Name: r.cfg.ProbeName}
+	if r.cfg.ProbeState != "" {
+		var err error
+		if id, err = probe.Resolve(r.cfg.ProbeState, r.cfg.ProbeID, r.cfg.ProbeName, false); err != nil {
+			r.bus.Warn(fmt.Sprintf(i18n.Text("Probe state: %v", "探针状态: %v"), err))
+			id = probe.Identity{ID: r.cfg.ProbeID, Name: r.cfg.ProbeName}
+		}
+	}
+	r.probe = id
+	if id.ID != "" {
+		r.rep.Probe = &report.Probe{ID: id.ID, Name: id.Name}
+	}
+}
+
+// tagList writes tags as key=value pairs in key order.
+func tagList(tags map[string]string) string {
+	keys := slices.Sorted(maps.Keys(tags))
+	for i, k := range keys {
+		keys[i] = k + "=" + tags[k]
+	}
+	return strings.Join(keys, ", ")
+}
+
+func probeLabel(id probe.Identity) string {
+	if id.Name == "" {
+		return id.ID
+	}
+	return id.Name + " (" + id.ID + ")"
+}
+
+// Note runs the `note` command: it appends the annotation to the history
+// file. Exit codes: 0 recorded, 1 failed.
+func Note(cfg *config.Config, bus *render.Bus) int {
+	n := history.Note{Time: time.Now().UTC(), Text: cfg.NoteText}
+	if err := history.AppendNote(cfg.History, n); err != nil {
+		bus.Fatal(fmt.Sprintf(i18n.Text("Could not record note: %v", "无法记录备注: %v"), err))
+		return 1
+	}
+	bus.Info(i18n.Text("Note recorded in ", "备注已记录至 ") + cfg.History)
+	return 0
+}
+
+// Register runs the `register` command: it enrolls the probe with the
+// collector and stores the returned token. Exit codes: 0 registered, 1 failed.
+func Register(ctx context.Context, cfg *config.Config, bus *render.Bus) int {
+	bus.Line()
+	bus.Banner("\u26a1 iNetSpeed-CLI")
+	bus.Header(i18n.Text("Probe Registration", "探针注册"))
+	id, err := probe.Resolve(cfg.ProbeState, cfg.ProbeID, cfg.ProbeName, true)
+	if err != nil {
+		bus.Fatal(fmt.Sprintf(i18n.Text("Probe state: %v", "探针状态: %v"), err))
+		return 1
+	}
+	bus.KV(i18n.Text("Probe ID", "探针标识"), id.ID)
+	if id.Name != "" {
+		bus.KV(i18n.Text("Probe Name", "探针名称"), id.Name)
+	}
+	bus.KV(i18n.Text("Collector", "收集器"), cfg.Collector)
+	if id, err = probe.Register(ctx, cfg.Collector, cfg.RegisterToken, report.Version, id); err != nil {
+		bus.Fatal(fmt.Sprintf(i18n.Text("Registration failed: %v", "注册失败: %v"), err))
+		return 1
+	}
+	if err := probe.Save(cfg.ProbeState, id); err != nil {
+		bus.Fatal(fmt.Sprintf(i18n.Text("Could not save probe token: %v", "无法保存探针令牌: %v"), err))
+		return 1
+	}
+	bus.Info(i18n.Text("Registered; token saved to ", "注册成功，令牌已保存至 ") + cfg.ProbeState)
+	bus.Line()
+	return 0
+}
+
+// loadBaseline returns the report to compare against, or nil when the
+// history holds no previous run yet.
+func loadBaseline(cfg *config.Config) (*report.Report, error) {
+	if cfg.Baseline != "" {
+		return history.Load(cfg.Baseline)
+	}
+	rep, err := history.Latest(cfg.History, cfg.Simulate)
+	if errors.Is(err, history.ErrEmpty) {
+		return nil, nil
+	}
+	return rep, err
+}
+
+// stdout receives the --quiet result line; tests replace it.
+var stdout io.Writer = os.Stdout
+
+// simulatedConfig returns a copy of cfg aimed at the emulator.
+func simulatedConfig(cfg *config.Config, srv *simulate.Server) *config.Config {
+	c := *cfg
+	c.DLURL = srv.DownloadURL()
+	c.ULURL = srv.UploadURL()
+	c.LatencyURL = srv.LatencyURL()
+	c.DLFallbacks, c.ULFallbacks, c.LatencyFallbacks = nil, nil, nil
+	return &c
+}
+
+func simBandwidth(bits int64) string {
+	if bits <= 0 {
+		return i18n.Text("unlimited", "不限速")
+	}
+	return fmt.Sprintf("%g Mbps", float64(bits)/1_000_000)
+}
+
+// run holds the state shared by the stages of a single Run.
+type run struct {
+	cfg   *config.Config
+	bus   *render.Bus
+	isTTY bool
+	rep   *report.Report
+
+	cdnHost string
+	ep      endpoint.Endpoint
+	family  int                     // 4 or 6 to pin the endpoint to that IP version; 0 for either
+	client  *http.Client            // netx.ModeAuto; used outside the multi-thread rounds
+	clients map[string]*http.Client // by netx mode
+	fresh   *http.Client            // latency probes on a new connection each; nil under LATENCY_CONN=reused
+	idle    latency.Stats
+	after   latency.Stats
+	gate    *ratelimit.Gate
+	tracker *tcpinfo.Tracker
+	targets []*target // latency targets that answered while idle
+
+	urlExpires   time.Time // when the URL hook's URLs expire; zero for never
+	disc         *discover.Config
+	baseline     *report.Report
+	regressed    bool
+	assertFailed int // assert*Fail bits
+	probe        probe.Identity
+	wire         *netx.Counter // bytes on the wire of every test connection
+	resolver     *net.Resolver // --dns; nil is the system resolver
+	load         load          // transfer flows behind the loaded-latency samples
+
+	mu        sync.Mutex
+	totalData int64
+	degraded  bool
+	tlsConns  map[*tls.ConnectionState]bool // connections counted in rep.TLS
+}
+
+func newRun(cfg *config.Config, bus *render.Bus, isTTY bool) *run {
+	rep := report.New()
+	rep.ID, rep.Tags = probe.NewID(), cfg.Tags
+	rep.Config = report.ConfigInfo{
+		DLURL:         config.Redact(cfg.DLURL),
+		ULURL:         config.Redact(cfg.ULURL),
+		LatencyURL:    config.Redact(cfg.LatencyURL),
+		Max:           cfg.Max,
+		TimeoutSec:    cfg.Timeout,
+		Threads:       cfg.Threads,
+		LatencyCount:  cfg.LatencyCount,
+		UploadPayload: cfg.UploadPayload,
+		LimitRate:     cfg.LimitRate,
+		MaxTotal:      cfg.MaxTotal,
+		Insecure:      cfg.Insecure,
+		DSCP:          cfg.DSCP,
+		TOS:           cfg.TOS,
+	}
+	if cfg.Units != "" {
+		rep.Units.Display = cfg.Units
+	}
+	rep.Config.SaturationWindowSec = cfg.SaturationWindow.Seconds()
+	rep.Config.DataBudget = cfg.DataBudget
+	if cfg.ConnectionMode != config.ConnAuto {
+		rep.Config.ConnMode = cfg.ConnectionMode
+	}
+	if cfg.DownloadMode == config.DownloadRange {
+		rep.Config.DownloadMode = cfg.DownloadMode
+	}
+	rep.Config.Phases = cfg.Phases
+	rep.Config.Preset = cfg.Preset
+	rep.Config.Region = cfg.Region.Name
+	rep.Config.LatencyIntervalMs = float64(cfg.LatencyInterval.Microseconds()) / 1000
+	rep.Config.LatencyProbeSize = cfg.LatencyProbeSize
+	if cfg.LatencyConn != config.LatencyReused {
+		rep.Config.LatencyConn = cfg.LatencyConn
+	}
+	if cfg.PAC != nil {
+		rep.Config.ProxyPAC = config.Redact(cfg.ProxyPAC)
+	}
+	rep.RateCapped = cfg.RateBits > 0
+	if cfg.Series() {
+		rep.Run, rep.Runs = 1, cfg.Runs
+	}
+	rep.Simulated = cfg.Simulate
+	_, capBytes := cfg.TotalCap()
+	r := &run{
+		cfg:     cfg,
+		bus:     bus,
+		isTTY:   isTTY,
+		rep:     rep,
+		cdnHost: endpoint.HostFromURL(cfg.DLURL),
+		gate: &ratelimit.Gate{
+			Rate:   ratelimit.NewBucket(cfg.RateBits),
+			Budget: ratelimit.NewBudget(capBytes),
+		},
+		wire:     new(netx.Counter),
+		resolver: netx.NewResolver(cfg.DNS),
+	}
+	if cfg.TCPInfo {
+		r.tracker = tcpinfo.NewTracker()
+	}
+	r.buildClients()
+	return r
+}
+
+// connModes maps CONNECTION_MODE to the netx modes the multi-thread rounds
+// run under, in order.
+func connModes(mode string) []string {
+	switch mode {
+	case config.ConnMulti:
+		return []string{netx.ModeMulti}
+	case config.ConnSingleH2:
+		return []string{netx.ModeSingleH2}
+	case config.ConnBoth:
+		return []string{netx.ModeMulti, netx.ModeSingleH2}
+	}
+	return []string{netx.ModeAuto}
+}
+
+// buildClients creates one HTTP client per connection mode in use. Separate
+// clients keep each mode's connections out of the other's pool.
+func (r *run) buildClients() {
+	r.clients = map[string]*http.Client{}
+	for _, mode := range append([]string{netx.ModeAuto}, connModes(r.cfg.ConnectionMode)...) {
+		opts := r.clientOptions()
+		opts.Mode = mode
+		r.clients[mode] = netx.NewClient(opts)
+	}
+	r.client = r.clients[netx.ModeAuto]
+	if r.cfg.LatencyConn == config.LatencyNew || r.cfg.LatencyConn == config.LatencyBoth {
+		// A transport of its own, so the probes closing their connections
+		// leave the test's pooled ones alone, and untracked, so they stay
+		// out of TCP_INFO.
+		opts := r.clientOptions()
+		opts.Tracker = nil
+		r.fresh = netx.NewClient(opts)
+	}
+}
+
+func (r *run) clientOptions() netx.Options {
+	opts := netx.Options{
+		Timeout: time.Duration(r.cfg.MaxTimeout()+5) * time.Second,
+		Tracker: r.tracker,
+		TLS:     r.cfg.TLS,
+		TOS:     r.cfg.TOS,
+		Observe: r.observeResponse,
+		Counter: r.wire,
+	}
+	opts.Resolver = r.resolver
+	if r.cfg.PAC != nil {
+		opts.Proxy = r.proxyFor
+	}
+	if r.cfg.Sign != nil {
+		opts.Sign = r.cfg.Sign.Apply
+	}
+	if r.ep.IP != "" && r.cdnHost != "" {
+		opts.PinHost = r.cdnHost
+		opts.PinIP = r.ep.IP
 	}
+	return opts
+}
+
+func (r *run) markDegraded() {
+	r.mu.Lock()
+	r.degraded = true
+	r.mu.Unlock()
+}
+
+func (r *run) isDegraded() bool {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	return r.degraded
+}
+
+// graph declares the run pipeline. Stage order here is the output order.
+func (r *run) graph() *Graph {
+	g := NewGraph()
+	g.Events = r.bus
+	// The geo lookups send a few small requests and mostly wait on ip-api,
+	// so they run alongside the idle latency probes.
+	parallel := map[string]bool{config.StageInfo: true}
+	add := func(name string, needs []string, enabled bool, fn func(context.Context) error) {
+		g.Add(Stage{
+			Name:     name,
+			Needs:    needs,
+			Parallel: parallel[name],
+			Timeout:  r.cfg.StageTimeouts[name],
+			Disabled: !enabled || !r.cfg.StageEnabled(name),
+			Run:      fn,
+		})
+	}
+	// The URL check comes before any stage that uses the test URLs.
+	ep := []string{config.StageEndpoint, config.StageURLCheck}
+	sized := []string{config.StageEndpoint, config.StageAutoMax}
+	transfers := []string{config.StageDownloadSingle, config.StageDownloadMulti,
+		config.StageUploadSingle, config.StageUploadMulti}
+	loads := append([]string{config.StageUploadVerify, config.StageBidirectional, config.StageThreadRamp}, transfers...)
+	rounds := append([]string{config.StageIdleLatency, config.StageICMPLatency, config.StageTargets, config.StageMTU, config.StageUDPLatency, config.StageRequestRate, config.StageCacheCheck, config.StageColdStart, config.StageIdleAfter}, loads...)
+
+	// The emulator is local: there is no endpoint to pick and no geo info.
+	online := !r.cfg.Simulate
+	add(config.StageDiscover, nil, r.cfg.Discover, r.discoverURLs)
+	add(config.StageEndpoint, []string{config.StageDiscover}, online, r.selectEndpoint)
+	add(config.StageURLCheck, []string{config.StageEndpoint}, r.cfg.HasFallbacks(), r.urlCheck)
+	add(config.StageInfo, ep, online, r.info)
+	add(config.StageSysInfo, []string{config.StageInfo}, r.cfg.SysInfo, r.sysInfo)
+	add(config.StageIdleLatency, ep, true, r.idleLatency)
+	add(config.StageICMPLatency, []string{config.StageEndpoint, config.StageIdleLatency}, r.cfg.ICMP, r.icmpLatency)
+	add(config.StageTargets, []string{config.StageIdleLatency}, len(r.cfg.LatencyTargets) > 0, r.latencyTargets)
+	add(config.StageMTU, []string{config.StageEndpoint, config.StageIdleLatency}, r.cfg.MTU, r.mtu)
+	add(config.StageUDPLatency, ep, r.cfg.UDPEcho != "", r.udpLatency)
+	add(config.StageRequestRate, ep, r.cfg.RequestRate, r.requestRate)
+	add(config.StageCacheCheck, ep, r.cfg.CacheCheck, r.cacheCheck)
+	add(config.StageColdStart, ep, r.cfg.ColdStart, r.coldStart)
+	add(config.StageAutoMax, ep, r.cfg.Max == config.MaxAuto, r.autoMax)
+	add(config.StageDownloadSingle, sized, true, r.round(config.StageDownloadSingle, transfer.Download,
+		"Download (single thread)", "下载（单线程）"))
+	add(config.StageDownloadMulti, sized, true, r.round(config.StageDownloadMulti, transfer.Download,
+		"Download (multi-thread)", "下载（多线程）"))
+	add(config.StageUploadSingle, sized, true, r.round(config.StageUploadSingle, transfer.Upload,
+		"Upload (single thread)", "上传（单线程）"))
+	add(config.StageUploadMulti, sized, true, r.round(config.StageUploadMulti, transfer.Upload,
+		"Upload (multi-thread)", "上传（多线程）"))
+	add(config.StageUploadVerify, []string{config.StageUploadSingle, config.StageUploadMulti}, r.cfg.VerifyUpload, r.uploadVerify)
+	// The solo rounds come first: they are what --bidi is measured against.
+	add(config.StageBidirectional, transfers, r.cfg.Bidi, r.bidirectional)
+	add(config.StageThreadRamp, append([]string{config.StageBidirectional}, transfers...), len(r.cfg.ThreadSchedule) > 0, r.threadRamp)
+	add(config.StageIdleAfter, loads, true, r.idleLatencyAfter)
+	add(config.StageSummary, rounds, true, r.summary)
+	// Simulated results would rank against real users; leave them out.
+	add(config.StageRanking, []string{config.StageInfo, config.StageSummary}, online, r.ranking)
+	add(config.StageCompare, []string{config.StageSummary}, r.cfg.Compare, r.compare)
+	add(config.StageAssert, []string{config.StageSummary}, r.cfg.Asserting(), r.assert)
+	// With --runs, only the last run, which carries the statistics, is shared;
+	// with --compare-ip-versions, only the IPv6 one, which carries the delta.
+	sharing := (r.cfg.Share || r.cfg.ShareImage != "" || r.cfg.Scatter != "" || r.cfg.ReportHTML != "") && r.rep.Run == r.rep.Runs && r.family != 4
+	add(config.StageShare, []string{config.StageSummary}, sharing, func(ctx context.Context) error {
+		r.rep.Degraded = r.isDegraded()
+		if !shareResults(ctx, r.cfg, r.bus, r.rep, r.probe) {
+			r.markDegraded()
+		}
+		return nil
+	})
+	return g
+}
+
+// info shows where the client and the endpoint are. It runs beside other
+// stages, so it fills a report of its own and copies the peers over under
+// r.mu.
+func (r *run) info(ctx context.Context) error {
+	var peers report.Report
+	ok := gatherInfo(ctx, stageBus(ctx, r.bus), r.cdnHost, r.ep, &peers, r.cfg.NoGeo, r.resolver)
+	r.mu.Lock()
+	r.rep.Client, r.rep.Server = peers.Client, peers.Server
+	r.degraded = r.degraded || !ok
+	r.mu.Unlock()
+	return nil
+}
+
+func (r *run) selectEndpoint(ctx context.Context) error {
+	if r.ep.IP != "" {
+		// A later run of --runs stays on the first run's endpoint, so the
+		// runs measure the same path.
+		r.bus.Header(i18n.Text("Endpoint Selection", "节点选择"))
+		r.bus.Info(i18n.Text("Same endpoint as run 1: ", "沿用第 1 次测速的节点: ") + r.ep.IP + " (" + r.ep.Desc + ")")
+		return nil
+	}
+	country := r.cfg.PreferCountry
+	if country == "" {
+		country = r.cfg.Region.Country
+	}
+	r.ep = endpoint.Choose(ctx, r.cdnHost, r.bus, r.isTTY && !r.cfg.NoPrompt, prescreen(r.cfg), endpoint.Lookups{
+		NoDoH: r.cfg.NoDoH, NoGeo: r.cfg.NoGeo, Family: r.family, Country: country,
+		Resolver: r.resolver, DNSServer: r.cfg.DNS, Subnet: r.cfg.Region.Subnet, Region: r.cfg.Region.Name,
+	})
+	if r.ep.IP == "" && r.family != 0 {
+		// Unpinned, the run would measure whichever version the OS prefers.
+		return fmt.Errorf("no IPv%d endpoint for %s", r.family, r.cdnHost)
+	}
+	if r.ep.IP == "" && r.cfg.Region.Name != "" && ctx.Err() == nil {
+		// Unpinned, it would measure the nearest PoP after all.
+		return fmt.Errorf("no endpoint for %s in region %s", r.cdnHost, r.cfg.Region.Name)
+	}
+	if r.ep.IP != "" && r.cdnHost != "" {
+		r.buildClients()
+	}
+	if r.cfg.PerASN && r.ep.IP != "" {
+		r.perASN(ctx)
+	}
+	return nil
+}
+
+// prescreen derives the endpoint connect check from PRESCREEN and DL_URL.
+func prescreen(cfg *config.Config) endpoint.Prescreen {
+	u, err := url.Parse(cfg.DLURL)
+	if cfg.Prescreen == config.PrescreenOff || err != nil {
+		return endpoint.Prescreen{}
+	}
+	return endpoint.Prescreen{Port: urlPort(u), TLS: cfg.Prescreen == config.PrescreenTLS && u.Scheme == "https"}
+}
+
+// urlPort is the port u connects to, explicit or implied by its scheme.
+func urlPort(u *url.URL) string {
+	if port := u.Port(); port != "" {
+		return port
+	}
+	if u.Scheme == "http" {
+		return "80"
+	}
+	return "443"
+}
 
-	bus.Header(i18n.Text("Idle Latency", "空载延迟"))
-	bus.Info(fmt.Sprintf(i18n.Text("Samples: %d", "采样: %d"), cfg.LatencyCount))
+func (r *run) idleLatency(ctx context.Context) error {
+	r.bus.Header(i18n.Text("Idle Latency", "空载延迟"))
+	r.bus.Info(fmt.Sprintf(i18n.Text("Samples: %d", "采样: %d"), r.cfg.LatencyCount))
 
-	idleStats := latency.MeasureIdle(ctx, client, cfg.LatencyURL, cfg.LatencyCount)
-	bus.Result(fmt.Sprintf(i18n.Text(
+	client, opts := r.headlineProbe(r.client)
+	r.idle = latency.MeasureIdleFunc(ctx, client, r.cfg.LatencyURL, r.cfg.RequestHeader(), r.cfg.LatencyCount, opts, r.latencySample("idle"))
+	r.bus.Result(fmt.Sprintf(i18n.Text(
 		"%.2f ms median  (min %.2f / avg %.2f / max %.2f)  jitter %.2f ms",
 		"%.2f 毫秒 中位数  (最小 %.2f / 平均 %.2f / 最大 %.2f)  抖动 %.2f 毫秒"),
-		idleStats.Median, idleStats.Min, idleStats.Avg, idleStats.Max, idleStats.Jitter))
+		r.idle.Median, r.idle.Min, r.idle.Avg, r.idle.Max, r.idle.Jitter))
+	r.showFiltered(r.idle)
+	r.rep.IdleLatency = latencyReport(r.idle)
+	if r.cfg.LatencyConn != config.LatencyBoth {
+		return nil
+	}
+	fresh := latency.MeasureIdleFunc(ctx, r.fresh, r.cfg.LatencyURL, r.cfg.RequestHeader(), r.cfg.LatencyCount, r.probeOpts(true), nil)
+	if fresh.N == 0 {
+		r.bus.Warn(i18n.Text("No latency samples over new connections.", "新建连接未取得延迟样本。"))
+		return nil
+	}
+	l := latencyReport(fresh)
+	r.rep.IdleLatencyNewConn = &l
+	r.bus.Result(fmt.Sprintf(i18n.Text("New connection per probe: %.2f ms median  (%+.2f ms for connection setup)", "每次探测新建连接: %.2f 毫秒 中位数  (建连耗时 %+.2f 毫秒)"),
+		fresh.Median, fresh.Median-r.idle.Median))
+	return nil
+}
 
-	var totalData int64
+// icmpLatency pings the selected endpoint so the HTTP idle latency can be
+// checked against a protocol that bypasses the HTTP stack and any proxies.
+func (r *run) icmpLatency(ctx context.Context) error {
+	bus := r.bus
+	bus.Header(i18n.Text("ICMP Latency", "ICMP 延迟"))
+	ip := r.ep.IP
+	if ip == "" {
+		ip = endpoint.ResolveHost(r.resolver, r.cdnHost)
+	}
+	if ip == "" {
+		bus.Warn(fmt.Sprintf(i18n.Text("Cannot resolve %s for ICMP.", "无法解析 %s，跳过 ICMP。"), r.cdnHost))
+		return nil
+	}
+	res, err := ping.Measure(ctx, ip, r.cfg.LatencyCount, 100*time.Millisecond, time.Second)
+	if err != nil {
+		bus.Warn(fmt.Sprintf(i18n.Text("ICMP latency unavailable: %v", "无法测量 ICMP 延迟: %v"), err))
+		return nil
+	}
+	loss := res.Loss() * 100
+	if len(res.Samples) == 0 {
+		bus.Warn(fmt.Sprintf(i18n.Text("No ICMP replies from %s; ICMP may be blocked.", "%s 未响应 ICMP，可能被屏蔽。"), ip))
+		r.rep.ICMPLossPct = loss
+		return nil
+	}
+	s := latency.Robust(res.Samples)
+	bus.Result(fmt.Sprintf(i18n.Text("%.2f ms median  (min %.2f / max %.2f)  loss %.0f%%", "%.2f 毫秒 中位数  (最小 %.2f / 最大 %.2f)  丢包 %.0f%%"),
+		s.Median, s.Min, s.Max, loss))
+	icmp := latencyReport(s)
+	r.rep.ICMPLatency = &icmp
+	r.rep.ICMPLossPct = loss
+	if r.idle.N > 0 {
+		r.rep.LatencyDiscrepancy = latencyDiscrepancy(r.idle.Median, s.Median)
+	}
+	return nil
+}
 
-	runRound := func(dir transfer.Direction, threads int, label string, url string) {
+// mtu probes the path MTU to the selected endpoint. A path below 1500 bytes
+// (PPPoE, VPNs, tunnels) costs some throughput; one that drops oversized
+// packets silently stalls large transfers outright.
+func (r *run) mtu(ctx context.Context) error {
+	bus := r.bus
+	bus.Header(i18n.Text("Path MTU", "路径 MTU"))
+	ip := r.ep.IP
+	if ip == "" {
+		ip = endpoint.ResolveHost(r.resolver, r.cdnHost)
+	}
+	if ip == "" {
+		bus.Warn(fmt.Sprintf(i18n.Text("Cannot resolve %s for the MTU probe.", "无法解析 %s，跳过 MTU 探测。"), r.cdnHost))
+		return nil
+	}
+	port := "443"
+	if u, err := url.Parse(r.cfg.DLURL); err == nil {
+		port = urlPort(u)
+	}
+	res := pmtu.Measure(ctx, ip, port)
+	if res.TCPErr != nil {
+		bus.Warn(fmt.Sprintf(i18n.Text("TCP MSS unavailable: %v", "无法读取 TCP MSS: %v"), res.TCPErr))
+	} else {
+		bus.Info(fmt.Sprintf(i18n.Text("TCP MSS %d (MTU %d)", "TCP MSS %d（MTU %d）"), res.MSS, res.TCPMTU))
+	}
+	if res.ICMPErr != nil {
+		bus.Warn(fmt.Sprintf(i18n.Text("ICMP MTU probe unavailable: %v", "无法进行 ICMP MTU 探测: %v"), res.ICMPErr))
+	} else {
+		bus.Result(fmt.Sprintf(i18n.Text("%d bytes  (largest unfragmented ICMP echo)", "%d 字节  (不分片 ICMP echo 的最大值)"), res.PathMTU))
+	}
+	mtu := res.MTU()
+	if mtu == 0 {
+		return nil
+	}
+	r.rep.MTU = &report.MTU{TCPMSS: res.MSS, TCPMTU: res.TCPMTU, PathMTU: res.PathMTU, Blackhole: res.Blackhole}
+	if res.Blackhole {
+		bus.Warn(fmt.Sprintf(i18n.Text(
+			"Packets over %d bytes are dropped without an ICMP \"fragmentation needed\": a PMTUD black hole is likely and can stall large transfers. Clamping the TCP MSS on the router usually fixes it.",
+			"超过 %d 字节的包被丢弃且没有 ICMP“需要分片”回应：可能存在 PMTUD 黑洞，会使大流量传输停滞。在路由器上钳制 TCP MSS 通常可以解决。"), res.PathMTU))
+	} else if mtu < pmtu.Ethernet {
+		bus.Warn(fmt.Sprintf(i18n.Text(
+			"Path MTU %d is below %d, typical of PPPoE, VPN or tunnel links; each packet carries less data.",
+			"路径 MTU %d 低于 %d，常见于 PPPoE、VPN 或隧道链路，每个包承载的数据更少。"), mtu, pmtu.Ethernet))
+	}
+	return nil
+}
+
+// udpLatency probes the --udp-echo server with paced datagrams. Unlike the
+// HTTP and ICMP probes it keeps sending through losses, which is what
+// real-time traffic such as calls and games experiences.
+func (r *run) udpLatency(ctx context.Context) error {
+	bus := r.bus
+	bus.Header(i18n.Text("UDP Latency", "UDP 延迟"))
+	bus.Info(fmt.Sprintf(i18n.Text("Echo server: %s", "回显服务器: %s"), r.cfg.UDPEcho))
+	res, err := udpprobe.Measure(ctx, r.cfg.UDPEcho, r.cfg.LatencyCount*5, 20*time.Millisecond, time.Second, r.cfg.TOS)
+	if err != nil {
+		bus.Warn(fmt.Sprintf(i18n.Text("UDP latency unavailable: %v", "无法测量 UDP 延迟: %v"), err))
+		return nil
+	}
+	udp := &report.UDP{
+		Target:     r.cfg.UDPEcho,
+		Sent:       res.Sent,
+		Received:   len(res.Samples),
+		LossPct:    res.Loss() * 100,
+		Reordered:  res.Reordered,
+		Duplicates: res.Duplicates,
+	}
+	r.rep.UDP = udp
+	if len(res.Samples) == 0 {
+		bus.Warn(fmt.Sprintf(i18n.Text("No UDP replies from %s; is the echo server running?", "%s 未响应 UDP，回显服务是否在运行？"), r.cfg.UDPEcho))
+		return nil
+	}
+	// Jitter follows arrival order, like the packets a real-time
+	// application would play out.
+	s := latency.Compute(res.Samples)
+	s.Jitter = udpprobe.Jitter(res.Samples)
+	udp.Latency = latencyReport(s)
+	bus.Result(fmt.Sprintf(i18n.Text("%.2f ms median  (min %.2f / max %.2f)  jitter %.2f ms  loss %.1f%%", "%.2f 毫秒 中位数  (最小 %.2f / 最大 %.2f)  抖动 %.2f 毫秒  丢包 %.1f%%"),
+		s.Median, s.Min, s.Max, s.Jitter, udp.LossPct))
+	if len(res.Up) > 1 {
+		udp.UpJitterMs = udpprobe.Jitter(res.Up)
+		udp.DownJitterMs = udpprobe.Jitter(res.Down)
+		bus.Info(fmt.Sprintf(i18n.Text("Jitter upstream %.2f ms / downstream %.2f ms", "上行抖动 %.2f 毫秒 / 下行抖动 %.2f 毫秒"), udp.UpJitterMs, udp.DownJitterMs))
+	}
+	if res.Reordered > 0 || res.Duplicates > 0 {
+		bus.Warn(fmt.Sprintf(i18n.Text("%d packets reordered, %d duplicated.", "%d 个包乱序，%d 个包重复。"), res.Reordered, res.Duplicates))
+	}
+	return nil
+}
+
+// requestRatePhase is how long each request-rate phase runs; tests shorten it.
+var requestRatePhase = 5 * time.Second
+
+// requestRate counts small-object requests per second against LATENCY_URL,
+// first one at a time and then with one request in flight per thread. It
+// tracks how API-heavy apps feel better than bulk throughput does.
+func (r *run) requestRate(ctx context.Context) error {
+	bus := r.bus
+	bus.Header(i18n.Text("Request Rate", "请求速率"))
+	workers := []int{1}
+	if r.cfg.Threads > 1 {
+		workers = append(workers, r.cfg.Threads)
+	}
+	for _, n := range workers {
 		if ctx.Err() != nil {
-			return
+			return nil
 		}
-		bus.Header(label)
-		bus.Info(fmt.Sprintf(i18n.Text("Threads: %d", "线程: %d"), threads))
-		bus.Info(fmt.Sprintf(i18n.Text("Limit: %s / %ds per thread", "上限: %s / 每线程 %ds"), cfg.Max, cfg.Timeout))
+		res := latency.MeasureRate(ctx, r.client, r.cfg.LatencyURL, r.cfg.RequestHeader(), n, requestRatePhase)
+		r.rep.RequestRate = append(r.rep.RequestRate, report.RequestRate{
+			Workers:     n,
+			Requests:    res.Requests,
+			Errors:      res.Errors,
+			DurationSec: res.Duration.Seconds(),
+			RPS:         math.Round(res.RPS*10) / 10,
+			MedianMs:    res.Latency.Median,
+		})
+		if res.Requests > 0 {
+			t := res.TTFB
+			r.rep.RequestRate[len(r.rep.RequestRate)-1].TTFB = &report.TTFB{Min: t.Min, P50: t.P50, P90: t.P90, P99: t.P99, Max: t.Max}
+		}
+		label := i18n.Text("Sequential", "串行")
+		if n > 1 {
+			label = fmt.Sprintf(i18n.Text("%d concurrent", "%d 并发"), n)
+		}
+		bus.Result(fmt.Sprintf(i18n.Text("%s: %.1f req/s  (%d requests, median %.2f ms)", "%s: %.1f 请求/秒  (%d 次请求，中位数 %.2f 毫秒)"),
+			label, res.RPS, res.Requests, res.Latency.Median))
+		if res.Requests > 0 {
+			t := res.TTFB
+			bus.Info(fmt.Sprintf(i18n.Text("TTFB: p50 %.2f / p90 %.2f / p99 %.2f ms  (min %.2f, max %.2f)", "首字节时间: p50 %.2f / p90 %.2f / p99 %.2f 毫秒  (最小 %.2f，最大 %.2f)"),
+				t.P50, t.P90, t.P99, t.Min, t.Max))
+		}
+		if res.Errors > 0 {
+			bus.Warn(fmt.Sprintf(i18n.Text("%d requests failed.", "%d 次请求失败。"), res.Errors))
+		}
+	}
+	return nil
+}
+
+// latencySample forwards latency probes to the event log under phase.
+func (r *run) latencySample(phase string) latency.SampleFunc {
+	return func(ms float64) { r.bus.Latency(phase, ms) }
+}
 
-		loadedProbe := latency.StartLoaded(ctx, client, cfg.LatencyURL)
-		res := transfer.Run(ctx, client, cfg, dir, threads, url, bus)
-		loadedStats := loadedProbe.Stop()
-		totalData += res.TotalBytes
+// probeOpts shapes the latency probes after LATENCY_INTERVAL and
+// LATENCY_PROBE_SIZE, opening a connection for each when newConn is set.
+func (r *run) probeOpts(newConn bool) latency.Options {
+	return latency.Options{Interval: r.cfg.LatencyInterval, Size: r.cfg.LatencyProbeBytes, NewConn: newConn}
+}
 
-		if threads <= 1 {
-			bus.Result(fmt.Sprintf(i18n.Text("%.0f Mbps  (%s in %.1fs)", "%.0f Mbps  (%s，耗时 %.1fs)"),
-				res.Mbps, config.HumanBytes(res.TotalBytes), res.Duration.Seconds()))
-		} else {
-			bus.Result(fmt.Sprintf(i18n.Text("%.0f Mbps  (%s in %.1fs, %d threads)", "%.0f Mbps  (%s，耗时 %.1fs，%d 线程)"),
-				res.Mbps, config.HumanBytes(res.TotalBytes), res.Duration.Seconds(), threads))
+// headlineProbe is the client and options of the latency probes behind the
+// headline figures: over a new connection each under LATENCY_CONN=new, over
+// client's kept-alive connections otherwise.
+func (r *run) headlineProbe(client *http.Client) (*http.Client, latency.Options) {
+	if r.cfg.LatencyConn == config.LatencyNew {
+		return r.fresh, r.probeOpts(true)
+	}
+	return client, r.probeOpts(false)
+}
+
+// latencyDiscrepancy compares the HTTP and ICMP idle medians. ICMP well
+// above HTTP usually means routers deprioritize or rate-limit ICMP; HTTP
+// well above ICMP points at a proxy or middlebox delaying HTTP only. Both
+// need a sizeable absolute and relative gap.
+func latencyDiscrepancy(httpMs, icmpMs float64) string {
+	switch {
+	case icmpMs-httpMs > 10 && icmpMs > httpMs*1.5:
+		return report.ICMPSlower
+	case httpMs-icmpMs > 20 && httpMs > icmpMs*2:
+		return report.HTTPSlower
+	}
+	return ""
+}
+
+// Drift thresholds: idle latency after the load must exceed the baseline by
+// both driftMinMs and driftMinRatio before it is reported as persistent.
+const (
+	driftMinMs    = 5
+	driftMinRatio = 0.5
+)
+
+// idleLatencyAfter repeats the idle measurement once the link has been
+// loaded. Latency that stays high afterwards points at state the load left
+// behind, such as exhausted CGNAT tables or a modem queue that never drains.
+func (r *run) idleLatencyAfter(ctx context.Context) error {
+	r.bus.Header(i18n.Text("Idle Latency (after load)", "空载延迟（负载后）"))
+	client, opts := r.headlineProbe(r.client)
+	r.after = latency.MeasureIdleFunc(ctx, client, r.cfg.LatencyURL, r.cfg.RequestHeader(), r.cfg.LatencyCount, opts, r.latencySample("idle-after"))
+	if r.after.N == 0 {
+		r.bus.Warn(i18n.Text("No latency samples after the load.", "负载后未取得延迟样本。"))
+		return nil
+	}
+	r.bus.Result(fmt.Sprintf(i18n.Text(
+		"%.2f ms median  (min %.2f / avg %.2f / max %.2f)  jitter %.2f ms",
+		"%.2f 毫秒 中位数  (最小 %.2f / 平均 %.2f / 最大 %.2f)  抖动 %.2f 毫秒"),
+		r.after.Median, r.after.Min, r.after.Avg, r.after.Max, r.after.Jitter))
+	r.showFiltered(r.after)
+	after := latencyReport(r.after)
+	r.rep.IdleLatencyAfter = &after
+	if r.idle.N == 0 {
+		return nil
+	}
+	r.rep.LatencyDriftMs, r.rep.LatencyDrifted = latencyDrift(r.idle, r.after)
+	r.bus.Info(fmt.Sprintf(i18n.Text("Drift vs before load: %+.2f ms", "相对负载前漂移: %+.2f 毫秒"), r.rep.LatencyDriftMs))
+	return nil
+}
+
+// latencyDrift returns how far the median moved between the two idle
+// measurements and whether the rise counts as persistent degradation.
+func latencyDrift(before, after latency.Stats) (float64, bool) {
+	drift := after.Median - before.Median
+	return drift, drift > driftMinMs && drift > before.Median*driftMinRatio
+}
+
+// round runs a transfer stage. Its rounds are named en in the report and
+// shown in the UI language.
+func (r *run) round(stage string, dir transfer.Direction, en, zh string) func(context.Context) error {
+	return func(ctx context.Context) error {
+		modes := r.stageModes(stage)
+		for i, mode := range modes {
+			if ctx.Err() != nil {
+				return nil
+			}
+			// The URLs must outlast the round: its timeout plus the grace
+			// transfer.Run allows.
+			r.refreshURLs(ctx, time.Duration(r.cfg.ForStage(stage).Timeout+2)*time.Second)
+			cfg := r.budgeted(stage, len(modes)-i, r.cfg.ForStage(stage))
+			url := cfg.DLURL
+			if dir == transfer.Upload {
+				url = cfg.ULURL
+			}
+			if res := r.transferRound(ctx, cfg, dir, en, zh, url, mode); res.HadFault() {
+				r.recheck(ctx, stage, dir)
+			}
 		}
-		if res.HadFault {
-			bus.Warn(i18n.Text("Network issue detected during this round; result may be affected.", "本轮测试中出现网络故障，结果可能受影响。"))
+		return nil
+	}
+}
+
+// transferRound runs and reports one round over the client of the given
+// connection mode, returning its result; a round skipped under the data cap
+// returns the zero Result.
+func (r *run) transferRound(ctx context.Context, cfg *config.Config, dir transfer.Direction, en, zh, url, mode string) transfer.Result {
+	bus := r.bus
+	threads := cfg.Threads
+	name, label := en, i18n.Text(en, zh)
+	if mode != netx.ModeAuto {
+		name += " · " + connLabel(i18n.LangEN, mode, threads)
+		label += " · " + connLabel(i18n.Lang(), mode, threads)
+	}
+	bus.Header(label)
+	if r.gate.Budget.Exhausted() {
+		bus.Warn(fmt.Sprintf(i18n.Text("Total data cap %s reached, round skipped.", "已达总流量上限 %s，跳过本轮。"), r.capName()))
+		return transfer.Result{}
+	}
+	bus.Info(fmt.Sprintf(i18n.Text("Threads: %d", "线程: %d"), threads))
+	bus.Info(fmt.Sprintf(i18n.Text("Limit: %s / %ds per thread", "上限: %s / 每线程 %ds"), cfg.Max, cfg.Timeout))
+	r.budgetNote()
+
+	if r.tracker != nil {
+		r.tracker.Begin()
+	}
+	client := r.clients[mode]
+	probeClient, opts := r.headlineProbe(client)
+	loadedProbe := latency.StartLoadedFunc(ctx, probeClient, cfg.LatencyURL, cfg.RequestHeader(), opts, r.loadedSample("loaded"))
+	var freshProbe *latency.Probe
+	if cfg.LatencyConn == config.LatencyBoth {
+		freshProbe = latency.StartLoadedFunc(ctx, r.fresh, cfg.LatencyURL, cfg.RequestHeader(), r.probeOpts(true), nil)
+	}
+	stopTargets := r.startTargets(ctx)
+	checkIface := r.startIfaceCheck()
+	res := transfer.RunLimited(ctx, client, cfg, dir, threads, url, bus, r.gate, loadedSource{loadedProbe, &r.load})
+	loadedStats := loadedProbe.Stop()
+	stopTargets(dir)
+	round := roundReport(name, res, loadedStats)
+	if freshProbe != nil {
+		if s := freshProbe.Stop(); s.N > 0 {
+			l := latencyReport(s)
+			round.LoadedLatencyNewConn = &l
 		}
-		bus.Info(fmt.Sprintf(i18n.Text("Loaded latency: %.2f ms  (jitter %.2f ms)", "负载延迟: %.2f 毫秒  (抖动 %.2f 毫秒)"),
-			loadedStats.Median, loadedStats.Jitter))
+	}
+	round.Label = label
+	round.ConnMode = mode
+	round.Scatter = scatter(res.Pairs)
+	round.Timeline = timeline(res)
+	round.Interface = checkIface(dir, res.TotalBytes)
+	workerShares(&round, res.WorkerBytes)
+	if dir == transfer.Upload {
+		r.uploadRamp(&round, res, loadedStats)
+	}
+	if r.tracker != nil {
+		round.TCP = tcpFlows(r.tracker.Collect())
 	}
 
-	runRound(transfer.Download, 1, i18n.Text("Download (single thread)", "下载（单线程）"), cfg.DLURL)
-	runRound(transfer.Download, cfg.Threads, i18n.Text("Download (multi-thread)", "下载（多线程）"), cfg.DLURL)
-	runRound(transfer.Upload, 1, i18n.Text("Upload (single thread)", "上传（单线程）"), cfg.ULURL)
-	runRound(transfer.Upload, cfg.Threads, i18n.Text("Upload (multi-thread)", "上传（多线程）"), cfg.ULURL)
+	r.mu.Lock()
+	r.totalData += res.TotalBytes
+	r.rep.DataUsedBytes = r.totalData
+	r.rep.TotalCapReached = r.gate.Budget.Exhausted()
+	r.rep.Rounds = append(r.rep.Rounds, round)
+	r.mu.Unlock()
 
-	if ctx.Err() != nil {
-		bus.Warn(i18n.Text("Interrupted.", "已中断。"))
-		return 130
+	if threads <= 1 {
+		bus.Result(fmt.Sprintf(i18n.Text("%s  (%s in %.1fs)", "%s  (%s，耗时 %.1fs)"),
+			units.Rate(res.Mbps, 0), config.HumanBytes(res.TotalBytes), res.Duration.Seconds()))
+	} else {
+		bus.Result(fmt.Sprintf(i18n.Text("%s  (%s in %.1fs, %d threads)", "%s  (%s，耗时 %.1fs，%d 线程)"),
+			units.Rate(res.Mbps, 0), config.HumanBytes(res.TotalBytes), res.Duration.Seconds(), threads))
+	}
+	if res.HadFault() {
+		bus.Warn(i18n.Text("Network issue detected during this round; result may be affected.", "本轮测试中出现网络故障，结果可能受影响。"))
+	}
+	if res.Saturated {
+		bus.Info(fmt.Sprintf(i18n.Text("Stopped early: throughput held within ±3%% for %v; the result is the steady rate.",
+			"提前结束：吞吐在 %v 内保持在 ±3%% 以内，结果为稳定速率。"), cfg.SaturationWindow))
 	}
+	showPause(bus, res.Paused)
+	showCPU(bus, res.CPUPct)
+	showSpread(bus, round)
+	showIface(bus, round.Interface)
+	bus.Info(fmt.Sprintf(i18n.Text("Loaded latency: %.2f ms  (jitter %.2f ms)", "负载延迟: %.2f 毫秒  (抖动 %.2f 毫秒)"),
+		loadedStats.Median, loadedStats.Jitter))
+	if l := round.LoadedLatencyNewConn; l != nil {
+		bus.Info(fmt.Sprintf(i18n.Text("Loaded latency over new connections: %.2f ms  (jitter %.2f ms)", "新建连接负载延迟: %.2f 毫秒  (抖动 %.2f 毫秒)"),
+			l.MedianMs, l.JitterMs))
+	}
+	if sc := round.Scatter; sc != nil && sc.Correlation >= bloatCorrelation {
+		bus.Info(fmt.Sprintf(i18n.Text("Latency rises with throughput (r = %.2f): queues build up under load.", "延迟随吞吐升高（r = %.2f）：负载下出现排队积压。"), sc.Correlation))
+	}
+	showShares(bus, round)
+	if round.Ramp != nil {
+		showRamp(bus, round.Ramp)
+	}
+	if r.tracker != nil {
+		showTCPFlows(bus, round.TCP)
+	}
+	return res
+}
+
+// skewFairness is the fairness index below which a round's threads are said
+// to be skewed: one of two threads carrying 75% of the bytes, or one of four
+// carrying about half.
+const skewFairness = 0.8
+
+// workerShares fills in how the round's bytes were spread over its threads.
+func workerShares(round *report.Round, bytes []int64) {
+	if len(bytes) < 2 || round.Bytes <= 0 {
+		return
+	}
+	round.WorkerShares = make([]float64, len(bytes))
+	for i, b := range bytes {
+		round.WorkerShares[i] = math.Round(float64(b)/float64(round.Bytes)*1000) / 1000
+	}
+	round.Fairness = math.Round(transfer.Fairness(bytes)*1000) / 1000
+	round.Skewed = round.Fairness < skewFairness
+}
+
+// showShares lists the per-thread shares under --verbose and warns when one
+// connection hogged the link: the total then says more about how the path
+// treats one flow than about the link's capacity.
+func showShares(bus *render.Bus, round report.Round) {
+	if len(round.WorkerShares) == 0 {
+		return
+	}
+	parts := make([]string, len(round.WorkerShares))
+	top := 0.0
+	for i, s := range round.WorkerShares {
+		parts[i] = fmt.Sprintf("#%d %.0f%%", i+1, s*100)
+		top = math.Max(top, s)
+	}
+	bus.Debug(fmt.Sprintf(i18n.Text("Thread shares: %s  (Jain fairness %.3f)", "线程占比: %s  (Jain 公平指数 %.3f)"),
+		strings.Join(parts, ", "), round.Fairness))
+	if round.Skewed {
+		bus.Warn(fmt.Sprintf(i18n.Text("Uneven threads: one connection carried %.0f%% of the data (fairness %.2f); the total reflects per-flow treatment on the path more than link capacity.",
+			"线程分配不均：单个连接承载了 %.0f%% 的数据（公平指数 %.2f）；总速率更多反映路径对单条流的处理，而非链路容量。"), top*100, round.Fairness))
+	}
+}
+
+// scatterMin is the fewest pairs a correlation is computed from, and
+// bloatCorrelation the r from which latency is said to follow throughput.
+const (
+	scatterMin       = 5
+	bloatCorrelation = 0.5
+)
+
+// scatter turns the round's latency/throughput pairs into its report form.
+func scatter(pairs []transfer.Pair) *report.Scatter {
+	if len(pairs) == 0 {
+		return nil
+	}
+	sc := &report.Scatter{Points: make([]report.ScatterPoint, len(pairs))}
+	xs := make([]float64, len(pairs))
+	ys := make([]float64, len(pairs))
+	for i, p := range pairs {
+		xs[i], ys[i] = p.Mbps, p.RTTMs
+		sc.Points[i] = report.ScatterPoint{Mbps: math.Round(p.Mbps*10) / 10, RTTMs: math.Round(p.RTTMs*100) / 100}
+	}
+	if len(pairs) >= scatterMin {
+		sc.Correlation = math.Round(latency.Correlation(xs, ys)*1000) / 1000
+	}
+	return sc
+}
+
+// timeline keeps the round's throughput series and latency samples over
+// time for the HTML report.
+func timeline(res transfer.Result) *report.Timeline {
+	if len(res.Series) == 0 {
+		return nil
+	}
+	tl := &report.Timeline{IntervalSec: transfer.SeriesInterval.Seconds(), Mbps: res.Series}
+	for _, p := range res.Pairs {
+		tl.Latency = append(tl.Latency, report.TimedRTT{AtSec: p.AtSec, RTTMs: p.RTTMs})
+	}
+	return tl
+}
+
+// uploadRamp keeps the round's throughput series and classifies it. Upload
+// is where CGNAT and PPPoE rate limits usually bite, so only uploads get it.
+func (r *run) uploadRamp(round *report.Round, res transfer.Result, loaded latency.Stats) {
+	round.SeriesIntervalMs = int(transfer.SeriesInterval / time.Millisecond)
+	round.SeriesMbps = make([]float64, len(res.Series))
+	for i, v := range res.Series {
+		round.SeriesMbps[i] = math.Round(v*10) / 10
+	}
+	d, ok := shaping.Classify(res.Series, transfer.SeriesInterval, r.idle.Median, loaded.Median)
+	if !ok {
+		return
+	}
+	round.Ramp = &report.Ramp{
+		Verdict:       d.Verdict,
+		SteadyMbps:    d.SteadyMbps,
+		RampMs:        ms(d.Ramp),
+		CV:            math.Round(d.CV*1000) / 1000,
+		Drops:         d.Drops,
+		LatencyRiseMs: d.LatencyRiseMs,
+	}
+}
+
+func showRamp(bus *render.Bus, rp *report.Ramp) {
+	bus.Info(fmt.Sprintf(i18n.Text("Ramp: %s steady after %.1fs  (variation %.0f%%, %d drops)", "爬升: 稳定在 %s，用时 %.1f 秒  (波动 %.0f%%，%d 次骤降)"),
+		units.Rate(rp.SteadyMbps, 0), rp.RampMs/1000, rp.CV*100, rp.Drops))
+	switch rp.Verdict {
+	case shaping.Policer:
+		bus.Warn(i18n.Text("Throughput saws up and down with little added latency: a policer dropping traffic above the rate is likely.",
+			"吞吐呈锯齿状起伏且延迟几乎未增加：很可能是丢弃超速流量的限速器（policer）。"))
+	case shaping.Shaper:
+		bus.Warn(fmt.Sprintf(i18n.Text("Throughput is flat while latency rose %.0f ms: a shaper queueing traffic to the rate is likely.",
+			"吞吐平稳而延迟升高 %.0f 毫秒：很可能是通过排队限速的整形器（shaper）。"), rp.LatencyRiseMs))
+	}
+}
+
+func connLabel(lang, mode string, threads int) string {
+	if mode == netx.ModeSingleH2 {
+		return fmt.Sprintf(i18n.TextIn(lang, "%d streams on one HTTP/2 connection", "单条 HTTP/2 连接上的 %d 个流"), threads)
+	}
+	return fmt.Sprintf(i18n.TextIn(lang, "%d TCP connections", "%d 条 TCP 连接"), threads)
+}
+
+// shapingRatio is the share of the separate connections' throughput below
+// which a single HTTP/2 connection is taken as a sign of per-connection
+// shaping.
+const shapingRatio = 0.7
+
+// connComparison pairs the multi and single-h2 rounds of each direction.
+func connComparison(rounds []report.Round) []report.ConnComparison {
+	var out []report.ConnComparison
+	for _, dir := range []string{report.DirDownload, report.DirUpload} {
+		var multi, h2 *report.Round
+		for i := range rounds {
+			rd := &rounds[i]
+			if rd.Direction != dir {
+				continue
+			}
+			switch rd.ConnMode {
+			case netx.ModeMulti:
+				multi = rd
+			case netx.ModeSingleH2:
+				h2 = rd
+			}
+		}
+		if multi == nil || h2 == nil || multi.Mbps <= 0 {
+			continue
+		}
+		ratio := h2.Mbps / multi.Mbps
+		out = append(out, report.ConnComparison{
+			Direction:     dir,
+			Streams:       multi.Threads,
+			MultiMbps:     multi.Mbps,
+			SingleH2Mbps:  h2.Mbps,
+			Ratio:         ratio,
+			PerConnShaped: ratio < shapingRatio,
+		})
+	}
+	return out
+}
+
+func (r *run) summary(ctx context.Context) error {
+	bus := r.bus
+	r.mu.Lock()
+	totalData := r.totalData
+	wire := r.wire.Total()
+	r.rep.WireBytes = wire
+	r.mu.Unlock()
 
 	bus.Line()
 	bus.Banner(i18n.Text("\U0001f4ca Summary", "\U0001f4ca 测速汇总"))
 	bus.Line()
-	bus.KV(i18n.Text("Idle Latency", "空载延迟"), fmt.Sprintf(i18n.Text("%.2f ms  (jitter %.2f ms)", "%.2f 毫秒  (抖动 %.2f 毫秒)"), idleStats.Median, idleStats.Jitter))
-	bus.KV(i18n.Text("Data Used", "消耗流量"), config.HumanBytes(totalData))
+	if r.idle.N > 0 || r.cfg.StageEnabled(config.StageIdleLatency) {
+		bus.KV(i18n.Text("Idle Latency", "空载延迟"), fmt.Sprintf(i18n.Text("%.2f ms  (jitter %.2f ms)", "%.2f 毫秒  (抖动 %.2f 毫秒)"), r.idle.Median, r.idle.Jitter))
+	}
+	if r.rep.IdleLatencyAfter != nil {
+		bus.KV(i18n.Text("  After Load", "  负载后"), fmt.Sprintf(i18n.Text("%.2f ms  (%+.2f ms)", "%.2f 毫秒  (%+.2f 毫秒)"), r.after.Median, r.rep.LatencyDriftMs))
+	}
+	if l := r.rep.IdleLatencyNewConn; l != nil {
+		bus.KV(i18n.Text("  New Connection", "  新建连接"), fmt.Sprintf(i18n.Text("%.2f ms  (%+.2f ms)", "%.2f 毫秒  (%+.2f 毫秒)"), l.MedianMs, l.MedianMs-r.idle.Median))
+	}
+	if icmp := r.rep.ICMPLatency; icmp != nil {
+		bus.KV(i18n.Text("ICMP Latency", "ICMP 延迟"), fmt.Sprintf(i18n.Text("%.2f ms  (loss %.0f%%)", "%.2f 毫秒  (丢包 %.0f%%)"), icmp.MedianMs, r.rep.ICMPLossPct))
+	}
+	if m := r.rep.MTU; m != nil {
+		mtu := m.PathMTU
+		if mtu == 0 {
+			mtu = m.TCPMTU
+		}
+		v := fmt.Sprint(mtu)
+		if m.Blackhole {
+			v += i18n.Text("  (PMTUD black hole)", "  (PMTUD 黑洞)")
+		}
+		bus.KV(i18n.Text("Path MTU", "路径 MTU"), v)
+	}
+	if udp := r.rep.UDP; udp != nil && udp.Received > 0 {
+		bus.KV(i18n.Text("UDP Latency", "UDP 延迟"), fmt.Sprintf(i18n.Text("%.2f ms  (jitter %.2f ms, loss %.1f%%)", "%.2f 毫秒  (抖动 %.2f 毫秒，丢包 %.1f%%)"),
+			udp.Latency.MedianMs, udp.Latency.JitterMs, udp.LossPct))
+	}
+	r.targetSummary()
+	if b := r.rep.Bidirectional; b != nil {
+		bus.KV(i18n.Text("Bidirectional", "双向同时"), fmt.Sprintf("↓ %s + ↑ %s = %s", units.Value(b.DownloadMbps, 0), units.Value(b.UploadMbps, 0), units.Rate(b.CombinedMbps, 0)))
+	}
+	for _, ramp := range r.rep.ThreadRamps {
+		arrow := "↓"
+		if ramp.Direction == report.DirUpload {
+			arrow = "↑"
+		}
+		parts := make([]string, len(ramp.Steps))
+		for i, s := range ramp.Steps {
+			parts[i] = fmt.Sprintf("%d: %s", s.Threads, units.Value(s.Mbps, 0))
+		}
+		bus.KV(i18n.Text("Thread Ramp", "线程阶梯")+" "+arrow, strings.Join(parts, " · ")+" "+units.Label())
+	}
+	if rates := r.rep.RequestRate; len(rates) > 0 {
+		line := fmt.Sprintf(i18n.Text("%.1f req/s", "%.1f 请求/秒"), rates[0].RPS)
+		if len(rates) > 1 {
+			line += fmt.Sprintf(i18n.Text("  (%.1f with %d concurrent)", "  (%.1f，%d 并发)"), rates[1].RPS, rates[1].Workers)
+		}
+		bus.KV(i18n.Text("Request Rate", "请求速率"), line)
+	}
+	if c := r.rep.ColdStart; c != nil {
+		bus.KV(i18n.Text("Cold Start", "冷启动"), fmt.Sprintf(i18n.Text("%s vs %s warm in the first %.0f s", "%s，复用连接 %s（前 %.0f 秒）"),
+			units.Rate(c.Cold.Mbps, 1), units.Rate(c.Warm.Mbps, 1), c.WindowSec))
+	}
+	for _, f := range r.rep.Failovers {
+		bus.KV(i18n.Text("Failover", "地址切换"), fmt.Sprintf("%s → %s  (%s)", f.URL, f.To, f.Stage))
+	}
+	if nodes := r.servedBy(); nodes != "" {
+		bus.KV(i18n.Text("Served by", "服务节点"), nodes)
+	}
+	if c := r.rep.CacheCheck; c != nil && (c.Verdict == cacheISP || c.Verdict == cacheUnknown) {
+		v := i18n.Text("suspected", "疑似")
+		if len(c.Proxies) > 0 {
+			v = strings.Join(c.Proxies, ", ")
+		}
+		bus.KV(i18n.Text("Transparent Cache", "透明缓存"), v)
+	}
+	if r.rep.TestEndpoint != "" {
+		bus.KV(i18n.Text("Test Endpoint", "测试节点"), r.rep.TestEndpoint)
+	}
+	r.tlsSummary()
+	r.lineRate()
+	r.mu.Lock()
+	r.rep.LatencyByFlows = r.latencyByFlows()
+	r.mu.Unlock()
+	showLatencyByFlows(bus, r.rep.LatencyByFlows)
+	if link := linkLabel(r.rep.System); link != "" {
+		bus.KV(i18n.Text("Local Link", "本地链路"), link)
+	}
+	used := config.HumanBytes(totalData)
+	if wire > totalData {
+		used += fmt.Sprintf(i18n.Text("  (%s on the wire with probes and framing)", "  (含探测与协议开销共 %s)"), config.HumanBytes(wire))
+	}
+	bus.KV(i18n.Text("Data Used", "消耗流量"), used)
+	if r.cfg.RateBits > 0 {
+		bus.Warn(fmt.Sprintf(i18n.Text("Rate-capped at %s: throughput reflects the cap, not the link.", "已限速 %s：吞吐量反映的是限速值而非链路能力。"), r.cfg.LimitRate))
+	}
+	r.mu.Lock()
+	r.rep.ConnComparison = connComparison(r.rep.Rounds)
+	r.mu.Unlock()
+	for _, c := range r.rep.ConnComparison {
+		label := i18n.Text("Download", "下载")
+		if c.Direction == report.DirUpload {
+			label = i18n.Text("Upload", "上传")
+		}
+		bus.KV(label, fmt.Sprintf(i18n.Text("%d×TCP %s  vs  1×HTTP/2 %s  (%.0f%%)", "%d×TCP %s  对比  1×HTTP/2 %s  (%.0f%%)"),
+			c.Streams, units.Rate(c.MultiMbps, 0), units.Rate(c.SingleH2Mbps, 0), c.Ratio*100))
+		if c.PerConnShaped {
+			bus.Warn(fmt.Sprintf(i18n.Text("%s: one connection reaches only %.0f%% of %d separate ones; per-connection shaping is likely.",
+				"%s：单连接仅达到独立连接的 %.0f%%（共 %d 条），很可能存在按连接限速。"), label, c.Ratio*100, c.Streams))
+		} else {
+			bus.Info(fmt.Sprintf(i18n.Text("%s: one connection keeps up with %d separate ones; total capacity is the limit.",
+				"%s：单连接与 %d 条独立连接速度相当，瓶颈在总带宽。"), label, c.Streams))
+		}
+	}
+	r.windowAdvice()
+	r.linkAdvice()
+	if r.rep.LatencyDrifted {
+		bus.Warn(fmt.Sprintf(i18n.Text("Idle latency stayed %.1f ms higher after the load; the link may not recover from load (CGNAT state exhaustion, modem queueing).",
+			"负载结束后空载延迟仍高出 %.1f 毫秒，链路可能无法从负载中恢复（如 CGNAT 状态表耗尽、调制解调器队列积压）。"), r.rep.LatencyDriftMs))
+	}
+	if r.gate.Budget.Exhausted() {
+		bus.Warn(fmt.Sprintf(i18n.Text("Total data cap %s reached; later rounds were cut short.", "已达总流量上限 %s，后续轮次被提前结束。"), r.capName()))
+	}
+	r.diagnostics()
 	bus.Line()
 	bus.Info(i18n.Text("All tests complete.", "所有测试完成。"))
 	bus.Line()
+	return nil
+}
 
-	if degraded {
-		return 2
+// ranking places the best download and upload within the reference
+// distribution of the client's ASN, falling back to its country and then to
+// all users.
+func (r *run) ranking(ctx context.Context) error {
+	bus := r.bus
+	bus.Header(i18n.Text("Ranking", "排名"))
+	if r.cfg.RateBits > 0 {
+		bus.Info(i18n.Text("Rate-capped run; ranking skipped.", "已限速，跳过排名。"))
+		return nil
 	}
-	return 0
+	db, err := ranking.Load(ctx, r.cfg.RankingDB)
+	if err != nil {
+		bus.Warn(fmt.Sprintf(i18n.Text("Cannot load ranking reference %s: %v; using the built-in one.", "无法加载排名参考数据 %s: %v，改用内置数据。"), r.cfg.RankingDB, err))
+		r.markDegraded()
+		db = ranking.Builtin()
+	}
+	key, group, ok := db.Lookup(r.rep.Client.ASN, r.rep.Client.Country)
+	if !ok {
+		bus.Info(i18n.Text("No reference for this network.", "没有适用于当前网络的参考数据。"))
+		return nil
+	}
+	who := i18n.Text("all", "所有")
+	if key != ranking.Global {
+		who = key + " (" + group.Name + ")"
+	}
+	bus.Info(fmt.Sprintf(i18n.Text("Reference: %s users, %s", "参考: %s用户，%s"), who, db.Updated))
+
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	for _, dir := range []string{report.DirDownload, report.DirUpload} {
+		dist, label := group.Download, i18n.Text("Download", "下载")
+		if dir == report.DirUpload {
+			dist, label = group.Upload, i18n.Text("Upload", "上传")
+		}
+		mbps := r.rep.Best(dir)
+		if mbps <= 0 || dist == nil {
+			continue
+		}
+		pct, capped := db.Percentile(dist, mbps)
+		r.rep.Ranking = append(r.rep.Ranking, report.Rank{
+			Direction: dir, Mbps: mbps, Group: key, GroupName: group.Name, Percentile: pct, Capped: capped,
+		})
+		var line string
+		switch p := ranking.Round5(pct); {
+		case capped:
+			line = fmt.Sprintf(i18n.Text("faster than over %d%% of %s users", "快于超过 %d%% 的%s用户"), p, who)
+		case p < 5:
+			line = fmt.Sprintf(i18n.Text("among the slowest 5%% of %s users", "处于%s用户中最慢的 5%%"), who)
+		default:
+			line = fmt.Sprintf(i18n.Text("faster than ~%d%% of %s users", "快于约 %d%% 的%s用户"), p, who)
+		}
+		bus.KV(label, units.Rate(mbps, 0)+"  "+line)
+	}
+	bus.Line()
+	return nil
+}
+
+// windowBoundRatio is the share of the window ceiling a single connection
+// must reach before the window, rather than the path, is taken as its cap.
+const windowBoundRatio = 0.8
+
+// windowAdvice reports each direction's bandwidth-delay product and warns
+// when a single connection ran into the kernel's TCP buffer limit.
+func (r *run) windowAdvice() {
+	limits, err := tcpinfo.WindowLimits()
+	if err != nil {
+		limits = tcpinfo.Limits{}
+	}
+	r.mu.Lock()
+	checks := windowChecks(r.rep.Rounds, r.idle.Median, limits)
+	r.rep.TCPWindow = checks
+	r.mu.Unlock()
+	for _, c := range checks {
+		label := i18n.Text("BDP (download)", "BDP（下载）")
+		if c.Direction == report.DirUpload {
+			label = i18n.Text("BDP (upload)", "BDP（上传）")
+		}
+		line := fmt.Sprintf(i18n.Text("%s  (%s × %.1f ms)", "%s  (%s × %.1f 毫秒)"), config.HumanBytes(c.BDPBytes), units.Rate(c.Mbps, 0), c.RTTMs)
+		if c.LimitBytes > 0 {
+			line += fmt.Sprintf(i18n.Text("  window limit %s", "  窗口上限 %s"), config.HumanBytes(c.LimitBytes))
+		}
+		r.bus.KV(label, line)
+		if c.WindowBound {
+			r.bus.Warn(fmt.Sprintf(i18n.Text("One connection reached %s of the %s a %s window allows: the host's TCP buffers, not the CDN, are the cap. Raise %s to at least %s.",
+				"单连接达到 %s，接近 %s 的 %s 窗口上限：瓶颈在本机 TCP 缓冲区而非 CDN。请将 %s 调高到至少 %s。"),
+				units.Rate(c.SingleMbps, 0), units.Rate(c.CeilingMbps, 0), config.HumanBytes(c.LimitBytes), c.LimitSource, config.HumanBytes(c.RecommendedBytes)))
+		}
+	}
+}
+
+// windowChecks computes the bandwidth-delay product of each direction's
+// best round, using the latency measured under that round's load (idle
+// latency when there is none). A single-thread round is then checked
+// against the receive (download) or send (upload) buffer limit.
+func windowChecks(rounds []report.Round, idleMs float64, limits tcpinfo.Limits) []report.WindowCheck {
+	rtt := func(rd report.Round) float64 {
+		if rd.LoadedLatency.MedianMs > 0 {
+			return rd.LoadedLatency.MedianMs
+		}
+		return idleMs
+	}
+	var out []report.WindowCheck
+	for _, dir := range []string{report.DirDownload, report.DirUpload} {
+		var best, single *report.Round
+		for i := range rounds {
+			rd := &rounds[i]
+			if rd.Direction != dir || rd.Mbps <= 0 {
+				continue
+			}
+			if best == nil || rd.Mbps > best.Mbps {
+				best = rd
+			}
+			if rd.Threads == 1 && (single == nil || rd.Mbps > single.Mbps) {
+				single = rd
+			}
+		}
+		if best == nil || rtt(*best) <= 0 {
+			continue
+		}
+		c := report.WindowCheck{
+			Direction: dir,
+			Mbps:      best.Mbps,
+			RTTMs:     rtt(*best),
+			BDPBytes:  int64(best.Mbps * 1e6 / 8 * rtt(*best) / 1000),
+		}
+		c.LimitBytes, c.LimitSource = limits.RecvMax, limits.RecvSource
+		if dir == report.DirUpload {
+			c.LimitBytes, c.LimitSource = limits.SendMax, limits.SendSource
+		}
+		if single != nil && rtt(*single) > 0 && c.LimitBytes > 0 {
+			c.SingleMbps = single.Mbps
+			c.CeilingMbps = float64(c.LimitBytes) * 8 / (rtt(*single) / 1000) / 1e6
+			c.WindowBound = single.Mbps >= windowBoundRatio*c.CeilingMbps
+			if c.WindowBound {
+				// Twice the path's BDP lets one connection fill it with
+				// headroom for autotuning and RTT spikes.
+				c.RecommendedBytes = nextPow2(2 * c.BDPBytes)
+				if c.RecommendedBytes <= c.LimitBytes {
+					c.RecommendedBytes = 2 * c.LimitBytes
+				}
+			}
+		}
+		if c.LimitBytes == 0 {
+			c.LimitSource = ""
+		}
+		out = append(out, c)
+	}
+	return out
 }
 
-func gatherInfo(ctx context.Context, bus *render.Bus, host string, ep endpoint.Endpoint) bool {
+func nextPow2(n int64) int64 {
+	p := int64(1)
+	for p < n {
+		p <<= 1
+	}
+	return p
+}
+
+func (r *run) compare(ctx context.Context) error {
+	bus := r.bus
+	bus.Header(i18n.Text("Comparison", "结果对比"))
+	if r.baseline == nil {
+		bus.Info(fmt.Sprintf(i18n.Text("No previous run in %s; this run becomes the baseline.", "%s 中没有历史记录，本次结果将作为基线。"), r.cfg.History))
+		return nil
+	}
+	ref := i18n.Text("last run", "上次测速")
+	source := r.cfg.History
+	if r.cfg.Baseline != "" {
+		ref = i18n.Text("baseline", "基线")
+		source = r.cfg.Baseline
+	}
+	bus.Info(fmt.Sprintf(i18n.Text("Baseline: %s (%s)", "基线: %s（%s）"), source, r.baseline.Time.Local().Format("2006-01-02 15:04")))
+	if r.cfg.History != "" {
+		notes, err := history.Notes(r.cfg.History, r.baseline.Time, r.rep.Time)
+		if err != nil {
+			bus.Warn(fmt.Sprintf(i18n.Text("Could not read notes: %v", "无法读取备注: %v"), err))
+		}
+		for _, n := range notes {
+			bus.Info(fmt.Sprintf(i18n.Text("Note %s: %s", "备注 %s: %s"), n.Time.Local().Format("2006-01-02 15:04"), n.Text))
+		}
+	}
+
+	deltas := history.Compare(r.rep, r.baseline, r.cfg.Thresholds)
+	if len(deltas) == 0 {
+		bus.Warn(i18n.Text("The baseline has no metrics in common with this run.", "基线与本次测速没有可对比的指标。"))
+	}
+	for _, d := range deltas {
+		label, cur, base := i18n.Text("Download", "下载"), units.Rate(d.Current, 1), units.Rate(d.Baseline, 1)
+		switch d.Metric {
+		case history.MetricUpload:
+			label = i18n.Text("Upload", "上传")
+		case history.MetricLatency:
+			label, cur, base = i18n.Text("Idle Latency", "空载延迟"), fmt.Sprintf("%.1f ms", d.Current), fmt.Sprintf("%.1f ms", d.Baseline)
+		}
+		bus.KV(label, fmt.Sprintf(i18n.Text("%s  %+.1f%% vs %s (%s)", "%s  %+.1f%%（对比%s: %s）"),
+			cur, d.Change, ref, base))
+		if d.Regression {
+			bus.Warn(fmt.Sprintf(i18n.Text("%s regressed by %.1f%%, over the %g%% threshold.", "%s 退化 %.1f%%，超过阈值 %g%%。"),
+				label, math.Abs(d.Change), d.Threshold))
+		}
+	}
+	r.regressed = history.Regressed(deltas)
+	bus.Line()
+	return nil
+}
+
+// shareResults publishes the report and/or writes the PNG card when requested.
+// It returns false if any requested share action failed.
+func shareResults(ctx context.Context, cfg *config.Config, bus *render.Bus, rep *report.Report, id probe.Identity) bool {
+	if !cfg.Share && cfg.ShareImage == "" && cfg.Scatter == "" && cfg.ReportHTML == "" {
+		return true
+	}
+	ok := true
+	if cfg.ShareImage != "" {
+		if err := writeCard(cfg.ShareImage, rep); err != nil {
+			bus.Warn(fmt.Sprintf(i18n.Text("Could not write summary card: %v", "无法生成结果卡片: %v"), err))
+			ok = false
+		} else {
+			bus.Info(i18n.Text("Summary card: ", "结果卡片: ") + cfg.ShareImage)
+		}
+	}
+	if cfg.Scatter != "" {
+		if err := writeScatter(cfg.Scatter, rep); err != nil {
+			bus.Warn(fmt.Sprintf(i18n.Text("Could not write scatter plot: %v", "无法生成散点图: %v"), err))
+			ok = false
+		} else {
+			bus.Info(i18n.Text("Scatter plot: ", "散点图: ") + cfg.Scatter)
+		}
+	}
+	if cfg.ReportHTML != "" {
+		if err := writeHTML(cfg.ReportHTML, rep, notesSincePrevious(cfg, rep)); err != nil {
+			bus.Warn(fmt.Sprintf(i18n.Text("Could not write HTML report: %v", "无法生成 HTML 报告: %v"), err))
+			ok = false
+		} else {
+			bus.Info(i18n.Text("HTML report: ", "HTML 报告: ") + cfg.ReportHTML)
+		}
+	}
+	if cfg.Share {
+		opts := share.Options{URL: cfg.ShareURL, GistToken: cfg.ShareToken}
+		if cfg.ShareURL != "" && id.SameOrigin(cfg.ShareURL) {
+			opts.ProbeToken = id.Token
+		}
+		link, err := share.Upload(ctx, rep, opts)
+		if err != nil {
+			bus.Warn(fmt.Sprintf(i18n.Text("Could not share results: %v", "结果分享失败: %v"), err))
+			ok = false
+		} else {
+			bus.KV(i18n.Text("Share", "分享链接"), link)
+		}
+	}
+	bus.Line()
+	return ok
+}
+
+// notesSincePrevious returns the notes in cfg.History logged after the
+// previous run, up to rep. The report is shared before it is recorded, so
+// the latest entry is that run. Notes are context only: an unreadable
+// history leaves them out.
+func notesSincePrevious(cfg *config.Config, rep *report.Report) []history.Note {
+	if cfg.History == "" {
+		return nil
+	}
+	var from time.Time
+	if prev, err := history.Latest(cfg.History, rep.Simulated); err == nil {
+		from = prev.Time
+	}
+	notes, _ := history.Notes(cfg.History, from, rep.Time)
+	return notes
+}
+
+func writeCard(path string, rep *report.Report) error {
+	f, err := os.Create(path)
+	if err != nil {
+		return err
+	}
+	if err := share.RenderCard(f, rep); err != nil {
+		f.Close()
+		return err
+	}
+	return f.Close()
+}
+
+func writeHTML(path string, rep *report.Report, notes []history.Note) error {
+	f, err := os.Create(path)
+	if err != nil {
+		return err
+	}
+	if err := share.RenderHTML(f, rep, notes); err != nil {
+		f.Close()
+		return err
+	}
+	return f.Close()
+}
+
+// writeScatter plots loaded latency against throughput, one series per
+// round, as SVG.
+func writeScatter(path string, rep *report.Report) error {
+	var series []chart.Series
+	for _, rd := range rep.Rounds {
+		if rd.Scatter == nil {
+			continue
+		}
+		s := chart.Series{Name: rd.Title(), Points: make([]chart.Point, len(rd.Scatter.Points))}
+		for i, p := range rd.Scatter.Points {
+			s.Points[i] = chart.Point{X: p.Mbps, Y: p.RTTMs}
+		}
+		series = append(series, s)
+	}
+	f, err := os.Create(path)
+	if err != nil {
+		return err
+	}
+	err = chart.Scatter(f, chart.Plot{
+		Title:  i18n.Text("Loaded latency vs throughput", "负载延迟与吞吐量"),
+		XLabel: i18n.Text("Throughput (Mbps)", "吞吐量 (Mbps)"),
+		YLabel: i18n.Text("Latency (ms)", "延迟 (毫秒)"),
+	}, series)
+	if err != nil {
+		f.Close()
+		return err
+	}
+	return f.Close()
+}
+
+func latencyReport(s latency.Stats) report.Latency {
+	l := report.Latency{
+		MinMs:    s.Min,
+		AvgMs:    s.Avg,
+		MedianMs: s.Median,
+		MaxMs:    s.Max,
+		JitterMs: s.Jitter,
+		Samples:  s.N,
+	}
+	if s.N > 0 {
+		l.Percentiles = &report.Percentiles{P1: s.Pct.P1, P25: s.Pct.P25, P50: s.Pct.P50, P75: s.Pct.P75, P99: s.Pct.P99}
+	}
+	if s.Raw != nil {
+		raw := latencyReport(*s.Raw)
+		l.Method, l.WarmupDropped, l.OutliersRejected, l.Raw = latency.Method, s.Warmup, s.Outliers, &raw
+	}
+	return l
+}
+
+// showFiltered notes what Robust left out of s, next to the raw figures
+// it would otherwise have shown.
+func (r *run) showFiltered(s latency.Stats) {
+	if s.Raw == nil || s.Warmup+s.Outliers == 0 {
+		return
+	}
+	r.bus.Info(fmt.Sprintf(i18n.Text(
+		"Dropped %d warm-up and %d outlier samples; raw: avg %.2f / max %.2f  jitter %.2f ms",
+		"已剔除 %d 个预热样本与 %d 个离群样本；原始: 平均 %.2f / 最大 %.2f  抖动 %.2f 毫秒"),
+		s.Warmup, s.Outliers, s.Raw.Avg, s.Raw.Max, s.Raw.Jitter))
+}
+
+// stallFraction is the share of a round's median throughput below which a
+// series slice counts as a micro-stall.
+const stallFraction = 0.1
+
+// throughputSpread reads the percentiles of a round's throughput series
+// from its histogram and counts its micro-stalls: slices below
+// stallFraction of the median between the first and the last slice that
+// reached it, so the ramp-up and the tail are left out.
+func throughputSpread(series []float64) (*report.Percentiles, int) {
+	if len(series) == 0 {
+		return nil, 0
+	}
+	p := histogram.Of(series).Percentiles()
+	first, last := -1, -1
+	for i, v := range series {
+		if v >= p.P50 {
+			if first < 0 {
+				first = i
+			}
+			last = i
+		}
+	}
+	stalls := 0
+	for i := first; first >= 0 && i <= last; i++ {
+		if series[i] < p.P50*stallFraction {
+			stalls++
+		}
+	}
+	r := func(v float64) float64 { return math.Round(v*10) / 10 }
+	return &report.Percentiles{P1: r(p.P1), P25: r(p.P25), P50: r(p.P50), P75: r(p.P75), P99: r(p.P99)}, stalls
+}
+
+// tcpFlows converts tracker output for the report, dropping connections
+// whose statistics could not be read.
+func tcpFlows(flows []tcpinfo.Flow) []report.TCPFlow {
+	var out []report.TCPFlow
+	for _, f := range flows {
+		if f.Err != nil {
+			continue
+		}
+		out = append(out, report.TCPFlow{
+			Remote:       f.Remote,
+			BytesRead:    f.BytesRead,
+			BytesWritten: f.BytesWritten,
+			RTTMs:        ms(f.Info.RTT),
+			RTTVarMs:     ms(f.Info.RTTVar),
+			MinRTTMs:     ms(f.Info.MinRTT),
+			Retransmits:  f.Info.Retransmits,
+			CwndBytes:    f.Info.CwndBytes,
+			DeliveryMbps: float64(f.Info.DeliveryRate) * 8 / 1_000_000,
+		})
+	}
+	return out
+}
+
+func ms(d time.Duration) float64 {
+	return float64(d) / float64(time.Millisecond)
+}
+
+func showTCPFlows(bus *render.Bus, flows []report.TCPFlow) {
+	if len(flows) == 0 {
+		bus.Info(i18n.Text("TCP info: not available on this platform.", "TCP 统计: 当前平台不可用。"))
+		return
+	}
+	for _, f := range flows {
+		line := fmt.Sprintf(i18n.Text("TCP %s  rtt %.2f ms (var %.2f)  retrans %d  cwnd %s",
+			"TCP %s  RTT %.2f 毫秒 (波动 %.2f)  重传 %d  拥塞窗口 %s"),
+			f.Remote, f.RTTMs, f.RTTVarMs, f.Retransmits, config.HumanBytes(int64(f.CwndBytes)))
+		if f.DeliveryMbps > 0 {
+			line += fmt.Sprintf(i18n.Text("  delivery %s", "  交付速率 %s"), units.Rate(f.DeliveryMbps, 0))
+		}
+		bus.Info(line)
+	}
+}
+
+func roundReport(name string, res transfer.Result, loaded latency.Stats) report.Round {
+	dir := report.DirDownload
+	if res.Direction == transfer.Upload {
+		dir = report.DirUpload
+	}
+	rd := report.Round{
+		Name:          name,
+		Direction:     dir,
+		Threads:       res.Threads,
+		Bytes:         res.TotalBytes,
+		DurationSec:   res.Duration.Seconds(),
+		Mbps:          res.Mbps,
+		Faults:        res.FaultCount,
+		LoadedLatency: latencyReport(loaded),
+		PausedSec:     math.Round(res.Paused.Seconds()*10) / 10,
+		ClientCPUPct:  math.Round(res.CPUPct*10) / 10,
+		ClientBound:   res.CPUPct >= clientBoundCPU,
+		Saturated:     res.Saturated,
+	}
+	for k, n := range res.FaultKinds {
+		if rd.FaultKinds == nil {
+			rd.FaultKinds = make(map[string]int)
+		}
+		rd.FaultKinds[string(k)] = n
+	}
+	rd.ThroughputPct, rd.MicroStalls = throughputSpread(res.Series)
+	return rd
+}
+
+// showSpread shows the round's throughput and loaded-latency percentiles
+// under --verbose, and the micro-stalls its average hides.
+func showSpread(bus *render.Bus, rd report.Round) {
+	if p := rd.ThroughputPct; p != nil {
+		bus.Debug(fmt.Sprintf(i18n.Text("Throughput p1/p25/p50/p75/p99: %s / %s / %s / %s / %s %s",
+			"吞吐量 p1/p25/p50/p75/p99: %s / %s / %s / %s / %s %s"),
+			units.Value(p.P1, 1), units.Value(p.P25, 1), units.Value(p.P50, 1), units.Value(p.P75, 1), units.Value(p.P99, 1), units.Label()))
+	}
+	if p := rd.LoadedLatency.Percentiles; p != nil {
+		bus.Debug(fmt.Sprintf(i18n.Text("Loaded latency p1/p25/p50/p75/p99: %.1f / %.1f / %.1f / %.1f / %.1f ms",
+			"负载延迟 p1/p25/p50/p75/p99: %.1f / %.1f / %.1f / %.1f / %.1f 毫秒"), p.P1, p.P25, p.P50, p.P75, p.P99))
+	}
+	if rd.MicroStalls > 0 {
+		bus.Info(fmt.Sprintf(i18n.Text("%d micro-stalls: 100 ms slices below a tenth of the median throughput.",
+			"%d 次微停顿：吞吐量低于中位数十分之一的 100 毫秒区间。"), rd.MicroStalls))
+	}
+}
+
+// clientBoundCPU is the client CPU share, in percent, from which a round is
+// taken to have been limited by the device running the test.
+const clientBoundCPU = 85
+
+// showCPU reports the client's CPU use under --verbose, and warns when it
+// was the bottleneck.
+func showCPU(bus *render.Bus, pct float64) {
+	if pct <= 0 {
+		return
+	}
+	bus.Debug(fmt.Sprintf(i18n.Text("Client CPU: %.0f%% of %d cores", "客户端 CPU: %.0f%%（共 %d 核）"), pct, runtime.GOMAXPROCS(0)))
+	if pct >= clientBoundCPU {
+		bus.Warn(fmt.Sprintf(i18n.Text("The client's CPU was %.0f%% busy: this device, not the network, likely limited the result.",
+			"客户端 CPU 占用达 %.0f%%：限制结果的很可能是本设备而非网络。"), pct))
+	}
+}
+
+// showPause notes a suspension during the round. The time asleep is left
+// out of the result, but connections that outlived it may have stalled or
+// reset on resume, so the figure deserves less trust.
+func showPause(bus *render.Bus, paused time.Duration) {
+	if paused <= 0 {
+		return
+	}
+	bus.Warn(fmt.Sprintf(i18n.Text("The system was suspended for %.1fs during this round; that time is excluded, but the result may be affected.",
+		"本轮测试期间系统暂停了 %.1f 秒；该时段已排除在外，但结果仍可能受影响。"), paused.Seconds()))
+}
+
+func gatherInfo(ctx context.Context, bus *render.Bus, host string, ep endpoint.Endpoint, rep *report.Report, noGeo bool, res *net.Resolver) bool {
 	ok := true
 	bus.Header(i18n.Text("Connection Information", "连接信息"))
 
-	cinfo := endpoint.FetchInfo(ctx, "")
+	serverIP := ep.IP
+	if serverIP == "" && host != "" {
+		// DNS fallback: resolve host to enrich server metadata
+		serverIP = endpoint.ResolveHost(res, host)
+	}
+	if noGeo {
+		bus.Info(i18n.Text("Geo lookups disabled.", "已禁用地理位置查询。"))
+		rep.Server = report.Peer{Host: host, IP: serverIP}
+		if serverIP == "" {
+			serverIP = "?"
+		}
+		bus.KV(i18n.Text("Server", "服务端"), fmt.Sprintf("%s  \u2192  %s", host, serverIP))
+		return true
+	}
+
+	// Both lookups share one budget, so a blocked ip-api cannot hold up
+	// the test for long.
+	lctx, cancel := context.WithTimeout(ctx, endpoint.LookupBudget)
+	defer cancel()
+	var sinfo, sinfoEN endpoint.IPInfo
+	looked := make(chan struct{})
+	go func() {
+		defer close(looked)
+		if serverIP != "" {
+			sinfo, sinfoEN = lookupInfo(lctx, serverIP)
+		}
+	}()
+	cinfo, cinfoEN := lookupInfo(lctx, "")
+	<-looked
+
 	clientIP := cinfo.Query
 	if clientIP == "" {
 		clientIP = "?"
@@ -140,26 +1846,25 @@ func gatherInfo(ctx context.Context, bus *render.Bus, host string, ep endpoint.E
 	}
 	clientLoc := formatLocation(cinfo)
 
+	rep.Client = report.Peer{IP: cinfoEN.Query, ISP: cinfoEN.ISP, ASN: cinfoEN.AS, Location: formatLocation(cinfoEN), Country: cinfoEN.CountryCode}
 	bus.KV(i18n.Text("Client", "客户端"), fmt.Sprintf("%s  (%s)", clientIP, clientISP))
 	bus.KV("  ASN", clientAS)
 	bus.KV(i18n.Text("  Location", "  位置"), clientLoc)
 
-	serverIP := ep.IP
-	if serverIP == "" && host != "" {
-		// DNS fallback: resolve host to enrich server metadata
-		serverIP = endpoint.ResolveHost(host)
-	}
 	if serverIP == "" {
 		serverIP = "?"
 		ok = false
 	}
+	rep.Server = report.Peer{Host: host}
+	if serverIP != "?" {
+		rep.Server.IP = serverIP
+	}
 	bus.KV(i18n.Text("Server", "服务端"), fmt.Sprintf("%s  \u2192  %s", host, serverIP))
 	if ep.Desc != "" {
 		bus.KV(i18n.Text("  Endpoint", "  节点"), ep.Desc)
 	}
 
 	if serverIP != "?" {
-		sinfo := endpoint.FetchInfo(ctx, serverIP)
 		sAS := sinfo.AS
 		if sAS == "" {
 			sAS = sinfo.Org
@@ -170,11 +1875,49 @@ func gatherInfo(ctx context.Context, bus *render.Bus, host string, ep endpoint.E
 		sLoc := formatLocation(sinfo)
 		bus.KV("  ASN", sAS)
 		bus.KV(i18n.Text("  Location", "  位置"), sLoc)
+		if sAS != "?" {
+			rep.Server.ASN = sAS
+		}
+		rep.Server.Location = formatLocation(sinfoEN)
+		if ep.Desc != "" {
+			rep.Server.Endpoint = endpointDesc(sinfoEN)
+		}
 	}
 
 	return ok
 }
 
+// lookupInfo fetches target's geo info for display and, unless the UI is
+// already English, concurrently again in English for the report: machine
+// output must not change with the language.
+func lookupInfo(ctx context.Context, target string) (shown, recorded endpoint.IPInfo) {
+	if i18n.Lang() == i18n.LangEN {
+		info := endpoint.FetchInfo(ctx, target)
+		return info, info
+	}
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		recorded = endpoint.FetchInfoEN(ctx, target)
+	}()
+	shown = endpoint.FetchInfo(ctx, target)
+	<-done
+	return shown, recorded
+}
+
+// endpointDesc is the English form of an endpoint description: location and
+// AS, as shown when choosing the endpoint.
+func endpointDesc(info endpoint.IPInfo) string {
+	as := info.AS
+	if as == "" {
+		as = info.Org
+	}
+	if as == "" {
+		return formatLocation(info)
+	}
+	return formatLocation(info) + " (" + as + ")"
+}
+
 func formatLocation(info endpoint.IPInfo) string {
 	loc := info.City
 	if info.RegionName != "" && info.RegionName != info.City {
@@ -1,8 +1,48 @@
 package runner
 
 import (
-	"github.com/tsosunchia/iNetSpeed-CLI/internal/endpoint"
+	"bytes"
+	"context"
+	"crypto/tls"
+	"crypto/x509"
+	"encoding/json"
+	"errors"
+	"io"
+	"math"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"reflect"
+	"regexp"
+	"runtime"
+	"slices"
+	"strconv"
+	"strings"
 	"testing"
+	"time"
+	"unicode"
+
+	"github.com/tsosunchia/iNetSpeed-CLI/internal/config"
+	"github.com/tsosunchia/iNetSpeed-CLI/internal/discover"
+	"github.com/tsosunchia/iNetSpeed-CLI/internal/endpoint"
+	"github.com/tsosunchia/iNetSpeed-CLI/internal/history"
+	"github.com/tsosunchia/iNetSpeed-CLI/internal/i18n"
+	"github.com/tsosunchia/iNetSpeed-CLI/internal/ifstat"
+	"github.com/tsosunchia/iNetSpeed-CLI/internal/latency"
+	"github.com/tsosunchia/iNetSpeed-CLI/internal/netx"
+	"github.com/tsosunchia/iNetSpeed-CLI/internal/overhead"
+	"github.com/tsosunchia/iNetSpeed-CLI/internal/pac"
+	"github.com/tsosunchia/iNetSpeed-CLI/internal/ping"
+	"github.com/tsosunchia/iNetSpeed-CLI/internal/probe"
+	"github.com/tsosunchia/iNetSpeed-CLI/internal/render"
+	"github.com/tsosunchia/iNetSpeed-CLI/internal/report"
+	"github.com/tsosunchia/iNetSpeed-CLI/internal/simulate"
+	"github.com/tsosunchia/iNetSpeed-CLI/internal/sysinfo"
+	"github.com/tsosunchia/iNetSpeed-CLI/internal/tcpinfo"
+	"github.com/tsosunchia/iNetSpeed-CLI/internal/transfer"
+	"github.com/tsosunchia/iNetSpeed-CLI/internal/udpprobe"
 )
 
 func TestFormatLocation(t *testing.T) {
@@ -25,3 +65,2235 @@ func TestFormatLocation(t *testing.T) {
 		})
 	}
 }
+
+func TestThroughputSpread(t *testing.T) {
+	// Ramp-up, a steady 100 Mbps with two near-empty slices, then a tail.
+	series := []float64{1, 5, 40}
+	for i := 0; i < 40; i++ {
+		series = append(series, 100)
+	}
+	series[10], series[20] = 0, 4
+	series = append(series, 30, 0)
+	p, stalls := throughputSpread(series)
+	if stalls != 2 {
+		t.Errorf("stalls = %d, want 2", stalls)
+	}
+	if p == nil || math.Abs(p.P50-100) > 1 || p.P1 != 0 || math.Abs(p.P99-100) > 1 {
+		t.Errorf("percentiles = %+v", p)
+	}
+	if p, stalls := throughputSpread(nil); p != nil || stalls != 0 {
+		t.Errorf("empty series: %+v, %d", p, stalls)
+	}
+}
+
+func TestRoundReport(t *testing.T) {
+	res := transfer.Result{
+		Direction:  transfer.Upload,
+		Threads:    4,
+		TotalBytes: 1000,
+		Duration:   2 * time.Second,
+		Mbps:       42,
+		FaultCount: 1,
+		Paused:     12340 * time.Millisecond,
+		CPUPct:     91.26,
+	}
+	got := roundReport("Upload (multi-thread)", res, latency.Stats{Median: 12.5, N: 3})
+	if got.Direction != report.DirUpload || got.Threads != 4 || got.Bytes != 1000 {
+		t.Errorf("unexpected round: %+v", got)
+	}
+	if got.DurationSec != 2 || got.Mbps != 42 || got.Faults != 1 || got.PausedSec != 12.3 {
+		t.Errorf("unexpected round metrics: %+v", got)
+	}
+	if got.ClientCPUPct != 91.3 || !got.ClientBound {
+		t.Errorf("unexpected round metrics: %+v", got)
+	}
+	if got.LoadedLatency.MedianMs != 12.5 || got.LoadedLatency.Samples != 3 {
+		t.Errorf("unexpected loaded latency: %+v", got.LoadedLatency)
+	}
+}
+
+func TestLatencyDiscrepancy(t *testing.T) {
+	tests := []struct {
+		http, icmp float64
+		want       string
+	}{
+		{20, 19, ""},
+		{20, 45, report.ICMPSlower},
+		{20, 28, ""},               // 8 ms apart: noise
+		{2, 14, report.ICMPSlower}, // tiny HTTP baseline
+		{80, 20, report.HTTPSlower},
+		{50, 30, ""}, // 20 ms apart but under 2x
+		{10, 5, ""},  // 2x but only 5 ms
+	}
+	for _, tt := range tests {
+		if got := latencyDiscrepancy(tt.http, tt.icmp); got != tt.want {
+			t.Errorf("latencyDiscrepancy(%v, %v) = %q, want %q", tt.http, tt.icmp, got, tt.want)
+		}
+	}
+}
+
+func TestLatencyDrift(t *testing.T) {
+	tests := []struct {
+		before, after float64
+		want          bool
+	}{
+		{20, 22, false}, // noise
+		{20, 28, false}, // above 5 ms but under 50%
+		{20, 40, true},  // doubled
+		{2, 6, false},   // doubled but under 5 ms
+		{2, 12, true},   // both
+		{30, 10, false}, // improved
+	}
+	for _, tt := range tests {
+		drift, got := latencyDrift(latency.Stats{Median: tt.before}, latency.Stats{Median: tt.after})
+		if drift != tt.after-tt.before || got != tt.want {
+			t.Errorf("latencyDrift(%v, %v) = %v, %v; want %v", tt.before, tt.after, drift, got, tt.want)
+		}
+	}
+}
+
+func TestScatter(t *testing.T) {
+	if scatter(nil) != nil {
+		t.Error("scatter without pairs")
+	}
+	var pairs []transfer.Pair
+	for i := 1; i <= 6; i++ {
+		pairs = append(pairs, transfer.Pair{Mbps: 100*float64(i) + 0.04, RTTMs: 10 * float64(i)})
+	}
+	sc := scatter(pairs)
+	if len(sc.Points) != 6 || sc.Points[0] != (report.ScatterPoint{Mbps: 100, RTTMs: 10}) || sc.Correlation != 1 {
+		t.Fatalf("scatter = %+v", sc)
+	}
+	if sc := scatter(pairs[:scatterMin-1]); sc.Correlation != 0 {
+		t.Errorf("correlation from %d pairs: %v", len(sc.Points), sc.Correlation)
+	}
+
+	path := filepath.Join(t.TempDir(), "scatter.svg")
+	rep := &report.Report{Rounds: []report.Round{{Name: "Download (multi-thread)", Scatter: sc}, {Name: "Upload"}}}
+	if err := writeScatter(path, rep); err != nil {
+		t.Fatal(err)
+	}
+	b, err := os.ReadFile(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if svg := string(b); !strings.Contains(svg, "Download (multi-thread)") || strings.Contains(svg, ">Upload<") {
+		t.Errorf("series in plot:\n%s", svg)
+	}
+}
+
+func TestRunGraphStages(t *testing.T) {
+	cfg := &config.Config{Threads: 4, Timeout: 5, SkipStages: map[string]bool{config.StageInfo: true}}
+	g := newRun(cfg, nil, false).graph()
+	if !reflect.DeepEqual(g.Names(), config.StageNames) {
+		t.Errorf("graph stages = %v, want %v", g.Names(), config.StageNames)
+	}
+	if _, err := g.Levels(); err != nil {
+		t.Fatalf("graph is invalid: %v", err)
+	}
+	for _, s := range g.stages {
+		switch s.Name {
+		case config.StageDiscover, config.StageURLCheck, config.StageInfo, config.StageSysInfo, config.StageICMPLatency, config.StageTargets, config.StageMTU, config.StageUDPLatency, config.StageRequestRate, config.StageCacheCheck, config.StageColdStart, config.StageAutoMax, config.StageUploadVerify, config.StageBidirectional, config.StageThreadRamp, config.StageCompare, config.StageAssert, config.StageShare:
+			if !s.Disabled {
+				t.Errorf("stage %s should be disabled", s.Name)
+			}
+		default:
+			if s.Disabled {
+				t.Errorf("stage %s should be enabled", s.Name)
+			}
+		}
+	}
+}
+
+func TestRunSimulated(t *testing.T) {
+	t.Setenv("SPEEDTEST_LANG", "en")
+	cfg, err := config.Load("--simulate", "--simulate-opts", "bandwidth=400Mbps,latency=2ms,size=1M",
+		"--max", "1M", "--timeout", "5", "--threads", "2", "--latency-count", "3")
+	if err != nil {
+		t.Fatal(err)
+	}
+	var buf bytes.Buffer
+	bus := render.NewBus(render.NewPlainRenderer(&buf))
+	code := Run(context.Background(), cfg, bus, false)
+	bus.Close()
+
+	if code != 0 {
+		t.Fatalf("exit code = %d\n%s", code, buf.String())
+	}
+	out := buf.String()
+	for _, want := range []string{"Simulation mode", "Download (multi-thread)", "Upload (multi-thread)", "Summary", "All tests complete."} {
+		if !strings.Contains(out, want) {
+			t.Errorf("output missing %q", want)
+		}
+	}
+	for _, unwanted := range []string{"Endpoint Selection", "Connection Information", "Network issue"} {
+		if strings.Contains(out, unwanted) {
+			t.Errorf("output should not contain %q", unwanted)
+		}
+	}
+}
+
+func TestRunSimulatedReport(t *testing.T) {
+	cfg, err := config.Load("--simulate", "--simulate-opts", "bandwidth=0,latency=1ms,size=512K",
+		"--max", "512K", "--timeout", "5", "--latency-count", "3")
+	if err != nil {
+		t.Fatal(err)
+	}
+	srv := simulate.Start(cfg.Sim)
+	defer srv.Close()
+	bus := render.NewBus(render.NewPlainRenderer(io.Discard))
+	defer bus.Close()
+
+	r := newRun(simulatedConfig(cfg, srv), bus, false)
+	if err := r.graph().Execute(context.Background()); err != nil {
+		t.Fatal(err)
+	}
+	if !r.rep.Simulated || len(r.rep.Rounds) != 4 {
+		t.Fatalf("report: simulated=%v rounds=%d", r.rep.Simulated, len(r.rep.Rounds))
+	}
+	for _, rd := range r.rep.Rounds {
+		want := int64(512 * 1000)
+		if rd.Threads > 1 {
+			want *= int64(rd.Threads)
+		}
+		if rd.Bytes != want || rd.Faults != 0 {
+			t.Errorf("round %s: bytes=%d faults=%d, want %d bytes and no faults", rd.Name, rd.Bytes, rd.Faults, want)
+		}
+	}
+	if l := r.rep.IdleLatency; l.Raw == nil || l.Raw.Samples != 3 || l.Samples+l.WarmupDropped+l.OutliersRejected != 3 || l.MinMs < 1 {
+		t.Errorf("idle latency = %+v", r.rep.IdleLatency)
+	}
+	if after := r.rep.IdleLatencyAfter; after == nil || after.Raw == nil || after.Raw.Samples != 3 || r.rep.LatencyDrifted {
+		t.Errorf("idle latency after = %+v, drifted=%v", after, r.rep.LatencyDrifted)
+	}
+}
+
+func TestDataBudget(t *testing.T) {
+	cfg, err := config.Load("--simulate", "--simulate-opts", "bandwidth=0,latency=1ms,size=4M",
+		"--max", "4M", "--threads", "2", "--timeout", "5", "--latency-count", "3", "--data-budget", "10M")
+	if err != nil {
+		t.Fatal(err)
+	}
+	srv := simulate.Start(cfg.Sim)
+	defer srv.Close()
+	var buf bytes.Buffer
+	bus := render.NewBus(render.NewPlainRenderer(&buf))
+	r := newRun(simulatedConfig(cfg, srv), bus, false)
+	err = r.graph().Execute(context.Background())
+	bus.Close()
+	if err != nil {
+		t.Fatal(err)
+	}
+	// 9M of the 10M shared among 6 threads is 1.5M, rounded down to 1M; the
+	// single-thread rounds leave more for the ones after them.
+	want := []int64{1_000_000, 2 * 1_000_000, 2_000_000, 2 * 2_000_000}
+	if len(r.rep.Rounds) != len(want) {
+		t.Fatalf("%d rounds", len(r.rep.Rounds))
+	}
+	for i, rd := range r.rep.Rounds {
+		// Downloads stop at the first whole read past the cap.
+		if rd.Bytes < want[i] || rd.Bytes > want[i]+64<<10 {
+			t.Errorf("round %s moved %d bytes, want %d", rd.Name, rd.Bytes, want[i])
+		}
+	}
+	if r.rep.DataUsedBytes > 10_000_000 || r.rep.WireBytes <= r.rep.DataUsedBytes || r.rep.TotalCapReached {
+		t.Errorf("data used %d, on the wire %d, cap reached %v", r.rep.DataUsedBytes, r.rep.WireBytes, r.rep.TotalCapReached)
+	}
+	for _, s := range []string{"Limit: 1M / 5s per thread", "Data budget: 9.5 MiB of 10M left", "on the wire with probes and framing"} {
+		if !strings.Contains(buf.String(), s) {
+			t.Errorf("output missing %q\n%s", s, buf.String())
+		}
+	}
+}
+
+func TestRunLatencyConnBoth(t *testing.T) {
+	cfg, err := config.Load("--simulate", "--simulate-opts", "bandwidth=0,latency=1ms,size=256K",
+		"--max", "256K", "--timeout", "5", "--latency-count", "3", "--skip", "download-multi,upload-single,upload-multi",
+		"--latency-conn", "both", "--latency-interval", "20ms", "--latency-probe-size", "1K")
+	if err != nil {
+		t.Fatal(err)
+	}
+	srv := simulate.Start(cfg.Sim)
+	defer srv.Close()
+	var buf bytes.Buffer
+	bus := render.NewBus(render.NewPlainRenderer(&buf))
+	r := newRun(simulatedConfig(cfg, srv), bus, false)
+	err = r.graph().Execute(context.Background())
+	bus.Close()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if l := r.rep.IdleLatencyNewConn; l == nil || l.Raw == nil || l.Raw.Samples != 3 || l.MinMs < 1 {
+		t.Errorf("idle latency over new connections = %+v", l)
+	}
+	if len(r.rep.Rounds) != 1 || r.rep.Rounds[0].LoadedLatencyNewConn == nil {
+		t.Errorf("rounds = %+v", r.rep.Rounds)
+	}
+	if c := r.rep.Config; c.LatencyConn != config.LatencyBoth || c.LatencyIntervalMs != 20 || c.LatencyProbeSize != "1K" {
+		t.Errorf("config = %+v", c)
+	}
+	for _, want := range []string{"New connection per probe:", "Loaded latency over new connections:", "New Connection:"} {
+		if !strings.Contains(buf.String(), want) {
+			t.Errorf("output lacks %q:\n%s", want, buf.String())
+		}
+	}
+}
+
+func TestRunQuiet(t *testing.T) {
+	cfg, err := config.Load("--simulate", "--simulate-opts", "bandwidth=0,latency=1ms,size=256K",
+		"--max", "256K", "--latency-count", "3", "--quiet")
+	if err != nil {
+		t.Fatal(err)
+	}
+	var out, log bytes.Buffer
+	old := stdout
+	stdout = &out
+	defer func() { stdout = old }()
+
+	bus := render.NewBus(render.NewQuietRenderer(&log))
+	code := Run(context.Background(), cfg, bus, false)
+	bus.Close()
+
+	if code != 0 {
+		t.Fatalf("exit code = %d", code)
+	}
+	if log.Len() != 0 {
+		t.Errorf("quiet run logged %q", log.String())
+	}
+	line := out.String()
+	if !regexp.MustCompile(`^down=\d+\.\d up=\d+\.\d latency=\d+\.\d\n$`).MatchString(line) {
+		t.Errorf("quiet line = %q", line)
+	}
+}
+
+func TestRunCompare(t *testing.T) {
+	t.Setenv("SPEEDTEST_LANG", "en")
+	path := filepath.Join(t.TempDir(), "history.jsonl")
+	args := []string{"compare", "--history", path, "--simulate", "--simulate-opts", "bandwidth=0,latency=1ms,size=256K",
+		"--max", "256K", "--latency-count", "3", "--skip", "upload-single,upload-multi"}
+	run := func() (int, string) {
+		cfg, err := config.Load(args...)
+		if err != nil {
+			t.Fatal(err)
+		}
+		var buf bytes.Buffer
+		bus := render.NewBus(render.NewPlainRenderer(&buf))
+		code := Run(context.Background(), cfg, bus, false)
+		bus.Close()
+		return code, buf.String()
+	}
+
+	code, out := run()
+	if code != 0 || !strings.Contains(out, "this run becomes the baseline") {
+		t.Fatalf("first run: code=%d\n%s", code, out)
+	}
+	last, err := history.Latest(path, true)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// A baseline far faster than the emulator can deliver must regress.
+	fast := *last
+	fast.Rounds = []report.Round{{Direction: report.DirDownload, Mbps: 1e9}}
+	if err := history.Append(path, &fast); err != nil {
+		t.Fatal(err)
+	}
+	noteCfg, err := config.Load("note", "--history", path, "switched", "to new router")
+	if err != nil {
+		t.Fatal(err)
+	}
+	bus := render.NewBus(render.NewPlainRenderer(io.Discard))
+	if code := Note(noteCfg, bus); code != 0 {
+		t.Fatalf("note: code=%d", code)
+	}
+	bus.Close()
+	code, out = run()
+	if code != 3 {
+		t.Fatalf("second run: code=%d, want 3\n%s", code, out)
+	}
+	for _, want := range []string{"Comparison", "vs last run", "Download regressed by", ": switched to new router"} {
+		if !strings.Contains(out, want) {
+			t.Errorf("output missing %q\n%s", want, out)
+		}
+	}
+
+	// Zero thresholds turn the checks off.
+	if err := history.Append(path, &fast); err != nil {
+		t.Fatal(err)
+	}
+	args = append(args, "--threshold", "download=0,latency=0")
+	if code, out = run(); code != 0 {
+		t.Errorf("third run: code=%d, want 0\n%s", code, out)
+	}
+}
+
+func TestConnComparison(t *testing.T) {
+	rounds := []report.Round{
+		{Direction: report.DirDownload, Threads: 1, Mbps: 90},
+		{Direction: report.DirDownload, Threads: 4, Mbps: 400, ConnMode: netx.ModeMulti},
+		{Direction: report.DirDownload, Threads: 4, Mbps: 100, ConnMode: netx.ModeSingleH2},
+		{Direction: report.DirUpload, Threads: 4, Mbps: 50, ConnMode: netx.ModeMulti},
+		{Direction: report.DirUpload, Threads: 4, Mbps: 48, ConnMode: netx.ModeSingleH2},
+	}
+	got := connComparison(rounds)
+	if len(got) != 2 {
+		t.Fatalf("got %d comparisons, want 2", len(got))
+	}
+	if d := got[0]; d.Direction != report.DirDownload || d.Ratio != 0.25 || !d.PerConnShaped || d.Streams != 4 {
+		t.Errorf("download = %+v", d)
+	}
+	if u := got[1]; u.Direction != report.DirUpload || u.PerConnShaped {
+		t.Errorf("upload = %+v", u)
+	}
+	if got := connComparison(rounds[:2]); len(got) != 0 {
+		t.Errorf("unpaired rounds compared: %+v", got)
+	}
+}
+
+func TestRunConnectionModeBoth(t *testing.T) {
+	cfg, err := config.Load("--simulate", "--simulate-opts", "bandwidth=0,latency=1ms,size=256K",
+		"--max", "256K", "--timeout", "5", "--latency-count", "2", "--connection-mode", "both")
+	if err != nil {
+		t.Fatal(err)
+	}
+	srv := simulate.Start(cfg.Sim)
+	defer srv.Close()
+	bus := render.NewBus(render.NewPlainRenderer(io.Discard))
+	defer bus.Close()
+
+	r := newRun(simulatedConfig(cfg, srv), bus, false)
+	if err := r.graph().Execute(context.Background()); err != nil {
+		t.Fatal(err)
+	}
+	modes := map[string]int{}
+	for _, rd := range r.rep.Rounds {
+		modes[rd.ConnMode]++
+		if rd.Faults != 0 || rd.Bytes == 0 {
+			t.Errorf("round %s: bytes=%d faults=%d", rd.Name, rd.Bytes, rd.Faults)
+		}
+	}
+	want := map[string]int{netx.ModeAuto: 2, netx.ModeMulti: 2, netx.ModeSingleH2: 2}
+	if !reflect.DeepEqual(modes, want) {
+		t.Errorf("rounds per mode = %v, want %v", modes, want)
+	}
+	if len(r.rep.ConnComparison) != 2 || r.rep.Config.ConnMode != config.ConnBoth {
+		t.Errorf("comparison = %+v, config mode = %q", r.rep.ConnComparison, r.rep.Config.ConnMode)
+	}
+}
+
+func TestRunProbe(t *testing.T) {
+	t.Setenv("SPEEDTEST_LANG", "en")
+	dir := t.TempDir()
+	state := filepath.Join(dir, "probe.json")
+	path := filepath.Join(dir, "history.jsonl")
+
+	var enrolled map[string]string
+	collector := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		json.NewDecoder(r.Body).Decode(&enrolled)
+		w.Write([]byte(`{"token":"probe-secret"}`))
+	}))
+	defer collector.Close()
+
+	cfg, err := config.Load("register", "--collector", collector.URL+"/enroll", "--probe-state", state, "--probe-name", "lab-1")
+	if err != nil {
+		t.Fatal(err)
+	}
+	var buf bytes.Buffer
+	bus := render.NewBus(render.NewPlainRenderer(&buf))
+	code := Register(context.Background(), cfg, bus)
+	bus.Close()
+	if code != 0 || !strings.Contains(buf.String(), "Registered") {
+		t.Fatalf("register: code=%d\n%s", code, buf.String())
+	}
+	id, err := probe.Load(state)
+	if err != nil || id.Token != "probe-secret" || id.ID == "" || enrolled["probe_id"] != id.ID {
+		t.Fatalf("stored identity = %+v, %v (sent %v)", id, err, enrolled)
+	}
+
+	cfg, err = config.Load("--probe-state", state, "--history", path, "--simulate", "--simulate-opts", "bandwidth=0,latency=1ms,size=256K",
+		"--max", "256K", "--latency-count", "3", "--skip", "download-multi,upload-single,upload-multi")
+	if err != nil {
+		t.Fatal(err)
+	}
+	buf.Reset()
+	bus = render.NewBus(render.NewPlainRenderer(&buf))
+	code = Run(context.Background(), cfg, bus, false)
+	bus.Close()
+	if code != 0 || !strings.Contains(buf.String(), "lab-1 ("+id.ID+")") {
+		t.Fatalf("run: code=%d\n%s", code, buf.String())
+	}
+	rep, err := history.Latest(path, true)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if rep.Probe == nil || rep.Probe.ID != id.ID || rep.Probe.Name != "lab-1" {
+		t.Errorf("report probe = %+v", rep.Probe)
+	}
+}
+
+func TestRunEventLog(t *testing.T) {
+	cfg, err := config.Load("--simulate", "--simulate-opts", "bandwidth=8Mbps,latency=1ms,size=1M",
+		"--max", "1M", "--threads", "2", "--latency-count", "3", "--skip", "upload-single,upload-multi")
+	if err != nil {
+		t.Fatal(err)
+	}
+	var buf bytes.Buffer
+	bus := render.NewBus(render.Multi(render.NewPlainRenderer(io.Discard), render.NewEventLog(&buf)))
+	code := Run(context.Background(), cfg, bus, false)
+	bus.Close()
+	if code != 0 {
+		t.Fatalf("exit code = %d", code)
+	}
+
+	seen := map[string]bool{}
+	for _, line := range strings.Split(strings.TrimSpace(buf.String()), "\n") {
+		var ev struct {
+			Time  time.Time      `json:"time"`
+			Kind  string         `json:"kind"`
+			Label string         `json:"label"`
+			Value string         `json:"value"`
+			Data  map[string]any `json:"data"`
+		}
+		if err := json.Unmarshal([]byte(line), &ev); err != nil || ev.Time.IsZero() {
+			t.Fatalf("bad event line %q: %v", line, err)
+		}
+		switch ev.Kind {
+		case "stage":
+			seen["stage:"+ev.Label+":"+ev.Value] = true
+		case "sample":
+			if _, ok := ev.Data["mbps"]; ok && ev.Label == "download" {
+				seen["sample"] = true
+			}
+		case "latency":
+			seen["latency:"+ev.Label] = true
+			if ev.Label == "loaded" && ev.Data["direction"] == "download" && ev.Data["download_flows"].(float64) > 0 {
+				seen["latency:loaded:flows"] = true
+			}
+		default:
+			seen[ev.Kind] = true
+		}
+	}
+	for _, want := range []string{
+		"stage:idle-latency:start", "stage:idle-latency:end", "stage:download-multi:end",
+		"sample", "latency:idle", "latency:loaded", "latency:loaded:flows", "latency:idle-after", "header", "result",
+	} {
+		if !seen[want] {
+			t.Errorf("event log has no %s event", want)
+		}
+	}
+}
+
+func TestPrescreenOptions(t *testing.T) {
+	tests := []struct {
+		mode, url string
+		want      endpoint.Prescreen
+	}{
+		{config.PrescreenTCP, "https://mensura.cdn-apple.com/api/v1/gm/large", endpoint.Prescreen{Port: "443"}},
+		{config.PrescreenTLS, "https://mensura.cdn-apple.com/api/v1/gm/large", endpoint.Prescreen{Port: "443", TLS: true}},
+		{config.PrescreenTLS, "http://example.com:8080/large", endpoint.Prescreen{Port: "8080"}},
+		{config.PrescreenTCP, "http://example.com/large", endpoint.Prescreen{Port: "80"}},
+		{config.PrescreenOff, "https://mensura.cdn-apple.com/api/v1/gm/large", endpoint.Prescreen{}},
+	}
+	for _, tt := range tests {
+		if got := prescreen(&config.Config{Prescreen: tt.mode, DLURL: tt.url}); got != tt.want {
+			t.Errorf("prescreen(%s, %s) = %+v, want %+v", tt.mode, tt.url, got, tt.want)
+		}
+	}
+}
+
+func TestRemote(t *testing.T) {
+	if runtime.GOOS == "windows" {
+		t.Skip("needs a POSIX shell")
+	}
+	dir := t.TempDir()
+	ssh := filepath.Join(dir, "ssh")
+	bin := filepath.Join(dir, "speedtest")
+	if err := os.WriteFile(ssh, []byte("#!/bin/sh\nwhile [ $# -gt 1 ]; do shift; done; exec sh -c \"$1\"\n"), 0o755); err != nil {
+		t.Fatal(err)
+	}
+	// The fake test reports a degraded run when asked for two threads.
+	if err := os.WriteFile(bin, []byte(`#!/bin/sh
+echo "{\"kind\":\"info\",\"value\":\"remote $*\"}"
+case "$*" in *--threads=2*) echo '{"kind":"report","data":{"report":{"degraded":true,"rounds":[{"direction":"download","mbps":50}]}}}'; exit 2;; esac
+echo '{"kind":"report","data":{"report":{"idle_latency":{"median_ms":4},"rounds":[{"direction":"download","mbps":100},{"direction":"upload","mbps":20}]}}}'
+`), 0o755); err != nil {
+		t.Fatal(err)
+	}
+
+	run := func(args ...string) (int, string) {
+		cfg, err := config.Load(append([]string{"remote", "--ssh", ssh, "--remote-binary", bin}, args...)...)
+		if err != nil {
+			t.Fatal(err)
+		}
+		var buf bytes.Buffer
+		bus := render.NewBus(render.NewPlainRenderer(&buf))
+		code := Remote(context.Background(), cfg, bus)
+		bus.Close()
+		return code, buf.String()
+	}
+
+	code, out := run("h1", "h2", "--", "--max", "1G")
+	if code != 0 {
+		t.Fatalf("exit = %d\n%s", code, out)
+	}
+	for _, want := range []string{"remote --event-log /dev/stdout --lang=", "--max 1G", "h1:", "h2:", "down 100.0 Mbps  up 20.0 Mbps  latency 4.0 ms"} {
+		if !strings.Contains(out, want) {
+			t.Errorf("output missing %q:\n%s", want, out)
+		}
+	}
+	if code, out = run("--threads", "2", "h1"); code != 2 || !strings.Contains(out, "(exit 2)") {
+		t.Errorf("degraded host: exit = %d\n%s", code, out)
+	}
+}
+
+func TestRanking(t *testing.T) {
+	ref := filepath.Join(t.TempDir(), "ref.json")
+	if err := os.WriteFile(ref, []byte(`{"updated":"2026-01","percentiles":[10,50,90],"groups":{
+		"*":{"name":"all users","download":[10,100,500],"upload":[1,10,50]},
+		"AS4837":{"name":"China Unicom","download":[20,200,400],"upload":[2,20,40]}}}`), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	var buf bytes.Buffer
+	bus := render.NewBus(render.NewPlainRenderer(&buf))
+	r := newRun(&config.Config{RankingDB: ref}, bus, false)
+	r.rep.Client = report.Peer{ASN: "AS4837 CHINA UNICOM China169 Backbone", Country: "CN"}
+	r.rep.Rounds = []report.Round{
+		{Direction: report.DirDownload, Mbps: 300},
+		{Direction: report.DirUpload, Mbps: 60},
+	}
+	if err := r.ranking(context.Background()); err != nil {
+		t.Fatal(err)
+	}
+	bus.Close()
+	want := []report.Rank{
+		{Direction: report.DirDownload, Mbps: 300, Group: "AS4837", GroupName: "China Unicom", Percentile: 70},
+		{Direction: report.DirUpload, Mbps: 60, Group: "AS4837", GroupName: "China Unicom", Percentile: 90, Capped: true},
+	}
+	if !reflect.DeepEqual(r.rep.Ranking, want) {
+		t.Errorf("ranking = %+v, want %+v", r.rep.Ranking, want)
+	}
+	for _, s := range []string{"faster than ~70% of AS4837 (China Unicom) users", "faster than over 90% of AS4837 (China Unicom) users"} {
+		if !strings.Contains(buf.String(), s) {
+			t.Errorf("output missing %q:\n%s", s, buf.String())
+		}
+	}
+	if r.isDegraded() {
+		t.Error("run marked degraded")
+	}
+
+	// An unreadable reference falls back to the built-in one.
+	bus = render.NewBus(render.NewPlainRenderer(io.Discard))
+	r = newRun(&config.Config{RankingDB: filepath.Join(t.TempDir(), "missing.json")}, bus, false)
+	r.rep.Rounds = []report.Round{{Direction: report.DirDownload, Mbps: 100}}
+	r.ranking(context.Background())
+	bus.Close()
+	if !r.isDegraded() || len(r.rep.Ranking) != 1 || r.rep.Ranking[0].Group != "*" {
+		t.Errorf("fallback: degraded=%v ranking=%+v", r.isDegraded(), r.rep.Ranking)
+	}
+}
+
+func TestWindowChecks(t *testing.T) {
+	lat := func(ms float64) report.Latency { return report.Latency{MedianMs: ms} }
+	rounds := []report.Round{
+		// 1 MiB over 100 ms is at most 83.9 Mbps per connection.
+		{Direction: report.DirDownload, Threads: 1, Mbps: 80, LoadedLatency: lat(100)},
+		{Direction: report.DirDownload, Threads: 4, Mbps: 400, LoadedLatency: lat(100)},
+		{Direction: report.DirUpload, Threads: 1, Mbps: 20},
+	}
+	limits := tcpinfo.Limits{RecvMax: 1 << 20, RecvSource: "net.ipv4.tcp_rmem", SendMax: 4 << 20, SendSource: "net.ipv4.tcp_wmem"}
+	got := windowChecks(rounds, 50, limits)
+	if len(got) != 2 {
+		t.Fatalf("checks = %+v", got)
+	}
+	dl, ul := got[0], got[1]
+	if dl.Mbps != 400 || dl.RTTMs != 100 || dl.BDPBytes != 5_000_000 || !dl.WindowBound ||
+		dl.SingleMbps != 80 || dl.LimitSource != "net.ipv4.tcp_rmem" || dl.RecommendedBytes != 16<<20 {
+		t.Errorf("download = %+v", dl)
+	}
+	if math.Abs(dl.CeilingMbps-83.886) > 0.01 {
+		t.Errorf("download ceiling = %.3f", dl.CeilingMbps)
+	}
+	// Upload falls back to the idle latency and stays well under its window.
+	if ul.RTTMs != 50 || ul.BDPBytes != 125_000 || ul.WindowBound || ul.RecommendedBytes != 0 {
+		t.Errorf("upload = %+v", ul)
+	}
+
+	if got := windowChecks(rounds, 50, tcpinfo.Limits{}); got[0].WindowBound || got[0].LimitSource != "" || got[0].BDPBytes != 5_000_000 {
+		t.Errorf("without limits = %+v", got[0])
+	}
+	if got := windowChecks(nil, 50, limits); got != nil {
+		t.Errorf("no rounds = %+v", got)
+	}
+}
+
+func TestUploadRamp(t *testing.T) {
+	series := make([]float64, 40)
+	for i := range series {
+		series[i] = 40
+		if i < 4 {
+			series[i] = float64(10 * (i + 1))
+		}
+	}
+	var buf bytes.Buffer
+	bus := render.NewBus(render.NewPlainRenderer(&buf))
+	r := newRun(&config.Config{}, bus, false)
+	r.idle = latency.Stats{Median: 10}
+	var round report.Round
+	r.uploadRamp(&round, transfer.Result{Series: series}, latency.Stats{Median: 95})
+	if round.SeriesIntervalMs != 100 || len(round.SeriesMbps) != 40 {
+		t.Fatalf("series = %d × %d ms", len(round.SeriesMbps), round.SeriesIntervalMs)
+	}
+	want := &report.Ramp{Verdict: "shaper", SteadyMbps: 40, RampMs: 400, LatencyRiseMs: 85}
+	if !reflect.DeepEqual(round.Ramp, want) {
+		t.Errorf("ramp = %+v, want %+v", round.Ramp, want)
+	}
+	showRamp(bus, round.Ramp)
+	bus.Close()
+	if !strings.Contains(buf.String(), "latency rose 85 ms") {
+		t.Errorf("output:\n%s", buf.String())
+	}
+
+	round = report.Round{}
+	r.uploadRamp(&round, transfer.Result{Series: series[:5]}, latency.Stats{})
+	if round.Ramp != nil || len(round.SeriesMbps) != 5 {
+		t.Errorf("short round = %+v", round)
+	}
+}
+
+func TestWorkerShares(t *testing.T) {
+	round := report.Round{Bytes: 1000}
+	workerShares(&round, []int64{250, 250, 250, 250})
+	if round.Fairness != 1 || round.Skewed || !reflect.DeepEqual(round.WorkerShares, []float64{0.25, 0.25, 0.25, 0.25}) {
+		t.Errorf("even round = %+v", round)
+	}
+
+	round = report.Round{Bytes: 1000}
+	workerShares(&round, []int64{700, 100, 100, 100})
+	if round.Fairness != 0.481 || !round.Skewed {
+		t.Errorf("skewed round = %+v", round)
+	}
+	var buf bytes.Buffer
+	pr := render.NewPlainRenderer(&buf)
+	pr.Verbose = true
+	bus := render.NewBus(pr)
+	showShares(bus, round)
+	bus.Close()
+	out := buf.String()
+	if !strings.Contains(out, "#1 70%, #2 10%") || !strings.Contains(out, "carried 70% of the data") {
+		t.Errorf("output:\n%s", out)
+	}
+
+	round = report.Round{Bytes: 1000}
+	workerShares(&round, []int64{1000})
+	if round.WorkerShares != nil || round.Fairness != 0 {
+		t.Errorf("single-thread round = %+v", round)
+	}
+}
+
+func TestWidget(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "history.jsonl")
+	cfg, err := config.Load("--widget", "--history", path, "--simulate", "--simulate-opts", "bandwidth=0,latency=1ms,size=256K",
+		"--max", "256K", "--latency-count", "3")
+	if err != nil {
+		t.Fatal(err)
+	}
+	var out bytes.Buffer
+	old := stdout
+	stdout = &out
+	defer func() { stdout = old }()
+	widget := func() string {
+		out.Reset()
+		bus := render.NewBus(render.NewQuietRenderer(io.Discard))
+		code := Widget(context.Background(), cfg, bus)
+		bus.Close()
+		if code != 0 {
+			t.Fatalf("exit code = %d", code)
+		}
+		return out.String()
+	}
+
+	line := widget()
+	if !regexp.MustCompile(`^↓ \d+ ↑ \d+ ⏱ \d+ms\n$`).MatchString(line) {
+		t.Errorf("first widget line = %q", line)
+	}
+	// A fresh cache is served without testing again.
+	if got := widget(); got != line {
+		t.Errorf("cached widget line = %q, want %q", got, line)
+	}
+	if reps, _ := history.Recent(path, 10, true); len(reps) != 1 {
+		t.Errorf("history has %d runs, want 1", len(reps))
+	}
+
+	cfg.WidgetMaxAge = 0
+	if got := widget(); !regexp.MustCompile(`^↓ \d+ [▁-█]{2} ↑ \d+ [▁-█]{2} ⏱ \d+ms [▁-█]{2}\n$`).MatchString(got) {
+		t.Errorf("stale widget line = %q, want sparklines", got)
+	}
+	if _, err := os.Stat(path + ".lock"); !os.IsNotExist(err) {
+		t.Errorf("lock file left behind: %v", err)
+	}
+
+	// The latest note since the oldest run shown closes the line.
+	cfg.WidgetMaxAge = time.Hour
+	if err := history.AppendNote(path, history.Note{Time: time.Now().UTC(), Text: "switched\nto new router"}); err != nil {
+		t.Fatal(err)
+	}
+	if got := widget(); !regexp.MustCompile(`[▁-█]{2} ✎ switched to new router\n$`).MatchString(got) {
+		t.Errorf("widget line with a note = %q", got)
+	}
+	reps, _ := history.Recent(path, 10, true)
+	if notes := notesSincePrevious(&config.Config{History: path}, &report.Report{Time: time.Now().Add(time.Minute), Simulated: true}); len(notes) != 1 || len(reps) != 2 {
+		t.Errorf("notes since run 2 = %+v", notes)
+	}
+}
+
+func TestWidgetLock(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "history.jsonl")
+	unlock, ok := widgetLock(path)
+	if !ok {
+		t.Fatal("first lock failed")
+	}
+	if _, ok := widgetLock(path); ok {
+		t.Error("second lock succeeded while held")
+	}
+	unlock()
+	unlock, ok = widgetLock(path)
+	if !ok {
+		t.Fatal("lock after unlock failed")
+	}
+	unlock()
+
+	// A lock older than widgetLockAge is left over from a dead run.
+	os.WriteFile(path+".lock", nil, 0o644)
+	stale := time.Now().Add(-2 * widgetLockAge)
+	os.Chtimes(path+".lock", stale, stale)
+	if unlock, ok := widgetLock(path); !ok {
+		t.Error("stale lock not taken over")
+	} else {
+		unlock()
+	}
+}
+
+func TestAssertions(t *testing.T) {
+	rep := &report.Report{
+		IdleLatency: report.Latency{MedianMs: 25},
+		Rounds:      []report.Round{{Direction: report.DirDownload, Mbps: 150}},
+	}
+	tests := []struct {
+		down, up, lat float64
+		failed        int
+		checks        int
+	}{
+		{0, 0, 0, 0, 0},
+		{100, 0, 30, 0, 2},
+		{200, 0, 0, assertDownloadFail, 1},
+		{100, 10, 0, assertUploadFail, 2}, // upload not measured
+		{200, 10, 20, assertDownloadFail | assertUploadFail | assertLatencyFail, 3},
+	}
+	for _, tt := range tests {
+		cfg := &config.Config{AssertDownloadMin: tt.down, AssertUploadMin: tt.up, AssertLatencyMax: tt.lat}
+		checks, failed := assertions(cfg, rep)
+		if failed != tt.failed || len(checks) != tt.checks {
+			t.Errorf("assertions(%v, %v, %v) = %d checks, failed %b; want %d, %b", tt.down, tt.up, tt.lat, len(checks), failed, tt.checks, tt.failed)
+		}
+	}
+}
+
+func TestRunAssertExitCode(t *testing.T) {
+	run := func(args ...string) (int, *report.Report) {
+		cfg, err := config.Load(append([]string{"--simulate", "--simulate-opts", "bandwidth=0,latency=1ms,size=256K",
+			"--max", "256K", "--latency-count", "3", "--skip", "upload-single,upload-multi"}, args...)...)
+		if err != nil {
+			t.Fatal(err)
+		}
+		var rep *report.Report
+		bus := render.NewBus(rendererFunc(func(ev render.Event) {
+			if ev.Kind == render.KindReport {
+				rep = ev.Data["report"].(*report.Report)
+			}
+		}))
+		code := Run(context.Background(), cfg, bus, false)
+		bus.Close()
+		return code, rep
+	}
+	if code, rep := run("--assert-download-min", "1", "--assert-latency-max", "1000"); code != 0 || len(rep.Assertions) != 2 {
+		t.Errorf("passing assertions: exit %d, %+v", code, rep.Assertions)
+	}
+	// Upload was skipped, so its assertion fails alongside the latency one.
+	if code, _ := run("--assert-upload-min", "1", "--assert-latency-max", "0.001"); code != exitAssert+assertUploadFail+assertLatencyFail {
+		t.Errorf("failing assertions: exit %d, want %d", code, exitAssert+assertUploadFail+assertLatencyFail)
+	}
+}
+
+type rendererFunc func(render.Event)
+
+func (f rendererFunc) Render(ev render.Event) { f(ev) }
+
+func TestRequestRateStage(t *testing.T) {
+	old := requestRatePhase
+	requestRatePhase = 200 * time.Millisecond
+	defer func() { requestRatePhase = old }()
+
+	cfg, err := config.Load("--simulate", "--simulate-opts", "latency=2ms", "--threads", "3", "--request-rate")
+	if err != nil {
+		t.Fatal(err)
+	}
+	srv := simulate.Start(cfg.Sim)
+	defer srv.Close()
+	bus := render.NewBus(render.NewPlainRenderer(io.Discard))
+	defer bus.Close()
+
+	r := newRun(simulatedConfig(cfg, srv), bus, false)
+	if err := r.requestRate(context.Background()); err != nil {
+		t.Fatal(err)
+	}
+	rates := r.rep.RequestRate
+	if len(rates) != 2 || rates[0].Workers != 1 || rates[1].Workers != 3 {
+		t.Fatalf("request rate = %+v", rates)
+	}
+	for _, rr := range rates {
+		if rr.Requests == 0 || rr.Errors != 0 || rr.RPS <= 0 || rr.MedianMs < 2 {
+			t.Errorf("phase %+v", rr)
+		}
+		if tt := rr.TTFB; tt == nil || tt.Min < 2 || tt.P50 < tt.Min || tt.P99 < tt.P90 || tt.Max < tt.P99 {
+			t.Errorf("phase TTFB %+v", rr.TTFB)
+		}
+	}
+}
+
+func TestAutoMaxSize(t *testing.T) {
+	for _, tc := range []struct {
+		mbps  float64
+		max   string
+		bytes int64
+	}{
+		{100, "150M", 150_000_000},
+		{33.3, "50M", 50_000_000},
+		{0.2, "1M", 1_000_000},
+		{10_000, "15000M", 15_000_000_000},
+		{0, "", 0},
+	} {
+		if max, n := autoMaxSize(tc.mbps); max != tc.max || n != tc.bytes {
+			t.Errorf("autoMaxSize(%v) = %q, %d; want %q, %d", tc.mbps, max, n, tc.max, tc.bytes)
+		}
+	}
+}
+
+func TestAutoMaxStage(t *testing.T) {
+	old := autoMaxProbe
+	autoMaxProbe = 1
+	defer func() { autoMaxProbe = old }()
+
+	cfg, err := config.Load("--simulate", "--simulate-opts", "bandwidth=40Mbps,latency=1ms", "--max", "auto", "--threads", "2")
+	if err != nil {
+		t.Fatal(err)
+	}
+	srv := simulate.Start(cfg.Sim)
+	defer srv.Close()
+	bus := render.NewBus(render.NewPlainRenderer(io.Discard))
+	defer bus.Close()
+
+	r := newRun(simulatedConfig(cfg, srv), bus, false)
+	if err := r.autoMax(context.Background()); err != nil {
+		t.Fatal(err)
+	}
+	mbps := r.rep.Config.AutoMbps
+	if mbps < 20 || mbps > 50 {
+		t.Fatalf("probe measured %.1f Mbps on a 40 Mbps link", mbps)
+	}
+	want, n := autoMaxSize(mbps)
+	if r.cfg.Max != want || r.cfg.MaxBytes != n || r.rep.Config.Max != want {
+		t.Errorf("cap = %q (%d bytes), report %q; want %q", r.cfg.Max, r.cfg.MaxBytes, r.rep.Config.Max, want)
+	}
+	if cfg.Max != config.MaxAuto || r.rep.DataUsedBytes == 0 {
+		t.Errorf("caller's config max = %q, data used = %d", cfg.Max, r.rep.DataUsedBytes)
+	}
+	// A later run of --runs keeps the cap instead of probing again.
+	for _, s := range r.next().graph().stages {
+		if s.Name == config.StageAutoMax && !s.Disabled {
+			t.Error("auto-max enabled again after choosing a cap")
+		}
+	}
+}
+
+func TestBidiReport(t *testing.T) {
+	res := func(mbps float64) transfer.Result { return transfer.Result{Mbps: mbps, Duration: 10 * time.Second} }
+	for _, tc := range []struct {
+		name           string
+		dl, ul         float64
+		soloDL, soloUL float64
+		want           string
+	}{
+		{"full duplex", 480, 95, 500, 100, ""},
+		{"ack starvation", 150, 95, 500, 100, report.DirDownload},
+		{"upload starved", 490, 40, 500, 100, report.DirUpload},
+		{"half duplex", 260, 50, 500, 100, bidiBoth},
+		{"no solo rounds", 150, 95, 0, 100, ""},
+	} {
+		b := bidiReport(2, res(tc.dl), res(tc.ul), latency.Stats{}, tc.soloDL, tc.soloUL)
+		if b.Congested != tc.want || b.CombinedMbps != tc.dl+tc.ul {
+			t.Errorf("%s: %+v, want congested %q", tc.name, b, tc.want)
+		}
+	}
+}
+
+func TestCacheCheckStage(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+		w.Header().Set("Via", "1.1 cache-03.isp.example (squid/4.10)")
+		if req.URL.Query().Get("cb") != "" {
+			w.Header().Set("X-Cache", "MISS from cache-03.isp.example")
+		} else {
+			w.Header().Set("X-Cache", "HIT from cache-03.isp.example")
+			w.Header().Set("Age", "300")
+		}
+		w.Write(make([]byte, 1024))
+	}))
+	defer srv.Close()
+	cfg, err := config.Load("--simulate", "--cache-check")
+	if err != nil {
+		t.Fatal(err)
+	}
+	cfg.DLURL = srv.URL + "/large?x=1"
+	var buf bytes.Buffer
+	bus := render.NewBus(render.NewPlainRenderer(&buf))
+	r := newRun(cfg, bus, false)
+	if err := r.cacheCheck(context.Background()); err != nil {
+		t.Fatal(err)
+	}
+	bus.Close()
+	c := r.rep.CacheCheck
+	if c == nil || c.Verdict != "isp-cache" || !slices.Equal(c.Proxies, []string{"cache-03.isp.example"}) || len(c.Fetches) != 4 || c.QueryIgnored {
+		t.Fatalf("cache check = %+v\n%s", c, buf.String())
+	}
+	if f := c.Fetches[1]; f.Busted || f.AgeSec == nil || *f.AgeSec != 300 || f.Cache != "HIT" || f.Bytes != 1024 {
+		t.Errorf("plain fetch = %+v", f)
+	}
+	if f := c.Fetches[3]; !f.Busted || f.AgeSec != nil || f.Cache != "MISS" {
+		t.Errorf("busted fetch = %+v", f)
+	}
+	if !strings.Contains(buf.String(), "A cache on the path (cache-03.isp.example) answered instead of the CDN") {
+		t.Errorf("no warning:\n%s", buf.String())
+	}
+}
+
+func TestCacheVerdict(t *testing.T) {
+	age := func(n int) *int { return &n }
+	fetch := func(busted bool, ttfb float64, cache, provider string, a *int) report.CacheFetch {
+		return report.CacheFetch{Busted: busted, Status: 206, TTFBMs: ttfb, Cache: cache, Provider: provider, AgeSec: a}
+	}
+	for _, tc := range []struct {
+		name    string
+		fetches []report.CacheFetch
+		proxies []string
+		want    string
+		ignored bool
+	}{
+		{"apple", []report.CacheFetch{fetch(false, 30, "miss", "Apple", nil), fetch(false, 8, "hit-fresh", "Apple", age(10)),
+			fetch(true, 40, "miss", "Apple", nil), fetch(true, 42, "miss", "Apple", nil)}, nil, "cdn", false},
+		{"squid", []report.CacheFetch{fetch(false, 3, "HIT", "", age(60)), fetch(false, 3, "HIT", "", age(61)),
+			fetch(true, 40, "MISS", "", nil), fetch(true, 40, "MISS", "", nil)}, []string{"squid.isp"}, "isp-cache", false},
+		{"proxy", []report.CacheFetch{fetch(false, 30, "", "", nil), fetch(false, 30, "", "", nil),
+			fetch(true, 30, "", "", nil), fetch(true, 30, "", "", nil)}, []string{"proxy.corp"}, "proxy", false},
+		{"silent cache", []report.CacheFetch{fetch(false, 40, "", "", nil), fetch(false, 4, "", "", age(5)),
+			fetch(true, 40, "", "", nil), fetch(true, 44, "", "", nil)}, nil, "unknown-cache", false},
+		{"query ignored", []report.CacheFetch{fetch(false, 5, "hit-fresh", "Apple", age(9)), fetch(false, 5, "hit-fresh", "Apple", age(9)),
+			fetch(true, 5, "hit-fresh", "Apple", age(9)), fetch(true, 5, "hit-fresh", "Apple", age(9))}, nil, "cdn", true},
+	} {
+		c := cacheVerdict(tc.fetches, tc.proxies)
+		if c.Verdict != tc.want || c.QueryIgnored != tc.ignored {
+			t.Errorf("%s: verdict %q, query ignored %v; want %q, %v", tc.name, c.Verdict, c.QueryIgnored, tc.want, tc.ignored)
+		}
+	}
+}
+
+func TestColdStartStage(t *testing.T) {
+	old := coldWindow
+	coldWindow = 500 * time.Millisecond
+	defer func() { coldWindow = old }()
+	cfg, err := config.Load("--simulate", "--simulate-opts", "bandwidth=80Mbps,latency=5ms", "--cold-start")
+	if err != nil {
+		t.Fatal(err)
+	}
+	srv := simulate.Start(cfg.Sim)
+	defer srv.Close()
+	var buf bytes.Buffer
+	bus := render.NewBus(render.NewPlainRenderer(&buf))
+	r := newRun(simulatedConfig(cfg, srv), bus, false)
+	if err := r.coldStart(context.Background()); err != nil {
+		t.Fatal(err)
+	}
+	bus.Close()
+	c := r.rep.ColdStart
+	if c == nil || c.WindowSec != 0.5 || c.Cold.Mbps <= 0 || c.Warm.Mbps <= 0 || c.Cold.Reused || !c.Warm.Reused {
+		t.Fatalf("cold start = %+v\n%s", c, buf.String())
+	}
+	if r.rep.DataUsedBytes < c.Cold.Bytes+c.Warm.Bytes || !strings.Contains(buf.String(), "Warm connection") {
+		t.Errorf("data used %d\n%s", r.rep.DataUsedBytes, buf.String())
+	}
+}
+
+func TestThreadRampStage(t *testing.T) {
+	cfg, err := config.Load("--simulate", "--simulate-opts", "bandwidth=80Mbps,latency=5ms", "--thread-schedule", "1@0s,2@500ms", "--phases", "download")
+	if err != nil {
+		t.Fatal(err)
+	}
+	srv := simulate.Start(cfg.Sim)
+	defer srv.Close()
+	var buf bytes.Buffer
+	bus := render.NewBus(render.NewPlainRenderer(&buf))
+	r := newRun(simulatedConfig(cfg, srv), bus, false)
+	if err := r.threadRamp(context.Background()); err != nil {
+		t.Fatal(err)
+	}
+	bus.Close()
+	if len(r.rep.ThreadRamps) != 1 {
+		t.Fatalf("ramps = %+v\n%s", r.rep.ThreadRamps, buf.String())
+	}
+	ramp := r.rep.ThreadRamps[0]
+	if ramp.Direction != report.DirDownload || len(ramp.Steps) != 2 || ramp.Steps[1].Threads != 2 || ramp.Steps[1].EndSec != 1 {
+		t.Fatalf("ramp = %+v\n%s", ramp, buf.String())
+	}
+	if ramp.Steps[0].Mbps <= 0 || r.rep.DataUsedBytes == 0 || !strings.Contains(buf.String(), "2 threads") {
+		t.Errorf("ramp = %+v, data used %d\n%s", ramp, r.rep.DataUsedBytes, buf.String())
+	}
+}
+
+func TestThreadRampReport(t *testing.T) {
+	series := []float64{10, 10, 20, 20, 20, 30}
+	steps := []config.ThreadStep{{Threads: 1}, {Threads: 2, At: 200 * time.Millisecond}, {Threads: 4, At: 500 * time.Millisecond}, {Threads: 8, At: 800 * time.Millisecond}}
+	ramp := threadRampReport(report.DirUpload, series, steps, time.Second)
+	if len(ramp.Steps) != 3 {
+		t.Fatalf("steps = %+v", ramp.Steps)
+	}
+	if s := ramp.Steps[1]; s.Mbps != 20 || s.GainPct != 100 || s.StartSec != 0.2 || s.EndSec != 0.5 {
+		t.Errorf("step 2 = %+v", s)
+	}
+	if s := ramp.Steps[2]; s.Mbps != 30 || s.GainPct != 50 {
+		t.Errorf("step 3 = %+v", s)
+	}
+	if got := perSecond(make([]float64, 25)); len(got) != 3 {
+		t.Errorf("perSecond: %d seconds", len(got))
+	}
+}
+
+func TestColdStartReport(t *testing.T) {
+	bin := int64(transfer.SeriesInterval.Seconds() * 100e6 / 8) // 100 Mbps
+	bins := make([]int64, int(coldWindow/transfer.SeriesInterval))
+	for i := range bins {
+		bins[i] = bin * int64(min(i+1, 10)) / 10
+	}
+	var cold int64
+	for _, n := range bins {
+		cold += n
+	}
+	c := windowFetch{bytes: cold, dur: coldWindow, ttfb: 80 * time.Millisecond, bins: bins}
+	w := windowFetch{bytes: bin * int64(len(bins)), dur: coldWindow, ttfb: 20 * time.Millisecond, reused: true}
+	cs := coldStartReport(c, w)
+	if cs.Warm.Mbps != 100 || cs.Cold.TTFBMs != 80 || cs.PenaltyPct != 22.5 || cs.RampMs == nil || *cs.RampMs != 900 {
+		t.Errorf("report = %+v, ramp %v", cs, cs.RampMs)
+	}
+	c.bins = make([]int64, len(bins))
+	if cs = coldStartReport(c, w); cs.RampMs != nil {
+		t.Errorf("ramp %v without any fast interval", *cs.RampMs)
+	}
+	if cs = coldStartReport(c, windowFetch{}); cs.PenaltyPct != 0 || cs.RampMs != nil {
+		t.Errorf("no warm fetch: %+v", cs)
+	}
+}
+
+func TestBidirectionalStage(t *testing.T) {
+	cfg, err := config.Load("--simulate", "--simulate-opts", "bandwidth=80Mbps,latency=1ms", "--bidi", "--threads", "4", "--timeout", "1", "--latency-count", "3")
+	if err != nil {
+		t.Fatal(err)
+	}
+	srv := simulate.Start(cfg.Sim)
+	defer srv.Close()
+	var buf bytes.Buffer
+	bus := render.NewBus(render.NewPlainRenderer(&buf))
+
+	r := newRun(simulatedConfig(cfg, srv), bus, false)
+	r.rep.Rounds = []report.Round{{Direction: report.DirDownload, Mbps: 80}, {Direction: report.DirUpload, Mbps: 80}}
+	if err := r.bidirectional(context.Background()); err != nil {
+		t.Fatal(err)
+	}
+	bus.Close()
+	b := r.rep.Bidirectional
+	if b == nil || b.Threads != 2 || b.DownloadMbps <= 0 || b.UploadMbps <= 0 || b.DownloadRetained <= 0 || b.UploadRetained <= 0 {
+		t.Fatalf("bidirectional = %+v\n%s", b, buf.String())
+	}
+	if r.rep.DataUsedBytes == 0 || !strings.Contains(buf.String(), "2 download + 2 upload") {
+		t.Errorf("data used %d\n%s", r.rep.DataUsedBytes, buf.String())
+	}
+	groups := r.latencyByFlows()
+	if !slices.ContainsFunc(groups, func(g report.FlowLatency) bool {
+		return g.Direction == "both" && g.DownloadFlows == 2 && g.UploadFlows == 2 && g.Samples > 0
+	}) {
+		t.Errorf("latency by flows = %+v", groups)
+	}
+}
+
+func TestLatencyByFlows(t *testing.T) {
+	r := &run{}
+	for _, f := range []struct {
+		down, up int
+		ms       float64
+	}{{0, 0, 1}, {4, 0, 30}, {1, 0, 10}, {4, 0, 40}, {0, 1, 20}, {2, 2, 50}} {
+		r.load.SetFlows(transfer.Download, f.down)
+		r.load.SetFlows(transfer.Upload, f.up)
+		r.load.sample(f.ms)
+	}
+	got := r.latencyByFlows()
+	want := []report.FlowLatency{
+		{Direction: "download", DownloadFlows: 1, Samples: 1, MedianMs: 10, AvgMs: 10, MaxMs: 10},
+		{Direction: "download", DownloadFlows: 4, Samples: 2, MedianMs: 35, AvgMs: 35, MaxMs: 40},
+		{Direction: "upload", UploadFlows: 1, Samples: 1, MedianMs: 20, AvgMs: 20, MaxMs: 20},
+		{Direction: "both", DownloadFlows: 2, UploadFlows: 2, Samples: 1, MedianMs: 50, AvgMs: 50, MaxMs: 50},
+	}
+	if !slices.Equal(got, want) {
+		t.Errorf("latency by flows =\n%+v\nwant\n%+v", got, want)
+	}
+}
+
+func TestUploadVerifyStage(t *testing.T) {
+	cfg, err := config.Load("--simulate", "--simulate-opts", "bandwidth=80Mbps,latency=1ms", "--verify-upload", "--timeout", "2")
+	if err != nil {
+		t.Fatal(err)
+	}
+	srv := simulate.Start(cfg.Sim)
+	defer srv.Close()
+	var buf bytes.Buffer
+	bus := render.NewBus(render.NewPlainRenderer(&buf))
+
+	r := newRun(simulatedConfig(cfg, srv), bus, false)
+	r.rep.Rounds = []report.Round{{Direction: report.DirUpload, Mbps: 80}}
+	if err := r.uploadVerify(context.Background()); err != nil {
+		t.Fatal(err)
+	}
+	bus.Close()
+	c := r.rep.UploadCheck
+	if c == nil || c.Status != 200 || c.SentBytes != verifySize(80, cfg.MaxBytes) || c.AckedBytes == nil || *c.AckedBytes != c.SentBytes {
+		t.Fatalf("upload check = %+v\n%s", c, buf.String())
+	}
+	if r.rep.DataUsedBytes != c.SentBytes || !strings.Contains(buf.String(), "Server acknowledged") {
+		t.Errorf("data used %d\n%s", r.rep.DataUsedBytes, buf.String())
+	}
+}
+
+func TestUploadCheck(t *testing.T) {
+	ok := transfer.UploadCheck{Status: 200, Sent: 100 << 20, Acked: 100 << 20, HasAck: true, Written: 9 * time.Second, Answered: 10 * time.Second}
+	for _, tc := range []struct {
+		name    string
+		edit    func(*transfer.UploadCheck)
+		suspect bool
+	}{
+		{"acknowledged", func(*transfer.UploadCheck) {}, false},
+		{"no byte count", func(c *transfer.UploadCheck) { c.HasAck = false }, false},
+		{"error status", func(c *transfer.UploadCheck) { c.Status = 413 }, true},
+		{"short ack", func(c *transfer.UploadCheck) { c.Acked = 60 << 20 }, true},
+		{"buffered", func(c *transfer.UploadCheck) { c.Written = 5 * time.Second }, true},
+	} {
+		c := ok
+		tc.edit(&c)
+		if got := uploadCheck(c); got.Suspect != tc.suspect || (got.AckedBytes != nil) != c.HasAck {
+			t.Errorf("%s: %+v", tc.name, got)
+		}
+	}
+	if got := verifySize(0, 1<<30); got != verifyDefault {
+		t.Errorf("verifySize without a rate = %d", got)
+	}
+	if got := verifySize(800, 100<<20); got != 100<<20 {
+		t.Errorf("verifySize over the cap = %d", got)
+	}
+	if got := verifySize(1, 1<<30); got != verifyMin {
+		t.Errorf("verifySize on a slow link = %d", got)
+	}
+}
+
+func TestLineRate(t *testing.T) {
+	cfg, err := config.Load("--overhead-model", "pppoe", "--dl-url", "https://cdn.invalid/large", "--ul-url", "http://cdn.invalid/slurp")
+	if err != nil {
+		t.Fatal(err)
+	}
+	var buf bytes.Buffer
+	bus := render.NewBus(render.NewPlainRenderer(&buf))
+	r := newRun(cfg, bus, false)
+	r.lineRate()
+	if r.rep.LineRate != nil {
+		t.Errorf("line rate without rounds: %+v", r.rep.LineRate)
+	}
+	r.ep.IP = "2001:db8::1"
+	r.rep.MTU = &report.MTU{PathMTU: 1400}
+	r.rep.TLS = []report.TLSSession{{Version: "TLS 1.3", ALPN: "h2"}}
+	r.rep.Rounds = []report.Round{
+		{Direction: report.DirDownload, Mbps: 500},
+		{Direction: report.DirUpload, Mbps: 50},
+	}
+	r.lineRate()
+	bus.Close()
+
+	lr := r.rep.LineRate
+	if lr == nil || lr.Link != "pppoe" || lr.LinkBytes != 46 || lr.MTU != 1400 || !lr.IPv6 || !lr.HTTP2 {
+		t.Fatalf("line rate = %+v", lr)
+	}
+	down := overhead.Stack{Link: overhead.Link{Bytes: 46, MTU: 1400}, IPv6: true, TLS: true, HTTP2: true}
+	up := down
+	up.TLS = false
+	if lr.DownloadMbps != math.Round(down.LineRate(500)*10)/10 || lr.UploadMbps != math.Round(up.LineRate(50)*10)/10 ||
+		lr.DownloadFactor <= lr.UploadFactor {
+		t.Errorf("line rate = %+v", lr)
+	}
+	if !strings.Contains(buf.String(), "Line Rate (est.):  ↓ 546 Mbps  ↑ 54 Mbps  (pppoe, MTU 1400, +9.1% overhead)") {
+		t.Errorf("output:\n%s", buf.String())
+	}
+}
+
+func TestProxyPAC(t *testing.T) {
+	cfg, err := config.Load("--simulate", "--simulate-opts", "bandwidth=0,latency=1ms,size=256K", "--max", "256K",
+		"--threads", "1", "--timeout", "2", "--latency-count", "2")
+	if err != nil {
+		t.Fatal(err)
+	}
+	srv := simulate.Start(cfg.Sim)
+	defer srv.Close()
+	// The test URLs name a host that does not resolve; only the PAC file's
+	// proxy, the emulator itself, can reach them.
+	cfg = simulatedConfig(cfg, srv)
+	emuAddr := strings.SplitN(strings.TrimPrefix(cfg.DLURL, "http://"), "/", 2)[0]
+	cfg.DLURL = strings.Replace(cfg.DLURL, emuAddr, "cdn.invalid", 1)
+	cfg.ProxyPAC = filepath.Join(t.TempDir(), "proxy.pac")
+	pacSrc := `function FindProxyForURL(url, host) {
+		if (dnsDomainIs(host, ".invalid")) return "PROXY ` + emuAddr + `; DIRECT";
+		return "DIRECT";
+	}`
+	if err := os.WriteFile(cfg.ProxyPAC, []byte(pacSrc), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	if cfg, err = loadPAC(context.Background(), cfg); err != nil {
+		t.Fatal(err)
+	}
+
+	var buf bytes.Buffer
+	pr := render.NewPlainRenderer(&buf)
+	pr.Verbose = true
+	bus := render.NewBus(pr)
+	r := newRun(cfg, bus, false)
+	ctx := context.WithValue(context.Background(), stageKey{}, config.StageDownloadSingle)
+	r.transferRound(ctx, cfg, transfer.Download, "Download", "下载", cfg.DLURL, netx.ModeAuto)
+	bus.Close()
+	if len(r.rep.Rounds) != 1 || r.rep.Rounds[0].Bytes == 0 {
+		t.Fatalf("rounds = %+v\n%s", r.rep.Rounds, buf.String())
+	}
+	// The latency prober and the download reach the two hosts in either
+	// order; the routes are sorted by host.
+	want := []report.ProxyRoute{
+		{Stage: config.StageDownloadSingle, Host: emuAddr, Proxy: pac.Direct},
+		{Stage: config.StageDownloadSingle, Host: "cdn.invalid", Proxy: "PROXY " + emuAddr},
+	}
+	if !reflect.DeepEqual(r.rep.ProxyRoutes, want) {
+		t.Errorf("routes = %+v\n%s", r.rep.ProxyRoutes, buf.String())
+	}
+	if r.rep.Config.ProxyPAC != cfg.ProxyPAC || !strings.Contains(buf.String(), "PAC: cdn.invalid via PROXY") {
+		t.Errorf("config %+v\n%s", r.rep.Config, buf.String())
+	}
+}
+
+func TestPushMetrics(t *testing.T) {
+	var lines string
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		b, _ := io.ReadAll(r.Body)
+		lines = string(b)
+		w.WriteHeader(http.StatusNoContent)
+	}))
+	defer srv.Close()
+	// Nothing listens on a port just released.
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	graphite := ln.Addr().String()
+	ln.Close()
+
+	textfile := filepath.Join(t.TempDir(), "speedtest.prom")
+	cfg, err := config.Load("--influx-url", srv.URL+"/write?db=speedtest", "--graphite-addr", graphite, "--textfile", textfile)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var buf bytes.Buffer
+	bus := render.NewBus(render.NewPlainRenderer(&buf))
+	r := newRun(cfg, bus, false)
+	r.rep.Server.IP = "127.0.0.1"
+	r.rep.Rounds = []report.Round{{Direction: report.DirDownload, Mbps: 99.5}}
+	r.pushMetrics(context.Background())
+	bus.Close()
+	if !strings.HasPrefix(lines, "speedtest,endpoint=127.0.0.1,") || !strings.Contains(lines, `,download_mbps=99.5,run_id="`+r.rep.ID+`" `) {
+		t.Errorf("InfluxDB received %q", lines)
+	}
+	if !strings.Contains(buf.String(), "Could not send metrics to Graphite") || strings.Contains(buf.String(), "InfluxDB") {
+		t.Errorf("output:\n%s", buf.String())
+	}
+	if b, err := os.ReadFile(textfile); err != nil || !strings.Contains(string(b), `speedtest_download_mbps{endpoint="127.0.0.1"`) {
+		t.Errorf("textfile %q, %v", b, err)
+	}
+}
+
+func TestDemo(t *testing.T) {
+	old, oldOut := demoSpeed, stdout
+	demoSpeed = 0
+	var out bytes.Buffer
+	stdout = &out
+	defer func() { demoSpeed, stdout = old, oldOut }()
+
+	cfg, err := config.Load("--demo", "--quiet")
+	if err != nil {
+		t.Fatal(err)
+	}
+	var buf bytes.Buffer
+	bus := render.NewBus(render.NewPlainRenderer(&buf))
+	code := Demo(context.Background(), cfg, bus)
+	bus.Close()
+	if code != 0 {
+		t.Fatalf("exit %d\n%s", code, buf.String())
+	}
+	if !strings.Contains(buf.String(), "Demo mode") || !strings.Contains(buf.String(), "All tests complete.") {
+		t.Errorf("output:\n%s", buf.String())
+	}
+	if !regexp.MustCompile(`^down=\d+\.\d up=\d+\.\d latency=\d+\.\d`).MatchString(out.String()) {
+		t.Errorf("quiet line %q", out.String())
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	bus = render.NewBus(render.NewPlainRenderer(io.Discard))
+	defer bus.Close()
+	if code := Demo(ctx, cfg, bus); code != 130 {
+		t.Errorf("interrupted demo exit %d", code)
+	}
+}
+
+func TestDemoEventsHant(t *testing.T) {
+	events, err := demoEvents(i18n.LangZHHant)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, ev := range events {
+		if ev.Kind == render.KindHeader && ev.Value == "環境檢查" {
+			return
+		}
+	}
+	t.Error("no Traditional Chinese header in the zh-Hant replay")
+}
+
+func TestUDPLatencyStage(t *testing.T) {
+	pc, err := net.ListenPacket("udp", "127.0.0.1:0")
+	if err != nil {
+		t.Skipf("no UDP loopback: %v", err)
+	}
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	go udpprobe.Serve(ctx, pc)
+
+	cfg, err := config.Load("--simulate", "--latency-count", "2", "--udp-echo", pc.LocalAddr().String())
+	if err != nil {
+		t.Fatal(err)
+	}
+	bus := render.NewBus(render.NewPlainRenderer(io.Discard))
+	defer bus.Close()
+	r := newRun(cfg, bus, false)
+	if err := r.udpLatency(context.Background()); err != nil {
+		t.Fatal(err)
+	}
+	udp := r.rep.UDP
+	if udp == nil || udp.Sent != 10 || udp.Received != 10 || udp.LossPct != 0 || udp.Latency.Samples != 10 {
+		t.Fatalf("udp = %+v", udp)
+	}
+}
+
+func TestMTUStage(t *testing.T) {
+	if runtime.GOOS != "linux" && runtime.GOOS != "darwin" {
+		t.Skip("no TCP MSS on this platform")
+	}
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer ln.Close()
+	go func() {
+		for {
+			c, err := ln.Accept()
+			if err != nil {
+				return
+			}
+			c.Close()
+		}
+	}()
+
+	cfg, err := config.Load("--mtu", "--dl-url", "http://"+ln.Addr().String()+"/large")
+	if err != nil {
+		t.Fatal(err)
+	}
+	bus := render.NewBus(render.NewPlainRenderer(io.Discard))
+	defer bus.Close()
+	r := newRun(cfg, bus, false)
+	r.ep.IP = "127.0.0.1"
+	if err := r.mtu(context.Background()); err != nil {
+		t.Fatal(err)
+	}
+	// Loopback carries far more than Ethernet: nothing to warn about.
+	m := r.rep.MTU
+	if m == nil || m.TCPMSS < 1460 || m.Blackhole {
+		t.Fatalf("mtu = %+v", m)
+	}
+	if m.PathMTU != 0 && m.PathMTU != 1500 {
+		t.Errorf("loopback path MTU = %d, want the 1500 cap", m.PathMTU)
+	}
+}
+
+func TestRoundRenewsHookURLs(t *testing.T) {
+	cfg, err := config.Load("--simulate", "--simulate-opts", "bandwidth=0,size=64K", "--max", "64K", "--threads", "1")
+	if err != nil {
+		t.Fatal(err)
+	}
+	sim := simulate.Start(cfg.Sim)
+	defer sim.Close()
+	var calls int
+	hook := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		calls++
+		json.NewEncoder(w).Encode(map[string]any{"dl_url": sim.DownloadURL() + "?sig=" + strconv.Itoa(calls), "expires_in": 3600})
+	}))
+	defer hook.Close()
+
+	c := simulatedConfig(cfg, sim)
+	c.URLHook = hook.URL
+	bus := render.NewBus(render.NewPlainRenderer(io.Discard))
+	defer bus.Close()
+	r := newRun(c, bus, false)
+	r.urlExpires = time.Now().Add(time.Second) // expires during the round
+	round := r.round(config.StageDownloadSingle, transfer.Download, "Download", "下载")
+
+	if err := round(context.Background()); err != nil {
+		t.Fatal(err)
+	}
+	if calls != 1 || r.cfg.DLURL != sim.DownloadURL()+"?sig=1" {
+		t.Errorf("after stale URLs: %d hook calls, DL_URL %s", calls, r.cfg.DLURL)
+	}
+	if len(r.rep.Rounds) != 1 || r.rep.Rounds[0].Bytes == 0 {
+		t.Errorf("rounds = %+v", r.rep.Rounds)
+	}
+	// Fresh URLs are kept.
+	if err := round(context.Background()); err != nil {
+		t.Fatal(err)
+	}
+	if calls != 1 {
+		t.Errorf("fresh URLs renewed: %d hook calls", calls)
+	}
+}
+
+func TestRuns(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "history.jsonl")
+	cfg, err := config.Load("--runs", "3", "--cooldown", "0", "--history", path, "--simulate", "--simulate-opts", "bandwidth=0,latency=1ms,size=256K",
+		"--max", "256K", "--latency-count", "3", "--skip", "download-multi,upload-single,upload-multi")
+	if err != nil {
+		t.Fatal(err)
+	}
+	var buf bytes.Buffer
+	bus := render.NewBus(render.NewPlainRenderer(&buf))
+	code := Run(context.Background(), cfg, bus, false)
+	bus.Close()
+	out := buf.String()
+	if code != 0 || !strings.Contains(out, "Run 3/3") || !strings.Contains(out, "Statistics over 3 runs") {
+		t.Fatalf("code=%d\n%s", code, out)
+	}
+	reps, err := history.Recent(path, 10, true)
+	if err != nil || len(reps) != 3 {
+		t.Fatalf("history: %d reports, %v", len(reps), err)
+	}
+	for i, rep := range reps {
+		if rep.Run != i+1 || rep.Runs != 3 || (rep.RunStats != nil) != (i == 2) {
+			t.Errorf("report %d: run %d/%d, stats %v", i, rep.Run, rep.Runs, rep.RunStats)
+		}
+	}
+	if st := reps[2].RunStats; len(st) == 0 || st[0].Metric != report.DirDownload || st[0].N != 3 || st[0].Mean <= 0 {
+		t.Errorf("run stats = %+v", st)
+	}
+}
+
+// Machine-readable output must not follow the UI language: the report, the
+// event log's structured events and the --quiet line are identical in shape
+// whatever --lang says.
+func TestMachineOutputLocaleInvariant(t *testing.T) {
+	defer i18n.Set(i18n.Lang())
+	var names []string
+	for _, lang := range []string{"en", "zh", "zh-Hant", "ja"} {
+		cfg, err := config.Load("--lang", lang, "--simulate", "--simulate-opts", "bandwidth=0,latency=1ms,size=256K",
+			"--max", "256K", "--latency-count", "3", "--threads", "2", "--connection-mode", "multi", "--quiet")
+		if err != nil {
+			t.Fatal(err)
+		}
+		var out, events bytes.Buffer
+		old := stdout
+		stdout = &out
+		bus := render.NewBus(render.Multi(render.NewQuietRenderer(io.Discard), render.NewEventLog(&events)))
+		code := Run(context.Background(), cfg, bus, false)
+		bus.Close()
+		stdout = old
+		if code != 0 {
+			t.Fatalf("%s: exit code %d", lang, code)
+		}
+		if !regexp.MustCompile(`^down=\d+\.\d up=\d+\.\d latency=\d+\.\d\n$`).MatchString(out.String()) {
+			t.Errorf("%s: quiet line = %q", lang, out.String())
+		}
+		var rep *report.Report
+		for _, line := range strings.Split(strings.TrimSpace(events.String()), "\n") {
+			var ev struct {
+				Kind string
+				Data struct{ Report *report.Report }
+			}
+			if err := json.Unmarshal([]byte(line), &ev); err != nil {
+				t.Fatalf("%s: event %s: %v", lang, line, err)
+			}
+			if ev.Kind == "report" {
+				rep = ev.Data.Report
+			}
+		}
+		if rep == nil {
+			t.Fatalf("%s: no report event", lang)
+		}
+		var b bytes.Buffer
+		rep.WriteJSON(&b)
+		for _, c := range b.String() {
+			if unicode.In(c, unicode.Han, unicode.Hiragana, unicode.Katakana) {
+				t.Fatalf("%s: report contains %q:\n%s", lang, c, b.String())
+			}
+		}
+		var got []string
+		for _, rd := range rep.Rounds {
+			got = append(got, rd.Name)
+		}
+		if names == nil {
+			names = got
+		} else if !reflect.DeepEqual(got, names) {
+			t.Errorf("%s: round names %q, want %q", lang, got, names)
+		}
+	}
+	if len(names) != 4 || names[1] != "Download (multi-thread) · 2 TCP connections" {
+		t.Errorf("round names = %q", names)
+	}
+}
+
+func TestObserveResponse(t *testing.T) {
+	var buf bytes.Buffer
+	pr := render.NewPlainRenderer(&buf)
+	pr.Verbose = true
+	bus := render.NewBus(pr)
+	r := newRun(&config.Config{}, bus, false)
+	respond := func(stage string, kv ...string) {
+		ctx := context.WithValue(context.Background(), stageKey{}, stage)
+		req, _ := http.NewRequestWithContext(ctx, http.MethodGet, "https://mensura.cdn-apple.com/", nil)
+		resp := &http.Response{Header: http.Header{}, Request: req}
+		for i := 0; i < len(kv); i += 2 {
+			resp.Header.Set(kv[i], kv[i+1])
+		}
+		r.observeResponse(resp)
+	}
+	edge := "http/1.1 hkhkg3-edge-bx-008.ts.apple.com (acdn/268.14795)"
+	respond(config.StageIdleLatency)
+	respond(config.StageDownloadSingle, "CDNUUID", "a1", "Via", edge, "X-Cache", "miss")
+	respond(config.StageDownloadSingle, "CDNUUID", "a2", "Via", edge, "X-Cache", "hit-fresh")
+	respond(config.StageUploadSingle, "CDNUUID", "a3", "Via", edge, "X-Cache", "hit-fresh")
+	bus.Close()
+	if len(r.rep.CDN) != 2 || r.rep.CDN[0].ID != "a1" || r.rep.CDN[0].PoP != "hkhkg3" || r.rep.CDN[1].Stage != config.StageUploadSingle {
+		t.Fatalf("cdn = %+v", r.rep.CDN)
+	}
+	if got, want := r.servedBy(), "Apple hkhkg3-edge-bx-008.ts.apple.com (hkhkg3)"; got != want {
+		t.Errorf("servedBy = %q, want %q", got, want)
+	}
+	if !strings.Contains(buf.String(), "Served by Apple hkhkg3-edge-bx-008.ts.apple.com (hkhkg3, miss)") {
+		t.Errorf("output:\n%s", buf.String())
+	}
+}
+
+func TestObserveTLS(t *testing.T) {
+	ts := httptest.NewTLSServer(http.HandlerFunc(func(w http.ResponseWriter, _ *http.Request) {}))
+	defer ts.Close()
+	roots := x509.NewCertPool()
+	roots.AddCert(ts.Certificate())
+	conf := &tls.Config{RootCAs: roots, ClientSessionCache: tls.NewLRUClientSessionCache(4)}
+
+	var buf bytes.Buffer
+	bus := render.NewBus(render.NewPlainRenderer(&buf))
+	r := newRun(&config.Config{}, bus, false)
+	// Two clients share the session cache, so the second connection
+	// resumes the first one's session.
+	for range 2 {
+		client := netx.NewClient(netx.Options{TLS: conf, Observe: r.observeResponse})
+		for range 2 {
+			resp, err := client.Get(ts.URL)
+			if err != nil {
+				t.Fatal(err)
+			}
+			io.Copy(io.Discard, resp.Body)
+			resp.Body.Close()
+		}
+		client.CloseIdleConnections()
+	}
+	r.tlsSummary()
+	bus.Close()
+
+	if len(r.rep.TLS) != 1 {
+		t.Fatalf("tls = %+v", r.rep.TLS)
+	}
+	s := r.rep.TLS[0]
+	if s.Version != "TLS 1.3" || s.CipherSuite == "" || s.Connections != 2 || s.Resumed != 1 || !s.Untrusted ||
+		!strings.Contains(s.Issuer, "Acme Co") || !slices.Contains(s.SANs, "example.com") {
+		t.Errorf("session = %+v", s)
+	}
+	out := buf.String()
+	if !strings.Contains(out, "(2 connections, 1 resumed)") || !strings.Contains(out, "may be intercepting TLS") {
+		t.Errorf("output:\n%s", out)
+	}
+
+	tlsRoots = roots
+	defer func() { tlsRoots = nil }()
+	if !trusted([]*x509.Certificate{ts.Certificate()}) {
+		t.Error("certificate under its own root is untrusted")
+	}
+}
+
+func TestLatencyTargets(t *testing.T) {
+	oldPing, oldGateway := pingMeasure, defaultGateway
+	defer func() { pingMeasure, defaultGateway = oldPing, oldGateway }()
+	defaultGateway = func() (string, error) { return "192.168.1.1", nil }
+	idle := map[string]float64{"192.168.1.1": 1, "1.1.1.1": 10}
+	loaded := map[string]float64{"192.168.1.1": 2, "1.1.1.1": 60}
+	pingMeasure = func(ctx context.Context, ip string, n int, _, _ time.Duration) (ping.Result, error) {
+		ms, ok := idle[ip]
+		if !ok {
+			return ping.Result{}, errors.New("operation not permitted")
+		}
+		if n > 100 {
+			<-ctx.Done()
+			ms = loaded[ip]
+			return ping.Result{Sent: 3, Samples: []float64{ms, ms}}, nil
+		}
+		return ping.Result{Sent: n, Samples: []float64{ms, ms, ms}}, nil
+	}
+
+	var buf bytes.Buffer
+	bus := render.NewBus(render.NewPlainRenderer(&buf))
+	r := newRun(&config.Config{LatencyTargets: []string{"1.1.1.1", config.TargetGateway, "192.0.2.1"}, LatencyCount: 3}, bus, false)
+	if err := r.latencyTargets(context.Background()); err != nil {
+		t.Fatal(err)
+	}
+	for _, dir := range []transfer.Direction{transfer.Download, transfer.Upload} {
+		stop := r.startTargets(context.Background())
+		stop(dir)
+	}
+	r.targetSummary()
+	bus.Close()
+
+	got := r.rep.LatencyTargets
+	if len(got) != 3 || got[1].IP != "192.168.1.1" || got[1].Idle.MedianMs != 1 || got[1].Download.MedianMs != 2 || got[1].Upload.MedianMs != 2 {
+		t.Fatalf("targets = %+v", got)
+	}
+	if got[0].LoadedLossPct != 0 || got[2].Error == "" || got[2].Idle != nil {
+		t.Errorf("targets = %+v", got)
+	}
+	out := buf.String()
+	for _, want := range []string{"gateway (192.168.1.1)", "idle 10.0  ↓ 60.0  ↑ 60.0 ms", "Latency to 1.1.1.1, the nearest target affected, rises 50 ms", "Cannot ping latency target 192.0.2.1"} {
+		if !strings.Contains(out, want) {
+			t.Errorf("missing %q in\n%s", want, out)
+		}
+	}
+}
+
+func TestDiscoverURLs(t *testing.T) {
+	t.Setenv("SPEEDTEST_LANG", "en")
+	fail := false
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if fail || r.URL.Path != discover.Path {
+			http.NotFound(w, r)
+			return
+		}
+		w.Write([]byte(`{"version":1,"test_endpoint":"hkhkg3-edge-bx-008.aaplimg.com","urls":{
+			"small_https_download_url":"https://hk.cdn-apple.com/small","large_https_download_url":"https://hk.cdn-apple.com/large",
+			"https_upload_url":"https://hk.cdn-apple.com/slurp"}}`))
+	}))
+	defer ts.Close()
+
+	var buf bytes.Buffer
+	bus := render.NewBus(render.NewPlainRenderer(&buf))
+	r := newRun(&config.Config{DLURL: ts.URL + "/api/v1/gm/large", Discover: true}, bus, false)
+	if err := r.discoverURLs(context.Background()); err != nil {
+		t.Fatal(err)
+	}
+	if r.cfg.DLURL != "https://hk.cdn-apple.com/large" || r.cfg.ULURL != "https://hk.cdn-apple.com/slurp" ||
+		r.cfg.LatencyURL != "https://hk.cdn-apple.com/small" || r.cdnHost != "hk.cdn-apple.com" {
+		t.Errorf("cfg = %+v, cdnHost %q", r.cfg, r.cdnHost)
+	}
+	if r.rep.TestEndpoint != "hkhkg3-edge-bx-008.aaplimg.com" || r.rep.Config.DLURL != r.cfg.DLURL || r.isDegraded() {
+		t.Errorf("report = %+v, degraded %v", r.rep, r.isDegraded())
+	}
+
+	// The next run reuses the answer without fetching again.
+	fail = true
+	n := r.next()
+	if err := n.discoverURLs(context.Background()); err != nil || n.rep.TestEndpoint != r.rep.TestEndpoint || n.isDegraded() {
+		t.Errorf("second run: %q, degraded %v, %v", n.rep.TestEndpoint, n.isDegraded(), err)
+	}
+
+	r = newRun(&config.Config{DLURL: ts.URL + "/api/v1/gm/large", Discover: true}, bus, false)
+	r.discoverURLs(context.Background())
+	bus.Close()
+	if !r.isDegraded() || r.cfg.DLURL != ts.URL+"/api/v1/gm/large" || r.rep.TestEndpoint != "" {
+		t.Errorf("failed discovery: degraded %v, cfg %+v", r.isDegraded(), r.cfg)
+	}
+	out := buf.String()
+	for _, want := range []string{"Test endpoint assigned by Apple: hkhkg3-edge-bx-008.aaplimg.com", "Same test URLs as run 1.",
+		"Config discovery failed, keeping the configured URLs: HTTP 404"} {
+		if !strings.Contains(out, want) {
+			t.Errorf("output lacks %q:\n%s", want, out)
+		}
+	}
+}
+
+func TestIfaceCheck(t *testing.T) {
+	t.Setenv("SPEEDTEST_LANG", "en")
+	oldRead, oldRoute := readIfCounters, routeInterface
+	defer func() { readIfCounters, routeInterface = oldRead, oldRoute }()
+	routeInterface = func(ip string) string {
+		if ip == "192.0.2.10" {
+			return "eth0"
+		}
+		return ""
+	}
+	var counters ifstat.Counters
+	readIfCounters = func(string) (ifstat.Counters, error) { return counters, nil }
+
+	var buf bytes.Buffer
+	bus := render.NewBus(render.NewPlainRenderer(&buf))
+	r := newRun(&config.Config{}, bus, false)
+	r.ep.IP = "192.0.2.10"
+	for _, tc := range []struct {
+		dir          transfer.Direction
+		rx, tx       uint64
+		bytes        int64
+		overhead     float64
+		otherTraffic bool
+	}{
+		{transfer.Download, 10_500_000, 300_000, 10_000_000, 1.05, false},
+		{transfer.Upload, 2_000_000, 30_000_000, 20_000_000, 1.5, true},
+		{transfer.Download, 5_000_000, 0, 10_000_000, 0.5, false},
+	} {
+		counters = ifstat.Counters{RxBytes: 1 << 40, TxBytes: 1 << 40}
+		check := r.startIfaceCheck()
+		counters.RxBytes += tc.rx
+		counters.TxBytes += tc.tx
+		c := check(tc.dir, tc.bytes)
+		if c == nil || c.Name != "eth0" || c.Overhead != tc.overhead || c.OtherTraffic != tc.otherTraffic {
+			t.Errorf("%v: %+v", tc.dir, c)
+		}
+		showIface(bus, c)
+	}
+	if c := r.startIfaceCheck()(transfer.Download, 1000); c != nil {
+		t.Errorf("tiny round checked: %+v", c)
+	}
+	r.ep.IP = "192.0.2.99"
+	if c := r.startIfaceCheck()(transfer.Download, 10_000_000); c != nil {
+		t.Errorf("unrouted interface checked: %+v", c)
+	}
+	bus.Close()
+	out := buf.String()
+	for _, want := range []string{"eth0 carried 1.50× the test's bytes: other traffic", "eth0 carried only 0.50× the test's bytes"} {
+		if !strings.Contains(out, want) {
+			t.Errorf("missing %q in\n%s", want, out)
+		}
+	}
+}
+
+func TestShowIPComparison(t *testing.T) {
+	var buf bytes.Buffer
+	bus := render.NewBus(render.NewPlainRenderer(&buf))
+	showIPComparison(bus, "17.253.1.1", "2403:300::1", []report.IPDelta{
+		{Metric: report.DirDownload, Unit: "Mbps", IPv4: 400, IPv6: 300, Delta: -100, DeltaPct: -25},
+		{Metric: report.DirUpload, Unit: "Mbps", IPv4: 40, IPv6: 42, Delta: 2, DeltaPct: 5},
+		{Metric: "latency", Unit: "ms", IPv4: 3, IPv6: 4, Delta: 1, DeltaPct: 33.3},
+	})
+	showIPComparison(bus, "17.253.1.1", "", nil)
+	bus.Close()
+	out := buf.String()
+	for _, want := range []string{
+		"IPv6 endpoint:", "2403:300::1",
+		"    400.00      300.00  -100.00 Mbps (-25.0%)",
+		"Download is 25% apart between IPv4 and IPv6, in favour of IPv4",
+		"No metric was measured over both IP versions.",
+	} {
+		if !strings.Contains(out, want) {
+			t.Errorf("output lacks %q:\n%s", want, out)
+		}
+	}
+	// Under 20% for upload, and only 1 ms for latency.
+	if strings.Count(out, "apart between") != 1 {
+		t.Errorf("want one finding:\n%s", out)
+	}
+}
+
+func TestSysInfoStage(t *testing.T) {
+	t.Setenv("SPEEDTEST_LANG", "en")
+	oldRoute, oldGateway, oldLink, oldDNS := routeInterface, defaultGateway, readLink, dnsServers
+	defer func() { routeInterface, defaultGateway, readLink, dnsServers = oldRoute, oldGateway, oldLink, oldDNS }()
+	routeInterface = func(ip string) string {
+		if ip == "192.168.1.1" {
+			return "wlan0"
+		}
+		return ""
+	}
+	defaultGateway = func() (string, error) { return "192.168.1.1", nil }
+	readLink = func(name string) (sysinfo.Link, error) {
+		return sysinfo.Link{WiFi: &sysinfo.WiFi{SSID: "home", RSSIdBm: -61, NoisedBm: -92, TxMbps: 144.4, Channel: "6"}}, nil
+	}
+	dnsServers = func() ([]string, error) { return []string{"192.168.1.1", "1.1.1.1"}, nil }
+
+	var buf bytes.Buffer
+	bus := render.NewBus(render.NewPlainRenderer(&buf))
+	r := newRun(&config.Config{SysInfo: true}, bus, false)
+	r.ep.IP = "17.253.1.1"
+	if err := r.sysInfo(context.Background()); err != nil {
+		t.Fatal(err)
+	}
+	sys := r.rep.System
+	if sys == nil || sys.Interface != "wlan0" || sys.Gateway != "192.168.1.1" || sys.WiFi == nil || sys.WiFi.TxMbps != 144.4 || len(sys.DNS) != 2 {
+		t.Fatalf("system = %+v", sys)
+	}
+	r.rep.Rounds = []report.Round{{Direction: report.DirDownload, Mbps: 300}}
+	r.linkAdvice()
+	r.rep.Rounds[0].Mbps = 60
+	r.linkAdvice()
+
+	r.rep.System = &report.System{Interface: "eth0", LinkMbps: 100}
+	r.rep.Rounds[0].Mbps = 94
+	r.linkAdvice()
+	if got := linkLabel(r.rep.System); got != "eth0, 100 Mbps" {
+		t.Errorf("linkLabel = %q", got)
+	}
+	r.rep.Rounds[0].Mbps = 50
+	r.linkAdvice()
+	bus.Close()
+	out := buf.String()
+	for _, want := range []string{`"home"  -61 dBm (noise -92)  PHY 144.4 Mbps  ch 6`, "192.168.1.1, 1.1.1.1",
+		"exceeds the Wi-Fi PHY rate of 144.4 Mbps", "close to what the 100 Mbps local link carries"} {
+		if !strings.Contains(out, want) {
+			t.Errorf("output lacks %q:\n%s", want, out)
+		}
+	}
+	// 60 Mbps of Wi-Fi and 50 Mbps of Ethernet leave headroom.
+	if strings.Count(out, "local link carries") != 1 || strings.Count(out, "exceeds the Wi-Fi") != 1 {
+		t.Errorf("want one finding each:\n%s", out)
+	}
+}
+
+func TestDiagnostics(t *testing.T) {
+	t.Setenv("SPEEDTEST_LANG", "en")
+	rounds := []report.Round{
+		{Direction: report.DirDownload, Faults: 1, FaultKinds: map[string]int{"http_status": 1}},
+		{Direction: report.DirUpload, Faults: 2, FaultKinds: map[string]int{"reset": 2}},
+		{Direction: report.DirUpload, Faults: 2, FaultKinds: map[string]int{"reset": 1, "read_timeout": 1}},
+		{Direction: report.DirDownload},
+	}
+	want := []report.Diagnostic{
+		{Direction: report.DirUpload, Fault: "reset", Count: 3},
+		{Direction: report.DirDownload, Fault: "http_status", Count: 1},
+		{Direction: report.DirUpload, Fault: "read_timeout", Count: 1},
+	}
+	if got := faultDiagnostics(rounds); !reflect.DeepEqual(got, want) {
+		t.Errorf("faultDiagnostics = %+v, want %+v", got, want)
+	}
+	if got := faultDiagnostics(rounds[3:]); got != nil {
+		t.Errorf("no faults = %+v", got)
+	}
+
+	var buf bytes.Buffer
+	bus := render.NewBus(render.NewPlainRenderer(&buf))
+	r := newRun(&config.Config{}, bus, false)
+	r.rep.Rounds = rounds
+	r.idle.Median = 20
+	r.rep.ICMPLatency = &report.Latency{MedianMs: 45}
+	r.rep.LatencyDiscrepancy = latencyDiscrepancy(20, 45)
+	r.diagnostics()
+	bus.Close()
+	want = append(want, report.Diagnostic{Finding: report.ICMPSlower, HTTPMs: 20, ICMPMs: 45})
+	if !reflect.DeepEqual(r.rep.Diagnostics, want) {
+		t.Errorf("report diagnostics = %+v", r.rep.Diagnostics)
+	}
+	out := buf.String()
+	for _, line := range []string{"> Diagnostics", "3× connection reset during upload — possible ISP upload policing",
+		"1× HTTP error status during download", "1× read timeout during upload",
+		"ICMP latency (45.0 ms) is well above HTTP (20.0 ms)"} {
+		if !strings.Contains(out, line) {
+			t.Errorf("output lacks %q:\n%s", line, out)
+		}
+	}
+}
+
+func TestURLFailover(t *testing.T) {
+	down := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusServiceUnavailable)
+	}))
+	defer down.Close()
+	cfg, err := config.Load("--simulate", "--simulate-opts", "latency=1ms", "--dl-url", "http://a.example/large,http://b.example/large")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if cfg.DLURL != "http://a.example/large" || len(cfg.DLFallbacks) != 1 || !cfg.HasFallbacks() {
+		t.Fatalf("DL_URL list = %q, %q", cfg.DLURL, cfg.DLFallbacks)
+	}
+	srv := simulate.Start(cfg.Sim)
+	defer srv.Close()
+	c := simulatedConfig(cfg, srv)
+	if c.HasFallbacks() {
+		t.Errorf("simulated config keeps fallbacks %q", c.DLFallbacks)
+	}
+	good := c.DLURL
+	c.DLURL, c.DLFallbacks = down.URL+"/a", []string{down.URL + "/b?token=secret", good}
+	c.ULFallbacks = []string{down.URL + "/up"}
+	var buf bytes.Buffer
+	bus := render.NewBus(render.NewPlainRenderer(&buf))
+	r := newRun(c, bus, false)
+	if err := r.urlCheck(context.Background()); err != nil {
+		t.Fatal(err)
+	}
+	if r.cfg.DLURL != good || len(r.cfg.DLFallbacks) != 0 || len(r.rep.Failovers) != 2 || r.isDegraded() {
+		t.Fatalf("DL_URL %s, failovers %+v\n%s", r.cfg.DLURL, r.rep.Failovers, buf.String())
+	}
+	if f := r.rep.Failovers[0]; f.URL != "dl_url" || f.Stage != config.StageURLCheck || f.Reason != "HTTP 503" || f.To != down.URL+"/b?token=secret" {
+		t.Errorf("failover = %+v", f)
+	}
+	if f := r.rep.Anonymized().Failovers[1]; f.From != down.URL+"/b" {
+		t.Errorf("anonymized failover = %+v", f)
+	}
+	// The upload URL answers and keeps its fallback.
+	if len(r.cfg.ULFallbacks) != 1 {
+		t.Errorf("UL fallbacks = %q", r.cfg.ULFallbacks)
+	}
+
+	// A failing URL with nothing left to fall back on degrades the run.
+	r.cfg.DLURL, r.cfg.DLFallbacks = down.URL+"/c", []string{down.URL + "/d"}
+	r.recheck(context.Background(), config.StageDownloadMulti, transfer.Download)
+	bus.Close()
+	if r.cfg.DLURL != down.URL+"/d" || len(r.rep.Failovers) != 3 || !r.isDegraded() || !strings.Contains(buf.String(), "no fallback is left") {
+		t.Errorf("DL_URL %s, failovers %+v\n%s", r.cfg.DLURL, r.rep.Failovers, buf.String())
+	}
+}
+
+func TestCheck(t *testing.T) {
+	cfg, err := config.Load("check", "--simulate", "--simulate-opts", "latency=1ms")
+	if err != nil {
+		t.Fatal(err)
+	}
+	var buf bytes.Buffer
+	bus := render.NewBus(render.NewPlainRenderer(&buf))
+	code := Check(context.Background(), cfg, bus)
+	bus.Close()
+	if code != 0 {
+		t.Fatalf("exit code = %d\n%s", code, buf.String())
+	}
+	for _, want := range []string{"Configuration is valid.", "GET HTTP 206", "PUT HTTP", "All test URLs are reachable."} {
+		if !strings.Contains(buf.String(), want) {
+			t.Errorf("output lacks %q:\n%s", want, buf.String())
+		}
+	}
+	if strings.Contains(buf.String(), "Download (single thread)") {
+		t.Errorf("check ran a transfer round:\n%s", buf.String())
+	}
+
+	cfg, err = config.Load("--dry-run", "--simulate", "--simulate-opts", "latency=1ms,errors=1,status=503")
+	if err != nil {
+		t.Fatal(err)
+	}
+	buf.Reset()
+	bus = render.NewBus(render.NewPlainRenderer(&buf))
+	code = Check(context.Background(), cfg, bus)
+	bus.Close()
+	if code != 2 || !strings.Contains(buf.String(), "Download URL unreachable: HTTP 503") {
+		t.Fatalf("exit code = %d\n%s", code, buf.String())
+	}
+}
+
+func TestAPI(t *testing.T) {
+	cfg, err := config.Load("--runs", "3", "--api", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	var buf bytes.Buffer
+	bus := render.NewBus(render.NewPlainRenderer(&buf))
+	ctl := newRun(cfg, bus, false).control(context.Background())
+	defer ctl.stop()
+	srv := httptest.NewServer(ctl.apiHandler())
+	defer srv.Close()
+	get := func(path string, v any) int {
+		t.Helper()
+		resp, err := http.Get(srv.URL + path)
+		if err != nil {
+			t.Fatal(err)
+		}
+		defer resp.Body.Close()
+		if v != nil && resp.StatusCode == http.StatusOK {
+			if err := json.NewDecoder(resp.Body).Decode(v); err != nil {
+				t.Fatal(err)
+			}
+		}
+		return resp.StatusCode
+	}
+
+	if code := get("/results/latest", nil); code != http.StatusNotFound {
+		t.Errorf("latest before any run: %d", code)
+	}
+	t0 := time.Date(2026, 5, 1, 12, 0, 0, 0, time.UTC)
+	for i, down := range []float64{100, 200} {
+		ctl.publish(&report.Report{Time: t0.Add(time.Duration(i) * time.Hour), Rounds: []report.Round{{Direction: report.DirDownload, Mbps: down}}})
+	}
+	var latest report.Report
+	if code := get("/results/latest", &latest); code != http.StatusOK || latest.Best(report.DirDownload) != 200 {
+		t.Errorf("latest: %d %+v", code, latest)
+	}
+	var reps []report.Report
+	if code := get("/results?since="+t0.Add(30*time.Minute).Format(time.RFC3339), &reps); code != http.StatusOK || len(reps) != 1 || !reps[0].Time.Equal(t0.Add(time.Hour)) {
+		t.Errorf("since: %d %+v", code, reps)
+	}
+	if code := get("/results", &reps); code != http.StatusOK || len(reps) != 2 {
+		t.Errorf("all: %d %d reports", code, len(reps))
+	}
+	if code := get("/results?since=yesterday", nil); code != http.StatusBadRequest {
+		t.Errorf("bad since: %d", code)
+	}
+	if code := get("/run", nil); code != http.StatusMethodNotAllowed {
+		t.Errorf("GET /run: %d", code)
+	}
+
+	// Without a token, only a script's JSON starts a run: a form or a
+	// fetch from a web page could come from any site the browser shows.
+	for _, tc := range []struct {
+		ctype, origin string
+		want          int
+	}{
+		{"", "", http.StatusForbidden},
+		{"application/x-www-form-urlencoded", "", http.StatusForbidden},
+		{"application/json", "https://evil.example", http.StatusForbidden},
+		{"application/json; charset=utf-8", "", http.StatusAccepted},
+	} {
+		req, _ := http.NewRequest(http.MethodPost, srv.URL+"/run", strings.NewReader("{}"))
+		req.Header.Set("Content-Type", tc.ctype)
+		if tc.origin != "" {
+			req.Header.Set("Origin", tc.origin)
+		}
+		resp, err := http.DefaultClient.Do(req)
+		if err != nil {
+			t.Fatal(err)
+		}
+		resp.Body.Close()
+		if resp.StatusCode != tc.want {
+			t.Errorf("POST /run %q from %q: %d, want %d", tc.ctype, tc.origin, resp.StatusCode, tc.want)
+		}
+		select {
+		case <-ctl.runNow:
+			if tc.want != http.StatusAccepted {
+				t.Errorf("POST /run %q from %q woke the series", tc.ctype, tc.origin)
+			}
+		default:
+			if tc.want == http.StatusAccepted {
+				t.Error("POST /run did not wake the series")
+			}
+		}
+	}
+}
+
+// banners records the banners of a run and cancels it at the one named
+// stop.
+type banners struct {
+	stop   string
+	cancel context.CancelFunc
+	seen   []string
+}
+
+func (b *banners) Render(ev render.Event) {
+	if ev.Kind != render.KindBanner {
+		return
+	}
+	b.seen = append(b.seen, ev.Value)
+	if ev.Value == b.stop {
+		b.cancel()
+	}
+}
+
+func TestRunUntilInterrupted(t *testing.T) {
+	t.Setenv("SPEEDTEST_LANG", "en")
+	cfg, err := config.Load("--simulate", "--simulate-opts", "bandwidth=0,latency=1ms,size=256K",
+		"--max", "256K", "--timeout", "5", "--latency-count", "3", "--runs", "0", "--cooldown", "0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	rec := &banners{stop: "Run 3", cancel: cancel}
+	bus := render.NewBus(rec)
+	code := Run(ctx, cfg, bus, false)
+	bus.Close()
+
+	if code != 130 {
+		t.Errorf("exit code = %d, want 130", code)
+	}
+	var runs []string
+	for _, b := range rec.seen {
+		if strings.HasPrefix(b, "Run ") {
+			runs = append(runs, b)
+		}
+	}
+	if want := []string{"Run 1", "Run 2", "Run 3"}; !slices.Equal(runs, want) {
+		t.Errorf("banners = %q, want %q", runs, want)
+	}
+}
+
+func TestKeepLatest(t *testing.T) {
+	var reps []*report.Report
+	for i := range keptReports + 5 {
+		reps = keepLatest(append(reps, &report.Report{Run: i + 1}))
+	}
+	if len(reps) != keptReports || reps[0].Run != 6 || reps[len(reps)-1].Run != keptReports+5 {
+		t.Errorf("kept %d reports, runs %d to %d", len(reps), reps[0].Run, reps[len(reps)-1].Run)
+	}
+}
+
+func TestAPIToken(t *testing.T) {
+	t.Setenv("API_TOKEN", "s3cret")
+	cfg, err := config.Load("--runs", "3", "--api", "0.0.0.0:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	bus := render.NewBus(render.NewPlainRenderer(io.Discard))
+	ctl := newRun(cfg, bus, false).control(context.Background())
+	defer ctl.stop()
+	srv := httptest.NewServer(ctl.apiHandler())
+	defer srv.Close()
+
+	for _, auth := range []string{"", "Bearer wrong", "s3cret", "Bearer s3cret"} {
+		req, _ := http.NewRequest(http.MethodPost, srv.URL+"/run", nil)
+		if auth != "" {
+			req.Header.Set("Authorization", auth)
+		}
+		resp, err := http.DefaultClient.Do(req)
+		if err != nil {
+			t.Fatal(err)
+		}
+		resp.Body.Close()
+		want := http.StatusUnauthorized
+		if auth == "Bearer s3cret" {
+			want = http.StatusAccepted
+		}
+		if resp.StatusCode != want {
+			t.Errorf("Authorization %q: %d, want %d", auth, resp.StatusCode, want)
+		}
+	}
+	select {
+	case <-ctl.runNow:
+	default:
+		t.Error("an authorized POST /run did not wake the series")
+	}
+}
@@ -0,0 +1,57 @@
+package runner
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"path/filepath"
+	"runtime"
+
+	"github.com/tsosunchia/iNetSpeed-CLI/internal/i18n"
+	"github.com/tsosunchia/iNetSpeed-CLI/internal/render"
+	"github.com/tsosunchia/iNetSpeed-CLI/internal/report"
+	"github.com/tsosunchia/iNetSpeed-CLI/internal/update"
+)
+
+// SelfUpdate runs the `self-update` command: it looks up the latest
+// release and, when it is newer than this build, downloads the binary for
+// this platform next to the running one, checks it against the release's
+// SHA-256 checksums and moves it into place. Exit codes: 0 up to date or
+// updated, 1 failed.
+func SelfUpdate(ctx context.Context, bus *render.Bus) int {
+	bus.Line()
+	bus.Banner("\u26a1 iNetSpeed-CLI")
+	bus.Header(i18n.Text("Self-Update", "自动更新"))
+	bus.KV(i18n.Text("Current Version", "当前版本"), report.Version)
+	rel, err := update.Latest(ctx)
+	if err != nil {
+		bus.Fatal(fmt.Sprintf(i18n.Text("Could not look up the latest release: %v", "无法查询最新版本: %v"), err))
+		return 1
+	}
+	bus.KV(i18n.Text("Latest Release", "最新版本"), rel.Tag)
+	if !update.Newer(rel.Tag, report.Version) {
+		bus.Result(i18n.Text("Already up to date.", "已是最新版本。"))
+		bus.Line()
+		return 0
+	}
+	exe, err := update.Executable()
+	if err != nil {
+		bus.Fatal(fmt.Sprintf(i18n.Text("Cannot locate this binary: %v", "无法定位当前程序: %v"), err))
+		return 1
+	}
+	bus.Info(fmt.Sprintf(i18n.Text("Downloading %s ...", "正在下载 %s ..."), update.AssetName(runtime.GOOS, runtime.GOARCH)))
+	path, err := rel.Download(ctx, runtime.GOOS, runtime.GOARCH, filepath.Dir(exe))
+	if err != nil {
+		bus.Fatal(fmt.Sprintf(i18n.Text("Download failed: %v", "下载失败: %v"), err))
+		return 1
+	}
+	bus.Info(i18n.Text("SHA-256 checksum verified.", "SHA-256 校验通过。"))
+	if err := update.Replace(exe, path); err != nil {
+		os.Remove(path)
+		bus.Fatal(fmt.Sprintf(i18n.Text("Could not replace %s: %v", "无法替换 %s: %v"), exe, err))
+		return 1
+	}
+	bus.Result(fmt.Sprintf(i18n.Text("Updated %s to %s.", "已将 %s 更新至 %s。"), exe, rel.Tag))
+	bus.Line()
+	return 0
+}
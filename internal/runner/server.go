@@ -0,0 +1,37 @@
+package runner
+
+import (
+	"context"
+	"fmt"
+	"net"
+
+	"github.com/tsosunchia/iNetSpeed-CLI/internal/config"
+	"github.com/tsosunchia/iNetSpeed-CLI/internal/i18n"
+	"github.com/tsosunchia/iNetSpeed-CLI/internal/netx"
+	"github.com/tsosunchia/iNetSpeed-CLI/internal/render"
+	"github.com/tsosunchia/iNetSpeed-CLI/internal/udpprobe"
+)
+
+// Server runs the `server` command: a UDP echo reflector for --udp-echo that
+// serves until interrupted. Exit codes: 0 stopped by a signal, 1 failed.
+func Server(ctx context.Context, cfg *config.Config, bus *render.Bus) int {
+	bus.Line()
+	bus.Banner("\u26a1 iNetSpeed-CLI")
+	bus.Header(i18n.Text("UDP Echo Server", "UDP 回显服务"))
+	// Replies carry --dscp too, so downstream marking can be tested.
+	lc := net.ListenConfig{Control: netx.TOSControl(cfg.TOS)}
+	pc, err := lc.ListenPacket(ctx, "udp", cfg.Listen)
+	if err != nil {
+		bus.Fatal(fmt.Sprintf(i18n.Text("Cannot listen on %s: %v", "无法监听 %s: %v"), cfg.Listen, err))
+		return 1
+	}
+	defer pc.Close()
+	bus.Info(fmt.Sprintf(i18n.Text("Listening on %s (UDP); stop with Ctrl+C.", "正在监听 %s（UDP），按 Ctrl+C 停止。"), pc.LocalAddr()))
+	if err := udpprobe.Serve(ctx, pc); err != nil {
+		bus.Fatal(fmt.Sprintf(i18n.Text("Echo server failed: %v", "回显服务出错: %v"), err))
+		return 1
+	}
+	bus.Info(i18n.Text("Stopped.", "已停止。"))
+	bus.Line()
+	return 0
+}
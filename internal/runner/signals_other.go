@@ -0,0 +1,12 @@
+//go:build !unix
+
+package runner
+
+import "os"
+
+// Windows has no user signals: a --runs series runs as scheduled.
+var (
+	sigReload, sigRunNow, sigDump os.Signal
+
+	controlSignals []os.Signal
+)
@@ -0,0 +1,17 @@
+//go:build unix
+
+package runner
+
+import (
+	"os"
+	"syscall"
+)
+
+// The signals steering a --runs series; see control.
+var (
+	sigReload os.Signal = syscall.SIGHUP
+	sigRunNow os.Signal = syscall.SIGUSR1
+	sigDump   os.Signal = syscall.SIGUSR2
+
+	controlSignals = []os.Signal{sigReload, sigRunNow, sigDump}
+)
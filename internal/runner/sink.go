@@ -0,0 +1,40 @@
+package runner
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/tsosunchia/iNetSpeed-CLI/internal/i18n"
+	"github.com/tsosunchia/iNetSpeed-CLI/internal/sink"
+)
+
+// pushMetrics sends the finished run to INFLUX_URL and GRAPHITE_ADDR and
+// writes it to TEXTFILE. Like the history file, an unreachable sink costs a
+// warning, not the exit code.
+func (r *run) pushMetrics(ctx context.Context) {
+	if r.cfg.InfluxURL == "" && r.cfg.GraphiteAddr == "" && r.cfg.Textfile == "" {
+		return
+	}
+	m := sink.FromReport(r.rep, sink.Interface(r.rep.Server.IP))
+	if r.cfg.InfluxURL != "" {
+		if err := sink.WriteInflux(ctx, r.cfg.InfluxURL, r.cfg.InfluxToken, m); err != nil {
+			r.bus.Warn(fmt.Sprintf(i18n.Text("Could not write metrics to InfluxDB: %v", "无法将指标写入 InfluxDB: %v"), err))
+		} else {
+			r.bus.Debug(i18n.Text("Metrics written to InfluxDB.", "指标已写入 InfluxDB。"))
+		}
+	}
+	if r.cfg.GraphiteAddr != "" {
+		if err := sink.SendGraphite(ctx, r.cfg.GraphiteAddr, m); err != nil {
+			r.bus.Warn(fmt.Sprintf(i18n.Text("Could not send metrics to Graphite: %v", "无法将指标发送至 Graphite: %v"), err))
+		} else {
+			r.bus.Debug(fmt.Sprintf(i18n.Text("Metrics sent to Graphite at %s.", "指标已发送至 Graphite %s。"), r.cfg.GraphiteAddr))
+		}
+	}
+	if r.cfg.Textfile != "" {
+		if err := sink.WriteTextfile(r.cfg.Textfile, m); err != nil {
+			r.bus.Warn(fmt.Sprintf(i18n.Text("Could not write metrics textfile: %v", "无法写入指标文本文件: %v"), err))
+		} else {
+			r.bus.Debug(fmt.Sprintf(i18n.Text("Metrics written to %s.", "指标已写入 %s。"), r.cfg.Textfile))
+		}
+	}
+}
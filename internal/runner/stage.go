@@ -0,0 +1,264 @@
+package runner
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sync"
+	"time"
+
+	"github.com/tsosunchia/iNetSpeed-CLI/internal/render"
+)
+
+// Stage is a node in the run graph.
+type Stage struct {
+	Name string
+	// Needs lists stages that must finish before this one starts. A disabled
+	// dependency counts as finished; a failed one skips this stage.
+	Needs []string
+	// A Parallel stage does not wait for the stages declared before it in
+	// its dependency level and runs alongside the rest of the level. It
+	// prints through stageBus, which holds its output back until it has
+	// ended and the stage running beside it has too.
+	Parallel bool
+	// Timeout bounds the stage's context; zero means no extra deadline.
+	Timeout  time.Duration
+	Disabled bool
+	Run      func(ctx context.Context) error
+}
+
+// ErrDependencyFailed is reported for stages skipped because a stage they
+// need returned an error.
+var ErrDependencyFailed = errors.New("dependency failed")
+
+// StageError records which stage failed and why.
+type StageError struct {
+	Stage string
+	Err   error
+}
+
+func (e *StageError) Error() string { return e.Stage + ": " + e.Err.Error() }
+func (e *StageError) Unwrap() error { return e.Err }
+
+type stageKey struct{}
+
+// stageName returns the name of the stage whose context ctx derives from,
+// or "" outside the graph.
+func stageName(ctx context.Context) string {
+	name, _ := ctx.Value(stageKey{}).(string)
+	return name
+}
+
+type stageBusKey struct{}
+
+// stageBus returns the bus a stage running on ctx prints through: the held
+// one of a Parallel stage, else bus.
+func stageBus(ctx context.Context, bus *render.Bus) *render.Bus {
+	if held, ok := ctx.Value(stageBusKey{}).(*render.Bus); ok {
+		return held
+	}
+	return bus
+}
+
+// Graph executes stages in dependency order.
+type Graph struct {
+	stages []*Stage
+	byName map[string]*Stage
+
+	// Events, when set, receives a KindStage event as each stage starts,
+	// ends or is skipped for a failed dependency.
+	Events *render.Bus
+}
+
+func NewGraph() *Graph {
+	return &Graph{byName: map[string]*Stage{}}
+}
+
+// Add registers a stage. Stages are ordered by declaration within each
+// dependency level, so the declaration order is the output order.
+func (g *Graph) Add(s Stage) {
+	st := s
+	g.stages = append(g.stages, &st)
+	g.byName[s.Name] = &st
+}
+
+// Names returns the registered stage names in declaration order.
+func (g *Graph) Names() []string {
+	out := make([]string, 0, len(g.stages))
+	for _, s := range g.stages {
+		out = append(out, s.Name)
+	}
+	return out
+}
+
+// Levels groups stages into dependency levels (Kahn's algorithm) while
+// preserving declaration order inside each level.
+func (g *Graph) Levels() ([][]*Stage, error) {
+	indeg := make(map[string]int, len(g.stages))
+	for _, s := range g.stages {
+		if _, dup := indeg[s.Name]; dup {
+			return nil, fmt.Errorf("duplicate stage %q", s.Name)
+		}
+		indeg[s.Name] = 0
+	}
+	for _, s := range g.stages {
+		for _, dep := range s.Needs {
+			if _, ok := g.byName[dep]; !ok {
+				return nil, fmt.Errorf("stage %q needs unknown stage %q", s.Name, dep)
+			}
+			indeg[s.Name]++
+		}
+	}
+
+	var levels [][]*Stage
+	done := 0
+	placed := map[string]bool{}
+	for done < len(g.stages) {
+		var level []*Stage
+		for _, s := range g.stages {
+			if !placed[s.Name] && indeg[s.Name] == 0 {
+				level = append(level, s)
+			}
+		}
+		if len(level) == 0 {
+			return nil, errors.New("stage graph has a cycle")
+		}
+		for _, s := range level {
+			placed[s.Name] = true
+			done++
+		}
+		for _, s := range g.stages {
+			for _, dep := range s.Needs {
+				for _, l := range level {
+					if l.Name == dep {
+						indeg[s.Name]--
+					}
+				}
+			}
+		}
+		levels = append(levels, level)
+	}
+	return levels, nil
+}
+
+func (g *Graph) emit(name, phase string, data map[string]any) {
+	if g.Events != nil {
+		g.Events.Stage(name, phase, data)
+	}
+}
+
+// Execute runs every enabled stage, level by level. It stops launching new
+// stages once ctx is cancelled and returns ctx.Err(); otherwise it returns
+// the joined errors of failed stages (nil when all succeeded). A failed
+// stage's dependents are skipped.
+func (g *Graph) Execute(ctx context.Context) error {
+	levels, err := g.Levels()
+	if err != nil {
+		return err
+	}
+
+	// Each start event carries the stage's place among the enabled ones, so
+	// a display can show how far the run has got.
+	step := map[string]int{}
+	for _, level := range levels {
+		for _, s := range level {
+			if !s.Disabled {
+				step[s.Name] = len(step) + 1
+			}
+		}
+	}
+
+	var (
+		mu     sync.Mutex
+		failed = map[string]bool{}
+		errs   []error
+	)
+	runOne := func(sctx context.Context, s *Stage) {
+		mu.Lock()
+		for _, dep := range s.Needs {
+			if failed[dep] {
+				failed[s.Name] = true
+				errs = append(errs, &StageError{Stage: s.Name, Err: ErrDependencyFailed})
+				mu.Unlock()
+				g.emit(s.Name, "skipped", map[string]any{"needs": dep})
+				return
+			}
+		}
+		mu.Unlock()
+
+		sctx, cancel := context.WithValue(sctx, stageKey{}, s.Name), context.CancelFunc(func() {})
+		if s.Timeout > 0 {
+			sctx, cancel = context.WithTimeout(sctx, s.Timeout)
+		}
+		g.emit(s.Name, "start", map[string]any{"step": step[s.Name], "steps": len(step)})
+		start := time.Now()
+		err := s.Run(sctx)
+		cancel()
+		end := map[string]any{"duration_ms": float64(time.Since(start).Microseconds()) / 1000}
+		if err != nil {
+			end["error"] = err.Error()
+		}
+		g.emit(s.Name, "end", end)
+		if err != nil && ctx.Err() == nil {
+			mu.Lock()
+			failed[s.Name] = true
+			errs = append(errs, &StageError{Stage: s.Name, Err: err})
+			mu.Unlock()
+		}
+	}
+
+	// The output of a Parallel stage is released between the stages running
+	// beside it, in declaration order, once the stage has ended.
+	type side struct {
+		done    chan struct{}
+		release func()
+	}
+	var sides []side
+	releaseDone := func(wait bool) {
+		for len(sides) > 0 {
+			if wait {
+				<-sides[0].done
+			}
+			select {
+			case <-sides[0].done:
+			default:
+				return
+			}
+			sides[0].release()
+			sides = sides[1:]
+		}
+	}
+
+	for _, level := range levels {
+		for _, s := range level {
+			if s.Disabled {
+				continue
+			}
+			if ctx.Err() != nil {
+				releaseDone(true)
+				return ctx.Err()
+			}
+			if !s.Parallel {
+				releaseDone(false)
+				runOne(ctx, s)
+				continue
+			}
+			sctx, sd := ctx, side{done: make(chan struct{}), release: func() {}}
+			if g.Events != nil {
+				var held *render.Bus
+				held, sd.release = g.Events.Hold()
+				sctx = context.WithValue(ctx, stageBusKey{}, held)
+			}
+			sides = append(sides, sd)
+			go func() {
+				defer close(sd.done)
+				runOne(sctx, s)
+			}()
+		}
+		releaseDone(true)
+	}
+	if ctx.Err() != nil {
+		return ctx.Err()
+	}
+	return errors.Join(errs...)
+}
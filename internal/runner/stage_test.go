@@ -0,0 +1,201 @@
+package runner
+
+import (
+	"context"
+	"errors"
+	"reflect"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/tsosunchia/iNetSpeed-CLI/internal/render"
+)
+
+func recorder() (*[]string, func(name string) func(context.Context) error) {
+	var (
+		mu  sync.Mutex
+		got []string
+	)
+	return &got, func(name string) func(context.Context) error {
+		return func(context.Context) error {
+			mu.Lock()
+			got = append(got, name)
+			mu.Unlock()
+			return nil
+		}
+	}
+}
+
+func TestGraphDeclarationOrder(t *testing.T) {
+	got, rec := recorder()
+	g := NewGraph()
+	g.Add(Stage{Name: "a", Run: rec("a")})
+	g.Add(Stage{Name: "c", Needs: []string{"b"}, Run: rec("c")})
+	g.Add(Stage{Name: "b", Needs: []string{"a"}, Run: rec("b")})
+	g.Add(Stage{Name: "d", Needs: []string{"a"}, Run: rec("d")})
+
+	if err := g.Execute(context.Background()); err != nil {
+		t.Fatal(err)
+	}
+	want := []string{"a", "b", "d", "c"}
+	if !reflect.DeepEqual(*got, want) {
+		t.Errorf("order = %v, want %v", *got, want)
+	}
+}
+
+func TestGraphDisabledDependencyCountsAsDone(t *testing.T) {
+	got, rec := recorder()
+	g := NewGraph()
+	g.Add(Stage{Name: "a", Disabled: true, Run: rec("a")})
+	g.Add(Stage{Name: "b", Needs: []string{"a"}, Run: rec("b")})
+
+	if err := g.Execute(context.Background()); err != nil {
+		t.Fatal(err)
+	}
+	if !reflect.DeepEqual(*got, []string{"b"}) {
+		t.Errorf("ran %v, want [b]", *got)
+	}
+}
+
+func TestGraphFailedDependencySkips(t *testing.T) {
+	got, rec := recorder()
+	boom := errors.New("boom")
+	g := NewGraph()
+	g.Add(Stage{Name: "a", Run: func(context.Context) error { return boom }})
+	g.Add(Stage{Name: "b", Needs: []string{"a"}, Run: rec("b")})
+	g.Add(Stage{Name: "c", Needs: []string{"b"}, Run: rec("c")})
+	g.Add(Stage{Name: "d", Run: rec("d")})
+
+	err := g.Execute(context.Background())
+	if !errors.Is(err, boom) || !errors.Is(err, ErrDependencyFailed) {
+		t.Fatalf("err = %v, want boom and ErrDependencyFailed", err)
+	}
+	var se *StageError
+	if !errors.As(err, &se) || se.Stage != "a" {
+		t.Errorf("first StageError = %+v", se)
+	}
+	if !reflect.DeepEqual(*got, []string{"d"}) {
+		t.Errorf("ran %v, want [d]", *got)
+	}
+}
+
+func TestGraphParallelStagesOverlap(t *testing.T) {
+	var lines infoLines
+	bus := render.NewBus(&lines)
+	started, finished := make(chan struct{}), make(chan struct{})
+	g := NewGraph()
+	g.Events = bus
+	// a runs beside b: b only returns once a has started and ended, which a
+	// stage waiting its turn never would.
+	g.Add(Stage{Name: "a", Parallel: true, Run: func(ctx context.Context) error {
+		close(started)
+		stageBus(ctx, bus).Info("a")
+		close(finished)
+		return nil
+	}})
+	g.Add(Stage{Name: "b", Run: func(context.Context) error {
+		bus.Info("b1")
+		for _, ch := range []chan struct{}{started, finished} {
+			select {
+			case <-ch:
+			case <-time.After(5 * time.Second):
+				return errors.New("a did not run alongside b")
+			}
+		}
+		bus.Info("b2")
+		return nil
+	}})
+	g.Add(Stage{Name: "c", Run: func(context.Context) error {
+		bus.Info("c")
+		return nil
+	}})
+
+	if err := g.Execute(context.Background()); err != nil {
+		t.Fatal(err)
+	}
+	bus.Close()
+	// a's output waits for b's section to end, and comes before c's.
+	if !reflect.DeepEqual(lines, infoLines{"b1", "b2", "a", "c"}) {
+		t.Errorf("output = %q", lines)
+	}
+}
+
+// infoLines records the Info messages a bus renders.
+type infoLines []string
+
+func (l *infoLines) Render(ev render.Event) {
+	if ev.Kind == render.KindInfo {
+		*l = append(*l, ev.Value)
+	}
+}
+
+func TestGraphParallelFailureSkipsDependents(t *testing.T) {
+	got, rec := recorder()
+	boom := errors.New("boom")
+	g := NewGraph()
+	g.Add(Stage{Name: "a", Parallel: true, Run: func(context.Context) error { return boom }})
+	g.Add(Stage{Name: "b", Run: rec("b")})
+	g.Add(Stage{Name: "c", Needs: []string{"a"}, Run: rec("c")})
+	g.Add(Stage{Name: "d", Needs: []string{"b"}, Run: rec("d")})
+	g.Add(Stage{Name: "e", Needs: []string{"c"}, Run: rec("e")})
+
+	err := g.Execute(context.Background())
+	if !errors.Is(err, boom) || !errors.Is(err, ErrDependencyFailed) {
+		t.Fatalf("err = %v, want boom and ErrDependencyFailed", err)
+	}
+	var skipped []string
+	for _, e := range err.(interface{ Unwrap() []error }).Unwrap() {
+		if se := (*StageError)(nil); errors.As(e, &se) && errors.Is(se, ErrDependencyFailed) {
+			skipped = append(skipped, se.Stage)
+		}
+	}
+	if !reflect.DeepEqual(skipped, []string{"c", "e"}) || !reflect.DeepEqual(*got, []string{"b", "d"}) {
+		t.Errorf("skipped %v, ran %v; want [c e], [b d]", skipped, *got)
+	}
+}
+
+func TestGraphStageTimeout(t *testing.T) {
+	g := NewGraph()
+	g.Add(Stage{Name: "slow", Timeout: 20 * time.Millisecond, Run: func(ctx context.Context) error {
+		<-ctx.Done()
+		return ctx.Err()
+	}})
+	start := time.Now()
+	err := g.Execute(context.Background())
+	if !errors.Is(err, context.DeadlineExceeded) {
+		t.Fatalf("err = %v, want DeadlineExceeded", err)
+	}
+	if time.Since(start) > time.Second {
+		t.Error("stage timeout not applied")
+	}
+}
+
+func TestGraphStopsOnCancel(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	got, rec := recorder()
+	g := NewGraph()
+	g.Add(Stage{Name: "a", Run: func(context.Context) error { cancel(); return nil }})
+	g.Add(Stage{Name: "b", Run: rec("b")})
+
+	if err := g.Execute(ctx); !errors.Is(err, context.Canceled) {
+		t.Fatalf("err = %v, want Canceled", err)
+	}
+	if len(*got) != 0 {
+		t.Errorf("stages ran after cancel: %v", *got)
+	}
+}
+
+func TestGraphInvalid(t *testing.T) {
+	g := NewGraph()
+	g.Add(Stage{Name: "a", Needs: []string{"missing"}})
+	if _, err := g.Levels(); err == nil {
+		t.Error("expected unknown dependency error")
+	}
+
+	g = NewGraph()
+	g.Add(Stage{Name: "a", Needs: []string{"b"}})
+	g.Add(Stage{Name: "b", Needs: []string{"a"}})
+	if _, err := g.Levels(); err == nil {
+		t.Error("expected cycle error")
+	}
+}
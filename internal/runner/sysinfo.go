@@ -0,0 +1,143 @@
+package runner
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strings"
+
+	"github.com/tsosunchia/iNetSpeed-CLI/internal/i18n"
+	"github.com/tsosunchia/iNetSpeed-CLI/internal/report"
+	"github.com/tsosunchia/iNetSpeed-CLI/internal/sysinfo"
+	"github.com/tsosunchia/iNetSpeed-CLI/internal/units"
+)
+
+// Shares of a link's rate a TCP transfer can use: Wi-Fi loses much of its
+// PHY rate to contention, acknowledgements and framing, a wired link little.
+const (
+	wifiUsable  = 0.5
+	wiredUsable = 0.9
+)
+
+var (
+	readLink   = sysinfo.ReadLink
+	dnsServers = sysinfo.DNS
+)
+
+// sysInfo describes the client's end of the path: the interface routed to
+// the endpoint, its link, the default gateway and the DNS servers.
+func (r *run) sysInfo(ctx context.Context) error {
+	bus := r.bus
+	bus.Header(i18n.Text("System", "本机网络"))
+	r.mu.Lock()
+	ip := r.rep.Server.IP
+	r.mu.Unlock()
+	if r.ep.IP != "" {
+		ip = r.ep.IP
+	}
+	sys := &report.System{Interface: routeInterface(ip)}
+	if gw, err := defaultGateway(); err == nil {
+		sys.Gateway = gw
+	}
+	if sys.Interface == "" && sys.Gateway != "" {
+		sys.Interface = routeInterface(sys.Gateway)
+	}
+	if sys.Interface != "" {
+		link, err := readLink(sys.Interface)
+		switch {
+		case errors.Is(err, sysinfo.ErrUnsupported):
+			bus.Warn(i18n.Text("Link details are not available on this platform.", "当前平台无法读取链路信息。"))
+		case err != nil:
+			bus.Debug(fmt.Sprintf(i18n.Text("Cannot read the link of %s: %v", "无法读取 %s 的链路信息: %v"), sys.Interface, err))
+		default:
+			sys.LinkMbps = link.Mbps
+			if w := link.WiFi; w != nil {
+				sys.WiFi = &report.WiFi{SSID: w.SSID, RSSIdBm: w.RSSIdBm, NoisedBm: w.NoisedBm, TxMbps: w.TxMbps, Channel: w.Channel}
+			}
+		}
+	}
+	if dns, err := dnsServers(); err == nil {
+		sys.DNS = dns
+	}
+	r.mu.Lock()
+	r.rep.System = sys
+	r.mu.Unlock()
+
+	unknown := i18n.Text("unknown", "未知")
+	or := func(s string) string {
+		if s == "" {
+			return unknown
+		}
+		return s
+	}
+	bus.KV(i18n.Text("Interface", "网卡"), or(sys.Interface))
+	if w := sys.WiFi; w != nil {
+		bus.KV("Wi-Fi", or(wifiLabel(w)))
+	} else if sys.LinkMbps > 0 {
+		bus.KV(i18n.Text("Link Speed", "链路速率"), fmt.Sprintf("%d Mbps", sys.LinkMbps))
+	}
+	bus.KV(i18n.Text("Gateway", "网关"), or(sys.Gateway))
+	bus.KV("DNS", or(strings.Join(sys.DNS, ", ")))
+	return nil
+}
+
+// wifiLabel reads like `"home"  -52 dBm (noise -91)  PHY 866.7 Mbps  ch 36`.
+func wifiLabel(w *report.WiFi) string {
+	var parts []string
+	if w.SSID != "" {
+		parts = append(parts, fmt.Sprintf("%q", w.SSID))
+	}
+	if w.RSSIdBm != 0 {
+		s := fmt.Sprintf("%d dBm", w.RSSIdBm)
+		if w.NoisedBm != 0 {
+			s += fmt.Sprintf(i18n.Text(" (noise %d)", "（噪声 %d）"), w.NoisedBm)
+		}
+		parts = append(parts, s)
+	}
+	if w.TxMbps > 0 {
+		parts = append(parts, fmt.Sprintf("PHY %g Mbps", w.TxMbps))
+	}
+	if w.Channel != "" {
+		parts = append(parts, i18n.Text("ch ", "信道 ")+w.Channel)
+	}
+	return strings.Join(parts, "  ")
+}
+
+// linkLabel is the summary line of the local link, "" when unknown.
+func linkLabel(sys *report.System) string {
+	switch {
+	case sys == nil:
+		return ""
+	case sys.WiFi != nil && sys.WiFi.TxMbps > 0:
+		return fmt.Sprintf(i18n.Text("%s, Wi-Fi PHY %g Mbps", "%s，Wi-Fi PHY %g Mbps"), sys.Interface, sys.WiFi.TxMbps)
+	case sys.WiFi == nil && sys.LinkMbps > 0:
+		return fmt.Sprintf(i18n.Text("%s, %d Mbps", "%s，%d Mbps"), sys.Interface, sys.LinkMbps)
+	}
+	return ""
+}
+
+// linkAdvice relates the best throughput to the local link's rate: close to
+// it, the link itself is the likely bottleneck; above a Wi-Fi PHY rate, that
+// rate was read while the idle link had stepped it down.
+func (r *run) linkAdvice() {
+	r.mu.Lock()
+	sys := r.rep.System
+	best := max(r.rep.Best(report.DirDownload), r.rep.Best(report.DirUpload))
+	r.mu.Unlock()
+	if sys == nil || best <= 0 {
+		return
+	}
+	rate, usable := float64(sys.LinkMbps), wiredUsable
+	if sys.WiFi != nil {
+		rate, usable = sys.WiFi.TxMbps, wifiUsable
+	}
+	switch {
+	case rate <= 0:
+	case sys.WiFi != nil && best > rate:
+		r.bus.Info(fmt.Sprintf(i18n.Text("Throughput of %s exceeds the Wi-Fi PHY rate of %g Mbps read before the test; Wi-Fi lowers the rate while idle.",
+			"吞吐量 %s 高于测速前读取的 Wi-Fi PHY 速率 %g Mbps；Wi-Fi 空闲时会降低速率。"), units.Rate(best, 0), rate))
+	case best >= usable*rate:
+		r.bus.Warn(fmt.Sprintf(i18n.Text("Throughput of %s is close to what the %g Mbps local link carries: the link to this device, not the ISP, is likely the bottleneck.",
+			"吞吐量 %s 已接近 %g Mbps 本地链路的承载能力：瓶颈很可能在本机到路由器的链路，而非运营商。"), units.Rate(best, 0), rate))
+	}
+}
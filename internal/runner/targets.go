@@ -0,0 +1,249 @@
+package runner
+
+import (
+	"context"
+	"fmt"
+	"math"
+	"net"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/tsosunchia/iNetSpeed-CLI/internal/config"
+	"github.com/tsosunchia/iNetSpeed-CLI/internal/gateway"
+	"github.com/tsosunchia/iNetSpeed-CLI/internal/i18n"
+	"github.com/tsosunchia/iNetSpeed-CLI/internal/latency"
+	"github.com/tsosunchia/iNetSpeed-CLI/internal/ping"
+	"github.com/tsosunchia/iNetSpeed-CLI/internal/report"
+	"github.com/tsosunchia/iNetSpeed-CLI/internal/transfer"
+)
+
+// targetInterval spaces the pings to each latency target under load.
+const targetInterval = 200 * time.Millisecond
+
+// targetRiseMs is the rise of a target's latency under load taken to mean
+// the queue filling up lies on the path to it.
+const targetRiseMs = 30
+
+var (
+	pingMeasure    = ping.Measure
+	defaultGateway = gateway.Default
+)
+
+// target is a latency target that answered its idle pings; i indexes its
+// entry in the report's LatencyTargets.
+type target struct {
+	i        int
+	ip       string
+	down, up []float64
+	sent     int
+	received int
+}
+
+// latencyTargets pings every LATENCY_TARGETS host while the link is idle.
+// Those that answer are pinged again during each download and upload round.
+func (r *run) latencyTargets(ctx context.Context) error {
+	bus := r.bus
+	bus.Header(i18n.Text("Latency Targets", "延迟目标"))
+	entries := make([]report.TargetLatency, len(r.cfg.LatencyTargets))
+	results := make([]ping.Result, len(entries))
+	var wg sync.WaitGroup
+	for i, name := range r.cfg.LatencyTargets {
+		entries[i].Target = name
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			ip, err := resolveTarget(ctx, r.resolver, name)
+			if err == nil {
+				entries[i].IP = ip
+				results[i], err = pingMeasure(ctx, ip, r.cfg.LatencyCount, 100*time.Millisecond, time.Second)
+			}
+			if err != nil {
+				entries[i].Error = err.Error()
+			}
+		}()
+	}
+	wg.Wait()
+
+	var targets []*target
+	for i := range entries {
+		e := &entries[i]
+		switch {
+		case e.Error != "":
+			bus.Warn(fmt.Sprintf(i18n.Text("Cannot ping latency target %s: %s", "无法 ping 延迟目标 %s: %s"), e.Target, e.Error))
+		case len(results[i].Samples) == 0:
+			e.IdleLossPct = 100
+			bus.Warn(fmt.Sprintf(i18n.Text("No ICMP replies from %s; ICMP may be blocked.", "%s 未响应 ICMP，可能被屏蔽。"), targetLabel(*e)))
+		default:
+			s := latency.Compute(results[i].Samples)
+			idle := latencyReport(s)
+			e.Idle = &idle
+			e.IdleLossPct = results[i].Loss() * 100
+			bus.Result(fmt.Sprintf(i18n.Text("%s: %.2f ms median  (min %.2f / max %.2f)  loss %.0f%%", "%s：%.2f 毫秒 中位数  (最小 %.2f / 最大 %.2f)  丢包 %.0f%%"),
+				targetLabel(*e), s.Median, s.Min, s.Max, e.IdleLossPct))
+			targets = append(targets, &target{i: i, ip: e.IP})
+		}
+	}
+	r.mu.Lock()
+	r.rep.LatencyTargets = entries
+	r.targets = targets
+	r.mu.Unlock()
+	return nil
+}
+
+// resolveTarget returns the address to ping for a LATENCY_TARGETS entry,
+// preferring IPv4 for host names.
+func resolveTarget(ctx context.Context, res *net.Resolver, name string) (string, error) {
+	if name == config.TargetGateway {
+		return defaultGateway()
+	}
+	if net.ParseIP(name) != nil {
+		return name, nil
+	}
+	addrs, err := res.LookupHost(ctx, name)
+	if err != nil {
+		return "", err
+	}
+	for _, a := range addrs {
+		if !strings.Contains(a, ":") {
+			return a, nil
+		}
+	}
+	return addrs[0], nil
+}
+
+func targetLabel(e report.TargetLatency) string {
+	if e.IP == "" || e.IP == e.Target {
+		return e.Target
+	}
+	return e.Target + " (" + e.IP + ")"
+}
+
+// startTargets pings the latency targets until the returned function is
+// called at the end of a round in direction dir.
+func (r *run) startTargets(ctx context.Context) func(dir transfer.Direction) {
+	r.mu.Lock()
+	targets := r.targets
+	r.mu.Unlock()
+	if len(targets) == 0 {
+		return func(transfer.Direction) {}
+	}
+	ctx, cancel := context.WithCancel(ctx)
+	results := make([]ping.Result, len(targets))
+	var wg sync.WaitGroup
+	for i, t := range targets {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			results[i], _ = pingMeasure(ctx, t.ip, math.MaxInt32, targetInterval, time.Second)
+		}()
+	}
+	return func(dir transfer.Direction) {
+		cancel()
+		wg.Wait()
+		r.mu.Lock()
+		defer r.mu.Unlock()
+		for i, t := range targets {
+			res := results[i]
+			// The echo in flight when the round ended was cut short, not lost.
+			if res.Sent > len(res.Samples) {
+				res.Sent--
+			}
+			t.sent += res.Sent
+			t.received += len(res.Samples)
+			e := &r.rep.LatencyTargets[t.i]
+			if dir == transfer.Download {
+				t.down = append(t.down, res.Samples...)
+				e.Download = loadedTargetReport(t.down)
+			} else {
+				t.up = append(t.up, res.Samples...)
+				e.Upload = loadedTargetReport(t.up)
+			}
+			if t.sent > 0 {
+				e.LoadedLossPct = 100 * float64(t.sent-t.received) / float64(t.sent)
+			}
+		}
+	}
+}
+
+func loadedTargetReport(samples []float64) *report.Latency {
+	if len(samples) == 0 {
+		return nil
+	}
+	l := latencyReport(latency.Compute(samples))
+	return &l
+}
+
+// targetRise is how far the loaded latency of e, in its worse direction,
+// is above its idle latency.
+func targetRise(e report.TargetLatency) (float64, bool) {
+	if e.Idle == nil || e.Download == nil && e.Upload == nil {
+		return 0, false
+	}
+	var loaded float64
+	for _, l := range []*report.Latency{e.Download, e.Upload} {
+		if l != nil {
+			loaded = max(loaded, l.MedianMs)
+		}
+	}
+	return loaded - e.Idle.MedianMs, true
+}
+
+// targetSummary shows each target's idle and loaded latency and names the
+// nearest one whose latency rises under load: the queue filling up lies on
+// the path to it. When none rises but the CDN's latency does, it lies beyond
+// them.
+func (r *run) targetSummary() {
+	bus := r.bus
+	r.mu.Lock()
+	entries := append([]report.TargetLatency(nil), r.rep.LatencyTargets...)
+	var cdnLoaded float64
+	for _, rd := range r.rep.Rounds {
+		cdnLoaded = max(cdnLoaded, rd.LoadedLatency.MedianMs)
+	}
+	r.mu.Unlock()
+	if len(entries) == 0 {
+		return
+	}
+
+	ms := func(l *report.Latency) string {
+		if l == nil {
+			return "-"
+		}
+		return fmt.Sprintf("%.1f", l.MedianMs)
+	}
+	var measured []report.TargetLatency
+	for _, e := range entries {
+		if e.Idle == nil {
+			bus.KV(targetLabel(e), i18n.Text("unreachable", "不可达"))
+			continue
+		}
+		bus.KV(targetLabel(e), fmt.Sprintf(i18n.Text("idle %s  ↓ %s  ↑ %s ms", "空载 %s  ↓ %s  ↑ %s 毫秒"), ms(e.Idle), ms(e.Download), ms(e.Upload)))
+		if _, ok := targetRise(e); ok {
+			measured = append(measured, e)
+		}
+	}
+	if len(measured) == 0 {
+		return
+	}
+	sort.SliceStable(measured, func(i, j int) bool { return measured[i].Idle.MedianMs < measured[j].Idle.MedianMs })
+	for _, e := range measured {
+		rise, _ := targetRise(e)
+		if rise < targetRiseMs {
+			continue
+		}
+		if e.Target == config.TargetGateway {
+			bus.Warn(fmt.Sprintf(i18n.Text("Latency to the gateway rises %.0f ms under load: the queue filling up is on the LAN or in the router.",
+				"负载时到网关的延迟上升 %.0f 毫秒：排队发生在局域网或路由器上。"), rise))
+		} else {
+			bus.Warn(fmt.Sprintf(i18n.Text("Latency to %s, the nearest target affected, rises %.0f ms under load: the queue filling up is on the path to it.",
+				"到 %s（受影响的最近目标）的延迟在负载时上升 %.0f 毫秒：排队发生在通往它的路径上。"), targetLabel(e), rise))
+		}
+		return
+	}
+	if r.idle.N > 0 && cdnLoaded-r.idle.Median >= targetRiseMs {
+		bus.Info(fmt.Sprintf(i18n.Text("Latency to every target stays flat under load while the CDN's rises %.0f ms: the queue filling up is beyond them, on the CDN path.",
+			"负载时到各目标的延迟保持平稳，而到 CDN 的延迟上升 %.0f 毫秒：排队发生在它们之后的 CDN 路径上。"), cdnLoaded-r.idle.Median))
+	}
+}
@@ -0,0 +1,147 @@
+package runner
+
+import (
+	"context"
+	"fmt"
+	"math"
+	"strings"
+	"time"
+
+	"github.com/tsosunchia/iNetSpeed-CLI/internal/config"
+	"github.com/tsosunchia/iNetSpeed-CLI/internal/i18n"
+	"github.com/tsosunchia/iNetSpeed-CLI/internal/latency"
+	"github.com/tsosunchia/iNetSpeed-CLI/internal/report"
+	"github.com/tsosunchia/iNetSpeed-CLI/internal/transfer"
+	"github.com/tsosunchia/iNetSpeed-CLI/internal/units"
+)
+
+// threadRamp runs a round per selected direction with workers joining as
+// THREAD_SCHEDULE says, after the fixed-thread rounds. Where the gain from
+// each step flattens is how many connections the path needs, which the
+// jump from one thread to THREADS cannot show.
+func (r *run) threadRamp(ctx context.Context) error {
+	steps := r.cfg.ThreadSchedule
+	for _, dir := range []transfer.Direction{transfer.Download, transfer.Upload} {
+		en, zh := "Download · Thread Ramp", "下载 · 线程阶梯"
+		phase, name := config.PhaseDownload, report.DirDownload
+		if dir == transfer.Upload {
+			en, zh = "Upload · Thread Ramp", "上传 · 线程阶梯"
+			phase, name = config.PhaseUpload, report.DirUpload
+		}
+		if !r.cfg.RunsPhase(phase) {
+			continue
+		}
+		bus := r.bus
+		bus.Header(i18n.Text(en, zh))
+		if r.gate.Budget.Exhausted() {
+			bus.Warn(fmt.Sprintf(i18n.Text("Total data cap %s reached, round skipped.", "已达总流量上限 %s，跳过本轮。"), r.capName()))
+			continue
+		}
+		length := r.cfg.ScheduleLength()
+		r.refreshURLs(ctx, length+2*time.Second)
+		url := r.cfg.DLURL
+		if dir == transfer.Upload {
+			url = r.cfg.ULURL
+		}
+		cfg := *r.cfg
+		// Stopping once throughput holds steady would cut off the steps
+		// still to come, which are the point of the round.
+		cfg.SaturationWindow = 0
+		bus.Info(fmt.Sprintf(i18n.Text("Schedule: %s over %.0fs", "计划: %s，共 %.0f 秒"), scheduleString(steps), length.Seconds()))
+		bus.Info(fmt.Sprintf(i18n.Text("Limit: %s per thread", "上限: 每线程 %s"), cfg.Max))
+
+		probeClient, opts := r.headlineProbe(r.client)
+		loadedProbe := latency.StartLoadedFunc(ctx, probeClient, cfg.LatencyURL, cfg.RequestHeader(), opts, r.loadedSample("thread-ramp"))
+		res := transfer.RunSchedule(ctx, r.client, &cfg, dir, steps, length, url, bus, r.gate, loadedSource{loadedProbe, &r.load})
+		loadedProbe.Stop()
+
+		ramp := threadRampReport(name, res.Series, steps, length)
+		r.mu.Lock()
+		r.totalData += res.TotalBytes
+		r.rep.DataUsedBytes = r.totalData
+		r.rep.TotalCapReached = r.gate.Budget.Exhausted()
+		r.rep.ThreadRamps = append(r.rep.ThreadRamps, ramp)
+		r.mu.Unlock()
+
+		for _, s := range ramp.Steps {
+			v := units.Rate(s.Mbps, 1)
+			if s.GainPct != 0 {
+				v += fmt.Sprintf("  (%+.0f%%)", s.GainPct)
+			}
+			bus.KV(fmt.Sprintf(i18n.Text("%d threads", "%d 线程"), s.Threads), v)
+		}
+		if len(res.Series) > 0 {
+			bus.Info(i18n.Text("Throughput per second: ", "每秒吞吐: ") + report.Sparkline(perSecond(res.Series)))
+		}
+		if res.HadFault() {
+			bus.Warn(i18n.Text("Network issue detected during this round; result may be affected.", "本轮测试中出现网络故障，结果可能受影响。"))
+		}
+		showPause(bus, res.Paused)
+		showCPU(bus, res.CPUPct)
+	}
+	return nil
+}
+
+// threadRampReport splits a ramp round's series at the steps of the
+// schedule and averages each. A step the round ended before, as a data cap
+// ends it, is left out.
+func threadRampReport(dir string, series []float64, steps []config.ThreadStep, length time.Duration) report.ThreadRamp {
+	ramp := report.ThreadRamp{
+		Direction:        dir,
+		SeriesIntervalMs: int(transfer.SeriesInterval / time.Millisecond),
+		SeriesMbps:       make([]float64, len(series)),
+	}
+	for i, v := range series {
+		ramp.SeriesMbps[i] = math.Round(v*10) / 10
+	}
+	for k, s := range steps {
+		end := length
+		if k+1 < len(steps) {
+			end = steps[k+1].At
+		}
+		lo, hi := int(s.At/transfer.SeriesInterval), min(int(end/transfer.SeriesInterval), len(series))
+		if lo >= hi {
+			break
+		}
+		var sum float64
+		for _, v := range series[lo:hi] {
+			sum += v
+		}
+		step := report.RampStep{
+			Threads:  s.Threads,
+			StartSec: s.At.Seconds(),
+			EndSec:   end.Seconds(),
+			Mbps:     math.Round(sum/float64(hi-lo)*10) / 10,
+		}
+		if n := len(ramp.Steps); n > 0 && ramp.Steps[n-1].Mbps > 0 {
+			step.GainPct = math.Round((step.Mbps/ramp.Steps[n-1].Mbps-1)*1000) / 10
+		}
+		ramp.Steps = append(ramp.Steps, step)
+	}
+	return ramp
+}
+
+// perSecond averages series over whole seconds, a resolution a sparkline
+// of a long round still fits a line at.
+func perSecond(series []float64) []float64 {
+	per := int(time.Second / transfer.SeriesInterval)
+	var out []float64
+	for i := 0; i < len(series); i += per {
+		chunk := series[i:min(i+per, len(series))]
+		var sum float64
+		for _, v := range chunk {
+			sum += v
+		}
+		out = append(out, sum/float64(len(chunk)))
+	}
+	return out
+}
+
+// scheduleString writes steps back as THREAD_SCHEDULE takes them.
+func scheduleString(steps []config.ThreadStep) string {
+	parts := make([]string, len(steps))
+	for i, s := range steps {
+		parts[i] = fmt.Sprintf("%d@%v", s.Threads, s.At)
+	}
+	return strings.Join(parts, ",")
+}
@@ -0,0 +1,125 @@
+package runner
+
+import (
+	"crypto/tls"
+	"crypto/x509"
+	"fmt"
+	"slices"
+	"strings"
+
+	"github.com/tsosunchia/iNetSpeed-CLI/internal/i18n"
+	"github.com/tsosunchia/iNetSpeed-CLI/internal/report"
+)
+
+// tlsRoots verifies the servers' certificates; nil is the system pool.
+var tlsRoots *x509.CertPool
+
+// observeTLS records the session of the connection resp came on. The
+// transports hand every response on a connection the same ConnectionState,
+// so its address tells connections apart.
+func (r *run) observeTLS(cs *tls.ConnectionState) {
+	s := tlsSession(cs)
+	r.mu.Lock()
+	if r.tlsConns == nil {
+		r.tlsConns = map[*tls.ConnectionState]bool{}
+	}
+	if r.tlsConns[cs] {
+		r.mu.Unlock()
+		return
+	}
+	r.tlsConns[cs] = true
+	if r.countTLS(s, cs.DidResume) {
+		r.mu.Unlock()
+		return
+	}
+	r.mu.Unlock()
+
+	// Verifying may load the system roots, so it runs unlocked, once per
+	// new session.
+	s.Untrusted = !trusted(cs.PeerCertificates)
+	r.mu.Lock()
+	if !r.countTLS(s, cs.DidResume) {
+		s.Connections = 1
+		if cs.DidResume {
+			s.Resumed = 1
+		}
+		r.rep.TLS = append(r.rep.TLS, s)
+	}
+	r.mu.Unlock()
+}
+
+// countTLS adds a connection to the recorded session matching s and reports
+// whether there was one. r.mu is held.
+func (r *run) countTLS(s report.TLSSession, resumed bool) bool {
+	i := slices.IndexFunc(r.rep.TLS, func(t report.TLSSession) bool {
+		return t.Version == s.Version && t.CipherSuite == s.CipherSuite && t.ALPN == s.ALPN &&
+			t.Subject == s.Subject && t.Issuer == s.Issuer
+	})
+	if i < 0 {
+		return false
+	}
+	r.rep.TLS[i].Connections++
+	if resumed {
+		r.rep.TLS[i].Resumed++
+	}
+	return true
+}
+
+func tlsSession(cs *tls.ConnectionState) report.TLSSession {
+	s := report.TLSSession{
+		Version:     tls.VersionName(cs.Version),
+		CipherSuite: tls.CipherSuiteName(cs.CipherSuite),
+		ALPN:        cs.NegotiatedProtocol,
+	}
+	if len(cs.PeerCertificates) > 0 {
+		leaf := cs.PeerCertificates[0]
+		s.Subject = leaf.Subject.String()
+		s.Issuer = leaf.Issuer.String()
+		s.SANs = leaf.DNSNames
+		for _, ip := range leaf.IPAddresses {
+			s.SANs = append(s.SANs, ip.String())
+		}
+	}
+	return s
+}
+
+// trusted reports whether chain leads to a trusted root. The host name is
+// not checked: the transport already did, unless told not to verify.
+func trusted(chain []*x509.Certificate) bool {
+	if len(chain) == 0 {
+		return false
+	}
+	inter := x509.NewCertPool()
+	for _, c := range chain[1:] {
+		inter.AddCert(c)
+	}
+	_, err := chain[0].Verify(x509.VerifyOptions{Roots: tlsRoots, Intermediates: inter})
+	return err == nil
+}
+
+// tlsSummary lists the negotiated sessions and warns about certificates the
+// system does not trust.
+func (r *run) tlsSummary() {
+	r.mu.Lock()
+	sessions := slices.Clone(r.rep.TLS)
+	r.mu.Unlock()
+	for _, s := range sessions {
+		params := strings.Join(nonEmpty(s.Version, s.CipherSuite, s.ALPN), ", ")
+		r.bus.KV("TLS", params+fmt.Sprintf(i18n.Text("  (%d connections, %d resumed)", "  (%d 条连接，%d 条复用会话)"), s.Connections, s.Resumed))
+		r.bus.Debug(fmt.Sprintf(i18n.Text("Certificate %s issued by %s for %s", "证书 %s，签发者 %s，适用于 %s"), s.Subject, s.Issuer, strings.Join(s.SANs, ", ")))
+		if s.Untrusted {
+			r.bus.Warn(fmt.Sprintf(i18n.Text("The certificate was issued by %s, which the system does not trust: a middlebox may be intercepting TLS, and such boxes often lower throughput too.",
+				"证书由系统不信任的 %s 签发：可能有中间设备在拦截 TLS，这类设备通常也会降低吞吐量。"), s.Issuer))
+		}
+	}
+}
+
+func nonEmpty(vals ...string) []string {
+	var out []string
+	for _, v := range vals {
+		if v != "" {
+			out = append(out, v)
+		}
+	}
+	return out
+}
@@ -0,0 +1,112 @@
+package runner
+
+import (
+	"context"
+	"fmt"
+	"math"
+	"time"
+
+	"github.com/tsosunchia/iNetSpeed-CLI/internal/config"
+	"github.com/tsosunchia/iNetSpeed-CLI/internal/i18n"
+	"github.com/tsosunchia/iNetSpeed-CLI/internal/report"
+	"github.com/tsosunchia/iNetSpeed-CLI/internal/transfer"
+	"github.com/tsosunchia/iNetSpeed-CLI/internal/units"
+)
+
+// The verification upload lasts about verifySecs at the best upload rate
+// measured, and is at least verifyMin; verifyDefault is its size when no
+// upload round ran. ackShareMin is the share of the write rate the server
+// must acknowledge at for the upload results to stand.
+const (
+	verifySecs    = 3
+	verifyMin     = 4 << 20
+	verifyDefault = 32 << 20
+	ackShareMin   = 0.8
+)
+
+// uploadVerify sends one upload to completion and checks that the server's
+// answer backs the upload rounds. An upload round ends at its time limit
+// with the data the client wrote counted as sent, whether or not it left
+// the socket buffers; a server that must answer a complete body shows what
+// actually arrived.
+func (r *run) uploadVerify(ctx context.Context) error {
+	bus := r.bus
+	bus.Header(i18n.Text("Upload Verification", "上传校验"))
+	cfg := r.cfg.ForStage(config.StageUploadVerify)
+	if r.gate.Budget.Exhausted() {
+		bus.Warn(fmt.Sprintf(i18n.Text("Total data cap %s reached, round skipped.", "已达总流量上限 %s，跳过本轮。"), r.capName()))
+		return nil
+	}
+	r.refreshURLs(ctx, time.Duration(cfg.Timeout+5)*time.Second)
+	r.mu.Lock()
+	best := r.rep.Best(report.DirUpload)
+	r.mu.Unlock()
+	size := verifySize(best, cfg.MaxBytes)
+	bus.Info(fmt.Sprintf(i18n.Text("Sending %s in one request and waiting for the server's answer.", "单次请求发送 %s 并等待服务器应答。"), config.HumanBytes(size)))
+
+	c, err := transfer.VerifyUpload(ctx, r.client, cfg, cfg.ULURL, size, r.gate)
+	r.mu.Lock()
+	r.totalData += c.Sent
+	r.rep.DataUsedBytes = r.totalData
+	r.mu.Unlock()
+	if err != nil {
+		if ctx.Err() == nil {
+			bus.Warn(fmt.Sprintf(i18n.Text("Upload verification failed: %v", "上传校验失败: %v"), err))
+		}
+		return nil
+	}
+	bus.Debug(c.String())
+	check := uploadCheck(c)
+	r.mu.Lock()
+	r.rep.UploadCheck = check
+	r.mu.Unlock()
+
+	switch {
+	case c.Status >= 300:
+		bus.Warn(fmt.Sprintf(i18n.Text("The server answered the upload with HTTP %d: it did not accept the data, so the upload results may count data it discarded.",
+			"服务器以 HTTP %d 应答上传：数据未被接受，上传结果可能计入了被丢弃的数据。"), c.Status))
+	case c.HasAck && c.Acked < c.Sent:
+		bus.Warn(fmt.Sprintf(i18n.Text("The server acknowledged %s of the %s sent: the upload results count data it never received.",
+			"服务器仅确认收到 %s（共发送 %s）：上传结果计入了服务器未收到的数据。"), config.HumanBytes(c.Acked), config.HumanBytes(c.Sent)))
+	case check.Suspect:
+		bus.Warn(fmt.Sprintf(i18n.Text("The server confirmed receipt at %s, %.0f%% of the %s the client wrote at: the rest sat in buffers on the way, inflating the upload results.",
+			"服务器确认接收的速率为 %s，仅为客户端写出速率的 %.0f%%（%s）：其余数据滞留在途中缓冲区，上传结果偏高。"),
+			units.Rate(check.AckMbps, 0), check.AckMbps/check.WriteMbps*100, units.Rate(check.WriteMbps, 0)))
+	case c.HasAck:
+		bus.Result(fmt.Sprintf(i18n.Text("Server acknowledged %s at %s  (HTTP %d)", "服务器确认收到 %s，速率 %s  (HTTP %d)"),
+			config.HumanBytes(c.Acked), units.Rate(check.AckMbps, 0), c.Status))
+	default:
+		bus.Result(fmt.Sprintf(i18n.Text("Server answered after %s at %s  (HTTP %d, no byte count)", "服务器在 %s 后应答，速率 %s  (HTTP %d，未返回字节数)"),
+			config.HumanBytes(c.Sent), units.Rate(check.AckMbps, 0), c.Status))
+	}
+	return nil
+}
+
+// verifySize is the verification upload's size: about verifySecs at the
+// best upload rate, or verifyDefault without one, within the per-thread cap.
+func verifySize(bestMbps float64, maxBytes int64) int64 {
+	size := int64(verifyDefault)
+	if bestMbps > 0 {
+		size = max(int64(bestMbps*1_000_000/8*verifySecs), verifyMin)
+	}
+	if maxBytes > 0 {
+		size = min(size, maxBytes)
+	}
+	return size
+}
+
+// uploadCheck is c in its report form.
+func uploadCheck(c transfer.UploadCheck) *report.UploadCheck {
+	rc := &report.UploadCheck{
+		Status:    c.Status,
+		SentBytes: c.Sent,
+		WriteMbps: math.Round(c.WriteMbps()*10) / 10,
+		AckMbps:   math.Round(c.AckMbps()*10) / 10,
+	}
+	if c.HasAck {
+		acked := c.Acked
+		rc.AckedBytes = &acked
+	}
+	rc.Suspect = c.Status >= 300 || c.HasAck && c.Acked < c.Sent || c.AckMbps() < ackShareMin*c.WriteMbps()
+	return rc
+}
@@ -0,0 +1,93 @@
+package runner
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"github.com/tsosunchia/iNetSpeed-CLI/internal/config"
+	"github.com/tsosunchia/iNetSpeed-CLI/internal/history"
+	"github.com/tsosunchia/iNetSpeed-CLI/internal/render"
+	"github.com/tsosunchia/iNetSpeed-CLI/internal/report"
+)
+
+// widgetLockAge is how long a lock left by another --widget invocation is
+// honored; older locks are taken to be from a run that died.
+const widgetLockAge = 10 * time.Minute
+
+// Widget runs the --widget mode for status bars that invoke the program
+// periodically. The history file is the cache: a test runs only when its
+// latest entry is older than cfg.WidgetMaxAge and no other invocation is
+// already testing. Either way one status line is printed on stdout. Exit
+// codes are those of Run; serving from the cache exits 0.
+func Widget(ctx context.Context, cfg *config.Config, bus *render.Bus) int {
+	reps, err := history.Recent(cfg.History, report.WidgetPoints, cfg.Simulate)
+	if err != nil {
+		bus.Fatal(fmt.Sprintf("%s: %v", cfg.History, err))
+		return 1
+	}
+	code := 0
+	if widgetStale(reps, cfg.WidgetMaxAge, time.Now()) {
+		if unlock, ok := widgetLock(cfg.History); ok {
+			c := *cfg
+			c.Quiet = false // the status line replaces the --quiet one
+			code = Run(ctx, &c, bus, false)
+			unlock()
+			if fresh, err := history.Recent(cfg.History, report.WidgetPoints, cfg.Simulate); err == nil {
+				reps = fresh
+			}
+		}
+	}
+	line := report.WidgetLine(reps)
+	if note := widgetNote(cfg.History, reps); note != "" {
+		line += " ✎ " + note
+	}
+	fmt.Fprintln(stdout, line)
+	return code
+}
+
+// widgetNote returns the latest history note logged since the oldest of
+// reps, the runs the sparklines show, on one line; "" when there is none.
+func widgetNote(historyPath string, reps []*report.Report) string {
+	if len(reps) == 0 {
+		return ""
+	}
+	notes, err := history.Notes(historyPath, reps[0].Time, time.Now())
+	if err != nil || len(notes) == 0 {
+		return ""
+	}
+	return strings.Join(strings.Fields(notes[len(notes)-1].Text), " ")
+}
+
+func widgetStale(reps []*report.Report, maxAge time.Duration, now time.Time) bool {
+	return len(reps) == 0 || now.Sub(reps[len(reps)-1].Time) >= maxAge
+}
+
+// widgetLock creates a lock file next to the history file so overlapping
+// invocations do not run tests at the same time.
+func widgetLock(historyPath string) (unlock func(), ok bool) {
+	path := historyPath + ".lock"
+	if err := os.MkdirAll(filepath.Dir(path), 0o755); err != nil {
+		return nil, false
+	}
+	for attempt := 0; attempt < 2; attempt++ {
+		f, err := os.OpenFile(path, os.O_WRONLY|os.O_CREATE|os.O_EXCL, 0o644)
+		if err == nil {
+			f.Close()
+			return func() { os.Remove(path) }, true
+		}
+		if !errors.Is(err, os.ErrExist) {
+			return nil, false
+		}
+		fi, err := os.Stat(path)
+		if err != nil || time.Since(fi.ModTime()) < widgetLockAge {
+			return nil, false
+		}
+		os.Remove(path)
+	}
+	return nil, false
+}
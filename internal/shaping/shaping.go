@@ -0,0 +1,118 @@
+// Package shaping tells rate policers from shapers by the throughput
+// time-series of a round. A policer drops packets above the rate, so TCP
+// keeps overshooting and backing off: a sawtooth with little extra latency.
+// A shaper queues them instead: a flat line at the rate while latency
+// climbs with the queue.
+package shaping
+
+import (
+	"math"
+	"sort"
+	"time"
+)
+
+// Verdicts.
+const (
+	Policer = "policer"
+	Shaper  = "shaper"
+	None    = "none"    // smooth, without the latency rise of a queue
+	Unclear = "unclear" // neither signature is clear
+)
+
+// Thresholds of Classify. A drop is a fall below dropRatio of the steady
+// rate after having reached it; minSamples intervals are needed at all.
+const (
+	minSamples    = 20
+	rampRatio     = 0.8
+	dropRatio     = 0.5
+	policerDrops  = 3
+	policerCV     = 0.3
+	shaperCV      = 0.2
+	shaperRiseMin = 20 // ms
+)
+
+// Diagnosis describes the shape of one round.
+type Diagnosis struct {
+	Verdict string
+	// SteadyMbps is the median of the second half of the round.
+	SteadyMbps float64
+	// Ramp is how long the round took to first reach rampRatio of SteadyMbps.
+	Ramp time.Duration
+	// CV is the coefficient of variation after the ramp.
+	CV float64
+	// Drops counts falls below dropRatio of SteadyMbps after the ramp.
+	Drops int
+	// LatencyRiseMs is loaded minus idle latency.
+	LatencyRiseMs float64
+}
+
+// Classify diagnoses series, sampled every interval, given the idle latency
+// and the latency measured during the round. ok is false when the round is
+// too short or carried nothing.
+func Classify(series []float64, interval time.Duration, idleMs, loadedMs float64) (d Diagnosis, ok bool) {
+	if len(series) < minSamples {
+		return d, false
+	}
+	d.SteadyMbps = median(series[len(series)/2:])
+	if d.SteadyMbps <= 0 {
+		return d, false
+	}
+	ramp := len(series)
+	for i, v := range series {
+		if v >= rampRatio*d.SteadyMbps {
+			ramp = i
+			break
+		}
+	}
+	d.Ramp = time.Duration(ramp+1) * interval
+	steady := series[ramp:]
+
+	var sum float64
+	for _, v := range steady {
+		sum += v
+	}
+	mean := sum / float64(len(steady))
+	var sq float64
+	for _, v := range steady {
+		sq += (v - mean) * (v - mean)
+	}
+	if mean > 0 {
+		d.CV = math.Sqrt(sq/float64(len(steady))) / mean
+	}
+	high := true
+	for _, v := range steady {
+		switch {
+		case high && v < dropRatio*d.SteadyMbps:
+			d.Drops++
+			high = false
+		case !high && v >= d.SteadyMbps:
+			high = true
+		}
+	}
+	if idleMs > 0 && loadedMs > 0 {
+		d.LatencyRiseMs = loadedMs - idleMs
+	}
+
+	queued := d.LatencyRiseMs >= max(shaperRiseMin, idleMs/2)
+	switch {
+	case d.Drops >= policerDrops && d.CV >= policerCV && !queued:
+		d.Verdict = Policer
+	case d.CV < shaperCV && d.Drops == 0 && queued:
+		d.Verdict = Shaper
+	case d.CV < shaperCV && d.Drops == 0:
+		d.Verdict = None
+	default:
+		d.Verdict = Unclear
+	}
+	return d, true
+}
+
+func median(v []float64) float64 {
+	s := append([]float64(nil), v...)
+	sort.Float64s(s)
+	n := len(s)
+	if n%2 == 1 {
+		return s[n/2]
+	}
+	return (s[n/2-1] + s[n/2]) / 2
+}
@@ -0,0 +1,58 @@
+package shaping
+
+import (
+	"testing"
+	"time"
+)
+
+// ramp returns n samples rising linearly to rate over the first rise
+// samples, then shaped by f.
+func ramp(n, rise int, rate float64, f func(i int) float64) []float64 {
+	s := make([]float64, n)
+	for i := range s {
+		if i < rise {
+			s[i] = rate * float64(i+1) / float64(rise)
+			continue
+		}
+		s[i] = f(i)
+	}
+	return s
+}
+
+func TestClassify(t *testing.T) {
+	flat := ramp(60, 10, 50, func(int) float64 { return 50 })
+	// A policer at 50 Mbps: TCP grows past the rate and collapses on loss.
+	saw := ramp(60, 5, 60, func(i int) float64 { return []float64{60, 70, 80, 15, 30, 45}[i%6] })
+	jumpy := ramp(60, 5, 50, func(i int) float64 { return []float64{50, 30, 70, 40, 60}[i%5] })
+
+	tests := []struct {
+		name           string
+		series         []float64
+		idle, loaded   float64
+		verdict        string
+		drops          int
+		rampMs, steady float64
+	}{
+		{"shaper", flat, 10, 80, Shaper, 0, 800, 50},
+		{"clean", flat, 10, 14, None, 0, 800, 50},
+		{"policer", saw, 10, 12, Policer, 9, 0, 0},
+		{"queue and drops", saw, 10, 90, Unclear, 9, 0, 0},
+		{"noisy", jumpy, 10, 12, Unclear, 0, 0, 0},
+	}
+	for _, tt := range tests {
+		d, ok := Classify(tt.series, 100*time.Millisecond, tt.idle, tt.loaded)
+		if !ok || d.Verdict != tt.verdict || d.Drops != tt.drops {
+			t.Errorf("%s: %+v, %v; want %s with %d drops", tt.name, d, ok, tt.verdict, tt.drops)
+		}
+		if tt.rampMs > 0 && (float64(d.Ramp.Milliseconds()) != tt.rampMs || d.SteadyMbps != tt.steady) {
+			t.Errorf("%s: ramp %v steady %.1f; want %.0f ms at %.1f", tt.name, d.Ramp, d.SteadyMbps, tt.rampMs, tt.steady)
+		}
+	}
+
+	if _, ok := Classify(flat[:10], 100*time.Millisecond, 10, 80); ok {
+		t.Error("short series classified")
+	}
+	if _, ok := Classify(make([]float64, 40), 100*time.Millisecond, 10, 80); ok {
+		t.Error("idle series classified")
+	}
+}
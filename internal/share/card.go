@@ -0,0 +1,163 @@
+package share
+
+import (
+	"fmt"
+	"image"
+	"image/color"
+	"image/png"
+	"io"
+	"strconv"
+	"strings"
+
+	"github.com/tsosunchia/iNetSpeed-CLI/internal/report"
+	"github.com/tsosunchia/iNetSpeed-CLI/internal/units"
+)
+
+const (
+	cardW     = 640
+	cardH     = 320
+	glyphW    = 5
+	glyphH    = 7
+	cardScale = 3
+)
+
+var (
+	cardBG     = color.RGBA{0x16, 0x1b, 0x22, 0xff}
+	cardAccent = color.RGBA{0x2d, 0xd4, 0xbf, 0xff}
+	cardText   = color.RGBA{0xe6, 0xed, 0xf3, 0xff}
+	cardDim    = color.RGBA{0x8b, 0x94, 0x9e, 0xff}
+)
+
+// RenderCard draws a PNG summary card of the report. The embedded bitmap font
+// covers ASCII letters, digits and common punctuation; other characters are
+// drawn as '?', so labels are always English.
+func RenderCard(w io.Writer, rep *report.Report) error {
+	img := image.NewRGBA(image.Rect(0, 0, cardW, cardH))
+	fillRect(img, img.Bounds(), cardBG)
+	fillRect(img, image.Rect(0, 0, cardW, 6), cardAccent)
+
+	y := 28
+	drawText(img, 28, y, "INETSPEED-CLI", cardAccent, cardScale+1)
+	y += (glyphH+4)*(cardScale+1) + 8
+
+	lines := []struct {
+		label, value string
+	}{
+		{"DOWNLOAD", cardRate(rep, report.DirDownload)},
+		{"UPLOAD", cardRate(rep, report.DirUpload)},
+		{"LATENCY", fmt.Sprintf("%.1f MS  JITTER %.1f MS", rep.IdleLatency.MedianMs, rep.IdleLatency.JitterMs)},
+	}
+	for _, l := range lines {
+		drawText(img, 28, y, l.label, cardDim, cardScale)
+		drawText(img, 28+11*(glyphW+1)*cardScale, y, l.value, cardText, cardScale)
+		y += (glyphH + 5) * cardScale
+	}
+
+	y += 6
+	footer := strings.TrimSpace(rep.Client.ASN)
+	if footer == "" {
+		footer = rep.Client.ISP
+	}
+	if rep.Server.IP != "" {
+		footer += "  ->  " + rep.Server.IP
+	}
+	drawText(img, 28, y, footer, cardDim, 2)
+	stamp := rep.Time.Format("2006-01-02 15:04 MST")
+	if rep.Probe != nil {
+		name := rep.Probe.Name
+		if name == "" {
+			name = rep.Probe.ID
+		}
+		stamp += "  " + name
+	}
+	drawText(img, 28, cardH-28, stamp, cardDim, 2)
+	return png.Encode(w, img)
+}
+
+// cardRate is the best dir throughput of rep in the unit the terminal
+// showed. Its digits are not grouped: the font has no locale's separators.
+func cardRate(rep *report.Report, dir string) string {
+	v, label, prec := units.ConvertIn(rep.Units.Display, rep.Best(dir), 0)
+	return strconv.FormatFloat(v, 'f', prec, 64) + " " + label
+}
+
+func fillRect(img *image.RGBA, r image.Rectangle, c color.RGBA) {
+	for y := r.Min.Y; y < r.Max.Y; y++ {
+		for x := r.Min.X; x < r.Max.X; x++ {
+			img.SetRGBA(x, y, c)
+		}
+	}
+}
+
+func drawText(img *image.RGBA, x, y int, s string, c color.RGBA, scale int) {
+	for _, ch := range strings.ToUpper(s) {
+		if x+glyphW*scale > cardW {
+			return
+		}
+		g, ok := font5x7[ch]
+		if !ok {
+			g = font5x7['?']
+		}
+		for row, bits := range g {
+			for col := 0; col < glyphW; col++ {
+				if bits[col] != '#' {
+					continue
+				}
+				fillRect(img, image.Rect(x+col*scale, y+row*scale, x+(col+1)*scale, y+(row+1)*scale), c)
+			}
+		}
+		x += (glyphW + 1) * scale
+	}
+}
+
+var font5x7 = map[rune][glyphH]string{
+	' ': {".....", ".....", ".....", ".....", ".....", ".....", "....."},
+	'0': {".###.", "#...#", "#..##", "#.#.#", "##..#", "#...#", ".###."},
+	'1': {"..#..", ".##..", "..#..", "..#..", "..#..", "..#..", ".###."},
+	'2': {".###.", "#...#", "....#", "...#.", "..#..", ".#...", "#####"},
+	'3': {"#####", "...#.", "..#..", "...#.", "....#", "#...#", ".###."},
+	'4': {"...#.", "..##.", ".#.#.", "#..#.", "#####", "...#.", "...#."},
+	'5': {"#####", "#....", "####.", "....#", "....#", "#...#", ".###."},
+	'6': {"..##.", ".#...", "#....", "####.", "#...#", "#...#", ".###."},
+	'7': {"#####", "....#", "...#.", "..#..", ".#...", ".#...", ".#..."},
+	'8': {".###.", "#...#", "#...#", ".###.", "#...#", "#...#", ".###."},
+	'9': {".###.", "#...#", "#...#", ".####", "....#", "...#.", ".##.."},
+	'A': {".###.", "#...#", "#...#", "#####", "#...#", "#...#", "#...#"},
+	'B': {"####.", "#...#", "#...#", "####.", "#...#", "#...#", "####."},
+	'C': {".###.", "#...#", "#....", "#....", "#....", "#...#", ".###."},
+	'D': {"###..", "#..#.", "#...#", "#...#", "#...#", "#..#.", "###.."},
+	'E': {"#####", "#....", "#....", "####.", "#....", "#....", "#####"},
+	'F': {"#####", "#....", "#....", "####.", "#....", "#....", "#...."},
+	'G': {".###.", "#...#", "#....", "#.###", "#...#", "#...#", ".####"},
+	'H': {"#...#", "#...#", "#...#", "#####", "#...#", "#...#", "#...#"},
+	'I': {".###.", "..#..", "..#..", "..#..", "..#..", "..#..", ".###."},
+	'J': {"..###", "...#.", "...#.", "...#.", "...#.", "#..#.", ".##.."},
+	'K': {"#...#", "#..#.", "#.#..", "##...", "#.#..", "#..#.", "#...#"},
+	'L': {"#....", "#....", "#....", "#....", "#....", "#....", "#####"},
+	'M': {"#...#", "##.##", "#.#.#", "#.#.#", "#...#", "#...#", "#...#"},
+	'N': {"#...#", "#...#", "##..#", "#.#.#", "#..##", "#...#", "#...#"},
+	'O': {".###.", "#...#", "#...#", "#...#", "#...#", "#...#", ".###."},
+	'P': {"####.", "#...#", "#...#", "####.", "#....", "#....", "#...."},
+	'Q': {".###.", "#...#", "#...#", "#...#", "#.#.#", "#..#.", ".##.#"},
+	'R': {"####.", "#...#", "#...#", "####.", "#.#..", "#..#.", "#...#"},
+	'S': {".####", "#....", "#....", ".###.", "....#", "....#", "####."},
+	'T': {"#####", "..#..", "..#..", "..#..", "..#..", "..#..", "..#.."},
+	'U': {"#...#", "#...#", "#...#", "#...#", "#...#", "#...#", ".###."},
+	'V': {"#...#", "#...#", "#...#", "#...#", "#...#", ".#.#.", "..#.."},
+	'W': {"#...#", "#...#", "#...#", "#.#.#", "#.#.#", "#.#.#", ".#.#."},
+	'X': {"#...#", "#...#", ".#.#.", "..#..", ".#.#.", "#...#", "#...#"},
+	'Y': {"#...#", "#...#", ".#.#.", "..#..", "..#..", "..#..", "..#.."},
+	'Z': {"#####", "....#", "...#.", "..#..", ".#...", "#....", "#####"},
+	'.': {".....", ".....", ".....", ".....", ".....", ".##..", ".##.."},
+	',': {".....", ".....", ".....", ".....", ".##..", "..#..", ".#..."},
+	':': {".....", ".##..", ".##..", ".....", ".##..", ".##..", "....."},
+	'-': {".....", ".....", ".....", "#####", ".....", ".....", "....."},
+	'>': {"#....", ".#...", "..#..", "...#.", "..#..", ".#...", "#...."},
+	'/': {".....", "....#", "...#.", "..#..", ".#...", "#....", "....."},
+	'(': {"...#.", "..#..", ".#...", ".#...", ".#...", "..#..", "...#."},
+	')': {".#...", "..#..", "...#.", "...#.", "...#.", "..#..", ".#..."},
+	'%': {"##...", "##..#", "...#.", "..#..", ".#...", "#..##", "...##"},
+	'+': {".....", "..#..", "..#..", "#####", "..#..", "..#..", "....."},
+	'_': {".....", ".....", ".....", ".....", ".....", ".....", "#####"},
+	'?': {".###.", "#...#", "....#", "...#.", "..#..", ".....", "..#.."},
+}
@@ -0,0 +1,252 @@
+package share
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"html/template"
+	"io"
+	"strings"
+
+	"github.com/tsosunchia/iNetSpeed-CLI/internal/chart"
+	"github.com/tsosunchia/iNetSpeed-CLI/internal/config"
+	"github.com/tsosunchia/iNetSpeed-CLI/internal/history"
+	"github.com/tsosunchia/iNetSpeed-CLI/internal/i18n"
+	"github.com/tsosunchia/iNetSpeed-CLI/internal/report"
+	"github.com/tsosunchia/iNetSpeed-CLI/internal/transfer"
+	"github.com/tsosunchia/iNetSpeed-CLI/internal/units"
+)
+
+// htmlPage is a standalone document: styles and charts are inline, so the
+// file opens offline and survives being attached to a support ticket.
+var htmlPage = template.Must(template.New("report").Parse(`<!DOCTYPE html>
+<html lang="{{.Lang}}">
+<head>
+<meta charset="utf-8">
+<meta name="viewport" content="width=device-width, initial-scale=1">
+<title>{{.Title}}</title>
+<style>
+body{font-family:-apple-system,"Segoe UI",Roboto,"Noto Sans",sans-serif;margin:0 auto;max-width:720px;padding:24px;color:#111827;background:#f9fafb}
+h1{font-size:22px;margin:0}h2{font-size:17px;margin:28px 0 8px;border-bottom:1px solid #e5e7eb;padding-bottom:4px}
+.meta{color:#6b7280;font-size:13px;margin-top:4px}
+.tiles{display:flex;gap:12px;flex-wrap:wrap;margin-top:16px}
+.tile{flex:1;min-width:150px;background:#fff;border:1px solid #e5e7eb;border-radius:8px;padding:12px}
+.tile b{display:block;font-size:24px}.tile span{color:#6b7280;font-size:13px}
+table{border-collapse:collapse;width:100%;background:#fff;font-size:13px}
+th,td{border:1px solid #e5e7eb;padding:4px 8px;text-align:left}th{background:#f3f4f6;font-weight:600}
+td.n{text-align:right;font-variant-numeric:tabular-nums}
+.path{display:flex;gap:12px;align-items:center}.path>div{flex:1;background:#fff;border:1px solid #e5e7eb;border-radius:8px;padding:12px;font-size:13px}
+.arrow{flex:0;font-size:20px;color:#6b7280}
+svg{max-width:100%;height:auto;border:1px solid #e5e7eb;border-radius:8px;margin-bottom:12px}
+.warn{color:#b45309}pre{background:#fff;border:1px solid #e5e7eb;padding:12px;font-size:12px;overflow:auto}
+</style>
+</head>
+<body>
+<h1>{{.Title}}</h1>
+<div class="meta">{{.Time}} · {{.Version}}{{range .Flags}} · <span class="warn">{{.}}</span>{{end}}</div>
+<div class="tiles">{{range .Tiles}}<div class="tile"><span>{{.Label}}</span><b>{{.Value}}</b><span>{{.Note}}</span></div>{{end}}</div>
+{{if .Notes}}<h2>{{.NotesHeading}}</h2><table>{{range .Notes}}<tr><th>{{.Label}}</th><td>{{.Value}}</td></tr>{{end}}</table>{{end}}
+{{if .Charts}}<h2>{{.ChartsHeading}}</h2>{{range .Charts}}
+{{.}}{{end}}{{end}}
+{{if .Rounds}}<h2>{{.RoundsHeading}}</h2>
+<table><tr>{{range .RoundHeader}}<th>{{.}}</th>{{end}}</tr>
+{{range .Rounds}}<tr><td>{{index . 0}}</td>{{range slice . 1}}<td class="n">{{.}}</td>{{end}}</tr>
+{{end}}</table>{{end}}
+<h2>{{.PathHeading}}</h2>
+<div class="path"><div>{{range .Client}}{{.}}<br>{{end}}</div><div class="arrow">→</div><div>{{range .Server}}{{.}}<br>{{end}}</div></div>
+{{if .Details}}<table style="margin-top:12px">{{range .Details}}<tr><th>{{.Label}}</th><td>{{.Value}}</td></tr>{{end}}</table>{{end}}
+{{if .Diagnostics}}<h2>{{.DiagnosticsHeading}}</h2><ul>{{range .Diagnostics}}<li class="warn">{{.}}</li>{{end}}</ul>{{end}}
+<h2>{{.ConfigHeading}}</h2>
+<pre>{{.Config}}</pre>
+</body>
+</html>
+`))
+
+type htmlItem struct{ Label, Value, Note string }
+
+type htmlData struct {
+	Lang, Title, Time, Version string
+	Flags                      []string
+	Tiles                      []htmlItem
+	NotesHeading               string
+	Notes                      []htmlItem
+	ChartsHeading              string
+	Charts                     []template.HTML
+	RoundsHeading              string
+	RoundHeader                []string
+	Rounds                     [][]string
+	PathHeading                string
+	Client, Server             []string
+	Details                    []htmlItem
+	DiagnosticsHeading         string
+	Diagnostics                []string
+	ConfigHeading              string
+	Config                     string
+}
+
+// RenderHTML writes the report as a standalone HTML page: the headline
+// results, the history notes logged since the previous run, throughput and
+// latency over time, the rounds, the path from the client to the endpoint
+// and the configuration used. Unlike Upload it keeps the client's address,
+// which an ISP asks for.
+func RenderHTML(w io.Writer, rep *report.Report, notes []history.Note) error {
+	d := htmlData{
+		Lang:    i18n.Lang(),
+		Title:   i18n.Text("iNetSpeed-CLI Speed Test Report", "iNetSpeed-CLI 测速报告"),
+		Time:    rep.Time.Format("2006-01-02 15:04:05 MST"),
+		Version: rep.Version,
+
+		NotesHeading:       i18n.Text("Notes since the previous run", "上次测速以来的备注"),
+		ChartsHeading:      i18n.Text("Over Time", "时间曲线"),
+		RoundsHeading:      i18n.Text("Rounds", "测速轮次"),
+		PathHeading:        i18n.Text("Path", "路径"),
+		DiagnosticsHeading: i18n.Text("Diagnostics", "诊断"),
+		ConfigHeading:      i18n.Text("Configuration", "配置"),
+	}
+	if rep.Simulated {
+		d.Flags = append(d.Flags, i18n.Text("simulated", "模拟"))
+	}
+	if rep.Degraded {
+		d.Flags = append(d.Flags, i18n.Text("degraded", "降级"))
+	}
+	if rep.RateCapped {
+		d.Flags = append(d.Flags, i18n.Text("rate-capped", "已限速"))
+	}
+
+	for _, dir := range []string{report.DirDownload, report.DirUpload} {
+		label := i18n.Text("Download", "下载")
+		if dir == report.DirUpload {
+			label = i18n.Text("Upload", "上传")
+		}
+		if mbps := rep.Best(dir); mbps > 0 {
+			d.Tiles = append(d.Tiles, htmlItem{label, units.RateIn(rep.Units.Display, mbps, 0), i18n.Text("best round", "最佳轮次")})
+		}
+	}
+	if l := rep.IdleLatency; l.Samples > 0 {
+		d.Tiles = append(d.Tiles, htmlItem{i18n.Text("Idle Latency", "空载延迟"), fmt.Sprintf("%.1f ms", l.MedianMs),
+			fmt.Sprintf(i18n.Text("jitter %.1f ms", "抖动 %.1f 毫秒"), l.JitterMs)})
+	}
+
+	for _, n := range notes {
+		d.Notes = append(d.Notes, htmlItem{Label: n.Time.Local().Format("2006-01-02 15:04"), Value: n.Text})
+	}
+
+	charts, err := timelineCharts(rep.Rounds, rep.Units.Display)
+	if err != nil {
+		return err
+	}
+	d.Charts = charts
+
+	d.RoundHeader = []string{i18n.Text("Round", "轮次"), i18n.Text("Threads", "线程"), units.LabelIn(rep.Units.Display),
+		i18n.Text("Data", "流量"), i18n.Text("Time (s)", "耗时 (秒)"), i18n.Text("Loaded Latency (ms)", "负载延迟 (毫秒)"), i18n.Text("Faults", "故障")}
+	for _, rd := range rep.Rounds {
+		d.Rounds = append(d.Rounds, []string{rd.Title(), fmt.Sprint(rd.Threads), units.ValueIn(rep.Units.Display, rd.Mbps, 1),
+			config.HumanBytes(rd.Bytes), fmt.Sprintf("%.1f", rd.DurationSec), fmt.Sprintf("%.1f", rd.LoadedLatency.MedianMs), fmt.Sprint(rd.Faults)})
+	}
+
+	d.Client = peerLines(i18n.Text("Client", "客户端"), rep.Client)
+	d.Server = peerLines(i18n.Text("Endpoint", "测速节点"), rep.Server)
+	if rep.TestEndpoint != "" {
+		d.Details = append(d.Details, htmlItem{Label: i18n.Text("Test Endpoint", "测试节点"), Value: rep.TestEndpoint})
+	}
+	for _, n := range rep.CDN {
+		v := strings.Join(nonEmpty(n.Provider, n.Node, n.PoP, n.Cache), " · ")
+		d.Details = append(d.Details, htmlItem{Label: i18n.Text("Served by", "服务节点") + " (" + n.Stage + ")", Value: v})
+	}
+	for _, t := range rep.TLS {
+		v := strings.Join(nonEmpty(t.Version, t.CipherSuite, t.ALPN), ", ")
+		if t.Issuer != "" {
+			v += " · " + fmt.Sprintf(i18n.Text("issued by %s", "签发者 %s"), t.Issuer)
+		}
+		d.Details = append(d.Details, htmlItem{Label: "TLS", Value: v})
+	}
+	if s := rep.System; s != nil {
+		link := s.Interface
+		if s.WiFi != nil && s.WiFi.TxMbps > 0 {
+			link += fmt.Sprintf(", Wi-Fi PHY %g Mbps, %d dBm", s.WiFi.TxMbps, s.WiFi.RSSIdBm)
+		} else if s.LinkMbps > 0 {
+			link += fmt.Sprintf(", %d Mbps", s.LinkMbps)
+		}
+		d.Details = append(d.Details, htmlItem{Label: i18n.Text("Local Link", "本地链路"), Value: link})
+	}
+	for _, dg := range rep.Diagnostics {
+		d.Diagnostics = append(d.Diagnostics, transfer.Explain(dg))
+	}
+
+	cfg, err := json.MarshalIndent(rep.Config, "", "  ")
+	if err != nil {
+		return err
+	}
+	d.Config = string(cfg)
+	return htmlPage.Execute(w, d)
+}
+
+// timelineCharts draws each round's throughput, in unit, and, where it was
+// sampled, loaded latency over time, one line per round.
+func timelineCharts(rounds []report.Round, unit string) ([]template.HTML, error) {
+	var tput, lat []chart.Series
+	for _, rd := range rounds {
+		tl := rd.Timeline
+		if tl == nil {
+			continue
+		}
+		s := chart.Series{Name: rd.Title()}
+		for i, v := range tl.Mbps {
+			s.Points = append(s.Points, chart.Point{X: float64(i+1) * tl.IntervalSec, Y: units.In(unit, v)})
+		}
+		tput = append(tput, s)
+		l := chart.Series{Name: rd.Title()}
+		for _, p := range tl.Latency {
+			l.Points = append(l.Points, chart.Point{X: p.AtSec, Y: p.RTTMs})
+		}
+		lat = append(lat, l)
+	}
+	var out []template.HTML
+	plots := []struct {
+		plot   chart.Plot
+		series []chart.Series
+	}{
+		{chart.Plot{Title: i18n.Text("Throughput over time", "吞吐量随时间变化"), XLabel: i18n.Text("Time (s)", "时间 (秒)"), YLabel: units.LabelIn(unit)}, tput},
+		{chart.Plot{Title: i18n.Text("Loaded latency over time", "负载延迟随时间变化"), XLabel: i18n.Text("Time (s)", "时间 (秒)"), YLabel: i18n.Text("Latency (ms)", "延迟 (毫秒)")}, lat},
+	}
+	for _, p := range plots {
+		if !hasPoints(p.series) {
+			continue
+		}
+		var b bytes.Buffer
+		if err := chart.Lines(&b, p.plot, p.series); err != nil {
+			return nil, err
+		}
+		out = append(out, template.HTML(b.String()))
+	}
+	return out, nil
+}
+
+func hasPoints(series []chart.Series) bool {
+	for _, s := range series {
+		if len(s.Points) > 0 {
+			return true
+		}
+	}
+	return false
+}
+
+// peerLines describes one end of the path, heading first.
+func peerLines(heading string, p report.Peer) []string {
+	out := []string{heading}
+	if p.Host != "" {
+		out = append(out, p.Host)
+	}
+	out = append(out, nonEmpty(p.IP, p.Location, strings.TrimSpace(p.ASN+" "+p.ISP), p.Endpoint)...)
+	return out
+}
+
+func nonEmpty(vals ...string) []string {
+	var out []string
+	for _, v := range vals {
+		if v != "" {
+			out = append(out, v)
+		}
+	}
+	return out
+}
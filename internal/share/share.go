@@ -0,0 +1,143 @@
+package share
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/tsosunchia/iNetSpeed-CLI/internal/report"
+)
+
+var (
+	gistAPIURL = "https://api.github.com/gists"
+
+	httpClient = &http.Client{Timeout: 15 * time.Second}
+)
+
+type Options struct {
+	// URL of a paste service that accepts a JSON POST and replies with the
+	// link, either as plain text, a Location header or a JSON object.
+	URL string
+	// GistToken enables the built-in GitHub Gist backend when URL is empty.
+	GistToken string
+	// ProbeToken, when set, is sent to URL as a bearer token so a collector
+	// can authenticate the registered probe.
+	ProbeToken string
+}
+
+// Upload publishes the anonymized report and returns a shareable link.
+func Upload(ctx context.Context, rep *report.Report, opts Options) (string, error) {
+	var body bytes.Buffer
+	if err := rep.Anonymized().WriteJSON(&body); err != nil {
+		return "", err
+	}
+	switch {
+	case opts.URL != "":
+		return postPaste(ctx, opts.URL, opts.ProbeToken, body.Bytes())
+	case opts.GistToken != "":
+		return postGist(ctx, opts.GistToken, body.String(), rep.Time)
+	default:
+		return "", errors.New("no share target configured")
+	}
+}
+
+func postPaste(ctx context.Context, target, token string, body []byte) (string, error) {
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, target, bytes.NewReader(body))
+	if err != nil {
+		return "", err
+	}
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set("Accept", "application/json, text/plain")
+	if token != "" {
+		req.Header.Set("Authorization", "Bearer "+token)
+	}
+
+	resp, err := httpClient.Do(req)
+	if err != nil {
+		return "", err
+	}
+	defer resp.Body.Close()
+	data, err := io.ReadAll(io.LimitReader(resp.Body, 64*1024))
+	if err != nil {
+		return "", err
+	}
+	if resp.StatusCode >= 400 {
+		return "", fmt.Errorf("HTTP %d", resp.StatusCode)
+	}
+	if link := linkFromBody(data); link != "" {
+		return link, nil
+	}
+	if loc := resp.Header.Get("Location"); loc != "" {
+		return loc, nil
+	}
+	return "", errors.New("paste service returned no link")
+}
+
+// linkFromBody accepts either a bare URL or a JSON object carrying one of the
+// commonly used link fields.
+func linkFromBody(data []byte) string {
+	var obj map[string]any
+	if json.Unmarshal(data, &obj) == nil {
+		for _, k := range []string{"url", "html_url", "link", "href"} {
+			if v, ok := obj[k].(string); ok && isHTTPURL(v) {
+				return v
+			}
+		}
+		return ""
+	}
+	s := strings.TrimSpace(string(data))
+	if isHTTPURL(s) && !strings.ContainsAny(s, " \n") {
+		return s
+	}
+	return ""
+}
+
+func isHTTPURL(s string) bool {
+	return strings.HasPrefix(s, "http://") || strings.HasPrefix(s, "https://")
+}
+
+func postGist(ctx context.Context, token, content string, ts time.Time) (string, error) {
+	payload := map[string]any{
+		"description": "iNetSpeed-CLI result " + ts.Format(time.RFC3339),
+		"public":      false,
+		"files": map[string]any{
+			"speedtest.json": map[string]string{"content": content},
+		},
+	}
+	body, err := json.Marshal(payload)
+	if err != nil {
+		return "", err
+	}
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, gistAPIURL, bytes.NewReader(body))
+	if err != nil {
+		return "", err
+	}
+	req.Header.Set("Accept", "application/vnd.github+json")
+	req.Header.Set("Authorization", "Bearer "+token)
+	req.Header.Set("Content-Type", "application/json")
+
+	resp, err := httpClient.Do(req)
+	if err != nil {
+		return "", err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusCreated {
+		return "", fmt.Errorf("GitHub API: HTTP %d", resp.StatusCode)
+	}
+	var out struct {
+		HTMLURL string `json:"html_url"`
+	}
+	if err := json.NewDecoder(resp.Body).Decode(&out); err != nil {
+		return "", err
+	}
+	if out.HTMLURL == "" {
+		return "", errors.New("GitHub API returned no gist URL")
+	}
+	return out.HTMLURL, nil
+}
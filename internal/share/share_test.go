@@ -0,0 +1,224 @@
+package share
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"image/png"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/tsosunchia/iNetSpeed-CLI/internal/history"
+	"github.com/tsosunchia/iNetSpeed-CLI/internal/i18n"
+	"github.com/tsosunchia/iNetSpeed-CLI/internal/report"
+)
+
+func testReport() *report.Report {
+	r := report.New()
+	r.Client = report.Peer{IP: "198.51.100.7", ISP: "Example", ASN: "AS64500 Example"}
+	r.Server = report.Peer{IP: "17.253.1.1", Host: "mensura.cdn-apple.com"}
+	r.IdleLatency = report.Latency{MedianMs: 8.3, JitterMs: 0.4, Samples: 20}
+	r.Rounds = []report.Round{
+		{Direction: report.DirDownload, Mbps: 812.4},
+		{Direction: report.DirUpload, Mbps: 96.2},
+	}
+	return r
+}
+
+func TestUploadPastePlainText(t *testing.T) {
+	var got map[string]any
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != http.MethodPost {
+			t.Errorf("method = %s, want POST", r.Method)
+		}
+		_ = json.NewDecoder(r.Body).Decode(&got)
+		io.WriteString(w, "https://paste.example/abc\n")
+	}))
+	defer srv.Close()
+
+	link, err := Upload(context.Background(), testReport(), Options{URL: srv.URL})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if link != "https://paste.example/abc" {
+		t.Errorf("link = %q", link)
+	}
+	client, _ := got["client"].(map[string]any)
+	if _, ok := client["ip"]; ok {
+		t.Error("uploaded report must not contain the client IP")
+	}
+}
+
+func TestUploadPasteProbe(t *testing.T) {
+	var got map[string]any
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Header.Get("Authorization") != "Bearer probe-secret" {
+			t.Errorf("Authorization = %q", r.Header.Get("Authorization"))
+		}
+		_ = json.NewDecoder(r.Body).Decode(&got)
+		io.WriteString(w, "https://collector.example/r/1")
+	}))
+	defer srv.Close()
+
+	rep := testReport()
+	rep.Probe = &report.Probe{ID: "abc", Name: "lab-1"}
+	if _, err := Upload(context.Background(), rep, Options{URL: srv.URL, ProbeToken: "probe-secret"}); err != nil {
+		t.Fatal(err)
+	}
+	if p, _ := got["probe"].(map[string]any); p["id"] != "abc" || p["name"] != "lab-1" {
+		t.Errorf("probe = %v", got["probe"])
+	}
+}
+
+func TestUploadPasteJSONAndLocation(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path == "/json" {
+			io.WriteString(w, `{"url":"https://paste.example/json"}`)
+			return
+		}
+		w.Header().Set("Location", "https://paste.example/loc")
+		w.WriteHeader(http.StatusCreated)
+	}))
+	defer srv.Close()
+
+	link, err := Upload(context.Background(), testReport(), Options{URL: srv.URL + "/json"})
+	if err != nil || link != "https://paste.example/json" {
+		t.Errorf("JSON link = %q, %v", link, err)
+	}
+	link, err = Upload(context.Background(), testReport(), Options{URL: srv.URL + "/loc"})
+	if err != nil || link != "https://paste.example/loc" {
+		t.Errorf("Location link = %q, %v", link, err)
+	}
+}
+
+func TestUploadPasteError(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusInternalServerError)
+	}))
+	defer srv.Close()
+
+	if _, err := Upload(context.Background(), testReport(), Options{URL: srv.URL}); err == nil {
+		t.Fatal("expected error on HTTP 500")
+	}
+	if _, err := Upload(context.Background(), testReport(), Options{}); err == nil {
+		t.Fatal("expected error without a share target")
+	}
+}
+
+func TestUploadGist(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Header.Get("Authorization") != "Bearer tok" {
+			t.Errorf("Authorization = %q", r.Header.Get("Authorization"))
+		}
+		var body struct {
+			Files map[string]struct {
+				Content string `json:"content"`
+			} `json:"files"`
+		}
+		_ = json.NewDecoder(r.Body).Decode(&body)
+		if !strings.Contains(body.Files["speedtest.json"].Content, `"rounds"`) {
+			t.Error("gist content does not contain the report")
+		}
+		w.WriteHeader(http.StatusCreated)
+		io.WriteString(w, `{"html_url":"https://gist.github.com/x/1"}`)
+	}))
+	defer srv.Close()
+
+	old := gistAPIURL
+	gistAPIURL = srv.URL
+	defer func() { gistAPIURL = old }()
+
+	link, err := Upload(context.Background(), testReport(), Options{GistToken: "tok"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if link != "https://gist.github.com/x/1" {
+		t.Errorf("link = %q", link)
+	}
+}
+
+func TestRenderCard(t *testing.T) {
+	var buf bytes.Buffer
+	if err := RenderCard(&buf, testReport()); err != nil {
+		t.Fatal(err)
+	}
+	img, err := png.Decode(&buf)
+	if err != nil {
+		t.Fatalf("card is not a valid PNG: %v", err)
+	}
+	if b := img.Bounds(); b.Dx() != cardW || b.Dy() != cardH {
+		t.Errorf("card size = %v", b)
+	}
+}
+
+func TestRenderHTML(t *testing.T) {
+	t.Setenv("SPEEDTEST_LANG", "en")
+	i18n.SetFromEnv()
+	rep := testReport()
+	rep.Config.DLURL = "https://mensura.cdn-apple.com/api/v1/gm/large"
+	rep.Server.Location = "Hong Kong <HK>"
+	rep.Rounds[0].Name = "Download (4 threads)"
+	rep.Rounds[0].Timeline = &report.Timeline{IntervalSec: 0.1, Mbps: []float64{700, 800, 812},
+		Latency: []report.TimedRTT{{AtSec: 0.2, RTTMs: 30}}}
+	rep.Diagnostics = []report.Diagnostic{{Direction: report.DirUpload, Fault: "reset", Count: 3}}
+
+	notes := []history.Note{{Time: rep.Time.Add(-time.Hour), Text: "switched to <new> router"}}
+
+	var buf bytes.Buffer
+	if err := RenderHTML(&buf, rep, notes); err != nil {
+		t.Fatal(err)
+	}
+	page := buf.String()
+	for _, want := range []string{"<!DOCTYPE html>", "Notes since the previous run", "switched to &lt;new&gt; router", "812 Mbps", "8.3 ms", "198.51.100.7", "Hong Kong &lt;HK&gt;",
+		"Throughput over time", "Loaded latency over time", "Download (4 threads)",
+		"3× connection reset during upload", "&#34;dl_url&#34;: &#34;https://mensura.cdn-apple.com/api/v1/gm/large&#34;"} {
+		if !strings.Contains(page, want) {
+			t.Errorf("page lacks %q", want)
+		}
+	}
+	// Standalone: nothing is fetched from elsewhere.
+	for _, ext := range []string{"<script src", "<link", "<img", "url("} {
+		if strings.Contains(page, ext) {
+			t.Errorf("page references an external asset: %q", ext)
+		}
+	}
+	if got := strings.Count(page, "<svg"); got != 2 {
+		t.Errorf("%d charts, want 2", got)
+	}
+
+	// Without timelines the charts are left out.
+	buf.Reset()
+	if err := RenderHTML(&buf, testReport(), nil); err != nil || strings.Contains(buf.String(), "<svg") || strings.Contains(buf.String(), "Notes since") {
+		t.Errorf("charts or notes without any: %v", err)
+	}
+
+	// Throughput is shown in the unit the terminal showed, not the one
+	// current when the page is rendered.
+	rep.Units.Display = "MB/s"
+	buf.Reset()
+	if err := RenderHTML(&buf, rep, nil); err != nil {
+		t.Fatal(err)
+	}
+	for _, want := range []string{"101.5 MB/s", "<th>MB/s</th>"} {
+		if !strings.Contains(buf.String(), want) {
+			t.Errorf("MB/s page lacks %q", want)
+		}
+	}
+	if strings.Contains(buf.String(), "Mbps") {
+		t.Error("MB/s page shows Mbps")
+	}
+}
+
+func TestFontGlyphShape(t *testing.T) {
+	for ch, g := range font5x7 {
+		for _, row := range g {
+			if len(row) != glyphW {
+				t.Fatalf("glyph %q has row %q of width %d", ch, row, len(row))
+			}
+		}
+	}
+}
@@ -0,0 +1,163 @@
+// Package sign adds the token or signature a restricted speedtest backend
+// demands to every test request, as REQUEST_SIGN describes: query
+// parameters or headers whose values are expanded for each request from
+// the time, a nonce and an HMAC of the request.
+package sign
+
+import (
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/url"
+	"os"
+	"regexp"
+	"strconv"
+	"strings"
+	"time"
+
+	"golang.org/x/net/http/httpguts"
+)
+
+// Template is a parsed REQUEST_SIGN: items separated by &, each either a
+// query parameter "name=value" or a header "Name: value". Values may hold
+// the placeholders
+//
+//	{timestamp}        Unix time in seconds when the request is sent
+//	{timestamp+N}      that plus N seconds, e.g. for an expiry; also -N
+//	{nonce}            16 random hex digits, new for every request
+//	{hmac(KEY)}        hex HMAC-SHA256 of the request keyed by KEY, or by
+//	                   the value of environment variable NAME for env:NAME
+//
+// The HMAC signs METHOD "\n" REQUEST-URI "\n" TIMESTAMP, where REQUEST-URI
+// is the path and query with the template's other parameters already
+// added, so it covers them too.
+type Template struct {
+	raw   string
+	items []item
+}
+
+type item struct {
+	header bool
+	name   string
+	value  string
+	keys   [][]byte // HMAC keys of the value's hmac placeholders, in order
+}
+
+var placeholderRe = regexp.MustCompile(`\{([^{}]*)\}`)
+
+// Parse reads a template. Secrets given as env:NAME are read now, so an
+// unset variable is an error at startup rather than a rejected request.
+func Parse(s string) (*Template, error) {
+	t := &Template{raw: strings.TrimSpace(s)}
+	for _, part := range strings.Split(t.raw, "&") {
+		if part = strings.TrimSpace(part); part == "" {
+			continue
+		}
+		it, err := parseItem(part)
+		if err != nil {
+			return nil, err
+		}
+		t.items = append(t.items, it)
+	}
+	if len(t.items) == 0 {
+		return nil, errors.New("no query parameter or header")
+	}
+	return t, nil
+}
+
+func parseItem(part string) (item, error) {
+	var it item
+	eq, colon := strings.Index(part, "="), strings.Index(part, ":")
+	switch {
+	case colon > 0 && (eq < 0 || colon < eq):
+		it.header = true
+		it.name, it.value = strings.TrimSpace(part[:colon]), strings.TrimSpace(part[colon+1:])
+		if !httpguts.ValidHeaderFieldName(it.name) {
+			return it, fmt.Errorf("invalid header name %q", it.name)
+		}
+	case eq > 0:
+		it.name, it.value = part[:eq], part[eq+1:]
+	default:
+		return it, fmt.Errorf("%q is neither name=value nor Name: value", part)
+	}
+	for _, m := range placeholderRe.FindAllStringSubmatch(it.value, -1) {
+		p := m[1]
+		switch {
+		case p == "timestamp" || p == "nonce":
+		case strings.HasPrefix(p, "timestamp+") || strings.HasPrefix(p, "timestamp-"):
+			if _, err := strconv.ParseInt(p[len("timestamp"):], 10, 64); err != nil {
+				return it, fmt.Errorf("invalid offset in {%s}", p)
+			}
+		case strings.HasPrefix(p, "hmac(") && strings.HasSuffix(p, ")"):
+			key := p[len("hmac(") : len(p)-1]
+			if name, ok := strings.CutPrefix(key, "env:"); ok {
+				if key = os.Getenv(name); key == "" {
+					return it, fmt.Errorf("{%s}: %s is not set", p, name)
+				}
+			}
+			if key == "" {
+				return it, fmt.Errorf("{%s} has no key", p)
+			}
+			it.keys = append(it.keys, []byte(key))
+		default:
+			return it, fmt.Errorf("unknown placeholder {%s}", p)
+		}
+	}
+	return it, nil
+}
+
+// String is the template as given, which may hold secrets: do not log it.
+func (t *Template) String() string { return t.raw }
+
+// Apply adds the template's parameters and headers to req, which is about
+// to be sent.
+func (t *Template) Apply(req *http.Request) {
+	var b [8]byte
+	rand.Read(b[:])
+	t.apply(req, time.Now(), hex.EncodeToString(b[:]))
+}
+
+func (t *Template) apply(req *http.Request, now time.Time, nonce string) {
+	ts := now.Unix()
+	// The signed items come last, so their HMAC covers every other one.
+	for _, signed := range []bool{false, true} {
+		for _, it := range t.items {
+			if (len(it.keys) > 0) != signed {
+				continue
+			}
+			k := 0
+			v := placeholderRe.ReplaceAllStringFunc(it.value, func(m string) string {
+				p := m[1 : len(m)-1]
+				switch {
+				case p == "timestamp":
+					return strconv.FormatInt(ts, 10)
+				case p == "nonce":
+					return nonce
+				case strings.HasPrefix(p, "timestamp"):
+					off, _ := strconv.ParseInt(p[len("timestamp"):], 10, 64)
+					return strconv.FormatInt(ts+off, 10)
+				}
+				mac := hmac.New(sha256.New, it.keys[k])
+				k++
+				fmt.Fprintf(mac, "%s\n%s\n%d", req.Method, req.URL.RequestURI(), ts)
+				return hex.EncodeToString(mac.Sum(nil))
+			})
+			if it.header {
+				req.Header.Set(it.name, v)
+				continue
+			}
+			// Appended rather than re-encoded, which would reorder the
+			// URL's own parameters.
+			q := url.QueryEscape(it.name) + "=" + url.QueryEscape(v)
+			if req.URL.RawQuery == "" {
+				req.URL.RawQuery = q
+			} else {
+				req.URL.RawQuery += "&" + q
+			}
+		}
+	}
+}
@@ -0,0 +1,65 @@
+package sign
+
+import (
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"net/http"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestApply(t *testing.T) {
+	t.Setenv("SIGN_KEY", "s3cret")
+	tmpl, err := Parse("ts={timestamp}&exp={timestamp+300}&sig={hmac(env:SIGN_KEY)}&X-Nonce: {nonce}")
+	if err != nil {
+		t.Fatal(err)
+	}
+	req, _ := http.NewRequest(http.MethodGet, "https://speed.example/large?b=2&a=1", nil)
+	tmpl.apply(req, time.Unix(1700000000, 0), "00ff")
+	q := req.URL.Query()
+	if q.Get("ts") != "1700000000" || q.Get("exp") != "1700000300" || req.Header.Get("X-Nonce") != "00ff" {
+		t.Fatalf("url %s, header %v", req.URL, req.Header)
+	}
+	// The URL's own parameters keep their order, and the signature covers
+	// the parameters added before it.
+	unsigned := "/large?b=2&a=1&ts=1700000000&exp=1700000300"
+	if !strings.HasPrefix(req.URL.RequestURI(), unsigned+"&sig=") {
+		t.Fatalf("request URI %s", req.URL.RequestURI())
+	}
+	mac := hmac.New(sha256.New, []byte("s3cret"))
+	mac.Write([]byte("GET\n" + unsigned + "\n1700000000"))
+	if want := hex.EncodeToString(mac.Sum(nil)); q.Get("sig") != want {
+		t.Errorf("sig = %s, want %s", q.Get("sig"), want)
+	}
+}
+
+func TestApplyHeaderSignature(t *testing.T) {
+	tmpl, err := Parse("X-Auth-Time: {timestamp}&X-Auth-Sig: {hmac(key)}")
+	if err != nil {
+		t.Fatal(err)
+	}
+	req, _ := http.NewRequest(http.MethodPut, "http://speed.example/upload", nil)
+	tmpl.apply(req, time.Unix(1700000000, 0), "")
+	mac := hmac.New(sha256.New, []byte("key"))
+	mac.Write([]byte("PUT\n/upload\n1700000000"))
+	if req.URL.RawQuery != "" || req.Header.Get("X-Auth-Time") != "1700000000" || req.Header.Get("X-Auth-Sig") != hex.EncodeToString(mac.Sum(nil)) {
+		t.Errorf("url %s, header %v", req.URL, req.Header)
+	}
+	a, b := req.Clone(req.Context()), req.Clone(req.Context())
+	tmpl.Apply(a)
+	tmpl.Apply(b)
+	if a.Header.Get("X-Auth-Time") == "" {
+		t.Error("Apply set no header")
+	}
+}
+
+func TestParseErrors(t *testing.T) {
+	t.Setenv("EMPTY_KEY", "")
+	for _, s := range []string{"", " & ", "token", "ts={time}", "exp={timestamp+5m}", "sig={hmac()}", "sig={hmac(env:EMPTY_KEY)}", "Bad Header: x"} {
+		if _, err := Parse(s); err == nil {
+			t.Errorf("Parse(%q): no error", s)
+		}
+	}
+}
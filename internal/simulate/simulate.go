@@ -0,0 +1,291 @@
+// Package simulate provides an in-process emulation of the mensura CDN
+// endpoints with configurable bandwidth, latency and fault injection. It
+// backs --simulate for offline demos and deterministic end-to-end tests.
+package simulate
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"math/rand/v2"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/tsosunchia/iNetSpeed-CLI/internal/ratelimit"
+)
+
+// Paths served by the emulator, matching the real mensura layout.
+const (
+	PathConfig = "/api/v1/gm/config"
+	PathSmall  = "/api/v1/gm/small"
+	PathLarge  = "/api/v1/gm/large"
+	PathSlurp  = "/api/v1/gm/slurp"
+)
+
+// Options shape the emulated link. Bandwidth is shared by all connections in
+// each direction, like a real access link. Uploads are paced as the server
+// reads, so the client's socket buffers still show up as an initial burst.
+type Options struct {
+	Bandwidth int64         // bits per second per direction; 0 is unlimited
+	Latency   time.Duration // added before every response
+	ErrorRate float64       // fraction of transfer requests answered with Status
+	Status    int           // injected error status; 503 when zero
+	Seed      uint64        // seeds fault injection so runs are repeatable
+	LargeSize int64         // body size of the download endpoint
+
+	// Faults applied to every transfer request, in either direction.
+	DropAfter int64         // abort the connection after this many body bytes
+	Stall     time.Duration // pause the body once for this long...
+	StallAt   int64         // ...after this many bytes
+}
+
+// DefaultOptions emulates a 200 Mbps link with 20 ms latency and no faults.
+func DefaultOptions() Options {
+	return Options{
+		Bandwidth: 200_000_000,
+		Latency:   20 * time.Millisecond,
+		Seed:      1,
+		LargeSize: 1 << 30,
+	}
+}
+
+// Server is a running emulator.
+type Server struct {
+	*httptest.Server
+	Options Options
+}
+
+// Start serves the emulator on a loopback port until Close. It speaks
+// HTTP/1.1 and cleartext HTTP/2 (h2c), so every connection mode can be tried.
+func Start(opts Options) *Server {
+	ts := httptest.NewUnstartedServer(NewHandler(opts))
+	ts.Config.Protocols = new(http.Protocols)
+	ts.Config.Protocols.SetHTTP1(true)
+	ts.Config.Protocols.SetUnencryptedHTTP2(true)
+	ts.Start()
+	return &Server{Server: ts, Options: opts}
+}
+
+// DownloadURL, UploadURL and LatencyURL point the speedtest at the emulator.
+func (s *Server) DownloadURL() string { return s.URL + PathLarge }
+func (s *Server) UploadURL() string   { return s.URL + PathSlurp }
+func (s *Server) LatencyURL() string  { return s.URL + PathSmall }
+
+type handler struct {
+	opts Options
+	down *ratelimit.Bucket
+	up   *ratelimit.Bucket
+
+	mu  sync.Mutex
+	rng *rand.Rand
+}
+
+// NewHandler returns the emulator's HTTP handler.
+func NewHandler(opts Options) http.Handler {
+	if opts.LargeSize <= 0 {
+		opts.LargeSize = DefaultOptions().LargeSize
+	}
+	h := &handler{
+		opts: opts,
+		down: ratelimit.NewBucket(opts.Bandwidth),
+		up:   ratelimit.NewBucket(opts.Bandwidth),
+		rng:  rand.New(rand.NewPCG(opts.Seed, opts.Seed^0x9e3779b97f4a7c15)),
+	}
+	mux := http.NewServeMux()
+	mux.HandleFunc(PathConfig, h.config)
+	mux.HandleFunc(PathSmall, h.small)
+	mux.HandleFunc(PathLarge, h.large)
+	mux.HandleFunc(PathSlurp, h.slurp)
+	return mux
+}
+
+// fail answers the request with an injected error status when the error
+// dice say so, reporting whether it did.
+func (h *handler) fail(w http.ResponseWriter) bool {
+	if h.opts.ErrorRate <= 0 {
+		return false
+	}
+	h.mu.Lock()
+	hit := h.rng.Float64() < h.opts.ErrorRate
+	h.mu.Unlock()
+	if hit {
+		status := h.opts.Status
+		if status == 0 {
+			status = http.StatusServiceUnavailable
+		}
+		http.Error(w, "injected fault", status)
+	}
+	return hit
+}
+
+// faults tracks the drop and stall faults of one transfer request.
+type faults struct {
+	opts    *Options
+	stalled bool
+}
+
+// before runs ahead of the next n body bytes, given done bytes so far, and
+// returns n shortened so a fault lands on its exact offset. Dropping panics
+// with http.ErrAbortHandler, which makes net/http close the socket without
+// finishing the response.
+func (f *faults) before(ctx context.Context, done, n int64) int64 {
+	o := f.opts
+	if o.Stall > 0 && !f.stalled {
+		if done >= o.StallAt {
+			f.stalled = true
+			sleep(ctx, o.Stall)
+		} else if done+n > o.StallAt {
+			n = o.StallAt - done
+		}
+	}
+	if o.DropAfter > 0 {
+		if done >= o.DropAfter {
+			panic(http.ErrAbortHandler)
+		}
+		n = min(n, o.DropAfter-done)
+	}
+	return n
+}
+
+func sleep(ctx context.Context, d time.Duration) bool {
+	if d <= 0 {
+		return true
+	}
+	t := time.NewTimer(d)
+	defer t.Stop()
+	select {
+	case <-t.C:
+		return true
+	case <-ctx.Done():
+		return false
+	}
+}
+
+func (h *handler) delay(ctx context.Context) bool {
+	return sleep(ctx, h.opts.Latency)
+}
+
+func (h *handler) config(w http.ResponseWriter, r *http.Request) {
+	base := "http://" + r.Host
+	host, _, err := net.SplitHostPort(r.Host)
+	if err != nil {
+		host = r.Host
+	}
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(map[string]any{
+		"version":       1,
+		"test_endpoint": host,
+		"urls": map[string]string{
+			"small_https_download_url": base + PathSmall,
+			"large_https_download_url": base + PathLarge,
+			"https_upload_url":         base + PathSlurp,
+		},
+	})
+}
+
+func (h *handler) small(w http.ResponseWriter, r *http.Request) {
+	if !h.delay(r.Context()) {
+		return
+	}
+	w.Write([]byte{0})
+}
+
+func (h *handler) large(w http.ResponseWriter, r *http.Request) {
+	if !h.delay(r.Context()) {
+		return
+	}
+	if h.fail(w) {
+		return
+	}
+	w.Header().Set("Content-Type", "application/octet-stream")
+	w.Header().Set("Accept-Ranges", "bytes")
+	size := h.opts.LargeSize
+	if v := r.Header.Get("Range"); v != "" {
+		first, last, ok := parseRange(v, size)
+		if !ok {
+			w.Header().Set("Content-Range", fmt.Sprintf("bytes */%d", size))
+			w.WriteHeader(http.StatusRequestedRangeNotSatisfiable)
+			return
+		}
+		w.Header().Set("Content-Range", fmt.Sprintf("bytes %d-%d/%d", first, last, size))
+		w.Header().Set("Content-Length", strconv.FormatInt(last-first+1, 10))
+		w.WriteHeader(http.StatusPartialContent)
+		size = last - first + 1
+	}
+	ctx := r.Context()
+	chunk := make([]byte, 32*1024)
+	flusher, _ := w.(http.Flusher)
+	f := faults{opts: &h.opts}
+	for sent := int64(0); sent < size; {
+		n := min(int64(len(chunk)), size-sent)
+		n = f.before(ctx, sent, n)
+		if err := h.down.Wait(ctx, int(n)); err != nil {
+			return
+		}
+		if _, err := w.Write(chunk[:n]); err != nil {
+			return
+		}
+		if flusher != nil {
+			flusher.Flush()
+		}
+		sent += n
+	}
+}
+
+// parseRange reads a single "bytes=first-last" range of a size-byte body;
+// last may be omitted.
+func parseRange(v string, size int64) (first, last int64, ok bool) {
+	spec, found := strings.CutPrefix(v, "bytes=")
+	a, b, dash := strings.Cut(spec, "-")
+	if !found || !dash {
+		return 0, 0, false
+	}
+	first, err := strconv.ParseInt(a, 10, 64)
+	if err != nil || first < 0 || first >= size {
+		return 0, 0, false
+	}
+	last = size - 1
+	if b != "" {
+		if last, err = strconv.ParseInt(b, 10, 64); err != nil || last < first {
+			return 0, 0, false
+		}
+		last = min(last, size-1)
+	}
+	return first, last, true
+}
+
+func (h *handler) slurp(w http.ResponseWriter, r *http.Request) {
+	if !h.delay(r.Context()) {
+		return
+	}
+	if h.fail(w) {
+		return
+	}
+	ctx := r.Context()
+	buf := make([]byte, 32*1024)
+	f := faults{opts: &h.opts}
+	var got int64
+	for {
+		want := f.before(ctx, got, int64(len(buf)))
+		n, err := r.Body.Read(buf[:want])
+		got += int64(n)
+		if werr := h.up.Wait(ctx, n); werr != nil {
+			return
+		}
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return
+		}
+	}
+	// The resumable-upload draft's offset acknowledges what arrived.
+	w.Header().Set("Upload-Offset", strconv.FormatInt(got, 10))
+	w.WriteHeader(http.StatusOK)
+}
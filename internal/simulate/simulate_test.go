@@ -0,0 +1,207 @@
+package simulate
+
+import (
+	"encoding/json"
+	"io"
+	"net/http"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestDownloadBandwidth(t *testing.T) {
+	opts := Options{Bandwidth: 40_000_000, LargeSize: 1 << 30}
+	srv := Start(opts)
+	defer srv.Close()
+
+	resp, err := http.Get(srv.DownloadURL())
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer resp.Body.Close()
+	start := time.Now()
+	n, _ := io.CopyN(io.Discard, resp.Body, 2_500_000) // 0.5s at 40 Mbps
+	el := time.Since(start)
+	if n != 2_500_000 {
+		t.Fatalf("read %d bytes", n)
+	}
+	if el < 350*time.Millisecond || el > 1500*time.Millisecond {
+		t.Errorf("2.5 MB took %v, want about 500ms", el)
+	}
+}
+
+func TestLatencyAndSize(t *testing.T) {
+	srv := Start(Options{Latency: 30 * time.Millisecond, LargeSize: 1000})
+	defer srv.Close()
+
+	start := time.Now()
+	resp, err := http.Get(srv.LatencyURL())
+	if err != nil {
+		t.Fatal(err)
+	}
+	resp.Body.Close()
+	if el := time.Since(start); el < 30*time.Millisecond {
+		t.Errorf("small responded in %v, want >= 30ms", el)
+	}
+
+	resp, err = http.Get(srv.DownloadURL())
+	if err != nil {
+		t.Fatal(err)
+	}
+	body, _ := io.ReadAll(resp.Body)
+	resp.Body.Close()
+	if len(body) != 1000 {
+		t.Errorf("large returned %d bytes, want 1000", len(body))
+	}
+
+	resp, err = http.Post(srv.UploadURL(), "application/octet-stream", strings.NewReader("payload"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		t.Errorf("slurp status = %d", resp.StatusCode)
+	}
+}
+
+func TestErrorInjectionIsSeeded(t *testing.T) {
+	statuses := func() []int {
+		srv := Start(Options{ErrorRate: 0.5, Seed: 42, LargeSize: 10})
+		defer srv.Close()
+		var out []int
+		for i := 0; i < 20; i++ {
+			resp, err := http.Get(srv.DownloadURL())
+			if err != nil {
+				t.Fatal(err)
+			}
+			io.Copy(io.Discard, resp.Body)
+			resp.Body.Close()
+			out = append(out, resp.StatusCode)
+		}
+		return out
+	}
+	a, b := statuses(), statuses()
+	failed := 0
+	for i := range a {
+		if a[i] != b[i] {
+			t.Fatalf("run %d differs: %d vs %d", i, a[i], b[i])
+		}
+		if a[i] == http.StatusServiceUnavailable {
+			failed++
+		}
+	}
+	if failed == 0 || failed == len(a) {
+		t.Errorf("%d of %d requests failed, want a mix", failed, len(a))
+	}
+}
+
+func TestConfigEndpoint(t *testing.T) {
+	srv := Start(DefaultOptions())
+	defer srv.Close()
+
+	resp, err := http.Get(srv.URL + PathConfig)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer resp.Body.Close()
+	var cfg struct {
+		TestEndpoint string            `json:"test_endpoint"`
+		URLs         map[string]string `json:"urls"`
+	}
+	if err := json.NewDecoder(resp.Body).Decode(&cfg); err != nil {
+		t.Fatal(err)
+	}
+	if cfg.URLs["large_https_download_url"] != srv.DownloadURL() {
+		t.Errorf("config urls = %v", cfg.URLs)
+	}
+	if cfg.TestEndpoint != "127.0.0.1" {
+		t.Errorf("test_endpoint = %q", cfg.TestEndpoint)
+	}
+}
+
+func TestDropAfter(t *testing.T) {
+	srv := Start(Options{DropAfter: 100_000, LargeSize: 1 << 20})
+	defer srv.Close()
+
+	resp, err := http.Get(srv.DownloadURL())
+	if err != nil {
+		t.Fatal(err)
+	}
+	n, err := io.Copy(io.Discard, resp.Body)
+	resp.Body.Close()
+	if err == nil {
+		t.Error("expected an error from the dropped connection")
+	}
+	if n != 100_000 {
+		t.Errorf("read %d bytes before the drop, want 100000", n)
+	}
+}
+
+func TestInjectedStatus(t *testing.T) {
+	srv := Start(Options{ErrorRate: 1, Status: 502})
+	defer srv.Close()
+
+	resp, err := http.Post(srv.UploadURL(), "application/octet-stream", strings.NewReader("x"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	resp.Body.Close()
+	if resp.StatusCode != http.StatusBadGateway {
+		t.Errorf("status = %d, want 502", resp.StatusCode)
+	}
+}
+
+func TestStall(t *testing.T) {
+	srv := Start(Options{Stall: 200 * time.Millisecond, StallAt: 50_000, LargeSize: 100_000})
+	defer srv.Close()
+
+	resp, err := http.Get(srv.DownloadURL())
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer resp.Body.Close()
+	start := time.Now()
+	if _, err := io.CopyN(io.Discard, resp.Body, 50_000); err != nil {
+		t.Fatal(err)
+	}
+	before := time.Since(start)
+	n, err := io.Copy(io.Discard, resp.Body)
+	if err != nil || n != 50_000 {
+		t.Fatalf("rest of body: %d bytes, %v", n, err)
+	}
+	if before > 150*time.Millisecond {
+		t.Errorf("stalled before offset: first half took %v", before)
+	}
+	if el := time.Since(start); el < 200*time.Millisecond {
+		t.Errorf("body finished in %v, want a 200ms stall", el)
+	}
+}
+
+func TestRange(t *testing.T) {
+	srv := Start(Options{LargeSize: 1000})
+	defer srv.Close()
+
+	for _, tc := range []struct {
+		rng          string
+		status       int
+		contentRange string
+		n            int
+	}{
+		{"bytes=0-0", http.StatusPartialContent, "bytes 0-0/1000", 1},
+		{"bytes=900-", http.StatusPartialContent, "bytes 900-999/1000", 100},
+		{"bytes=990-2000", http.StatusPartialContent, "bytes 990-999/1000", 10},
+		{"bytes=1000-1001", http.StatusRequestedRangeNotSatisfiable, "bytes */1000", 0},
+	} {
+		req, _ := http.NewRequest(http.MethodGet, srv.DownloadURL(), nil)
+		req.Header.Set("Range", tc.rng)
+		resp, err := http.DefaultClient.Do(req)
+		if err != nil {
+			t.Fatal(err)
+		}
+		body, _ := io.ReadAll(resp.Body)
+		resp.Body.Close()
+		if resp.StatusCode != tc.status || resp.Header.Get("Content-Range") != tc.contentRange || len(body) != tc.n {
+			t.Errorf("%s: %d %q, %d bytes", tc.rng, resp.StatusCode, resp.Header.Get("Content-Range"), len(body))
+		}
+	}
+}
@@ -0,0 +1,232 @@
+// Package sink pushes the metrics of a finished run to time-series
+// databases: InfluxDB through its HTTP line-protocol write API and Graphite
+// through its plaintext protocol, so long-term monitoring needs no glue
+// around the CLI.
+package sink
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"maps"
+	"net"
+	"net/http"
+	"net/url"
+	"slices"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/tsosunchia/iNetSpeed-CLI/internal/report"
+)
+
+// Measurement is the InfluxDB measurement, and the Graphite prefix, of every
+// metric.
+const Measurement = "speedtest"
+
+// timeout bounds one push.
+const timeout = 10 * time.Second
+
+var httpClient = &http.Client{Timeout: timeout}
+
+// Metrics is a run flattened into one point: numeric fields, the tags
+// identifying where it was measured and the run's ID.
+type Metrics struct {
+	Time   time.Time
+	ID     string
+	Tags   map[string]string
+	Fields map[string]float64
+}
+
+// FromReport flattens rep. Metrics that were not measured are left out, and
+// so are empty tags; iface is the name of the network interface the test
+// ran over, empty when unknown. The run's --tag pairs come first, so a tag
+// of the same name set here wins.
+func FromReport(rep *report.Report, iface string) Metrics {
+	m := Metrics{Time: rep.Time, ID: rep.ID, Tags: map[string]string{}, Fields: map[string]float64{}}
+	tag := func(k, v string) {
+		if v != "" {
+			m.Tags[k] = v
+		}
+	}
+	for k, v := range rep.Tags {
+		tag(k, v)
+	}
+	tag("endpoint", rep.Server.IP)
+	tag("asn", rep.Client.ASN)
+	tag("interface", iface)
+	if rep.Probe != nil {
+		tag("probe", rep.Probe.ID)
+	}
+	if rep.Simulated {
+		tag("simulated", "true")
+	}
+
+	if v := rep.Best(report.DirDownload); v > 0 {
+		m.Fields["download_mbps"] = v
+	}
+	if v := rep.Best(report.DirUpload); v > 0 {
+		m.Fields["upload_mbps"] = v
+	}
+	if rep.IdleLatency.Samples > 0 {
+		m.Fields["latency_ms"] = rep.IdleLatency.MedianMs
+		m.Fields["jitter_ms"] = rep.IdleLatency.JitterMs
+	}
+	var loaded float64
+	for _, rd := range rep.Rounds {
+		loaded = max(loaded, rd.LoadedLatency.MedianMs)
+	}
+	if loaded > 0 {
+		m.Fields["loaded_latency_ms"] = loaded
+	}
+	if rep.ICMPLatency != nil && rep.ICMPLatency.Samples > 0 {
+		m.Fields["icmp_latency_ms"] = rep.ICMPLatency.MedianMs
+	}
+	if rep.UDP != nil {
+		m.Fields["udp_loss_pct"] = rep.UDP.LossPct
+	}
+	m.Fields["data_used_bytes"] = float64(rep.DataUsedBytes)
+	m.Fields["degraded"] = 0
+	if rep.Degraded {
+		m.Fields["degraded"] = 1
+	}
+	return m
+}
+
+func formatFloat(v float64) string {
+	return strconv.FormatFloat(v, 'f', -1, 64)
+}
+
+var (
+	influxEscaper = strings.NewReplacer(",", `\,`, "=", `\=`, " ", `\ `)
+	stringEscaper = strings.NewReplacer(`\`, `\\`, `"`, `\"`)
+)
+
+// LineProtocol is m as one line of InfluxDB line protocol with a
+// nanosecond timestamp. The run's ID is the string field run_id rather
+// than a tag, which would index a series per run.
+func (m Metrics) LineProtocol() string {
+	var b strings.Builder
+	b.WriteString(Measurement)
+	for _, k := range slices.Sorted(maps.Keys(m.Tags)) {
+		b.WriteString("," + influxEscaper.Replace(k) + "=" + influxEscaper.Replace(m.Tags[k]))
+	}
+	for i, k := range slices.Sorted(maps.Keys(m.Fields)) {
+		if i == 0 {
+			b.WriteByte(' ')
+		} else {
+			b.WriteByte(',')
+		}
+		b.WriteString(influxEscaper.Replace(k) + "=" + formatFloat(m.Fields[k]))
+	}
+	if m.ID != "" {
+		sep := ","
+		if len(m.Fields) == 0 {
+			sep = " "
+		}
+		b.WriteString(sep + `run_id="` + stringEscaper.Replace(m.ID) + `"`)
+	}
+	b.WriteString(" " + strconv.FormatInt(m.Time.UnixNano(), 10) + "\n")
+	return b.String()
+}
+
+var graphiteEscaper = strings.NewReplacer(";", "_", "~", "_", " ", "_", "=", "_")
+
+// Plaintext is m in Graphite's plaintext protocol, one tagged series per
+// field, such as "speedtest.download_mbps;asn=AS4134 512.3 1700000000".
+// Graphite stores numbers only, so the run's ID is left out.
+func (m Metrics) Plaintext() string {
+	var tags strings.Builder
+	for _, k := range slices.Sorted(maps.Keys(m.Tags)) {
+		tags.WriteString(";" + graphiteEscaper.Replace(k) + "=" + graphiteEscaper.Replace(m.Tags[k]))
+	}
+	ts := strconv.FormatInt(m.Time.Unix(), 10)
+	var b strings.Builder
+	for _, k := range slices.Sorted(maps.Keys(m.Fields)) {
+		b.WriteString(Measurement + "." + k + tags.String() + " " + formatFloat(m.Fields[k]) + " " + ts + "\n")
+	}
+	return b.String()
+}
+
+// WriteInflux posts m to an InfluxDB write endpoint: /write?db=… for 1.x,
+// where user:pass@ in the URL is sent as basic auth, or
+// /api/v2/write?org=…&bucket=… for 2.x, which takes token.
+func WriteInflux(ctx context.Context, rawURL, token string, m Metrics) error {
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, rawURL, strings.NewReader(m.LineProtocol()))
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Content-Type", "text/plain; charset=utf-8")
+	if token != "" {
+		req.Header.Set("Authorization", "Token "+token)
+	}
+	resp, err := httpClient.Do(req)
+	if err != nil {
+		// The URL may carry credentials; the cause alone is enough.
+		var uerr *url.Error
+		if errors.As(err, &uerr) {
+			return uerr.Err
+		}
+		return err
+	}
+	defer resp.Body.Close()
+	body, _ := io.ReadAll(io.LimitReader(resp.Body, 512))
+	if resp.StatusCode/100 != 2 {
+		if msg := strings.TrimSpace(string(body)); msg != "" {
+			return fmt.Errorf("HTTP %d: %s", resp.StatusCode, msg)
+		}
+		return fmt.Errorf("HTTP %d", resp.StatusCode)
+	}
+	return nil
+}
+
+// SendGraphite writes m to a Graphite plaintext listener over TCP.
+func SendGraphite(ctx context.Context, addr string, m Metrics) error {
+	ctx, cancel := context.WithTimeout(ctx, timeout)
+	defer cancel()
+	var d net.Dialer
+	conn, err := d.DialContext(ctx, "tcp", addr)
+	if err != nil {
+		return err
+	}
+	defer conn.Close()
+	if deadline, ok := ctx.Deadline(); ok {
+		conn.SetDeadline(deadline)
+	}
+	if _, err := io.Copy(conn, bytes.NewBufferString(m.Plaintext())); err != nil {
+		return err
+	}
+	return conn.Close()
+}
+
+// Interface returns the name of the interface the system routes to ip
+// through, or "" when that cannot be told. No packet is sent.
+func Interface(ip string) string {
+	if net.ParseIP(ip) == nil {
+		return ""
+	}
+	c, err := net.Dial("udp", net.JoinHostPort(ip, "443"))
+	if err != nil {
+		return ""
+	}
+	local := c.LocalAddr().(*net.UDPAddr).IP
+	c.Close()
+	ifaces, err := net.Interfaces()
+	if err != nil {
+		return ""
+	}
+	for _, ifc := range ifaces {
+		addrs, err := ifc.Addrs()
+		if err != nil {
+			continue
+		}
+		for _, a := range addrs {
+			if n, ok := a.(*net.IPNet); ok && n.IP.Equal(local) {
+				return ifc.Name
+			}
+		}
+	}
+	return ""
+}
@@ -0,0 +1,89 @@
+package sink
+
+import (
+	"maps"
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+)
+
+// help describes each field for the OpenMetrics HELP lines.
+var help = map[string]string{
+	"download_mbps":     "Best download round in Mbps.",
+	"upload_mbps":       "Best upload round in Mbps.",
+	"latency_ms":        "Median idle latency in milliseconds.",
+	"jitter_ms":         "Idle latency jitter in milliseconds.",
+	"loaded_latency_ms": "Highest median latency under load of any round, in milliseconds.",
+	"icmp_latency_ms":   "Median ICMP echo latency in milliseconds.",
+	"udp_loss_pct":      "UDP echo packet loss in percent.",
+	"data_used_bytes":   "Data transferred by the run in bytes.",
+	"degraded":          "1 when an auxiliary step of the run failed.",
+}
+
+var labelEscaper = strings.NewReplacer(`\`, `\\`, `"`, `\"`, "\n", `\n`)
+
+// OpenMetrics is m in the OpenMetrics text format, one gauge per field,
+// such as `speedtest_download_mbps{asn="AS4134"} 512.3`. The samples carry
+// no timestamp, which node_exporter's textfile collector rejects; the run's
+// time is the speedtest_last_run_timestamp_seconds gauge instead, and its ID
+// the run_id label of speedtest_run_info.
+func (m Metrics) OpenMetrics() string {
+	var labels []string
+	for _, k := range slices.Sorted(maps.Keys(m.Tags)) {
+		labels = append(labels, k+`="`+labelEscaper.Replace(m.Tags[k])+`"`)
+	}
+	var b strings.Builder
+	gauge := func(name, desc string, labels []string, value string) {
+		name = Measurement + "_" + name
+		b.WriteString("# TYPE " + name + " gauge\n")
+		if desc != "" {
+			b.WriteString("# HELP " + name + " " + desc + "\n")
+		}
+		b.WriteString(name)
+		if len(labels) > 0 {
+			b.WriteString("{" + strings.Join(labels, ",") + "}")
+		}
+		b.WriteString(" " + value + "\n")
+	}
+	for _, k := range slices.Sorted(maps.Keys(m.Fields)) {
+		gauge(k, help[k], labels, formatFloat(m.Fields[k]))
+	}
+	gauge("last_run_timestamp_seconds", "Unix time the run started.", labels, formatFloat(float64(m.Time.UnixNano())/1e9))
+	if m.ID != "" {
+		gauge("run_info", "ID of the last run, in the run_id label.", append(labels, `run_id="`+labelEscaper.Replace(m.ID)+`"`), "1")
+	}
+	b.WriteString("# EOF\n")
+	return b.String()
+}
+
+// WriteTextfile writes m in the OpenMetrics format to path, for
+// node_exporter's textfile collector. The file is replaced in one rename so
+// the collector never reads it half written; the temporary file does not
+// end in .prom, which the collector would pick up.
+func WriteTextfile(path string, m Metrics) error {
+	dir, base := filepath.Split(path)
+	if dir == "" {
+		dir = "."
+	}
+	f, err := os.CreateTemp(dir, "."+base+".*.tmp")
+	if err != nil {
+		return err
+	}
+	tmp := f.Name()
+	_, err = f.WriteString(m.OpenMetrics())
+	if err == nil {
+		// node_exporter usually runs as another user.
+		err = f.Chmod(0o644)
+	}
+	if cerr := f.Close(); err == nil {
+		err = cerr
+	}
+	if err == nil {
+		err = os.Rename(tmp, path)
+	}
+	if err != nil {
+		os.Remove(tmp)
+	}
+	return err
+}
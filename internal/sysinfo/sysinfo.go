@@ -0,0 +1,217 @@
+// Package sysinfo describes the client's end of the path: the interface the
+// test leaves through, the speed its link negotiated, the Wi-Fi signal and
+// PHY rate when it is wireless, and the DNS servers. A 300 Mbps result over
+// a 144 Mbps Wi-Fi link then explains itself.
+package sysinfo
+
+import (
+	"bufio"
+	"errors"
+	"io"
+	"net"
+	"regexp"
+	"strconv"
+	"strings"
+)
+
+// ErrUnsupported is returned where the link cannot be described.
+var ErrUnsupported = errors.New("reading link details is not supported on this platform")
+
+// Link is what the OS tells about an interface's link. Zero fields are
+// unknown.
+type Link struct {
+	Mbps int   // negotiated speed of a wired link
+	WiFi *WiFi // set for a wireless interface
+}
+
+// WiFi is the state of a wireless link.
+type WiFi struct {
+	SSID     string
+	RSSIdBm  int
+	NoisedBm int
+	TxMbps   float64 // PHY rate of the last transmission
+	Channel  string
+}
+
+// ReadLink describes the link of the interface called name.
+func ReadLink(name string) (Link, error) {
+	return readLink(name)
+}
+
+// DNS returns the system's DNS servers.
+func DNS() ([]string, error) {
+	return dnsServers()
+}
+
+// parseResolvConf returns the nameserver addresses of a resolv.conf.
+func parseResolvConf(r io.Reader) []string {
+	var out []string
+	sc := bufio.NewScanner(r)
+	for sc.Scan() {
+		f := strings.Fields(sc.Text())
+		if len(f) >= 2 && f[0] == "nameserver" && net.ParseIP(strings.Split(f[1], "%")[0]) != nil {
+			out = append(out, f[1])
+		}
+	}
+	return out
+}
+
+// stubResolver reports whether servers is only systemd-resolved's local
+// stub, behind which the real servers hide.
+func stubResolver(servers []string) bool {
+	return len(servers) == 1 && servers[0] == "127.0.0.53"
+}
+
+// parseIwLink reads the output of Linux's `iw dev NAME link`:
+//
+//	Connected to aa:bb:cc:dd:ee:ff (on wlan0)
+//		SSID: home
+//		freq: 5180
+//		signal: -52 dBm
+//		tx bitrate: 866.7 MBit/s VHT-MCS 9 80MHz short GI VHT-NSS 2
+//
+// It returns nil when the interface is not connected.
+func parseIwLink(out string) *WiFi {
+	if !strings.HasPrefix(strings.TrimSpace(out), "Connected") {
+		return nil
+	}
+	w := &WiFi{}
+	for _, line := range strings.Split(out, "\n") {
+		k, v, ok := strings.Cut(strings.TrimSpace(line), ":")
+		if !ok {
+			continue
+		}
+		v = strings.TrimSpace(v)
+		switch k {
+		case "SSID":
+			w.SSID = v
+		case "freq":
+			w.Channel = channel(v)
+		case "signal":
+			w.RSSIdBm = leadingInt(v)
+		case "tx bitrate":
+			w.TxMbps = leadingFloat(v)
+		}
+	}
+	return w
+}
+
+// channel converts a frequency in MHz to its Wi-Fi channel, or returns it
+// as is when it lies outside the 2.4, 5 and 6 GHz bands.
+func channel(freq string) string {
+	mhz := int(leadingFloat(freq))
+	switch {
+	case mhz == 2484:
+		return "14"
+	case mhz >= 2412 && mhz < 2484:
+		return strconv.Itoa((mhz - 2407) / 5)
+	case mhz >= 5160 && mhz <= 5885:
+		return strconv.Itoa((mhz - 5000) / 5)
+	case mhz >= 5955 && mhz <= 7115:
+		return strconv.Itoa((mhz - 5950) / 5)
+	}
+	return freq
+}
+
+// parseAirport reads the output of macOS's `airport -I`:
+//
+//	 agrCtlRSSI: -52
+//	agrCtlNoise: -91
+//	 lastTxRate: 867
+//	       SSID: home
+//	    channel: 36,80
+//
+// It returns nil when Wi-Fi is off or not associated.
+func parseAirport(out string) *WiFi {
+	w := &WiFi{}
+	for _, line := range strings.Split(out, "\n") {
+		k, v, ok := strings.Cut(strings.TrimSpace(line), ":")
+		if !ok {
+			continue
+		}
+		v = strings.TrimSpace(v)
+		switch k {
+		case "agrCtlRSSI":
+			w.RSSIdBm = leadingInt(v)
+		case "agrCtlNoise":
+			w.NoisedBm = leadingInt(v)
+		case "lastTxRate":
+			w.TxMbps = leadingFloat(v)
+		case "SSID":
+			w.SSID = v
+		case "channel":
+			w.Channel, _, _ = strings.Cut(v, ",")
+		}
+	}
+	if w.RSSIdBm == 0 && w.TxMbps == 0 {
+		return nil
+	}
+	return w
+}
+
+// wifiDevice reads the device of the Wi-Fi port off the output of macOS's
+// `networksetup -listallhardwareports`:
+//
+//	Hardware Port: Wi-Fi
+//	Device: en0
+//	Ethernet Address: a4:83:e7:00:00:01
+func wifiDevice(out string) string {
+	port := ""
+	for _, line := range strings.Split(out, "\n") {
+		k, v, ok := strings.Cut(line, ":")
+		if !ok {
+			continue
+		}
+		switch v = strings.TrimSpace(v); k {
+		case "Hardware Port":
+			port = v
+		case "Device":
+			if port == "Wi-Fi" || port == "AirPort" {
+				return v
+			}
+		}
+	}
+	return ""
+}
+
+var mediaRe = regexp.MustCompile(`(?i)\((\d+(?:\.\d+)?)(g|m)?base`)
+
+// parseIfconfigMedia reads the speed off the media line of a BSD
+// `ifconfig NAME`, such as "media: autoselect (1000baseT <full-duplex>)" or
+// "(10GbaseT <full-duplex>)", in Mbps; 0 when there is none.
+func parseIfconfigMedia(out string) int {
+	for _, line := range strings.Split(out, "\n") {
+		line = strings.TrimSpace(line)
+		if !strings.HasPrefix(line, "media:") {
+			continue
+		}
+		m := mediaRe.FindStringSubmatch(line)
+		if m == nil {
+			return 0
+		}
+		v, _ := strconv.ParseFloat(m[1], 64)
+		if strings.EqualFold(m[2], "g") {
+			v *= 1000
+		}
+		return int(v)
+	}
+	return 0
+}
+
+func leadingInt(s string) int {
+	f := strings.Fields(s)
+	if len(f) == 0 {
+		return 0
+	}
+	n, _ := strconv.Atoi(f[0])
+	return n
+}
+
+func leadingFloat(s string) float64 {
+	f := strings.Fields(s)
+	if len(f) == 0 {
+		return 0
+	}
+	v, _ := strconv.ParseFloat(f[0], 64)
+	return v
+}
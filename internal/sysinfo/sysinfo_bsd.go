@@ -0,0 +1,45 @@
+//go:build darwin || freebsd || netbsd || openbsd || dragonfly
+
+package sysinfo
+
+import (
+	"os"
+	"os/exec"
+	"runtime"
+)
+
+// airport is the macOS Wi-Fi tool; `airport -I` describes the association.
+const airport = "/System/Library/PrivateFrameworks/Apple80211.framework/Versions/Current/Resources/airport"
+
+func readLink(name string) (Link, error) {
+	out, err := exec.Command("ifconfig", name).Output()
+	if err != nil {
+		return Link{}, err
+	}
+	l := Link{Mbps: parseIfconfigMedia(string(out))}
+	if runtime.GOOS != "darwin" {
+		return l, nil
+	}
+	// airport describes the Wi-Fi interface whatever it is called; ask it
+	// only when that is name.
+	if out, err := exec.Command("networksetup", "-listallhardwareports").Output(); err == nil && wifiDevice(string(out)) == name {
+		l.WiFi = &WiFi{}
+		if out, err := exec.Command(airport, "-I").Output(); err == nil {
+			if w := parseAirport(string(out)); w != nil {
+				l.WiFi = w
+			}
+		}
+	}
+	return l, nil
+}
+
+// dnsServers reads /etc/resolv.conf, which macOS keeps in step with the
+// resolver of the primary service.
+func dnsServers() ([]string, error) {
+	f, err := os.Open("/etc/resolv.conf")
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+	return parseResolvConf(f), nil
+}
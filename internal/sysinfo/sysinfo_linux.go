@@ -0,0 +1,51 @@
+package sysinfo
+
+import (
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strconv"
+	"strings"
+)
+
+func readLink(name string) (Link, error) {
+	dir := filepath.Join("/sys/class/net", name)
+	if _, err := os.Stat(dir); err != nil {
+		return Link{}, err
+	}
+	var l Link
+	// Wireless interfaces, and wired ones without a carrier, report -1 or
+	// fail to read.
+	if b, err := os.ReadFile(filepath.Join(dir, "speed")); err == nil {
+		if v, err := strconv.Atoi(strings.TrimSpace(string(b))); err == nil && v > 0 {
+			l.Mbps = v
+		}
+	}
+	if _, err := os.Stat(filepath.Join(dir, "wireless")); err == nil {
+		l.WiFi = &WiFi{}
+		if out, err := exec.Command("iw", "dev", name, "link").Output(); err == nil {
+			if w := parseIwLink(string(out)); w != nil {
+				l.WiFi = w
+			}
+		}
+	}
+	return l, nil
+}
+
+func dnsServers() ([]string, error) {
+	f, err := os.Open("/etc/resolv.conf")
+	if err != nil {
+		return nil, err
+	}
+	servers := parseResolvConf(f)
+	f.Close()
+	if stubResolver(servers) {
+		if f, err := os.Open("/run/systemd/resolve/resolv.conf"); err == nil {
+			defer f.Close()
+			if up := parseResolvConf(f); len(up) > 0 {
+				return up, nil
+			}
+		}
+	}
+	return servers, nil
+}
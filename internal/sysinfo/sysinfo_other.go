@@ -0,0 +1,7 @@
+//go:build !linux && !darwin && !freebsd && !netbsd && !openbsd && !dragonfly
+
+package sysinfo
+
+func readLink(string) (Link, error) { return Link{}, ErrUnsupported }
+
+func dnsServers() ([]string, error) { return nil, ErrUnsupported }
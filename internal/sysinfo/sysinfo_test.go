@@ -0,0 +1,96 @@
+package sysinfo
+
+import (
+	"slices"
+	"strings"
+	"testing"
+)
+
+func TestParseResolvConf(t *testing.T) {
+	const conf = `# Generated by NetworkManager
+search lan
+nameserver 192.168.1.1
+nameserver fe80::1%en0
+nameserver not-an-ip
+options edns0
+`
+	if got := parseResolvConf(strings.NewReader(conf)); !slices.Equal(got, []string{"192.168.1.1", "fe80::1%en0"}) {
+		t.Errorf("servers = %q", got)
+	}
+	if !stubResolver([]string{"127.0.0.53"}) || stubResolver([]string{"127.0.0.53", "1.1.1.1"}) {
+		t.Error("stub resolver misdetected")
+	}
+}
+
+func TestParseIwLink(t *testing.T) {
+	const out = `Connected to aa:bb:cc:dd:ee:ff (on wlan0)
+	SSID: home
+	freq: 5180
+	RX: 123456 bytes (789 packets)
+	signal: -52 dBm
+	rx bitrate: 780.0 MBit/s VHT-MCS 8 80MHz short GI VHT-NSS 2
+	tx bitrate: 866.7 MBit/s VHT-MCS 9 80MHz short GI VHT-NSS 2
+`
+	w := parseIwLink(out)
+	if w == nil || *w != (WiFi{SSID: "home", RSSIdBm: -52, TxMbps: 866.7, Channel: "36"}) {
+		t.Errorf("link = %+v", w)
+	}
+	if w := parseIwLink("Not connected.\n"); w != nil {
+		t.Errorf("disconnected link = %+v", w)
+	}
+}
+
+func TestChannel(t *testing.T) {
+	for freq, want := range map[string]string{"2412": "1", "2437": "6", "2484": "14", "5745": "149", "5955": "1", "60480": "60480"} {
+		if got := channel(freq); got != want {
+			t.Errorf("channel(%s) = %s, want %s", freq, got, want)
+		}
+	}
+}
+
+func TestParseAirport(t *testing.T) {
+	const out = `     agrCtlRSSI: -61
+     agrExtRSSI: 0
+    agrCtlNoise: -92
+          state: running
+     lastTxRate: 144
+        maxRate: 144
+           SSID: cafe
+        channel: 11
+`
+	w := parseAirport(out)
+	if w == nil || *w != (WiFi{SSID: "cafe", RSSIdBm: -61, NoisedBm: -92, TxMbps: 144, Channel: "11"}) {
+		t.Errorf("link = %+v", w)
+	}
+	if w := parseAirport("AirPort: Off\n"); w != nil {
+		t.Errorf("Wi-Fi off = %+v", w)
+	}
+}
+
+func TestWifiDevice(t *testing.T) {
+	const out = `
+Hardware Port: Ethernet
+Device: en1
+Ethernet Address: a4:83:e7:00:00:02
+
+Hardware Port: Wi-Fi
+Device: en0
+Ethernet Address: a4:83:e7:00:00:01
+`
+	if got := wifiDevice(out); got != "en0" {
+		t.Errorf("Wi-Fi device = %q", got)
+	}
+}
+
+func TestParseIfconfigMedia(t *testing.T) {
+	for out, want := range map[string]int{
+		"en1: flags=8863<UP>\n\tmedia: autoselect (1000baseT <full-duplex>)\n\tstatus: active\n": 1000,
+		"\tmedia: autoselect (10GbaseT <full-duplex,flow-control>)\n":                            10000,
+		"\tmedia: autoselect (2500Base-T <full-duplex>)\n":                                       2500,
+		"\tmedia: autoselect\n\tstatus: active\n":                                                0,
+	} {
+		if got := parseIfconfigMedia(out); got != want {
+			t.Errorf("parseIfconfigMedia(%q) = %d, want %d", out, got, want)
+		}
+	}
+}
@@ -0,0 +1,185 @@
+// Package tcpinfo reads kernel TCP statistics (TCP_INFO on Linux,
+// TCP_CONNECTION_INFO on macOS) from live connections and tracks the
+// connections dialed by an HTTP client so each test round can report them.
+package tcpinfo
+
+import (
+	"errors"
+	"io"
+	"net"
+	"sort"
+	"sync"
+	"sync/atomic"
+	"syscall"
+	"time"
+)
+
+// ErrUnsupported is returned on platforms without a TCP info socket option.
+var ErrUnsupported = errors.New("tcp info not supported on this platform")
+
+// Info is a platform-neutral subset of the kernel's per-connection state.
+// Fields the platform does not expose are left zero.
+type Info struct {
+	RTT          time.Duration // smoothed round-trip time
+	RTTVar       time.Duration
+	MinRTT       time.Duration
+	Retransmits  uint64 // total retransmitted segments
+	CwndBytes    uint64 // congestion window
+	DeliveryRate uint64 // bytes per second, as estimated by the kernel
+	// MSS is the segment size negotiated with the peer, before per-segment
+	// TCP options; a clamped MSS reveals a path MTU below 1500.
+	MSS uint32
+}
+
+// Get queries c, which must be (or wrap, via a Tracker) a *net.TCPConn.
+func Get(c net.Conn) (Info, error) {
+	if tc, ok := c.(*Conn); ok {
+		c = tc.Conn
+	}
+	sc, ok := c.(syscall.Conn)
+	if !ok {
+		return Info{}, ErrUnsupported
+	}
+	raw, err := sc.SyscallConn()
+	if err != nil {
+		return Info{}, err
+	}
+	var info Info
+	var gerr error
+	if err := raw.Control(func(fd uintptr) { info, gerr = getInfo(fd) }); err != nil {
+		return Info{}, err
+	}
+	return info, gerr
+}
+
+// Flow is one connection's traffic and kernel statistics for a round.
+type Flow struct {
+	Remote       string
+	BytesRead    int64
+	BytesWritten int64
+	Info         Info
+	Err          error
+}
+
+// Conn is a connection registered with a Tracker.
+type Conn struct {
+	net.Conn
+	t       *Tracker
+	read    atomic.Int64
+	written atomic.Int64
+
+	closeOnce sync.Once
+	final     Info
+	finalErr  error
+}
+
+func (c *Conn) Read(p []byte) (int, error) {
+	n, err := c.Conn.Read(p)
+	c.read.Add(int64(n))
+	return n, err
+}
+
+func (c *Conn) Write(p []byte) (int, error) {
+	n, err := c.Conn.Write(p)
+	c.written.Add(int64(n))
+	return n, err
+}
+
+// ReadFrom keeps the connection's own io.ReaderFrom, such as the sendfile
+// path of *net.TCPConn, reachable through the wrapper.
+func (c *Conn) ReadFrom(r io.Reader) (int64, error) {
+	var n int64
+	var err error
+	if rf, ok := c.Conn.(io.ReaderFrom); ok {
+		n, err = rf.ReadFrom(r)
+	} else {
+		n, err = io.Copy(c.Conn, r)
+	}
+	c.written.Add(n)
+	return n, err
+}
+
+// Close snapshots the statistics before the socket goes away, so flows that
+// end mid-round are still reported.
+func (c *Conn) Close() error {
+	c.closeOnce.Do(func() {
+		c.final, c.finalErr = Get(c.Conn)
+		c.t.retire(c)
+	})
+	return c.Conn.Close()
+}
+
+// Tracker records the connections of one HTTP client. Begin and Collect
+// bracket a round; rounds must not overlap.
+type Tracker struct {
+	mu     sync.Mutex
+	live   map[*Conn]struct{}
+	closed []*Conn
+}
+
+func NewTracker() *Tracker {
+	return &Tracker{live: map[*Conn]struct{}{}}
+}
+
+// Wrap registers c and returns the tracked connection.
+func (t *Tracker) Wrap(c net.Conn) net.Conn {
+	tc := &Conn{Conn: c, t: t}
+	t.mu.Lock()
+	t.live[tc] = struct{}{}
+	t.mu.Unlock()
+	return tc
+}
+
+func (t *Tracker) retire(c *Conn) {
+	t.mu.Lock()
+	delete(t.live, c)
+	t.closed = append(t.closed, c)
+	t.mu.Unlock()
+}
+
+// Begin starts a round: byte counters of live connections are reset and
+// connections closed earlier are forgotten.
+func (t *Tracker) Begin() {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	t.closed = nil
+	for c := range t.live {
+		c.read.Store(0)
+		c.written.Store(0)
+	}
+}
+
+// Collect returns every connection that carried data since Begin, querying
+// live sockets now and using the close-time snapshot for the rest.
+func (t *Tracker) Collect() []Flow {
+	t.mu.Lock()
+	conns := make([]*Conn, 0, len(t.live)+len(t.closed))
+	live := make(map[*Conn]bool, len(t.live))
+	for c := range t.live {
+		conns = append(conns, c)
+		live[c] = true
+	}
+	conns = append(conns, t.closed...)
+	t.mu.Unlock()
+
+	var flows []Flow
+	for _, c := range conns {
+		f := Flow{BytesRead: c.read.Load(), BytesWritten: c.written.Load()}
+		if f.BytesRead == 0 && f.BytesWritten == 0 {
+			continue
+		}
+		if addr := c.RemoteAddr(); addr != nil {
+			f.Remote = addr.String()
+		}
+		if live[c] {
+			f.Info, f.Err = Get(c.Conn)
+		} else {
+			f.Info, f.Err = c.final, c.finalErr
+		}
+		flows = append(flows, f)
+	}
+	sort.Slice(flows, func(i, j int) bool {
+		return flows[i].BytesRead+flows[i].BytesWritten > flows[j].BytesRead+flows[j].BytesWritten
+	})
+	return flows
+}
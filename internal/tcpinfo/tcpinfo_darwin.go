@@ -0,0 +1,50 @@
+package tcpinfo
+
+import (
+	"syscall"
+	"time"
+	"unsafe"
+)
+
+// tcpConnectionInfo is TCP_CONNECTION_INFO from <netinet/tcp.h>.
+const tcpConnectionInfo = 0x106
+
+// darwinTCPInfo mirrors struct tcp_connection_info from <netinet/tcp.h>.
+type darwinTCPInfo struct {
+	State, SndWScale, RcvWScale, _ uint8
+
+	Options, Flags, RTO, MaxSeg, SndSSThresh, SndCwnd uint32
+	SndWnd, SndSBBytes, RcvWnd, RTTCur, SRTT, RTTVar  uint32
+	TFOFlags                                          uint32
+
+	TxPackets, TxBytes, TxRetransmitBytes uint64
+	RxPackets, RxBytes, RxOutOfOrderBytes uint64
+	TxRetransmitPackets                   uint64
+}
+
+// optTimestamps is TCPCI_OPT_TIMESTAMPS: MaxSeg then excludes the 12-byte
+// timestamp option.
+const optTimestamps = 0x1
+
+func getInfo(fd uintptr) (Info, error) {
+	var ti darwinTCPInfo
+	size := uint32(unsafe.Sizeof(ti))
+	_, _, errno := syscall.Syscall6(syscall.SYS_GETSOCKOPT, fd,
+		syscall.IPPROTO_TCP, tcpConnectionInfo,
+		uintptr(unsafe.Pointer(&ti)), uintptr(unsafe.Pointer(&size)), 0)
+	if errno != 0 {
+		return Info{}, errno
+	}
+	mss := ti.MaxSeg
+	if ti.Options&optTimestamps != 0 {
+		mss += 12
+	}
+	// macOS reports no delivery-rate estimate or minimum RTT.
+	return Info{
+		RTT:         time.Duration(ti.SRTT) * time.Millisecond,
+		RTTVar:      time.Duration(ti.RTTVar) * time.Millisecond,
+		Retransmits: ti.TxRetransmitPackets,
+		CwndBytes:   uint64(ti.SndCwnd),
+		MSS:         mss,
+	}, nil
+}
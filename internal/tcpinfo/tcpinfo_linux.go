@@ -0,0 +1,52 @@
+package tcpinfo
+
+import (
+	"syscall"
+	"time"
+	"unsafe"
+)
+
+// linuxTCPInfo mirrors struct tcp_info from <linux/tcp.h> up to
+// tcpi_delivery_rate (Linux 4.9+). Older kernels fill a prefix only.
+type linuxTCPInfo struct {
+	State, CAState, Retransmits, Probes, Backoff, Options, WScale, Flags uint8
+
+	RTO, ATO, SndMSS, RcvMSS                             uint32
+	Unacked, Sacked, Lost, Retrans, Fackets              uint32
+	LastDataSent, LastAckSent, LastDataRecv, LastAckRecv uint32
+	PMTU, RcvSSThresh, RTT, RTTVar, SndSSThresh, SndCwnd uint32
+	AdvMSS, Reordering, RcvRTT, RcvSpace, TotalRetrans   uint32
+
+	PacingRate, MaxPacingRate, BytesAcked, BytesReceived           uint64
+	SegsOut, SegsIn, NotsentBytes, MinRTT, DataSegsIn, DataSegsOut uint32
+
+	DeliveryRate uint64
+}
+
+// optTimestamps is TCPI_OPT_TIMESTAMPS: the kernel's MSS then excludes the
+// 12-byte timestamp option.
+const optTimestamps = 1
+
+func getInfo(fd uintptr) (Info, error) {
+	var ti linuxTCPInfo
+	size := uint32(unsafe.Sizeof(ti))
+	_, _, errno := syscall.Syscall6(syscall.SYS_GETSOCKOPT, fd,
+		syscall.IPPROTO_TCP, syscall.TCP_INFO,
+		uintptr(unsafe.Pointer(&ti)), uintptr(unsafe.Pointer(&size)), 0)
+	if errno != 0 {
+		return Info{}, errno
+	}
+	mss := ti.SndMSS
+	if ti.Options&optTimestamps != 0 {
+		mss += 12
+	}
+	return Info{
+		RTT:          time.Duration(ti.RTT) * time.Microsecond,
+		RTTVar:       time.Duration(ti.RTTVar) * time.Microsecond,
+		MinRTT:       time.Duration(ti.MinRTT) * time.Microsecond,
+		Retransmits:  uint64(ti.TotalRetrans),
+		CwndBytes:    uint64(ti.SndCwnd) * uint64(ti.SndMSS),
+		DeliveryRate: ti.DeliveryRate,
+		MSS:          mss,
+	}, nil
+}
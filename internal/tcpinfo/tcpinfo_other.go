@@ -0,0 +1,7 @@
+//go:build !linux && !darwin
+
+package tcpinfo
+
+func getInfo(uintptr) (Info, error) {
+	return Info{}, ErrUnsupported
+}
@@ -0,0 +1,146 @@
+package tcpinfo
+
+import (
+	"errors"
+	"io"
+	"net"
+	"runtime"
+	"testing"
+)
+
+// pipe returns a tracked client connection to a local server that echoes
+// nothing and discards whatever it reads.
+func pipe(t *testing.T, tr *Tracker) net.Conn {
+	t.Helper()
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { ln.Close() })
+	go func() {
+		c, err := ln.Accept()
+		if err != nil {
+			return
+		}
+		io.Copy(io.Discard, c)
+		c.Close()
+	}()
+	c, err := net.Dial("tcp", ln.Addr().String())
+	if err != nil {
+		t.Fatal(err)
+	}
+	return tr.Wrap(c)
+}
+
+func TestGet(t *testing.T) {
+	c := pipe(t, NewTracker())
+	defer c.Close()
+	if _, err := c.Write(make([]byte, 64*1024)); err != nil {
+		t.Fatal(err)
+	}
+	info, err := Get(c)
+	if runtime.GOOS != "linux" && runtime.GOOS != "darwin" {
+		if !errors.Is(err, ErrUnsupported) {
+			t.Fatalf("err = %v, want ErrUnsupported", err)
+		}
+		return
+	}
+	if err != nil {
+		t.Fatal(err)
+	}
+	if info.CwndBytes == 0 {
+		t.Error("cwnd = 0")
+	}
+	if info.MSS < 536 {
+		t.Errorf("MSS = %d", info.MSS)
+	}
+}
+
+func TestTrackerCollect(t *testing.T) {
+	tr := NewTracker()
+	idle := pipe(t, tr)
+	defer idle.Close()
+	busy := pipe(t, tr)
+	closed := pipe(t, tr)
+
+	if _, err := closed.Write(make([]byte, 1000)); err != nil {
+		t.Fatal(err)
+	}
+	closed.Close()
+
+	tr.Begin()
+	if _, err := busy.Write(make([]byte, 2000)); err != nil {
+		t.Fatal(err)
+	}
+	flows := tr.Collect()
+	if len(flows) != 1 {
+		t.Fatalf("got %d flows, want only the busy one", len(flows))
+	}
+	if flows[0].BytesWritten != 2000 || flows[0].Remote == "" {
+		t.Errorf("flow = %+v", flows[0])
+	}
+
+	busy.Close()
+	flows = tr.Collect()
+	if len(flows) != 1 || flows[0].BytesWritten != 2000 {
+		t.Fatalf("closed flow not kept until next Begin: %+v", flows)
+	}
+	tr.Begin()
+	if flows := tr.Collect(); len(flows) != 0 {
+		t.Errorf("Begin should forget closed flows, got %+v", flows)
+	}
+}
+
+func TestConnReadFrom(t *testing.T) {
+	tr := NewTracker()
+	c := pipe(t, tr)
+	defer c.Close()
+	rf, ok := c.(io.ReaderFrom)
+	if !ok {
+		t.Fatal("tracked connection hides io.ReaderFrom")
+	}
+	tr.Begin()
+	if n, err := rf.ReadFrom(io.LimitReader(zeros{}, 3000)); n != 3000 || err != nil {
+		t.Fatalf("ReadFrom = %d, %v", n, err)
+	}
+	if flows := tr.Collect(); len(flows) != 1 || flows[0].BytesWritten != 3000 {
+		t.Errorf("flows = %+v", flows)
+	}
+}
+
+type zeros struct{}
+
+func (zeros) Read(p []byte) (int, error) { clear(p); return len(p), nil }
+
+func TestWindowLimits(t *testing.T) {
+	l, err := WindowLimits()
+	switch runtime.GOOS {
+	case "linux", "darwin":
+		if err != nil {
+			t.Skipf("limits not readable here: %v", err)
+		}
+		if l.RecvMax <= 0 || l.SendMax <= 0 || l.RecvSource == "" || l.SendSource == "" {
+			t.Errorf("limits = %+v", l)
+		}
+	default:
+		if !errors.Is(err, ErrUnsupported) {
+			t.Errorf("err = %v, want ErrUnsupported", err)
+		}
+	}
+}
+
+func TestParseLimits(t *testing.T) {
+	if v, err := maxField("4096\t131072\t6291456\n"); err != nil || v != 6291456 {
+		t.Errorf("maxField = %d, %v", v, err)
+	}
+	if v, err := maxField("1\n"); err != nil || v != 1 {
+		t.Errorf("maxField(single) = %d, %v", v, err)
+	}
+	if _, err := maxField(" \n"); err == nil {
+		t.Error("maxField: expected error for empty value")
+	}
+	m := parseSysctl("net.inet.tcp.autorcvbufmax: 4194304\nkern.ipc.maxsockbuf: 8388608\n\n")
+	if m["net.inet.tcp.autorcvbufmax"] != "4194304" || m["kern.ipc.maxsockbuf"] != "8388608" || len(m) != 2 {
+		t.Errorf("parseSysctl = %v", m)
+	}
+}
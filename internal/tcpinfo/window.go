@@ -0,0 +1,49 @@
+package tcpinfo
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+)
+
+// Limits are the kernel's upper bounds on a TCP connection's buffers, which
+// cap how much data can be in flight per connection: throughput can not
+// exceed the window divided by the round-trip time.
+type Limits struct {
+	RecvMax int64 // largest receive buffer autotuning may reach, bytes
+	SendMax int64 // largest send buffer autotuning may reach, bytes
+	// Sources names the settings the limits came from, for advice.
+	RecvSource string
+	SendSource string
+}
+
+// noScaleWindow is the largest window without RFC 7323 window scaling.
+const noScaleWindow = 65535
+
+// WindowLimits reads the TCP buffer limits of this host. It returns
+// ErrUnsupported where they are not exposed.
+func WindowLimits() (Limits, error) {
+	return windowLimits()
+}
+
+// maxField returns the last whitespace-separated number of s, as in
+// /proc/sys/net/ipv4/tcp_rmem ("min default max").
+func maxField(s string) (int64, error) {
+	f := strings.Fields(s)
+	if len(f) == 0 {
+		return 0, fmt.Errorf("empty value")
+	}
+	return strconv.ParseInt(f[len(f)-1], 10, 64)
+}
+
+// parseSysctl reads `sysctl name: value` lines into a map.
+func parseSysctl(out string) map[string]string {
+	m := map[string]string{}
+	for _, line := range strings.Split(out, "\n") {
+		k, v, ok := strings.Cut(line, ":")
+		if ok {
+			m[strings.TrimSpace(k)] = strings.TrimSpace(v)
+		}
+	}
+	return m
+}
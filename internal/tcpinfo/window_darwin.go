@@ -0,0 +1,54 @@
+package tcpinfo
+
+import (
+	"fmt"
+	"os/exec"
+	"strconv"
+)
+
+// sysctlOutput runs sysctl(8); tests replace it.
+var sysctlOutput = func(names ...string) (string, error) {
+	out, err := exec.Command("sysctl", names...).Output()
+	return string(out), err
+}
+
+func windowLimits() (Limits, error) {
+	const (
+		recv    = "net.inet.tcp.autorcvbufmax"
+		send    = "net.inet.tcp.autosndbufmax"
+		sockbuf = "kern.ipc.maxsockbuf"
+		rfc1323 = "net.inet.tcp.rfc1323"
+	)
+	out, err := sysctlOutput(recv, send, sockbuf, rfc1323)
+	if err != nil && out == "" {
+		return Limits{}, err
+	}
+	m := parseSysctl(out)
+	num := func(name string) (int64, error) {
+		v, ok := m[name]
+		if !ok {
+			return 0, fmt.Errorf("sysctl %s unavailable", name)
+		}
+		return strconv.ParseInt(v, 10, 64)
+	}
+	l := Limits{RecvSource: recv, SendSource: send}
+	if l.RecvMax, err = num(recv); err != nil {
+		return Limits{}, err
+	}
+	if l.SendMax, err = num(send); err != nil {
+		return Limits{}, err
+	}
+	// Autotuned buffers are still bounded by the socket buffer ceiling.
+	if max, err := num(sockbuf); err == nil {
+		if max < l.RecvMax {
+			l.RecvMax, l.RecvSource = max, sockbuf
+		}
+		if max < l.SendMax {
+			l.SendMax, l.SendSource = max, sockbuf
+		}
+	}
+	if v, err := num(rfc1323); err == nil && v == 0 {
+		l.RecvMax, l.RecvSource = min(l.RecvMax, noScaleWindow), rfc1323
+	}
+	return l, nil
+}
@@ -0,0 +1,33 @@
+package tcpinfo
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+// procSys is where Linux exposes sysctls; tests point it elsewhere.
+var procSys = "/proc/sys"
+
+func windowLimits() (Limits, error) {
+	read := func(name string) (int64, error) {
+		b, err := os.ReadFile(filepath.Join(procSys, strings.ReplaceAll(name, ".", "/")))
+		if err != nil {
+			return 0, err
+		}
+		return maxField(string(b))
+	}
+	l := Limits{RecvSource: "net.ipv4.tcp_rmem", SendSource: "net.ipv4.tcp_wmem"}
+	var err error
+	if l.RecvMax, err = read(l.RecvSource); err != nil {
+		return Limits{}, err
+	}
+	if l.SendMax, err = read(l.SendSource); err != nil {
+		return Limits{}, err
+	}
+	if scaling, err := read("net.ipv4.tcp_window_scaling"); err == nil && scaling == 0 {
+		l.RecvMax = min(l.RecvMax, noScaleWindow)
+		l.RecvSource = "net.ipv4.tcp_window_scaling"
+	}
+	return l, nil
+}
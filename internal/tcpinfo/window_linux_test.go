@@ -0,0 +1,40 @@
+package tcpinfo
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestWindowLimitsProc(t *testing.T) {
+	dir := t.TempDir()
+	write := func(name, v string) {
+		p := filepath.Join(dir, "net", "ipv4", name)
+		if err := os.MkdirAll(filepath.Dir(p), 0o755); err != nil {
+			t.Fatal(err)
+		}
+		if err := os.WriteFile(p, []byte(v+"\n"), 0o644); err != nil {
+			t.Fatal(err)
+		}
+	}
+	old := procSys
+	procSys = dir
+	t.Cleanup(func() { procSys = old })
+
+	write("tcp_rmem", "4096\t131072\t6291456")
+	write("tcp_wmem", "4096\t16384\t4194304")
+	l, err := windowLimits()
+	if err != nil || l.RecvMax != 6291456 || l.SendMax != 4194304 || l.RecvSource != "net.ipv4.tcp_rmem" {
+		t.Fatalf("limits = %+v, %v", l, err)
+	}
+
+	write("tcp_window_scaling", "0")
+	if l, err = windowLimits(); err != nil || l.RecvMax != noScaleWindow || l.RecvSource != "net.ipv4.tcp_window_scaling" {
+		t.Errorf("without scaling: %+v, %v", l, err)
+	}
+
+	os.Remove(filepath.Join(dir, "net", "ipv4", "tcp_wmem"))
+	if _, err := windowLimits(); err == nil {
+		t.Error("expected error when tcp_wmem is missing")
+	}
+}
@@ -0,0 +1,7 @@
+//go:build !linux && !darwin
+
+package tcpinfo
+
+func windowLimits() (Limits, error) {
+	return Limits{}, ErrUnsupported
+}
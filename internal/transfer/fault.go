@@ -0,0 +1,144 @@
+package transfer
+
+import (
+	"crypto/tls"
+	"crypto/x509"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"os"
+	"strings"
+	"syscall"
+
+	"github.com/tsosunchia/iNetSpeed-CLI/internal/i18n"
+	"github.com/tsosunchia/iNetSpeed-CLI/internal/report"
+)
+
+// Fault is the category of a request that failed on the network or with an
+// HTTP error status. Its value is the key used in machine-readable output.
+type Fault string
+
+const (
+	FaultDNS     Fault = "dns"          // the host name did not resolve
+	FaultConnect Fault = "connect"      // connection refused or unreachable
+	FaultTLS     Fault = "tls"          // handshake or certificate error
+	FaultStatus  Fault = "http_status"  // the server answered with an error status
+	FaultTimeout Fault = "read_timeout" // the transport timed out waiting for data
+	FaultReset   Fault = "reset"        // the connection or stream was torn down mid-request
+	FaultOther   Fault = "other"
+)
+
+// Faults are the most common first, so a summary lists what matters before
+// the rest.
+var Faults = []Fault{FaultReset, FaultTimeout, FaultStatus, FaultTLS, FaultDNS, FaultConnect, FaultOther}
+
+// StatusError is a response that ended a request with an unusable status:
+// an error status, or anything but 206 Partial Content to a range request.
+type StatusError struct {
+	Code int
+	Want int // 206 for a range request, else 0
+}
+
+func (e *StatusError) Error() string {
+	if e.Want != 0 {
+		return fmt.Sprintf("HTTP %d, not %d", e.Code, e.Want)
+	}
+	return fmt.Sprintf("HTTP %d", e.Code)
+}
+
+// Classify sorts err, which failed a request before its context ended, into
+// a Fault.
+func Classify(err error) Fault {
+	var (
+		status    *StatusError
+		dns       *net.DNSError
+		verify    *tls.CertificateVerificationError
+		record    tls.RecordHeaderError
+		alert     tls.AlertError
+		authority x509.UnknownAuthorityError
+		hostname  x509.HostnameError
+		invalid   x509.CertificateInvalidError
+		ne        net.Error
+	)
+	switch {
+	case err == nil:
+		return ""
+	case errors.As(err, &status):
+		return FaultStatus
+	case errors.As(err, &dns):
+		return FaultDNS
+	case errors.As(err, &verify), errors.As(err, &record), errors.As(err, &alert),
+		errors.As(err, &authority), errors.As(err, &hostname), errors.As(err, &invalid):
+		return FaultTLS
+	case errors.Is(err, syscall.ECONNRESET), errors.Is(err, syscall.ECONNABORTED), errors.Is(err, syscall.EPIPE),
+		errors.Is(err, io.ErrUnexpectedEOF):
+		return FaultReset
+	case errors.Is(err, syscall.ECONNREFUSED), errors.Is(err, syscall.EHOSTUNREACH), errors.Is(err, syscall.ENETUNREACH):
+		return FaultConnect
+	case errors.Is(err, os.ErrDeadlineExceeded), errors.As(err, &ne) && ne.Timeout():
+		return FaultTimeout
+	}
+	// The HTTP/2 transport reports stream resets, GOAWAY and some TLS
+	// failures only as text.
+	msg := err.Error()
+	switch {
+	case strings.Contains(msg, "stream error"), strings.Contains(msg, "GOAWAY"),
+		strings.Contains(msg, "connection reset"), strings.Contains(msg, "unexpected EOF"):
+		return FaultReset
+	case strings.Contains(msg, "tls: "), strings.Contains(msg, "x509: "):
+		return FaultTLS
+	case strings.Contains(msg, "timeout"):
+		return FaultTimeout
+	}
+	return FaultOther
+}
+
+// Explain puts d in words for a non-expert, like "3× connection reset
+// during upload — possible ISP upload policing".
+func Explain(d report.Diagnostic) string {
+	switch d.Finding {
+	case report.ICMPSlower:
+		return fmt.Sprintf(i18n.Text("ICMP latency (%.1f ms) is well above HTTP (%.1f ms): ICMP is likely deprioritized on the path; trust the HTTP figure.",
+			"ICMP 延迟（%.1f 毫秒）明显高于 HTTP（%.1f 毫秒）：路径上可能对 ICMP 降低了优先级，请以 HTTP 结果为准。"), d.ICMPMs, d.HTTPMs)
+	case report.HTTPSlower:
+		return fmt.Sprintf(i18n.Text("HTTP latency (%.1f ms) is well above ICMP (%.1f ms): a proxy or middlebox may be delaying HTTP traffic.",
+			"HTTP 延迟（%.1f 毫秒）明显高于 ICMP（%.1f 毫秒）：可能有代理或中间设备拖慢了 HTTP 流量。"), d.HTTPMs, d.ICMPMs)
+	}
+	up := d.Direction == report.DirUpload
+	during := i18n.Text("during download", "下载期间")
+	if up {
+		during = i18n.Text("during upload", "上传期间")
+	}
+	var what, hint string
+	switch Fault(d.Fault) {
+	case FaultReset:
+		what = i18n.Text("connection reset", "连接被重置")
+		hint = i18n.Text("the path or a middlebox dropped connections mid-transfer", "路径或中间设备在传输中途断开了连接")
+		if up {
+			hint = i18n.Text("possible ISP upload policing", "可能存在运营商上传限速")
+		}
+	case FaultTimeout:
+		what = i18n.Text("read timeout", "读取超时")
+		hint = i18n.Text("the path stalled, as on a congested or lossy link", "路径出现停滞，常见于拥塞或丢包严重的链路")
+	case FaultStatus:
+		what = i18n.Text("HTTP error status", "HTTP 错误状态")
+		hint = i18n.Text("the server rejected or throttled requests; check the test URL", "服务器拒绝或限制了请求，请检查测速地址")
+	case FaultTLS:
+		what = i18n.Text("TLS error", "TLS 错误")
+		hint = i18n.Text("a proxy may be intercepting HTTPS, or the system clock is wrong", "可能有代理拦截 HTTPS，或系统时间不正确")
+	case FaultDNS:
+		what = i18n.Text("DNS failure", "DNS 解析失败")
+		hint = i18n.Text("the resolver is unreliable or blocks the test host", "DNS 服务器不稳定或屏蔽了测速域名")
+	case FaultConnect:
+		what = i18n.Text("connection refused", "连接被拒绝")
+		hint = i18n.Text("a firewall or the endpoint rejects new connections", "防火墙或节点拒绝了新连接")
+	default:
+		what = i18n.Text("network error", "网络错误")
+		hint = i18n.Text("run with --verbose to see each request's error", "使用 --verbose 查看每个请求的错误")
+	}
+	if i18n.IsZH() {
+		return fmt.Sprintf("%s %d× %s — %s", during, d.Count, what, hint)
+	}
+	return fmt.Sprintf("%d× %s %s — %s", d.Count, what, during, hint)
+}
@@ -0,0 +1,83 @@
+package transfer
+
+import (
+	"fmt"
+	"math"
+	"strings"
+	"time"
+
+	"github.com/tsosunchia/iNetSpeed-CLI/internal/config"
+	"github.com/tsosunchia/iNetSpeed-CLI/internal/render"
+)
+
+// intervals prints a round as iperf3 does under --interval: a heading, one
+// line per interval and a total. The layout and words are iperf3's and stay
+// untranslated, so tools that read its output can read this too.
+type intervals struct {
+	bus   *render.Bus
+	tag   string
+	every float64 // seconds
+	from  float64 // start of the current interval, in seconds into the round
+	at    float64 // when the last line was printed
+	bytes int64   // bytes counted by then
+}
+
+// newIntervals prints the heading of the report, or returns nil when every
+// is zero.
+func newIntervals(bus *render.Bus, dir Direction, every time.Duration) *intervals {
+	if every <= 0 {
+		return nil
+	}
+	iv := &intervals{bus: bus, tag: "UL", every: every.Seconds()}
+	if dir == Download {
+		iv.tag = "DL"
+	}
+	bus.Interval("ID", fmt.Sprintf("%-15s  %10s  %12s", "Interval", "Transfer", "Bitrate"), nil)
+	return iv
+}
+
+// tick prints the intervals completed elapsed seconds into the round, when
+// bytes had been counted. Intervals that passed within one tick, as across
+// a suspend, share a line.
+func (iv *intervals) tick(elapsed float64, bytes int64) {
+	if iv == nil || elapsed < iv.from+iv.every {
+		return
+	}
+	to := iv.from + math.Floor((elapsed-iv.from)/iv.every)*iv.every
+	iv.line(iv.from, to, elapsed-iv.at, bytes-iv.bytes, "")
+	iv.from, iv.at, iv.bytes = to, elapsed, bytes
+}
+
+// finish prints what is left of the last interval and the round's total.
+func (iv *intervals) finish(elapsed float64, bytes int64) {
+	if iv == nil {
+		return
+	}
+	if elapsed-iv.from >= 0.01 {
+		iv.line(iv.from, elapsed, elapsed-iv.at, bytes-iv.bytes, "")
+	}
+	iv.bus.Interval("", strings.TrimSpace(strings.Repeat("- ", 25)), nil)
+	end := "sender"
+	if iv.tag == "DL" {
+		end = "receiver"
+	}
+	iv.line(0, elapsed, elapsed, bytes, end)
+}
+
+// line prints the interval from-to, during which n bytes were counted over
+// secs seconds.
+func (iv *intervals) line(from, to, secs float64, n int64, end string) {
+	var mbps float64
+	if secs > 0 {
+		mbps = float64(n) * 8 / (secs * 1_000_000)
+	}
+	// Like the heading, the bitrate stays in iperf3's format whatever
+	// --units says, for the tools that parse it.
+	v := fmt.Sprintf("%6.2f-%-6.2f s  %10s  %7.2f Mbits/sec", from, to, config.HumanBytes(n), mbps)
+	data := map[string]any{"from_s": from, "to_s": to, "bytes": n, "mbps": mbps}
+	if end != "" {
+		v += "  " + end
+		data["total"] = true
+	}
+	iv.bus.Interval(iv.tag, v, data)
+}
@@ -0,0 +1,74 @@
+package transfer
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"io"
+	"net/http"
+	"net/http/httptrace"
+	"time"
+
+	"github.com/tsosunchia/iNetSpeed-CLI/internal/config"
+)
+
+// ReachSize is how much a reachability request downloads or uploads.
+const ReachSize = 1 << 10
+
+// Reach is the outcome of one reachability request.
+type Reach struct {
+	Method string
+	Status int
+	// TTFB is the time from sending the request to the first response byte.
+	TTFB  time.Duration
+	Bytes int64
+}
+
+// CheckURL sends one tiny request of direction dir to url: a GET of the first
+// ReachSize bytes, or an upload of ReachSize bytes with the configured
+// method and headers. It checks that url answers as a round expects without
+// moving test data; a status of 400 or more is a *StatusError.
+func CheckURL(ctx context.Context, client *http.Client, cfg *config.Config, dir Direction, url string) (Reach, error) {
+	ctx, cancel := context.WithTimeout(ctx, time.Duration(cfg.Timeout)*time.Second)
+	defer cancel()
+	var first time.Time
+	ctx = httptrace.WithClientTrace(ctx, &httptrace.ClientTrace{
+		GotFirstResponseByte: func() { first = time.Now() },
+	})
+	rc := Reach{Method: http.MethodGet}
+	var body io.Reader
+	if dir == Upload {
+		rc.Method = uploadMethod(cfg)
+		body = bytes.NewReader(make([]byte, ReachSize))
+	}
+	req, err := http.NewRequestWithContext(ctx, rc.Method, url, body)
+	if err != nil {
+		return rc, err
+	}
+	if dir == Upload {
+		setUploadHeaders(req, rc.Method)
+	} else {
+		req.Header.Set("Range", fmt.Sprintf("bytes=0-%d", ReachSize-1))
+	}
+	config.SetHeader(req, cfg.RequestHeader())
+	start := time.Now()
+	resp, err := client.Do(req)
+	if err != nil {
+		return rc, err
+	}
+	defer resp.Body.Close()
+	n, err := io.Copy(io.Discard, io.LimitReader(resp.Body, ReachSize))
+	if first.IsZero() {
+		first = time.Now()
+	}
+	rc.Status, rc.TTFB = resp.StatusCode, first.Sub(start)
+	if dir == Upload {
+		rc.Bytes = ReachSize
+	} else {
+		rc.Bytes = n
+	}
+	if resp.StatusCode >= 400 {
+		return rc, &StatusError{Code: resp.StatusCode}
+	}
+	return rc, err
+}
@@ -5,14 +5,23 @@ import (
 	"errors"
 	"fmt"
 	"io"
+	"math"
 	"net/http"
+	"strconv"
+	"strings"
 	"sync"
 	"sync/atomic"
 	"time"
 
+	"golang.org/x/sync/errgroup"
+
 	"github.com/tsosunchia/iNetSpeed-CLI/internal/config"
+	"github.com/tsosunchia/iNetSpeed-CLI/internal/cpustat"
 	"github.com/tsosunchia/iNetSpeed-CLI/internal/i18n"
+	"github.com/tsosunchia/iNetSpeed-CLI/internal/payload"
+	"github.com/tsosunchia/iNetSpeed-CLI/internal/ratelimit"
 	"github.com/tsosunchia/iNetSpeed-CLI/internal/render"
+	"github.com/tsosunchia/iNetSpeed-CLI/internal/units"
 )
 
 type Direction int
@@ -22,6 +31,14 @@ const (
 	Upload
 )
 
+// key is the untranslated direction name used in machine-readable output.
+func (d Direction) key() string {
+	if d == Download {
+		return "download"
+	}
+	return "upload"
+}
+
 func (d Direction) String() string {
 	if d == Download {
 		return i18n.Text("Download", "下载")
@@ -36,39 +53,298 @@ type Result struct {
 	Duration   time.Duration
 	Mbps       float64
 	FaultCount int
-	HadFault   bool
+	// FaultKinds counts the faulted requests by category.
+	FaultKinds map[Fault]int
+	// Series is the throughput of each SeriesInterval of the round, in Mbps.
+	Series []float64
+	// Pairs holds each loaded-latency sample that arrived during the round
+	// with the throughput of the interval it arrived in.
+	Pairs []Pair
+	// WorkerBytes is what each thread moved, in thread order.
+	WorkerBytes []int64
+	// Paused is how long the system was suspended during the round, as
+	// seen from gaps between series ticks. Duration and Mbps exclude it.
+	Paused time.Duration
+	// CPUPct is the CPU the whole process used during the round, as a share
+	// of the cores it may use; zero where the platform does not report it.
+	CPUPct float64
+	// Saturated is set when the round ended early because its throughput
+	// held steady for cfg.SaturationWindow; Mbps is then the steady rate
+	// rather than the average over the ramp-up as well.
+	Saturated bool
+}
+
+// HadFault reports whether any request of the round faulted.
+func (r Result) HadFault() bool { return r.FaultCount > 0 }
+
+// Fairness is Jain's fairness index of the workers' byte counts: 1 when
+// every worker moved the same amount, down to 1/n when one moved it all.
+// It is zero when nothing moved.
+func Fairness(bytes []int64) float64 {
+	var sum, sq float64
+	for _, b := range bytes {
+		sum += float64(b)
+		sq += float64(b) * float64(b)
+	}
+	if sq == 0 {
+		return 0
+	}
+	return sum * sum / (float64(len(bytes)) * sq)
+}
+
+// Pair is a latency sample and the throughput at the time it was taken.
+// AtSec is when it was taken, in seconds into the round, at the resolution
+// of SeriesInterval.
+type Pair struct {
+	Mbps  float64
+	RTTMs float64
+	AtSec float64
+}
+
+// SeriesInterval is the resolution of Result.Series. It is fine enough to
+// show the sawtooth of a policer, which a per-round average hides.
+const SeriesInterval = 100 * time.Millisecond
+
+// progressEvery is how many series intervals pass between progress events.
+const progressEvery = 5
+
+// pauseGap is the gap between series ticks taken to mean the process was
+// suspended (laptop sleep, a stopped process, a paused VM) rather than busy.
+const pauseGap = time.Second
+
+// pauseOf reads the gap between two series ticks, as measured by the
+// monotonic and the wall clock, and whether bytes moved across it.
+// Depending on the platform, the monotonic clock either runs through a
+// suspension, leaving a long gap on both clocks, or stops, leaving the wall
+// clock ahead of it; a stepped wall clock looks the same. A long gap on
+// both clocks while bytes kept moving is a process starved of CPU, by GC or
+// on a small router, not a suspension: the transfer went on through it, so
+// it stays in the round. paused is how long a suspension lasted and counted
+// how much of it the monotonic clock, which round durations are taken from,
+// saw.
+func pauseOf(mono, wall time.Duration, moved bool) (paused, counted time.Duration) {
+	if wall-mono <= pauseGap && (moved || mono <= pauseGap) {
+		return 0, 0
+	}
+	if gap := max(mono, wall); gap > pauseGap {
+		paused = gap - SeriesInterval
+	}
+	if mono > pauseGap {
+		counted = mono - SeriesInterval
+	}
+	return paused, counted
+}
+
+// completion is how far a round is along: the larger of the share of its
+// byte target moved and the share of its time limit used, since the round
+// ends at whichever comes first. eta extrapolates the pace so far.
+func completion(bytes, target int64, elapsed, limit time.Duration) (frac float64, eta time.Duration) {
+	if target > 0 {
+		frac = float64(bytes) / float64(target)
+	}
+	if limit > 0 {
+		frac = max(frac, elapsed.Seconds()/limit.Seconds())
+	}
+	frac = min(frac, 1)
+	if frac > 0 {
+		eta = time.Duration(float64(elapsed) * (1 - frac) / frac)
+	}
+	return frac, eta
+}
+
+// saturationTolerance is how far each second of the saturation window may
+// stray from the window's mean.
+const saturationTolerance = 0.03
+
+// errSaturated cancels a round whose throughput has held steady.
+var errSaturated = errors.New("throughput saturated")
+
+// saturated reports whether the last window of series held steady: each
+// one-second average within saturationTolerance of their mean, which it
+// returns. Single intervals are too noisy to compare.
+func saturated(series []float64, window time.Duration) (float64, bool) {
+	per := int(time.Second / SeriesInterval)
+	secs := int(window / time.Second)
+	if secs < 2 || len(series) < secs*per {
+		return 0, false
+	}
+	tail := series[len(series)-secs*per:]
+	avgs := make([]float64, secs)
+	var mean float64
+	for i := range avgs {
+		for _, v := range tail[i*per : (i+1)*per] {
+			avgs[i] += v
+		}
+		avgs[i] /= float64(per)
+		mean += avgs[i]
+	}
+	mean /= float64(secs)
+	if mean <= 0 {
+		return 0, false
+	}
+	for _, a := range avgs {
+		if math.Abs(a-mean) > saturationTolerance*mean {
+			return 0, false
+		}
+	}
+	return mean, true
+}
+
+// LatencySource supplies the latest loaded-latency sample in ms and the
+// number of samples so far, zero before the first one. It is read from the
+// progress goroutine while the prober keeps writing, so implementations must
+// be safe for that.
+type LatencySource interface {
+	Last() (float64, int)
+}
+
+// FlowCounter is told how many of a round's flows are running each time
+// that changes, when the LatencySource passed to RunLimited implements it,
+// so the latency samples can be attributed to the load they were taken
+// under. Calls come from the round's collector and may overlap with those
+// of a round running alongside in the other direction.
+type FlowCounter interface {
+	SetFlows(dir Direction, n int)
 }
 
 func Run(ctx context.Context, client *http.Client, cfg *config.Config,
 	dir Direction, threads int, url string, bus *render.Bus) Result {
+	return RunLimited(ctx, client, cfg, dir, threads, url, bus, nil, nil)
+}
+
+// RunLimited is Run with every worker's traffic passed through gate, which
+// may pace it (--limit-rate) and stop it at a run-wide cap (--max-total).
+// When lat is set, progress lines also show its latest sample and each new
+// sample is paired with the current throughput in Result.Pairs.
+func RunLimited(ctx context.Context, client *http.Client, cfg *config.Config,
+	dir Direction, threads int, url string, bus *render.Bus, gate *ratelimit.Gate, lat LatencySource) Result {
+	return runRound(ctx, client, cfg, dir, make([]time.Duration, threads), time.Duration(cfg.Timeout)*time.Second, url, bus, gate, lat)
+}
+
+// RunSchedule is RunLimited with workers joining the round step by step:
+// steps[0].Threads start it and each later step adds workers until
+// steps[k].Threads run, steps[k].At into the round. The round lasts
+// length, every worker stopping then however late it joined.
+func RunSchedule(ctx context.Context, client *http.Client, cfg *config.Config,
+	dir Direction, steps []config.ThreadStep, length time.Duration, url string, bus *render.Bus, gate *ratelimit.Gate, lat LatencySource) Result {
+	var joins []time.Duration
+	for _, s := range steps {
+		for len(joins) < s.Threads {
+			joins = append(joins, s.At)
+		}
+	}
+	return runRound(ctx, client, cfg, dir, joins, length, url, bus, gate, lat)
+}
+
+// runRound runs one worker per entry of joins, each starting its request
+// that long into the round and ending it timeout into the round.
+func runRound(ctx context.Context, client *http.Client, cfg *config.Config,
+	dir Direction, joins []time.Duration, timeout time.Duration, url string, bus *render.Bus, gate *ratelimit.Gate, lat LatencySource) Result {
 
+	threads := len(joins)
 	maxBytes := cfg.MaxBytes
-	timeout := time.Duration(cfg.Timeout) * time.Second
 
 	var totalBytes int64
-	var faultCount atomic.Int32
-	var wg sync.WaitGroup
+	var active atomic.Int32 // workers whose request is still running
 
 	ctx2, cancel := context.WithTimeout(ctx, timeout+2*time.Second)
 	defer cancel()
+	ctx2, stop := context.WithCancelCause(ctx2)
+	defer stop(nil)
+
+	hdr := cfg.RequestHeader()
+	method := http.MethodGet
+	var src payload.Source
+	if dir == Upload {
+		method = uploadMethod(cfg)
+		src = payloadOf(cfg)
+	}
+
+	// Range mode needs the object's size; servers that won't tell it get
+	// whole-object requests instead.
+	var size int64
+	if dir == Download && cfg.DownloadMode == config.DownloadRange {
+		var err error
+		if size, err = objectSize(ctx2, client, url, hdr); err != nil {
+			bus.Warn(fmt.Sprintf(i18n.Text("Range requests unavailable (%v); downloading whole objects.", "无法使用分段请求（%v），改为下载整个文件。"), err))
+		} else {
+			bus.Debug(fmt.Sprintf(i18n.Text("Range mode: %s object, %s per request", "分段模式: 文件 %s，每次请求 %s"),
+				config.HumanBytes(size), config.HumanBytes(min(size, rangeChunk))))
+		}
+	}
 
 	start := time.Now()
+	cpu := cpustat.Start()
 
+	var series []float64
+	var pairs []Pair
+	var seen int       // latency samples taken before this interval
+	var steady float64 // the steady rate once saturated
+	if lat != nil {
+		_, seen = lat.Last()
+	}
+	// Time suspended, and the part of it the monotonic clock saw; see pauseOf.
+	var paused, counted time.Duration
+	iv := newIntervals(bus, dir, cfg.Interval)
 	progressDone := make(chan struct{})
 	go func() {
 		defer close(progressDone)
-		ticker := time.NewTicker(500 * time.Millisecond)
+		ticker := time.NewTicker(SeriesInterval)
 		defer ticker.Stop()
+		var lastBytes int64
+		lastTick := start
 		for {
 			select {
-			case <-ticker.C:
+			case now := <-ticker.C:
 				cur := atomic.LoadInt64(&totalBytes)
-				elapsed := time.Since(start).Seconds()
-				if elapsed > 0 {
+				p, c := pauseOf(now.Sub(lastTick), now.Round(0).Sub(lastTick.Round(0)), cur > lastBytes)
+				paused, counted = paused+p, counted+c
+				// Nothing was measured across a pause: it gets no series
+				// point, or the policer and shaper checks would see a dip.
+				if d := now.Sub(lastTick).Seconds(); d > 0 && p == 0 {
+					inst := float64(cur-lastBytes) * 8 / (d * 1_000_000)
+					series = append(series, inst)
+					if lat != nil {
+						if ms, n := lat.Last(); n > seen {
+							pairs = append(pairs, Pair{Mbps: inst, RTTMs: ms, AtSec: float64(len(series)) * SeriesInterval.Seconds()})
+							seen = n
+						}
+					}
+				}
+				lastBytes, lastTick = cur, now
+				if cfg.SaturationWindow > 0 && p == 0 && steady == 0 {
+					if m, ok := saturated(series, cfg.SaturationWindow); ok {
+						steady = m
+						stop(errSaturated)
+					}
+				}
+				elapsed := (time.Since(start) - counted).Seconds()
+				if p == 0 {
+					iv.tick(elapsed, cur)
+				}
+				if p == 0 && len(series)%progressEvery == 0 && elapsed > 0 {
 					mbps := float64(cur) * 8 / (elapsed * 1_000_000)
-					bus.Progress(dir.String(),
-						fmt.Sprintf("%.1f Mbps  %s  %.1fs",
-							mbps, config.HumanBytes(cur), elapsed))
+					line := fmt.Sprintf("%s  %s  %.1fs", units.Rate(mbps, 1), config.HumanBytes(cur), elapsed)
+					data := map[string]any{
+						"threads":   threads,
+						"active":    active.Load(),
+						"bytes":     cur,
+						"elapsed_s": elapsed,
+						"mbps":      mbps,
+					}
+					if lat != nil {
+						if ms, n := lat.Last(); n > 0 {
+							line += fmt.Sprintf(i18n.Text("  lat %.0fms", "  延迟 %.0fms"), ms)
+							data["latency_ms"] = ms
+						}
+					}
+					frac, eta := completion(cur, int64(threads)*maxBytes, time.Duration(elapsed*float64(time.Second)), timeout)
+					data["fraction"] = frac
+					// Interval lines take the place of the progress line.
+					if iv == nil {
+						bus.ProgressETA(dir.String(), line, frac, eta)
+					}
+					bus.Sample(dir.key(), data)
 				}
 			case <-ctx2.Done():
 				return
@@ -76,34 +352,105 @@ func Run(ctx context.Context, client *http.Client, cfg *config.Config,
 		}
 	}()
 
-	for i := 0; i < threads; i++ {
-		wg.Add(1)
-		go func() {
-			defer wg.Done()
-			var fault bool
-			if dir == Download {
-				_, fault = doDownload(ctx2, client, url, maxBytes, timeout, &totalBytes)
-			} else {
-				_, fault = doUpload(ctx2, client, url, maxBytes, timeout, &totalBytes)
+	// Workers share nothing but the byte counter: each reports its outcome
+	// on results, which has room for all of them, so none blocks on a
+	// collector that has stopped listening. A fault ends only the worker it
+	// hit, so workers never return an error to the group; it is there to
+	// close results once the last of them has returned.
+	results := make(chan outcome, threads)
+	g, gctx := errgroup.WithContext(ctx2)
+	flows, _ := lat.(FlowCounter)
+	// Workers join and leave from their own goroutines under a schedule;
+	// the lock keeps the flow counts reported in order.
+	var flowMu sync.Mutex
+	setActive := func(delta int32) {
+		flowMu.Lock()
+		defer flowMu.Unlock()
+		if n := active.Add(delta); flows != nil {
+			flows.SetFlows(dir, int(n))
+		}
+	}
+	var first int32
+	for _, j := range joins {
+		if j == 0 {
+			first++
+		}
+	}
+	setActive(first)
+	for i := range threads {
+		g.Go(func() error {
+			o := outcome{worker: i}
+			if join := joins[i]; join > 0 {
+				// A worker due once the round is over, or cut short, never
+				// joins it.
+				t := time.NewTimer(join - time.Since(start))
+				if join < timeout {
+					select {
+					case <-t.C:
+					case <-gctx.Done():
+					}
+				}
+				t.Stop()
+				if join >= timeout || gctx.Err() != nil {
+					o.idle = true
+					results <- o
+					return nil
+				}
+				setActive(1)
 			}
-			if fault {
-				faultCount.Add(1)
+			limit := timeout - joins[i]
+			reqStart := time.Now()
+			switch {
+			case size > 0:
+				o.bytes, o.how, o.err = doRanges(gctx, client, url, hdr, size, int64(i)*rangeChunk, int64(threads)*rangeChunk, maxBytes, limit, &totalBytes, gate)
+			case dir == Download:
+				o.bytes, o.how, o.err = doDownload(gctx, client, url, hdr, maxBytes, limit, &totalBytes, gate)
+			default:
+				o.bytes, o.how, o.err = doUpload(gctx, client, method, cfg.UploadFixedLength, url, hdr, src, maxBytes, limit, &totalBytes, gate)
 			}
-		}()
+			o.took = time.Since(reqStart)
+			results <- o
+			return nil
+		})
 	}
+	go func() {
+		g.Wait()
+		close(results)
+	}()
 
-	wg.Wait()
+	workerBytes := make([]int64, threads)
+	fc := 0
+	var kinds map[Fault]int
+	for o := range results {
+		if o.idle {
+			continue
+		}
+		setActive(-1)
+		workerBytes[o.worker] = o.bytes
+		if o.how == endFault {
+			fc++
+			if kinds == nil {
+				kinds = make(map[Fault]int)
+			}
+			kinds[Classify(o.err)]++
+		}
+		bus.Debug(requestLog(method, o.worker+1, url, o.bytes, o.took, o.how, o.err))
+	}
 	cancel()
 	<-progressDone
 
-	dur := time.Since(start)
+	dur := time.Since(start) - counted
 	total := atomic.LoadInt64(&totalBytes)
 	secs := dur.Seconds()
 	if secs <= 0 {
 		secs = 1
 	}
 	mbps := float64(total) * 8 / (secs * 1_000_000)
-	fc := int(faultCount.Load())
+	if steady > 0 {
+		mbps = steady
+	}
+	cpuPct, _ := cpu.Percent()
+	iv.finish(dur.Seconds(), total)
 
 	return Result{
 		Direction:  dir,
@@ -112,121 +459,401 @@ func Run(ctx context.Context, client *http.Client, cfg *config.Config,
 		Duration:   dur,
 		Mbps:       mbps,
 		FaultCount: fc,
-		HadFault:   fc > 0,
+		FaultKinds: kinds,
+		Series:     series,
+		Pairs:      pairs,
+
+		WorkerBytes: workerBytes,
+		Paused:      paused,
+		CPUPct:      cpuPct,
+		Saturated:   steady > 0,
+	}
+}
+
+// outcome is what a worker reports when its request has finished, or,
+// idle, when the round ended before the worker was due to join.
+type outcome struct {
+	worker int
+	bytes  int64
+	how    end
+	err    error
+	took   time.Duration
+	idle   bool
+}
+
+// end says how a worker's request finished. Only endFault counts toward
+// Result.FaultCount: reaching the round's time limit or being cancelled by
+// the caller cuts short requests that were otherwise healthy.
+type end int
+
+const (
+	endDone      end = iota // body complete, byte cap reached or data cap used up
+	endDeadline             // stopped by the round's time limit
+	endCanceled             // stopped because the caller cancelled the context
+	endSaturated            // stopped because the round's throughput held steady
+	endFault                // network error or HTTP error status
+)
+
+// classify attributes err, returned while a request on ctx was in flight.
+// Once ctx is done, whatever error the transport reports (a reset stream, a
+// closed body) is a consequence of that, not a network fault.
+func classify(ctx context.Context, err error) end {
+	switch {
+	case ctx.Err() == nil:
+		return endFault
+	case errors.Is(ctx.Err(), context.DeadlineExceeded):
+		return endDeadline
+	case errors.Is(context.Cause(ctx), errSaturated):
+		return endSaturated
+	default:
+		return endCanceled
+	}
+}
+
+// payloadOf returns the upload body source of cfg, zeros when Load did not
+// set one.
+func payloadOf(cfg *config.Config) payload.Source {
+	if cfg.Payload == nil {
+		return payload.Zero()
 	}
+	return cfg.Payload
 }
 
-func doDownload(ctx context.Context, client *http.Client, url string, maxBytes int64, timeout time.Duration, shared *int64) (int64, bool) {
+// uploadMethod is the HTTP method of upload requests under cfg.
+func uploadMethod(cfg *config.Config) string {
+	if cfg.UploadMethod == config.UploadPost {
+		return http.MethodPost
+	}
+	return http.MethodPut
+}
+
+func requestLog(method string, worker int, url string, n int64, d time.Duration, how end, err error) string {
+	var status string
+	switch how {
+	case endDone:
+		status = i18n.Text("ok", "成功")
+	case endDeadline:
+		status = i18n.Text("time limit", "到达时限")
+	case endCanceled:
+		status = i18n.Text("cancelled", "已取消")
+	case endSaturated:
+		status = i18n.Text("saturated", "已饱和")
+	default:
+		status = i18n.Text("fault", "故障")
+		if err != nil {
+			status += ": " + err.Error()
+		}
+	}
+	return fmt.Sprintf("#%d %s %s  %s in %.2fs  %s", worker, method, config.Redact(url), config.HumanBytes(n), d.Seconds(), status)
+}
+
+func doDownload(ctx context.Context, client *http.Client, url string, hdr http.Header, maxBytes int64, timeout time.Duration, shared *int64, gate *ratelimit.Gate) (int64, end, error) {
 	ctx2, cancel := context.WithTimeout(ctx, timeout)
 	defer cancel()
 
 	req, err := http.NewRequestWithContext(ctx2, http.MethodGet, url, nil)
 	if err != nil {
-		return 0, true
+		return 0, endFault, err
 	}
-	req.Header.Set("User-Agent", config.UserAgent)
-	req.Header.Set("Accept", "*/*")
-	req.Header.Set("Accept-Language", "zh-CN,zh-Hans;q=0.9")
-	req.Header.Set("Accept-Encoding", "identity")
+	config.SetHeader(req, hdr)
 
 	resp, err := client.Do(req)
 	if err != nil {
-		return 0, true
+		return 0, classify(ctx2, err), err
 	}
 	defer resp.Body.Close()
 	if resp.StatusCode >= 400 {
-		return 0, true
+		return 0, endFault, &StatusError{Code: resp.StatusCode}
 	}
+	return drain(ctx2, gate.Reader(ctx2, resp.Body), maxBytes, shared)
+}
 
-	buf := make([]byte, 256*1024)
-	var total int64
-	fault := false
-	for {
-		n, e := resp.Body.Read(buf)
-		if n > 0 {
-			total += int64(n)
-			atomic.AddInt64(shared, int64(n))
-		}
-		if total >= maxBytes {
-			break
-		}
-		if e != nil {
-			if !errors.Is(e, io.EOF) {
-				fault = true
-			}
-			break
-		}
+// sharedFlush and flushInterval bound how far a round's shared byte count
+// may lag behind what its downloads have read. Adding every read to it at
+// once would have all the workers contend for its cache line; the progress
+// ticker only samples it every SeriesInterval.
+const (
+	sharedFlush   = 1 << 20
+	flushInterval = SeriesInterval / 4
+)
+
+// drain reads body, a response body on ctx, until its end or limit bytes,
+// counting what it reads in shared as it goes.
+func drain(ctx context.Context, body io.Reader, limit int64, shared *int64) (int64, end, error) {
+	bp := payload.GetBuffer()
+	defer payload.PutBuffer(bp)
+	w := &discardCounter{shared: shared, flushed: time.Now()}
+	defer w.flush()
+	n, err := io.CopyBuffer(w, io.LimitReader(body, limit), *bp)
+	if err != nil {
+		return n, classify(ctx, err), err
 	}
-	return total, fault
+	return n, endDone, nil
 }
 
-type zeroReader struct {
-	remaining int64
+// discardCounter discards what downloads read, adding its size to shared
+// in batches; see sharedFlush. It has no ReadFrom, so io.CopyBuffer reads
+// into the pooled buffer rather than one of io.Discard's.
+type discardCounter struct {
+	shared  *int64
+	pending int64
+	flushed time.Time
 }
 
-func (z *zeroReader) Read(p []byte) (int, error) {
-	if z.remaining <= 0 {
-		return 0, io.EOF
+func (w *discardCounter) Write(p []byte) (int, error) {
+	w.pending += int64(len(p))
+	if now := time.Now(); w.pending >= sharedFlush || now.Sub(w.flushed) >= flushInterval {
+		w.flush()
+		w.flushed = now
 	}
-	n := int64(len(p))
-	if n > z.remaining {
-		n = z.remaining
+	return len(p), nil
+}
+
+func (w *discardCounter) flush() {
+	atomic.AddInt64(w.shared, w.pending)
+	w.pending = 0
+}
+
+// rangeChunk is how much of the object a range-mode request asks for.
+const rangeChunk = 8 << 20
+
+// objectSize asks for the first byte of the object at url and returns the
+// size its Content-Range gives.
+func objectSize(ctx context.Context, client *http.Client, url string, hdr http.Header) (int64, error) {
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, url, nil)
+	if err != nil {
+		return 0, err
+	}
+	config.SetHeader(req, hdr)
+	req.Header.Set("Range", "bytes=0-0")
+	resp, err := client.Do(req)
+	if err != nil {
+		return 0, err
+	}
+	defer resp.Body.Close()
+	io.Copy(io.Discard, io.LimitReader(resp.Body, 64<<10))
+	if resp.StatusCode != http.StatusPartialContent {
+		return 0, &StatusError{Code: resp.StatusCode, Want: http.StatusPartialContent}
+	}
+	size, ok := rangeSize(resp.Header.Get("Content-Range"))
+	if !ok {
+		return 0, fmt.Errorf("unusable Content-Range %q", resp.Header.Get("Content-Range"))
+	}
+	return size, nil
+}
+
+// rangeSize returns the complete length in a Content-Range header such as
+// "bytes 0-0/1048576".
+func rangeSize(v string) (int64, bool) {
+	_, total, ok := strings.Cut(v, "/")
+	if !ok || !strings.HasPrefix(v, "bytes ") {
+		return 0, false
 	}
-	for i := int64(0); i < n; i++ {
-		p[i] = 0
+	n, err := strconv.ParseInt(total, 10, 64)
+	return n, err == nil && n > 0
+}
+
+// doRanges downloads up to maxBytes of the size-byte object at url as a
+// series of Range requests, the first at offset first and each next one
+// stride bytes on, wrapping around at the end of the object. Threads that
+// start rangeChunk apart with a stride of threads chunks never ask for the
+// same bytes in one pass over the object.
+func doRanges(ctx context.Context, client *http.Client, url string, hdr http.Header, size, first, stride, maxBytes int64, timeout time.Duration, shared *int64, gate *ratelimit.Gate) (int64, end, error) {
+	ctx2, cancel := context.WithTimeout(ctx, timeout)
+	defer cancel()
+
+	var total int64
+	for off := first % size; total < maxBytes; off = (off + stride) % size {
+		last := min(off+rangeChunk, size, off+maxBytes-total) - 1
+		n, how, err := getRange(ctx2, client, url, hdr, off, last, shared, gate)
+		total += n
+		// A range that ended short hit the data cap or the round's time
+		// limit while paced.
+		if how != endDone || n <= last-off {
+			return total, how, err
+		}
 	}
-	z.remaining -= n
-	return int(n), nil
+	return total, endDone, nil
+}
+
+// getRange downloads bytes first through last of the object at url.
+func getRange(ctx context.Context, client *http.Client, url string, hdr http.Header, first, last int64, shared *int64, gate *ratelimit.Gate) (int64, end, error) {
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, url, nil)
+	if err != nil {
+		return 0, endFault, err
+	}
+	config.SetHeader(req, hdr)
+	req.Header.Set("Range", fmt.Sprintf("bytes=%d-%d", first, last))
+	resp, err := client.Do(req)
+	if err != nil {
+		return 0, classify(ctx, err), err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusPartialContent {
+		return 0, endFault, &StatusError{Code: resp.StatusCode, Want: http.StatusPartialContent}
+	}
+	return drain(ctx, gate.Reader(ctx, resp.Body), last-first+1, shared)
 }
 
 type countingReader struct {
 	r      io.Reader
+	c      io.Closer // optional; closed when the HTTP client is done with the body
 	count  atomic.Int64
 	shared *int64 // shared counter updated atomically during transfer
+	eof    atomic.Bool
+
+	mu      sync.Mutex // orders add against stop
+	stopped bool
+}
+
+// add counts n bytes sent, unless the request has been stopped.
+func (c *countingReader) add(n int) {
+	if n <= 0 {
+		return
+	}
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if c.stopped {
+		return
+	}
+	c.count.Add(int64(n))
+	if c.shared != nil {
+		atomic.AddInt64(c.shared, int64(n))
+	}
+}
+
+// stop freezes the count and returns it. The transport may go on reading
+// the body for a moment after the request has ended; those bytes are not
+// part of the round, and counting them would race with a rollback.
+func (c *countingReader) stop() int64 {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.stopped = true
+	return c.count.Load()
 }
 
 func (c *countingReader) Read(p []byte) (int, error) {
 	n, err := c.r.Read(p)
-	if n > 0 {
-		c.count.Add(int64(n))
-		if c.shared != nil {
-			atomic.AddInt64(c.shared, int64(n))
-		}
+	c.add(n)
+	if errors.Is(err, io.EOF) {
+		c.eof.Store(true)
+	}
+	return n, err
+}
+
+// WriteTo serves io.Copy in the HTTP/1.1 transport. A payload that can
+// write itself, such as the shared zero block, then skips the copy
+// through an intermediate buffer.
+func (c *countingReader) WriteTo(w io.Writer) (int64, error) {
+	cw := &countingWriter{w: w, c: c}
+	var n int64
+	var err error
+	if wt, ok := c.r.(io.WriterTo); ok {
+		n, err = wt.WriteTo(cw)
+	} else {
+		bp := payload.GetBuffer()
+		defer payload.PutBuffer(bp)
+		n, err = io.CopyBuffer(cw, struct{ io.Reader }{c.r}, *bp)
 	}
+	if err == nil {
+		c.eof.Store(true)
+	}
+	return n, err
+}
+
+// countingWriter counts what a payload's WriteTo gets through to w as
+// progress of the countingReader c.
+type countingWriter struct {
+	w io.Writer
+	c *countingReader
+}
+
+func (cw *countingWriter) Write(p []byte) (int, error) {
+	n, err := cw.w.Write(p)
+	cw.c.add(n)
+	return n, err
+}
+
+// ReadFrom keeps w's own ReadFrom, such as a TCP connection's sendfile(2),
+// reachable through the counter.
+func (cw *countingWriter) ReadFrom(r io.Reader) (int64, error) {
+	var n int64
+	var err error
+	if rf, ok := cw.w.(io.ReaderFrom); ok {
+		n, err = rf.ReadFrom(r)
+	} else {
+		bp := payload.GetBuffer()
+		defer payload.PutBuffer(bp)
+		n, err = io.CopyBuffer(struct{ io.Writer }{cw.w}, r, *bp)
+	}
+	cw.c.add(int(n))
 	return n, err
 }
 
-func doUpload(ctx context.Context, client *http.Client, url string, maxBytes int64, timeout time.Duration, shared *int64) (int64, bool) {
+func (c *countingReader) Close() error {
+	if c.c != nil {
+		return c.c.Close()
+	}
+	return nil
+}
+
+// setUploadHeaders marks req as an upload: PUT carries the resumable-upload
+// draft headers Apple's endpoint expects, POST an octet-stream body.
+func setUploadHeaders(req *http.Request, method string) {
+	if method == http.MethodPut {
+		req.Header.Set("Upload-Draft-Interop-Version", "6")
+		req.Header.Set("Upload-Complete", "?1")
+	} else {
+		req.Header.Set("Content-Type", "application/octet-stream")
+	}
+}
+
+// doUpload sends one upload request. With fixedLength the body is announced
+// as maxBytes long; it can still end early when gate's data cap runs out or
+// its pacing would overrun the deadline, and the transport's complaint about
+// the short body is then no fault.
+func doUpload(ctx context.Context, client *http.Client, method string, fixedLength bool, url string, hdr http.Header, src payload.Source, maxBytes int64, timeout time.Duration, shared *int64, gate *ratelimit.Gate) (int64, end, error) {
 	ctx2, cancel := context.WithTimeout(ctx, timeout)
 	defer cancel()
 
+	body, err := src.Open(maxBytes)
+	if err != nil {
+		return 0, endFault, err
+	}
 	cr := &countingReader{
-		r:      &zeroReader{remaining: maxBytes},
+		r:      gate.Reader(ctx2, body),
+		c:      body,
 		shared: shared,
 	}
 
-	req, err := http.NewRequestWithContext(ctx2, http.MethodPut, url, cr)
+	req, err := http.NewRequestWithContext(ctx2, method, url, cr)
 	if err != nil {
-		return 0, true
+		cr.Close()
+		return 0, endFault, err
 	}
 	req.ContentLength = -1
-	req.Header.Set("User-Agent", config.UserAgent)
-	req.Header.Set("Accept", "*/*")
-	req.Header.Set("Accept-Language", "zh-CN,zh-Hans;q=0.9")
-	req.Header.Set("Accept-Encoding", "identity")
-	req.Header.Set("Upload-Draft-Interop-Version", "6")
-	req.Header.Set("Upload-Complete", "?1")
+	if fixedLength {
+		req.ContentLength = maxBytes
+	}
+	setUploadHeaders(req, method)
+	config.SetHeader(req, hdr)
 
 	resp, err := client.Do(req)
 	if err != nil {
-		return cr.count.Load(), true
+		sent := cr.stop()
+		if fixedLength && cr.eof.Load() && sent < maxBytes && ctx2.Err() == nil {
+			return sent, endDone, nil
+		}
+		// Bytes already sent stay counted: when the time limit ends an
+		// upload, that is the normal way for a round to finish.
+		return sent, classify(ctx2, err), err
 	}
 	defer resp.Body.Close()
+	sent := cr.stop()
 	io.Copy(io.Discard, resp.Body)
 	if resp.StatusCode >= 400 {
-		sent := cr.count.Load()
 		atomic.AddInt64(shared, -sent) // rollback shared counter
-		return 0, true
+		return 0, endFault, &StatusError{Code: resp.StatusCode}
 	}
-	return cr.count.Load(), false
+	return sent, endDone, nil
 }
@@ -1,37 +1,39 @@
 package transfer
 
 import (
+	"bytes"
 	"context"
+	"crypto/x509"
+	"errors"
+	"fmt"
 	"io"
+	"math"
+	"net"
 	"net/http"
 	"net/http/httptest"
+	"net/url"
+	"os"
+	"reflect"
+	"runtime"
+	"slices"
+	"sort"
 	"strings"
+	"sync"
+	"sync/atomic"
+	"syscall"
 	"testing"
+	"testing/iotest"
 	"time"
 
 	"github.com/tsosunchia/iNetSpeed-CLI/internal/config"
+	"github.com/tsosunchia/iNetSpeed-CLI/internal/payload"
+	"github.com/tsosunchia/iNetSpeed-CLI/internal/ratelimit"
 	"github.com/tsosunchia/iNetSpeed-CLI/internal/render"
 )
 
-func TestZeroReader(t *testing.T) {
-	r := &zeroReader{remaining: 100}
-	buf := make([]byte, 50)
-	n, err := r.Read(buf)
-	if n != 50 || err != nil {
-		t.Errorf("Read(50) = %d, %v", n, err)
-	}
-	n, err = r.Read(buf)
-	if n != 50 || err != nil {
-		t.Errorf("Read(50) = %d, %v", n, err)
-	}
-	n, err = r.Read(buf)
-	if n != 0 || err != io.EOF {
-		t.Errorf("Read(0) = %d, %v", n, err)
-	}
-}
-
 func TestCountingReader(t *testing.T) {
-	cr := &countingReader{r: &zeroReader{remaining: 200}}
+	body, _ := payload.Zero().Open(200)
+	cr := &countingReader{r: body}
 	buf := make([]byte, 80)
 	cr.Read(buf)
 	cr.Read(buf)
@@ -41,6 +43,48 @@ func TestCountingReader(t *testing.T) {
 	}
 }
 
+// sizedReader hands out at most chunk bytes per read, or fills the buffer
+// when chunk is 0, and records the buffer sizes it was given.
+type sizedReader struct {
+	chunk int
+	sizes []int
+}
+
+func (r *sizedReader) Read(p []byte) (int, error) {
+	r.sizes = append(r.sizes, len(p))
+	if r.chunk > 0 && len(p) > r.chunk {
+		return r.chunk, nil
+	}
+	return len(p), nil
+}
+
+func TestDrain(t *testing.T) {
+	limit := int64(64 << 20)
+	fast := &sizedReader{}
+	var shared int64
+	n, how, err := drain(context.Background(), fast, limit, &shared)
+	if err != nil || how != endDone || n != limit || shared != n {
+		t.Fatalf("drain = %d, %v, %v; shared %d", n, how, err, shared)
+	}
+	if first := fast.sizes[0]; first != payload.BufferSize() {
+		t.Errorf("read into %d bytes, want the pooled %d", first, payload.BufferSize())
+	}
+
+	// TLS hands over one record per read.
+	tlsLike := &sizedReader{chunk: 16 << 10}
+	shared = 0
+	if n, _, _ = drain(context.Background(), tlsLike, 4<<20+1, &shared); n != 4<<20+1 || shared != n {
+		t.Errorf("shared = %d, read %d", shared, n)
+	}
+
+	// A body cut off mid-way is a fault, with what it delivered counted.
+	shared = 0
+	cut := io.MultiReader(strings.NewReader("partial"), iotest.ErrReader(syscall.ECONNRESET))
+	if n, how, err = drain(context.Background(), cut, limit, &shared); n != 7 || shared != 7 || how != endFault || !errors.Is(err, syscall.ECONNRESET) {
+		t.Errorf("cut body: drain = %d, %v, %v; shared %d", n, how, err, shared)
+	}
+}
+
 func newTestBus() *render.Bus {
 	return render.NewBus(render.NewPlainRenderer(&strings.Builder{}))
 }
@@ -70,7 +114,7 @@ func TestDownloadIntegration(t *testing.T) {
 	if res.Mbps <= 0 {
 		t.Error("Mbps <= 0")
 	}
-	if res.HadFault {
+	if res.HadFault() {
 		t.Error("unexpected fault on successful download")
 	}
 	if res.Direction != Download {
@@ -97,17 +141,68 @@ func TestUploadIntegration(t *testing.T) {
 	client := srv.Client()
 
 	res := Run(context.Background(), client, cfg, Upload, 1, srv.URL, bus)
-	if res.TotalBytes == 0 {
-		t.Error("uploaded 0 bytes")
+	// The zero payload goes out through WriteTo; it must count the same.
+	if res.TotalBytes != cfg.MaxBytes || received != cfg.MaxBytes {
+		t.Errorf("uploaded %d bytes, server received %d, want %d", res.TotalBytes, received, cfg.MaxBytes)
 	}
-	if received == 0 {
-		t.Error("server received 0 bytes")
+	if runtime.GOOS == "linux" && res.CPUPct <= 0 {
+		t.Errorf("CPUPct = %v", res.CPUPct)
 	}
-	if res.HadFault {
+	if res.HadFault() {
 		t.Error("unexpected fault on successful upload")
 	}
 }
 
+func TestUploadMethods(t *testing.T) {
+	type seen struct {
+		method, draft, encoding string
+		length                  int64
+	}
+	var got seen
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		io.Copy(io.Discard, r.Body)
+		got = seen{r.Method, r.Header.Get("Upload-Complete"), strings.Join(r.TransferEncoding, ","), r.ContentLength}
+	}))
+	defer srv.Close()
+
+	for _, tc := range []struct {
+		method string
+		fixed  bool
+		want   seen
+	}{
+		{"", false, seen{"PUT", "?1", "chunked", -1}},
+		{config.UploadPost, false, seen{"POST", "", "chunked", -1}},
+		{config.UploadPost, true, seen{"POST", "", "", 64 * 1024}},
+		{config.UploadPut, true, seen{"PUT", "?1", "", 64 * 1024}},
+	} {
+		cfg := &config.Config{MaxBytes: 64 * 1024, Timeout: 5, Max: "64K", UploadMethod: tc.method, UploadFixedLength: tc.fixed}
+		bus := newTestBus()
+		res := Run(context.Background(), srv.Client(), cfg, Upload, 1, srv.URL, bus)
+		bus.Close()
+		if got != tc.want || res.HadFault() || res.TotalBytes != 64*1024 {
+			t.Errorf("%q fixed=%v: server saw %+v, want %+v (sent %d, fault %v)", tc.method, tc.fixed, got, tc.want, res.TotalBytes, res.HadFault())
+		}
+	}
+}
+
+// A data cap cutting a fixed-length body short is the cap at work, not a
+// network fault, even though the transport rejects the short body.
+func TestFixedLengthUploadCutByDataCap(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		io.Copy(io.Discard, r.Body)
+	}))
+	defer srv.Close()
+
+	cfg := &config.Config{MaxBytes: 1 << 20, Timeout: 5, Max: "1M", UploadMethod: config.UploadPost, UploadFixedLength: true}
+	gate := &ratelimit.Gate{Budget: ratelimit.NewBudget(300 * 1024)}
+	bus := newTestBus()
+	defer bus.Close()
+	res := RunLimited(context.Background(), srv.Client(), cfg, Upload, 1, srv.URL, bus, gate, nil)
+	if res.HadFault() || res.TotalBytes != 300*1024 {
+		t.Errorf("sent %d bytes, fault %v; want the 300 KiB cap and no fault", res.TotalBytes, res.HadFault())
+	}
+}
+
 func TestMultiThreadDownload(t *testing.T) {
 	data := make([]byte, 512*1024)
 	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
@@ -131,6 +226,71 @@ func TestMultiThreadDownload(t *testing.T) {
 	if res.Threads != 4 {
 		t.Errorf("Threads = %d", res.Threads)
 	}
+	var sum int64
+	for _, n := range res.WorkerBytes {
+		sum += n
+	}
+	if len(res.WorkerBytes) != 4 || sum != res.TotalBytes {
+		t.Errorf("WorkerBytes = %v, total %d", res.WorkerBytes, res.TotalBytes)
+	}
+}
+
+func TestFairness(t *testing.T) {
+	for _, tc := range []struct {
+		bytes []int64
+		want  float64
+	}{
+		{nil, 0},
+		{[]int64{0, 0}, 0},
+		{[]int64{5, 5, 5, 5}, 1},
+		{[]int64{8, 0, 0, 0}, 0.25},
+		{[]int64{3, 1}, 0.8},
+	} {
+		if got := Fairness(tc.bytes); math.Abs(got-tc.want) > 1e-9 {
+			t.Errorf("Fairness(%v) = %v, want %v", tc.bytes, got, tc.want)
+		}
+	}
+}
+
+func TestPauseOf(t *testing.T) {
+	ms := time.Millisecond
+	for _, tc := range []struct {
+		mono, wall      time.Duration
+		moved           bool
+		paused, counted time.Duration
+	}{
+		{100 * ms, 100 * ms, true, 0, 0},
+		{400 * ms, 400 * ms, false, 0, 0}, // a busy scheduler, not a pause
+		{30 * time.Second, 30 * time.Second, false, 30*time.Second - 100*ms, 30*time.Second - 100*ms},
+		{30 * time.Second, 30 * time.Second, true, 0, 0},                // starved, but bytes kept flowing
+		{100 * ms, 20 * time.Second, false, 20*time.Second - 100*ms, 0}, // monotonic clock stopped
+		{100 * ms, 20 * time.Second, true, 20*time.Second - 100*ms, 0},
+		{100 * ms, -time.Hour, false, 0, 0}, // wall clock stepped back
+	} {
+		paused, counted := pauseOf(tc.mono, tc.wall, tc.moved)
+		if paused != tc.paused || counted != tc.counted {
+			t.Errorf("pauseOf(%v, %v, %v) = %v, %v; want %v, %v", tc.mono, tc.wall, tc.moved, paused, counted, tc.paused, tc.counted)
+		}
+	}
+}
+
+func TestCompletion(t *testing.T) {
+	for _, tc := range []struct {
+		bytes, target  int64
+		elapsed, limit time.Duration
+		frac           float64
+		eta            time.Duration
+	}{
+		{25, 100, time.Second, 10 * time.Second, 0.25, 3 * time.Second},    // bytes ahead of time
+		{10, 100, 5 * time.Second, 10 * time.Second, 0.5, 5 * time.Second}, // time ahead of bytes
+		{200, 100, time.Second, 10 * time.Second, 1, 0},                    // over the target
+		{0, 100, 0, 10 * time.Second, 0, 0},                                // not started
+	} {
+		frac, eta := completion(tc.bytes, tc.target, tc.elapsed, tc.limit)
+		if frac != tc.frac || eta != tc.eta {
+			t.Errorf("completion(%d, %d, %v, %v) = %v, %v; want %v, %v", tc.bytes, tc.target, tc.elapsed, tc.limit, frac, eta, tc.frac, tc.eta)
+		}
+	}
 }
 
 func TestDownloadTimeout(t *testing.T) {
@@ -169,6 +329,41 @@ func TestDownloadTimeout(t *testing.T) {
 	}
 }
 
+func TestUploadRandomPayload(t *testing.T) {
+	var received int64
+	var nonZero bool
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		b, _ := io.ReadAll(r.Body)
+		received = int64(len(b))
+		for _, c := range b {
+			if c != 0 {
+				nonZero = true
+				break
+			}
+		}
+		w.WriteHeader(200)
+	}))
+	defer srv.Close()
+
+	cfg := &config.Config{
+		MaxBytes:      64 * 1024,
+		Timeout:       5,
+		Max:           "64K",
+		UploadPayload: "random",
+		Payload:       payload.Random(),
+	}
+	bus := newTestBus()
+	defer bus.Close()
+
+	res := Run(context.Background(), srv.Client(), cfg, Upload, 1, srv.URL, bus)
+	if res.TotalBytes != 64*1024 || received != 64*1024 {
+		t.Fatalf("sent %d / received %d, want %d", res.TotalBytes, received, 64*1024)
+	}
+	if !nonZero {
+		t.Error("random payload produced only zero bytes")
+	}
+}
+
 func TestDirectionString(t *testing.T) {
 	if Download.String() != "Download" {
 		t.Error("Download.String()")
@@ -195,10 +390,658 @@ func TestUploadBadStatusMarksFault(t *testing.T) {
 	client := srv.Client()
 
 	res := Run(context.Background(), client, cfg, Upload, 1, srv.URL, bus)
-	if !res.HadFault {
+	if !res.HadFault() {
 		t.Fatal("expected fault on HTTP 403 upload")
 	}
-	if res.FaultCount != 1 {
-		t.Fatalf("FaultCount = %d, want 1", res.FaultCount)
+	if res.FaultCount != 1 || res.FaultKinds[FaultStatus] != 1 {
+		t.Fatalf("FaultCount = %d, kinds %v; want 1 http_status", res.FaultCount, res.FaultKinds)
+	}
+}
+
+func TestRequestHeadersAndAuth(t *testing.T) {
+	type seen struct{ method, auth, ua, custom, host string }
+	got := make(chan seen, 2)
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		io.Copy(io.Discard, r.Body)
+		user, pass, _ := r.BasicAuth()
+		if user != "" {
+			got <- seen{r.Method, user + ":" + pass, r.UserAgent(), r.Header.Get("X-Token"), r.Host}
+		} else {
+			got <- seen{r.Method, r.Header.Get("Authorization"), r.UserAgent(), r.Header.Get("X-Token"), r.Host}
+		}
+		w.Write(make([]byte, 1024))
+	}))
+	defer srv.Close()
+
+	cfg := &config.Config{
+		MaxBytes:  64 * 1024,
+		Timeout:   5,
+		Max:       "64K",
+		UserAgent: "bench/1",
+		Headers:   http.Header{"Authorization": {"Bearer abc"}, "X-Token": {"t"}, "Host": {"files.internal"}},
+	}
+	bus := newTestBus()
+	defer bus.Close()
+
+	Run(context.Background(), srv.Client(), cfg, Download, 1, srv.URL, bus)
+	if s := <-got; s != (seen{"GET", "Bearer abc", "bench/1", "t", "files.internal"}) {
+		t.Errorf("download request = %+v", s)
+	}
+
+	// Credentials in the URL become basic auth when no Authorization is set.
+	cfg.Headers = nil
+	authURL := strings.Replace(srv.URL, "http://", "http://alice:s3cret@", 1)
+	Run(context.Background(), srv.Client(), cfg, Upload, 1, authURL, bus)
+	if s := <-got; s.method != "PUT" || s.auth != "alice:s3cret" || s.ua != "bench/1" {
+		t.Errorf("upload request = %+v", s)
+	}
+	if log := requestLog(http.MethodPut, 1, authURL, 0, time.Second, endDone, nil); strings.Contains(log, "s3cret") {
+		t.Errorf("request log leaks the password: %s", log)
+	}
+}
+
+func TestSeries(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		for i := 0; i < 10; i++ {
+			w.Write(make([]byte, 32*1024))
+			w.(http.Flusher).Flush()
+			time.Sleep(50 * time.Millisecond)
+		}
+	}))
+	defer srv.Close()
+	bus := newTestBus()
+	defer bus.Close()
+
+	res := Run(context.Background(), srv.Client(), &config.Config{MaxBytes: 1 << 20, Timeout: 5, Max: "1M"}, Download, 1, srv.URL, bus)
+	if len(res.Series) < 3 {
+		t.Fatalf("series = %v", res.Series)
+	}
+	var sum float64
+	for _, v := range res.Series {
+		sum += v
+	}
+	// Every byte lands in some interval except those after the last tick.
+	if got := sum * 1e6 / 8 * SeriesInterval.Seconds(); got > float64(res.TotalBytes)*1.05 {
+		t.Errorf("series carries %.0f bytes, round %d", got, res.TotalBytes)
+	}
+}
+
+func TestAckedBytes(t *testing.T) {
+	for _, tc := range []struct {
+		header, body string
+		n            int64
+		ok           bool
+	}{
+		{"1048576", "", 1 << 20, true},
+		{"", "  4096\n", 4096, true},
+		{"", `{"ok": true, "received": 2048}`, 2048, true},
+		{"", `{"status": "ok"}`, 0, false},
+		{"", "<html>thanks</html>", 0, false},
+		{"bogus", "", 0, false},
+	} {
+		h := http.Header{}
+		if tc.header != "" {
+			h.Set("Upload-Offset", tc.header)
+		}
+		if n, ok := ackedBytes(h, []byte(tc.body)); n != tc.n || ok != tc.ok {
+			t.Errorf("ackedBytes(%q, %q) = %d, %v", tc.header, tc.body, n, ok)
+		}
+	}
+}
+
+func TestVerifyUpload(t *testing.T) {
+	// The server reads half the body, then claims to be done.
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		n, _ := io.Copy(io.Discard, io.LimitReader(r.Body, r.ContentLength/2))
+		fmt.Fprintf(w, `{"bytes": %d}`, n)
+	}))
+	defer srv.Close()
+	cfg := &config.Config{Timeout: 5, UploadPayload: "zero"}
+	c, err := VerifyUpload(context.Background(), srv.Client(), cfg, srv.URL, 1<<20, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if c.Status != 200 || !c.HasAck || c.Acked != 512<<10 || c.Answered <= 0 || c.Written > c.Answered {
+		t.Errorf("check = %+v", c)
+	}
+}
+
+func TestSaturated(t *testing.T) {
+	flat := func(secs int, v float64) []float64 {
+		out := make([]float64, secs*10)
+		for i := range out {
+			out[i] = v
+		}
+		return out
+	}
+	ramp := append([]float64{10, 50, 200, 400}, flat(3, 500)...)
+	noisy := slices.Clone(ramp)
+	for i := 4; i < len(noisy); i++ {
+		// Noise between intervals averages out within each second.
+		if i%2 == 0 {
+			noisy[i] *= 1.2
+		} else {
+			noisy[i] *= 0.8
+		}
+	}
+	for _, tc := range []struct {
+		name   string
+		series []float64
+		window time.Duration
+		ok     bool
+	}{
+		{"steady", ramp, 3 * time.Second, true},
+		{"noisy intervals", noisy, 3 * time.Second, true},
+		{"too short", flat(2, 500), 3 * time.Second, false},
+		{"ramp in window", append([]float64{100, 100, 100, 100, 100, 100, 100, 100, 100, 100}, flat(2, 500)...), 3 * time.Second, false},
+		{"still rising", append(flat(1, 480), flat(1, 520)...), 2 * time.Second, false},
+		{"idle", flat(3, 0), 3 * time.Second, false},
+	} {
+		mbps, ok := saturated(tc.series, tc.window)
+		if ok != tc.ok || ok && math.Abs(mbps-500) > 1e-9 {
+			t.Errorf("%s: saturated = %v, %v", tc.name, mbps, ok)
+		}
+	}
+}
+
+func TestStopOnSaturation(t *testing.T) {
+	// A steady 16 Mbps, paced by the clock so each second carries the same.
+	const rate = 2 << 20
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		start := time.Now()
+		var sent int
+		buf := make([]byte, 16*1024)
+		for r.Context().Err() == nil {
+			for float64(sent) < time.Since(start).Seconds()*rate {
+				if _, err := w.Write(buf); err != nil {
+					return
+				}
+				sent += len(buf)
+			}
+			w.(http.Flusher).Flush()
+			time.Sleep(5 * time.Millisecond)
+		}
+	}))
+	defer srv.Close()
+	bus := newTestBus()
+	defer bus.Close()
+
+	cfg := &config.Config{MaxBytes: 1 << 30, Timeout: 10, Max: "1G", SaturationWindow: 2 * time.Second}
+	res := Run(context.Background(), srv.Client(), cfg, Download, 1, srv.URL, bus)
+	if !res.Saturated || res.HadFault() || res.Duration > 6*time.Second {
+		t.Fatalf("saturated = %v, faults = %d, duration = %v", res.Saturated, res.FaultCount, res.Duration)
+	}
+	if want := float64(rate) * 8 / 1e6; math.Abs(res.Mbps-want) > 0.1*want {
+		t.Errorf("mbps = %.1f, want about %.1f", res.Mbps, want)
+	}
+}
+
+type fixedLatency float64
+
+func (f fixedLatency) Last() (float64, int) {
+	if f > 0 {
+		return float64(f), 1
+	}
+	return 0, 0
+}
+
+// risingLatency yields a new, larger sample every time it is read.
+type risingLatency struct{ n atomic.Int32 }
+
+func (r *risingLatency) Last() (float64, int) {
+	n := int(r.n.Add(1))
+	return float64(10 * n), n
+}
+
+func TestProgressShowsLatency(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		for i := 0; i < 14; i++ {
+			w.Write(make([]byte, 16*1024))
+			w.(http.Flusher).Flush()
+			time.Sleep(50 * time.Millisecond)
+		}
+	}))
+	defer srv.Close()
+	cfg := &config.Config{MaxBytes: 1 << 20, Timeout: 5, Max: "1M"}
+
+	for _, lat := range []fixedLatency{38, 0} {
+		var out strings.Builder
+		bus := render.NewBus(render.NewPlainRenderer(&out))
+		RunLimited(context.Background(), srv.Client(), cfg, Download, 1, srv.URL, bus, nil, lat)
+		bus.Close()
+		if got := strings.Contains(out.String(), "  lat 38ms"); got != (lat > 0) {
+			t.Errorf("latency %v: progress shows it = %v\n%s", lat, got, out.String())
+		}
+	}
+}
+
+func TestInterruptionIsNotAFault(t *testing.T) {
+	// Downloads trickle and uploads stall once the socket buffers fill, so
+	// every request is still in flight when the round is stopped.
+	stop := make(chan struct{})
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Method == http.MethodPut {
+			<-stop
+			return
+		}
+		buf := make([]byte, 1024)
+		rc := http.NewResponseController(w)
+		for {
+			if _, err := w.Write(buf); err != nil || rc.Flush() != nil {
+				return
+			}
+			time.Sleep(20 * time.Millisecond)
+		}
+	}))
+	defer srv.Close()
+	defer close(stop)
+
+	cfg := &config.Config{MaxBytes: 1 << 30, Timeout: 1, Max: "1G"}
+	for _, tc := range []struct {
+		name   string
+		dir    Direction
+		cancel bool
+		want   string
+	}{
+		{"download deadline", Download, false, "time limit"},
+		{"upload deadline", Upload, false, "time limit"},
+		{"download cancelled", Download, true, "cancelled"},
+		{"upload cancelled", Upload, true, "cancelled"},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			var out strings.Builder
+			bus := render.NewBus(verbosePlain(&out))
+			ctx, cancel := context.WithCancel(context.Background())
+			defer cancel()
+			if tc.cancel {
+				time.AfterFunc(200*time.Millisecond, cancel)
+			}
+			res := Run(ctx, srv.Client(), cfg, tc.dir, 2, srv.URL, bus)
+			bus.Close()
+			if res.HadFault() || res.FaultCount != 0 {
+				t.Errorf("FaultCount = %d, want 0", res.FaultCount)
+			}
+			if res.TotalBytes == 0 {
+				t.Error("bytes moved before the stop were dropped")
+			}
+			if got := strings.Count(out.String(), tc.want); got != 2 {
+				t.Errorf("%q in %d request logs, want 2:\n%s", tc.want, got, out.String())
+			}
+		})
+	}
+}
+
+func TestDroppedConnectionIsAFault(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Length", "1048576")
+		w.Write(make([]byte, 4096))
+		w.(http.Flusher).Flush()
+		conn, _, err := w.(http.Hijacker).Hijack()
+		if err == nil {
+			conn.Close()
+		}
+	}))
+	defer srv.Close()
+
+	var out strings.Builder
+	bus := render.NewBus(verbosePlain(&out))
+	cfg := &config.Config{MaxBytes: 1 << 20, Timeout: 5, Max: "1M"}
+	res := Run(context.Background(), srv.Client(), cfg, Download, 1, srv.URL, bus)
+	bus.Close()
+	if res.FaultCount != 1 || res.TotalBytes != 4096 || res.FaultKinds[FaultReset] != 1 {
+		t.Errorf("FaultCount = %d TotalBytes = %d kinds %v, want 1 reset and 4096", res.FaultCount, res.TotalBytes, res.FaultKinds)
+	}
+	if !strings.Contains(out.String(), "fault: unexpected EOF") {
+		t.Errorf("request log lacks the error:\n%s", out.String())
+	}
+}
+
+func verbosePlain(w io.Writer) *render.PlainRenderer {
+	r := render.NewPlainRenderer(w)
+	r.Verbose = true
+	return r
+}
+
+func TestPairs(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		for i := 0; i < 8; i++ {
+			w.Write(make([]byte, 16*1024))
+			w.(http.Flusher).Flush()
+			time.Sleep(50 * time.Millisecond)
+		}
+	}))
+	defer srv.Close()
+	cfg := &config.Config{MaxBytes: 1 << 20, Timeout: 5, Max: "1M"}
+	bus := newTestBus()
+	defer bus.Close()
+
+	res := RunLimited(context.Background(), srv.Client(), cfg, Download, 1, srv.URL, bus, nil, &risingLatency{})
+	if len(res.Pairs) == 0 || len(res.Pairs) > len(res.Series) {
+		t.Fatalf("%d pairs for %d intervals", len(res.Pairs), len(res.Series))
+	}
+	for i, p := range res.Pairs {
+		if p.Mbps < 0 || i > 0 && p.RTTMs <= res.Pairs[i-1].RTTMs {
+			t.Errorf("pair %d = %+v", i, p)
+		}
+	}
+	if res := Run(context.Background(), srv.Client(), cfg, Download, 1, srv.URL, bus); res.Pairs != nil {
+		t.Errorf("pairs without a latency source: %v", res.Pairs)
+	}
+}
+
+// countingLatency is a LatencySource that records the download flow
+// counts it is told.
+type countingLatency struct {
+	risingLatency
+	mu    sync.Mutex
+	flows []int
+}
+
+func (c *countingLatency) SetFlows(dir Direction, n int) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if dir == Download {
+		c.flows = append(c.flows, n)
+	}
+}
+
+func TestFlowCounter(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Write(make([]byte, 16*1024))
+	}))
+	defer srv.Close()
+	cfg := &config.Config{MaxBytes: 1 << 20, Timeout: 5, Max: "1M"}
+	bus := newTestBus()
+	defer bus.Close()
+
+	lat := &countingLatency{}
+	RunLimited(context.Background(), srv.Client(), cfg, Download, 3, srv.URL, bus, nil, lat)
+	if want := []int{3, 2, 1, 0}; !slices.Equal(lat.flows, want) {
+		t.Errorf("flows %v, want %v", lat.flows, want)
+	}
+}
+
+func TestRunSchedule(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		for r.Context().Err() == nil {
+			w.Write(make([]byte, 16*1024))
+			w.(http.Flusher).Flush()
+			time.Sleep(10 * time.Millisecond)
+		}
+	}))
+	defer srv.Close()
+	cfg := &config.Config{MaxBytes: 1 << 30, Timeout: 5, Max: "1G"}
+	bus := newTestBus()
+	defer bus.Close()
+
+	steps := []config.ThreadStep{{Threads: 1}, {Threads: 2, At: 200 * time.Millisecond}, {Threads: 3, At: 400 * time.Millisecond}}
+	lat := &countingLatency{}
+	res := RunSchedule(context.Background(), srv.Client(), cfg, Download, steps, 600*time.Millisecond, srv.URL, bus, nil, lat)
+	if want := []int{1, 2, 3, 2, 1, 0}; !slices.Equal(lat.flows, want) {
+		t.Errorf("flows %v, want %v", lat.flows, want)
+	}
+	if res.Duration > 2*time.Second || res.TotalBytes == 0 || res.HadFault() {
+		t.Errorf("round took %v, %d bytes, fault %v", res.Duration, res.TotalBytes, res.HadFault())
+	}
+
+	// A worker due after the round has ended never joins it.
+	lat = &countingLatency{}
+	RunSchedule(context.Background(), srv.Client(), cfg, Download, steps, 300*time.Millisecond, srv.URL, bus, nil, lat)
+	if want := []int{1, 2, 1, 0}; !slices.Equal(lat.flows, want) {
+		t.Errorf("flows %v, want %v", lat.flows, want)
+	}
+}
+
+// checkNoLeak fails t when goroutines started after before outlive the
+// round. Servers must be closed first; their handlers exit once the
+// client has disconnected.
+func checkNoLeak(t *testing.T, before int) {
+	t.Helper()
+	deadline := time.Now().Add(3 * time.Second)
+	for runtime.NumGoroutine() > before {
+		if time.Now().After(deadline) {
+			buf := make([]byte, 1<<20)
+			buf = buf[:runtime.Stack(buf, true)]
+			t.Fatalf("%d goroutines leaked:\n%s", runtime.NumGoroutine()-before, buf)
+		}
+		time.Sleep(20 * time.Millisecond)
+	}
+}
+
+// reset aborts the connection behind w with a TCP RST.
+func reset(w http.ResponseWriter) {
+	conn, _, err := w.(http.Hijacker).Hijack()
+	if err != nil {
+		return
+	}
+	if tc, ok := conn.(*net.TCPConn); ok {
+		tc.SetLinger(0)
+	}
+	conn.Close()
+}
+
+func TestNoGoroutineLeak(t *testing.T) {
+	// A handler that does not read the whole upload body does not notice
+	// the client going away; release lets it go before its server is closed.
+	var release chan struct{}
+	stall := func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Length", "1048576")
+		w.(http.Flusher).Flush()
+		<-r.Context().Done()
+	}
+	trickle := func(w http.ResponseWriter, r *http.Request) {
+		rc := http.NewResponseController(w)
+		for {
+			if _, err := w.Write(make([]byte, 512)); err != nil || rc.Flush() != nil {
+				return
+			}
+			time.Sleep(10 * time.Millisecond)
+		}
+	}
+	rst := func(w http.ResponseWriter, r *http.Request) {
+		if r.Method == http.MethodGet {
+			w.Header().Set("Content-Length", "1048576")
+			w.Write(make([]byte, 8192))
+			w.(http.Flusher).Flush()
+		} else {
+			io.CopyN(io.Discard, r.Body, 8192)
+		}
+		reset(w)
+	}
+	slowRead := func(w http.ResponseWriter, r *http.Request) {
+		buf := make([]byte, 512)
+		for {
+			select {
+			case <-release:
+				return
+			case <-time.After(10 * time.Millisecond):
+			}
+			if _, err := r.Body.Read(buf); err != nil {
+				return
+			}
+		}
+	}
+	for _, tc := range []struct {
+		name    string
+		dir     Direction
+		handler http.HandlerFunc
+		cancel  bool
+		fault   bool
+	}{
+		{"stalled download, deadline", Download, stall, false, false},
+		{"stalled download, cancelled", Download, stall, true, false},
+		{"slow download, cancelled", Download, trickle, true, false},
+		{"reset download", Download, rst, false, true},
+		{"stalled upload, cancelled", Upload, func(w http.ResponseWriter, r *http.Request) { <-release }, true, false},
+		{"slow upload, deadline", Upload, slowRead, false, false},
+		{"reset upload", Upload, rst, false, true},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			before := runtime.NumGoroutine()
+			release = make(chan struct{})
+			srv := httptest.NewServer(tc.handler)
+			client := srv.Client()
+			bus := newTestBus()
+			ctx, cancel := context.WithCancel(context.Background())
+			defer cancel()
+			if tc.cancel {
+				time.AfterFunc(300*time.Millisecond, cancel)
+			}
+			cfg := &config.Config{MaxBytes: 1 << 30, Timeout: 1, Max: "1G"}
+			start := time.Now()
+			res := Run(ctx, client, cfg, tc.dir, 4, srv.URL, bus)
+			if took := time.Since(start); took > 3*time.Second {
+				t.Errorf("round took %v", took)
+			}
+			if res.HadFault() != tc.fault {
+				t.Errorf("HadFault = %v, want %v", res.HadFault(), tc.fault)
+			}
+			bus.Close()
+			close(release)
+			client.CloseIdleConnections()
+			srv.Close()
+			checkNoLeak(t, before)
+		})
+	}
+}
+
+func TestUploadRollbackIsExact(t *testing.T) {
+	// The server answers early, while the transport may still be sending
+	// the body: none of it may stay counted.
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		io.CopyN(io.Discard, r.Body, 64*1024)
+		w.WriteHeader(http.StatusServiceUnavailable)
+	}))
+	defer srv.Close()
+	cfg := &config.Config{MaxBytes: 8 << 20, Timeout: 5, Max: "8M"}
+	for range 5 {
+		bus := newTestBus()
+		res := Run(context.Background(), srv.Client(), cfg, Upload, 4, srv.URL, bus)
+		bus.Close()
+		if res.TotalBytes != 0 || res.FaultCount != 4 {
+			t.Fatalf("TotalBytes = %d FaultCount = %d, want 0 and 4", res.TotalBytes, res.FaultCount)
+		}
+	}
+}
+
+func TestRangeDownload(t *testing.T) {
+	obj := bytes.Repeat([]byte{7}, 20<<20)
+	var mu sync.Mutex
+	var ranges []string
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		mu.Lock()
+		ranges = append(ranges, r.Header.Get("Range"))
+		mu.Unlock()
+		http.ServeContent(w, r, "ipsw", time.Time{}, bytes.NewReader(obj))
+	}))
+	defer srv.Close()
+
+	cfg := &config.Config{MaxBytes: 12 << 20, Timeout: 5, Max: "12M", DownloadMode: config.DownloadRange}
+	res := Run(context.Background(), srv.Client(), cfg, Download, 2, srv.URL, newTestBus())
+	if res.HadFault() || res.TotalBytes != 24<<20 {
+		t.Fatalf("TotalBytes = %d, HadFault = %v", res.TotalBytes, res.HadFault())
+	}
+	sort.Strings(ranges)
+	want := []string{
+		"bytes=0-0",
+		"bytes=0-8388607", "bytes=16777216-20971519", // thread 1: a chunk, then the 4 MiB tail
+		"bytes=8388608-16777215", "bytes=4194304-8388607", // thread 2: a chunk, then wraps around for the 4 MiB left of its cap
+	}
+	sort.Strings(want)
+	if !reflect.DeepEqual(ranges, want) {
+		t.Errorf("ranges = %q\nwant %q", ranges, want)
+	}
+}
+
+func TestRangeDownloadFallback(t *testing.T) {
+	data := make([]byte, 1<<20)
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Write(data)
+	}))
+	defer srv.Close()
+
+	var out strings.Builder
+	bus := render.NewBus(render.NewPlainRenderer(&out))
+	cfg := &config.Config{MaxBytes: 4 << 20, Timeout: 5, Max: "4M", DownloadMode: config.DownloadRange}
+	res := Run(context.Background(), srv.Client(), cfg, Download, 2, srv.URL, bus)
+	bus.Close()
+	if res.HadFault() || res.TotalBytes != 2<<20 {
+		t.Errorf("TotalBytes = %d, HadFault = %v", res.TotalBytes, res.HadFault())
+	}
+	if !strings.Contains(out.String(), "Range requests unavailable (HTTP 200, not 206)") {
+		t.Errorf("no fallback warning in\n%s", out.String())
+	}
+}
+
+func TestRangeSize(t *testing.T) {
+	for v, want := range map[string]int64{"bytes 0-0/1048576": 1048576, "bytes 0-0/*": 0, "0-0/10": 0, "": 0} {
+		if got, _ := rangeSize(v); got != want {
+			t.Errorf("rangeSize(%q) = %d, want %d", v, got, want)
+		}
+	}
+}
+
+func TestIntervalReport(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		rc := http.NewResponseController(w)
+		for {
+			if _, err := w.Write(make([]byte, 16<<10)); err != nil || rc.Flush() != nil {
+				return
+			}
+			time.Sleep(5 * time.Millisecond)
+		}
+	}))
+	defer srv.Close()
+
+	var out strings.Builder
+	bus := render.NewBus(render.NewPlainRenderer(&out))
+	cfg := &config.Config{MaxBytes: 1 << 30, Timeout: 1, Max: "1G", Interval: 250 * time.Millisecond}
+	res := Run(context.Background(), srv.Client(), cfg, Download, 2, srv.URL, bus)
+	bus.Close()
+
+	lines := strings.Split(strings.TrimSpace(out.String()), "\n")
+	if len(lines) < 5 || !strings.Contains(lines[0], "[ ID ]  Interval") {
+		t.Fatalf("report:\n%s", out.String())
+	}
+	for i, want := range []string{"  0.00-0.25   s", "  0.25-0.50   s", "  0.50-0.75   s"} {
+		if l := lines[1+i]; !strings.HasPrefix(l, "  [ DL ]  "+want) || !strings.HasSuffix(l, " Mbits/sec") {
+			t.Errorf("line %d = %q, want %q", 1+i, l, want)
+		}
+	}
+	total := lines[len(lines)-1]
+	if !strings.HasPrefix(total, "  [ DL ]    0.00-1.0") || !strings.Contains(total, config.HumanBytes(res.TotalBytes)) ||
+		!strings.HasSuffix(total, " Mbits/sec  receiver") || !strings.HasPrefix(lines[len(lines)-2], "  - - -") {
+		t.Errorf("total:\n%s", out.String())
+	}
+	if strings.Contains(out.String(), "ETA") {
+		t.Errorf("progress lines next to the interval report:\n%s", out.String())
+	}
+}
+
+func TestClassify(t *testing.T) {
+	for _, tc := range []struct {
+		err  error
+		want Fault
+	}{
+		{nil, ""},
+		{&StatusError{Code: 503}, FaultStatus},
+		{fmt.Errorf("get: %w", &StatusError{Code: 200, Want: 206}), FaultStatus},
+		{&url.Error{Op: "Get", Err: &net.OpError{Op: "dial", Err: &net.DNSError{Err: "no such host", Name: "cdn.invalid", IsNotFound: true}}}, FaultDNS},
+		{&url.Error{Op: "Get", Err: x509.UnknownAuthorityError{}}, FaultTLS},
+		{errors.New("remote error: tls: handshake failure"), FaultTLS},
+		{&net.OpError{Op: "read", Err: os.NewSyscallError("read", syscall.ECONNRESET)}, FaultReset},
+		{io.ErrUnexpectedEOF, FaultReset},
+		{errors.New("stream error: stream ID 3; INTERNAL_ERROR; received from peer"), FaultReset},
+		{&net.OpError{Op: "dial", Err: os.NewSyscallError("connect", syscall.ECONNREFUSED)}, FaultConnect},
+		{&net.OpError{Op: "read", Err: os.ErrDeadlineExceeded}, FaultTimeout},
+		{errors.New("net/http: timeout awaiting response headers"), FaultTimeout},
+		{errors.New("something else"), FaultOther},
+	} {
+		if got := Classify(tc.err); got != tc.want {
+			t.Errorf("Classify(%v) = %q, want %q", tc.err, got, tc.want)
+		}
+	}
+	if got := (&StatusError{Code: 200, Want: 206}).Error(); got != "HTTP 200, not 206" {
+		t.Errorf("StatusError = %q", got)
 	}
 }
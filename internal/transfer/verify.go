@@ -0,0 +1,151 @@
+package transfer
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"strconv"
+	"sync/atomic"
+	"time"
+
+	"github.com/tsosunchia/iNetSpeed-CLI/internal/config"
+	"github.com/tsosunchia/iNetSpeed-CLI/internal/ratelimit"
+)
+
+// ackLimit is how much of an upload's response body is searched for a byte
+// count.
+const ackLimit = 64 << 10
+
+// ackKeys are the JSON fields upload services report the received size in.
+var ackKeys = []string{"bytes", "received", "bytes_received", "received_bytes", "size", "length", "content_length"}
+
+// UploadCheck is the outcome of one upload sent to completion: what the
+// client wrote, when it finished writing, and what the server answered and
+// when.
+type UploadCheck struct {
+	Status int
+	Sent   int64
+	// Acked is the byte count the server reported receiving; HasAck is
+	// false when it reported none.
+	Acked  int64
+	HasAck bool
+	// Written is when the last byte went to the transport, Answered when
+	// the response arrived, both from the start of the request. Bytes still
+	// buffered on the way put a gap between them.
+	Written  time.Duration
+	Answered time.Duration
+}
+
+// WriteMbps is the rate the client handed the body to the network, which
+// is what an upload round measures.
+func (c UploadCheck) WriteMbps() float64 { return mbpsOver(c.Sent, c.Written) }
+
+// AckMbps is the rate up to the server's answer: the data is known to have
+// arrived.
+func (c UploadCheck) AckMbps() float64 { return mbpsOver(c.Sent, c.Answered) }
+
+// String describes c for --verbose.
+func (c UploadCheck) String() string {
+	ack := "-"
+	if c.HasAck {
+		ack = config.HumanBytes(c.Acked)
+	}
+	return fmt.Sprintf("HTTP %d  sent %s  acked %s  written %.2fs  answered %.2fs",
+		c.Status, config.HumanBytes(c.Sent), ack, c.Written.Seconds(), c.Answered.Seconds())
+}
+
+func mbpsOver(n int64, d time.Duration) float64 {
+	if d <= 0 {
+		return 0
+	}
+	return float64(n) * 8 / (d.Seconds() * 1_000_000)
+}
+
+// VerifyUpload sends size bytes to url with a Content-Length, so the server
+// has to read the whole body before it answers, and reads its answer for
+// the status and any byte count it acknowledges.
+func VerifyUpload(ctx context.Context, client *http.Client, cfg *config.Config, url string, size int64, gate *ratelimit.Gate) (UploadCheck, error) {
+	ctx2, cancel := context.WithTimeout(ctx, time.Duration(cfg.Timeout+5)*time.Second)
+	defer cancel()
+
+	body, err := payloadOf(cfg).Open(size)
+	if err != nil {
+		return UploadCheck{}, err
+	}
+	defer body.Close()
+	method := uploadMethod(cfg)
+	start := time.Now()
+	tr := &timedReader{r: gate.Reader(ctx2, body), size: size, start: start}
+	req, err := http.NewRequestWithContext(ctx2, method, url, tr)
+	if err != nil {
+		return UploadCheck{}, err
+	}
+	req.ContentLength = size
+	setUploadHeaders(req, method)
+	config.SetHeader(req, cfg.RequestHeader())
+
+	resp, err := client.Do(req)
+	if err != nil {
+		return UploadCheck{}, err
+	}
+	defer resp.Body.Close()
+	c := UploadCheck{
+		Status:   resp.StatusCode,
+		Sent:     tr.n.Load(),
+		Written:  time.Duration(tr.done.Load()),
+		Answered: time.Since(start),
+	}
+	if c.Written == 0 {
+		// The server answered before the body was done.
+		c.Written = c.Answered
+	}
+	b, _ := io.ReadAll(io.LimitReader(resp.Body, ackLimit))
+	c.Acked, c.HasAck = ackedBytes(resp.Header, b)
+	return c, nil
+}
+
+// ackedBytes finds the received byte count in an upload's response: the
+// resumable-upload draft's Upload-Offset header, a body that is just a
+// number, or a JSON object with one of ackKeys.
+func ackedBytes(h http.Header, body []byte) (int64, bool) {
+	if v := h.Get("Upload-Offset"); v != "" {
+		if n, err := strconv.ParseInt(v, 10, 64); err == nil && n >= 0 {
+			return n, true
+		}
+	}
+	body = bytes.TrimSpace(body)
+	if n, err := strconv.ParseInt(string(body), 10, 64); err == nil && n >= 0 {
+		return n, true
+	}
+	var obj map[string]any
+	if json.Unmarshal(body, &obj) != nil {
+		return 0, false
+	}
+	for _, k := range ackKeys {
+		if f, ok := obj[k].(float64); ok && f >= 0 {
+			return int64(f), true
+		}
+	}
+	return 0, false
+}
+
+// timedReader counts an upload body of size bytes and notes when all of
+// it was read.
+type timedReader struct {
+	r     io.Reader
+	size  int64
+	start time.Time
+	n     atomic.Int64
+	done  atomic.Int64 // nanoseconds from start to the last byte; 0 before
+}
+
+func (t *timedReader) Read(p []byte) (int, error) {
+	n, err := t.r.Read(p)
+	if t.n.Add(int64(n)) >= t.size {
+		t.done.CompareAndSwap(0, int64(time.Since(t.start)))
+	}
+	return n, err
+}
@@ -0,0 +1,212 @@
+// Package udpprobe measures latency, jitter and loss over UDP against an
+// echo reflector. Any server that sends datagrams back unchanged works; the
+// one started by `speedtest server` also stamps its receive time into the
+// reply, which splits jitter into its upstream and downstream parts.
+package udpprobe
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/binary"
+	"errors"
+	"math"
+	"net"
+	"sync"
+	"time"
+
+	"github.com/tsosunchia/iNetSpeed-CLI/internal/netx"
+)
+
+// Probe packet layout. The server timestamp is zero on the way out and left
+// zero by plain reflectors.
+const (
+	offMagic   = 0  // 4 bytes
+	offSeq     = 4  // uint32
+	offToken   = 8  // 8 bytes, identifies this run
+	offSent    = 16 // int64 client wall clock, ns
+	offServer  = 24 // int64 server wall clock, ns
+	packetSize = 32
+)
+
+var magic = [4]byte{'i', 'N', 'S', 'u'}
+
+// Result holds what came back from one Measure run. The one-way delays are
+// raw differences between unsynchronized clocks: only their variation, not
+// their level, is meaningful.
+type Result struct {
+	Sent       int
+	Duplicates int
+	Reordered  int       // replies arriving after a later sequence number
+	Samples    []float64 // round-trip times, milliseconds, in arrival order
+	// Up and Down are the client→server and server→client delays in ms,
+	// present only for replies the server timestamped.
+	Up   []float64
+	Down []float64
+}
+
+// Loss is the fraction of packets left unanswered.
+func (r Result) Loss() float64 {
+	if r.Sent == 0 {
+		return 0
+	}
+	return 1 - float64(len(r.Samples))/float64(r.Sent)
+}
+
+// Jitter is the mean absolute difference between consecutive values in the
+// order given, i.e. packet delay variation. A constant clock offset cancels
+// out, so it applies to Up and Down as well.
+func Jitter(vals []float64) float64 {
+	if len(vals) < 2 {
+		return 0
+	}
+	var sum float64
+	for i := 1; i < len(vals); i++ {
+		sum += math.Abs(vals[i] - vals[i-1])
+	}
+	return sum / float64(len(vals)-1)
+}
+
+// Measure sends n packets to addr, one every interval regardless of replies,
+// and waits up to timeout after the last one for stragglers. Packets carry
+// the given TOS byte unless it is zero. It fails only when addr cannot be
+// resolved or dialed; unanswered packets count as loss.
+func Measure(ctx context.Context, addr string, n int, interval, timeout time.Duration, tos int) (Result, error) {
+	d := net.Dialer{Control: netx.TOSControl(tos)}
+	conn, err := d.DialContext(ctx, "udp", addr)
+	if err != nil {
+		return Result{}, err
+	}
+	defer conn.Close()
+	stop := context.AfterFunc(ctx, func() { conn.SetDeadline(time.Now()) })
+	defer stop()
+
+	var token [8]byte
+	rand.Read(token[:])
+
+	var (
+		mu   sync.Mutex
+		res  Result
+		seen = make(map[uint32]bool, n)
+		high = -1
+		done = make(chan struct{})
+	)
+	go func() {
+		defer close(done)
+		buf := make([]byte, 1500)
+		for {
+			m, err := conn.Read(buf)
+			if err != nil {
+				if timedOut(err) {
+					return
+				}
+				// Typically ICMP port unreachable for an earlier send; that
+				// packet is simply lost.
+				continue
+			}
+			now := time.Now()
+			seq, sent, srv, ok := parse(buf[:m], token)
+			if !ok {
+				continue
+			}
+			mu.Lock()
+			switch {
+			case seen[seq]:
+				res.Duplicates++
+			default:
+				seen[seq] = true
+				if int(seq) < high {
+					res.Reordered++
+				} else {
+					high = int(seq)
+				}
+				res.Samples = append(res.Samples, ms(now.UnixNano()-sent))
+				if srv != 0 {
+					res.Up = append(res.Up, ms(srv-sent))
+					res.Down = append(res.Down, ms(now.UnixNano()-srv))
+				}
+			}
+			all := len(seen) == n
+			mu.Unlock()
+			if all {
+				return
+			}
+		}
+	}()
+
+	pkt := make([]byte, packetSize)
+	copy(pkt[offMagic:], magic[:])
+	copy(pkt[offToken:], token[:])
+	sent := 0
+	for seq := 0; seq < n && ctx.Err() == nil; seq++ {
+		if seq > 0 && !sleep(ctx, interval) {
+			break
+		}
+		binary.BigEndian.PutUint32(pkt[offSeq:], uint32(seq))
+		binary.BigEndian.PutUint64(pkt[offSent:], uint64(time.Now().UnixNano()))
+		// A failed send, e.g. after a port unreachable, is a lost packet.
+		conn.Write(pkt)
+		sent++
+	}
+	if ctx.Err() == nil {
+		conn.SetReadDeadline(time.Now().Add(timeout))
+	}
+	<-done
+
+	mu.Lock()
+	defer mu.Unlock()
+	res.Sent = sent
+	return res, nil
+}
+
+// Serve echoes every datagram on conn back to its sender until ctx is done,
+// stamping the receive time into packets that carry the probe magic.
+func Serve(ctx context.Context, conn net.PacketConn) error {
+	stop := context.AfterFunc(ctx, func() { conn.SetDeadline(time.Now()) })
+	defer stop()
+	buf := make([]byte, 65535)
+	for {
+		m, from, err := conn.ReadFrom(buf)
+		if err != nil {
+			if ctx.Err() != nil {
+				return nil
+			}
+			if timedOut(err) {
+				return err
+			}
+			continue
+		}
+		if m >= packetSize && [4]byte(buf[offMagic:offMagic+4]) == magic {
+			binary.BigEndian.PutUint64(buf[offServer:], uint64(time.Now().UnixNano()))
+		}
+		conn.WriteTo(buf[:m], from)
+	}
+}
+
+func parse(b []byte, token [8]byte) (seq uint32, sent, srv int64, ok bool) {
+	if len(b) < packetSize || [4]byte(b[offMagic:offMagic+4]) != magic || [8]byte(b[offToken:offToken+8]) != token {
+		return 0, 0, 0, false
+	}
+	return binary.BigEndian.Uint32(b[offSeq:]),
+		int64(binary.BigEndian.Uint64(b[offSent:])),
+		int64(binary.BigEndian.Uint64(b[offServer:])), true
+}
+
+// timedOut reports a deadline, which Measure and Serve use to stop reading,
+// or a closed socket.
+func timedOut(err error) bool {
+	var ne net.Error
+	return errors.As(err, &ne) && ne.Timeout() || errors.Is(err, net.ErrClosed)
+}
+
+func ms(ns int64) float64 { return float64(ns) / float64(time.Millisecond) }
+
+func sleep(ctx context.Context, d time.Duration) bool {
+	t := time.NewTimer(d)
+	defer t.Stop()
+	select {
+	case <-ctx.Done():
+		return false
+	case <-t.C:
+		return true
+	}
+}
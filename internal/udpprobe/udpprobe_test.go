@@ -0,0 +1,118 @@
+package udpprobe
+
+import (
+	"context"
+	"net"
+	"testing"
+	"time"
+)
+
+func listen(t *testing.T) net.PacketConn {
+	t.Helper()
+	pc, err := net.ListenPacket("udp", "127.0.0.1:0")
+	if err != nil {
+		t.Skipf("no UDP loopback: %v", err)
+	}
+	return pc
+}
+
+func TestMeasureAgainstServe(t *testing.T) {
+	pc := listen(t)
+	ctx, cancel := context.WithCancel(context.Background())
+	served := make(chan error, 1)
+	go func() { served <- Serve(ctx, pc) }()
+
+	res, err := Measure(context.Background(), pc.LocalAddr().String(), 5, 5*time.Millisecond, time.Second, 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if res.Sent != 5 || len(res.Samples) != 5 || res.Loss() != 0 {
+		t.Errorf("result = %+v", res)
+	}
+	if len(res.Up) != 5 || len(res.Down) != 5 {
+		t.Errorf("server timestamps missing: up %v down %v", res.Up, res.Down)
+	}
+	for _, ms := range res.Samples {
+		if ms < 0 || ms > 1000 {
+			t.Errorf("implausible RTT %v ms", ms)
+		}
+	}
+
+	cancel()
+	if err := <-served; err != nil {
+		t.Errorf("Serve after cancel = %v", err)
+	}
+}
+
+func TestMeasurePlainEcho(t *testing.T) {
+	// A reflector that knows nothing of the format: RTT only.
+	pc := listen(t)
+	defer pc.Close()
+	go func() {
+		buf := make([]byte, 1500)
+		for {
+			n, from, err := pc.ReadFrom(buf)
+			if err != nil {
+				return
+			}
+			pc.WriteTo(buf[:n], from)
+		}
+	}()
+
+	res, err := Measure(context.Background(), pc.LocalAddr().String(), 3, time.Millisecond, time.Second, 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(res.Samples) != 3 || len(res.Up) != 0 || len(res.Down) != 0 {
+		t.Errorf("result = %+v", res)
+	}
+}
+
+func TestMeasureLoss(t *testing.T) {
+	// Nothing answers: every packet is lost, and Measure still returns.
+	pc := listen(t)
+	addr := pc.LocalAddr().String()
+	pc.Close()
+
+	start := time.Now()
+	res, err := Measure(context.Background(), addr, 3, time.Millisecond, 50*time.Millisecond, 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if res.Sent != 3 || res.Loss() != 1 {
+		t.Errorf("result = %+v, loss %v", res, res.Loss())
+	}
+	if time.Since(start) > time.Second {
+		t.Errorf("Measure took %v", time.Since(start))
+	}
+}
+
+func TestParse(t *testing.T) {
+	token := [8]byte{1, 2, 3, 4, 5, 6, 7, 8}
+	b := make([]byte, packetSize)
+	copy(b, magic[:])
+	copy(b[offToken:], token[:])
+	b[offSeq+3] = 9
+	seq, _, srv, ok := parse(b, token)
+	if !ok || seq != 9 || srv != 0 {
+		t.Errorf("parse = %d, %d, %v", seq, srv, ok)
+	}
+	if _, _, _, ok := parse(b, [8]byte{}); ok {
+		t.Error("packet of another run accepted")
+	}
+	if _, _, _, ok := parse(b[:packetSize-1], token); ok {
+		t.Error("short packet accepted")
+	}
+}
+
+func TestJitter(t *testing.T) {
+	if got := Jitter([]float64{10, 12, 11, 15}); got != 7.0/3 {
+		t.Errorf("Jitter = %v", got)
+	}
+	if got := Jitter([]float64{1}); got != 0 {
+		t.Errorf("Jitter of one = %v", got)
+	}
+	if got := (Result{Sent: 4, Samples: []float64{1}}).Loss(); got != 0.75 {
+		t.Errorf("Loss = %v", got)
+	}
+}
@@ -0,0 +1,230 @@
+// Package units renders throughput in the unit --units selects and groups
+// the digits of the numbers it writes as the user's locale does. Reports,
+// sinks and the --quiet line keep Mbps whatever the choice: only what is
+// rendered for people changes.
+package units
+
+import (
+	"math"
+	"os"
+	"strconv"
+	"strings"
+	"sync/atomic"
+)
+
+// The units --units accepts. Auto picks Kbps, Mbps or Gbps by magnitude.
+const (
+	Mbps  = "Mbps"
+	MBps  = "MB/s"
+	Mibit = "Mibit"
+	Auto  = "auto"
+)
+
+// Names lists the units in the order usage shows them.
+var Names = []string{Mbps, MBps, Mibit, Auto}
+
+// Parse reads a --units value, in any case and with or without a
+// trailing /s on Mibit.
+func Parse(s string) (string, bool) {
+	switch strings.ToLower(strings.TrimSpace(s)) {
+	case "", "mbps", "mbit/s":
+		return Mbps, true
+	case "mb/s", "mbyte/s":
+		return MBps, true
+	case "mibit", "mibit/s", "mibps":
+		return Mibit, true
+	case "auto":
+		return Auto, true
+	}
+	return "", false
+}
+
+// Format is how a locale writes numbers: the separator between groups of
+// three integer digits and the decimal mark.
+type Format struct {
+	Group   string
+	Decimal string
+}
+
+// The locale formats. Grouping with a space uses a no-break space, so a
+// number never wraps.
+var (
+	formatEN    = Format{",", "."}
+	formatComma = Format{".", ","}
+	formatSpace = Format{"\u00a0", ","}
+	formatCH    = Format{"'", "."}
+)
+
+// localeFormats maps a language to its format; languages not listed write
+// numbers as English does, as Chinese and Japanese also do.
+var localeFormats = map[string]Format{
+	"de": formatComma, "es": formatComma, "it": formatComma, "nl": formatComma, "pt": formatComma,
+	"id": formatComma, "tr": formatComma, "da": formatComma, "el": formatComma, "ro": formatComma,
+	"fr": formatSpace, "ru": formatSpace, "pl": formatSpace, "cs": formatSpace, "sk": formatSpace,
+	"sv": formatSpace, "fi": formatSpace, "nb": formatSpace, "uk": formatSpace, "hu": formatSpace,
+}
+
+var (
+	unit   atomic.Value
+	format atomic.Value
+)
+
+func init() {
+	unit.Store(Mbps)
+	format.Store(formatEN)
+}
+
+// Set selects the unit throughput is rendered in; an unknown one selects
+// Mbps.
+func Set(u string) {
+	if u, ok := Parse(u); ok {
+		unit.Store(u)
+		return
+	}
+	unit.Store(Mbps)
+}
+
+// Current is the unit throughput is rendered in.
+func Current() string {
+	return unit.Load().(string)
+}
+
+// LocaleFormat maps a locale such as de_DE.UTF-8 or fr-CH to its number
+// format.
+func LocaleFormat(locale string) Format {
+	v := strings.ToLower(strings.TrimSpace(locale))
+	if i := strings.IndexAny(v, ".@"); i >= 0 {
+		v = v[:i]
+	}
+	lang, region, _ := strings.Cut(strings.ReplaceAll(v, "_", "-"), "-")
+	if region == "ch" && (lang == "de" || lang == "it") {
+		return formatCH
+	}
+	if f, ok := localeFormats[lang]; ok {
+		return f
+	}
+	return formatEN
+}
+
+// SetLocaleFromEnv takes the number format from the first locale set among
+// LC_ALL, LC_NUMERIC and LANG. The C/POSIX locales write numbers as
+// English does.
+func SetLocaleFromEnv() {
+	for _, k := range []string{"LC_ALL", "LC_NUMERIC", "LANG"} {
+		if v := strings.TrimSpace(os.Getenv(k)); v != "" {
+			format.Store(LocaleFormat(v))
+			return
+		}
+	}
+	format.Store(formatEN)
+}
+
+// SetFormat sets the number format, as tests do.
+func SetFormat(f Format) {
+	format.Store(f)
+}
+
+// Number writes v with prec decimals, grouped as the locale does.
+func Number(v float64, prec int) string {
+	f := format.Load().(Format)
+	s := strconv.FormatFloat(math.Abs(v), 'f', prec, 64)
+	whole, frac, _ := strings.Cut(s, ".")
+	var b strings.Builder
+	if v < 0 && strings.Trim(s, "0.") != "" {
+		b.WriteByte('-')
+	}
+	for i, d := range whole {
+		if i > 0 && (len(whole)-i)%3 == 0 {
+			b.WriteString(f.Group)
+		}
+		b.WriteRune(d)
+	}
+	if frac != "" {
+		b.WriteString(f.Decimal)
+		b.WriteString(frac)
+	}
+	return b.String()
+}
+
+// Convert expresses mbps in the current unit, returning the value, its
+// label and the decimals to show it with, given those Mbps would get.
+// MB/s, eight times coarser, gets a decimal more; auto switches to Gbps
+// with two decimals from 1000 Mbps and to whole Kbps below 1 Mbps.
+func Convert(mbps float64, prec int) (float64, string, int) {
+	return ConvertIn(Current(), mbps, prec)
+}
+
+// ConvertIn is Convert in unit u, such as the Units.Display of a report
+// rendered later, rather than the current unit; a u Parse does not know
+// is Mbps.
+func ConvertIn(u string, mbps float64, prec int) (float64, string, int) {
+	u, _ = Parse(u)
+	switch u {
+	case MBps:
+		return mbps / 8, MBps, prec + 1
+	case Mibit:
+		return mbps * 1e6 / (1 << 20), "Mibit/s", prec
+	case Auto:
+		switch a := math.Abs(mbps); {
+		case a >= 1000:
+			return mbps / 1000, "Gbps", max(prec, 2)
+		case a > 0 && a < 1:
+			return mbps * 1000, "Kbps", 0
+		}
+	}
+	return mbps, Mbps, prec
+}
+
+// Rate renders a throughput given in Mbps, such as "1,234 Mbps" or
+// "154.3 MB/s", with prec decimals at Mbps.
+func Rate(mbps float64, prec int) string {
+	return RateIn(Current(), mbps, prec)
+}
+
+// RateIn is Rate in unit u; see ConvertIn.
+func RateIn(u string, mbps float64, prec int) string {
+	v, label, p := ConvertIn(u, mbps, prec)
+	return Number(v, p) + " " + label
+}
+
+// Value renders a throughput given in Mbps without its label, in the unit
+// Label names, for columns and axes whose heading carries the unit.
+func Value(mbps float64, prec int) string {
+	return ValueIn(Current(), mbps, prec)
+}
+
+// ValueIn is Value in unit u; see ConvertIn.
+func ValueIn(u string, mbps float64, prec int) string {
+	if u, _ := Parse(u); u == Auto {
+		return Number(mbps, prec)
+	}
+	v, _, p := ConvertIn(u, mbps, prec)
+	return Number(v, p)
+}
+
+// In is mbps in the unit LabelIn(u) names, for chart axes.
+func In(u string, mbps float64) float64 {
+	if u, _ := Parse(u); u == Auto {
+		return mbps
+	}
+	v, _, _ := ConvertIn(u, mbps, 0)
+	return v
+}
+
+// Label names the current unit for headings and axes, where auto, having
+// no single unit, shows Mbps.
+func Label() string {
+	return LabelIn(Current())
+}
+
+// LabelIn is Label for unit u; see ConvertIn.
+func LabelIn(u string) string {
+	u, _ = Parse(u)
+	switch u {
+	case MBps:
+		return MBps
+	case Mibit:
+		return "Mibit/s"
+	}
+	return Mbps
+}
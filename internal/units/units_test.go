@@ -0,0 +1,74 @@
+package units
+
+import "testing"
+
+func TestNumber(t *testing.T) {
+	defer SetFormat(formatEN)
+	for _, c := range []struct {
+		locale string
+		v      float64
+		prec   int
+		want   string
+	}{
+		{"C", 1234567.891, 1, "1,234,567.9"},
+		{"en_US.UTF-8", 999, 0, "999"},
+		{"de_DE.UTF-8", 1234.5, 1, "1.234,5"},
+		{"fr_FR", 12345, 0, "12\u00a0345"},
+		{"de_CH", 1234.5, 1, "1'234.5"},
+		{"zh_CN.UTF-8", -1234, 0, "-1,234"},
+		{"ja-JP", -0.01, 1, "0.0"},
+	} {
+		SetFormat(LocaleFormat(c.locale))
+		if got := Number(c.v, c.prec); got != c.want {
+			t.Errorf("%s: Number(%v, %d) = %q, want %q", c.locale, c.v, c.prec, got, c.want)
+		}
+	}
+}
+
+func TestSetLocaleFromEnv(t *testing.T) {
+	defer SetFormat(formatEN)
+	t.Setenv("LC_ALL", "")
+	t.Setenv("LC_NUMERIC", "ru_RU.UTF-8")
+	t.Setenv("LANG", "en_US.UTF-8")
+	SetLocaleFromEnv()
+	if got := Number(1500, 0); got != "1\u00a0500" {
+		t.Errorf("LC_NUMERIC=ru_RU: %q", got)
+	}
+}
+
+func TestRate(t *testing.T) {
+	defer Set(Mbps)
+	for _, c := range []struct {
+		unit string
+		mbps float64
+		want string
+	}{
+		{Mbps, 1234.4, "1,234 Mbps"},
+		{MBps, 1234.4, "154.3 MB/s"},
+		{Mibit, 100, "95 Mibit/s"},
+		{Auto, 2345, "2.35 Gbps"},
+		{Auto, 0.5, "500 Kbps"},
+		{Auto, 94.2, "94 Mbps"},
+		{"bogus", 94.2, "94 Mbps"},
+	} {
+		Set(c.unit)
+		if got := Rate(c.mbps, 0); got != c.want {
+			t.Errorf("%s: Rate(%v) = %q, want %q", c.unit, c.mbps, got, c.want)
+		}
+	}
+	Set(Auto)
+	if Value(2345, 0) != "2,345" || Label() != Mbps {
+		t.Errorf("auto: Value = %q, Label = %q", Value(2345, 0), Label())
+	}
+	Set("MiBit/s")
+	if Current() != Mibit || Label() != "Mibit/s" {
+		t.Errorf("MiBit/s: %q, %q", Current(), Label())
+	}
+	// The In variants ignore the current unit.
+	if got := RateIn(MBps, 1234.4, 0); got != "154.3 MB/s" {
+		t.Errorf("RateIn(MB/s) = %q", got)
+	}
+	if ValueIn(Auto, 2345, 0) != "2,345" || LabelIn("") != Mbps || In(MBps, 80) != 10 || In(Auto, 2345) != 2345 {
+		t.Errorf("In variants: %q %q %v %v", ValueIn(Auto, 2345, 0), LabelIn(""), In(MBps, 80), In(Auto, 2345))
+	}
+}
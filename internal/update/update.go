@@ -0,0 +1,255 @@
+// Package update describes the running build and replaces the binary with
+// the latest GitHub release, checking it against the release's SHA-256
+// checksums first.
+package update
+
+import (
+	"bufio"
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"os"
+	"path/filepath"
+	"runtime"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Build identifies the running binary, as `speedtest version` prints it.
+type Build struct {
+	Version   string `json:"version"`
+	Commit    string `json:"commit"`
+	Date      string `json:"date"`
+	GoVersion string `json:"go_version"`
+	Platform  string `json:"platform"`
+}
+
+// Current describes this binary, given the values the release build links
+// into main.
+func Current(version, commit, date string) Build {
+	return Build{
+		Version:   version,
+		Commit:    commit,
+		Date:      date,
+		GoVersion: runtime.Version(),
+		Platform:  runtime.GOOS + "/" + runtime.GOARCH,
+	}
+}
+
+// ReleaseURL is the GitHub API endpoint of the latest release; tests point
+// it at a local server.
+var ReleaseURL = "https://api.github.com/repos/tsosunchia/iNetSpeed-CLI/releases/latest"
+
+// ChecksumAsset is the release asset listing the SHA-256 of every binary,
+// as scripts/build.sh writes it.
+const ChecksumAsset = "checksums-sha256.txt"
+
+var httpClient = &http.Client{Timeout: 5 * time.Minute}
+
+// Release is a published release: its tag and the download URL of each
+// asset by name.
+type Release struct {
+	Tag    string
+	Assets map[string]string
+}
+
+// Latest looks up the latest published release.
+func Latest(ctx context.Context) (*Release, error) {
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, ReleaseURL, nil)
+	if err != nil {
+		return nil, err
+	}
+	req.Header.Set("Accept", "application/vnd.github+json")
+	resp, err := httpClient.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("releases: HTTP %d", resp.StatusCode)
+	}
+	var out struct {
+		Tag    string `json:"tag_name"`
+		Assets []struct {
+			Name string `json:"name"`
+			URL  string `json:"browser_download_url"`
+		} `json:"assets"`
+	}
+	if err := json.NewDecoder(io.LimitReader(resp.Body, 1<<20)).Decode(&out); err != nil {
+		return nil, fmt.Errorf("releases: %w", err)
+	}
+	if out.Tag == "" {
+		return nil, errors.New("releases: no tag in the latest release")
+	}
+	rel := &Release{Tag: out.Tag, Assets: map[string]string{}}
+	for _, a := range out.Assets {
+		rel.Assets[a.Name] = a.URL
+	}
+	return rel, nil
+}
+
+// AssetName is the release binary for goos/goarch, as scripts/build.sh
+// names it.
+func AssetName(goos, goarch string) string {
+	name := "speedtest-" + goos + "-" + goarch
+	if goos == "windows" {
+		name += ".exe"
+	}
+	return name
+}
+
+// Newer reports whether release tag is a later version than current. Both
+// are read as [v]MAJOR.MINOR.PATCH with an optional -prerelease suffix,
+// which comes before the plain version; a current version that does not
+// parse, such as "dev", is older than any release.
+func Newer(tag, current string) bool {
+	t, tPre, ok := parseVersion(tag)
+	if !ok {
+		return false
+	}
+	c, cPre, ok := parseVersion(current)
+	if !ok {
+		return true
+	}
+	for i := range t {
+		if t[i] != c[i] {
+			return t[i] > c[i]
+		}
+	}
+	return cPre && !tPre
+}
+
+func parseVersion(s string) (v [3]int, pre, ok bool) {
+	s = strings.TrimPrefix(strings.TrimSpace(s), "v")
+	s, suffix, pre := strings.Cut(s, "-")
+	parts := strings.Split(s, ".")
+	if len(parts) > 3 || pre && suffix == "" {
+		return v, pre, false
+	}
+	for i, p := range parts {
+		n, err := strconv.Atoi(p)
+		if err != nil || n < 0 {
+			return v, pre, false
+		}
+		v[i] = n
+	}
+	return v, pre, true
+}
+
+// Download fetches the release's asset for goos/goarch into a temporary
+// file in dir and checks it against the release's checksum list. It
+// returns the path of the verified file, which the caller moves into
+// place or removes.
+func (rel *Release) Download(ctx context.Context, goos, goarch, dir string) (string, error) {
+	asset := AssetName(goos, goarch)
+	binURL, ok := rel.Assets[asset]
+	if !ok {
+		return "", fmt.Errorf("release %s has no binary for %s/%s", rel.Tag, goos, goarch)
+	}
+	sumURL, ok := rel.Assets[ChecksumAsset]
+	if !ok {
+		return "", fmt.Errorf("release %s has no %s", rel.Tag, ChecksumAsset)
+	}
+	want, err := checksum(ctx, sumURL, asset)
+	if err != nil {
+		return "", err
+	}
+
+	body, err := get(ctx, binURL)
+	if err != nil {
+		return "", err
+	}
+	defer body.Close()
+	f, err := os.CreateTemp(dir, ".speedtest-update-*")
+	if err != nil {
+		return "", err
+	}
+	h := sha256.New()
+	_, err = io.Copy(io.MultiWriter(f, h), body)
+	if cerr := f.Close(); err == nil {
+		err = cerr
+	}
+	if err == nil && hex.EncodeToString(h.Sum(nil)) != want {
+		err = fmt.Errorf("checksum mismatch for %s", asset)
+	}
+	if err == nil {
+		err = os.Chmod(f.Name(), 0o755)
+	}
+	if err != nil {
+		os.Remove(f.Name())
+		return "", err
+	}
+	return f.Name(), nil
+}
+
+// checksum reads the SHA-256 of asset from the checksum list at sumURL,
+// lines of "<hex>  <name>" as shasum writes them.
+func checksum(ctx context.Context, sumURL, asset string) (string, error) {
+	body, err := get(ctx, sumURL)
+	if err != nil {
+		return "", err
+	}
+	defer body.Close()
+	sc := bufio.NewScanner(io.LimitReader(body, 1<<20))
+	for sc.Scan() {
+		f := strings.Fields(sc.Text())
+		if len(f) == 2 && strings.TrimPrefix(f[1], "*") == asset && len(f[0]) == sha256.Size*2 {
+			return strings.ToLower(f[0]), nil
+		}
+	}
+	if err := sc.Err(); err != nil {
+		return "", err
+	}
+	return "", fmt.Errorf("no checksum for %s in %s", asset, ChecksumAsset)
+}
+
+func get(ctx context.Context, url string) (io.ReadCloser, error) {
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, url, nil)
+	if err != nil {
+		return nil, err
+	}
+	resp, err := httpClient.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	if resp.StatusCode != http.StatusOK {
+		resp.Body.Close()
+		return nil, fmt.Errorf("%s: HTTP %d", filepath.Base(url), resp.StatusCode)
+	}
+	return resp.Body, nil
+}
+
+// Replace moves the verified binary at path over exe. Windows cannot
+// overwrite a running executable but can rename it, so there the old
+// binary is first moved aside to exe.old.
+func Replace(exe, path string) error {
+	if runtime.GOOS == "windows" {
+		old := exe + ".old"
+		os.Remove(old)
+		if err := os.Rename(exe, old); err != nil {
+			return err
+		}
+		if err := os.Rename(path, exe); err != nil {
+			os.Rename(old, exe)
+			return err
+		}
+		return nil
+	}
+	return os.Rename(path, exe)
+}
+
+// Executable is the path of the running binary with symlinks resolved, so
+// an update replaces the file rather than a link to it.
+func Executable() (string, error) {
+	exe, err := os.Executable()
+	if err != nil {
+		return "", err
+	}
+	return filepath.EvalSymlinks(exe)
+}
@@ -0,0 +1,129 @@
+package update
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"runtime"
+	"strings"
+	"testing"
+)
+
+// releaseServer serves a latest release holding bin as the asset for
+// linux/amd64, listed in the checksums with sum.
+func releaseServer(t *testing.T, bin []byte, sum string) *httptest.Server {
+	t.Helper()
+	var srv *httptest.Server
+	srv = httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch r.URL.Path {
+		case "/latest":
+			json.NewEncoder(w).Encode(map[string]any{
+				"tag_name": "v1.4.0",
+				"assets": []map[string]string{
+					{"name": "speedtest-linux-amd64", "browser_download_url": srv.URL + "/speedtest-linux-amd64"},
+					{"name": ChecksumAsset, "browser_download_url": srv.URL + "/" + ChecksumAsset},
+				},
+			})
+		case "/speedtest-linux-amd64":
+			w.Write(bin)
+		case "/" + ChecksumAsset:
+			fmt.Fprintf(w, "%s  speedtest-darwin-arm64\n%s  speedtest-linux-amd64\n", strings.Repeat("0", 64), sum)
+		default:
+			http.NotFound(w, r)
+		}
+	}))
+	old := ReleaseURL
+	ReleaseURL = srv.URL + "/latest"
+	t.Cleanup(func() {
+		ReleaseURL = old
+		srv.Close()
+	})
+	return srv
+}
+
+func TestDownload(t *testing.T) {
+	bin := []byte("#!/bin/sh\necho new\n")
+	h := sha256.Sum256(bin)
+	releaseServer(t, bin, hex.EncodeToString(h[:]))
+	rel, err := Latest(context.Background())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if rel.Tag != "v1.4.0" || len(rel.Assets) != 2 {
+		t.Fatalf("release = %+v", rel)
+	}
+	dir := t.TempDir()
+	if _, err := rel.Download(context.Background(), "windows", "amd64", dir); err == nil {
+		t.Error("no error for a platform without a binary")
+	}
+	path, err := rel.Download(context.Background(), "linux", "amd64", dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got, err := os.ReadFile(path); err != nil || string(got) != string(bin) {
+		t.Fatalf("downloaded %q, %v", got, err)
+	}
+	exe := filepath.Join(dir, "speedtest")
+	if err := os.WriteFile(exe, []byte("old"), 0o755); err != nil {
+		t.Fatal(err)
+	}
+	if err := Replace(exe, path); err != nil {
+		t.Fatal(err)
+	}
+	if got, _ := os.ReadFile(exe); string(got) != string(bin) {
+		t.Errorf("replaced binary = %q", got)
+	}
+	if runtime.GOOS != "windows" {
+		if fi, err := os.Stat(exe); err != nil || fi.Mode().Perm() != 0o755 {
+			t.Errorf("mode = %v, %v", fi.Mode(), err)
+		}
+	}
+}
+
+func TestDownloadChecksumMismatch(t *testing.T) {
+	releaseServer(t, []byte("tampered"), strings.Repeat("ab", 32))
+	rel, err := Latest(context.Background())
+	if err != nil {
+		t.Fatal(err)
+	}
+	dir := t.TempDir()
+	if _, err := rel.Download(context.Background(), "linux", "amd64", dir); err == nil || !strings.Contains(err.Error(), "checksum mismatch") {
+		t.Fatalf("err = %v", err)
+	}
+	if left, _ := os.ReadDir(dir); len(left) != 0 {
+		t.Errorf("left behind %v", left)
+	}
+}
+
+func TestNewer(t *testing.T) {
+	for _, tc := range []struct {
+		tag, current string
+		want         bool
+	}{
+		{"v1.4.0", "v1.3.9", true},
+		{"v1.4.0", "1.4.0", false},
+		{"v1.10.0", "v1.9.2", true},
+		{"v1.4.0", "v1.4.1", false},
+		{"v2.0.0", "v2.0.0-rc1", true},
+		{"v2.0.0-rc2", "v2.0.0", false},
+		{"v1.4.0", "dev", true},
+		{"nightly", "v1.0.0", false},
+	} {
+		if got := Newer(tc.tag, tc.current); got != tc.want {
+			t.Errorf("Newer(%q, %q) = %v, want %v", tc.tag, tc.current, got, tc.want)
+		}
+	}
+}
+
+func TestCurrent(t *testing.T) {
+	b := Current("v1.2.3", "abc1234", "2026-01-02T03:04:05Z")
+	if b.Version != "v1.2.3" || b.GoVersion != runtime.Version() || b.Platform != runtime.GOOS+"/"+runtime.GOARCH {
+		t.Errorf("build = %+v", b)
+	}
+}
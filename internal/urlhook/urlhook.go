@@ -0,0 +1,121 @@
+// Package urlhook obtains test URLs from an external hook, for endpoints
+// that need short-lived signed URLs or rotating tokens. A hook is either an
+// http(s) URL answering a GET, or a command run without a shell; both
+// produce the same JSON document:
+//
+//	{"dl_url": "...", "ul_url": "...", "latency_url": "...", "expires_in": 300}
+//
+// Missing URLs keep their configured value. Expiry is optional and may be
+// given as expires_in seconds or an RFC 3339 expires_at.
+package urlhook
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"os/exec"
+	"strings"
+	"time"
+)
+
+// timeout bounds a single hook call.
+const timeout = 15 * time.Second
+
+// URLs is the hook's answer. Expires is zero when the URLs do not expire.
+type URLs struct {
+	DLURL      string    `json:"dl_url"`
+	ULURL      string    `json:"ul_url"`
+	LatencyURL string    `json:"latency_url"`
+	Expires    time.Time `json:"expires_at"`
+	ExpiresIn  float64   `json:"expires_in"`
+}
+
+// IsHTTP reports whether spec names an HTTP hook rather than a command.
+func IsHTTP(spec string) bool {
+	return strings.HasPrefix(spec, "http://") || strings.HasPrefix(spec, "https://")
+}
+
+// Fetch calls the hook and validates its answer.
+func Fetch(ctx context.Context, spec string) (URLs, error) {
+	ctx, cancel := context.WithTimeout(ctx, timeout)
+	defer cancel()
+	var body []byte
+	var err error
+	if IsHTTP(spec) {
+		body, err = fetchHTTP(ctx, spec)
+	} else {
+		body, err = run(ctx, spec)
+	}
+	if err != nil {
+		return URLs{}, err
+	}
+	return parse(body, time.Now())
+}
+
+func fetchHTTP(ctx context.Context, url string) ([]byte, error) {
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, url, nil)
+	if err != nil {
+		return nil, err
+	}
+	req.Header.Set("Accept", "application/json")
+	resp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+	body, err := io.ReadAll(io.LimitReader(resp.Body, 1<<20))
+	if err != nil {
+		return nil, err
+	}
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("HTTP %d", resp.StatusCode)
+	}
+	return body, nil
+}
+
+func run(ctx context.Context, spec string) ([]byte, error) {
+	args := strings.Fields(spec)
+	if len(args) == 0 {
+		return nil, errors.New("empty hook command")
+	}
+	var stderr bytes.Buffer
+	cmd := exec.CommandContext(ctx, args[0], args[1:]...)
+	cmd.Stderr = &stderr
+	out, err := cmd.Output()
+	if err != nil {
+		if msg := strings.TrimSpace(stderr.String()); msg != "" {
+			return nil, fmt.Errorf("%w: %s", err, msg)
+		}
+		return nil, err
+	}
+	return out, nil
+}
+
+func parse(body []byte, now time.Time) (URLs, error) {
+	var u URLs
+	if err := json.Unmarshal(body, &u); err != nil {
+		return URLs{}, fmt.Errorf("invalid hook output: %w", err)
+	}
+	for _, v := range []string{u.DLURL, u.ULURL, u.LatencyURL} {
+		if v != "" && !IsHTTP(v) {
+			return URLs{}, fmt.Errorf("hook URL %q must start with http(s)://", v)
+		}
+	}
+	if u.DLURL == "" && u.ULURL == "" && u.LatencyURL == "" {
+		return URLs{}, errors.New("hook returned no URLs")
+	}
+	if u.Expires.IsZero() && u.ExpiresIn > 0 {
+		u.Expires = now.Add(time.Duration(u.ExpiresIn * float64(time.Second)))
+	}
+	return u, nil
+}
+
+// Stale reports whether URLs expiring at exp should be renewed before work
+// that may last d starting at now.
+func Stale(exp time.Time, now time.Time, d time.Duration) bool {
+	return !exp.IsZero() && !now.Add(d).Before(exp)
+}
@@ -0,0 +1,75 @@
+package urlhook
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"os/exec"
+	"testing"
+	"time"
+)
+
+func TestParse(t *testing.T) {
+	now := time.Date(2026, 5, 1, 12, 0, 0, 0, time.UTC)
+	u, err := parse([]byte(`{"dl_url":"https://cdn.example/large?sig=1","expires_in":300}`), now)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if u.DLURL != "https://cdn.example/large?sig=1" || u.ULURL != "" || !u.Expires.Equal(now.Add(5*time.Minute)) {
+		t.Errorf("parse = %+v", u)
+	}
+	u, err = parse([]byte(`{"ul_url":"http://x/up","expires_at":"2026-05-01T13:00:00Z","expires_in":5}`), now)
+	if err != nil || !u.Expires.Equal(now.Add(time.Hour)) {
+		t.Errorf("expires_at should win: %+v, %v", u, err)
+	}
+	for _, body := range []string{`{}`, `{"dl_url":"ftp://x"}`, `not json`} {
+		if _, err := parse([]byte(body), now); err == nil {
+			t.Errorf("parse(%s): expected error", body)
+		}
+	}
+}
+
+func TestStale(t *testing.T) {
+	now := time.Now()
+	if Stale(time.Time{}, now, time.Hour) {
+		t.Error("URLs without expiry are never stale")
+	}
+	if Stale(now.Add(time.Minute), now, 30*time.Second) {
+		t.Error("URL valid past the work reported stale")
+	}
+	if !Stale(now.Add(time.Minute), now, 90*time.Second) {
+		t.Error("URL expiring mid-work not reported stale")
+	}
+}
+
+func TestFetchHTTP(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path != "/urls" {
+			http.NotFound(w, r)
+			return
+		}
+		w.Write([]byte(`{"dl_url":"https://cdn.example/large?token=abc"}`))
+	}))
+	defer srv.Close()
+
+	u, err := Fetch(context.Background(), srv.URL+"/urls")
+	if err != nil || u.DLURL != "https://cdn.example/large?token=abc" {
+		t.Errorf("Fetch = %+v, %v", u, err)
+	}
+	if _, err := Fetch(context.Background(), srv.URL+"/missing"); err == nil {
+		t.Error("404 hook accepted")
+	}
+}
+
+func TestFetchCommand(t *testing.T) {
+	if _, err := exec.LookPath("echo"); err != nil {
+		t.Skip("no echo command")
+	}
+	u, err := Fetch(context.Background(), `echo {"latency_url":"https://cdn.example/small"}`)
+	if err != nil || u.LatencyURL != "https://cdn.example/small" {
+		t.Errorf("Fetch = %+v, %v", u, err)
+	}
+	if _, err := Fetch(context.Background(), "false"); err == nil {
+		t.Error("failing command accepted")
+	}
+}